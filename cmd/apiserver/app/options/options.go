@@ -70,6 +70,8 @@ func (s *ServerRunOptions) Flags() (fss cliflag.NamedFlagSets) {
 	s.S3Options.AddFlags(fss.FlagSet("s3"), s.S3Options)
 	s.ArgoCDOption.AddFlags(fss.FlagSet("argocd"), s.ArgoCDOption)
 	s.FluxCDOption.AddFlags(fss.FlagSet("fluxcd"), s.FluxCDOption)
+	s.PipelineRunDataStore.AddFlags(fss.FlagSet("pipelinerun"), s.PipelineRunDataStore)
+	s.VaultOptions.AddFlags(fss.FlagSet("vault"), s.VaultOptions)
 
 	fs = fss.FlagSet("klog")
 	local := flag.NewFlagSet("klog", flag.ExitOnError)
@@ -91,7 +91,7 @@ func Run(s *options.ServerRunOptions, stopCh context.Context) error {
 
 	err = apiserver.PrepareRun(stopCh.Done())
 	if err != nil {
-		return nil
+		return err
 	}
 
 	return apiserver.Run(stopCh)
@@ -19,14 +19,20 @@ package app
 import (
 	"kubesphere.io/devops/controllers/addon"
 	"kubesphere.io/devops/controllers/argocd"
+	"kubesphere.io/devops/controllers/drift"
 	"kubesphere.io/devops/controllers/fluxcd"
+	"kubesphere.io/devops/controllers/generator"
 	"kubesphere.io/devops/controllers/gitrepository"
 	"kubesphere.io/devops/controllers/jenkins/devopscredential"
 	"kubesphere.io/devops/controllers/jenkins/devopsproject"
+	"kubesphere.io/devops/controllers/notification"
+	"kubesphere.io/devops/controllers/promotion"
 	"kubesphere.io/devops/pkg/jwt/token"
 	"kubesphere.io/devops/pkg/server/errors"
+	"kubesphere.io/devops/pkg/store/backend"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	"kubesphere.io/devops/cmd/controller/app/options"
 	"kubesphere.io/devops/controllers/jenkins/config"
@@ -34,6 +40,10 @@ import (
 	"kubesphere.io/devops/controllers/jenkins/pipelinerun"
 	"kubesphere.io/devops/pkg/client/devops"
 	"kubesphere.io/devops/pkg/client/k8s"
+	"kubesphere.io/devops/pkg/client/s3"
+	"kubesphere.io/devops/pkg/credential"
+	"kubesphere.io/devops/pkg/event/cloudevents"
+	gitopsnotification "kubesphere.io/devops/pkg/event/notification"
 	"kubesphere.io/devops/pkg/informers"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -48,6 +58,32 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 	reconcilers := getAllControllers(mgr, client, informerFactory, devopsClient, s, jenkinsCore)
 	reconcilers["pipeline"] = func(mgr manager.Manager) (err error) {
 		tokenIssuer := token.NewTokenIssuer(s.JWTOptions.Secret, s.JWTOptions.MaximumClockSkew)
+		dataStoreOption := &backend.Option{
+			Dir: s.FeatureOptions.PipelineRunDataStoreDir,
+		}
+		if s.S3Options != nil && s.S3Options.Endpoint != "" {
+			if dataStoreOption.S3Client, err = s3.NewS3Client(s.S3Options); err != nil {
+				klog.Errorf("unable to create the s3 client for the PipelineRun data store, err: %v", err)
+				return
+			}
+		}
+		var eventPublisher *cloudevents.Publisher
+		if len(s.FeatureOptions.PipelineRunEventSinks) > 0 {
+			eventPublisher = cloudevents.NewPublisher(s.FeatureOptions.PipelineRunEventSinks)
+			if err = mgr.Add(eventPublisher); err != nil {
+				klog.Errorf("unable to add the PipelineRun event publisher, err: %v", err)
+				return
+			}
+		}
+		var stepRequests, stepLimits corev1.ResourceList
+		if stepRequests, err = options.ParseResourceList(s.FeatureOptions.KubernetesEngineStepRequests); err != nil {
+			klog.Errorf("invalid resource requests of the Kubernetes engine steps, err: %v", err)
+			return
+		}
+		if stepLimits, err = options.ParseResourceList(s.FeatureOptions.KubernetesEngineStepLimits); err != nil {
+			klog.Errorf("invalid resource limits of the Kubernetes engine steps, err: %v", err)
+			return
+		}
 		// add PipelineRun controller
 		if err = (&pipelinerun.Reconciler{
 			Client:               mgr.GetClient(),
@@ -56,6 +92,18 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 			JenkinsCore:          jenkinsCore,
 			TokenIssuer:          tokenIssuer,
 			PipelineRunDataStore: s.FeatureOptions.PipelineRunDataStore,
+			DataStoreOption:      dataStoreOption,
+			DataRetention:        s.FeatureOptions.PipelineRunDataRetention,
+			KubernetesEngineOption: &pipelinerun.KubernetesEngineOption{
+				Image:                 s.FeatureOptions.KubernetesEngineImage,
+				ContainerImages:       s.FeatureOptions.KubernetesEngineContainerImages,
+				WorkspaceStorageClass: s.FeatureOptions.KubernetesEngineWorkspaceStorageClass,
+				WorkspaceSize:         s.FeatureOptions.KubernetesEngineWorkspaceSize,
+				WorkspaceAccessMode:   corev1.PersistentVolumeAccessMode(s.FeatureOptions.KubernetesEngineWorkspaceAccessMode),
+				StepRequests:          stepRequests,
+				StepLimits:            stepLimits,
+			},
+			EventPublisher: eventPublisher,
 		}).SetupWithManager(mgr); err != nil {
 			klog.Errorf("unable to create pipelinerun-controller, err: %v", err)
 			return
@@ -142,6 +190,25 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 	fluxcdAppStatusReconciler := &fluxcd.ApplicationStatusReconciler{
 		Client: mgr.GetClient(),
 	}
+	fluxcdImageUpdaterReconciler := &fluxcd.ImageUpdaterReconciler{
+		Client: mgr.GetClient(),
+	}
+	fluxcdChartRepoReconciler := &fluxcd.ChartRepositoryReconciler{
+		Client: mgr.GetClient(),
+	}
+	promotionReconciler := &promotion.Reconciler{
+		Client: mgr.GetClient(),
+	}
+	generatorReconciler := &generator.Reconciler{
+		Client: mgr.GetClient(),
+	}
+	driftReconciler := &drift.Reconciler{
+		Client:   mgr.GetClient(),
+		Interval: s.FeatureOptions.DriftDetectionInterval,
+	}
+	notificationReconciler := &notification.Reconciler{
+		Client: mgr.GetClient(),
+	}
 
 	return map[string]func(mgr manager.Manager) error{
 		gitRepoReconcilers.GetName(): func(mgr manager.Manager) error {
@@ -184,10 +251,15 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 			}, s.JenkinsOptions))
 		},
 		"jenkins": func(mgr manager.Manager) error {
-			err := mgr.Add(devopscredential.NewController(client.Kubernetes(),
+			credentialResolver, err := credential.NewResolverFromOptions(s.VaultOptions)
+			if err != nil {
+				return err
+			}
+			err = mgr.Add(devopscredential.NewController(client.Kubernetes(),
 				devopsClient,
 				informerFactory.KubernetesSharedInformerFactory().Core().V1().Namespaces(),
-				informerFactory.KubernetesSharedInformerFactory().Core().V1().Secrets()))
+				informerFactory.KubernetesSharedInformerFactory().Core().V1().Secrets(),
+				credentialResolver))
 			if err == nil {
 				err = mgr.Add(devopsproject.NewController(client.Kubernetes(),
 					client.KubeSphere(), devopsClient,
@@ -212,6 +284,17 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 			if err == nil {
 				err = jenkinsAgentLabelsReconciler.SetupWithManager(mgr)
 			}
+			if err == nil {
+				err = (&devopscredential.ExpiryReconciler{
+					Client:        mgr.GetClient(),
+					WarningPeriod: s.FeatureOptions.CredentialExpiryWarningPeriod,
+				}).SetupWithManager(mgr)
+			}
+			if err == nil {
+				err = (&devopsproject.UsageReconciler{
+					Client: mgr.GetClient(),
+				}).SetupWithManager(mgr)
+			}
 			return err
 		},
 		argocdReconciler.GetGroupName(): func(mgr manager.Manager) (err error) {
@@ -236,6 +319,9 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 			if err = fluxcdGitRepoReconciler.SetupWithManager(mgr); err != nil {
 				return
 			}
+			if err = fluxcdChartRepoReconciler.SetupWithManager(mgr); err != nil {
+				return
+			}
 			if err = fluxcdMultiClusterReconciler.SetupWithManager(mgr); err != nil {
 				return
 			}
@@ -244,5 +330,31 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 			}
 			return fluxcdApplicationReconciler.SetupWithManager(mgr)
 		},
+		fluxcdImageUpdaterReconciler.GetGroupName() + "-image-updater": func(mgr manager.Manager) error {
+			return fluxcdImageUpdaterReconciler.SetupWithManager(mgr)
+		},
+		promotionReconciler.GetGroupName(): func(mgr manager.Manager) error {
+			return promotionReconciler.SetupWithManager(mgr)
+		},
+		generatorReconciler.GetGroupName(): func(mgr manager.Manager) error {
+			return generatorReconciler.SetupWithManager(mgr)
+		},
+		driftReconciler.GetGroupName(): func(mgr manager.Manager) error {
+			return driftReconciler.SetupWithManager(mgr)
+		},
+		notificationReconciler.GetGroupName(): func(mgr manager.Manager) (err error) {
+			var receivers []gitopsnotification.Receiver
+			if receivers, err = gitopsnotification.ParseReceivers(s.FeatureOptions.GitOpsNotificationReceivers); err != nil {
+				return
+			}
+			if len(receivers) > 0 {
+				notificationReconciler.Notifier = gitopsnotification.NewNotifier(receivers)
+				if err = mgr.Add(notificationReconciler.Notifier); err != nil {
+					klog.Errorf("unable to add the gitops Application notifier, err: %v", err)
+					return
+				}
+			}
+			return notificationReconciler.SetupWithManager(mgr)
+		},
 	}
 }
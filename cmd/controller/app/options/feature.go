@@ -17,10 +17,15 @@ limitations under the License.
 package options
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	cliflag "k8s.io/component-base/cli/flag"
+	"kubesphere.io/devops/pkg/event/notification"
 	"kubesphere.io/devops/pkg/utils/reflectutils"
 )
 
@@ -31,6 +36,35 @@ type FeatureOptions struct {
 	ExternalAddress      string
 	ClusterName          string
 	PipelineRunDataStore string
+	// PipelineRunDataStoreDir is the root directory of the filesystem data store
+	PipelineRunDataStoreDir string
+	// PipelineRunDataRetention is how long the data of completed PipelineRuns will be kept
+	PipelineRunDataRetention time.Duration
+	// EnableAdmissionWebhook indicates whether to serve the defaulting and validating admission webhooks
+	EnableAdmissionWebhook bool
+	// KubernetesEngineImage is the default image of the steps which run on the Kubernetes engine
+	KubernetesEngineImage string
+	// KubernetesEngineContainerImages maps the names of the container steps to images on the Kubernetes engine
+	KubernetesEngineContainerImages map[string]string
+	// KubernetesEngineWorkspaceStorageClass is the StorageClass of the workspaces on the Kubernetes engine
+	KubernetesEngineWorkspaceStorageClass string
+	// KubernetesEngineWorkspaceSize is the storage size of the workspaces on the Kubernetes engine
+	KubernetesEngineWorkspaceSize string
+	// KubernetesEngineWorkspaceAccessMode is the access mode of the workspaces on the Kubernetes engine
+	KubernetesEngineWorkspaceAccessMode string
+	// KubernetesEngineStepRequests are the resource requests of the step containers on the Kubernetes engine
+	KubernetesEngineStepRequests map[string]string
+	// KubernetesEngineStepLimits are the resource limits of the step containers on the Kubernetes engine
+	KubernetesEngineStepLimits map[string]string
+	// PipelineRunEventSinks are the URLs which receive the lifecycle CloudEvents of PipelineRuns
+	PipelineRunEventSinks []string
+	// DriftDetectionInterval is the interval to detect whether the gitops Applications drifted
+	DriftDetectionInterval time.Duration
+	// GitOpsNotificationReceivers receive the notifications when the gitops Applications become Degraded
+	// or OutOfSync, or recover. Each one is in the format of [type=]url
+	GitOpsNotificationReceivers []string
+	// CredentialExpiryWarningPeriod is how long before the expiry a credential is considered as expiring soon
+	CredentialExpiryWarningPeriod time.Duration
 }
 
 // GetControllers returns the controllers map
@@ -66,7 +100,34 @@ func NewFeatureOptions() *FeatureOptions {
 
 // Validate checks validation of FeatureOptions.
 func (o *FeatureOptions) Validate() []error {
-	return []error{}
+	errs := []error{}
+	if _, err := notification.ParseReceivers(o.GitOpsNotificationReceivers); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseResourceList(o.KubernetesEngineStepRequests); err != nil {
+		errs = append(errs, fmt.Errorf("invalid kubernetes-engine-step-requests: %v", err))
+	}
+	if _, err := ParseResourceList(o.KubernetesEngineStepLimits); err != nil {
+		errs = append(errs, fmt.Errorf("invalid kubernetes-engine-step-limits: %v", err))
+	}
+	return errs
+}
+
+// ParseResourceList parses the resource=quantity pairs into a ResourceList
+func ParseResourceList(values map[string]string) (list corev1.ResourceList, err error) {
+	if len(values) == 0 {
+		return
+	}
+	list = corev1.ResourceList{}
+	for name, value := range values {
+		var quantity resource.Quantity
+		if quantity, err = resource.ParseQuantity(value); err != nil {
+			err = fmt.Errorf("the quantity of %s is invalid: %v", name, err)
+			return
+		}
+		list[corev1.ResourceName(name)] = quantity
+	}
+	return
 }
 
 // ApplyTo fills up FeatureOptions config with options
@@ -83,7 +144,43 @@ func (o *FeatureOptions) AddFlags(fs *pflag.FlagSet, c *FeatureOptions) {
 	fs.StringVarP(&o.ExternalAddress, "external-address", "", "", "The external address for the UI")
 	fs.StringVarP(&o.ClusterName, "cluster-name", "", "default", "Current cluster name")
 	fs.StringVarP(&o.PipelineRunDataStore, "pipelinerun-data-store", "", "configmap",
-		"The data store type of the PipelineRun data, could be empty or configmap")
+		"The data store type of the PipelineRun data, could be empty, configmap, s3 or filesystem")
+	fs.StringVarP(&o.PipelineRunDataStoreDir, "pipelinerun-data-store-dir", "", "",
+		"The root directory of the filesystem data store, it's usually a mounted PersistentVolume")
+	fs.DurationVarP(&o.PipelineRunDataRetention, "pipelinerun-data-retention", "", 0,
+		"How long the data of completed PipelineRuns will be kept, keep it forever if it's zero")
+	fs.BoolVarP(&o.EnableAdmissionWebhook, "enable-admission-webhook", "", false,
+		"Serve the defaulting and validating admission webhooks of Pipeline, PipelineRun, Template and DevOpsProject, "+
+			"the certificates are required in the webhook-cert-dir. See also docs/admission-webhooks.md")
+	fs.StringVarP(&o.KubernetesEngineImage, "kubernetes-engine-image", "", "kubesphere/builder-base:v3.2.2",
+		"The default image of the steps of the Pipelines which run on the Kubernetes engine")
+	fs.Var(cliflag.NewMapStringString(&o.KubernetesEngineContainerImages), "kubernetes-engine-container-images",
+		"A set of container=image pairs that describe the images of the container steps on the Kubernetes engine, "+
+			"for example: base=kubesphere/builder-base:v3.2.2,maven=kubesphere/builder-maven:v3.2.0")
+	fs.StringVarP(&o.KubernetesEngineWorkspaceStorageClass, "kubernetes-engine-workspace-storage-class", "", "",
+		"The StorageClass of the workspace PersistentVolumeClaims on the Kubernetes engine, use the default one if it's empty")
+	fs.StringVarP(&o.KubernetesEngineWorkspaceSize, "kubernetes-engine-workspace-size", "", "1Gi",
+		"The storage size of the workspace PersistentVolumeClaims on the Kubernetes engine")
+	fs.StringVarP(&o.KubernetesEngineWorkspaceAccessMode, "kubernetes-engine-workspace-access-mode", "", "ReadWriteOnce",
+		"The access mode of the workspace PersistentVolumeClaims on the Kubernetes engine, "+
+			"the parallel branches which run on different nodes need ReadWriteMany")
+	fs.Var(cliflag.NewMapStringString(&o.KubernetesEngineStepRequests), "kubernetes-engine-step-requests",
+		"A set of resource=quantity pairs that describe the resource requests of the step containers on the Kubernetes engine, "+
+			"for example: cpu=100m,memory=128Mi. It's the default one if it's empty")
+	fs.Var(cliflag.NewMapStringString(&o.KubernetesEngineStepLimits), "kubernetes-engine-step-limits",
+		"A set of resource=quantity pairs that describe the resource limits of the step containers on the Kubernetes engine, "+
+			"for example: cpu=1,memory=1Gi. They are capped by the maxAgentResources of the DevOpsProject")
+	fs.StringSliceVarP(&o.PipelineRunEventSinks, "pipelinerun-event-sinks", "", nil,
+		"The URLs which receive the lifecycle events of PipelineRuns as CloudEvents in the HTTP binary content mode, "+
+			"no event will be sent if it's empty")
+	fs.DurationVarP(&o.DriftDetectionInterval, "drift-detection-interval", "", 10*time.Minute,
+		"The interval to detect whether the live resources of the gitops Applications drifted from the desired state")
+	fs.StringSliceVarP(&o.GitOpsNotificationReceivers, "gitops-notification-receivers", "", nil,
+		"The receivers of the notifications when the gitops Applications become Degraded or OutOfSync, or recover. "+
+			"Each one is in the format of [type=]url, the type could be webhook, slack, dingtalk, wecom or feishu, "+
+			"for example: slack=https://hooks.slack.com/services/xxx,https://example.com/webhook")
+	fs.DurationVarP(&o.CredentialExpiryWarningPeriod, "credential-expiry-warning-period", "", 7*24*time.Hour,
+		"How long before the expiry a DevOps credential is considered as expiring soon, a warning event is recorded then")
 }
 
 func (o *FeatureOptions) knownControllers() []string {
@@ -22,6 +22,8 @@ import (
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestFeatureOptions_GetControllers(t *testing.T) {
@@ -121,4 +123,34 @@ func TestFeatureOptions(t *testing.T) {
 	assert.NotNil(t, flagSet.Lookup("external-address"))
 	assert.NotNil(t, flagSet.Lookup("cluster-name"))
 	assert.NotNil(t, flagSet.Lookup("pipelinerun-data-store"))
+	assert.NotNil(t, flagSet.Lookup("kubernetes-engine-image"))
+	assert.NotNil(t, flagSet.Lookup("kubernetes-engine-container-images"))
+	assert.NotNil(t, flagSet.Lookup("kubernetes-engine-step-requests"))
+	assert.NotNil(t, flagSet.Lookup("kubernetes-engine-step-limits"))
+	assert.NotNil(t, flagSet.Lookup("pipelinerun-event-sinks"))
+	assert.NotNil(t, flagSet.Lookup("gitops-notification-receivers"))
+
+	opt.GitOpsNotificationReceivers = []string{"slack=https://hooks.slack.com/services/fake", "https://example.com"}
+	assert.Equal(t, []error{}, opt.Validate())
+	opt.GitOpsNotificationReceivers = []string{"slack=hooks.slack.com"}
+	assert.Len(t, opt.Validate(), 1)
+	opt.GitOpsNotificationReceivers = nil
+	opt.KubernetesEngineStepLimits = map[string]string{"cpu": "one"}
+	assert.Len(t, opt.Validate(), 1)
+}
+
+func TestParseResourceList(t *testing.T) {
+	list, err := ParseResourceList(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, list)
+
+	list, err = ParseResourceList(map[string]string{"cpu": "500m", "memory": "1Gi"})
+	assert.Nil(t, err)
+	assert.Equal(t, corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("500m"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}, list)
+
+	_, err = ParseResourceList(map[string]string{"cpu": "one"})
+	assert.NotNil(t, err)
 }
@@ -26,6 +26,7 @@ import (
 	"kubesphere.io/devops/pkg/client/devops/jenkins"
 	"kubesphere.io/devops/pkg/client/k8s"
 	"kubesphere.io/devops/pkg/client/s3"
+	"kubesphere.io/devops/pkg/client/vault"
 
 	"k8s.io/apimachinery/pkg/labels"
 
@@ -45,6 +46,7 @@ type DevOpsControllerManagerOptions struct {
 	FeatureOptions    *FeatureOptions
 	JWTOptions        *JWTOptions
 	ArgoCDOption      *config.ArgoCDOption
+	VaultOptions      *vault.Options
 
 	// KubeSphere is using sigs.k8s.io/application as fundamental object to implement Application Management.
 	// There are other projects also built on sigs.k8s.io/application, when KubeSphere installed along side
@@ -70,6 +72,7 @@ func NewDevOpsControllerManagerOptions() *DevOpsControllerManagerOptions {
 		ApplicationSelector: "",
 		KubernetesOptions:   &k8s.KubernetesOptions{},
 		ArgoCDOption:        &config.ArgoCDOption{},
+		VaultOptions:        vault.NewVaultOptions(),
 	}
 
 	return s
@@ -82,6 +85,7 @@ func (s *DevOpsControllerManagerOptions) Flags() cliflag.NamedFlagSets {
 	s.JenkinsOptions.AddFlags(fss.FlagSet("devops"), s.JenkinsOptions)
 	s.FeatureOptions.AddFlags(fss.FlagSet("feature"), s.FeatureOptions)
 	s.ArgoCDOption.AddFlags(fss.FlagSet("argocd"), s.ArgoCDOption)
+	s.VaultOptions.AddFlags(fss.FlagSet("vault"), s.VaultOptions)
 
 	fs := fss.FlagSet("leaderelection")
 	s.bindLeaderElectionFlags(s.LeaderElection, fs)
@@ -116,6 +120,7 @@ func (s *DevOpsControllerManagerOptions) Validate() []error {
 	errs = append(errs, s.JenkinsOptions.Validate()...)
 	errs = append(errs, s.KubernetesOptions.Validate()...)
 	errs = append(errs, s.FeatureOptions.Validate()...)
+	errs = append(errs, s.VaultOptions.Validate()...)
 
 	if len(s.ApplicationSelector) != 0 {
 		_, err := labels.Parse(s.ApplicationSelector)
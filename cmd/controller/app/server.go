@@ -50,6 +50,9 @@ func NewControllerManagerCommand() *cobra.Command {
 		if conf.ArgoCDOption == nil {
 			conf.ArgoCDOption = &config.ArgoCDOption{}
 		}
+		if conf.VaultOptions == nil {
+			conf.VaultOptions = s.VaultOptions
+		}
 		// make sure LeaderElection is not nil
 		// override devops controller manager options
 		s = &options.DevOpsControllerManagerOptions{
@@ -61,6 +64,7 @@ func NewControllerManagerCommand() *cobra.Command {
 				MaximumClockSkew: conf.AuthenticationOptions.MaximumClockSkew,
 			},
 			ArgoCDOption:   conf.ArgoCDOption,
+			VaultOptions:   conf.VaultOptions,
 			FeatureOptions: s.FeatureOptions,
 			LeaderElection: s.LeaderElection,
 			LeaderElect:    s.LeaderElect,
@@ -189,6 +193,12 @@ func Run(s *options.DevOpsControllerManagerOptions, ctx context.Context) error {
 		return fmt.Errorf("unable to register controllers to the manager: %v", err)
 	}
 
+	if s.FeatureOptions.EnableAdmissionWebhook {
+		if err = addWebhooks(mgr); err != nil {
+			return fmt.Errorf("unable to register admission webhooks to the manager: %v", err)
+		}
+	}
+
 	if err = indexers.CreatePipelineRunSCMRefNameIndexer(mgr.GetCache()); err != nil {
 		return err
 	}
@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/devopsproject"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// webhookSetup registers the admission webhooks of a kind of object, or a standalone admission handler
+type webhookSetup interface {
+	SetupWebhookWithManager(mgr manager.Manager) error
+}
+
+// addWebhooks registers the defaulting and validating admission webhooks into the webhook server of the manager
+func addWebhooks(mgr manager.Manager) (err error) {
+	objects := []webhookSetup{
+		&v1alpha3.Pipeline{},
+		&v1alpha3.PipelineRun{},
+		&v1alpha3.Template{},
+		&v1alpha3.ClusterTemplate{},
+		&v1alpha3.DevOpsProject{},
+		&devopsproject.PipelineLimitsValidator{},
+	}
+	for _, obj := range objects {
+		if err = obj.SetupWebhookWithManager(mgr); err != nil {
+			klog.Errorf("unable to create the admission webhooks of %T, err: %v", obj, err)
+			return
+		}
+	}
+	return
+}
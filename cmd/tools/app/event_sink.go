@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/event/cloudevents"
+)
+
+type eventSinkOption struct {
+	port int
+}
+
+func (o *eventSinkOption) runE(cmd *cobra.Command, args []string) error {
+	sink := &cloudevents.Sink{
+		OnReceive: func(event cloudevents.ReceivedEvent) {
+			if data, err := json.Marshal(event); err == nil {
+				cmd.Println(string(data))
+			}
+		},
+	}
+	address := fmt.Sprintf(":%d", o.port)
+	klog.Infof("receiving CloudEvents on %s ..", address)
+	return http.ListenAndServe(address, sink)
+}
+
+// NewEventSinkCmd creates a command which serves a local sink of the PipelineRun CloudEvents
+func NewEventSinkCmd() (cmd *cobra.Command) {
+	opt := &eventSinkOption{}
+
+	eventSinkCmd := &cobra.Command{
+		Use:   "event-sink",
+		Short: "Serve a local sink which prints the received PipelineRun CloudEvents, it's useful for testing",
+		RunE:  opt.runE,
+	}
+
+	flags := eventSinkCmd.Flags()
+	flags.IntVarP(&opt.port, "port", "p", 8080, "The port of the event sink")
+	return eventSinkCmd
+}
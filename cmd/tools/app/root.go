@@ -56,5 +56,6 @@ func NewToolsCmd() (cmd *cobra.Command) {
 		"The configmap name of DevOps service")
 
 	rootCmd.AddCommand(NewInitCmd())
+	rootCmd.AddCommand(NewEventSinkCmd())
 	return rootCmd
 }
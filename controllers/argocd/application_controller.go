@@ -30,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/controllers/rollout"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	"kubesphere.io/devops/pkg/utils/k8sutil"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -39,6 +40,7 @@ import (
 )
 
 //+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications/status,verbs=get;update
 //+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;create;update;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
@@ -64,6 +66,16 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	if argo := app.Spec.ArgoApp; argo != nil {
+		if app.ObjectMeta.DeletionTimestamp.IsZero() {
+			// the project is always the namespace, see also setArgoProject
+			app.Spec.ArgoApp.Spec.Project = app.Namespace
+
+			var plan *rollout.Plan
+			if plan, result, err = rollout.Reconcile(ctx, r.Client, r.recorder, app, getRolloutHealth); err != nil {
+				return
+			}
+			applyRolloutPlan(app, plan)
+		}
 		err = r.reconcileArgoApplication(app)
 	}
 	return
@@ -447,7 +459,8 @@ func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		WithEventFilter(predicate.Or(
 			predicate.GenerationChangedPredicate{},
 			finalizersChangedPredicate{},
-			specificAnnotationsOrLabelsChangedPredicate{filter: "argoproj.io"})).
+			specificAnnotationsOrLabelsChangedPredicate{filter: "argoproj.io"},
+			specificAnnotationsOrLabelsChangedPredicate{filter: v1alpha1.RolloutPromoteAnnoKey})).
 		For(&v1alpha1.Application{}).
 		Complete(r)
 }
@@ -105,8 +105,18 @@ func (r *ApplicationStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 			}
 
 			// update labels
+			var syncHistory []v1alpha1.SyncHistory
+			if syncHistory, err = mergeSyncHistory(statusData, app.Status.SyncHistory); err != nil {
+				r.log.Error(err, "failed to parse the sync history", "namespace", appNs, "name", appName)
+				syncHistory = app.Status.SyncHistory
+			}
+
 			if err = r.Update(ctx, app); err == nil {
 				app.Status.ArgoApp = string(statusData)
+				app.Status.SyncHistory = syncHistory
+				if err = setArgoHealthAndSyncStatus(app, statusData); err != nil {
+					r.log.Error(err, "failed to parse the health and sync status", "namespace", appNs, "name", appName)
+				}
 				err = r.Status().Update(ctx, app)
 			}
 		}
@@ -233,6 +233,9 @@ func TestArgoCDApplicationStatusReconciler_Reconcile(t *testing.T) {
 			assert.Nil(t, err)
 
 			assert.Equal(t, "nginx", app.Annotations[v1alpha1.AnnoKeyImages])
+			// the unknown status codes of Argo CD are normalized
+			assert.Equal(t, v1alpha1.HealthStatusUnknown, app.Status.Health)
+			assert.Equal(t, v1alpha1.SyncStatusUnknown, app.Status.SyncStatus)
 			return true
 		},
 	}, {
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+// maxResourcesInMessage is the max number of the resources listed in the message of the Healthy and Synced conditions
+const maxResourcesInMessage = 5
+
+// argoHealthAndSyncStatus is the health and sync status of an Argo CD Application
+type argoHealthAndSyncStatus struct {
+	Health    argoHealth           `json:"health"`
+	Sync      argoSync             `json:"sync"`
+	Resources []argoResourceStatus `json:"resources"`
+}
+
+type argoHealth struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type argoSync struct {
+	Status   string `json:"status"`
+	Revision string `json:"revision"`
+}
+
+type argoResourceStatus struct {
+	Group     string      `json:"group"`
+	Kind      string      `json:"kind"`
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`
+	Health    *argoHealth `json:"health"`
+}
+
+func (r argoResourceStatus) String() string {
+	kind := r.Kind
+	if r.Group != "" {
+		kind += "." + r.Group
+	}
+	if r.Namespace == "" {
+		return kind + " " + r.Name
+	}
+	return kind + " " + r.Namespace + "/" + r.Name
+}
+
+// setArgoHealthAndSyncStatus normalizes the health and sync status of the Argo CD Application,
+// Argo CD uses the same health and sync status codes
+func setArgoHealthAndSyncStatus(app *v1alpha1.Application, statusData []byte) (err error) {
+	status := &argoHealthAndSyncStatus{}
+	if err = json.Unmarshal(statusData, status); err != nil {
+		return
+	}
+
+	health := v1alpha1.HealthStatus(status.Health.Status)
+	switch health {
+	case v1alpha1.HealthStatusHealthy, v1alpha1.HealthStatusSuspended, v1alpha1.HealthStatusProgressing,
+		v1alpha1.HealthStatusMissing, v1alpha1.HealthStatusDegraded:
+	default:
+		health = v1alpha1.HealthStatusUnknown
+	}
+	healthMessage := status.Health.Message
+	if healthMessage == "" && health != v1alpha1.HealthStatusHealthy {
+		var unhealthy []string
+		for _, resource := range status.Resources {
+			if resource.Health == nil || resource.Health.Status == "" ||
+				resource.Health.Status == string(v1alpha1.HealthStatusHealthy) {
+				continue
+			}
+			item := fmt.Sprintf("%s is %s", resource, resource.Health.Status)
+			if resource.Health.Message != "" {
+				item += ": " + resource.Health.Message
+			}
+			unhealthy = append(unhealthy, item)
+		}
+		healthMessage = listInMessage(unhealthy, "resource(s) are not healthy")
+	}
+	app.Status.SetHealth(health, healthMessage, app.Generation)
+
+	syncStatus := v1alpha1.SyncStatusCode(status.Sync.Status)
+	var syncMessage string
+	switch syncStatus {
+	case v1alpha1.SyncStatusSynced:
+		if status.Sync.Revision != "" {
+			syncMessage = "synced to revision " + status.Sync.Revision
+		}
+	case v1alpha1.SyncStatusOutOfSync:
+		var outOfSync []string
+		for _, resource := range status.Resources {
+			if resource.Status == string(v1alpha1.SyncStatusOutOfSync) {
+				outOfSync = append(outOfSync, resource.String())
+			}
+		}
+		syncMessage = listInMessage(outOfSync, "resource(s) are out of sync")
+	default:
+		syncStatus = v1alpha1.SyncStatusUnknown
+	}
+	app.Status.SetSyncStatus(syncStatus, syncMessage, app.Generation)
+	return
+}
+
+// listInMessage lists the first few items in the message, it returns an empty string if there is no item
+func listInMessage(items []string, description string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	listed := items
+	if len(listed) > maxResourcesInMessage {
+		listed = listed[:maxResourcesInMessage]
+	}
+	message := fmt.Sprintf("%d %s: %s", len(items), description, strings.Join(listed, ", "))
+	if len(items) > len(listed) {
+		message += fmt.Sprintf(" and %d more", len(items)-len(listed))
+	}
+	return message
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+func Test_setArgoHealthAndSyncStatus(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          string
+		wantHealth      v1alpha1.HealthStatus
+		wantHealthMsg   string
+		wantSyncStatus  v1alpha1.SyncStatusCode
+		wantSyncMessage string
+		wantErr         bool
+	}{{
+		name:            "healthy and synced",
+		status:          `{"health":{"status":"Healthy"},"sync":{"status":"Synced","revision":"abc"}}`,
+		wantHealth:      v1alpha1.HealthStatusHealthy,
+		wantHealthMsg:   "the Application is Healthy",
+		wantSyncStatus:  v1alpha1.SyncStatusSynced,
+		wantSyncMessage: "synced to revision abc",
+	}, {
+		name: "degraded and out of sync",
+		status: `{"health":{"status":"Degraded"},"sync":{"status":"OutOfSync"},"resources":[
+{"kind":"Service","namespace":"default","name":"svc","status":"Synced","health":{"status":"Healthy"}},
+{"group":"apps","kind":"Deployment","namespace":"default","name":"app","status":"OutOfSync",
+"health":{"status":"Degraded","message":"Deployment exceeded its progress deadline"}},
+{"kind":"Namespace","name":"ns","status":"OutOfSync"}]}`,
+		wantHealth:      v1alpha1.HealthStatusDegraded,
+		wantHealthMsg:   "1 resource(s) are not healthy: Deployment.apps default/app is Degraded: Deployment exceeded its progress deadline",
+		wantSyncStatus:  v1alpha1.SyncStatusOutOfSync,
+		wantSyncMessage: "2 resource(s) are out of sync: Deployment.apps default/app, Namespace ns",
+	}, {
+		name:            "message of Argo CD",
+		status:          `{"health":{"status":"Missing","message":"fake message"}}`,
+		wantHealth:      v1alpha1.HealthStatusMissing,
+		wantHealthMsg:   "fake message",
+		wantSyncStatus:  v1alpha1.SyncStatusUnknown,
+		wantSyncMessage: "the Application is Unknown",
+	}, {
+		name:            "not reported",
+		status:          `{}`,
+		wantHealth:      v1alpha1.HealthStatusUnknown,
+		wantHealthMsg:   "the Application is Unknown",
+		wantSyncStatus:  v1alpha1.SyncStatusUnknown,
+		wantSyncMessage: "the Application is Unknown",
+	}, {
+		name:    "invalid status",
+		status:  `invalid`,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &v1alpha1.Application{}
+			err := setArgoHealthAndSyncStatus(app, []byte(tt.status))
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, app.Status.Health)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantHealth, app.Status.Health)
+			assert.Equal(t, tt.wantSyncStatus, app.Status.SyncStatus)
+			assert.Equal(t, tt.wantHealthMsg,
+				meta.FindStatusCondition(app.Status.Conditions, v1alpha1.ApplicationConditionHealthy).Message)
+			assert.Equal(t, tt.wantSyncMessage,
+				meta.FindStatusCondition(app.Status.Conditions, v1alpha1.ApplicationConditionSynced).Message)
+		})
+	}
+
+	t.Run("status from file", func(t *testing.T) {
+		data, err := ioutil.ReadFile("data/argo-status.json")
+		assert.Nil(t, err)
+		app := &v1alpha1.Application{}
+		assert.Nil(t, setArgoHealthAndSyncStatus(app, data))
+		assert.Equal(t, v1alpha1.HealthStatusHealthy, app.Status.Health)
+		assert.Equal(t, v1alpha1.SyncStatusSynced, app.Status.SyncStatus)
+	})
+}
+
+func Test_listInMessage(t *testing.T) {
+	assert.Empty(t, listInMessage(nil, "items"))
+	assert.Equal(t, "7 items: a, b, c, d, e and 2 more",
+		listInMessage([]string{"a", "b", "c", "d", "e", "f", "g"}, "items"))
+}
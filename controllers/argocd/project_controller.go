@@ -19,10 +19,12 @@ package argocd
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -75,6 +77,14 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ct
 		return
 	}
 
+	// the invalid sync windows are not synchronized to the Argo AppProject until they are corrected
+	if err = project.Spec.Argo.SyncWindows.Validate(); err != nil {
+		r.recorder.Eventf(project, corev1.EventTypeWarning, "InvalidSyncWindows",
+			"The sync windows of DevOpsProject %s are invalid: %v", project.Name, err)
+		err = nil
+		return
+	}
+
 	err = r.reconcileArgoProject(project)
 	return
 }
@@ -143,6 +153,9 @@ func createUnstructuredObject(project *v1alpha3.DevOpsProject, argocdNamespace s
 	buffer := new(bytes.Buffer)
 	if err = tpl.Execute(buffer, argo); err == nil {
 		if result, err = GetObjectFromYaml(buffer.String()); err == nil {
+			if err = setSyncWindows(result, argo.SyncWindows); err != nil {
+				return
+			}
 			result.SetName(project.GetName())
 			result.SetNamespace(argocdNamespace)
 			k8sutil.AddOwnerReference(result, project.TypeMeta, project.ObjectMeta)
@@ -151,6 +164,22 @@ func createUnstructuredObject(project *v1alpha3.DevOpsProject, argocdNamespace s
 	return
 }
 
+// setSyncWindows sets the sync windows into the Argo AppProject, they have the same fields
+func setSyncWindows(appProject *unstructured.Unstructured, windows v1alpha3.SyncWindows) (err error) {
+	if len(windows) == 0 {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(windows); err != nil {
+		return
+	}
+	var syncWindows []interface{}
+	if err = json.Unmarshal(data, &syncWindows); err == nil {
+		err = unstructured.SetNestedSlice(appProject.Object, syncWindows, "spec", "syncWindows")
+	}
+	return
+}
+
 const argoProjectTemplate = `apiVersion: argoproj.io/v1alpha1
 kind: AppProject
 spec:
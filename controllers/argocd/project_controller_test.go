@@ -22,6 +22,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
@@ -31,6 +32,7 @@ import (
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"testing"
 )
 
@@ -92,6 +94,67 @@ func TestCreateUnstructuredObject(t *testing.T) {
 	assert.Equal(t, []interface{}{
 		map[string]interface{}{"group": "group1", "kind": "kind1"},
 		map[string]interface{}{"group": "group2", "kind": "kind2"}}, p2ClusterResourceWhitelist)
+	_, found, _ := unstructured.NestedSlice(obj.Object, "spec", "syncWindows")
+	assert.False(t, found)
+
+	// test against the sync windows
+	p3 := project.DeepCopy()
+	p3.Spec.Argo = &v1alpha3.Argo{
+		SyncWindows: v1alpha3.SyncWindows{{
+			Kind:         v1alpha3.SyncWindowKindAllow,
+			Schedule:     "0 22 * * *",
+			Duration:     "1h",
+			Applications: []string{"*"},
+			ManualSync:   true,
+			TimeZone:     "Asia/Shanghai",
+		}},
+	}
+	obj, err = createUnstructuredObject(p3, argocdNs)
+	assert.Nil(t, err)
+	p3SyncWindows, _, _ := unstructured.NestedSlice(obj.Object, "spec", "syncWindows")
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"kind":         "allow",
+		"schedule":     "0 22 * * *",
+		"duration":     "1h",
+		"applications": []interface{}{"*"},
+		"manualSync":   true,
+		"timeZone":     "Asia/Shanghai",
+	}}, p3SyncWindows)
+}
+
+func TestReconcileInvalidSyncWindows(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	project := &v1alpha3.DevOpsProject{}
+	project.SetName("fake")
+	project.Spec.Argo = &v1alpha3.Argo{
+		SyncWindows: v1alpha3.SyncWindows{{
+			Kind:     v1alpha3.SyncWindowKindDeny,
+			Schedule: "0 25 * * *",
+			Duration: "1h",
+		}},
+	}
+	c := fake.NewFakeClientWithScheme(schema, project)
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{
+		Client:        c,
+		log:           logr.New(log.NullLogSink{}),
+		recorder:      recorder,
+		ArgoNamespace: "argocd",
+	}
+	_, err = r.Reconcile(context.Background(), controllerruntime.Request{
+		NamespacedName: types.NamespacedName{Name: "fake"},
+	})
+	assert.Nil(t, err)
+	assert.Contains(t, <-recorder.Events, "InvalidSyncWindows")
+
+	// the Argo AppProject is not created
+	appProject := &unstructured.Unstructured{}
+	appProject.SetAPIVersion("argoproj.io/v1alpha1")
+	appProject.SetKind("AppProject")
+	err = c.Get(context.Background(), types.NamespacedName{Namespace: "argocd", Name: "fake"}, appProject)
+	assert.True(t, apierrors.IsNotFound(err))
 }
 
 func TestReconcileArgoProject(t *testing.T) {
@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"kubesphere.io/devops/controllers/rollout"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+// argoRolloutStatus is the part of the Argo CD Application status which decides the health of a rollout
+type argoRolloutStatus struct {
+	Sync struct {
+		Status     string `json:"status"`
+		Revision   string `json:"revision"`
+		ComparedTo struct {
+			Source json.RawMessage `json:"source"`
+		} `json:"comparedTo"`
+	} `json:"sync"`
+	Health struct {
+		Status string `json:"status"`
+	} `json:"health"`
+	OperationState *struct {
+		Phase string `json:"phase"`
+	} `json:"operationState"`
+}
+
+// commitSHAPattern matches a full git commit SHA
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// getRolloutHealth returns the health of the Argo CD Application according to its sync and health status.
+// The status is cached from the Argo CD Application, and the promoted spec might not be applied yet. So it's
+// Progressing until Argo CD compared the promoted source and finished the operation.
+func getRolloutHealth(_ context.Context, app *v1alpha1.Application, plan *rollout.Plan) (health rollout.Health, err error) {
+	health = rollout.Progressing
+	if app.Status.ArgoApp == "" || app.Spec.ArgoApp == nil {
+		return
+	}
+
+	status := &argoRolloutStatus{}
+	if err = json.Unmarshal([]byte(app.Status.ArgoApp), status); err != nil {
+		return
+	}
+	if app.Spec.ArgoApp.Operation != nil ||
+		(status.OperationState != nil && (status.OperationState.Phase == "Running" || status.OperationState.Phase == "Terminating")) {
+		// there is an operation in flight
+		return
+	}
+
+	// the source which should be applied at the current step
+	promoted := app.DeepCopy()
+	applyRolloutPlan(promoted, plan)
+	source := promoted.Spec.ArgoApp.Spec.Source
+	var compared bool
+	if compared, err = isComparedTo(source, status.Sync.ComparedTo.Source); err != nil || !compared {
+		return
+	}
+	if status.Sync.Revision == "" ||
+		(commitSHAPattern.MatchString(source.TargetRevision) && status.Sync.Revision != source.TargetRevision) {
+		return
+	}
+
+	switch {
+	case status.Health.Status == "Degraded":
+		health = rollout.Degraded
+	case status.Sync.Status == "Synced" && status.Health.Status == "Healthy":
+		health = rollout.Healthy
+	}
+	return
+}
+
+// isComparedTo returns true if Argo CD compared the live state with the given source
+func isComparedTo(source v1alpha1.ApplicationSource, comparedSource json.RawMessage) (bool, error) {
+	if len(comparedSource) == 0 {
+		return false, nil
+	}
+	compared := v1alpha1.ApplicationSource{}
+	if err := json.Unmarshal(comparedSource, &compared); err != nil {
+		return false, err
+	}
+	// both sides go through the same type, so that the empty fields are the same
+	expectedData, err := json.Marshal(source)
+	if err != nil {
+		return false, err
+	}
+	comparedData, err := json.Marshal(compared)
+	if err != nil {
+		return false, err
+	}
+	return string(expectedData) == string(comparedData), nil
+}
+
+// applyRolloutPlan replaces the Argo CD Application with the stable one if the new revision is not promoted,
+// otherwise passes the traffic weight to the Helm parameters.
+// It only changes the Application in memory, the Argo CD Application will be created or updated from it.
+func applyRolloutPlan(app *v1alpha1.Application, plan *rollout.Plan) {
+	if plan == nil {
+		return
+	}
+
+	destination := app.Spec.ArgoApp.Spec.Destination
+	destinationName := destination.Name
+	if destinationName == "" {
+		destinationName = destination.Server
+	}
+	if !plan.IsPromoted(destinationName) {
+		if plan.Stable.ArgoApp != nil {
+			stable := plan.Stable.ArgoApp.DeepCopy()
+			stable.Operation = app.Spec.ArgoApp.Operation
+			app.Spec.ArgoApp = stable
+		}
+		return
+	}
+
+	if plan.Weight == nil {
+		return
+	}
+	source := &app.Spec.ArgoApp.Spec.Source
+	if source.Helm == nil {
+		source.Helm = &v1alpha1.ApplicationSourceHelm{}
+	}
+	weight := strconv.Itoa(int(*plan.Weight))
+	for i := range source.Helm.Parameters {
+		if source.Helm.Parameters[i].Name == plan.WeightParameter {
+			source.Helm.Parameters[i].Value = weight
+			return
+		}
+	}
+	source.Helm.Parameters = append(source.Helm.Parameters, v1alpha1.HelmParameter{
+		Name:  plan.WeightParameter,
+		Value: weight,
+	})
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/controllers/rollout"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+func TestGetRolloutHealth(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	// comparedTo is the sync status of the source in the Application below
+	comparedTo := `"comparedTo":{"source":{"repoURL":"https://github.com/org/repo","targetRevision":"main"}}`
+
+	tests := []struct {
+		name           string
+		argoApp        string
+		targetRevision string
+		operation      *v1alpha1.Operation
+		want           rollout.Health
+		wantErr        bool
+	}{{
+		name: "no status",
+		want: rollout.Progressing,
+	}, {
+		name:    "synced and healthy",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `",` + comparedTo + `},"health":{"status":"Healthy"}}`,
+		want:    rollout.Healthy,
+	}, {
+		name:    "out of sync",
+		argoApp: `{"sync":{"status":"OutOfSync","revision":"` + sha + `",` + comparedTo + `},"health":{"status":"Healthy"}}`,
+		want:    rollout.Progressing,
+	}, {
+		name:    "degraded",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `",` + comparedTo + `},"health":{"status":"Degraded"}}`,
+		want:    rollout.Degraded,
+	}, {
+		name: "the status is about the previous source",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `","comparedTo":{"source":` +
+			`{"repoURL":"https://github.com/org/repo","targetRevision":"v1"}}},"health":{"status":"Degraded"}}`,
+		want: rollout.Progressing,
+	}, {
+		name:    "the status has not been compared",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `"},"health":{"status":"Healthy"}}`,
+		want:    rollout.Progressing,
+	}, {
+		name: "the synced revision is not the target commit",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `","comparedTo":{"source":` +
+			`{"repoURL":"https://github.com/org/repo","targetRevision":"76543210fedcba9876543210fedcba9876543210"}}},` +
+			`"health":{"status":"Healthy"}}`,
+		targetRevision: "76543210fedcba9876543210fedcba9876543210",
+		want:           rollout.Progressing,
+	}, {
+		name: "the synced revision is the target commit",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `","comparedTo":{"source":` +
+			`{"repoURL":"https://github.com/org/repo","targetRevision":"` + sha + `"}}},"health":{"status":"Healthy"}}`,
+		targetRevision: sha,
+		want:           rollout.Healthy,
+	}, {
+		name: "the operation is running",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `",` + comparedTo + `},"health":{"status":"Healthy"},` +
+			`"operationState":{"phase":"Running"}}`,
+		want: rollout.Progressing,
+	}, {
+		name: "the operation has not started",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `",` + comparedTo + `},"health":{"status":"Healthy"},` +
+			`"operationState":{"phase":"Succeeded"}}`,
+		operation: &v1alpha1.Operation{},
+		want:      rollout.Progressing,
+	}, {
+		name: "the operation succeeded",
+		argoApp: `{"sync":{"status":"Synced","revision":"` + sha + `",` + comparedTo + `},"health":{"status":"Healthy"},` +
+			`"operationState":{"phase":"Succeeded"}}`,
+		want: rollout.Healthy,
+	}, {
+		name:    "invalid status",
+		argoApp: `invalid`,
+		want:    rollout.Progressing,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetRevision := tt.targetRevision
+			if targetRevision == "" {
+				targetRevision = "main"
+			}
+			app := &v1alpha1.Application{
+				Spec: v1alpha1.ApplicationSpec{
+					Kind: v1alpha1.ArgoCD,
+					ArgoApp: &v1alpha1.ArgoApplication{
+						Spec: v1alpha1.ArgoApplicationSpec{Source: v1alpha1.ApplicationSource{
+							RepoURL:        "https://github.com/org/repo",
+							TargetRevision: targetRevision,
+						}},
+						Operation: tt.operation,
+					},
+				},
+				Status: v1alpha1.ApplicationStatus{ArgoApp: tt.argoApp},
+			}
+			health, err := getRolloutHealth(context.TODO(), app, nil)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, health)
+		})
+	}
+}
+
+func TestGetRolloutHealth_weight(t *testing.T) {
+	weight := int32(20)
+	strategy := &v1alpha1.RolloutStrategy{Steps: []v1alpha1.RolloutStep{{Weight: &weight}}}
+	newApp := func(targetRevision string) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			Spec: v1alpha1.ApplicationSpec{
+				Kind: v1alpha1.ArgoCD,
+				ArgoApp: &v1alpha1.ArgoApplication{Spec: v1alpha1.ArgoApplicationSpec{
+					Source: v1alpha1.ApplicationSource{RepoURL: "https://github.com/org/repo", TargetRevision: targetRevision},
+				}},
+				Rollout: strategy,
+			},
+		}
+	}
+	stableSpec, err := json.Marshal(newApp("v1").Spec.GetRevisionSpec())
+	assert.Nil(t, err)
+	app := newApp("v2")
+	app.Status.Rollout = &v1alpha1.RolloutStatus{
+		Phase:      v1alpha1.RolloutProgressing,
+		Revision:   app.Spec.GetRevision(),
+		StableSpec: string(stableSpec),
+	}
+	plan, err := rollout.GetPlan(app)
+	assert.Nil(t, err)
+
+	// the weight of the current step has not been applied
+	app.Status.ArgoApp = `{"sync":{"status":"Synced","revision":"abc","comparedTo":{"source":` +
+		`{"repoURL":"https://github.com/org/repo","targetRevision":"v2"}}},"health":{"status":"Healthy"}}`
+	health, err := getRolloutHealth(context.TODO(), app, plan)
+	assert.Nil(t, err)
+	assert.Equal(t, rollout.Progressing, health)
+
+	app.Status.ArgoApp = `{"sync":{"status":"Synced","revision":"abc","comparedTo":{"source":` +
+		`{"repoURL":"https://github.com/org/repo","targetRevision":"v2","helm":{"parameters":[{"name":"` +
+		v1alpha1.DefaultTrafficWeightParameter + `","value":"20"}]}}}},"health":{"status":"Healthy"}}`
+	health, err = getRolloutHealth(context.TODO(), app, plan)
+	assert.Nil(t, err)
+	assert.Equal(t, rollout.Healthy, health)
+	// the Application itself should not be changed
+	assert.Nil(t, app.Spec.ArgoApp.Spec.Source.Helm)
+}
+
+func TestApplyRolloutPlan(t *testing.T) {
+	weight := int32(20)
+	newApp := func(targetRevision string, strategy *v1alpha1.RolloutStrategy) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app"},
+			Spec: v1alpha1.ApplicationSpec{
+				Kind: v1alpha1.ArgoCD,
+				ArgoApp: &v1alpha1.ArgoApplication{
+					Spec: v1alpha1.ArgoApplicationSpec{
+						Source: v1alpha1.ApplicationSource{
+							RepoURL:        "https://github.com/org/repo",
+							TargetRevision: targetRevision,
+							Helm: &v1alpha1.ApplicationSourceHelm{
+								Parameters: []v1alpha1.HelmParameter{{Name: "image", Value: "nginx"}},
+							},
+						},
+						Destination: v1alpha1.ApplicationDestination{Name: "production"},
+					},
+				},
+				Rollout: strategy,
+			},
+		}
+	}
+	newRollingOutApp := func(strategy *v1alpha1.RolloutStrategy) *v1alpha1.Application {
+		stable := newApp("v1", strategy)
+		stableSpec, _ := json.Marshal(stable.Spec.GetRevisionSpec())
+		app := newApp("v2", strategy)
+		app.Spec.ArgoApp.Operation = &v1alpha1.Operation{}
+		app.Status.Rollout = &v1alpha1.RolloutStatus{
+			Phase:          v1alpha1.RolloutProgressing,
+			Revision:       app.Spec.GetRevision(),
+			StableRevision: stable.Spec.GetRevision(),
+			StableSpec:     string(stableSpec),
+		}
+		return app
+	}
+
+	// the destination is not promoted yet
+	app := newRollingOutApp(&v1alpha1.RolloutStrategy{
+		Steps: []v1alpha1.RolloutStep{{Destinations: []string{"test"}}, {Destinations: []string{"production"}}},
+	})
+	plan, err := rollout.GetPlan(app)
+	assert.Nil(t, err)
+	applyRolloutPlan(app, plan)
+	assert.Equal(t, "v1", app.Spec.ArgoApp.Spec.Source.TargetRevision)
+	assert.NotNil(t, app.Spec.ArgoApp.Operation)
+
+	// the traffic weight is passed to the Helm parameters
+	app = newRollingOutApp(&v1alpha1.RolloutStrategy{Steps: []v1alpha1.RolloutStep{{Weight: &weight}}})
+	plan, err = rollout.GetPlan(app)
+	assert.Nil(t, err)
+	applyRolloutPlan(app, plan)
+	assert.Equal(t, "v2", app.Spec.ArgoApp.Spec.Source.TargetRevision)
+	assert.Equal(t, []v1alpha1.HelmParameter{{Name: "image", Value: "nginx"},
+		{Name: v1alpha1.DefaultTrafficWeightParameter, Value: "20"}}, app.Spec.ArgoApp.Spec.Source.Helm.Parameters)
+
+	// the existing weight parameter is replaced
+	applyRolloutPlan(app, plan)
+	assert.Len(t, app.Spec.ArgoApp.Spec.Source.Helm.Parameters, 2)
+
+	// no rollout
+	app = newApp("v2", nil)
+	applyRolloutPlan(app, nil)
+	assert.Equal(t, "v2", app.Spec.ArgoApp.Spec.Source.TargetRevision)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"encoding/json"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+// argoSyncStatus contains the fields of the Argo CD Application status which are related to the sync history
+type argoSyncStatus struct {
+	History        []argoRevisionHistory `json:"history"`
+	OperationState *argoOperationState   `json:"operationState"`
+}
+
+type argoRevisionHistory struct {
+	ID              int64                        `json:"id"`
+	Revision        string                       `json:"revision"`
+	DeployedAt      metav1.Time                  `json:"deployedAt"`
+	DeployStartedAt *metav1.Time                 `json:"deployStartedAt"`
+	InitiatedBy     *v1alpha1.OperationInitiator `json:"initiatedBy"`
+}
+
+type argoOperationState struct {
+	Operation  v1alpha1.Operation `json:"operation"`
+	Phase      string             `json:"phase"`
+	Message    string             `json:"message"`
+	StartedAt  metav1.Time        `json:"startedAt"`
+	FinishedAt *metav1.Time       `json:"finishedAt"`
+	SyncResult *struct {
+		Revision string `json:"revision"`
+	} `json:"syncResult"`
+}
+
+// revision returns the revision which the operation synced or tried to sync
+func (o *argoOperationState) revision() string {
+	if o.SyncResult != nil && o.SyncResult.Revision != "" {
+		return o.SyncResult.Revision
+	}
+	if o.Operation.Sync != nil {
+		return o.Operation.Sync.Revision
+	}
+	return ""
+}
+
+// mergeSyncHistory merges the deployment history and the failed operation of the Argo CD Application status
+// into the existing sync history. The latest record comes first, and at most v1alpha1.MaxSyncHistory records are kept.
+func mergeSyncHistory(statusData []byte, existing []v1alpha1.SyncHistory) (history []v1alpha1.SyncHistory, err error) {
+	status := &argoSyncStatus{}
+	if err = json.Unmarshal(statusData, status); err != nil {
+		return
+	}
+
+	history = make([]v1alpha1.SyncHistory, len(existing))
+	copy(history, existing)
+	recorded := func(match func(*v1alpha1.SyncHistory) bool) bool {
+		for i := range history {
+			if match(&history[i]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	operation := status.OperationState
+	for _, item := range status.History {
+		if recorded(func(h *v1alpha1.SyncHistory) bool { return h.Result == v1alpha1.SyncSucceeded && h.ID == item.ID }) {
+			continue
+		}
+		record := v1alpha1.SyncHistory{
+			ID:              item.ID,
+			Revision:        item.Revision,
+			DeployStartedAt: item.DeployStartedAt,
+			DeployedAt:      item.DeployedAt,
+			Result:          v1alpha1.SyncSucceeded,
+		}
+		initiator := item.InitiatedBy
+		if initiator == nil && operation != nil && operation.Phase == string(v1alpha1.SyncSucceeded) &&
+			operation.revision() == item.Revision {
+			// the old versions of Argo CD do not record the initiator in the history
+			initiator = &operation.Operation.InitiatedBy
+			record.Message = operation.Message
+		}
+		if initiator != nil {
+			record.Initiator = initiator.Username
+			record.Automated = initiator.Automated
+		}
+		history = append(history, record)
+	}
+
+	if operation != nil && operation.FinishedAt != nil &&
+		(operation.Phase == string(v1alpha1.SyncFailed) || operation.Phase == string(v1alpha1.SyncError)) &&
+		!recorded(func(h *v1alpha1.SyncHistory) bool {
+			return h.Result != v1alpha1.SyncSucceeded && h.DeployedAt.Equal(operation.FinishedAt)
+		}) {
+		startedAt := operation.StartedAt
+		history = append(history, v1alpha1.SyncHistory{
+			Revision:        operation.revision(),
+			DeployStartedAt: &startedAt,
+			DeployedAt:      *operation.FinishedAt,
+			Initiator:       operation.Operation.InitiatedBy.Username,
+			Automated:       operation.Operation.InitiatedBy.Automated,
+			Result:          v1alpha1.SyncResult(operation.Phase),
+			Message:         operation.Message,
+		})
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		if history[i].DeployedAt.Equal(&history[j].DeployedAt) {
+			return history[i].ID > history[j].ID
+		}
+		return history[j].DeployedAt.Before(&history[i].DeployedAt)
+	})
+	if len(history) > v1alpha1.MaxSyncHistory {
+		history = history[:v1alpha1.MaxSyncHistory]
+	}
+	return
+}
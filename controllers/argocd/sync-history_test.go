@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+func TestMergeSyncHistory(t *testing.T) {
+	at := func(minute int) metav1.Time {
+		// metav1.Time is unmarshalled in the local time zone
+		return metav1.NewTime(time.Date(2022, 7, 1, 0, minute, 0, 0, time.UTC).Local())
+	}
+	atPtr := func(minute int) *metav1.Time {
+		t := at(minute)
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		status   string
+		existing []v1alpha1.SyncHistory
+		want     []v1alpha1.SyncHistory
+		wantErr  bool
+	}{{
+		name:    "invalid status",
+		status:  `{"history": "invalid"}`,
+		wantErr: true,
+	}, {
+		name:   "no history",
+		status: `{}`,
+		want:   []v1alpha1.SyncHistory{},
+	}, {
+		name: "new history with the initiator from the operation state",
+		status: `{
+  "history": [
+    {"id": 0, "revision": "r0", "deployStartedAt": "2022-07-01T00:00:00Z", "deployedAt": "2022-07-01T00:01:00Z"},
+    {"id": 1, "revision": "r1", "deployStartedAt": "2022-07-01T00:02:00Z", "deployedAt": "2022-07-01T00:03:00Z",
+      "initiatedBy": {"automated": true}}
+  ],
+  "operationState": {
+    "operation": {"sync": {"revision": "r0"}, "initiatedBy": {"username": "admin"}},
+    "phase": "Succeeded",
+    "message": "successfully synced (all tasks run)",
+    "syncResult": {"revision": "r0"}
+  }
+}`,
+		want: []v1alpha1.SyncHistory{{
+			ID:              1,
+			Revision:        "r1",
+			DeployStartedAt: atPtr(2),
+			DeployedAt:      at(3),
+			Automated:       true,
+			Result:          v1alpha1.SyncSucceeded,
+		}, {
+			ID:              0,
+			Revision:        "r0",
+			DeployStartedAt: atPtr(0),
+			DeployedAt:      at(1),
+			Initiator:       "admin",
+			Result:          v1alpha1.SyncSucceeded,
+			Message:         "successfully synced (all tasks run)",
+		}},
+	}, {
+		name: "failed operation is recorded once",
+		status: `{
+  "history": [{"id": 0, "revision": "r0", "deployedAt": "2022-07-01T00:01:00Z"}],
+  "operationState": {
+    "operation": {"sync": {"revision": "r1"}, "initiatedBy": {"username": "admin"}},
+    "phase": "Failed",
+    "message": "one or more objects failed to apply",
+    "startedAt": "2022-07-01T00:04:00Z",
+    "finishedAt": "2022-07-01T00:05:00Z"
+  }
+}`,
+		existing: []v1alpha1.SyncHistory{{
+			ID:         0,
+			Revision:   "r0",
+			DeployedAt: at(1),
+			Initiator:  "someone",
+			Result:     v1alpha1.SyncSucceeded,
+		}, {
+			Revision:   "r1",
+			DeployedAt: at(5),
+			Initiator:  "admin",
+			Result:     v1alpha1.SyncFailed,
+		}},
+		want: []v1alpha1.SyncHistory{{
+			Revision:   "r1",
+			DeployedAt: at(5),
+			Initiator:  "admin",
+			Result:     v1alpha1.SyncFailed,
+		}, {
+			ID:         0,
+			Revision:   "r0",
+			DeployedAt: at(1),
+			Initiator:  "someone",
+			Result:     v1alpha1.SyncSucceeded,
+		}},
+	}, {
+		name: "new failed operation",
+		status: `{
+  "history": [{"id": 0, "revision": "r0", "deployedAt": "2022-07-01T00:01:00Z"}],
+  "operationState": {
+    "operation": {"sync": {"revision": "HEAD"}, "initiatedBy": {"automated": true}},
+    "phase": "Error",
+    "message": "rpc error",
+    "startedAt": "2022-07-01T00:04:00Z",
+    "finishedAt": "2022-07-01T00:05:00Z",
+    "syncResult": {"revision": "r1"}
+  }
+}`,
+		existing: []v1alpha1.SyncHistory{{
+			ID:         0,
+			Revision:   "r0",
+			DeployedAt: at(1),
+			Result:     v1alpha1.SyncSucceeded,
+		}},
+		want: []v1alpha1.SyncHistory{{
+			Revision:        "r1",
+			DeployStartedAt: atPtr(4),
+			DeployedAt:      at(5),
+			Automated:       true,
+			Result:          v1alpha1.SyncError,
+			Message:         "rpc error",
+		}, {
+			ID:         0,
+			Revision:   "r0",
+			DeployedAt: at(1),
+			Result:     v1alpha1.SyncSucceeded,
+		}},
+	}, {
+		name:   "running operation is not recorded",
+		status: `{"operationState": {"phase": "Running", "startedAt": "2022-07-01T00:04:00Z"}}`,
+		want:   []v1alpha1.SyncHistory{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeSyncHistory([]byte(tt.status), tt.existing)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("keep the latest records only", func(t *testing.T) {
+		var existing []v1alpha1.SyncHistory
+		for i := 0; i < v1alpha1.MaxSyncHistory; i++ {
+			existing = append(existing, v1alpha1.SyncHistory{
+				ID:         int64(i),
+				DeployedAt: at(i),
+				Result:     v1alpha1.SyncSucceeded,
+			})
+		}
+		got, err := mergeSyncHistory([]byte(`{"history": [{"id": 100, "deployedAt": "2022-07-01T01:00:00Z"}]}`), existing)
+		assert.NoError(t, err)
+		assert.Len(t, got, v1alpha1.MaxSyncHistory)
+		assert.Equal(t, int64(100), got[0].ID)
+		assert.Equal(t, int64(1), got[len(got)-1].ID)
+	})
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications/status,verbs=get;update
+//+kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=get;list;watch
+//+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories;buckets;ocirepositories,verbs=get
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// only the common kinds of the workloads could be previewed, the Secrets are never previewed. The server-side dry-run
+// requires the patch permission as well, it's not granted cluster-wide but by binding the ClusterRole
+// drift-dry-run-role in the namespaces of the Applications, see also docs/drift-detection.md
+//+kubebuilder:rbac:groups="",resources=namespaces;configmaps;services;serviceaccounts;persistentvolumeclaims,verbs=get
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get
+//+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses;networkpolicies,verbs=get
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get
+
+// DefaultInterval is the default interval to detect the drift of an Application
+const DefaultInterval = 10 * time.Minute
+
+// maxDriftedResourcesInMessage is the max number of the drifted resources listed in the message of the condition
+const maxDriftedResourcesInMessage = 5
+
+// Reconciler detects whether the live resources of the Applications drifted from the desired state periodically,
+// the report is stored as the Drifted condition of the Application
+type Reconciler struct {
+	client.Client
+	// Interval is the interval to detect the drift of an Application
+	Interval time.Duration
+	log      logr.Logger
+	recorder record.EventRecorder
+	// fluxDiffer previews the changes of the FluxCD Kustomization Applications
+	fluxDiffer diff.Differ
+}
+
+// Reconcile detects the drift of the Application, then requeues it after the interval
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.Info(fmt.Sprintf("start to reconcile Application: %s", req.String()))
+
+	app := &v1alpha1.Application{}
+	if err = r.Get(ctx, req.NamespacedName, app); err != nil || !app.DeletionTimestamp.IsZero() {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	var condition *metav1.Condition
+	switch {
+	case app.Spec.ArgoApp != nil:
+		condition = getArgoDriftCondition(app)
+	case isKustomizationApp(app):
+		condition = r.getFluxDriftCondition(ctx, app)
+	default:
+		// the drift detection of HelmRelease is not supported
+		return
+	}
+
+	result.RequeueAfter = r.Interval
+	if condition != nil {
+		condition.ObservedGeneration = app.Generation
+		err = r.setCondition(ctx, app, *condition)
+	}
+	return
+}
+
+// getArgoDriftCondition reports the drift by the resources which are out of sync in the Argo CD Application status,
+// Argo CD has already taken the ignored differences into account
+func getArgoDriftCondition(app *v1alpha1.Application) *metav1.Condition {
+	if app.Status.ArgoApp == "" {
+		// not reported by Argo CD yet
+		return nil
+	}
+	status := &argoResourcesStatus{}
+	if err := json.Unmarshal([]byte(app.Status.ArgoApp), status); err != nil {
+		return detectionFailedCondition(fmt.Errorf("failed to parse the status of Argo CD Application: %v", err))
+	}
+
+	var drifted []string
+	for _, resource := range status.Resources {
+		if resource.Status == "OutOfSync" {
+			drifted = append(drifted, diff.ResourceDiff{
+				Group:     resource.Group,
+				Kind:      resource.Kind,
+				Namespace: resource.Namespace,
+				Name:      resource.Name,
+			}.String())
+		}
+	}
+	return driftCondition(drifted)
+}
+
+type argoResourcesStatus struct {
+	Resources []struct {
+		Group     string `json:"group"`
+		Kind      string `json:"kind"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+		Status    string `json:"status"`
+	} `json:"resources"`
+}
+
+// getFluxDriftCondition reports the drift by the server-side dry-run of the FluxCD Kustomizations
+func (r *Reconciler) getFluxDriftCondition(ctx context.Context, app *v1alpha1.Application) *metav1.Condition {
+	diffs, err := r.fluxDiffer.Diff(ctx, app)
+	if err != nil {
+		r.log.Error(err, "failed to detect the drift", "namespace", app.Namespace, "name", app.Name)
+		return detectionFailedCondition(err)
+	}
+
+	drifted := make([]string, 0, len(diffs))
+	for _, resourceDiff := range diffs {
+		drifted = append(drifted, resourceDiff.String())
+	}
+	return driftCondition(drifted)
+}
+
+func isKustomizationApp(app *v1alpha1.Application) bool {
+	return app.Spec.FluxApp != nil && app.Spec.FluxApp.Spec.Config != nil &&
+		app.Spec.FluxApp.Spec.Config.Kustomization != nil
+}
+
+func driftCondition(drifted []string) *metav1.Condition {
+	if len(drifted) == 0 {
+		return &metav1.Condition{
+			Type:    v1alpha1.ApplicationConditionDrifted,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.DriftReasonNotDetected,
+			Message: "all the live resources are the same as the desired state",
+		}
+	}
+
+	listed := drifted
+	if len(listed) > maxDriftedResourcesInMessage {
+		listed = listed[:maxDriftedResourcesInMessage]
+	}
+	message := fmt.Sprintf("%d resource(s) drifted from the desired state: %s", len(drifted),
+		strings.Join(listed, ", "))
+	if len(drifted) > len(listed) {
+		message += fmt.Sprintf(" and %d more", len(drifted)-len(listed))
+	}
+	return &metav1.Condition{
+		Type:    v1alpha1.ApplicationConditionDrifted,
+		Status:  metav1.ConditionTrue,
+		Reason:  v1alpha1.DriftReasonDetected,
+		Message: message,
+	}
+}
+
+func detectionFailedCondition(err error) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    v1alpha1.ApplicationConditionDrifted,
+		Status:  metav1.ConditionUnknown,
+		Reason:  v1alpha1.DriftReasonDetectionFailed,
+		Message: err.Error(),
+	}
+}
+
+// setCondition updates the Drifted condition of the Application if it changed
+func (r *Reconciler) setCondition(ctx context.Context, app *v1alpha1.Application, condition metav1.Condition) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		latest := &v1alpha1.Application{}
+		if err = r.Get(ctx, client.ObjectKeyFromObject(app), latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		previous := meta.FindStatusCondition(latest.Status.Conditions, condition.Type)
+		if previous != nil && previous.Status == condition.Status && previous.Reason == condition.Reason &&
+			previous.Message == condition.Message && previous.ObservedGeneration == condition.ObservedGeneration {
+			return
+		}
+		drifted := condition.Status == metav1.ConditionTrue && (previous == nil || previous.Status != metav1.ConditionTrue)
+
+		meta.SetStatusCondition(&latest.Status.Conditions, condition)
+		if err = r.Status().Update(ctx, latest); err == nil && drifted {
+			r.recorder.Event(latest, corev1.EventTypeWarning, v1alpha1.DriftReasonDetected, condition.Message)
+		}
+		return
+	})
+}
+
+// GetName returns the name of this controller
+func (r *Reconciler) GetName() string {
+	return "DriftDetectionController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *Reconciler) GetGroupName() string {
+	return "drift"
+}
+
+// argoStatusChangedPredicate accepts the updates of the Argo CD Application status
+var argoStatusChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldApp, okOld := e.ObjectOld.(*v1alpha1.Application)
+		newApp, okNew := e.ObjectNew.(*v1alpha1.Application)
+		return okOld && okNew && oldApp.Status.ArgoApp != newApp.Status.ArgoApp
+	},
+}
+
+// SetupWithManager setups the log, recorder and the differ
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	if r.Interval <= 0 {
+		r.Interval = DefaultInterval
+	}
+	if r.fluxDiffer == nil {
+		r.fluxDiffer = diff.NewKustomizationDiffer(mgr.GetClient())
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Application{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, argoStatusChangedPredicate))).
+		Complete(r)
+}
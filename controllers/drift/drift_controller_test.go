@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type fakeDiffer struct {
+	diffs []diff.ResourceDiff
+	err   error
+}
+
+func (d *fakeDiffer) Diff(context.Context, *v1alpha1.Application) ([]diff.ResourceDiff, error) {
+	return d.diffs, d.err
+}
+
+func TestReconcile(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+
+	newArgoApp := func(status string) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns", Generation: 2},
+			Spec:       v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}},
+			Status:     v1alpha1.ApplicationStatus{ArgoApp: status},
+		}
+	}
+	fluxApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{
+			Spec: v1alpha1.FluxApplicationSpec{Config: &v1alpha1.FluxApplicationConfig{
+				Kustomization: []*v1alpha1.KustomizationSpec{{}},
+			}},
+		}},
+	}
+	helmApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{
+			Spec: v1alpha1.FluxApplicationSpec{Config: &v1alpha1.FluxApplicationConfig{
+				HelmRelease: &v1alpha1.HelmReleaseSpec{},
+			}},
+		}},
+	}
+
+	tests := []struct {
+		name          string
+		app           runtime.Object
+		differ        diff.Differ
+		wantRequeue   bool
+		wantCondition *metav1.Condition
+		wantEvent     bool
+	}{{
+		name: "not found",
+	}, {
+		name: "HelmRelease is not supported",
+		app:  helmApp,
+	}, {
+		name:        "Argo CD has not reported the status",
+		app:         newArgoApp(""),
+		wantRequeue: true,
+	}, {
+		name:        "Argo CD Application is out of sync",
+		app:         newArgoApp(`{"resources": [{"kind": "Service", "namespace": "ns", "name": "a", "status": "OutOfSync"}, {"group": "apps", "kind": "Deployment", "namespace": "ns", "name": "b", "status": "Synced"}]}`),
+		wantRequeue: true,
+		wantCondition: &metav1.Condition{
+			Type:               v1alpha1.ApplicationConditionDrifted,
+			Status:             metav1.ConditionTrue,
+			Reason:             v1alpha1.DriftReasonDetected,
+			Message:            "1 resource(s) drifted from the desired state: Service ns/a",
+			ObservedGeneration: 2,
+		},
+		wantEvent: true,
+	}, {
+		name:        "Argo CD Application is synced",
+		app:         newArgoApp(`{"resources": [{"kind": "Service", "namespace": "ns", "name": "a", "status": "Synced"}]}`),
+		wantRequeue: true,
+		wantCondition: &metav1.Condition{
+			Type:               v1alpha1.ApplicationConditionDrifted,
+			Status:             metav1.ConditionFalse,
+			Reason:             v1alpha1.DriftReasonNotDetected,
+			Message:            "all the live resources are the same as the desired state",
+			ObservedGeneration: 2,
+		},
+	}, {
+		name:        "invalid Argo CD status",
+		app:         newArgoApp(`{`),
+		wantRequeue: true,
+		wantCondition: &metav1.Condition{
+			Type:               v1alpha1.ApplicationConditionDrifted,
+			Status:             metav1.ConditionUnknown,
+			Reason:             v1alpha1.DriftReasonDetectionFailed,
+			Message:            "failed to parse the status of Argo CD Application: unexpected end of JSON input",
+			ObservedGeneration: 2,
+		},
+	}, {
+		name: "FluxCD Kustomization drifted",
+		app:  fluxApp.DeepCopy(),
+		differ: &fakeDiffer{diffs: []diff.ResourceDiff{
+			{Kind: "ConfigMap", Namespace: "ns", Name: "a"},
+			{Kind: "ConfigMap", Namespace: "ns", Name: "b"},
+			{Kind: "ConfigMap", Namespace: "ns", Name: "c"},
+			{Kind: "ConfigMap", Namespace: "ns", Name: "d"},
+			{Kind: "ConfigMap", Namespace: "ns", Name: "e"},
+			{Kind: "Namespace", Name: "ns"},
+		}},
+		wantRequeue: true,
+		wantCondition: &metav1.Condition{
+			Type:   v1alpha1.ApplicationConditionDrifted,
+			Status: metav1.ConditionTrue,
+			Reason: v1alpha1.DriftReasonDetected,
+			Message: "6 resource(s) drifted from the desired state: ConfigMap ns/a, ConfigMap ns/b, " +
+				"ConfigMap ns/c, ConfigMap ns/d, ConfigMap ns/e and 1 more",
+		},
+		wantEvent: true,
+	}, {
+		name:        "failed to detect the drift of FluxCD Kustomization",
+		app:         fluxApp.DeepCopy(),
+		differ:      &fakeDiffer{err: errors.New("fake error")},
+		wantRequeue: true,
+		wantCondition: &metav1.Condition{
+			Type:    v1alpha1.ApplicationConditionDrifted,
+			Status:  metav1.ConditionUnknown,
+			Reason:  v1alpha1.DriftReasonDetectionFailed,
+			Message: "fake error",
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(schema)
+			if tt.app != nil {
+				builder.WithRuntimeObjects(tt.app)
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &Reconciler{
+				Client:     builder.Build(),
+				Interval:   DefaultInterval,
+				log:        logr.New(log.NullLogSink{}),
+				recorder:   recorder,
+				fluxDiffer: tt.differ,
+			}
+			key := types.NamespacedName{Namespace: "ns", Name: "app"}
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRequeue, result.RequeueAfter == DefaultInterval)
+			assert.Equal(t, tt.wantEvent, len(recorder.Events) == 1)
+			if tt.wantEvent {
+				assert.Contains(t, <-recorder.Events, "Warning DriftDetected")
+			}
+
+			if tt.app == nil {
+				return
+			}
+			app := &v1alpha1.Application{}
+			assert.NoError(t, r.Get(context.Background(), key, app))
+			condition := meta.FindStatusCondition(app.Status.Conditions, v1alpha1.ApplicationConditionDrifted)
+			if tt.wantCondition == nil {
+				assert.Nil(t, condition)
+				return
+			}
+			assert.NotNil(t, condition)
+			condition.LastTransitionTime = metav1.Time{}
+			assert.Equal(t, tt.wantCondition, condition)
+
+			// nothing changes in the next round
+			_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			assert.NoError(t, err)
+			assert.Equal(t, 0, len(recorder.Events))
+		})
+	}
+}
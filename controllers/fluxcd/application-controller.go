@@ -26,9 +26,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/controllers/rollout"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
 	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	fluxmeta "kubesphere.io/devops/pkg/external/fluxcd/meta"
 	sourcev1 "kubesphere.io/devops/pkg/external/fluxcd/source/v1beta2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,11 +39,13 @@ import (
 	"time"
 )
 
-//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=watch;get;list
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=watch;get;list;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications/status,verbs=get;update
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups="kustomize.toolkit.fluxcd.io",resources=kustomizations,verbs=watch;get;list;create;update;delete
 //+kubebuilder:rbac:groups="helm.toolkit.fluxcd.io",resources=helmreleases,verbs=watch;get;list;create;update;delete
 //+kubebuilder:rbac:groups="source.toolkit.fluxcd.io",resources=helmcharts,verbs=watch;get;list;create
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list
 
 // ApplicationReconciler is the reconciler of the FluxCD HelmRelease and FluxCD Kustomization
 type ApplicationReconciler struct {
@@ -64,7 +69,12 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return
 	}
 
-	if err = r.reconcileFluxApp(app); err != nil {
+	var plan *rollout.Plan
+	if plan, result, err = rollout.Reconcile(ctx, r.Client, r.recorder, app, r.getRolloutHealth); err != nil {
+		return
+	}
+
+	if err = r.reconcileFluxApp(app, plan); err != nil {
 		return
 	}
 
@@ -87,66 +97,94 @@ func validateFluxApp(app *v1alpha1.Application) (err error) {
 	return
 }
 
-func (r *ApplicationReconciler) reconcileFluxApp(app *v1alpha1.Application) (err error) {
+func (r *ApplicationReconciler) reconcileFluxApp(app *v1alpha1.Application, plan *rollout.Plan) (err error) {
 	ctx := context.Background()
 
 	var at AppType
 	at, err = isHelmOrKustomize(app)
 	switch at {
 	case HelmRelease:
-		return r.reconcileHelmRelease(ctx, app)
+		return r.reconcileHelmRelease(ctx, app, plan)
 	case Kustomization:
-		return r.reconcileKustomization(ctx, app)
+		return r.reconcileKustomization(ctx, app, plan)
 	}
 	return
 }
 
-func (r *ApplicationReconciler) reconcileHelmRelease(ctx context.Context, app *v1alpha1.Application) (err error) {
+func (r *ApplicationReconciler) reconcileHelmRelease(ctx context.Context, app *v1alpha1.Application, plan *rollout.Plan) (err error) {
 	fluxApp := app.Spec.FluxApp.DeepCopy()
 	if err = checkHelmRelease(fluxApp); err != nil {
 		return
 	}
 
 	var helmChart *sourcev1.HelmChart
+	if helmChart, err = r.getHelmChart(ctx, app, fluxApp); err != nil {
+		return
+	}
+	if fluxApp.Spec.Config.HelmRelease.Template == "" && wantSaveHelmTemplate(app) {
+		if err = r.saveTemplate(ctx, helmChart); err != nil {
+			return
+		}
+	}
+
+	if err = r.reconcileHelmReleaseList(ctx, app, helmChart, plan); err != nil {
+		return
+	}
+	return
+}
+
+func (r *ApplicationReconciler) getHelmChart(ctx context.Context, app *v1alpha1.Application, fluxApp *v1alpha1.FluxApplication) (
+	helmChart *sourcev1.HelmChart, err error) {
 	// 1. get helmChart by searching existed helm template
 	if helmTemplateName := fluxApp.Spec.Config.HelmRelease.Template; helmTemplateName != "" {
 		helmTemplateNS := app.GetNamespace()
 
 		helmChart = &sourcev1.HelmChart{}
-		if err = r.Get(ctx, types.NamespacedName{Namespace: helmTemplateNS, Name: helmTemplateName}, helmChart); err != nil {
-			return
-		}
-	} else {
-		// 2. get helmChart by building from app
-		if helmChart, err = buildTemplateFromApp(fluxApp); err != nil {
-			return
-		}
-		helmChart.SetName(app.GetName())
-		helmChart.SetNamespace(app.GetNamespace())
-		helmChart.SetLabels(map[string]string{
-			"app.kubernetes.io/managed-by": v1alpha1.GroupName,
-		})
-		helmChart.SetAnnotations(map[string]string{
-			v1alpha1.HelmTemplateName: app.GetName(),
-		})
-
-		if wantSaveHelmTemplate(app) {
-			if err = r.saveTemplate(ctx, helmChart); err != nil {
-				return
-			}
-		}
+		err = r.Get(ctx, types.NamespacedName{Namespace: helmTemplateNS, Name: helmTemplateName}, helmChart)
+		return
+	}
+
+	// 2. get helmChart by building from app
+	if helmChart, err = buildTemplateFromApp(fluxApp); err != nil {
+		return
 	}
+	helmChart.SetName(app.GetName())
+	helmChart.SetNamespace(app.GetNamespace())
+	helmChart.SetLabels(map[string]string{
+		"app.kubernetes.io/managed-by": v1alpha1.GroupName,
+	})
+	helmChart.SetAnnotations(map[string]string{
+		v1alpha1.HelmTemplateName: app.GetName(),
+	})
+	return
+}
 
-	if err = r.reconcileHelmReleaseList(ctx, app, helmChart); err != nil {
+// getStableHelmReleases returns the chart and the Deploys of the stable revision which the destinations are kept in
+func (r *ApplicationReconciler) getStableHelmReleases(ctx context.Context, app *v1alpha1.Application, plan *rollout.Plan) (
+	helmChart *sourcev1.HelmChart, deploys map[string]*v1alpha1.Deploy, err error) {
+	deploys = map[string]*v1alpha1.Deploy{}
+	if plan == nil || plan.Stable.FluxApp == nil || checkHelmRelease(plan.Stable.FluxApp) != nil {
 		return
 	}
+	if helmChart, err = r.getHelmChart(ctx, app, plan.Stable.FluxApp); err != nil {
+		return
+	}
+	for _, deploy := range plan.Stable.FluxApp.Spec.Config.HelmRelease.Deploy {
+		deploys[getHelmReleaseName(deploy)] = deploy
+	}
 	return
 }
 
-func (r *ApplicationReconciler) reconcileHelmReleaseList(ctx context.Context, app *v1alpha1.Application, helmChart *sourcev1.HelmChart) (err error) {
+func (r *ApplicationReconciler) reconcileHelmReleaseList(ctx context.Context, app *v1alpha1.Application, helmChart *sourcev1.HelmChart,
+	plan *rollout.Plan) (err error) {
 	fluxApp := app.Spec.FluxApp.DeepCopy()
 	appNS, appName := app.GetNamespace(), app.GetName()
 
+	stableHelmChart, stableDeploys, err := r.getStableHelmReleases(ctx, app, plan)
+	if err != nil {
+		return
+	}
+
 	fluxHelmReleaseList := &helmv2.HelmReleaseList{}
 	if err = r.List(ctx, fluxHelmReleaseList, client.InNamespace(appNS), client.MatchingLabels{
 		"app.kubernetes.io/managed-by": appName,
@@ -162,17 +200,31 @@ func (r *ApplicationReconciler) reconcileHelmReleaseList(ctx context.Context, ap
 
 	for _, deploy := range fluxApp.Spec.Config.HelmRelease.Deploy {
 		name := getHelmReleaseName(deploy)
+		chart := helmChart
+		if !plan.IsPromoted(name) {
+			// keep the destination in the stable revision during the rollout
+			if deploy = stableDeploys[name]; deploy == nil {
+				continue
+			}
+			chart = stableHelmChart
+		} else if plan != nil && plan.Weight != nil {
+			deploy = deploy.DeepCopy()
+			if deploy.Values, err = rollout.SetHelmValue(deploy.Values, plan.WeightParameter, *plan.Weight); err != nil {
+				return
+			}
+		}
+
 		if hr, ok := hrMap[name]; !ok {
 			// there is no matching helmRelease
 			// create
-			if err = r.createHelmRelease(ctx, app, helmChart, deploy); err != nil {
+			if err = r.createHelmRelease(ctx, app, chart, deploy); err != nil {
 				return
 			}
 		} else {
 			// there is a matching helmRelease
 			// update the helmRelease
 			// TODO: determine whether this helmrelease should update by ResourceVersion
-			if err = r.updateHelmRelease(ctx, hr, helmChart, deploy); err != nil {
+			if err = r.updateHelmRelease(ctx, hr, chart, deploy); err != nil {
 				return
 			}
 		}
@@ -230,7 +282,7 @@ func (r *ApplicationReconciler) updateHelmRelease(ctx context.Context, hr *helmv
 	return
 }
 
-func (r *ApplicationReconciler) reconcileKustomization(ctx context.Context, app *v1alpha1.Application) (err error) {
+func (r *ApplicationReconciler) reconcileKustomization(ctx context.Context, app *v1alpha1.Application, plan *rollout.Plan) (err error) {
 	if err = checkKustomization(app.Spec.FluxApp); err != nil {
 		return err
 	}
@@ -249,30 +301,49 @@ func (r *ApplicationReconciler) reconcileKustomization(ctx context.Context, app
 		kusMap[name] = kus.DeepCopy()
 	}
 
+	stableDeploys := map[string]*v1alpha1.KustomizationSpec{}
+	if plan != nil && plan.Stable.FluxApp != nil && checkKustomization(plan.Stable.FluxApp) == nil {
+		for _, kusDeploy := range plan.Stable.FluxApp.Spec.Config.Kustomization {
+			stableDeploys[getKustomizationName(kusDeploy)] = kusDeploy
+		}
+	}
+
 	for _, kusDeploy := range app.Spec.FluxApp.Spec.Config.Kustomization {
 		name := getKustomizationName(kusDeploy)
+		fluxApp := app.Spec.FluxApp
+		if !plan.IsPromoted(name) {
+			// keep the destination in the stable revision during the rollout
+			if kusDeploy = stableDeploys[name]; kusDeploy == nil {
+				continue
+			}
+			fluxApp = plan.Stable.FluxApp
+		}
+
 		if kus, ok := kusMap[name]; !ok {
 			// not found
 			// create
-			err = r.createKustomization(ctx, app, kusDeploy)
+			err = r.createKustomization(ctx, app, fluxApp, kusDeploy)
 		} else {
 			// found
 			// update this kus
-			err = r.updateKustomization(ctx, app, kus, kusDeploy)
+			err = r.updateKustomization(ctx, fluxApp, kus, kusDeploy)
 		}
 	}
 	return
 }
 
-func (r *ApplicationReconciler) createKustomization(ctx context.Context, app *v1alpha1.Application, deploy *v1alpha1.KustomizationSpec) (err error) {
+func (r *ApplicationReconciler) createKustomization(ctx context.Context, app *v1alpha1.Application, fluxApp *v1alpha1.FluxApplication,
+	deploy *v1alpha1.KustomizationSpec) (err error) {
 	appNS, appName := app.GetNamespace(), app.GetName()
 	kusNS := appNS
 
-	fluxApp := app.Spec.FluxApp.DeepCopy()
 	var kus *kusv1.Kustomization
 	if kus, err = buildKustomization(fluxApp, deploy); err != nil {
 		return
 	}
+	if err = r.setDecryption(ctx, kusNS, kus); err != nil {
+		return
+	}
 	kus.SetNamespace(kusNS)
 	kus.SetGenerateName(appName)
 	kus.SetName("")
@@ -299,12 +370,15 @@ func (r *ApplicationReconciler) createKustomization(ctx context.Context, app *v1
 	r.recorder.Eventf(kus, corev1.EventTypeNormal, "Created", "Created FluxCD Kustomization %s", kus.GetAnnotations()["app.kubernetes.io/name"])
 	return
 }
-func (r *ApplicationReconciler) updateKustomization(ctx context.Context, app *v1alpha1.Application, kus *kusv1.Kustomization, deploy *v1alpha1.KustomizationSpec) (err error) {
-	fluxApp := app.Spec.FluxApp.DeepCopy()
+func (r *ApplicationReconciler) updateKustomization(ctx context.Context, fluxApp *v1alpha1.FluxApplication, kus *kusv1.Kustomization,
+	deploy *v1alpha1.KustomizationSpec) (err error) {
 	var newKus *kusv1.Kustomization
 	if newKus, err = buildKustomization(fluxApp, deploy); err != nil {
 		return
 	}
+	if err = r.setDecryption(ctx, kus.GetNamespace(), newKus); err != nil {
+		return
+	}
 	kus.Spec = newKus.Spec
 	if err = r.Update(ctx, kus); err != nil {
 		return
@@ -314,6 +388,38 @@ func (r *ApplicationReconciler) updateKustomization(ctx context.Context, app *v1
 	return
 }
 
+// setDecryption decrypts the SOPS encrypted manifests of the Kustomization with the SOPS credential in the namespace,
+// unless a decryption secret is specified. The first one in name order is used if there are multiple SOPS credentials.
+// Only the credentials of the SOPS type are picked automatically, an explicit decryption secret is always kept as is.
+func (r *ApplicationReconciler) setDecryption(ctx context.Context, namespace string, kus *kusv1.Kustomization) (err error) {
+	if kus.Spec.Decryption != nil && kus.Spec.Decryption.SecretRef != nil {
+		return
+	}
+
+	secrets := &corev1.SecretList{}
+	if err = r.List(ctx, secrets, client.InNamespace(namespace)); err != nil {
+		return
+	}
+	var credential string
+	for _, secret := range secrets.Items {
+		if secret.Type == devopsv1alpha3.SecretTypeSOPS && secret.DeletionTimestamp.IsZero() &&
+			(credential == "" || secret.Name < credential) {
+			credential = secret.Name
+		}
+	}
+	if credential == "" {
+		return
+	}
+
+	decryption := &kusv1.Decryption{Provider: sopsDecryptionProvider}
+	if kus.Spec.Decryption != nil {
+		decryption = kus.Spec.Decryption.DeepCopy()
+	}
+	decryption.SecretRef = &fluxmeta.LocalObjectReference{Name: credential}
+	kus.Spec.Decryption = decryption
+	return
+}
+
 func isHelmOrKustomize(app *v1alpha1.Application) (at AppType, err error) {
 	isHelm, isKus := checkHelmRelease(app.Spec.FluxApp) == nil, checkKustomization(app.Spec.FluxApp) == nil
 	if isHelm && !isKus {
@@ -21,11 +21,13 @@ import (
 	"fmt"
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"kubesphere.io/devops/controllers/core"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
 	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
@@ -52,6 +54,9 @@ func TestApplicationReconciler_Reconcile(t *testing.T) {
 	err = kusv1.SchemeBuilder.AddToScheme(schema)
 	assert.Nil(t, err)
 
+	err = corev1.AddToScheme(schema)
+	assert.Nil(t, err)
+
 	argoApp := &v1alpha1.Application{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: "fake-ns",
@@ -302,6 +307,9 @@ func TestApplicationReconciler_reconcileApp(t *testing.T) {
 	err = kusv1.SchemeBuilder.AddToScheme(schema)
 	assert.Nil(t, err)
 
+	err = corev1.AddToScheme(schema)
+	assert.Nil(t, err)
+
 	helmApp := &v1alpha1.Application{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "fake-app",
@@ -839,7 +847,7 @@ func TestApplicationReconciler_reconcileApp(t *testing.T) {
 				log:      logr.New(log.NullLogSink{}),
 				recorder: &record.FakeRecorder{},
 			}
-			err := r.reconcileFluxApp(tt.args.app)
+			err := r.reconcileFluxApp(tt.args.app, nil)
 			tt.verify(t, tt.fields.Client, err)
 		})
 	}
@@ -1036,3 +1044,60 @@ func TestApplicationReconciler_SetupWithManager(t *testing.T) {
 		})
 	}
 }
+
+func TestApplicationReconciler_setDecryption(t *testing.T) {
+	schema, err := kusv1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = corev1.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	newSecret := func(name string, secretType corev1.SecretType) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "fake-ns", Name: name},
+			Type:       secretType,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		objects    []client.Object
+		decryption *kusv1.Decryption
+		want       *kusv1.Decryption
+	}{{
+		name:    "no SOPS credentials",
+		objects: []client.Object{newSecret("opaque", corev1.SecretTypeOpaque)},
+	}, {
+		name: "the first SOPS credential",
+		objects: []client.Object{
+			newSecret("sops-b", devopsv1alpha3.SecretTypeSOPS),
+			newSecret("sops-a", devopsv1alpha3.SecretTypeSOPS),
+			newSecret("basic", devopsv1alpha3.SecretTypeBasicAuth),
+		},
+		want: &kusv1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "sops-a"}},
+	}, {
+		name:       "the decryption without secret",
+		objects:    []client.Object{newSecret("sops", devopsv1alpha3.SecretTypeSOPS)},
+		decryption: &kusv1.Decryption{Provider: "sops"},
+		want:       &kusv1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "sops"}},
+	}, {
+		name:       "the specified decryption secret",
+		objects:    []client.Object{newSecret("sops", devopsv1alpha3.SecretTypeSOPS)},
+		decryption: &kusv1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "keys"}},
+		want:       &kusv1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "keys"}},
+	}, {
+		name:       "the specified Opaque decryption secret",
+		objects:    []client.Object{newSecret("sops-age", corev1.SecretTypeOpaque)},
+		decryption: &kusv1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "sops-age"}},
+		want:       &kusv1.Decryption{Provider: "sops", SecretRef: &meta.LocalObjectReference{Name: "sops-age"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ApplicationReconciler{
+				Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.objects...).Build(),
+			}
+			kus := &kusv1.Kustomization{Spec: kusv1.KustomizationSpec{Decryption: tt.decryption}}
+			assert.Nil(t, r.setDecryption(context.Background(), "fake-ns", kus))
+			assert.Equal(t, tt.want, kus.Spec.Decryption)
+		})
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=chartrepositories,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=chartrepositories/status,verbs=get;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="source.toolkit.fluxcd.io",resources=helmrepositories,verbs=get;list;create;update;delete
+
+// chartRepositoryStatusInterval is the interval of copying the status of the FluxCD HelmRepository
+const chartRepositoryStatusInterval = time.Minute
+
+// ChartRepositoryReconciler maintains the FluxCD HelmRepository against to the ChartRepository
+type ChartRepositoryReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+// Reconcile makes sure the FluxCD HelmRepository is consistent with the ChartRepository,
+// and copies the conditions of the FluxCD HelmRepository back
+func (r *ChartRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.Info(fmt.Sprintf("start to reconcile chartRepository: %s", req.String()))
+
+	repo := &v1alpha1.ChartRepository{}
+	if err = r.Get(ctx, req.NamespacedName, repo); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	// the FluxCD HelmRepository will be deleted by the garbage collector
+	if !repo.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	status := repo.Status.DeepCopy()
+	if validateErr := repo.Validate(); validateErr != nil {
+		r.recorder.Eventf(repo, corev1.EventTypeWarning, v1alpha1.ChartRepositoryReasonInvalid, validateErr.Error())
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               v1alpha1.ChartRepositoryConditionReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             v1alpha1.ChartRepositoryReasonInvalid,
+			Message:            validateErr.Error(),
+			ObservedGeneration: repo.Generation,
+		})
+		err = r.updateStatus(ctx, repo, status)
+		return
+	}
+
+	var fluxHelmRepo *unstructured.Unstructured
+	if fluxHelmRepo, err = r.reconcileFluxHelmRepo(ctx, repo); err != nil {
+		r.recorder.Eventf(repo, corev1.EventTypeWarning, "FailedWithFluxCD",
+			"failed to create or update FluxCD HelmRepository, error is: %v", err)
+		return
+	}
+
+	setChartRepositoryConditions(status, fluxHelmRepo, repo.Generation)
+	if err = r.updateStatus(ctx, repo, status); err == nil {
+		result = ctrl.Result{RequeueAfter: chartRepositoryStatusInterval}
+	}
+	return
+}
+
+func (r *ChartRepositoryReconciler) reconcileFluxHelmRepo(ctx context.Context, repo *v1alpha1.ChartRepository) (
+	fluxHelmRepo *unstructured.Unstructured, err error) {
+	fluxHelmRepo = createBareFluxHelmRepoObject()
+	fluxHelmRepo.SetNamespace(repo.Namespace)
+	fluxHelmRepo.SetName(getFluxRepoName(repo.Name))
+
+	var op controllerutil.OperationResult
+	op, err = controllerutil.CreateOrUpdate(ctx, r.Client, fluxHelmRepo, func() error {
+		fluxHelmRepo.Object["spec"] = getFluxHelmRepoSpec(repo)
+		labels := fluxHelmRepo.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels["app.kubernetes.io/managed-by"] = v1alpha1.GroupName
+		fluxHelmRepo.SetLabels(labels)
+		return controllerutil.SetControllerReference(repo, fluxHelmRepo, r.Scheme())
+	})
+	if err == nil && op != controllerutil.OperationResultNone {
+		r.log.Info(fmt.Sprintf("%s FluxCD HelmRepository", op), "name", fluxHelmRepo.GetName())
+	}
+	return
+}
+
+func getFluxHelmRepoSpec(repo *v1alpha1.ChartRepository) map[string]interface{} {
+	spec := map[string]interface{}{
+		"url":      repo.Spec.URL,
+		"interval": repo.GetInterval().String(),
+	}
+	if repo.GetType() == v1alpha1.ChartRepositoryTypeOCI {
+		spec["type"] = string(v1alpha1.ChartRepositoryTypeOCI)
+	}
+	if repo.Spec.Secret != nil && repo.Spec.Secret.Name != "" {
+		spec["secretRef"] = map[string]interface{}{"name": repo.Spec.Secret.Name}
+	}
+	if repo.Spec.Suspend {
+		spec["suspend"] = true
+	}
+	return spec
+}
+
+// setChartRepositoryConditions copies the Ready condition of the FluxCD HelmRepository.
+// The FluxCD HelmRepository of the oci type has no status, it's ready once it's created.
+func setChartRepositoryConditions(status *v1alpha1.ChartRepositoryStatus, fluxHelmRepo *unstructured.Unstructured,
+	generation int64) {
+	ready := metav1.Condition{
+		Type:               v1alpha1.ChartRepositoryConditionReady,
+		Status:             metav1.ConditionUnknown,
+		Reason:             "Progressing",
+		Message:            "waiting for the FluxCD HelmRepository to be reconciled",
+		ObservedGeneration: generation,
+	}
+	if repoType, _, _ := unstructured.NestedString(fluxHelmRepo.Object, "spec", "type"); repoType ==
+		string(v1alpha1.ChartRepositoryTypeOCI) {
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = "Succeeded"
+		ready.Message = "the FluxCD HelmRepository of the oci type is created"
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(fluxHelmRepo.Object, "status", "conditions")
+	for _, item := range conditions {
+		condition := metav1.Condition{}
+		if data, ok := item.(map[string]interface{}); !ok || runtime.DefaultUnstructuredConverter.
+			FromUnstructured(data, &condition) != nil || condition.Type != v1alpha1.ChartRepositoryConditionReady {
+			continue
+		}
+		ready.Status = condition.Status
+		ready.Reason = condition.Reason
+		ready.Message = condition.Message
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
+
+func (r *ChartRepositoryReconciler) updateStatus(ctx context.Context, repo *v1alpha1.ChartRepository,
+	status *v1alpha1.ChartRepositoryStatus) error {
+	if reflect.DeepEqual(status, &repo.Status) {
+		return nil
+	}
+	repo.Status = *status
+	return r.Status().Update(ctx, repo)
+}
+
+func createBareFluxHelmRepoObject() *unstructured.Unstructured {
+	fluxHelmRepo := &unstructured.Unstructured{}
+	fluxHelmRepo.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "source.toolkit.fluxcd.io",
+		Version: "v1beta2",
+		Kind:    "HelmRepository",
+	})
+	return fluxHelmRepo
+}
+
+// GetName returns the name of this controller
+func (r *ChartRepositoryReconciler) GetName() string {
+	return "FluxChartRepositoryReconciler"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *ChartRepositoryReconciler) GetGroupName() string {
+	return controllerGroupName
+}
+
+// SetupWithManager setups the log and recorder
+func (r *ChartRepositoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ChartRepository{}).
+		Complete(r)
+}
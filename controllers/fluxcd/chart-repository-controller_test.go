@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestChartRepositoryReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	helmRepo := &v1alpha1.ChartRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "bitnami", Namespace: "fake", UID: "uid", Generation: 1},
+		Spec: v1alpha1.ChartRepositorySpec{
+			URL:      "https://charts.bitnami.com/bitnami",
+			Secret:   &v1.LocalObjectReference{Name: "bitnami-auth"},
+			Interval: &metav1.Duration{Duration: 5 * time.Minute},
+		},
+	}
+	ociRepo := &v1alpha1.ChartRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "fake", UID: "uid"},
+		Spec: v1alpha1.ChartRepositorySpec{
+			Type:    v1alpha1.ChartRepositoryTypeOCI,
+			URL:     "oci://ghcr.io/stefanprodan/charts",
+			Suspend: true,
+		},
+	}
+	invalidRepo := &v1alpha1.ChartRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "fake"},
+		Spec: v1alpha1.ChartRepositorySpec{
+			Type: v1alpha1.ChartRepositoryTypeOCI,
+			URL:  "https://ghcr.io/stefanprodan/charts",
+		},
+	}
+
+	getFluxHelmRepo := func(c client.Client, name string) *unstructured.Unstructured {
+		obj := createBareFluxHelmRepoObject()
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: name}, obj); err != nil {
+			return nil
+		}
+		return obj
+	}
+	getReadyCondition := func(c client.Client, name string) *metav1.Condition {
+		repo := &v1alpha1.ChartRepository{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: name}, repo))
+		return meta.FindStatusCondition(repo.Status.Conditions, v1alpha1.ChartRepositoryConditionReady)
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema)
+		r := &ChartRepositoryReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: &record.FakeRecorder{}}
+		result, err := r.Reconcile(context.TODO(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Namespace: "fake", Name: "bitnami"}})
+		assert.Nil(t, err)
+		assert.Equal(t, ctrl.Result{}, result)
+	})
+
+	t.Run("invalid spec", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema, invalidRepo.DeepCopy())
+		recorder := record.NewFakeRecorder(10)
+		r := &ChartRepositoryReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: recorder}
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Namespace: "fake", Name: "invalid"}})
+		assert.Nil(t, err)
+		assert.Contains(t, <-recorder.Events, v1alpha1.ChartRepositoryReasonInvalid)
+		assert.Nil(t, getFluxHelmRepo(c, "fluxcd-invalid"))
+
+		ready := getReadyCondition(c, "invalid")
+		if assert.NotNil(t, ready) {
+			assert.Equal(t, metav1.ConditionFalse, ready.Status)
+			assert.Equal(t, v1alpha1.ChartRepositoryReasonInvalid, ready.Reason)
+		}
+	})
+
+	t.Run("helm repository", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema, helmRepo.DeepCopy())
+		r := &ChartRepositoryReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: &record.FakeRecorder{}}
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "fake", Name: "bitnami"}}
+		result, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Equal(t, ctrl.Result{RequeueAfter: chartRepositoryStatusInterval}, result)
+
+		fluxHelmRepo := getFluxHelmRepo(c, "fluxcd-bitnami")
+		if assert.NotNil(t, fluxHelmRepo) {
+			assert.Equal(t, map[string]interface{}{
+				"url":       "https://charts.bitnami.com/bitnami",
+				"interval":  "5m0s",
+				"secretRef": map[string]interface{}{"name": "bitnami-auth"},
+			}, fluxHelmRepo.Object["spec"])
+			assert.True(t, metav1.IsControlledBy(fluxHelmRepo, helmRepo))
+			assert.Equal(t, v1alpha1.GroupName, fluxHelmRepo.GetLabels()["app.kubernetes.io/managed-by"])
+		}
+		ready := getReadyCondition(c, "bitnami")
+		if assert.NotNil(t, ready) {
+			assert.Equal(t, metav1.ConditionUnknown, ready.Status)
+			assert.Equal(t, int64(1), ready.ObservedGeneration)
+		}
+
+		// FluxCD fetched the index.yaml
+		assert.Nil(t, unstructured.SetNestedSlice(fluxHelmRepo.Object, []interface{}{map[string]interface{}{
+			"type":               "Ready",
+			"status":             "True",
+			"reason":             "Succeeded",
+			"message":            "stored artifact for revision 'abc'",
+			"lastTransitionTime": "2022-10-01T10:00:00Z",
+		}}, "status", "conditions"))
+		assert.Nil(t, c.Update(context.TODO(), fluxHelmRepo))
+		_, err = r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		ready = getReadyCondition(c, "bitnami")
+		if assert.NotNil(t, ready) {
+			assert.Equal(t, metav1.ConditionTrue, ready.Status)
+			assert.Equal(t, "Succeeded", ready.Reason)
+			assert.Equal(t, "stored artifact for revision 'abc'", ready.Message)
+		}
+	})
+
+	t.Run("oci repository", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema, ociRepo.DeepCopy())
+		r := &ChartRepositoryReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: &record.FakeRecorder{}}
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Namespace: "fake", Name: "podinfo"}})
+		assert.Nil(t, err)
+
+		fluxHelmRepo := getFluxHelmRepo(c, "fluxcd-podinfo")
+		if assert.NotNil(t, fluxHelmRepo) {
+			assert.Equal(t, map[string]interface{}{
+				"url":      "oci://ghcr.io/stefanprodan/charts",
+				"interval": "10m0s",
+				"type":     "oci",
+				"suspend":  true,
+			}, fluxHelmRepo.Object["spec"])
+		}
+		ready := getReadyCondition(c, "podinfo")
+		if assert.NotNil(t, ready) {
+			assert.Equal(t, metav1.ConditionTrue, ready.Status)
+		}
+	})
+}
@@ -44,3 +44,7 @@ const (
 	// FluxAppLastRevision is the revision of the last successfully applied source.
 	FluxAppLastRevision = "gitops.kubesphere.io/last-revision"
 )
+
+// sopsDecryptionProvider is the only decryption provider of FluxCD Kustomization
+// https://fluxcd.io/docs/components/kustomize/kustomization/#secrets-decryption
+const sopsDecryptionProvider = "sops"
@@ -93,6 +93,11 @@ func (r *ApplicationStatusReconciler) reconcileHelmRelease(ctx context.Context,
 		app.Status.FluxApp.HelmReleaseStatus = make(map[string]*helmv2.HelmReleaseStatus, totalHRNum)
 	}
 	app.Status.FluxApp.HelmReleaseStatus[hr.GetAnnotations()["app.kubernetes.io/name"]] = hr.Status.DeepCopy()
+	statuses := make(map[string]fluxResourceStatus, len(app.Status.FluxApp.HelmReleaseStatus))
+	for name, status := range app.Status.FluxApp.HelmReleaseStatus {
+		statuses[name] = fluxResourceStatus{conditions: status.Conditions, lastAppliedRevision: status.LastAppliedRevision}
+	}
+	setFluxHealthAndSyncStatus(app, statuses, totalHRNum)
 	// Update status
 	if err = r.Status().Update(ctx, app); err != nil {
 		return
@@ -104,6 +109,9 @@ func (r *ApplicationStatusReconciler) reconcileHelmRelease(ctx context.Context,
 	if app.GetAnnotations() == nil {
 		app.SetAnnotations(map[string]string{})
 	}
+	// set the health and sync status into labels for filtering
+	app.GetLabels()[v1alpha1.HealthStatusLabelKey] = string(app.Status.Health)
+	app.GetLabels()[v1alpha1.SyncStatusLabelKey] = string(app.Status.SyncStatus)
 	// should aggregate status from all the HelmRelease that FluxApp managed
 	for _, status := range app.Status.FluxApp.HelmReleaseStatus {
 		if meta.IsStatusConditionTrue(status.Conditions, apimeta.ReadyCondition) {
@@ -140,6 +148,11 @@ func (r *ApplicationStatusReconciler) reconcileKustomization(ctx context.Context
 		app.Status.FluxApp.KustomizationStatus = make(map[string]*kusv1.KustomizationStatus, totalKusNum)
 	}
 	app.Status.FluxApp.KustomizationStatus[kus.GetAnnotations()["app.kubernetes.io/name"]] = kus.Status.DeepCopy()
+	statuses := make(map[string]fluxResourceStatus, len(app.Status.FluxApp.KustomizationStatus))
+	for name, status := range app.Status.FluxApp.KustomizationStatus {
+		statuses[name] = fluxResourceStatus{conditions: status.Conditions, lastAppliedRevision: status.LastAppliedRevision}
+	}
+	setFluxHealthAndSyncStatus(app, statuses, totalKusNum)
 	// Update status
 	if err = r.Status().Update(ctx, app); err != nil {
 		return
@@ -151,6 +164,9 @@ func (r *ApplicationStatusReconciler) reconcileKustomization(ctx context.Context
 	if app.GetAnnotations() == nil {
 		app.SetAnnotations(map[string]string{})
 	}
+	// set the health and sync status into labels for filtering
+	app.GetLabels()[v1alpha1.HealthStatusLabelKey] = string(app.Status.Health)
+	app.GetLabels()[v1alpha1.SyncStatusLabelKey] = string(app.Status.SyncStatus)
 	// should aggregate status from all the Kustomization that FluxApp managed
 	for _, status := range app.Status.FluxApp.KustomizationStatus {
 		if meta.IsStatusConditionTrue(status.Conditions, apimeta.ReadyCondition) {
@@ -564,6 +564,8 @@ func TestApplicationStatusReconciler_reconcileHelmRelease(t *testing.T) {
 				// labels
 				assert.Equal(t, string(HelmRelease), app.GetLabels()[FluxAppTypeKey])
 				assert.Equal(t, "1-1", app.GetLabels()[FluxAppReadyNumKey])
+				assert.Equal(t, v1alpha1.HealthStatusHealthy, app.Status.Health)
+				assert.Equal(t, string(v1alpha1.SyncStatusSynced), app.GetLabels()[v1alpha1.SyncStatusLabelKey])
 			},
 		},
 		{
@@ -749,6 +751,8 @@ func TestApplicationStatusReconciler_reconcileKustomization(t *testing.T) {
 				// labels
 				assert.Equal(t, string(Kustomization), app.GetLabels()[FluxAppTypeKey])
 				assert.Equal(t, "1-1", app.GetLabels()[FluxAppReadyNumKey])
+				assert.Equal(t, v1alpha1.HealthStatusHealthy, app.Status.Health)
+				assert.Equal(t, string(v1alpha1.SyncStatusSynced), app.GetLabels()[v1alpha1.SyncStatusLabelKey])
 			},
 		},
 		{
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	apimeta "kubesphere.io/devops/pkg/external/fluxcd/meta"
+)
+
+// fluxResourceStatus is the common part of the status of HelmRelease and Kustomization
+type fluxResourceStatus struct {
+	conditions          []metav1.Condition
+	lastAppliedRevision string
+}
+
+// setFluxHealthAndSyncStatus aggregates the health and sync status of all the HelmReleases or Kustomizations
+// of the Application, the worst one wins. The ones which have not reported their status are considered as progressing.
+func setFluxHealthAndSyncStatus(app *v1alpha1.Application, statuses map[string]fluxResourceStatus, total int) {
+	names := make([]string, 0, len(statuses))
+	for name := range statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	health, syncStatus := v1alpha1.HealthStatusHealthy, v1alpha1.SyncStatusSynced
+	if len(statuses) < total {
+		health, syncStatus = v1alpha1.HealthStatusProgressing, v1alpha1.SyncStatusUnknown
+	}
+	var unhealthy, outOfSync []string
+	revisions := map[string]bool{}
+	for _, name := range names {
+		status := statuses[name]
+		itemHealth, itemSyncStatus, message := getFluxHealthAndSyncStatus(status.conditions)
+		if itemHealth.IsWorseThan(health) {
+			health = itemHealth
+		}
+		if itemHealth != v1alpha1.HealthStatusHealthy {
+			item := fmt.Sprintf("%s is %s", name, itemHealth)
+			if message != "" {
+				item += ": " + message
+			}
+			unhealthy = append(unhealthy, item)
+		}
+
+		switch itemSyncStatus {
+		case v1alpha1.SyncStatusOutOfSync:
+			syncStatus = v1alpha1.SyncStatusOutOfSync
+			outOfSync = append(outOfSync, name)
+		case v1alpha1.SyncStatusUnknown:
+			if syncStatus == v1alpha1.SyncStatusSynced {
+				syncStatus = v1alpha1.SyncStatusUnknown
+			}
+		}
+		revisions[status.lastAppliedRevision] = true
+	}
+
+	app.Status.SetHealth(health, strings.Join(unhealthy, "; "), app.Generation)
+
+	var syncMessage string
+	switch {
+	case syncStatus == v1alpha1.SyncStatusOutOfSync:
+		syncMessage = fmt.Sprintf("%d of %d are out of sync: %s", len(outOfSync), total, strings.Join(outOfSync, ", "))
+	case syncStatus == v1alpha1.SyncStatusSynced && len(revisions) == 1 && !revisions[""]:
+		for revision := range revisions {
+			syncMessage = "synced to revision " + revision
+		}
+	}
+	app.Status.SetSyncStatus(syncStatus, syncMessage, app.Generation)
+}
+
+// getFluxHealthAndSyncStatus normalizes the health and sync status of a HelmRelease or Kustomization by its conditions
+func getFluxHealthAndSyncStatus(conditions []metav1.Condition) (health v1alpha1.HealthStatus,
+	syncStatus v1alpha1.SyncStatusCode, message string) {
+	if stalled := meta.FindStatusCondition(conditions, apimeta.StalledCondition); stalled != nil &&
+		stalled.Status == metav1.ConditionTrue {
+		return v1alpha1.HealthStatusDegraded, v1alpha1.SyncStatusOutOfSync, stalled.Message
+	}
+
+	ready := meta.FindStatusCondition(conditions, apimeta.ReadyCondition)
+	switch {
+	case ready == nil:
+		return v1alpha1.HealthStatusProgressing, v1alpha1.SyncStatusUnknown, ""
+	case ready.Status == metav1.ConditionTrue:
+		return v1alpha1.HealthStatusHealthy, v1alpha1.SyncStatusSynced, ready.Message
+	case ready.Status == metav1.ConditionUnknown, ready.Reason == apimeta.ProgressingReason,
+		ready.Reason == kusv1.DependencyNotReadyReason,
+		meta.IsStatusConditionTrue(conditions, apimeta.ReconcilingCondition):
+		return v1alpha1.HealthStatusProgressing, v1alpha1.SyncStatusUnknown, ready.Message
+	case ready.Reason == apimeta.SuspendedReason:
+		return v1alpha1.HealthStatusSuspended, v1alpha1.SyncStatusUnknown, ready.Message
+	case ready.Reason == kusv1.HealthCheckFailedReason:
+		// the resources were applied, but they are not healthy
+		return v1alpha1.HealthStatusDegraded, v1alpha1.SyncStatusSynced, ready.Message
+	default:
+		return v1alpha1.HealthStatusDegraded, v1alpha1.SyncStatusOutOfSync, ready.Message
+	}
+}
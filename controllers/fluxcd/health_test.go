@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	apimeta "kubesphere.io/devops/pkg/external/fluxcd/meta"
+)
+
+func Test_getFluxHealthAndSyncStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		conditions     []metav1.Condition
+		wantHealth     v1alpha1.HealthStatus
+		wantSyncStatus v1alpha1.SyncStatusCode
+	}{{
+		name:           "not reported",
+		wantHealth:     v1alpha1.HealthStatusProgressing,
+		wantSyncStatus: v1alpha1.SyncStatusUnknown,
+	}, {
+		name: "ready",
+		conditions: []metav1.Condition{{
+			Type: apimeta.ReadyCondition, Status: metav1.ConditionTrue, Reason: apimeta.SucceededReason,
+		}},
+		wantHealth:     v1alpha1.HealthStatusHealthy,
+		wantSyncStatus: v1alpha1.SyncStatusSynced,
+	}, {
+		name: "reconciling",
+		conditions: []metav1.Condition{{
+			Type: apimeta.ReadyCondition, Status: metav1.ConditionFalse, Reason: apimeta.FailedReason,
+		}, {
+			Type: apimeta.ReconcilingCondition, Status: metav1.ConditionTrue, Reason: apimeta.ProgressingReason,
+		}},
+		wantHealth:     v1alpha1.HealthStatusProgressing,
+		wantSyncStatus: v1alpha1.SyncStatusUnknown,
+	}, {
+		name: "suspended",
+		conditions: []metav1.Condition{{
+			Type: apimeta.ReadyCondition, Status: metav1.ConditionFalse, Reason: apimeta.SuspendedReason,
+		}},
+		wantHealth:     v1alpha1.HealthStatusSuspended,
+		wantSyncStatus: v1alpha1.SyncStatusUnknown,
+	}, {
+		name: "health check failed",
+		conditions: []metav1.Condition{{
+			Type: apimeta.ReadyCondition, Status: metav1.ConditionFalse, Reason: kusv1.HealthCheckFailedReason,
+		}},
+		wantHealth:     v1alpha1.HealthStatusDegraded,
+		wantSyncStatus: v1alpha1.SyncStatusSynced,
+	}, {
+		name: "failed to apply",
+		conditions: []metav1.Condition{{
+			Type: apimeta.ReadyCondition, Status: metav1.ConditionFalse, Reason: kusv1.BuildFailedReason,
+		}},
+		wantHealth:     v1alpha1.HealthStatusDegraded,
+		wantSyncStatus: v1alpha1.SyncStatusOutOfSync,
+	}, {
+		name: "stalled",
+		conditions: []metav1.Condition{{
+			Type: apimeta.ReadyCondition, Status: metav1.ConditionUnknown, Reason: apimeta.ProgressingReason,
+		}, {
+			Type: apimeta.StalledCondition, Status: metav1.ConditionTrue, Reason: apimeta.FailedReason,
+		}},
+		wantHealth:     v1alpha1.HealthStatusDegraded,
+		wantSyncStatus: v1alpha1.SyncStatusOutOfSync,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health, syncStatus, _ := getFluxHealthAndSyncStatus(tt.conditions)
+			assert.Equal(t, tt.wantHealth, health)
+			assert.Equal(t, tt.wantSyncStatus, syncStatus)
+		})
+	}
+}
+
+func Test_setFluxHealthAndSyncStatus(t *testing.T) {
+	ready := []metav1.Condition{{
+		Type: apimeta.ReadyCondition, Status: metav1.ConditionTrue, Reason: apimeta.SucceededReason,
+	}}
+	failed := []metav1.Condition{{
+		Type: apimeta.ReadyCondition, Status: metav1.ConditionFalse, Reason: kusv1.BuildFailedReason,
+		Message: "kustomize build failed",
+	}}
+
+	tests := []struct {
+		name            string
+		statuses        map[string]fluxResourceStatus
+		total           int
+		wantHealth      v1alpha1.HealthStatus
+		wantHealthMsg   string
+		wantSyncStatus  v1alpha1.SyncStatusCode
+		wantSyncMessage string
+	}{{
+		name: "all ready",
+		statuses: map[string]fluxResourceStatus{
+			"a": {conditions: ready, lastAppliedRevision: "main/abc"},
+			"b": {conditions: ready, lastAppliedRevision: "main/abc"},
+		},
+		total:           2,
+		wantHealth:      v1alpha1.HealthStatusHealthy,
+		wantHealthMsg:   "the Application is Healthy",
+		wantSyncStatus:  v1alpha1.SyncStatusSynced,
+		wantSyncMessage: "synced to revision main/abc",
+	}, {
+		name: "waiting for the status",
+		statuses: map[string]fluxResourceStatus{
+			"a": {conditions: ready, lastAppliedRevision: "main/abc"},
+		},
+		total:           2,
+		wantHealth:      v1alpha1.HealthStatusProgressing,
+		wantHealthMsg:   "the Application is Progressing",
+		wantSyncStatus:  v1alpha1.SyncStatusUnknown,
+		wantSyncMessage: "the Application is Unknown",
+	}, {
+		name: "one failed",
+		statuses: map[string]fluxResourceStatus{
+			"b": {conditions: failed, lastAppliedRevision: "main/abc"},
+			"a": {conditions: ready, lastAppliedRevision: "main/def"},
+		},
+		total:           2,
+		wantHealth:      v1alpha1.HealthStatusDegraded,
+		wantHealthMsg:   "b is Degraded: kustomize build failed",
+		wantSyncStatus:  v1alpha1.SyncStatusOutOfSync,
+		wantSyncMessage: "1 of 2 are out of sync: b",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &v1alpha1.Application{}
+			setFluxHealthAndSyncStatus(app, tt.statuses, tt.total)
+			assert.Equal(t, tt.wantHealth, app.Status.Health)
+			assert.Equal(t, tt.wantSyncStatus, app.Status.SyncStatus)
+			assert.Equal(t, tt.wantHealthMsg,
+				meta.FindStatusCondition(app.Status.Conditions, v1alpha1.ApplicationConditionHealthy).Message)
+			assert.Equal(t, tt.wantSyncMessage,
+				meta.FindStatusCondition(app.Status.Conditions, v1alpha1.ApplicationConditionSynced).Message)
+		})
+	}
+}
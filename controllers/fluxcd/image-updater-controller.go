@@ -0,0 +1,521 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=imageupdaters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=imageupdaters/status,verbs=get;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=list;create;patch
+//+kubebuilder:rbac:groups="image.toolkit.fluxcd.io",resources=imagerepositories;imagepolicies;imageupdateautomations,verbs=get;list;create;update;delete
+
+const (
+	// defaultImageUpdateInterval is the default interval of scanning the image repositories
+	defaultImageUpdateInterval = time.Minute
+	// defaultImageExclusion is the default exclusion list of the FluxCD ImageRepository, it excludes the signatures
+	defaultImageExclusion = `^.*\.sig$`
+	// updatedImageTrailer is the prefix of the lines which list the updated images in the commit message
+	updatedImageTrailer = "Updated-Image: "
+	// updatedImagesMessageTemplate is appended to the commit message template, so the pushed images could be found
+	// in the event of the push
+	updatedImagesMessageTemplate = "{{range .Updated.Images}}" + updatedImageTrailer + "{{.}}\n{{end}}"
+	// defaultImageCommitMessage is the commit message of the pushes if there is no message template
+	defaultImageCommitMessage = "Update from image update automation"
+)
+
+// ImageUpdaterReconciler generates the FluxCD ImageRepositories, ImagePolicies and ImageUpdateAutomation
+// according to the ImageUpdater
+type ImageUpdaterReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+	// reader reads the events without caching them
+	reader client.Reader
+}
+
+// Reconcile makes sure the FluxCD image automation objects are consistent with the ImageUpdater
+func (r *ImageUpdaterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.Info(fmt.Sprintf("start to reconcile imageUpdater: %s", req.String()))
+
+	updater := &v1alpha1.ImageUpdater{}
+	if err = r.Get(ctx, req.NamespacedName, updater); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	// skip if kind is not fluxcd
+	if updater.Spec.Kind != string(v1alpha1.FluxCD) {
+		r.log.V(7).Info(fmt.Sprintf("skip %s due to the spec.kind value is not fluxcd", req.String()))
+		return
+	}
+
+	flux := updater.Spec.Flux
+	if flux == nil {
+		r.log.V(7).Info(fmt.Sprintf("skip %s due to the Flux is nil", req.String()))
+		return
+	}
+
+	if flux.App.Name == "" {
+		r.recorder.Eventf(updater, corev1.EventTypeWarning, "Missing", "application name is required")
+		return
+	}
+
+	app := &v1alpha1.Application{}
+	if err = r.Get(ctx, types.NamespacedName{
+		Namespace: req.Namespace,
+		Name:      flux.App.Name,
+	}, app); err != nil {
+		result = ctrl.Result{RequeueAfter: time.Minute}
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	sourceRef, err := getImageUpdateSourceRef(app)
+	if err != nil {
+		r.recorder.Event(updater, corev1.EventTypeWarning, "InvalidApplication", err.Error())
+		err = nil
+		return
+	}
+
+	images := parseImages(updater.Spec.Images)
+	status := updater.Status.DeepCopy()
+	status.Images = make([]v1alpha1.ImageStatus, 0, len(images))
+	names := make(map[string]bool, len(images))
+	for _, image := range images {
+		imagePolicy, policyErr := getImagePolicy(updater.Spec.Flux.UpdateStrategy[image.alias], image.constraint)
+		if policyErr != nil {
+			r.recorder.Eventf(updater, corev1.EventTypeWarning, "InvalidImage", "skip image %s: %v",
+				image.alias, policyErr)
+			continue
+		}
+
+		name := getImageObjectName(updater.Name, image.alias)
+		names[name] = true
+		if err = r.reconcileImageRepository(ctx, updater, name, image); err != nil {
+			return
+		}
+		var policy *unstructured.Unstructured
+		if policy, err = r.reconcileImagePolicy(ctx, updater, name, image, imagePolicy); err != nil {
+			return
+		}
+
+		latestImage, _, _ := unstructured.NestedString(policy.Object, "status", "latestImage")
+		status.Images = append(status.Images, v1alpha1.ImageStatus{
+			Name:           image.alias,
+			Image:          image.name,
+			Policy:         fmt.Sprintf("%s:%s", updater.Namespace, name),
+			LatestTag:      getImageTag(latestImage),
+			LastAppliedTag: getLastAppliedTag(&updater.Status, image.alias),
+		})
+	}
+	if err = r.removeStaleImageObjects(ctx, updater, names); err != nil {
+		return
+	}
+
+	var automation *unstructured.Unstructured
+	if automation, err = r.reconcileImageUpdateAutomation(ctx, updater, sourceRef); err != nil {
+		return
+	}
+	if err = r.setLastPush(ctx, status, automation); err != nil {
+		return
+	}
+
+	if !reflect.DeepEqual(status, &updater.Status) {
+		updater.Status = *status
+		if err = r.Status().Update(ctx, updater); err != nil {
+			return
+		}
+	}
+	result = ctrl.Result{RequeueAfter: getImageUpdateInterval(flux)}
+	return
+}
+
+// image is an item of the image list, the format is [<alias>=]<image>[:<version constraint>], the same as Argo CD
+type image struct {
+	alias      string
+	name       string
+	constraint string
+}
+
+func parseImages(images []string) (result []image) {
+	for _, item := range images {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		img := image{}
+		if i := strings.Index(item, "="); i > 0 {
+			img.alias, item = item[:i], item[i+1:]
+		}
+		// the colon might belong to the port of the registry
+		if i := strings.LastIndex(item, ":"); i > strings.LastIndex(item, "/") {
+			img.constraint, item = item[i+1:], item[:i]
+		}
+		img.name = item
+		if img.alias == "" {
+			img.alias = img.name
+		}
+		result = append(result, img)
+	}
+	return
+}
+
+var invalidObjectNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func getImageObjectName(updater, alias string) string {
+	name := invalidObjectNameChars.ReplaceAllString(strings.ToLower(alias), "-")
+	return fmt.Sprintf("%s-%s", updater, strings.Trim(name, "-"))
+}
+
+// getImageUpdateSourceRef returns the GitRepository of the Application which the updated images are written back to
+func getImageUpdateSourceRef(app *v1alpha1.Application) (sourceRef map[string]interface{}, err error) {
+	if app.Spec.Kind != v1alpha1.FluxCD || app.Spec.FluxApp == nil || app.Spec.FluxApp.Spec.Source == nil {
+		err = fmt.Errorf("application %s is not a FluxCD application with a source", app.Name)
+		return
+	}
+	ref := app.Spec.FluxApp.Spec.Source.SourceRef
+	if ref.Kind != "GitRepository" {
+		err = fmt.Errorf("the source of application %s must be a GitRepository instead of %s", app.Name, ref.Kind)
+		return
+	}
+	sourceRef = map[string]interface{}{
+		"kind": ref.Kind,
+		"name": ref.Name,
+	}
+	if ref.Namespace != "" && ref.Namespace != app.Namespace {
+		sourceRef["namespace"] = ref.Namespace
+	}
+	return
+}
+
+func (r *ImageUpdaterReconciler) reconcileImageRepository(ctx context.Context, updater *v1alpha1.ImageUpdater,
+	name string, image image) error {
+	flux := updater.Spec.Flux
+	repo := createBareFluxImageObject("ImageRepository")
+	repo.SetNamespace(updater.Namespace)
+	repo.SetName(name)
+	return r.createOrUpdate(ctx, updater, repo, func() (err error) {
+		spec := map[string]interface{}{
+			"image":    image.name,
+			"interval": getImageUpdateInterval(flux).String(),
+		}
+		if secret := flux.Secrets[image.alias]; secret != "" {
+			spec["secretRef"] = map[string]interface{}{"name": secret}
+		}
+		if ignoreTags := flux.IgnoreTags[image.alias]; ignoreTags != "" {
+			exclusions := []interface{}{defaultImageExclusion}
+			for _, pattern := range strings.Split(ignoreTags, ",") {
+				if pattern = strings.TrimSpace(pattern); pattern != "" {
+					exclusions = append(exclusions, globToRegexp(pattern))
+				}
+			}
+			spec["exclusionList"] = exclusions
+		}
+		repo.Object["spec"] = spec
+		return
+	})
+}
+
+func (r *ImageUpdaterReconciler) reconcileImagePolicy(ctx context.Context, updater *v1alpha1.ImageUpdater,
+	name string, image image, imagePolicy map[string]interface{}) (policy *unstructured.Unstructured, err error) {
+	flux := updater.Spec.Flux
+	policy = createBareFluxImageObject("ImagePolicy")
+	policy.SetNamespace(updater.Namespace)
+	policy.SetName(name)
+	err = r.createOrUpdate(ctx, updater, policy, func() (err error) {
+		spec := map[string]interface{}{
+			"imageRepositoryRef": map[string]interface{}{"name": name},
+			"policy":             imagePolicy,
+		}
+		if allowTags := flux.AllowTags[image.alias]; allowTags != "" {
+			spec["filterTags"] = map[string]interface{}{"pattern": strings.TrimPrefix(allowTags, "regexp:")}
+		}
+		policy.Object["spec"] = spec
+		return
+	})
+	return
+}
+
+func (r *ImageUpdaterReconciler) reconcileImageUpdateAutomation(ctx context.Context, updater *v1alpha1.ImageUpdater,
+	sourceRef map[string]interface{}) (automation *unstructured.Unstructured, err error) {
+	flux := updater.Spec.Flux
+	automation = createBareFluxImageObject("ImageUpdateAutomation")
+	automation.SetNamespace(updater.Namespace)
+	automation.SetName(updater.Name)
+	err = r.createOrUpdate(ctx, updater, automation, func() (err error) {
+		git := flux.Git
+		author := map[string]interface{}{
+			"name":  "fluxcdbot",
+			"email": "fluxcdbot@users.noreply.github.com",
+		}
+		if git.AuthorName != "" {
+			author["name"] = git.AuthorName
+		}
+		if git.AuthorEmail != "" {
+			author["email"] = git.AuthorEmail
+		}
+		messageTemplate := git.MessageTemplate
+		if messageTemplate == "" {
+			messageTemplate = defaultImageCommitMessage
+		}
+		commit := map[string]interface{}{
+			"author":          author,
+			"messageTemplate": messageTemplate + "\n\n" + updatedImagesMessageTemplate,
+		}
+		gitSpec := map[string]interface{}{"commit": commit}
+		if git.Branch != "" {
+			gitSpec["checkout"] = map[string]interface{}{
+				"ref": map[string]interface{}{"branch": git.Branch},
+			}
+		}
+		if pushBranch := git.PushBranch; pushBranch != "" || git.Branch != "" {
+			if pushBranch == "" {
+				pushBranch = git.Branch
+			}
+			gitSpec["push"] = map[string]interface{}{"branch": pushBranch}
+		}
+
+		path := git.Path
+		if path == "" {
+			path = "./"
+		}
+		automation.Object["spec"] = map[string]interface{}{
+			"interval":  getImageUpdateInterval(flux).String(),
+			"sourceRef": sourceRef,
+			"git":       gitSpec,
+			"update": map[string]interface{}{
+				"path":     path,
+				"strategy": "Setters",
+			},
+		}
+		return
+	})
+	return
+}
+
+// removeStaleImageObjects deletes the ImageRepositories and ImagePolicies of the images which were removed
+func (r *ImageUpdaterReconciler) removeStaleImageObjects(ctx context.Context, updater *v1alpha1.ImageUpdater,
+	names map[string]bool) (err error) {
+	for _, kind := range []string{"ImageRepository", "ImagePolicy"} {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(createBareFluxImageObject(kind + "List").GroupVersionKind())
+		if err = r.List(ctx, list, client.InNamespace(updater.Namespace), client.MatchingLabels{
+			"app.kubernetes.io/managed-by": updater.Name,
+		}); err != nil {
+			return
+		}
+		for i := range list.Items {
+			item := &list.Items[i]
+			if names[item.GetName()] || !metav1.IsControlledBy(item, updater) {
+				continue
+			}
+			r.log.Info(fmt.Sprintf("delete FluxCD %s", kind), "name", item.GetName())
+			if err = client.IgnoreNotFound(r.Delete(ctx, item)); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (r *ImageUpdaterReconciler) createOrUpdate(ctx context.Context, updater *v1alpha1.ImageUpdater,
+	obj *unstructured.Unstructured, mutate func() error) (err error) {
+	var op controllerutil.OperationResult
+	op, err = controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() (err error) {
+		if err = mutate(); err != nil {
+			return
+		}
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels["app.kubernetes.io/managed-by"] = updater.Name
+		obj.SetLabels(labels)
+		return controllerutil.SetControllerReference(updater, obj, r.Scheme())
+	})
+	if err == nil && op != controllerutil.OperationResultNone {
+		r.log.Info(fmt.Sprintf("%s FluxCD %s", op, obj.GetKind()), "name", obj.GetName())
+	}
+	return
+}
+
+// getImagePolicy maps the update strategy of the Argo CD image updater to the FluxCD ImagePolicy
+func getImagePolicy(strategy, constraint string) (policy map[string]interface{}, err error) {
+	switch v1alpha1.ImageUpdateStrategy(strategy) {
+	case "", v1alpha1.UpdateStrategySemver:
+		if constraint == "" {
+			constraint = ">=0.0.0"
+		}
+		policy = map[string]interface{}{
+			"semver": map[string]interface{}{"range": constraint},
+		}
+	case v1alpha1.UpdateStrategyName:
+		// the last one in the ascending order is chosen
+		policy = map[string]interface{}{
+			"alphabetical": map[string]interface{}{"order": "asc"},
+		}
+	default:
+		err = fmt.Errorf("update strategy %q is not supported by FluxCD, the supported ones are: %s, %s",
+			strategy, v1alpha1.UpdateStrategySemver, v1alpha1.UpdateStrategyName)
+	}
+	return
+}
+
+// globToRegexp converts a glob pattern of the Argo CD image updater to a regular expression
+func globToRegexp(pattern string) string {
+	builder := strings.Builder{}
+	builder.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+// getImageTag returns the tag of an image, such as: nginx:1.0 or registry:5000/nginx:1.0
+func getImageTag(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
+
+func getLastAppliedTag(status *v1alpha1.ImageUpdaterStatus, alias string) string {
+	for _, image := range status.Images {
+		if image.Name == alias {
+			return image.LastAppliedTag
+		}
+	}
+	return ""
+}
+
+// setLastPush records the last push of the ImageUpdateAutomation.
+// Once there is a new push, the images which it wrote are found in its event, and their tags are the applied ones.
+// The applied tags are kept if the event cannot be found, for example, it has expired.
+func (r *ImageUpdaterReconciler) setLastPush(ctx context.Context, status *v1alpha1.ImageUpdaterStatus,
+	automation *unstructured.Unstructured) (err error) {
+	lastPushTime, _, _ := unstructured.NestedString(automation.Object, "status", "lastPushTime")
+	pushTime, parseErr := time.Parse(time.RFC3339, lastPushTime)
+	if parseErr != nil || (status.LastPushTime != nil && !pushTime.After(status.LastPushTime.Time)) {
+		return
+	}
+
+	lastPushCommit, _, _ := unstructured.NestedString(automation.Object, "status", "lastPushCommit")
+	if lastPushCommit != "" {
+		events := &corev1.EventList{}
+		if err = r.reader.List(ctx, events, client.InNamespace(automation.GetNamespace())); err != nil {
+			return
+		}
+		for i := range events.Items {
+			event := &events.Items[i]
+			if event.InvolvedObject.Kind == automation.GetKind() && event.InvolvedObject.Name == automation.GetName() &&
+				strings.Contains(event.Message, lastPushCommit) {
+				setLastAppliedTags(status, getUpdatedImages(event.Message))
+				break
+			}
+		}
+	}
+	status.LastPushCommit = lastPushCommit
+	status.LastPushTime = &metav1.Time{Time: pushTime}
+	return
+}
+
+// getUpdatedImages returns the images which are listed in the commit message, such as: registry:5000/nginx:1.0
+func getUpdatedImages(message string) (images []string) {
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, updatedImageTrailer) {
+			images = append(images, strings.TrimPrefix(line, updatedImageTrailer))
+		}
+	}
+	return
+}
+
+func setLastAppliedTags(status *v1alpha1.ImageUpdaterStatus, updatedImages []string) {
+	for _, updated := range updatedImages {
+		tag := getImageTag(updated)
+		name := strings.TrimSuffix(updated, ":"+tag)
+		for i := range status.Images {
+			if tag != "" && status.Images[i].Image == name {
+				status.Images[i].LastAppliedTag = tag
+			}
+		}
+	}
+}
+
+func getImageUpdateInterval(flux *v1alpha1.FluxImageUpdater) time.Duration {
+	if flux.Interval != nil && flux.Interval.Duration > 0 {
+		return flux.Interval.Duration
+	}
+	return defaultImageUpdateInterval
+}
+
+func createBareFluxImageObject(kind string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "image.toolkit.fluxcd.io",
+		Version: "v1beta1",
+		Kind:    kind,
+	})
+	return obj
+}
+
+// GetName returns the name of this controller
+func (r *ImageUpdaterReconciler) GetName() string {
+	return "FluxImageUpdaterController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *ImageUpdaterReconciler) GetGroupName() string {
+	return controllerGroupName
+}
+
+// SetupWithManager setups the log and recorder
+func (r *ImageUpdaterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	r.reader = mgr.GetAPIReader()
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ImageUpdater{}).
+		Complete(r)
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_parseImages(t *testing.T) {
+	assert.Equal(t, []image{
+		{alias: "nginx", name: "nginx", constraint: "^1.20"},
+		{alias: "web", name: "registry:5000/team/web"},
+		{alias: "api", name: "registry:5000/team/api", constraint: "~1.0"},
+	}, parseImages([]string{"nginx:^1.20", " ", "web=registry:5000/team/web", "api=registry:5000/team/api:~1.0"}))
+}
+
+func Test_getImagePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   string
+		constraint string
+		want       map[string]interface{}
+		wantErr    bool
+	}{{
+		name: "semver by default",
+		want: map[string]interface{}{"semver": map[string]interface{}{"range": ">=0.0.0"}},
+	}, {
+		name:       "semver with constraint",
+		strategy:   "semver",
+		constraint: "^1.20",
+		want:       map[string]interface{}{"semver": map[string]interface{}{"range": "^1.20"}},
+	}, {
+		name:     "name",
+		strategy: "name",
+		want:     map[string]interface{}{"alphabetical": map[string]interface{}{"order": "asc"}},
+	}, {
+		name:     "latest is not supported",
+		strategy: "latest",
+		wantErr:  true,
+	}, {
+		name:     "digest is not supported",
+		strategy: "digest",
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := getImagePolicy(tt.strategy, tt.constraint)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, policy)
+		})
+	}
+}
+
+func Test_globToRegexp(t *testing.T) {
+	assert.Equal(t, `^.*-dev$`, globToRegexp("*-dev"))
+	assert.Equal(t, `^v1\.0\..$`, globToRegexp("v1.0.?"))
+}
+
+func Test_getImageTag(t *testing.T) {
+	assert.Equal(t, "1.0", getImageTag("nginx:1.0"))
+	assert.Equal(t, "1.0", getImageTag("registry:5000/nginx:1.0"))
+	assert.Equal(t, "", getImageTag("registry:5000/nginx"))
+	assert.Equal(t, "", getImageTag(""))
+}
+
+func Test_getUpdatedImages(t *testing.T) {
+	assert.Equal(t, []string{"nginx:1.21.0", "registry:5000/team/web:main-abc"}, getUpdatedImages(
+		"Committed and pushed change abc123 to main\nUpdate from image update automation\n\n"+
+			"Updated-Image: nginx:1.21.0\nUpdated-Image: registry:5000/team/web:main-abc\n"))
+	assert.Nil(t, getUpdatedImages("Committed and pushed change abc123 to main\nUpdate nginx to 1.21.0"))
+}
+
+func TestImageUpdaterReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, v1.AddToScheme(schema))
+
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "fake"},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+				Source: &v1alpha1.FluxApplicationSource{SourceRef: helmv2.CrossNamespaceObjectReference{
+					Kind: "GitRepository",
+					Name: "fluxcd-repo",
+				}},
+			}},
+		},
+	}
+	helmApp := app.DeepCopy()
+	helmApp.Name = "helm-app"
+	helmApp.Spec.FluxApp.Spec.Source.SourceRef.Kind = "HelmRepository"
+
+	updater := &v1alpha1.ImageUpdater{
+		ObjectMeta: metav1.ObjectMeta{Name: "updater", Namespace: "fake", UID: "uid"},
+		Spec: v1alpha1.ImageUpdaterSpec{
+			Kind:   "fluxcd",
+			Images: []string{"nginx:^1.20", "web=registry:5000/team/web", "api=team/api"},
+			Flux: &v1alpha1.FluxImageUpdater{
+				App:            v1.LocalObjectReference{Name: "app"},
+				Interval:       &metav1.Duration{Duration: 5 * time.Minute},
+				UpdateStrategy: map[string]string{"web": "name", "api": "latest"},
+				AllowTags:      map[string]string{"web": "^main-[a-f0-9]+$"},
+				IgnoreTags:     map[string]string{"nginx": "*-alpine, *-dev"},
+				Secrets:        map[string]string{"web": "registry-secret"},
+				Git:            v1alpha1.FluxImageUpdateGit{Branch: "main", Path: "./deploy"},
+			},
+		},
+	}
+	argoUpdater := updater.DeepCopy()
+	argoUpdater.Spec.Kind = "argocd"
+	helmAppUpdater := updater.DeepCopy()
+	helmAppUpdater.Spec.Flux.App.Name = "helm-app"
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "fake", Name: "updater"}}
+	getObject := func(c client.Client, kind, name string) *unstructured.Unstructured {
+		obj := createBareFluxImageObject(kind)
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: name}, obj); err != nil {
+			return nil
+		}
+		return obj
+	}
+
+	t.Run("kind is not fluxcd", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema, app.DeepCopy(), argoUpdater)
+		r := &ImageUpdaterReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: &record.FakeRecorder{},
+			reader: c}
+		result, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Equal(t, ctrl.Result{}, result)
+		assert.Nil(t, getObject(c, "ImageUpdateAutomation", "updater"))
+	})
+
+	t.Run("the source of the application is not a GitRepository", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema, helmApp, helmAppUpdater)
+		recorder := record.NewFakeRecorder(10)
+		r := &ImageUpdaterReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: recorder, reader: c}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Contains(t, <-recorder.Events, "InvalidApplication")
+		assert.Nil(t, getObject(c, "ImageUpdateAutomation", "updater"))
+	})
+
+	t.Run("generate the FluxCD image automation", func(t *testing.T) {
+		stale := createBareFluxImageObject("ImagePolicy")
+		stale.SetNamespace("fake")
+		stale.SetName("updater-redis")
+		stale.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "updater"})
+		stale.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: v1alpha1.GroupVersion.String(), Kind: "ImageUpdater", Name: "updater", UID: "uid",
+			Controller: &[]bool{true}[0],
+		}})
+
+		c := fake.NewFakeClientWithScheme(schema, app.DeepCopy(), updater.DeepCopy(), stale)
+		recorder := record.NewFakeRecorder(10)
+		r := &ImageUpdaterReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: recorder, reader: c}
+		result, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Equal(t, ctrl.Result{RequeueAfter: 5 * time.Minute}, result)
+		assert.Contains(t, <-recorder.Events, `skip image api: update strategy "latest" is not supported`)
+
+		repo := getObject(c, "ImageRepository", "updater-nginx")
+		if assert.NotNil(t, repo) {
+			assert.Equal(t, map[string]interface{}{
+				"image":         "nginx",
+				"interval":      "5m0s",
+				"exclusionList": []interface{}{`^.*\.sig$`, `^.*-alpine$`, `^.*-dev$`},
+			}, repo.Object["spec"])
+			assert.True(t, metav1.IsControlledBy(repo, updater))
+		}
+		repo = getObject(c, "ImageRepository", "updater-web")
+		if assert.NotNil(t, repo) {
+			assert.Equal(t, map[string]interface{}{
+				"image":     "registry:5000/team/web",
+				"interval":  "5m0s",
+				"secretRef": map[string]interface{}{"name": "registry-secret"},
+			}, repo.Object["spec"])
+		}
+		assert.Nil(t, getObject(c, "ImageRepository", "updater-api"))
+
+		policy := getObject(c, "ImagePolicy", "updater-web")
+		if assert.NotNil(t, policy) {
+			assert.Equal(t, map[string]interface{}{
+				"imageRepositoryRef": map[string]interface{}{"name": "updater-web"},
+				"policy":             map[string]interface{}{"alphabetical": map[string]interface{}{"order": "asc"}},
+				"filterTags":         map[string]interface{}{"pattern": "^main-[a-f0-9]+$"},
+			}, policy.Object["spec"])
+		}
+		assert.Nil(t, getObject(c, "ImagePolicy", "updater-redis"))
+
+		automation := getObject(c, "ImageUpdateAutomation", "updater")
+		if assert.NotNil(t, automation) {
+			assert.Equal(t, map[string]interface{}{
+				"interval":  "5m0s",
+				"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "fluxcd-repo"},
+				"git": map[string]interface{}{
+					"checkout": map[string]interface{}{"ref": map[string]interface{}{"branch": "main"}},
+					"commit": map[string]interface{}{
+						"author": map[string]interface{}{
+							"name":  "fluxcdbot",
+							"email": "fluxcdbot@users.noreply.github.com",
+						},
+						"messageTemplate": "Update from image update automation\n\n" +
+							"{{range .Updated.Images}}Updated-Image: {{.}}\n{{end}}",
+					},
+					"push": map[string]interface{}{"branch": "main"},
+				},
+				"update": map[string]interface{}{"path": "./deploy", "strategy": "Setters"},
+			}, automation.Object["spec"])
+		}
+
+		latest := &v1alpha1.ImageUpdater{}
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		assert.Equal(t, []v1alpha1.ImageStatus{
+			{Name: "nginx", Image: "nginx", Policy: "fake:updater-nginx"},
+			{Name: "web", Image: "registry:5000/team/web", Policy: "fake:updater-web"},
+		}, latest.Status.Images)
+		assert.Nil(t, latest.Status.LastPushTime)
+
+		// the image policies chose new tags, but they have not been pushed yet
+		for name, latestImage := range map[string]string{
+			"updater-nginx": "nginx:1.21.0",
+			"updater-web":   "registry:5000/team/web:main-abc",
+		} {
+			policy = getObject(c, "ImagePolicy", name)
+			assert.Nil(t, unstructured.SetNestedField(policy.Object, latestImage, "status", "latestImage"))
+			assert.Nil(t, c.Update(context.TODO(), policy))
+		}
+		_, err = r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		assert.Equal(t, "1.21.0", latest.Status.Images[0].LatestTag)
+		assert.Equal(t, "", latest.Status.Images[0].LastAppliedTag)
+
+		// the automation pushed the new tag of nginx only, the manifests have no marker of web
+		assert.Nil(t, c.Create(context.TODO(), &v1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "updater.push", Namespace: "fake"},
+			InvolvedObject: v1.ObjectReference{Kind: "ImageUpdateAutomation", Name: "updater", Namespace: "fake"},
+			Message: "Committed and pushed change abc123 to main\nUpdate from image update automation\n\n" +
+				"Updated-Image: nginx:1.21.0\n",
+		}))
+		automation = getObject(c, "ImageUpdateAutomation", "updater")
+		assert.Nil(t, unstructured.SetNestedField(automation.Object, "abc123", "status", "lastPushCommit"))
+		assert.Nil(t, unstructured.SetNestedField(automation.Object, "2022-10-01T10:00:00Z", "status", "lastPushTime"))
+		assert.Nil(t, c.Update(context.TODO(), automation))
+		_, err = r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		assert.Equal(t, "1.21.0", latest.Status.Images[0].LastAppliedTag)
+		assert.Equal(t, "main-abc", latest.Status.Images[1].LatestTag)
+		assert.Equal(t, "", latest.Status.Images[1].LastAppliedTag)
+		assert.Equal(t, "abc123", latest.Status.LastPushCommit)
+		if assert.NotNil(t, latest.Status.LastPushTime) {
+			assert.True(t, latest.Status.LastPushTime.Equal(&metav1.Time{
+				Time: time.Date(2022, 10, 1, 10, 0, 0, 0, time.UTC)}))
+		}
+
+		// a newer tag is kept as the latest one until the next push
+		policy = getObject(c, "ImagePolicy", "updater-nginx")
+		assert.Nil(t, unstructured.SetNestedField(policy.Object, "nginx:1.22.0", "status", "latestImage"))
+		assert.Nil(t, c.Update(context.TODO(), policy))
+		_, err = r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		assert.Equal(t, "1.22.0", latest.Status.Images[0].LatestTag)
+		assert.Equal(t, "1.21.0", latest.Status.Images[0].LastAppliedTag)
+
+		// the applied tags are kept if the event of the push cannot be found
+		automation = getObject(c, "ImageUpdateAutomation", "updater")
+		assert.Nil(t, unstructured.SetNestedField(automation.Object, "def456", "status", "lastPushCommit"))
+		assert.Nil(t, unstructured.SetNestedField(automation.Object, "2022-10-01T11:00:00Z", "status", "lastPushTime"))
+		assert.Nil(t, c.Update(context.TODO(), automation))
+		_, err = r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		assert.Equal(t, "1.21.0", latest.Status.Images[0].LastAppliedTag)
+		assert.Equal(t, "def456", latest.Status.LastPushCommit)
+	})
+
+	t.Run("keep the commit message template", func(t *testing.T) {
+		withTemplate := updater.DeepCopy()
+		withTemplate.Spec.Flux.Git.MessageTemplate = "chore: update images"
+		c := fake.NewFakeClientWithScheme(schema, app.DeepCopy(), withTemplate)
+		r := &ImageUpdaterReconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: record.NewFakeRecorder(10),
+			reader: c}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+
+		automation := getObject(c, "ImageUpdateAutomation", "updater")
+		if assert.NotNil(t, automation) {
+			messageTemplate, _, _ := unstructured.NestedString(automation.Object, "spec", "git", "commit", "messageTemplate")
+			assert.Equal(t, "chore: update images\n\n{{range .Updated.Images}}Updated-Image: {{.}}\n{{end}}",
+				messageTemplate)
+		}
+	})
+}
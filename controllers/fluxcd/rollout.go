@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/controllers/rollout"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	apimeta "kubesphere.io/devops/pkg/external/fluxcd/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getRolloutHealth returns the health of the HelmReleases or Kustomizations which the new revision was promoted to
+func (r *ApplicationReconciler) getRolloutHealth(ctx context.Context, app *v1alpha1.Application, plan *rollout.Plan) (
+	health rollout.Health, err error) {
+	appNS, appName := app.GetNamespace(), app.GetName()
+	matchingLabels := client.MatchingLabels{"app.kubernetes.io/managed-by": appName}
+
+	var destinations []string
+	healthMap := map[string]rollout.Health{}
+	at, _ := isHelmOrKustomize(app)
+	switch at {
+	case HelmRelease:
+		for _, deploy := range app.Spec.FluxApp.Spec.Config.HelmRelease.Deploy {
+			destinations = append(destinations, getHelmReleaseName(deploy))
+		}
+		hrList := &helmv2.HelmReleaseList{}
+		if err = r.List(ctx, hrList, client.InNamespace(appNS), matchingLabels); err != nil {
+			return
+		}
+		for _, hr := range hrList.Items {
+			healthMap[hr.GetAnnotations()["app.kubernetes.io/name"]] = getFluxHealth(hr.Generation,
+				hr.Status.ObservedGeneration, hr.Status.Conditions)
+		}
+	case Kustomization:
+		for _, deploy := range app.Spec.FluxApp.Spec.Config.Kustomization {
+			destinations = append(destinations, getKustomizationName(deploy))
+		}
+		kusList := &kusv1.KustomizationList{}
+		if err = r.List(ctx, kusList, client.InNamespace(appNS), matchingLabels); err != nil {
+			return
+		}
+		for _, kus := range kusList.Items {
+			healthMap[kus.GetAnnotations()["app.kubernetes.io/name"]] = getFluxHealth(kus.Generation,
+				kus.Status.ObservedGeneration, kus.Status.Conditions)
+		}
+	}
+
+	healths := make([]rollout.Health, 0, len(destinations))
+	for _, destination := range destinations {
+		if !plan.IsPromoted(destination) {
+			continue
+		}
+		if destinationHealth, ok := healthMap[destination]; ok {
+			healths = append(healths, destinationHealth)
+		} else {
+			healths = append(healths, rollout.Progressing)
+		}
+	}
+	health = rollout.Aggregate(healths...)
+	return
+}
+
+// getFluxHealth returns the health of a FluxCD object according to its Ready condition
+func getFluxHealth(generation, observedGeneration int64, conditions []metav1.Condition) rollout.Health {
+	if observedGeneration < generation {
+		return rollout.Progressing
+	}
+	ready := meta.FindStatusCondition(conditions, apimeta.ReadyCondition)
+	switch {
+	case ready == nil:
+		return rollout.Progressing
+	case ready.Status == metav1.ConditionTrue:
+		return rollout.Healthy
+	case ready.Status == metav1.ConditionFalse && ready.Reason != apimeta.ProgressingReason:
+		return rollout.Degraded
+	}
+	return rollout.Progressing
+}
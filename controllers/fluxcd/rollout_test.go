@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/controllers/rollout"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
+	"kubesphere.io/devops/pkg/external/fluxcd/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetFluxHealth(t *testing.T) {
+	tests := []struct {
+		name               string
+		generation         int64
+		observedGeneration int64
+		conditions         []metav1.Condition
+		want               rollout.Health
+	}{{
+		name:               "not observed",
+		generation:         2,
+		observedGeneration: 1,
+		conditions:         []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionTrue}},
+		want:               rollout.Progressing,
+	}, {
+		name:               "no ready condition",
+		generation:         1,
+		observedGeneration: 1,
+		want:               rollout.Progressing,
+	}, {
+		name:               "ready",
+		generation:         1,
+		observedGeneration: 1,
+		conditions:         []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionTrue}},
+		want:               rollout.Healthy,
+	}, {
+		name:               "progressing",
+		generation:         1,
+		observedGeneration: 1,
+		conditions: []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionFalse,
+			Reason: meta.ProgressingReason}},
+		want: rollout.Progressing,
+	}, {
+		name:               "failed",
+		generation:         1,
+		observedGeneration: 1,
+		conditions: []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionFalse,
+			Reason: "InstallFailed"}},
+		want: rollout.Degraded,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getFluxHealth(tt.generation, tt.observedGeneration, tt.conditions))
+		})
+	}
+}
+
+func TestApplicationReconciler_getRolloutHealth(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = helmv2.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fake-ns", Name: "fake-app"},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{
+				Spec: v1alpha1.FluxApplicationSpec{
+					Config: &v1alpha1.FluxApplicationConfig{
+						HelmRelease: &v1alpha1.HelmReleaseSpec{
+							Deploy: []*v1alpha1.Deploy{
+								{Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "test"}},
+								{Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "production"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	newHelmRelease := func(name, destination string, ready metav1.ConditionStatus) *helmv2.HelmRelease {
+		return &helmv2.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "fake-ns",
+				Name:        name,
+				Labels:      map[string]string{"app.kubernetes.io/managed-by": "fake-app"},
+				Annotations: map[string]string{"app.kubernetes.io/name": destination},
+			},
+			Status: helmv2.HelmReleaseStatus{
+				Conditions: []metav1.Condition{{Type: meta.ReadyCondition, Status: ready, Reason: "Reason"}},
+			},
+		}
+	}
+	stable, err := rollout.GetPlan(app)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		objects []*helmv2.HelmRelease
+		want    rollout.Health
+	}{{
+		name: "the HelmReleases were not created",
+		want: rollout.Progressing,
+	}, {
+		name: "all HelmReleases are ready",
+		objects: []*helmv2.HelmRelease{newHelmRelease("hr1", "test", metav1.ConditionTrue),
+			newHelmRelease("hr2", "production", metav1.ConditionTrue)},
+		want: rollout.Healthy,
+	}, {
+		name: "one of the HelmReleases failed",
+		objects: []*helmv2.HelmRelease{newHelmRelease("hr1", "test", metav1.ConditionTrue),
+			newHelmRelease("hr2", "production", metav1.ConditionFalse)},
+		want: rollout.Degraded,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema)
+			for _, obj := range tt.objects {
+				c.WithObjects(obj)
+			}
+			r := &ApplicationReconciler{Client: c.Build()}
+			health, err := r.getRolloutHealth(context.TODO(), app, stable)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, health)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/jenkins-x/go-scm/scm"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applicationgenerators,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applicationgenerators/status,verbs=get;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=gitrepositories,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.kubesphere.io,resources=clusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconciler generates the Applications of the ApplicationGenerators, and prunes the ones which are not generated anymore
+type Reconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+	// newGitClient creates the git client of a GitRepository
+	newGitClient func(repo *v1alpha3.GitRepository) (*scm.Client, error)
+}
+
+// Reconcile makes sure the generated Applications are consistent with the generators and template
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.Info(fmt.Sprintf("start to reconcile ApplicationGenerator: %s", req.String()))
+
+	generator := &v1alpha1.ApplicationGenerator{}
+	if err = r.Get(ctx, req.NamespacedName, generator); err != nil || !generator.DeletionTimestamp.IsZero() {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	status := generator.Status.DeepCopy()
+	var apps []*v1alpha1.Application
+	if apps, err = r.generate(ctx, generator); err != nil {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               v1alpha1.ApplicationGeneratorConditionReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generator.Generation,
+			Reason:             v1alpha1.ApplicationGeneratorReasonFailed,
+			Message:            err.Error(),
+		})
+		r.recorder.Event(generator, corev1.EventTypeWarning, v1alpha1.ApplicationGeneratorReasonFailed, err.Error())
+	} else {
+		var failures []string
+		status.Applications = make([]string, 0, len(apps))
+		for _, app := range apps {
+			if applyErr := r.apply(ctx, generator, app); applyErr != nil {
+				failures = append(failures, applyErr.Error())
+				continue
+			}
+			status.Applications = append(status.Applications, app.Name)
+		}
+		// the generate succeeded, which means every generator source returned without error
+		var pruneSkipped bool
+		if generator.IsPruneEnabled() {
+			if pruneSkipped, err = r.prune(ctx, generator, apps); err != nil {
+				return
+			}
+		}
+
+		condition := metav1.Condition{
+			Type:               v1alpha1.ApplicationGeneratorConditionReady,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generator.Generation,
+			Reason:             v1alpha1.ApplicationGeneratorReasonGenerated,
+			Message:            fmt.Sprintf("%d Application(s) were generated", len(apps)),
+		}
+		if len(failures) > 0 {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = v1alpha1.ApplicationGeneratorReasonFailed
+			condition.Message = strings.Join(failures, "; ")
+		} else if pruneSkipped {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = v1alpha1.ApplicationGeneratorReasonPruneSkipped
+			condition.Message = "no Application was generated, set spec.allowEmpty to prune all the generated Applications"
+			r.recorder.Event(generator, corev1.EventTypeWarning, condition.Reason, condition.Message)
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+
+	if !reflect.DeepEqual(status, &generator.Status) {
+		generator.Status = *status
+		if err = r.Status().Update(ctx, generator); err != nil {
+			return
+		}
+	}
+	result = ctrl.Result{RequeueAfter: generator.GetInterval()}
+	return
+}
+
+// generate renders the Applications with all the sets of parameters
+func (r *Reconciler) generate(ctx context.Context, generator *v1alpha1.ApplicationGenerator) (
+	apps []*v1alpha1.Application, err error) {
+	var params []map[string]string
+	if params, err = r.generateParameters(ctx, generator); err != nil {
+		err = fmt.Errorf("failed to generate the parameters: %v", err)
+		return
+	}
+
+	names := make(map[string]bool, len(params))
+	for _, param := range params {
+		var app *v1alpha1.Application
+		if app, err = renderApplication(generator, param); err != nil {
+			return
+		}
+		if names[app.Name] {
+			err = fmt.Errorf("duplicated Application name %q, the name template should reference the parameters",
+				app.Name)
+			return
+		}
+		names[app.Name] = true
+		apps = append(apps, app)
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Name < apps[j].Name
+	})
+	return
+}
+
+// apply creates or updates a generated Application
+func (r *Reconciler) apply(ctx context.Context, generator *v1alpha1.ApplicationGenerator, app *v1alpha1.Application) (err error) {
+	existing := &v1alpha1.Application{}
+	if err = r.Get(ctx, client.ObjectKeyFromObject(app), existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		if err = controllerutil.SetControllerReference(generator, app, r.Scheme()); err != nil {
+			return
+		}
+		if err = r.Create(ctx, app); err == nil {
+			r.recorder.Eventf(generator, corev1.EventTypeNormal, "Created", "Created Application %s", app.Name)
+		}
+		return
+	}
+
+	if !metav1.IsControlledBy(existing, generator) {
+		return fmt.Errorf("application %s already exists and is not generated by %s", app.Name, generator.Name)
+	}
+	updated := existing.DeepCopy()
+	updated.Spec = app.Spec
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for key, value := range app.Labels {
+		updated.Labels[key] = value
+	}
+	if len(app.Annotations) > 0 && updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for key, value := range app.Annotations {
+		updated.Annotations[key] = value
+	}
+	if reflect.DeepEqual(updated, existing) {
+		return
+	}
+	if err = r.Update(ctx, updated); err == nil {
+		r.recorder.Eventf(generator, corev1.EventTypeNormal, "Updated", "Updated Application %s", app.Name)
+	}
+	return
+}
+
+// prune deletes the generated Applications which are not generated anymore. It refuses to delete all of them when nothing
+// was generated unless the generator allows empty, skipped is true in this case.
+func (r *Reconciler) prune(ctx context.Context, generator *v1alpha1.ApplicationGenerator, apps []*v1alpha1.Application) (
+	skipped bool, err error) {
+	names := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		names[app.Name] = true
+	}
+
+	existing := &v1alpha1.ApplicationList{}
+	if err = r.List(ctx, existing, client.InNamespace(generator.Namespace),
+		client.MatchingLabels{v1alpha1.ApplicationGeneratorLabelKey: generator.Name}); err != nil {
+		return
+	}
+	var stale []*v1alpha1.Application
+	for i := range existing.Items {
+		app := &existing.Items[i]
+		if names[app.Name] || !metav1.IsControlledBy(app, generator) || !app.DeletionTimestamp.IsZero() {
+			continue
+		}
+		stale = append(stale, app)
+	}
+	if len(apps) == 0 && len(stale) > 0 && !generator.Spec.AllowEmpty {
+		skipped = true
+		return
+	}
+	for _, app := range stale {
+		if err = client.IgnoreNotFound(r.Delete(ctx, app)); err != nil {
+			return
+		}
+		r.recorder.Eventf(generator, corev1.EventTypeNormal, "Pruned", "Pruned Application %s", app.Name)
+	}
+	return
+}
+
+// GetName returns the name of this controller
+func (r *Reconciler) GetName() string {
+	return "ApplicationGeneratorController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *Reconciler) GetGroupName() string {
+	return "application-generator"
+}
+
+// SetupWithManager setups the log and recorder
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	r.newGitClient = newGitClient(mgr.GetClient())
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ApplicationGenerator{}).
+		Owns(&v1alpha1.Application{}).
+		Complete(r)
+}
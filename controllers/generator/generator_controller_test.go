@@ -0,0 +1,252 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
+	fluxmeta "kubesphere.io/devops/pkg/external/fluxcd/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	generator := &v1alpha1.ApplicationGenerator{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo", Namespace: "fake", UID: "uid", Generation: 1},
+		Spec: v1alpha1.ApplicationGeneratorSpec{
+			Generators: []v1alpha1.ApplicationGeneratorSource{{
+				List: &v1alpha1.ListGenerator{Elements: []map[string]string{
+					{"cluster": "member-a"},
+					{"cluster": "member-b"},
+				}},
+			}},
+			Template: v1alpha1.ApplicationTemplate{
+				Metadata: v1alpha1.ApplicationTemplateMeta{Name: "podinfo-{{cluster}}"},
+				Spec: v1alpha1.ApplicationSpec{
+					Kind: v1alpha1.FluxCD,
+					FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+						Config: &v1alpha1.FluxApplicationConfig{Kustomization: []*v1alpha1.KustomizationSpec{{
+							Destination: v1alpha1.FluxApplicationDestination{
+								KubeConfig: &helmv2.KubeConfig{SecretRef: fluxmeta.SecretKeyReference{Name: "{{cluster}}"}},
+							},
+							Path: "./deploy",
+						}}},
+					}},
+				},
+			},
+		},
+	}
+	isController := true
+	ownerRef := metav1.OwnerReference{
+		APIVersion: v1alpha1.GroupVersion.String(), Kind: "ApplicationGenerator", Name: "podinfo", UID: "uid",
+		Controller: &isController, BlockOwnerDeletion: &isController,
+	}
+	stale := &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{
+		Name: "podinfo-member-c", Namespace: "fake", OwnerReferences: []metav1.OwnerReference{ownerRef},
+		Labels: map[string]string{v1alpha1.ApplicationGeneratorLabelKey: "podinfo"},
+	}}
+	outdated := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "podinfo-member-b", Namespace: "fake", OwnerReferences: []metav1.OwnerReference{ownerRef},
+			Labels: map[string]string{v1alpha1.ApplicationGeneratorLabelKey: "podinfo", "custom": "label"},
+		},
+		Spec: v1alpha1.ApplicationSpec{Kind: v1alpha1.ArgoCD},
+	}
+	notOwned := &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Name: "podinfo-member-a", Namespace: "fake"}}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "fake", Name: "podinfo"}}
+
+	t.Run("not found", func(t *testing.T) {
+		r := &Reconciler{Client: fake.NewFakeClientWithScheme(schema), log: logr.New(log.NullLogSink{})}
+		result, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Equal(t, ctrl.Result{}, result)
+	})
+
+	t.Run("create, update and prune the Applications", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema, generator.DeepCopy(), stale.DeepCopy(), outdated.DeepCopy())
+		recorder := record.NewFakeRecorder(10)
+		r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: recorder}
+		result, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Equal(t, ctrl.Result{RequeueAfter: 3 * time.Minute}, result)
+
+		app := &v1alpha1.Application{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-a"}, app))
+		assert.True(t, metav1.IsControlledBy(app, generator))
+		assert.Equal(t, "podinfo", app.Labels[v1alpha1.ApplicationGeneratorLabelKey])
+		assert.Equal(t, "member-a",
+			app.Spec.FluxApp.Spec.Config.Kustomization[0].Destination.KubeConfig.SecretRef.Name)
+
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-b"}, app))
+		assert.Equal(t, v1alpha1.FluxCD, app.Spec.Kind)
+		assert.Equal(t, "label", app.Labels["custom"])
+		assert.Equal(t, "member-b",
+			app.Spec.FluxApp.Spec.Config.Kustomization[0].Destination.KubeConfig.SecretRef.Name)
+
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-c"}, app)
+		assert.True(t, client.IgnoreNotFound(err) == nil && err != nil)
+
+		latest := &v1alpha1.ApplicationGenerator{}
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		assert.Equal(t, []string{"podinfo-member-a", "podinfo-member-b"}, latest.Status.Applications)
+		condition := meta.FindStatusCondition(latest.Status.Conditions, v1alpha1.ApplicationGeneratorConditionReady)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, metav1.ConditionTrue, condition.Status)
+			assert.Equal(t, "2 Application(s) were generated", condition.Message)
+			assert.Equal(t, int64(1), condition.ObservedGeneration)
+		}
+		assert.Contains(t, <-recorder.Events, "Created Application podinfo-member-a")
+		assert.Contains(t, <-recorder.Events, "Updated Application podinfo-member-b")
+		assert.Contains(t, <-recorder.Events, "Pruned Application podinfo-member-c")
+
+		// nothing changed in the second round
+		_, err = r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Len(t, recorder.Events, 0)
+	})
+
+	t.Run("an Application with the same name exists", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(schema, generator.DeepCopy(), notOwned.DeepCopy())
+		r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: record.NewFakeRecorder(10)}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+
+		app := &v1alpha1.Application{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-a"}, app))
+		assert.Nil(t, app.Spec.FluxApp)
+
+		latest := &v1alpha1.ApplicationGenerator{}
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		assert.Equal(t, []string{"podinfo-member-b"}, latest.Status.Applications)
+		condition := meta.FindStatusCondition(latest.Status.Conditions, v1alpha1.ApplicationGeneratorConditionReady)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, metav1.ConditionFalse, condition.Status)
+			assert.Equal(t, "application podinfo-member-a already exists and is not generated by podinfo",
+				condition.Message)
+		}
+	})
+
+	t.Run("duplicated names", func(t *testing.T) {
+		duplicated := generator.DeepCopy()
+		duplicated.Spec.Template.Metadata.Name = "podinfo"
+		c := fake.NewFakeClientWithScheme(schema, duplicated, stale.DeepCopy())
+		recorder := record.NewFakeRecorder(10)
+		r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: recorder}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Contains(t, <-recorder.Events, `duplicated Application name "podinfo"`)
+
+		// the Applications are not pruned if the generating failed
+		app := &v1alpha1.Application{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-c"}, app))
+
+		latest := &v1alpha1.ApplicationGenerator{}
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		condition := meta.FindStatusCondition(latest.Status.Conditions, v1alpha1.ApplicationGeneratorConditionReady)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, v1alpha1.ApplicationGeneratorReasonFailed, condition.Reason)
+		}
+	})
+	t.Run("one of the generators failed", func(t *testing.T) {
+		partial := generator.DeepCopy()
+		partial.Spec.Generators = append(partial.Spec.Generators, v1alpha1.ApplicationGeneratorSource{
+			GitDirectory: &v1alpha1.GitDirectoryGenerator{Repo: corev1.LocalObjectReference{Name: "not-found"}},
+		})
+		c := fake.NewFakeClientWithScheme(schema, partial, stale.DeepCopy())
+		r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: record.NewFakeRecorder(10)}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+
+		// the Applications are not pruned unless all the generators succeeded
+		app := &v1alpha1.Application{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-c"}, app))
+	})
+
+	t.Run("no parameters were generated", func(t *testing.T) {
+		empty := generator.DeepCopy()
+		empty.Spec.Generators[0].List.Elements = nil
+		c := fake.NewFakeClientWithScheme(schema, empty, stale.DeepCopy())
+		recorder := record.NewFakeRecorder(10)
+		r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: recorder}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Contains(t, <-recorder.Events, "set spec.allowEmpty")
+
+		app := &v1alpha1.Application{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-c"}, app))
+
+		latest := &v1alpha1.ApplicationGenerator{}
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		condition := meta.FindStatusCondition(latest.Status.Conditions, v1alpha1.ApplicationGeneratorConditionReady)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, metav1.ConditionFalse, condition.Status)
+			assert.Equal(t, v1alpha1.ApplicationGeneratorReasonPruneSkipped, condition.Reason)
+		}
+	})
+
+	t.Run("no parameters were generated and empty is allowed", func(t *testing.T) {
+		empty := generator.DeepCopy()
+		empty.Spec.Generators[0].List.Elements = nil
+		empty.Spec.AllowEmpty = true
+		c := fake.NewFakeClientWithScheme(schema, empty, stale.DeepCopy())
+		recorder := record.NewFakeRecorder(10)
+		r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: recorder}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+		assert.Contains(t, <-recorder.Events, "Pruned Application podinfo-member-c")
+
+		app := &v1alpha1.Application{}
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-c"}, app)
+		assert.True(t, client.IgnoreNotFound(err) == nil && err != nil)
+	})
+
+	t.Run("prune is disabled", func(t *testing.T) {
+		prune := false
+		notPrune := generator.DeepCopy()
+		notPrune.Spec.Prune = &prune
+		c := fake.NewFakeClientWithScheme(schema, notPrune, stale.DeepCopy())
+		r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{}), recorder: record.NewFakeRecorder(10)}
+		_, err := r.Reconcile(context.TODO(), req)
+		assert.Nil(t, err)
+
+		app := &v1alpha1.Application{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "podinfo-member-c"}, app))
+
+		latest := &v1alpha1.ApplicationGenerator{}
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, latest))
+		condition := meta.FindStatusCondition(latest.Status.Conditions, v1alpha1.ApplicationGeneratorConditionReady)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, metav1.ConditionTrue, condition.Status)
+		}
+	})
+}
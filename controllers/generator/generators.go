@@ -0,0 +1,284 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/client/git"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// parameterPattern matches the parameter references in the template, such as {{name}} or {{ path.basename }}
+var parameterPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][\w.\-/]*)\s*\}\}`)
+
+// nonAlphanumeric matches the characters which are not allowed in a normalized name
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// generateParameters returns the sets of parameters of all the generators
+func (r *Reconciler) generateParameters(ctx context.Context, generator *v1alpha1.ApplicationGenerator) (
+	params []map[string]string, err error) {
+	for i, source := range generator.Spec.Generators {
+		var result []map[string]string
+		switch {
+		case source.List != nil:
+			result = source.List.Elements
+		case source.Cluster != nil:
+			result, err = r.generateClusterParameters(ctx, source.Cluster)
+		case source.GitDirectory != nil:
+			result, err = r.generateGitDirectoryParameters(ctx, generator.Namespace, source.GitDirectory)
+		default:
+			err = fmt.Errorf("generator %d has no list, cluster or gitDirectory", i)
+		}
+		if err != nil {
+			return
+		}
+		params = append(params, result...)
+	}
+	return
+}
+
+func (r *Reconciler) generateClusterParameters(ctx context.Context, generator *v1alpha1.ClusterGenerator) (
+	params []map[string]string, err error) {
+	selector, err := metav1.LabelSelectorAsSelector(&generator.Selector)
+	if err != nil {
+		return
+	}
+
+	clusters := &unstructured.UnstructuredList{}
+	clusters.SetAPIVersion("cluster.kubesphere.io/v1alpha1")
+	clusters.SetKind("ClusterList")
+	if err = r.List(ctx, clusters, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return
+	}
+
+	for _, cluster := range clusters.Items {
+		server, _, _ := unstructured.NestedString(cluster.Object, "spec", "connection", "kubernetesAPIEndpoint")
+		param := map[string]string{
+			"name":   cluster.GetName(),
+			"server": server,
+		}
+		for key, value := range cluster.GetLabels() {
+			param["metadata.labels."+key] = value
+		}
+		for key, value := range generator.Values {
+			param["values."+key] = render(value, param)
+		}
+		params = append(params, param)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i]["name"] < params[j]["name"]
+	})
+	return
+}
+
+func (r *Reconciler) generateGitDirectoryParameters(ctx context.Context, namespace string,
+	generator *v1alpha1.GitDirectoryGenerator) (params []map[string]string, err error) {
+	repo := &v1alpha3.GitRepository{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: generator.Repo.Name}, repo); err != nil {
+		return
+	}
+	var gitClient *scm.Client
+	if gitClient, err = r.newGitClient(repo); err != nil {
+		return
+	}
+
+	lister := &directoryLister{
+		client:   gitClient,
+		repo:     getRepoPath(repo),
+		revision: generator.Revision,
+		cache:    map[string][]string{},
+	}
+	included := map[string]bool{}
+	for _, dir := range generator.Directories {
+		var paths []string
+		if paths, err = lister.match(ctx, dir.Path); err != nil {
+			return
+		}
+		for _, p := range paths {
+			if dir.Exclude {
+				included[p] = false
+			} else if _, ok := included[p]; !ok {
+				included[p] = true
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(included))
+	for p, ok := range included {
+		if ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		basename := path.Base(p)
+		params = append(params, map[string]string{
+			"path":                    p,
+			"path.basename":           basename,
+			"path.basenameNormalized": strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(basename), "-"), "-"),
+		})
+	}
+	return
+}
+
+// directoryLister finds the directories of a Git repository via the API of the git provider
+type directoryLister struct {
+	client   *scm.Client
+	repo     string
+	revision string
+	// cache holds the sub-directories of the listed directories
+	cache map[string][]string
+}
+
+// match returns the directories which match the path pattern, such as: apps/*
+func (l *directoryLister) match(ctx context.Context, pattern string) (result []string, err error) {
+	candidates := []string{""}
+	for _, segment := range strings.Split(strings.Trim(path.Clean("/"+pattern), "/"), "/") {
+		var next []string
+		for _, parent := range candidates {
+			var dirs []string
+			if dirs, err = l.list(ctx, parent); err != nil {
+				return
+			}
+			for _, dir := range dirs {
+				var matched bool
+				if matched, err = path.Match(segment, dir); err != nil {
+					return
+				} else if matched {
+					next = append(next, path.Join(parent, dir))
+				}
+			}
+		}
+		candidates = next
+	}
+	result = candidates
+	return
+}
+
+// list returns the names of the sub-directories
+func (l *directoryLister) list(ctx context.Context, dir string) (dirs []string, err error) {
+	if cached, ok := l.cache[dir]; ok {
+		return cached, nil
+	}
+	var entries []*scm.FileEntry
+	if entries, _, err = l.client.Contents.List(ctx, l.repo, dir, l.revision); err != nil {
+		err = fmt.Errorf("failed to list directory %q of repository %s: %v", dir, l.repo, err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Type == "dir" {
+			dirs = append(dirs, entry.Name)
+		}
+	}
+	l.cache[dir] = dirs
+	return
+}
+
+// newGitClient creates the git client of a GitRepository
+func newGitClient(c client.Client) func(repo *v1alpha3.GitRepository) (*scm.Client, error) {
+	return func(repo *v1alpha3.GitRepository) (*scm.Client, error) {
+		secret := repo.Spec.Secret.DeepCopy()
+		if secret != nil && secret.Namespace == "" {
+			secret.Namespace = repo.Namespace
+		}
+		factory := git.NewClientFactory(repo.Spec.Provider, secret, c)
+		factory.Server = repo.Spec.Server
+		return factory.GetClient()
+	}
+}
+
+// getRepoPath returns the path of a repository, such as: kubesphere/ks-devops
+func getRepoPath(repo *v1alpha3.GitRepository) string {
+	if repo.Spec.Owner != "" && repo.Spec.Repo != "" {
+		return fmt.Sprintf("%s/%s", repo.Spec.Owner, repo.Spec.Repo)
+	}
+	address := repo.Spec.URL
+	if i := strings.Index(address, "://"); i >= 0 {
+		// https://github.com/kubesphere/ks-devops.git
+		address = address[i+3:]
+		if j := strings.Index(address, "/"); j >= 0 {
+			address = address[j+1:]
+		}
+	} else if i = strings.Index(address, ":"); i >= 0 {
+		// git@github.com:kubesphere/ks-devops.git
+		address = address[i+1:]
+	}
+	return strings.TrimSuffix(strings.Trim(address, "/"), ".git")
+}
+
+// renderApplication renders the template of the Application with a set of parameters
+func renderApplication(generator *v1alpha1.ApplicationGenerator, params map[string]string) (
+	app *v1alpha1.Application, err error) {
+	var data []byte
+	if data, err = json.Marshal(generator.Spec.Template); err != nil {
+		return
+	}
+	escaped := make(map[string]string, len(params))
+	for key, value := range params {
+		// the values are put into the JSON strings
+		quoted, _ := json.Marshal(value)
+		escaped[key] = string(quoted[1 : len(quoted)-1])
+	}
+
+	template := &v1alpha1.ApplicationTemplate{}
+	if err = json.Unmarshal([]byte(render(string(data), escaped)), template); err != nil {
+		return
+	}
+	if errs := validation.IsDNS1123Subdomain(template.Metadata.Name); len(errs) > 0 {
+		err = fmt.Errorf("invalid Application name %q: %s", template.Metadata.Name, strings.Join(errs, "; "))
+		return
+	}
+
+	app = &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   generator.Namespace,
+			Name:        template.Metadata.Name,
+			Labels:      template.Metadata.Labels,
+			Annotations: template.Metadata.Annotations,
+		},
+		Spec: template.Spec,
+	}
+	if app.Labels == nil {
+		app.Labels = map[string]string{}
+	}
+	app.Labels[v1alpha1.ApplicationGeneratorLabelKey] = generator.Name
+	return
+}
+
+// render replaces the parameter references with the values, the unknown references are kept as they are
+func render(text string, params map[string]string) string {
+	return parameterPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		if value, ok := params[parameterPattern.FindStringSubmatch(ref)[1]]; ok {
+			return value
+		}
+		return ref
+	})
+}
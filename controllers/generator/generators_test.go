@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/jenkins-x/go-scm/scm"
+	fakescm "github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_render(t *testing.T) {
+	params := map[string]string{"name": "prod", "path.basename": "web"}
+	assert.Equal(t, "prod-web", render("{{name}}-{{ path.basename }}", params))
+	assert.Equal(t, "{{unknown}} {{ .Release.Name }}", render("{{unknown}} {{ .Release.Name }}", params))
+}
+
+func Test_getRepoPath(t *testing.T) {
+	tests := []struct {
+		name string
+		spec v1alpha3.GitRepositorySpec
+		want string
+	}{{
+		name: "owner and repo",
+		spec: v1alpha3.GitRepositorySpec{Owner: "kubesphere", Repo: "ks-devops", URL: "https://fake.com/a/b"},
+		want: "kubesphere/ks-devops",
+	}, {
+		name: "https URL",
+		spec: v1alpha3.GitRepositorySpec{URL: "https://github.com/kubesphere/ks-devops.git"},
+		want: "kubesphere/ks-devops",
+	}, {
+		name: "ssh URL",
+		spec: v1alpha3.GitRepositorySpec{URL: "git@gitlab.com:group/sub/repo.git"},
+		want: "group/sub/repo",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getRepoPath(&v1alpha3.GitRepository{Spec: tt.spec}))
+		})
+	}
+}
+
+func Test_renderApplication(t *testing.T) {
+	generator := &v1alpha1.ApplicationGenerator{
+		ObjectMeta: metav1.ObjectMeta{Name: "generator", Namespace: "fake"},
+		Spec: v1alpha1.ApplicationGeneratorSpec{Template: v1alpha1.ApplicationTemplate{
+			Metadata: v1alpha1.ApplicationTemplateMeta{
+				Name:   "guestbook-{{name}}",
+				Labels: map[string]string{"env": "{{values.env}}"},
+			},
+			Spec: v1alpha1.ApplicationSpec{
+				Kind: v1alpha1.ArgoCD,
+				ArgoApp: &v1alpha1.ArgoApplication{Spec: v1alpha1.ArgoApplicationSpec{
+					Source: v1alpha1.ApplicationSource{RepoURL: "https://fake.com/repo", Path: "{{path}}"},
+					Destination: v1alpha1.ApplicationDestination{
+						Name:      "{{name}}",
+						Namespace: "guestbook",
+					},
+				}},
+			},
+		}},
+	}
+
+	app, err := renderApplication(generator, map[string]string{
+		"name":       "member",
+		"path":       `apps/"quoted"`,
+		"values.env": "prod",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "guestbook-member", app.Name)
+	assert.Equal(t, "fake", app.Namespace)
+	assert.Equal(t, map[string]string{"env": "prod", v1alpha1.ApplicationGeneratorLabelKey: "generator"}, app.Labels)
+	assert.Equal(t, "member", app.Spec.ArgoApp.Spec.Destination.Name)
+	assert.Equal(t, `apps/"quoted"`, app.Spec.ArgoApp.Spec.Source.Path)
+	// the template is not changed
+	assert.Equal(t, "{{name}}", generator.Spec.Template.Spec.ArgoApp.Spec.Destination.Name)
+
+	_, err = renderApplication(generator, map[string]string{"name": "Invalid_Name"})
+	assert.NotNil(t, err)
+}
+
+func TestReconciler_generateParameters(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, v1alpha3.AddToScheme(schema))
+
+	newCluster := func(name string, labels map[string]string) *unstructured.Unstructured {
+		cluster := &unstructured.Unstructured{}
+		cluster.SetAPIVersion("cluster.kubesphere.io/v1alpha1")
+		cluster.SetKind("Cluster")
+		cluster.SetName(name)
+		cluster.SetLabels(labels)
+		_ = unstructured.SetNestedField(cluster.Object, "https://"+name+":6443",
+			"spec", "connection", "kubernetesAPIEndpoint")
+		return cluster
+	}
+	repo := &v1alpha3.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "fake"},
+		Spec:       v1alpha3.GitRepositorySpec{Provider: "github", URL: "https://github.com/org/apps"},
+	}
+
+	contentDir := t.TempDir()
+	for _, dir := range []string{"apps/web", "apps/Api_Server", "apps/legacy", "infra/ingress", "README.md"} {
+		if filepath.Ext(dir) != "" {
+			assert.Nil(t, os.WriteFile(filepath.Join(contentDir, "org/apps", dir), []byte("readme"), 0600))
+		} else {
+			assert.Nil(t, os.MkdirAll(filepath.Join(contentDir, "org/apps", dir), 0755))
+		}
+	}
+	gitClient, data := fakescm.NewDefault()
+	data.ContentDir = contentDir
+
+	generator := &v1alpha1.ApplicationGenerator{
+		ObjectMeta: metav1.ObjectMeta{Name: "generator", Namespace: "fake"},
+		Spec: v1alpha1.ApplicationGeneratorSpec{Generators: []v1alpha1.ApplicationGeneratorSource{{
+			List: &v1alpha1.ListGenerator{Elements: []map[string]string{{"name": "list"}}},
+		}, {
+			Cluster: &v1alpha1.ClusterGenerator{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+				Values:   map[string]string{"namespace": "{{name}}-apps"},
+			},
+		}, {
+			GitDirectory: &v1alpha1.GitDirectoryGenerator{
+				Repo: corev1.LocalObjectReference{Name: "repo"},
+				Directories: []v1alpha1.GitDirectory{
+					{Path: "apps/*"},
+					{Path: "apps/legacy", Exclude: true},
+					{Path: "infra/ingress"},
+				},
+			},
+		}}},
+	}
+
+	r := &Reconciler{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(repo,
+			newCluster("member-b", map[string]string{"env": "prod"}),
+			newCluster("member-a", map[string]string{"env": "prod"}),
+			newCluster("host", map[string]string{"env": "test"})).Build(),
+		log: logr.New(log.NullLogSink{}),
+		newGitClient: func(*v1alpha3.GitRepository) (*scm.Client, error) {
+			return gitClient, nil
+		},
+	}
+	params, err := r.generateParameters(context.TODO(), generator)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "list"},
+		{
+			"name":                "member-a",
+			"server":              "https://member-a:6443",
+			"metadata.labels.env": "prod",
+			"values.namespace":    "member-a-apps",
+		},
+		{
+			"name":                "member-b",
+			"server":              "https://member-b:6443",
+			"metadata.labels.env": "prod",
+			"values.namespace":    "member-b-apps",
+		},
+		{"path": "apps/Api_Server", "path.basename": "Api_Server", "path.basenameNormalized": "api-server"},
+		{"path": "apps/web", "path.basename": "web", "path.basenameNormalized": "web"},
+		{"path": "infra/ingress", "path.basename": "ingress", "path.basenameNormalized": "ingress"},
+	}, params)
+
+	// the GitRepository does not exist
+	generator.Spec.Generators[2].GitDirectory.Repo.Name = "not-found"
+	_, err = r.generateParameters(context.TODO(), generator)
+	assert.NotNil(t, err)
+
+	// no generator is set
+	generator.Spec.Generators = []v1alpha1.ApplicationGeneratorSource{{}}
+	_, err = r.generateParameters(context.TODO(), generator)
+	assert.NotNil(t, err)
+}
@@ -111,6 +111,14 @@ func (r *Reconciler) createOrUpdateWebhook(repo *v1alpha3.GitRepository) (err er
 
 		// the token is optional, we can ignore the error
 		webhookToken, _ := r.getTokenFromSecret(repo.Spec.Secret, repo.Namespace)
+		if webhook.Spec.Secret != nil {
+			// prefer the dedicated secret of the webhook, it is used to verify the payloads
+			var webhookSecret *v1.Secret
+			if webhookSecret, err = r.getSecret(webhook.Spec.Secret, repo.Namespace); err != nil {
+				continue
+			}
+			webhookToken = v1alpha3.GetWebhookSecretValue(webhookSecret)
+		}
 
 		// TODO users need to add every single event of target git provider if they want to add all of them
 		//   it's possible to have a solution to allow users add all events in an easy way.
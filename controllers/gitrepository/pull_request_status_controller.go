@@ -57,7 +57,19 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return
 	}
 
-	if !pipelinerun.Spec.IsMultiBranchPipeline() || pipelinerun.Spec.SCM == nil || pipelinerun.Status.Phase == "" {
+	if pipelinerun.Spec.SCM == nil || pipelinerun.Status.Phase == "" {
+		return
+	}
+
+	isMultiBranch := pipelinerun.Spec.IsMultiBranchPipeline()
+	// a PipelineRun of a regular Pipeline is only related to a pull request when it was triggered by a webhook
+	if !isMultiBranch && pipelinerun.Spec.SCM.RefType != v1alpha3.PullRequest {
+		return
+	}
+	// the status of a pull request is only reported if the PipelineRun did build the pull request
+	if !isMultiBranch && !checksOutPullRequestHead(pipelinerun) {
+		r.log.V(6).Info(fmt.Sprintf("skip %s because it does not check out %s", req.NamespacedName,
+			v1alpha3.PullRequestHeadSHAParameter))
 		return
 	}
 
@@ -68,7 +80,12 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return
 	}
 
-	repoInfo := getRepoInfo(pipelinerun.Spec.PipelineSpec.MultiBranchPipeline)
+	var repoInfo repoInformation
+	if isMultiBranch {
+		repoInfo = getRepoInfo(pipelinerun.Spec.PipelineSpec.MultiBranchPipeline)
+	} else if repoInfo, err = r.getRepoInfoFromGitRepository(ctx, pipelinerun); err != nil {
+		return
+	}
 	if repoInfo.isInvalid() {
 		return
 	}
@@ -115,6 +132,17 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	return
 }
 
+// checksOutPullRequestHead returns true if a PipelineRun of a regular Pipeline checks out the head of the pull request.
+// The Kubernetes engine always checks it out, the Jenkinsfile must refer to the parameter PR_HEAD_SHA.
+func checksOutPullRequestHead(pipelineRun *v1alpha3.PipelineRun) bool {
+	if pipelineRun.Annotations[v1alpha3.PipelineEngineAnnoKey] == v1alpha3.PipelineEngineKubernetes {
+		return true
+	}
+	spec := pipelineRun.Spec.PipelineSpec
+	return spec != nil && spec.Pipeline != nil &&
+		strings.Contains(spec.Pipeline.Jenkinsfile, v1alpha3.PullRequestHeadSHAParameter)
+}
+
 // createExpirationCheckFunc checks the start time of the PipelineRun
 func createExpirationCheckFunc(ctx context.Context, k8sClient client.Client, currentPipelineRun *v1alpha3.PipelineRun) expirationCheckFunc {
 	return func(previousStatus *scm.Status, currentStatus *scm.StatusInput) bool {
@@ -208,9 +236,47 @@ func getRepoInfo(repo *v1alpha3.MultiBranchPipeline) (info repoInformation) {
 	return
 }
 
+// getRepoInfoFromGitRepository finds the GitRepository by the git URL which triggered the PipelineRun
+func (r *PullRequestStatusReconciler) getRepoInfoFromGitRepository(ctx context.Context, pipelineRun *v1alpha3.PipelineRun) (
+	info repoInformation, err error) {
+	gitURL := pipelineRun.GetAnnotations()[v1alpha3.PipelineRunGitURLAnnoKey]
+	if gitURL == "" {
+		return
+	}
+
+	repoList := &v1alpha3.GitRepositoryList{}
+	if err = r.List(ctx, repoList, client.InNamespace(pipelineRun.Namespace)); err != nil {
+		return
+	}
+
+	for i := range repoList.Items {
+		repo := repoList.Items[i]
+		if repo.Spec.URL != gitURL {
+			continue
+		}
+
+		info.provider = repo.Spec.Provider
+		info.owner = repo.Spec.Owner
+		info.repo = repo.Spec.Repo
+		if repo.Spec.Secret != nil {
+			info.tokenId = repo.Spec.Secret.Name
+		}
+		break
+	}
+	return
+}
+
 func (r *PullRequestStatusReconciler) getExternalPipelineRunAddress(ctx context.Context, pipelineRun *v1alpha3.PipelineRun) (target string, err error) {
 	var ws string
-	if ws, err = r.getWorkspace(ctx, pipelineRun.GetNamespace()); err == nil {
+	if ws, err = r.getWorkspace(ctx, pipelineRun.GetNamespace()); err != nil {
+		return
+	}
+
+	if !pipelineRun.Spec.IsMultiBranchPipeline() {
+		target = fmt.Sprintf("%s/%s/clusters/%s/devops/%s/pipelines/%s/run/%s/task-status",
+			net.ParseURL(r.ExternalAddress), ws, r.ClusterName,
+			pipelineRun.Namespace, pipelineRun.Spec.PipelineRef.Name, pipelineRun.Name)
+	} else {
 		target = fmt.Sprintf("%s/%s/clusters/%s/devops/%s/pipelines/%s/branch/%s/run/%s/task-status",
 			net.ParseURL(r.ExternalAddress), ws, r.ClusterName,
 			pipelineRun.Namespace, pipelineRun.Spec.PipelineRef.Name, pipelineRun.Spec.SCM.RefName, pipelineRun.Name)
@@ -240,6 +240,60 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 		"kubesphere.io/workspace": "ws",
 	}
 
+	regularPipRun := pipRun.DeepCopy()
+	regularPipRun.SetAnnotations(map[string]string{
+		v1alpha3.PipelineRunGitURLAnnoKey: "https://github.com/octocat/hello-world",
+	})
+	regularPipRun.Spec.SCM.RefType = v1alpha3.PullRequest
+	regularPipRun.Spec.PipelineSpec = &v1alpha3.PipelineSpec{
+		Type: v1alpha3.NoScmPipelineType,
+		Pipeline: &v1alpha3.NoScmPipeline{
+			Jenkinsfile: `pipeline { stages { stage('checkout') { steps { git branch: params.PR_HEAD_SHA } } } }`,
+		},
+	}
+	// the pull request is not built if the Jenkinsfile does not check it out
+	notCheckedOutPipRun := regularPipRun.DeepCopy()
+	notCheckedOutPipRun.Spec.PipelineSpec.Pipeline.Jenkinsfile = `pipeline { stages { stage('build') { steps { sh 'make' } } } }`
+
+	gitRepo := &v1alpha3.GitRepository{}
+	gitRepo.SetName("hello-world")
+	gitRepo.SetNamespace(defaultReq.namespace)
+	gitRepo.Spec = v1alpha3.GitRepositorySpec{
+		Provider: "github",
+		URL:      "https://github.com/octocat/hello-world",
+		Owner:    "octocat",
+		Repo:     "hello-world",
+		Secret: &v1.SecretReference{
+			Name: "token",
+		},
+	}
+
+	mockGitHubStatus := func(t *testing.T) {
+		gock.New("https://api.github.com").
+			Get("/repos/octocat/hello-world/pulls/1347").
+			Reply(200).
+			Type("application/json").
+			SetHeaders(mockHeaders).
+			File("testdata/pr.json")
+
+		gock.New("https://api.github.com").
+			Post("/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
+			Reply(201).
+			Type("application/json").
+			SetHeaders(mockHeaders).
+			File("testdata/status.json")
+
+		gock.New("https://api.github.com").
+			Get("/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
+			MatchParam("page", "1").
+			MatchParam("per_page", "100").
+			Reply(200).
+			Type("application/json").
+			SetHeaders(mockHeaders).
+			SetHeaders(mockPageHeaders).
+			File("testdata/statuses.json")
+	}
+
 	tests := []struct {
 		name       string
 		request    request
@@ -254,35 +308,27 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 
 		wantErr: false,
 	}, {
-		name:    "pipeline with github",
-		request: defaultReq,
-		prepare: func(t *testing.T) {
-			gock.New("https://api.github.com").
-				Get("/repos/octocat/hello-world/pulls/1347").
-				Reply(200).
-				Type("application/json").
-				SetHeaders(mockHeaders).
-				File("testdata/pr.json")
-
-			gock.New("https://api.github.com").
-				Post("/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
-				Reply(201).
-				Type("application/json").
-				SetHeaders(mockHeaders).
-				File("testdata/status.json")
-
-			gock.New("https://api.github.com").
-				Get("/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
-				MatchParam("page", "1").
-				MatchParam("per_page", "100").
-				Reply(200).
-				Type("application/json").
-				SetHeaders(mockHeaders).
-				SetHeaders(mockPageHeaders).
-				File("testdata/statuses.json")
-		},
+		name:      "pipeline with github",
+		request:   defaultReq,
+		prepare:   mockGitHubStatus,
 		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(pipRun.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
 		wantErr:   false,
+	}, {
+		name:      "regular pipeline triggered by a github pull request",
+		request:   defaultReq,
+		prepare:   mockGitHubStatus,
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(regularPipRun.DeepCopy(), gitRepo.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
+		wantErr:   false,
+	}, {
+		name:      "regular pipeline which does not check out the pull request",
+		request:   defaultReq,
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(notCheckedOutPipRun, gitRepo.DeepCopy()).Build(),
+		wantErr:   false,
+	}, {
+		name:      "regular pipeline without a matched git repository",
+		request:   defaultReq,
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(regularPipRun.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
+		wantErr:   false,
 	}}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -309,6 +355,9 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 			} else {
 				assert.Nil(t, err, "should not have error in case [%s]-[%d]", tt.name, i)
 			}
+			if tt.prepare != nil {
+				assert.True(t, gock.IsDone(), "all mocked requests should be called in case [%s]-[%d]", tt.name, i)
+			}
 		})
 	}
 }
@@ -534,3 +583,18 @@ func TestCreateExpirationCheckFunc(t *testing.T) {
 		})
 	}
 }
+
+func Test_checksOutPullRequestHead(t *testing.T) {
+	withJenkinsfile := func(jenkinsfile string) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{Spec: v1alpha3.PipelineRunSpec{PipelineSpec: &v1alpha3.PipelineSpec{
+			Pipeline: &v1alpha3.NoScmPipeline{Jenkinsfile: jenkinsfile},
+		}}}
+	}
+	kubernetesEngine := &v1alpha3.PipelineRun{}
+	kubernetesEngine.SetAnnotations(map[string]string{v1alpha3.PipelineEngineAnnoKey: v1alpha3.PipelineEngineKubernetes})
+
+	assert.True(t, checksOutPullRequestHead(kubernetesEngine))
+	assert.True(t, checksOutPullRequestHead(withJenkinsfile(`checkout scm: [$class: 'GitSCM', branches: [[name: env.PR_HEAD_SHA]]]`)))
+	assert.False(t, checksOutPullRequestHead(withJenkinsfile(`sh 'make'`)))
+	assert.False(t, checksOutPullRequestHead(&v1alpha3.PipelineRun{}))
+}
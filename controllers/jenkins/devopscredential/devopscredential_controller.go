@@ -18,16 +18,17 @@ package devopscredential
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"net/http"
 	"reflect"
-	"strings"
 	"time"
 
 	"github.com/emicklei/go-restful"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	corev1informer "k8s.io/client-go/informers/core/v1"
@@ -44,6 +45,7 @@ import (
 
 	devopsClient "kubesphere.io/devops/pkg/client/devops"
 	"kubesphere.io/devops/pkg/constants"
+	"kubesphere.io/devops/pkg/credential"
 	"kubesphere.io/devops/pkg/utils"
 	"kubesphere.io/devops/pkg/utils/k8sutil"
 	"kubesphere.io/devops/pkg/utils/sliceutil"
@@ -51,6 +53,10 @@ import (
 
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;update;watch
 
+// defaultExternalCredentialResyncPeriod is the period of resolving the external credentials again,
+// then the rotated data in the external secret backends is propagated to Jenkins
+const defaultExternalCredentialResyncPeriod = 5 * time.Minute
+
 // Controller is the controller for DevOpsProject
 type Controller struct {
 	client           clientset.Interface
@@ -68,13 +74,19 @@ type Controller struct {
 	workerLoopPeriod time.Duration
 
 	devopsClient devopsClient.Interface
+
+	credentialResolver             *credential.Resolver
+	externalCredentialResyncPeriod time.Duration
+	// dataHashKey is the key of the HMAC of the external credential data, it's never stored anywhere
+	dataHashKey []byte
 }
 
 // NewController creates an instance of the DevOpsProject controller
 func NewController(client clientset.Interface,
 	devopsClient devopsClient.Interface,
 	namespaceInformer corev1informer.NamespaceInformer,
-	secretInformer corev1informer.SecretInformer) *Controller {
+	secretInformer corev1informer.SecretInformer,
+	credentialResolver *credential.Resolver) *Controller {
 
 	broadcaster := record.NewBroadcaster()
 	broadcaster.StartLogging(func(format string, args ...interface{}) {
@@ -83,6 +95,12 @@ func NewController(client clientset.Interface,
 	broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: client.CoreV1().Events("")})
 	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "devopscredential-controller"})
 
+	// the external credentials are synchronized once again after restarting, because the key changes
+	dataHashKey := make([]byte, 32)
+	if _, err := rand.Read(dataHashKey); err != nil {
+		klog.Fatalf("failed to generate the key of the credential data hash: %v", err)
+	}
+
 	v := &Controller{
 		client:           client,
 		devopsClient:     devopsClient,
@@ -92,6 +110,10 @@ func NewController(client clientset.Interface,
 		namespaceLister:  namespaceInformer.Lister(),
 		namespaceSynced:  namespaceInformer.Informer().HasSynced,
 		workerLoopPeriod: time.Second,
+
+		credentialResolver:             credentialResolver,
+		externalCredentialResyncPeriod: defaultExternalCredentialResyncPeriod,
+		dataHashKey:                    dataHashKey,
 	}
 
 	v.eventBroadcaster = broadcaster
@@ -100,7 +122,7 @@ func NewController(client clientset.Interface,
 	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			secret, ok := obj.(*v1.Secret)
-			if ok && strings.HasPrefix(string(secret.Type), devopsv1alpha3.DevOpsCredentialPrefix) {
+			if ok && devopsv1alpha3.IsJenkinsCredential(secret) {
 				v.enqueueSecret(obj)
 			}
 		},
@@ -110,13 +132,13 @@ func NewController(client clientset.Interface,
 			if ook && nok && old.ResourceVersion == new.ResourceVersion {
 				return
 			}
-			if ook && nok && strings.HasPrefix(string(new.Type), devopsv1alpha3.DevOpsCredentialPrefix) {
+			if ook && nok && devopsv1alpha3.IsJenkinsCredential(new) {
 				v.enqueueSecret(newObj)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
 			secret, ok := obj.(*v1.Secret)
-			if ok && strings.HasPrefix(string(secret.Type), devopsv1alpha3.DevOpsCredentialPrefix) {
+			if ok && devopsv1alpha3.IsJenkinsCredential(secret) {
 				v.enqueueSecret(obj)
 			}
 		},
@@ -137,6 +159,21 @@ func (c *Controller) enqueueSecret(obj interface{}) {
 	c.workqueue.Add(key)
 }
 
+// enqueueExternalCredentials enqueues all the external credentials, they might be rotated in the external backends
+func (c *Controller) enqueueExternalCredentials() {
+	secrets, err := c.secretLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, secret := range secrets {
+		if devopsv1alpha3.IsJenkinsCredential(secret) && devopsv1alpha3.IsExternalCredential(secret) &&
+			secret.DeletionTimestamp.IsZero() {
+			c.enqueueSecret(secret)
+		}
+	}
+}
+
 func (c *Controller) processNextWorkItem() bool {
 	obj, shutdown := c.workqueue.Get()
 
@@ -198,6 +235,7 @@ func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
 	for i := 0; i < workers; i++ {
 		go wait.Until(c.worker, c.workerLoopPeriod, stopCh)
 	}
+	go wait.Until(c.enqueueExternalCredentials, c.externalCredentialResyncPeriod, stopCh)
 
 	<-stopCh
 	return nil
@@ -245,9 +283,24 @@ func (c *Controller) syncHandler(key string) error {
 			copySecret.Annotations = map[string]string{}
 		}
 
+		// the data of an external credential is only sent to Jenkins, it's never stored in the secret
+		data := copySecret.Data
+		if devopsv1alpha3.IsExternalCredential(copySecret) {
+			resolved, err := c.credentialResolver.Resolve(context.Background(), copySecret)
+			if err != nil {
+				c.eventRecorder.Event(secret, v1.EventTypeWarning, "FailedResolve", err.Error())
+				return err
+			}
+			data = resolved.Data
+		}
+
 		//If the sync is successful, return handle
 		if state, ok := copySecret.Annotations[devopsv1alpha3.CredentialSyncStatusAnnoKey]; ok && state == constants.StatusSuccessful {
-			specHash := utils.ComputeHash(copySecret.Data)
+			specHash := utils.ComputeHash(data)
+			if devopsv1alpha3.IsExternalCredential(copySecret) {
+				// the hash of the external data must not be guessable, it's stored in the annotations of the secret
+				specHash = utils.ComputeHMAC(c.dataHashKey, data)
+			}
 			oldHash := copySecret.Annotations[devopsv1alpha3.DevOpsCredentialDataHash] // don't need to check if it's nil, only compare if they're different
 			if specHash == oldHash {
 				// it was synced successfully, and there's any change with the Pipeline spec, skip this round
@@ -260,19 +313,26 @@ func (c *Controller) syncHandler(key string) error {
 		if !sliceutil.HasString(secret.ObjectMeta.Finalizers, devopsv1alpha3.CredentialFinalizerName) {
 			copySecret.ObjectMeta.Finalizers = append(copySecret.ObjectMeta.Finalizers, devopsv1alpha3.CredentialFinalizerName)
 		}
+		jenkinsCredential := copySecret
+		if devopsv1alpha3.IsExternalCredential(copySecret) {
+			jenkinsCredential = copySecret.DeepCopy()
+			jenkinsCredential.Data = data
+		}
 		// Check secret config exists, otherwise we will create it.
 		// if secret exists, update config
 		_, err := c.devopsClient.GetCredentialInProject(nsName, copySecret.Name)
 		if err == nil {
-			if _, ok := copySecret.Annotations[devopsv1alpha3.CredentialAutoSyncAnnoKey]; ok {
-				_, err := c.devopsClient.UpdateCredentialInProject(nsName, copySecret)
+			// the data of an external credential could always be resolved, it's never overwritten by a nil value
+			if _, ok := copySecret.Annotations[devopsv1alpha3.CredentialAutoSyncAnnoKey]; ok ||
+				devopsv1alpha3.IsExternalCredential(copySecret) {
+				_, err := c.devopsClient.UpdateCredentialInProject(nsName, jenkinsCredential)
 				if err != nil {
 					klog.V(8).Info(err, fmt.Sprintf("failed to update secret %s ", key))
 					return err
 				}
 			}
 		} else {
-			_, err = c.devopsClient.CreateCredentialInProject(nsName, copySecret)
+			_, err = c.devopsClient.CreateCredentialInProject(nsName, jenkinsCredential)
 			if err != nil {
 				klog.V(8).Info(err, fmt.Sprintf("failed to create secret %s ", key))
 				return err
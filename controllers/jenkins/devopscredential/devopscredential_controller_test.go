@@ -24,7 +24,11 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	fakeDevOps "kubesphere.io/devops/pkg/client/devops/fake"
+	"kubesphere.io/devops/pkg/client/vault"
+	fakeVault "kubesphere.io/devops/pkg/client/vault/fake"
 	"kubesphere.io/devops/pkg/constants"
+	"kubesphere.io/devops/pkg/credential"
+	"kubesphere.io/devops/pkg/utils"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -58,6 +62,9 @@ type fixture struct {
 	initDevOpsProject string
 	initCredential    []*v1.Secret
 	expectCredential  []*v1.Secret
+
+	credentialResolver *credential.Resolver
+	dataHashKey        []byte
 }
 
 func newFixture(t *testing.T) *fixture {
@@ -144,9 +151,12 @@ func (f *fixture) newController() (*Controller, kubeinformers.SharedInformerFact
 	dI := fakeDevOps.NewWithCredentials(f.initDevOpsProject, f.initCredential...)
 
 	c := NewController(f.kubeclient, dI, k8sI.Core().V1().Namespaces(),
-		k8sI.Core().V1().Secrets())
+		k8sI.Core().V1().Secrets(), f.credentialResolver)
 
 	c.secretSynced = alwaysReady
+	if f.dataHashKey != nil {
+		c.dataHashKey = f.dataHashKey
+	}
 	c.eventRecorder = &record.FakeRecorder{}
 	for _, f := range f.secretLister {
 		_ = k8sI.Core().V1().Secrets().Informer().GetIndexer().Add(f)
@@ -347,3 +357,108 @@ func TestNotUpdateCredential(t *testing.T) {
 	f.expectCredential = []*v1.Secret{initSecret}
 	f.run(getKey(expectSecret, t))
 }
+
+func newExternalSecret(namespace, name, path string, data map[string][]byte, syncOk bool) *v1.Secret {
+	secret := newSecret(namespace, name, data, true, false, syncOk)
+	secret.Annotations[devops.CredentialSourceAnnoKey] = devops.CredentialSourceVault
+	secret.Annotations[devops.CredentialSourcePathAnnoKey] = path
+	return secret
+}
+
+func newFakeVaultResolver(t *testing.T, server *fakeVault.Server) *credential.Resolver {
+	resolver, err := credential.NewResolverFromOptions(&vault.Options{
+		Address: server.URL, Token: "root", Mount: "secret", KVVersion: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolver
+}
+
+func TestCreateExternalCredential(t *testing.T) {
+	server := fakeVault.NewServer("root", "secret", 2)
+	defer server.Close()
+	server.Put("test-123/devops/test", map[string]interface{}{"a": "aa"})
+
+	f := newFixture(t)
+	nsName := "test-123"
+	secretName := "test"
+
+	ns := newNamespace(nsName, "test_project")
+	secret := newExternalSecret(nsName, secretName, "devops/test", nil, false)
+	// the resolved data is sent to Jenkins, but not stored in the secret
+	expectCredential := newExternalSecret(nsName, secretName, "devops/test", map[string][]byte{"a": []byte("aa")}, false)
+	expectSecret := newExternalSecret(nsName, secretName, "devops/test", nil, true)
+
+	f.secretLister = append(f.secretLister, secret)
+	f.namespaceLister = append(f.namespaceLister, ns)
+	f.kubeobjects = append(f.kubeobjects, secret)
+	f.initDevOpsProject = nsName
+	f.expectCredential = []*v1.Secret{expectCredential}
+	f.credentialResolver = newFakeVaultResolver(t, server)
+	f.expectUpdateSecretAction(expectSecret)
+	f.run(getKey(secret, t))
+}
+
+func TestRotateExternalCredential(t *testing.T) {
+	server := fakeVault.NewServer("root", "secret", 2)
+	defer server.Close()
+	server.Put("test-123/devops/test", map[string]interface{}{"a": "rotated"})
+
+	f := newFixture(t)
+	f.dataHashKey = []byte("key")
+	nsName := "test-123"
+	secretName := "test"
+
+	ns := newNamespace(nsName, "test_project")
+	oldData := map[string][]byte{"a": []byte("aa")}
+	initCredential := newExternalSecret(nsName, secretName, "devops/test", oldData, false)
+	secret := newExternalSecret(nsName, secretName, "devops/test", nil, true)
+	secret.Annotations[devops.DevOpsCredentialDataHash] = utils.ComputeHMAC(f.dataHashKey, oldData)
+	newData := map[string][]byte{"a": []byte("rotated")}
+	expectCredential := newExternalSecret(nsName, secretName, "devops/test", newData, true)
+	// the plain hash of the data is never stored
+	expectCredential.Annotations[devops.DevOpsCredentialDataHash] = utils.ComputeHMAC(f.dataHashKey, newData)
+
+	f.secretLister = append(f.secretLister, secret)
+	f.namespaceLister = append(f.namespaceLister, ns)
+	f.kubeobjects = append(f.kubeobjects, secret)
+	f.initDevOpsProject = nsName
+	f.initCredential = []*v1.Secret{initCredential}
+	f.expectCredential = []*v1.Secret{expectCredential}
+	f.credentialResolver = newFakeVaultResolver(t, server)
+	f.run(getKey(secret, t))
+}
+
+func TestResolveExternalCredentialFailed(t *testing.T) {
+	f := newFixture(t)
+	nsName := "test-123"
+	secretName := "test"
+
+	ns := newNamespace(nsName, "test_project")
+	secret := newExternalSecret(nsName, secretName, "devops/test", nil, false)
+
+	f.secretLister = append(f.secretLister, secret)
+	f.namespaceLister = append(f.namespaceLister, ns)
+	f.kubeobjects = append(f.kubeobjects, secret)
+	f.initDevOpsProject = nsName
+	f.expectCredential = []*v1.Secret{}
+	// the Vault backend is not enabled
+	f.credentialResolver = credential.NewResolver(nil)
+	f.runController(getKey(secret, t), true, true)
+}
+
+func TestEnqueueExternalCredentials(t *testing.T) {
+	f := newFixture(t)
+	external := newExternalSecret("test-123", "external", "devops/test", nil, true)
+	internal := newSecret("test-123", "internal", nil, true, true, true)
+	f.secretLister = append(f.secretLister, external, internal)
+	c, _, _ := f.newController()
+
+	c.enqueueExternalCredentials()
+	if c.workqueue.Len() != 1 {
+		t.Fatalf("expected 1 external credential in the queue, got %d", c.workqueue.Len())
+	}
+	if key, _ := c.workqueue.Get(); key != getKey(external, t) {
+		t.Errorf("expected the external credential in the queue, got %v", key)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopscredential
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// DefaultExpiryWarningPeriod is how long before the expiry a credential is considered as expiring soon
+const DefaultExpiryWarningPeriod = 7 * 24 * time.Hour
+
+// ExpiryReconciler tracks the expiry and rotation of the credentials, the result is stored as the conditions
+// of the credential, and an event is recorded when a credential is expiring or its rotation is overdue
+type ExpiryReconciler struct {
+	client.Client
+	// WarningPeriod is how long before the expiry a credential is considered as expiring soon
+	WarningPeriod time.Duration
+
+	log      logr.Logger
+	recorder record.EventRecorder
+	now      func() time.Time
+}
+
+// Reconcile updates the Expiring and RotationDue conditions of a credential,
+// then checks it again when the conditions are going to change
+func (r *ExpiryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.V(5).Info(fmt.Sprintf("start to reconcile credential expiry: %s", req.String()))
+
+	secret := &v1.Secret{}
+	if err = r.Get(ctx, req.NamespacedName, secret); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if !secret.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	now := r.now()
+	oldConditions := devopsv1alpha3.GetCredentialConditions(secret)
+	var conditions []metav1.Condition
+	conditions = append(conditions, oldConditions...)
+
+	var nextCheck time.Time
+	if expiresAt := devopsv1alpha3.GetCredentialExpiresAt(secret); expiresAt.IsZero() {
+		meta.RemoveStatusCondition(&conditions, devopsv1alpha3.CredentialConditionExpiring)
+	} else {
+		condition := metav1.Condition{Type: devopsv1alpha3.CredentialConditionExpiring}
+		warningAt := expiresAt.Add(-r.WarningPeriod)
+		switch {
+		case !now.Before(expiresAt):
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = devopsv1alpha3.CredentialReasonExpired
+			condition.Message = fmt.Sprintf("the credential expired at %s", expiresAt.Format(time.RFC3339))
+		case !now.Before(warningAt):
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = devopsv1alpha3.CredentialReasonExpiringSoon
+			condition.Message = fmt.Sprintf("the credential will expire at %s", expiresAt.Format(time.RFC3339))
+			nextCheck = expiresAt
+		default:
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = devopsv1alpha3.CredentialReasonNotExpiring
+			condition.Message = fmt.Sprintf("the credential will expire at %s", expiresAt.Format(time.RFC3339))
+			nextCheck = warningAt
+		}
+		r.setCondition(secret, &conditions, condition)
+	}
+
+	if due := devopsv1alpha3.GetCredentialRotationDue(secret); due.IsZero() {
+		meta.RemoveStatusCondition(&conditions, devopsv1alpha3.CredentialConditionRotationDue)
+	} else {
+		condition := metav1.Condition{
+			Type:    devopsv1alpha3.CredentialConditionRotationDue,
+			Status:  metav1.ConditionFalse,
+			Reason:  devopsv1alpha3.CredentialReasonRotated,
+			Message: fmt.Sprintf("the credential should be rotated before %s", due.Format(time.RFC3339)),
+		}
+		if !now.Before(due) {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = devopsv1alpha3.CredentialReasonRotationOverdue
+			condition.Message = fmt.Sprintf("the credential should have been rotated before %s",
+				due.Format(time.RFC3339))
+		} else if nextCheck.IsZero() || due.Before(nextCheck) {
+			nextCheck = due
+		}
+		r.setCondition(secret, &conditions, condition)
+	}
+
+	if !reflect.DeepEqual(conditions, oldConditions) {
+		secretToPatch := secret.DeepCopy()
+		devopsv1alpha3.SetCredentialConditions(secretToPatch, conditions)
+		if err = r.Patch(ctx, secretToPatch, client.MergeFrom(secret)); err != nil {
+			return
+		}
+	}
+	if !nextCheck.IsZero() {
+		result.RequeueAfter = nextCheck.Sub(now)
+	}
+	return
+}
+
+// setCondition sets the condition, and records an event once the status or the reason changed
+func (r *ExpiryReconciler) setCondition(secret *v1.Secret, conditions *[]metav1.Condition, condition metav1.Condition) {
+	old := meta.FindStatusCondition(*conditions, condition.Type)
+	if old != nil && old.Status == condition.Status && old.Reason == condition.Reason {
+		// keep the message unchanged, or the condition will be updated every time
+		return
+	}
+	meta.SetStatusCondition(conditions, condition)
+
+	eventType := v1.EventTypeNormal
+	if condition.Status == metav1.ConditionTrue {
+		eventType = v1.EventTypeWarning
+	} else if old == nil {
+		// there's no need to notify if a credential is fine at the beginning
+		return
+	}
+	r.recorder.Event(secret, eventType, condition.Reason, condition.Message)
+}
+
+// GetName returns the name of this controller
+func (r *ExpiryReconciler) GetName() string {
+	return "CredentialExpiryReconciler"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *ExpiryReconciler) GetGroupName() string {
+	return "jenkins"
+}
+
+// hasExpiryOrRotation accepts the credentials which have the expiry or rotation annotations,
+// or the conditions to be cleaned up
+func hasExpiryOrRotation(object client.Object) bool {
+	secret, ok := object.(*v1.Secret)
+	if !ok || !strings.HasPrefix(string(secret.Type), devopsv1alpha3.DevOpsCredentialPrefix) {
+		return false
+	}
+	for _, key := range []string{devopsv1alpha3.CredentialExpiresAtAnnoKey, devopsv1alpha3.CredentialRotationPeriodAnnoKey,
+		devopsv1alpha3.CredentialConditionsAnnoKey} {
+		if _, ok := secret.Annotations[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupWithManager setups the log and recorder
+func (r *ExpiryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	if r.WarningPeriod <= 0 {
+		r.WarningPeriod = DefaultExpiryWarningPeriod
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("credential_expiry").
+		For(&v1.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(hasExpiryOrRotation))).
+		Complete(r)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopscredential
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestExpiryReconciler_Reconcile(t *testing.T) {
+	schema := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(schema))
+
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	newCredential := func(annotations map[string]string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "fake",
+				Name:              "fake",
+				CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour)),
+				Annotations:       annotations,
+			},
+			Type: devopsv1alpha3.SecretTypeBasicAuth,
+		}
+	}
+
+	tests := []struct {
+		name             string
+		secret           *v1.Secret
+		wantResult       ctrl.Result
+		wantConditions   map[string]string
+		wantEventReasons []string
+	}{{
+		name:   "without expiry or rotation",
+		secret: newCredential(nil),
+	}, {
+		name: "not expiring",
+		secret: newCredential(map[string]string{
+			devopsv1alpha3.CredentialExpiresAtAnnoKey: now.Add(30 * 24 * time.Hour).Format(time.RFC3339),
+		}),
+		wantResult:     ctrl.Result{RequeueAfter: 23 * 24 * time.Hour},
+		wantConditions: map[string]string{devopsv1alpha3.CredentialConditionExpiring: devopsv1alpha3.CredentialReasonNotExpiring},
+	}, {
+		name: "expiring soon",
+		secret: newCredential(map[string]string{
+			devopsv1alpha3.CredentialExpiresAtAnnoKey: now.Add(24 * time.Hour).Format(time.RFC3339),
+		}),
+		wantResult:       ctrl.Result{RequeueAfter: 24 * time.Hour},
+		wantConditions:   map[string]string{devopsv1alpha3.CredentialConditionExpiring: devopsv1alpha3.CredentialReasonExpiringSoon},
+		wantEventReasons: []string{devopsv1alpha3.CredentialReasonExpiringSoon},
+	}, {
+		name: "expired",
+		secret: newCredential(map[string]string{
+			devopsv1alpha3.CredentialExpiresAtAnnoKey: now.Add(-time.Hour).Format(time.RFC3339),
+		}),
+		wantConditions:   map[string]string{devopsv1alpha3.CredentialConditionExpiring: devopsv1alpha3.CredentialReasonExpired},
+		wantEventReasons: []string{devopsv1alpha3.CredentialReasonExpired},
+	}, {
+		name: "rotation overdue since the creation",
+		secret: newCredential(map[string]string{
+			devopsv1alpha3.CredentialRotationPeriodAnnoKey: "12h",
+		}),
+		wantConditions:   map[string]string{devopsv1alpha3.CredentialConditionRotationDue: devopsv1alpha3.CredentialReasonRotationOverdue},
+		wantEventReasons: []string{devopsv1alpha3.CredentialReasonRotationOverdue},
+	}, {
+		name: "rotated recently",
+		secret: newCredential(map[string]string{
+			devopsv1alpha3.CredentialRotationPeriodAnnoKey: "12h",
+			devopsv1alpha3.CredentialLastRotatedAnnoKey:    now.Add(-time.Hour).Format(time.RFC3339),
+			devopsv1alpha3.CredentialExpiresAtAnnoKey:      now.Add(30 * 24 * time.Hour).Format(time.RFC3339),
+		}),
+		wantResult: ctrl.Result{RequeueAfter: 11 * time.Hour},
+		wantConditions: map[string]string{
+			devopsv1alpha3.CredentialConditionExpiring:    devopsv1alpha3.CredentialReasonNotExpiring,
+			devopsv1alpha3.CredentialConditionRotationDue: devopsv1alpha3.CredentialReasonRotated,
+		},
+	}, {
+		name: "recovered after the rotation",
+		secret: func() *v1.Secret {
+			secret := newCredential(map[string]string{
+				devopsv1alpha3.CredentialRotationPeriodAnnoKey: "12h",
+				devopsv1alpha3.CredentialLastRotatedAnnoKey:    now.Format(time.RFC3339),
+			})
+			devopsv1alpha3.SetCredentialConditions(secret, []metav1.Condition{{
+				Type:   devopsv1alpha3.CredentialConditionRotationDue,
+				Status: metav1.ConditionTrue,
+				Reason: devopsv1alpha3.CredentialReasonRotationOverdue,
+			}})
+			return secret
+		}(),
+		wantResult:       ctrl.Result{RequeueAfter: 12 * time.Hour},
+		wantConditions:   map[string]string{devopsv1alpha3.CredentialConditionRotationDue: devopsv1alpha3.CredentialReasonRotated},
+		wantEventReasons: []string{devopsv1alpha3.CredentialReasonRotated},
+	}, {
+		name: "clean up the conditions",
+		secret: func() *v1.Secret {
+			secret := newCredential(nil)
+			devopsv1alpha3.SetCredentialConditions(secret, []metav1.Condition{{
+				Type:   devopsv1alpha3.CredentialConditionExpiring,
+				Status: metav1.ConditionTrue,
+				Reason: devopsv1alpha3.CredentialReasonExpired,
+			}})
+			return secret
+		}(),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(schema, tt.secret)
+			recorder := record.NewFakeRecorder(10)
+			r := &ExpiryReconciler{
+				Client:        c,
+				WarningPeriod: DefaultExpiryWarningPeriod,
+				log:           logr.New(log.NullLogSink{}),
+				recorder:      recorder,
+				now:           func() time.Time { return now },
+			}
+			result, err := r.Reconcile(context.TODO(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "fake", Name: "fake"}})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantResult, result)
+
+			secret := &v1.Secret{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake", Name: "fake"}, secret))
+			conditions := devopsv1alpha3.GetCredentialConditions(secret)
+			assert.Equal(t, len(tt.wantConditions), len(conditions))
+			for conditionType, reason := range tt.wantConditions {
+				if condition := meta.FindStatusCondition(conditions, conditionType); assert.NotNil(t, condition) {
+					assert.Equal(t, reason, condition.Reason)
+				}
+			}
+
+			close(recorder.Events)
+			var reasons []string
+			for event := range recorder.Events {
+				// the event of the fake recorder is in the format of: type reason message
+				reasons = append(reasons, strings.Fields(event)[1])
+			}
+			assert.Equal(t, tt.wantEventReasons, reasons)
+		})
+	}
+}
+
+func TestHasExpiryOrRotation(t *testing.T) {
+	assert.False(t, hasExpiryOrRotation(&v1.Secret{Type: devopsv1alpha3.SecretTypeBasicAuth}))
+	assert.False(t, hasExpiryOrRotation(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			devopsv1alpha3.CredentialExpiresAtAnnoKey: "2022-12-31T00:00:00Z",
+		}},
+		Type: v1.SecretTypeOpaque,
+	}))
+	assert.True(t, hasExpiryOrRotation(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			devopsv1alpha3.CredentialRotationPeriodAnnoKey: "720h",
+		}},
+		Type: devopsv1alpha3.SecretTypeSOPS,
+	}))
+	assert.True(t, hasExpiryOrRotation(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			devopsv1alpha3.CredentialConditionsAnnoKey: "[]",
+		}},
+		Type: devopsv1alpha3.SecretTypeBasicAuth,
+	}))
+}
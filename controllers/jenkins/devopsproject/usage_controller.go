@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsproject
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	projectmodel "kubesphere.io/devops/pkg/models/devopsproject"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=devopsprojects,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines;pipelineruns,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// UsageReconciler reports the usage of the limits into the status of the DevOpsProjects
+type UsageReconciler struct {
+	client.Client
+
+	log logr.Logger
+}
+
+// Reconcile counts the Pipelines and PipelineRuns in the admin namespace of a DevOpsProject
+func (r *UsageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.V(5).Info(fmt.Sprintf("start to reconcile DevOpsProject usage: %s", req.String()))
+
+	project := &devopsv1alpha3.DevOpsProject{}
+	if err = r.Get(ctx, req.NamespacedName, project); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if !project.DeletionTimestamp.IsZero() || project.Status.AdminNamespace == "" {
+		return
+	}
+
+	var usage *devopsv1alpha3.DevOpsProjectUsage
+	if usage, err = projectmodel.GetUsage(ctx, r.Client, project.Status.AdminNamespace); err != nil {
+		return
+	}
+	if reflect.DeepEqual(usage, project.Status.Usage) {
+		return
+	}
+	// there's no status subresource of DevOpsProject
+	projectToPatch := project.DeepCopy()
+	projectToPatch.Status.Usage = usage
+	err = r.Patch(ctx, projectToPatch, client.MergeFrom(project))
+	return
+}
+
+// findProject returns the DevOpsProject which the namespace of the object belongs to
+func (r *UsageReconciler) findProject(obj client.Object) (requests []reconcile.Request) {
+	ns := &v1.Namespace{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: obj.GetNamespace()}, ns); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			r.log.Error(err, "failed to get Namespace", "namespace", obj.GetNamespace())
+		}
+		return
+	}
+	if name := ns.Labels[constants.DevOpsProjectLabelKey]; name != "" {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+	return
+}
+
+// GetName returns the name of this controller
+func (r *UsageReconciler) GetName() string {
+	return "DevOpsProjectUsageReconciler"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *UsageReconciler) GetGroupName() string {
+	return "jenkins"
+}
+
+// SetupWithManager setups the log
+func (r *UsageReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("devopsproject_usage").
+		For(&devopsv1alpha3.DevOpsProject{}).
+		Watches(&source.Kind{Type: &devopsv1alpha3.Pipeline{}}, handler.EnqueueRequestsFromMapFunc(r.findProject)).
+		Watches(&source.Kind{Type: &devopsv1alpha3.PipelineRun{}}, handler.EnqueueRequestsFromMapFunc(r.findProject)).
+		Complete(r)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsproject
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestUsageReconciler_Reconcile(t *testing.T) {
+	schema := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(schema))
+	assert.Nil(t, devopsv1alpha3.AddToScheme(schema))
+
+	newProject := func(adminNamespace string, usage *devopsv1alpha3.DevOpsProjectUsage) *devopsv1alpha3.DevOpsProject {
+		return &devopsv1alpha3.DevOpsProject{
+			ObjectMeta: metav1.ObjectMeta{Name: "project"},
+			Status:     devopsv1alpha3.DevOpsProjectStatus{AdminNamespace: adminNamespace, Usage: usage},
+		}
+	}
+	completionTime := metav1.NewTime(time.Now())
+	objects := []client.Object{
+		&devopsv1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "a"}},
+		&devopsv1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "b"}},
+		&devopsv1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "c"}},
+		&devopsv1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "running",
+			Annotations: map[string]string{devopsv1alpha3.JenkinsPipelineRunIDAnnoKey: "1"}}},
+		&devopsv1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "queued"}},
+		&devopsv1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "completed",
+				Annotations: map[string]string{devopsv1alpha3.JenkinsPipelineRunIDAnnoKey: "2"}},
+			Status: devopsv1alpha3.PipelineRunStatus{CompletionTime: &completionTime},
+		},
+	}
+	wantUsage := &devopsv1alpha3.DevOpsProjectUsage{Pipelines: 2, RunningPipelineRuns: 1, QueuedPipelineRuns: 1}
+
+	tests := []struct {
+		name      string
+		project   *devopsv1alpha3.DevOpsProject
+		wantUsage *devopsv1alpha3.DevOpsProjectUsage
+	}{{
+		name:    "without admin namespace",
+		project: newProject("", nil),
+	}, {
+		name:      "report the usage",
+		project:   newProject("project-ns", nil),
+		wantUsage: wantUsage,
+	}, {
+		name:      "update the usage",
+		project:   newProject("project-ns", &devopsv1alpha3.DevOpsProjectUsage{Pipelines: 5}),
+		wantUsage: wantUsage,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(append(objects, tt.project)...).Build()
+			r := &UsageReconciler{
+				Client: c,
+				log:    logr.New(log.NullLogSink{}),
+			}
+			result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "project"}})
+			assert.Nil(t, err)
+			assert.Equal(t, ctrl.Result{}, result)
+
+			project := &devopsv1alpha3.DevOpsProject{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Name: "project"}, project))
+			assert.Equal(t, tt.wantUsage, project.Status.Usage)
+		})
+	}
+}
+
+func TestUsageReconciler_findProject(t *testing.T) {
+	schema := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(schema))
+
+	r := &UsageReconciler{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "project-ns",
+				Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"}}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}}).Build(),
+		log: logr.New(log.NullLogSink{}),
+	}
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "project"}}},
+		r.findProject(&devopsv1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "a"}}))
+	assert.Empty(t, r.findProject(&devopsv1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "a"}}))
+	assert.Empty(t, r.findProject(&devopsv1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "missing", Name: "a"}}))
+}
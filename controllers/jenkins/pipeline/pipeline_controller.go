@@ -236,6 +236,13 @@ func (c *Controller) syncHandler(key string) error {
 	copyPipeline := pipeline.DeepCopy()
 	// DeletionTimestamp.IsZero() means copyPipeline has not been deleted.
 	if copyPipeline.ObjectMeta.DeletionTimestamp.IsZero() {
+		// the Pipelines of the other engines have nothing in Jenkins. The ones which ran on Jenkins before
+		// keep the finalizer, so their Jenkins jobs are still deleted along with them.
+		if copyPipeline.GetEngine() != devopsv1alpha3.PipelineEngineJenkins {
+			klog.V(8).Info(fmt.Sprintf("skip pipeline %s which runs on the %s engine", key, copyPipeline.GetEngine()))
+			return nil
+		}
+
 		// make sure Annotations is not nil
 		if copyPipeline.Annotations == nil {
 			copyPipeline.Annotations = map[string]string{}
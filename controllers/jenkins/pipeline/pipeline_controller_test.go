@@ -349,6 +349,31 @@ func TestAddPipelineFinalizers(t *testing.T) {
 	f.run(getKey(pipeline, t))
 }
 
+func TestSkipKubernetesEnginePipeline(t *testing.T) {
+	f := newFixture(t)
+	nsName := "test-123"
+	pipelineName := "test"
+	projectName := "test_project"
+	spec := devops.PipelineSpec{
+		Type: devops.NoScmPipelineType,
+		Pipeline: &devops.NoScmPipeline{
+			Name: pipelineName,
+		},
+	}
+	pipeline := newPipeline(nsName, pipelineName, spec, false, false)
+	pipeline.Annotations[devops.PipelineEngineAnnoKey] = devops.PipelineEngineKubernetes
+	ns := newNamespace(nsName, projectName)
+
+	f.pipelineLister = append(f.pipelineLister, pipeline)
+	f.namespaceLister = append(f.namespaceLister, ns)
+	f.objects = append(f.objects, pipeline)
+	f.initDevOpsProject = nsName
+	// neither the Jenkins job nor the finalizer is created
+	f.expectPipeline = []*devops.Pipeline{}
+
+	f.run(getKey(pipeline, t))
+}
+
 func TestCreatePipeline(t *testing.T) {
 	f := newFixture(t)
 	nsName := "test-123"
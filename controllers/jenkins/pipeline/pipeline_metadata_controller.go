@@ -63,6 +63,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		// ignore resource not found due to deletion
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if pipeline.GetEngine() != v1alpha3.PipelineEngineJenkins {
+		// there is no metadata in Jenkins
+		return ctrl.Result{}, nil
+	}
 
 	if err := r.obtainAndUpdatePipelineMetadata(pipeline); err != nil {
 		log.Error(err, "unable to obtain and update Pipeline metadata from Jenkins")
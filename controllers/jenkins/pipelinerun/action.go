@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleAction stops, pauses or resumes a running PipelineRun according to its action.
+// The Stopped and Paused conditions record the current state, so the same action won't be sent to the engine twice.
+// The state is recorded before the engine is called, and it's rolled back if the engine fails.
+func (r *Reconciler) handleAction(ctx context.Context, runEngine engine, pr *v1alpha3.PipelineRun) (err error) {
+	if pr.Spec.Action == nil || !pr.HasStarted() || pr.HasCompleted() {
+		return
+	}
+
+	var (
+		condition = &v1alpha3.Condition{Type: v1alpha3.ConditionPaused}
+		apply     func(context.Context, *v1alpha3.PipelineRun) error
+	)
+	switch *pr.Spec.Action {
+	case v1alpha3.Stop:
+		if pr.Status.IsStopped() {
+			return
+		}
+		condition.Type = v1alpha3.ConditionStopped
+		condition.Status = v1alpha3.ConditionTrue
+		condition.Reason = v1alpha3.Stopped
+		condition.Message = "the PipelineRun has been requested to stop"
+		apply = runEngine.stop
+	case v1alpha3.Pause:
+		if pr.Status.IsPaused() {
+			return
+		}
+		condition.Status = v1alpha3.ConditionTrue
+		condition.Reason = v1alpha3.Paused
+		condition.Message = "the PipelineRun holds at the next step until it is resumed"
+		apply = runEngine.togglePause
+	case v1alpha3.Resume:
+		if !pr.Status.IsPaused() {
+			return
+		}
+		condition.Status = v1alpha3.ConditionFalse
+		condition.Reason = v1alpha3.Resumed
+		condition.Message = "the PipelineRun has been resumed"
+		apply = runEngine.togglePause
+	default:
+		return
+	}
+
+	// record the state before applying the action, so that a failure to update the status after the engine applied it
+	// won't toggle the PipelineRun once more in the next reconciliation
+	key := client.ObjectKey{Namespace: pr.Namespace, Name: pr.Name}
+	previousConditions := append([]v1alpha3.Condition(nil), pr.Status.Conditions...)
+	now := v1.Now()
+	condition.LastProbeTime = now
+	condition.LastTransitionTime = now
+	pr.Status.AddCondition(condition)
+	if err = r.updateStatus(ctx, &pr.Status, key); err != nil {
+		return
+	}
+
+	if err = apply(ctx, pr); err != nil {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed,
+			"Failed to %s PipelineRun %s/%s, and error was %v", *pr.Spec.Action, pr.Namespace, pr.Name, err)
+		// take back the state, so that the action will be applied again
+		pr.Status.Conditions = previousConditions
+		if rollbackErr := r.updateStatus(ctx, &pr.Status, key); rollbackErr != nil {
+			klog.Errorf("unable to roll back the status of PipelineRun %s/%s, error: %v", pr.Namespace, pr.Name, rollbackErr)
+		}
+		return
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, condition.Reason, "%s PipelineRun %s/%s", condition.Reason,
+		pr.Namespace, pr.Name)
+	return
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/jenkins-zh/jenkins-client/pkg/mock/mhttp"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHandleAction(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	const (
+		toggleAPI = "http://localhost/job/ns/job/pipeline/1/pause/toggle"
+		stopAPI   = "http://localhost/job/ns/job/pipeline/1/stop"
+	)
+	newRunningRun := func(action v1alpha3.Action, paused bool) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "pr",
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "1",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{Name: "pipeline"},
+				Action:      &action,
+			},
+			Status: v1alpha3.PipelineRunStatus{
+				Phase: v1alpha3.Running,
+			},
+		}
+		if paused {
+			pr.Status.AddCondition(&v1alpha3.Condition{
+				Type:   v1alpha3.ConditionPaused,
+				Status: v1alpha3.ConditionTrue,
+				Reason: v1alpha3.Paused,
+			})
+		}
+		return pr
+	}
+
+	tests := []struct {
+		name          string
+		pipelineRun   *v1alpha3.PipelineRun
+		api           string
+		statusCode    int
+		wantErr       bool
+		wantCondition *v1alpha3.Condition
+	}{{
+		name: "PipelineRun without action",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newRunningRun(v1alpha3.Pause, false)
+			pr.Spec.Action = nil
+			return pr
+		}(),
+	}, {
+		name: "PipelineRun which has not started",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newRunningRun(v1alpha3.Pause, false)
+			pr.Annotations = nil
+			return pr
+		}(),
+	}, {
+		name: "completed PipelineRun",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newRunningRun(v1alpha3.Pause, false)
+			now := metav1.Now()
+			pr.Status.CompletionTime = &now
+			return pr
+		}(),
+	}, {
+		name:        "stop a running PipelineRun",
+		pipelineRun: newRunningRun(v1alpha3.Stop, false),
+		api:         stopAPI,
+		statusCode:  http.StatusOK,
+		wantCondition: &v1alpha3.Condition{
+			Type:   v1alpha3.ConditionStopped,
+			Status: v1alpha3.ConditionTrue,
+			Reason: v1alpha3.Stopped,
+		},
+	}, {
+		name: "stop a stopped PipelineRun",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newRunningRun(v1alpha3.Stop, false)
+			pr.Status.AddCondition(&v1alpha3.Condition{
+				Type:   v1alpha3.ConditionStopped,
+				Status: v1alpha3.ConditionTrue,
+				Reason: v1alpha3.Stopped,
+			})
+			return pr
+		}(),
+		wantCondition: &v1alpha3.Condition{
+			Type:   v1alpha3.ConditionStopped,
+			Status: v1alpha3.ConditionTrue,
+			Reason: v1alpha3.Stopped,
+		},
+	}, {
+		name:        "pause a running PipelineRun",
+		pipelineRun: newRunningRun(v1alpha3.Pause, false),
+		statusCode:  http.StatusOK,
+		wantCondition: &v1alpha3.Condition{
+			Type:   v1alpha3.ConditionPaused,
+			Status: v1alpha3.ConditionTrue,
+			Reason: v1alpha3.Paused,
+		},
+	}, {
+		name:        "pause a paused PipelineRun",
+		pipelineRun: newRunningRun(v1alpha3.Pause, true),
+		wantCondition: &v1alpha3.Condition{
+			Type:   v1alpha3.ConditionPaused,
+			Status: v1alpha3.ConditionTrue,
+			Reason: v1alpha3.Paused,
+		},
+	}, {
+		name:        "resume a paused PipelineRun",
+		pipelineRun: newRunningRun(v1alpha3.Resume, true),
+		statusCode:  http.StatusOK,
+		wantCondition: &v1alpha3.Condition{
+			Type:   v1alpha3.ConditionPaused,
+			Status: v1alpha3.ConditionFalse,
+			Reason: v1alpha3.Resumed,
+		},
+	}, {
+		name:        "resume a running PipelineRun",
+		pipelineRun: newRunningRun(v1alpha3.Resume, false),
+	}, {
+		name:        "failed to pause a PipelineRun",
+		pipelineRun: newRunningRun(v1alpha3.Pause, false),
+		statusCode:  http.StatusInternalServerError,
+		wantErr:     true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			roundTripper := mhttp.NewMockRoundTripper(ctrl)
+			if tt.statusCode != 0 {
+				api := tt.api
+				if api == "" {
+					api = toggleAPI
+				}
+				expectJenkinsPost(roundTripper, api, tt.statusCode)
+			}
+
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.pipelineRun.DeepCopy()).Build(),
+				recorder: record.NewFakeRecorder(10),
+			}
+			runEngine := &jenkinsEngine{jenkinsHandler: &jenkinsHandler{&core.JenkinsCore{
+				URL:          "http://localhost",
+				RoundTripper: roundTripper,
+			}}, reconciler: r}
+			err := r.handleAction(context.TODO(), runEngine, tt.pipelineRun.DeepCopy())
+			assert.Equal(t, tt.wantErr, err != nil, err)
+
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.TODO(), client.ObjectKey{Namespace: "ns", Name: "pr"}, pr))
+			if tt.wantCondition == nil {
+				assert.Nil(t, pr.Status.GetCondition(v1alpha3.ConditionPaused))
+				assert.Nil(t, pr.Status.GetCondition(v1alpha3.ConditionStopped))
+				return
+			}
+			condition := pr.Status.GetCondition(tt.wantCondition.Type)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantCondition.Status, condition.Status)
+				assert.Equal(t, tt.wantCondition.Reason, condition.Reason)
+			}
+		})
+	}
+}
+
+// pauseRecorder records whether the Paused condition was stored when the PipelineRun was toggled
+type pauseRecorder struct {
+	engine
+	client.Client
+	pausedWhenToggled bool
+}
+
+func (e *pauseRecorder) togglePause(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	stored := &v1alpha3.PipelineRun{}
+	if err := e.Get(ctx, client.ObjectKeyFromObject(pr), stored); err != nil {
+		return err
+	}
+	e.pausedWhenToggled = stored.Status.IsPaused()
+	return nil
+}
+
+func TestHandleAction_recordBeforeApplying(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	action := v1alpha3.Pause
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "pr",
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"},
+		},
+		Spec:   v1alpha3.PipelineRunSpec{Action: &action},
+		Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running},
+	}
+	r := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(pr.DeepCopy()).Build(),
+		recorder: record.NewFakeRecorder(10),
+	}
+	runEngine := &pauseRecorder{Client: r.Client}
+	assert.Nil(t, r.handleAction(context.TODO(), runEngine, pr.DeepCopy()))
+	assert.True(t, runEngine.pausedWhenToggled)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/devopsproject"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// queuedRequeueInterval is the interval to check if a queued PipelineRun could be triggered
+const queuedRequeueInterval = 10 * time.Second
+
+// admitPipelineRun decides whether a PipelineRun could be triggered in Jenkins now,
+// it has to be admitted by both its concurrency group and the limits of its DevOpsProject.
+func (r *Reconciler) admitPipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, pipeline *v1alpha3.Pipeline) (
+	admitted bool, result ctrl.Result, err error) {
+	if pr.Spec.Action != nil && *pr.Spec.Action == v1alpha3.Stop {
+		err = r.cancelPipelineRun(ctx, pr, "the PipelineRun was stopped before it was triggered")
+		return
+	}
+
+	if admitted, result, err = r.admitConcurrencyGroup(ctx, pr, pipeline); err == nil && admitted {
+		admitted, result, err = r.admitProjectLimits(ctx, pr, pipeline)
+	}
+	return
+}
+
+// admitConcurrencyGroup decides whether a PipelineRun could be triggered by its concurrency group.
+// The PipelineRun waits in the Queued phase until no other PipelineRun of the same concurrency group is running,
+// and the earlier queued PipelineRuns go first.
+// In the cancel-in-progress mode, the newest PipelineRun of the group stops the older ones.
+func (r *Reconciler) admitConcurrencyGroup(ctx context.Context, pr *v1alpha3.PipelineRun, pipeline *v1alpha3.Pipeline) (
+	admitted bool, result ctrl.Result, err error) {
+	policy := pipeline.Spec.Concurrency
+	if policy == nil || policy.Group == "" {
+		admitted = true
+		return
+	}
+	if errs := validation.IsValidLabelValue(policy.Group); len(errs) > 0 {
+		err = fmt.Errorf("invalid concurrency group %q of Pipeline %s/%s: %s", policy.Group, pipeline.Namespace,
+			pipeline.Name, strings.Join(errs, "; "))
+		return
+	}
+
+	// label the PipelineRun, then other PipelineRuns could find it
+	if pr.Labels[v1alpha3.PipelineRunConcurrencyGroupLabelKey] != policy.Group {
+		pr.Labels[v1alpha3.PipelineRunConcurrencyGroupLabelKey] = policy.Group
+		if err = r.updateLabelsAndAnnotations(ctx, pr); err != nil {
+			return
+		}
+	}
+
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, pipelineRuns, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunConcurrencyGroupLabelKey: policy.Group}); err != nil {
+		return
+	}
+
+	var running, earlier, later []*v1alpha3.PipelineRun
+	for i := range pipelineRuns.Items {
+		item := &pipelineRuns.Items[i]
+		if item.Name == pr.Name || !item.Buildable() || !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+		switch {
+		case item.HasStarted():
+			running = append(running, item)
+		case isEarlierPipelineRun(item, pr):
+			earlier = append(earlier, item)
+		default:
+			later = append(later, item)
+		}
+	}
+
+	if policy.CancelInProgress {
+		if len(later) > 0 {
+			err = r.cancelPipelineRun(ctx, pr, fmt.Sprintf("the PipelineRun was superseded by PipelineRun %s of concurrency group %s",
+				later[0].Name, policy.Group))
+			return
+		}
+		// the earlier queued PipelineRuns will cancel themselves
+		for _, item := range running {
+			if err = r.requestStop(ctx, item, pr); err != nil {
+				return
+			}
+		}
+	}
+
+	var blocker *v1alpha3.PipelineRun
+	if len(running) > 0 {
+		blocker = running[0]
+	} else if len(earlier) > 0 && !policy.CancelInProgress {
+		blocker = earlier[0]
+	}
+	if blocker == nil {
+		admitted = true
+		return
+	}
+
+	if pr.Status.Phase != v1alpha3.Queued {
+		err = r.queuePipelineRun(ctx, pr, fmt.Sprintf("waiting for PipelineRun %s of concurrency group %s",
+			blocker.Name, policy.Group))
+	}
+	result = ctrl.Result{RequeueAfter: queuedRequeueInterval}
+	return
+}
+
+// admitProjectLimits decides whether a PipelineRun could be triggered under the limits of its DevOpsProject.
+// The PipelineRun is cancelled if its agents violate the limits, or it waits in the Queued phase until
+// the running PipelineRuns of the DevOpsProject are fewer than the limit, and the earlier queued PipelineRuns go first.
+func (r *Reconciler) admitProjectLimits(ctx context.Context, pr *v1alpha3.PipelineRun, pipeline *v1alpha3.Pipeline) (
+	admitted bool, result ctrl.Result, err error) {
+	var project *v1alpha3.DevOpsProject
+	if project, err = devopsproject.GetByNamespace(ctx, r.Client, pr.Namespace); err != nil {
+		return
+	}
+	if project == nil || project.Spec.Limits == nil {
+		admitted = true
+		return
+	}
+	limits := project.Spec.Limits
+
+	if violation := devopsproject.ValidatePipelineAgents(ctx, r.Client, limits, pipeline); violation != nil {
+		err = r.cancelPipelineRun(ctx, pr, fmt.Sprintf("the agents violate the limits of DevOpsProject %s: %v",
+			project.Name, violation))
+		return
+	}
+
+	if limits.MaxConcurrentPipelineRuns <= 0 {
+		admitted = true
+		return
+	}
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, pipelineRuns, client.InNamespace(pr.Namespace)); err != nil {
+		return
+	}
+	var running, earlier int32
+	for i := range pipelineRuns.Items {
+		item := &pipelineRuns.Items[i]
+		if item.Name == pr.Name || !item.Buildable() || !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if item.HasStarted() {
+			running++
+		} else if isEarlierPipelineRun(item, pr) {
+			earlier++
+		}
+	}
+	if running+earlier < limits.MaxConcurrentPipelineRuns {
+		admitted = true
+		return
+	}
+
+	if pr.Status.Phase != v1alpha3.Queued {
+		err = r.queuePipelineRun(ctx, pr, fmt.Sprintf("waiting for %d running and %d earlier PipelineRuns of DevOpsProject %s, the limit is %d",
+			running, earlier, project.Name, limits.MaxConcurrentPipelineRuns))
+	}
+	result = ctrl.Result{RequeueAfter: queuedRequeueInterval}
+	return
+}
+
+// queuePipelineRun moves the PipelineRun into the Queued phase
+func (r *Reconciler) queuePipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, message string) error {
+	now := v1.Now()
+	status := pr.Status.DeepCopy()
+	status.Phase = v1alpha3.Queued
+	status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionReady,
+		Status:             v1alpha3.ConditionUnknown,
+		Reason:             string(v1alpha3.Queued),
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, string(v1alpha3.Queued), "Queued PipelineRun %s/%s, %s",
+		pr.Namespace, pr.Name, message)
+	return r.updateStatus(ctx, status, client.ObjectKey{Namespace: pr.Namespace, Name: pr.Name})
+}
+
+// cancelPipelineRun completes a PipelineRun which has not been triggered
+func (r *Reconciler) cancelPipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, message string) error {
+	now := v1.Now()
+	status := pr.Status.DeepCopy()
+	status.Phase = v1alpha3.Cancelled
+	status.CompletionTime = &now
+	status.UpdateTime = &now
+	status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionSucceeded,
+		Status:             v1alpha3.ConditionFalse,
+		Reason:             string(v1alpha3.Cancelled),
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, string(v1alpha3.Cancelled), "Cancelled PipelineRun %s/%s, %s",
+		pr.Namespace, pr.Name, message)
+	return r.updateStatus(ctx, status, client.ObjectKey{Namespace: pr.Namespace, Name: pr.Name})
+}
+
+// requestStop sets the Stop action of a running PipelineRun, then it will be stopped by its own reconciling
+func (r *Reconciler) requestStop(ctx context.Context, pr, newer *v1alpha3.PipelineRun) (err error) {
+	if pr.Spec.Action != nil && *pr.Spec.Action == v1alpha3.Stop {
+		return
+	}
+	prToUpdate := pr.DeepCopy()
+	stop := v1alpha3.Stop
+	prToUpdate.Spec.Action = &stop
+	if err = r.Patch(ctx, prToUpdate, client.MergeFrom(pr)); err == nil {
+		r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Stopped,
+			"Stopping PipelineRun %s/%s in favor of the newer PipelineRun %s", pr.Namespace, pr.Name, newer.Name)
+	}
+	return client.IgnoreNotFound(err)
+}
+
+// isEarlierPipelineRun returns true if PipelineRun a was created before PipelineRun b
+func isEarlierPipelineRun(a, b *v1alpha3.PipelineRun) bool {
+	if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.Name < b.Name
+	}
+	return a.CreationTimestamp.Before(&b.CreationTimestamp)
+}
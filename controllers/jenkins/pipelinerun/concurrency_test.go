@@ -0,0 +1,325 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	"kubesphere.io/devops/pkg/models/devopsproject"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAdmitPipelineRun(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, corev1.AddToScheme(schema))
+
+	now := time.Now()
+	newPipeline := func(policy *v1alpha3.ConcurrencyPolicy) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+			Spec: v1alpha3.PipelineSpec{
+				Type:        v1alpha3.NoScmPipelineType,
+				Concurrency: policy,
+			},
+		}
+	}
+	// newRun creates a PipelineRun of the group "prod" which was created at the given offset
+	newRun := func(name string, offset time.Duration, started bool) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "ns",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(offset)),
+				Labels: map[string]string{
+					v1alpha3.PipelineNameLabelKey:                "pipeline",
+					v1alpha3.PipelineRunConcurrencyGroupLabelKey: "prod",
+				},
+				Annotations: map[string]string{},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{Name: "pipeline"},
+			},
+		}
+		if started {
+			pr.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = "1"
+		}
+		return pr
+	}
+	newCompletedRun := func(name string, offset time.Duration) *v1alpha3.PipelineRun {
+		pr := newRun(name, offset, true)
+		completionTime := metav1.NewTime(now)
+		pr.Status.CompletionTime = &completionTime
+		return pr
+	}
+	stop := v1alpha3.Stop
+	prodGroup := &v1alpha3.ConcurrencyPolicy{Group: "prod"}
+	cancelProdGroup := &v1alpha3.ConcurrencyPolicy{Group: "prod", CancelInProgress: true}
+
+	tests := []struct {
+		name         string
+		pipelineRun  *v1alpha3.PipelineRun
+		policy       *v1alpha3.ConcurrencyPolicy
+		others       []*v1alpha3.PipelineRun
+		wantAdmitted bool
+		wantErr      bool
+		wantPhase    v1alpha3.RunPhase
+		wantStopped  []string
+	}{{
+		name:         "without concurrency group",
+		pipelineRun:  newRun("pr", 0, false),
+		others:       []*v1alpha3.PipelineRun{newRun("running", -time.Minute, true)},
+		wantAdmitted: true,
+	}, {
+		name: "stop a PipelineRun before it was triggered",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newRun("pr", 0, false)
+			pr.Spec.Action = &stop
+			return pr
+		}(),
+		wantPhase: v1alpha3.Cancelled,
+	}, {
+		name:        "invalid concurrency group",
+		pipelineRun: newRun("pr", 0, false),
+		policy:      &v1alpha3.ConcurrencyPolicy{Group: "deploy to prod"},
+		wantErr:     true,
+	}, {
+		name:         "no other PipelineRuns in the group",
+		pipelineRun:  newRun("pr", 0, false),
+		policy:       prodGroup,
+		others:       []*v1alpha3.PipelineRun{newCompletedRun("completed", -time.Minute)},
+		wantAdmitted: true,
+	}, {
+		name:        "a PipelineRun of the group is running",
+		pipelineRun: newRun("pr", 0, false),
+		policy:      prodGroup,
+		others:      []*v1alpha3.PipelineRun{newRun("running", -time.Minute, true)},
+		wantPhase:   v1alpha3.Queued,
+	}, {
+		name:        "an earlier PipelineRun of the group is queued",
+		pipelineRun: newRun("pr", 0, false),
+		policy:      prodGroup,
+		others:      []*v1alpha3.PipelineRun{newRun("earlier", -time.Minute, false)},
+		wantPhase:   v1alpha3.Queued,
+	}, {
+		name:         "a later PipelineRun of the group is queued",
+		pipelineRun:  newRun("pr", 0, false),
+		policy:       prodGroup,
+		others:       []*v1alpha3.PipelineRun{newRun("later", time.Minute, false)},
+		wantAdmitted: true,
+	}, {
+		name:        "cancel in progress, a later PipelineRun of the group is queued",
+		pipelineRun: newRun("pr", 0, false),
+		policy:      cancelProdGroup,
+		others:      []*v1alpha3.PipelineRun{newRun("later", time.Minute, false)},
+		wantPhase:   v1alpha3.Cancelled,
+	}, {
+		name:        "cancel in progress, a PipelineRun of the group is running",
+		pipelineRun: newRun("pr", 0, false),
+		policy:      cancelProdGroup,
+		others:      []*v1alpha3.PipelineRun{newRun("running", -time.Minute, true)},
+		wantPhase:   v1alpha3.Queued,
+		wantStopped: []string{"running"},
+	}, {
+		name:         "cancel in progress, an earlier PipelineRun of the group is queued",
+		pipelineRun:  newRun("pr", 0, false),
+		policy:       cancelProdGroup,
+		others:       []*v1alpha3.PipelineRun{newRun("earlier", -time.Minute, false)},
+		wantAdmitted: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []client.Object{tt.pipelineRun.DeepCopy()}
+			for _, other := range tt.others {
+				objects = append(objects, other)
+			}
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(objects...).Build(),
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			admitted, result, err := r.admitPipelineRun(context.TODO(), tt.pipelineRun.DeepCopy(), newPipeline(tt.policy))
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantAdmitted, admitted)
+			assert.Equal(t, tt.wantPhase == v1alpha3.Queued, result.RequeueAfter > 0)
+
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.TODO(), client.ObjectKey{Namespace: "ns", Name: "pr"}, pr))
+			assert.Equal(t, tt.wantPhase, pr.Status.Phase)
+			if tt.policy != nil && !tt.wantErr {
+				assert.Equal(t, tt.policy.Group, pr.Labels[v1alpha3.PipelineRunConcurrencyGroupLabelKey])
+			}
+			if tt.wantPhase == v1alpha3.Cancelled {
+				assert.False(t, pr.Buildable())
+			}
+
+			for _, name := range tt.wantStopped {
+				stopped := &v1alpha3.PipelineRun{}
+				assert.Nil(t, r.Get(context.TODO(), client.ObjectKey{Namespace: "ns", Name: name}, stopped))
+				if assert.NotNil(t, stopped.Spec.Action) {
+					assert.Equal(t, v1alpha3.Stop, *stopped.Spec.Action)
+				}
+			}
+		})
+	}
+}
+
+func TestAdmitProjectLimits(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, corev1.AddToScheme(schema))
+
+	now := time.Now()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "ns",
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"},
+	}}
+	newProject := func(limits *v1alpha3.DevOpsProjectLimits) *v1alpha3.DevOpsProject {
+		return &v1alpha3.DevOpsProject{
+			ObjectMeta: metav1.ObjectMeta{Name: "project"},
+			Spec:       v1alpha3.DevOpsProjectSpec{Limits: limits},
+		}
+	}
+	newPipeline := func(jenkinsfile string) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+			Spec: v1alpha3.PipelineSpec{
+				Type:     v1alpha3.NoScmPipelineType,
+				Pipeline: &v1alpha3.NoScmPipeline{Jenkinsfile: jenkinsfile},
+			},
+		}
+	}
+	newRun := func(name string, offset time.Duration, started bool) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "ns",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(offset)),
+				Annotations:       map[string]string{},
+			},
+		}
+		if started {
+			pr.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = "1"
+		}
+		return pr
+	}
+	mavenJenkinsfile := "pipeline { agent { label 'maven' } }"
+
+	tests := []struct {
+		name         string
+		objects      []client.Object
+		jenkinsfile  string
+		annotations  map[string]string
+		wantAdmitted bool
+		wantPhase    v1alpha3.RunPhase
+	}{{
+		name:         "not in a DevOpsProject",
+		objects:      []client.Object{newRun("running", -time.Minute, true)},
+		wantAdmitted: true,
+	}, {
+		name:         "without limits",
+		objects:      []client.Object{namespace, newProject(nil), newRun("running", -time.Minute, true)},
+		wantAdmitted: true,
+	}, {
+		name: "fewer running PipelineRuns than the limit",
+		objects: []client.Object{namespace, newProject(&v1alpha3.DevOpsProjectLimits{MaxConcurrentPipelineRuns: 2}),
+			newRun("running", -time.Minute, true), newRun("later", time.Minute, false)},
+		wantAdmitted: true,
+	}, {
+		name: "reached the limit of running PipelineRuns",
+		objects: []client.Object{namespace, newProject(&v1alpha3.DevOpsProjectLimits{MaxConcurrentPipelineRuns: 1}),
+			newRun("running", -time.Minute, true)},
+		wantPhase: v1alpha3.Queued,
+	}, {
+		name: "an earlier PipelineRun is queued",
+		objects: []client.Object{namespace, newProject(&v1alpha3.DevOpsProjectLimits{MaxConcurrentPipelineRuns: 1}),
+			newRun("earlier", -time.Minute, false)},
+		wantPhase: v1alpha3.Queued,
+	}, {
+		name: "allowed agent label",
+		objects: []client.Object{namespace, newProject(&v1alpha3.DevOpsProjectLimits{
+			AllowedAgentLabels: []string{"base", "maven"}})},
+		jenkinsfile:  mavenJenkinsfile,
+		wantAdmitted: true,
+	}, {
+		name: "not allowed agent label",
+		objects: []client.Object{namespace, newProject(&v1alpha3.DevOpsProjectLimits{
+			AllowedAgentLabels: []string{"base"}})},
+		jenkinsfile: mavenJenkinsfile,
+		wantPhase:   v1alpha3.Cancelled,
+	}, {
+		name: "the agent exceeds the max resources",
+		objects: []client.Object{namespace, newProject(&v1alpha3.DevOpsProjectLimits{
+			MaxAgentResources: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}),
+			&corev1.PodTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "kubesphere-devops-system",
+					Name:      "maven",
+					Labels:    map[string]string{devopsproject.AgentPodTemplateLabelKey: ""},
+				},
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "maven",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+					},
+				}}}},
+			}},
+		jenkinsfile: mavenJenkinsfile,
+		wantPhase:   v1alpha3.Cancelled,
+	}, {
+		name: "not allowed container step on the Kubernetes engine",
+		objects: []client.Object{namespace, newProject(&v1alpha3.DevOpsProjectLimits{
+			AllowedAgentLabels: []string{"base"}})},
+		annotations: map[string]string{
+			v1alpha3.PipelineEngineAnnoKey:              v1alpha3.PipelineEngineKubernetes,
+			v1alpha3.PipelineJenkinsfileEditModeAnnoKey: v1alpha3.PipelineJenkinsfileEditModeJSON,
+			v1alpha3.PipelineJenkinsfileValueAnnoKey: `{"pipeline":{"stages":[{"name":"build","branches":[{"name":"",` +
+				`"steps":[{"name":"container","arguments":{"isLiteral":true,"value":"maven"},"children":[]}]}]}]}}`,
+		},
+		wantPhase: v1alpha3.Cancelled,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipelineRun := newRun("pr", 0, false)
+			pipeline := newPipeline(tt.jenkinsfile)
+			pipeline.Annotations = tt.annotations
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(append(tt.objects, pipelineRun.DeepCopy())...).Build(),
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			admitted, result, err := r.admitPipelineRun(context.TODO(), pipelineRun, pipeline)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantAdmitted, admitted)
+			assert.Equal(t, tt.wantPhase == v1alpha3.Queued, result.RequeueAfter > 0)
+
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.TODO(), client.ObjectKey{Namespace: "ns", Name: "pr"}, pr))
+			assert.Equal(t, tt.wantPhase, pr.Status.Phase)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+)
+
+// errSamePipelineRun indicates there is still a pending PipelineRun with the same parameters
+var errSamePipelineRun = errors.New("there is still a pending PipelineRun with the same parameters")
+
+// engine runs the PipelineRuns. The PipelineRuns run in Jenkins by default.
+type engine interface {
+	// trigger starts the PipelineRun, then returns the run ID
+	trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (runID string, err error)
+	// getResult returns the result of a started PipelineRun
+	getResult(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error)
+	// getNodeDetails returns the stages and steps of a started PipelineRun
+	getNodeDetails(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error)
+	// stop aborts a running PipelineRun
+	stop(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	// togglePause pauses a running PipelineRun or resumes a paused one
+	togglePause(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	// cleanup deletes the run history of a PipelineRun which is being deleted
+	cleanup(ctx context.Context, pr *v1alpha3.PipelineRun) error
+}
+
+// getEngine returns the engine which runs the PipelineRun
+func (r *Reconciler) getEngine(pr *v1alpha3.PipelineRun) engine {
+	if pr.Annotations[v1alpha3.PipelineEngineAnnoKey] == v1alpha3.PipelineEngineKubernetes {
+		option := KubernetesEngineOption{}
+		if r.KubernetesEngineOption != nil {
+			option = *r.KubernetesEngineOption
+		}
+		return &kubernetesEngine{Client: r.Client, option: option}
+	}
+	return &jenkinsEngine{jenkinsHandler: &jenkinsHandler{&r.JenkinsCore}, reconciler: r}
+}
+
+// jenkinsEngine runs the PipelineRuns in Jenkins
+type jenkinsEngine struct {
+	*jenkinsHandler
+	reconciler *Reconciler
+}
+
+var _ engine = &jenkinsEngine{}
+
+func (e *jenkinsEngine) trigger(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (runID string, err error) {
+	// get or create JenkinsCore if the PipelineRun has creator annotation
+	jenkinsCore, err := e.reconciler.getOrCreateJenkinsCore(pr.GetAnnotations())
+	if err != nil {
+		return
+	}
+	triggerHandler := &jenkinsHandler{jenkinsCore}
+	jobRun, err := triggerHandler.triggerJenkinsJob(pipeline.Namespace, pipeline.Name, &pr.Spec)
+	if err != nil {
+		return
+	}
+	// check if there is still a same PipelineRun
+	var exists bool
+	if exists, err = e.reconciler.hasSamePipelineRun(jobRun, pipeline); err == nil && exists {
+		err = errSamePipelineRun
+	}
+	runID = jobRun.ID
+	return
+}
+
+func (e *jenkinsEngine) getResult(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	return e.getPipelineRunResult(pipeline.Namespace, pipeline.Name, pr)
+}
+
+func (e *jenkinsEngine) getNodeDetails(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error) {
+	return e.getPipelineNodeDetails(pipeline.Name, pipeline.Namespace, pr)
+}
+
+func (e *jenkinsEngine) stop(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return e.stopJenkinsJob(pr)
+}
+
+func (e *jenkinsEngine) togglePause(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return e.jenkinsHandler.togglePause(pr)
+}
+
+func (e *jenkinsEngine) cleanup(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return e.deleteJenkinsJobHistory(pr)
+}
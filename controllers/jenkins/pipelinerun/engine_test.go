@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReconciler_getEngine(t *testing.T) {
+	r := &Reconciler{KubernetesEngineOption: &KubernetesEngineOption{Image: "alpine"}}
+
+	pr := &v1alpha3.PipelineRun{}
+	_, ok := r.getEngine(pr).(*jenkinsEngine)
+	assert.True(t, ok, "the PipelineRun runs in Jenkins by default")
+
+	pr.Annotations = map[string]string{v1alpha3.PipelineEngineAnnoKey: v1alpha3.PipelineEngineKubernetes}
+	kubernetes, ok := r.getEngine(pr).(*kubernetesEngine)
+	if assert.True(t, ok) {
+		assert.Equal(t, "alpine", kubernetes.option.Image)
+	}
+}
+
+func TestReconcileWithKubernetesEngine(t *testing.T) {
+	schema := newKubernetesEngineScheme(t)
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr", UID: "uid"},
+		Spec: v1alpha3.PipelineRunSpec{
+			PipelineRef: &corev1.ObjectReference{Name: "pipeline"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).
+		WithObjects(pr, newJSONPipeline(twoStagesJenkinsfile)).Build()
+	r := &Reconciler{
+		Client:   c,
+		log:      logr.New(log.NullLogSink{}),
+		recorder: record.NewFakeRecorder(100),
+	}
+	ctx := context.TODO()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "pr"}}
+
+	// trigger the PipelineRun
+	_, err := r.Reconcile(ctx, req)
+	assert.Nil(t, err)
+	triggered := &v1alpha3.PipelineRun{}
+	assert.Nil(t, c.Get(ctx, req.NamespacedName, triggered))
+	runID, _ := triggered.GetPipelineRunID()
+	assert.Equal(t, "1", runID)
+	assert.Equal(t, v1alpha3.PipelineEngineKubernetes, triggered.Annotations[v1alpha3.PipelineEngineAnnoKey])
+	assert.Equal(t, twoStagesJenkinsfile, triggered.Annotations[v1alpha3.PipelineRunJenkinsfileAnnoKey])
+
+	// run the first stage, and keep the stages as the Jenkins engine does
+	_, err = r.Reconcile(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pr-0-0"}, getPodNames(t, c))
+
+	running := &v1alpha3.PipelineRun{}
+	assert.Nil(t, c.Get(ctx, req.NamespacedName, running))
+	assert.Equal(t, v1alpha3.Running, running.Status.Phase)
+	var nodeDetails []pipelinerun.NodeDetail
+	assert.Nil(t, json.Unmarshal([]byte(running.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey]), &nodeDetails))
+	assert.Len(t, nodeDetails, 4)
+	assert.NotEmpty(t, running.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey])
+}
@@ -18,6 +18,7 @@ package pipelinerun
 
 import (
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -144,6 +145,37 @@ func (handler *jenkinsHandler) deleteJenkinsJobHistory(pipelineRun *v1alpha3.Pip
 	return
 }
 
+// togglePause pauses a running Jenkins build or resumes a paused one.
+// A paused build holds at the next step boundary, and nothing will be executed until it's resumed.
+func (handler *jenkinsHandler) togglePause(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return fmt.Errorf("unable to pause or resume PipelineRun due to not found run ID")
+	}
+
+	jobPath := getJenkinsJobPath(pipelineRun)
+	api := fmt.Sprintf("%s/%d/pause/toggle", jobPath, buildNum)
+	if _, err = handler.RequestWithoutData(http.MethodPost, api, nil, nil, http.StatusOK); err != nil {
+		err = fmt.Errorf("failed to pause or resume Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
+// stopJenkinsJob aborts a running Jenkins build
+func (handler *jenkinsHandler) stopJenkinsJob(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return fmt.Errorf("unable to stop PipelineRun due to not found run ID")
+	}
+
+	jenkinsClient := job.Client{JenkinsCore: *handler.JenkinsCore}
+	jobPath := getJenkinsJobPath(pipelineRun)
+	if err = jenkinsClient.StopJob(jobPath, buildNum); err != nil {
+		err = fmt.Errorf("failed to stop Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
 // getJenkinsJobPath returns the corresponding Jenkins job path
 // only a regular or multi-branch Pipeline supported
 func getJenkinsJobPath(run *v1alpha3.PipelineRun) (jobPath string) {
@@ -159,12 +191,21 @@ func getJenkinsJobPath(run *v1alpha3.PipelineRun) (jobPath string) {
 	}
 
 	jobPath = fmt.Sprintf("/job/%s/job/%s", ref.Namespace, ref.Name)
-	if run.Spec.SCM != nil && run.Spec.SCM.RefName != "" {
-		jobPath = fmt.Sprintf("%s/job/%s", jobPath, run.Spec.SCM.RefName)
+	if refName := getJenkinsRefName(run); refName != "" {
+		jobPath = fmt.Sprintf("%s/job/%s", jobPath, refName)
 	}
 	return
 }
 
+// getJenkinsRefName returns the SCM reference name which is the branch job name of a multi-branch Pipeline in Jenkins.
+// The SCM of a regular Pipeline only describes the webhook event which triggered it, so it's ignored.
+func getJenkinsRefName(run *v1alpha3.PipelineRun) string {
+	if run.Spec.SCM == nil || (run.Spec.PipelineSpec != nil && !run.Spec.IsMultiBranchPipeline()) {
+		return ""
+	}
+	return run.Spec.SCM.RefName
+}
+
 // getJenkinsBuildNumber returns the build number of a Jenkins job build which related with a PipelineRun
 // return a negative value if there is no valid build number
 func getJenkinsBuildNumber(pipelineRun *v1alpha3.PipelineRun) (num int) {
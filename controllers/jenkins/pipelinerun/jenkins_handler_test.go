@@ -238,6 +238,95 @@ var _ = Describe("Test deleteJenkinsJobHistory", func() {
 	})
 })
 
+var _ = Describe("Test togglePause", func() {
+	var (
+		ctrl         *gomock.Controller
+		roundTripper *mhttp.MockRoundTripper
+		jHandler     *jenkinsHandler
+		pipelineRun  *v1alpha3.PipelineRun
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		roundTripper = mhttp.NewMockRoundTripper(ctrl)
+		jHandler = &jenkinsHandler{&core.JenkinsCore{
+			URL:          "http://localhost",
+			RoundTripper: roundTripper,
+		}}
+		pipelineRun = &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "project1",
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "2",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{
+					Name: "testPipeline",
+				},
+			},
+		}
+	})
+
+	It("toggle a PipelineRun without run ID", func() {
+		err := jHandler.togglePause(&v1alpha3.PipelineRun{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("toggle a valid PipelineRun", func() {
+		expectJenkinsPost(roundTripper, "http://localhost/job/project1/job/testPipeline/2/pause/toggle", http.StatusOK)
+
+		err := jHandler.togglePause(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("toggle a PipelineRun of a multi-branch Pipeline", func() {
+		pipelineRun.Spec.SCM = &v1alpha3.SCM{RefName: "master"}
+		expectJenkinsPost(roundTripper, "http://localhost/job/project1/job/testPipeline/job/master/2/pause/toggle",
+			http.StatusOK)
+
+		err := jHandler.togglePause(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("failed to toggle a PipelineRun", func() {
+		expectJenkinsPost(roundTripper, "http://localhost/job/project1/job/testPipeline/2/pause/toggle",
+			http.StatusForbidden)
+
+		err := jHandler.togglePause(pipelineRun)
+		Expect(err).To(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+})
+
+// expectJenkinsPost makes the fake Jenkins respond to a POST request with the given status code
+func expectJenkinsPost(roundTripper *mhttp.MockRoundTripper, api string, statusCode int) {
+	requestCrumb, _ := http.NewRequest(http.MethodGet, "http://localhost/crumbIssuer/api/json", nil)
+	responseCrumb := &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		Request:    requestCrumb,
+		Body: ioutil.NopCloser(bytes.NewBufferString(`
+			{"crumbRequestField":"CrumbRequestField","crumb":"Crumb"}
+			`)),
+	}
+	roundTripper.EXPECT().
+		RoundTrip(core.NewRequestMatcher(requestCrumb)).Return(responseCrumb, nil)
+
+	request, _ := http.NewRequest(http.MethodPost, api, nil)
+	request.Header.Set("CrumbRequestField", "Crumb")
+	response := &http.Response{
+		Request:    request,
+		StatusCode: statusCode,
+		Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+	}
+	roundTripper.EXPECT().
+		RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+}
+
 func Test_getJenkinsJobPath(t *testing.T) {
 	type args struct {
 		pipelineRun *v1alpha3.PipelineRun
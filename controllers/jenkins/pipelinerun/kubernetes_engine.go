@@ -0,0 +1,641 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/devopsproject"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultStepImage is the image of the steps if there is no specific one
+	defaultStepImage = "kubesphere/builder-base:v3.2.2"
+	// defaultWorkspaceSize is the storage size of the workspace if there is no specific one
+	defaultWorkspaceSize = "1Gi"
+	// workspaceVolumeName is the name of the volume which is shared by all steps of a PipelineRun
+	workspaceVolumeName = "workspace"
+	// workspaceDir is the working directory of the steps
+	workspaceDir = "/home/jenkins/agent/workspace"
+	// workspaceSubPath is the directory of the workspace in the volume, the checkout lock is beside it
+	workspaceSubPath = "workspace"
+	// checkoutDir is where the checkout container mounts the whole volume
+	checkoutDir = "/home/jenkins/agent/checkout"
+	// checkoutContainerName is the name of the container which checks out the source
+	checkoutContainerName = "checkout"
+)
+
+// checkoutScript clones the source into the workspace once, even if the parallel branches of the first stage run it
+// together. The first one takes the lock directory, and the others wait for its result.
+// The revision of a pull request is fetched after cloning, because it's not a branch of the repository. The commit is
+// fetched directly first, then the refs of the pull request in the conventions of the git providers.
+const checkoutScript = `checkout_revision() (
+  cd ` + workspaceSubPath + ` || exit 1
+  if [ -z "$GIT_REVISION" ] || ! git fetch origin "$GIT_REVISION"; then
+    for ref in $GIT_FETCH_REFS; do git fetch origin "$ref" && break; done
+  fi
+  git checkout --detach "${GIT_REVISION:-FETCH_HEAD}"
+)
+if mkdir .checkout 2>/dev/null; then
+  if git clone $GIT_CLONE_ARGS "$GIT_URL" ` + workspaceSubPath + ` && { [ -z "$GIT_REVISION$GIT_FETCH_REFS" ] || checkout_revision; }; then
+    touch .checkout/done
+  else
+    touch .checkout/failed; exit 1
+  fi
+else
+  until [ -e .checkout/done ]; do
+    if [ -e .checkout/failed ]; then echo "failed to check out $GIT_URL"; exit 1; fi
+    sleep 1
+  done
+fi`
+
+// defaultStepRequests and defaultStepLimits are the resources of a step container if there are no specific ones
+var (
+	defaultStepRequests = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("128Mi"),
+	}
+	defaultStepLimits = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("1"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}
+)
+
+// KubernetesEngineOption provides the images and the workspace of the steps which run as Kubernetes Pods
+type KubernetesEngineOption struct {
+	// Image is the default image of the steps, it's the image of the checkout container as well
+	Image string
+	// ContainerImages maps the names of the container steps to images, such as: base, maven and nodejs.
+	// The steps in an unknown container run with the default image.
+	ContainerImages map[string]string
+	// WorkspaceStorageClass is the StorageClass of the workspace PersistentVolumeClaims, the default one is used
+	// if it's empty
+	WorkspaceStorageClass string
+	// WorkspaceSize is the storage size of the workspace PersistentVolumeClaims
+	WorkspaceSize string
+	// WorkspaceAccessMode is the access mode of the workspace PersistentVolumeClaims. The Pods of the parallel
+	// branches might be scheduled to different nodes, they need ReadWriteMany.
+	WorkspaceAccessMode corev1.PersistentVolumeAccessMode
+	// StepRequests are the resource requests of every step container, they override the default ones
+	StepRequests corev1.ResourceList
+	// StepLimits are the resource limits of every step container, they override the default ones
+	StepLimits corev1.ResourceList
+}
+
+func (o KubernetesEngineOption) getImage(container string) string {
+	if image, ok := o.ContainerImages[container]; ok && image != "" {
+		return image
+	}
+	if o.Image != "" {
+		return o.Image
+	}
+	return defaultStepImage
+}
+
+// getStepResources returns the resources of the step containers. The limits never exceed the max agent resources of
+// the DevOpsProject, and the requests never exceed the limits.
+func (o KubernetesEngineOption) getStepResources(limits *v1alpha3.DevOpsProjectLimits) corev1.ResourceRequirements {
+	resources := corev1.ResourceRequirements{
+		Requests: mergeResourceList(defaultStepRequests, o.StepRequests),
+		Limits:   mergeResourceList(defaultStepLimits, o.StepLimits),
+	}
+	if limits != nil {
+		for name, max := range limits.MaxAgentResources {
+			if limit, ok := resources.Limits[name]; !max.IsZero() && (!ok || limit.Cmp(max) > 0) {
+				resources.Limits[name] = max.DeepCopy()
+			}
+		}
+	}
+	for name, request := range resources.Requests {
+		if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			resources.Requests[name] = limit.DeepCopy()
+		}
+	}
+	return resources
+}
+
+func mergeResourceList(lists ...corev1.ResourceList) corev1.ResourceList {
+	merged := corev1.ResourceList{}
+	for _, list := range lists {
+		for name, quantity := range list {
+			merged[name] = quantity.DeepCopy()
+		}
+	}
+	return merged
+}
+
+func (o KubernetesEngineOption) getWorkspaceSize() (resource.Quantity, error) {
+	if o.WorkspaceSize == "" {
+		return resource.ParseQuantity(defaultWorkspaceSize)
+	}
+	return resource.ParseQuantity(o.WorkspaceSize)
+}
+
+func (o KubernetesEngineOption) getWorkspaceAccessMode() corev1.PersistentVolumeAccessMode {
+	if o.WorkspaceAccessMode == "" {
+		return corev1.ReadWriteOnce
+	}
+	return o.WorkspaceAccessMode
+}
+
+// kubernetesEngine runs the JSON Jenkinsfile of a Pipeline as Kubernetes Pods without Jenkins.
+// The stages run one by one, every branch of a stage runs in a Pod, and the branches of the same stage run in parallel.
+// Every step of a branch is a container of the Pod, they run in order. All Pods of a PipelineRun mount the same
+// workspace PersistentVolumeClaim, and the Pods of the first stage check out the source into it.
+// Only the sh, echo, container and dir steps are supported.
+type kubernetesEngine struct {
+	client.Client
+	option KubernetesEngineOption
+}
+
+var _ engine = &kubernetesEngine{}
+
+// trigger checks the JSON Jenkinsfile, then keeps a snapshot of it in the PipelineRun.
+// The Pods will be created when the result is retrieved.
+func (e *kubernetesEngine) trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (
+	runID string, err error) {
+	if pipeline.IsMultiBranch() {
+		err = errors.New("the kubernetes engine does not support multi-branch Pipelines")
+		return
+	}
+	if pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] != v1alpha3.PipelineJenkinsfileEditModeJSON {
+		err = errors.New("the kubernetes engine only supports the Pipelines which are edited in the JSON mode")
+		return
+	}
+	jenkinsfile := pipeline.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey]
+	if _, err = parseKubernetesStages(jenkinsfile, e.option); err != nil {
+		return
+	}
+	if runID, err = e.getNextRunID(ctx, pipeline); err != nil {
+		return
+	}
+
+	if pr.Annotations == nil {
+		pr.Annotations = make(map[string]string)
+	}
+	pr.Annotations[v1alpha3.PipelineRunJenkinsfileAnnoKey] = jenkinsfile
+	if gitURL := pr.Annotations[v1alpha3.PipelineRunGitURLAnnoKey]; gitURL != "" {
+		pr.Annotations[v1alpha3.PipelineRunCheckoutURLAnnoKey] = gitURL
+	} else if gitURL = pipeline.Annotations[v1alpha3.PipelineSCMAnnoKey]; gitURL != "" {
+		pr.Annotations[v1alpha3.PipelineRunCheckoutURLAnnoKey] = gitURL
+	}
+	return
+}
+
+// getNextRunID allocates a run ID from the counter annotation of the Pipeline. The counter is patched with the
+// resource version of the Pipeline, so a PipelineRun retries with the latest Pipeline if another one took the ID.
+func (e *kubernetesEngine) getNextRunID(ctx context.Context, pipeline *v1alpha3.Pipeline) (runID string, err error) {
+	latest := pipeline.DeepCopy()
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		var lastID int
+		if lastID, err = e.getLastRunID(ctx, latest); err != nil {
+			return
+		}
+		runID = strconv.Itoa(lastID + 1)
+
+		pipelineToPatch := latest.DeepCopy()
+		if pipelineToPatch.Annotations == nil {
+			pipelineToPatch.Annotations = make(map[string]string)
+		}
+		pipelineToPatch.Annotations[v1alpha3.PipelineLastRunIDAnnoKey] = runID
+		if err = e.Patch(ctx, pipelineToPatch, client.MergeFromWithOptions(latest, client.MergeFromWithOptimisticLock{})); apierrors.IsConflict(err) {
+			latest = &v1alpha3.Pipeline{}
+			if getErr := e.Get(ctx, client.ObjectKeyFromObject(pipeline), latest); getErr != nil {
+				return getErr
+			}
+		}
+		return
+	})
+	return
+}
+
+// getLastRunID returns the counter of the Pipeline. The Pipelines which ran before the counter was introduced
+// start from the max run ID of their PipelineRuns.
+func (e *kubernetesEngine) getLastRunID(ctx context.Context, pipeline *v1alpha3.Pipeline) (int, error) {
+	if lastID, ok := pipeline.Annotations[v1alpha3.PipelineLastRunIDAnnoKey]; ok {
+		return strconv.Atoi(lastID)
+	}
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err := e.List(ctx, pipelineRuns, client.InNamespace(pipeline.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline.Name}); err != nil {
+		return 0, err
+	}
+	maxID := 0
+	for i := range pipelineRuns.Items {
+		runID, _ := pipelineRuns.Items[i].GetPipelineRunID()
+		if id, err := strconv.Atoi(runID); err == nil && id > maxID {
+			maxID = id
+		}
+	}
+	return maxID, nil
+}
+
+// getResult moves the PipelineRun forward by creating the Pods of the next stage, then returns the result.
+func (e *kubernetesEngine) getResult(ctx context.Context, _ *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (
+	*job.PipelineRun, error) {
+	r, err := e.newRunReport(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	if r.nextStage >= 0 {
+		if err = e.createWorkspace(ctx, pr); err != nil {
+			return nil, err
+		}
+		if err = e.createParameterSecret(ctx, pr); err != nil {
+			return nil, err
+		}
+		if err = e.createStagePods(ctx, pr, r.nextStage, r.stages[r.nextStage]); err != nil {
+			return nil, err
+		}
+	}
+	return r.getResult(), nil
+}
+
+func (e *kubernetesEngine) getNodeDetails(ctx context.Context, _ *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (
+	[]pipelinerun.NodeDetail, error) {
+	r, err := e.newRunReport(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	return r.getNodeDetails(), nil
+}
+
+// stop deletes the unfinished Pods, the finished ones are kept for the node details.
+func (e *kubernetesEngine) stop(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	pods, err := e.getPods(ctx, pr)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if isPodFinished(pod) {
+			continue
+		}
+		if err = e.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// togglePause does nothing, because a paused PipelineRun holds before the next stage according to its Paused condition
+func (e *kubernetesEngine) togglePause(_ context.Context, _ *v1alpha3.PipelineRun) error {
+	return nil
+}
+
+// cleanup does nothing, because the Pods and the workspace will be deleted along with their owner PipelineRun
+func (e *kubernetesEngine) cleanup(_ context.Context, _ *v1alpha3.PipelineRun) error {
+	return nil
+}
+
+func (e *kubernetesEngine) getPods(ctx context.Context, pr *v1alpha3.PipelineRun) (pods map[string]*corev1.Pod, err error) {
+	podList := &corev1.PodList{}
+	if err = e.List(ctx, podList, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunNameLabelKey: pr.Name}); err != nil {
+		return
+	}
+	pods = make(map[string]*corev1.Pod, len(podList.Items))
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if v1.IsControlledBy(pod, pr) {
+			pods[pod.Name] = pod
+		}
+	}
+	return
+}
+
+func (e *kubernetesEngine) newRunReport(ctx context.Context, pr *v1alpha3.PipelineRun) (r *runReport, err error) {
+	var stages []kubernetesStage
+	if stages, err = parseKubernetesStages(pr.Annotations[v1alpha3.PipelineRunJenkinsfileAnnoKey], e.option); err != nil {
+		return
+	}
+	var pods map[string]*corev1.Pod
+	if pods, err = e.getPods(ctx, pr); err != nil {
+		return
+	}
+	r = newRunReport(pr, stages, pods, time.Now())
+	return
+}
+
+// createWorkspace creates the workspace PersistentVolumeClaim of the PipelineRun if it doesn't exist
+func (e *kubernetesEngine) createWorkspace(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	size, err := e.option.getWorkspaceSize()
+	if err != nil {
+		return fmt.Errorf("invalid workspace size %q: %v", e.option.WorkspaceSize, err)
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: pr.Namespace,
+			Name:      getWorkspaceName(pr),
+			Labels: map[string]string{
+				v1alpha3.PipelineNameLabelKey:    pr.Labels[v1alpha3.PipelineNameLabelKey],
+				v1alpha3.PipelineRunNameLabelKey: pr.Name,
+			},
+			OwnerReferences: []v1.OwnerReference{
+				*v1.NewControllerRef(pr, v1alpha3.GroupVersion.WithKind("PipelineRun")),
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{e.option.getWorkspaceAccessMode()},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+	if e.option.WorkspaceStorageClass != "" {
+		pvc.Spec.StorageClassName = &e.option.WorkspaceStorageClass
+	}
+	if err = e.Create(ctx, pvc); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create the workspace %s/%s: %v", pvc.Namespace, pvc.Name, err)
+	}
+	return nil
+}
+
+// createParameterSecret creates the Secret of the secret parameters of the PipelineRun if there are any, the Pods
+// refer to it instead of having the values in their specs
+func (e *kubernetesEngine) createParameterSecret(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	secretValues := getSecretParameters(pr)
+	if len(secretValues) == 0 {
+		return nil
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: pr.Namespace,
+			Name:      getParameterSecretName(pr),
+			Labels: map[string]string{
+				v1alpha3.PipelineNameLabelKey:    pr.Labels[v1alpha3.PipelineNameLabelKey],
+				v1alpha3.PipelineRunNameLabelKey: pr.Name,
+			},
+			OwnerReferences: []v1.OwnerReference{
+				*v1.NewControllerRef(pr, v1alpha3.GroupVersion.WithKind("PipelineRun")),
+			},
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: secretValues,
+	}
+	if err := e.Create(ctx, secret); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create the parameter secret %s/%s: %v", secret.Namespace, secret.Name, err)
+	}
+	return nil
+}
+
+// createStagePods creates a Pod for every branch of the stage, the existing Pods are skipped.
+// The Pods are checked against the limits of the DevOpsProject before they're created.
+func (e *kubernetesEngine) createStagePods(ctx context.Context, pr *v1alpha3.PipelineRun, stageIndex int,
+	stage kubernetesStage) error {
+	project, err := devopsproject.GetByNamespace(ctx, e.Client, pr.Namespace)
+	if err != nil {
+		return err
+	}
+	var limits *v1alpha3.DevOpsProjectLimits
+	if project != nil {
+		limits = project.Spec.Limits
+	}
+	resources := e.option.getStepResources(limits)
+
+	for branchIndex, branch := range stage.branches {
+		pod := newBranchPod(pr, stageIndex, branchIndex, branch, resources)
+		if stageIndex == 0 {
+			addCheckoutContainer(pod, pr, e.option.getImage(""), resources)
+		}
+		if err = validateBranchLimits(limits, branch, pod); err != nil {
+			return fmt.Errorf("the Pod %s/%s for stage %q violates the limits of DevOpsProject %s: %v", pod.Namespace,
+				pod.Name, stage.name, project.Name, err)
+		}
+		if err = e.Create(ctx, pod); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create Pod %s/%s for stage %q: %v", pod.Namespace, pod.Name, stage.name, err)
+		}
+	}
+	return nil
+}
+
+// validateBranchLimits returns an error if a branch violates the limits of its DevOpsProject. The names of the
+// container steps are taken as the agent labels, the steps out of any container step run with the default image.
+// The containers of a branch Pod run one by one, so each of them is checked against the max agent resources.
+func validateBranchLimits(limits *v1alpha3.DevOpsProjectLimits, branch kubernetesBranch, pod *corev1.Pod) error {
+	if limits == nil {
+		return nil
+	}
+	for _, step := range branch.steps {
+		if step.container != "" && !limits.IsAgentLabelAllowed(step.container) {
+			return fmt.Errorf("agent label %q is not allowed", step.container)
+		}
+	}
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for i := range containers {
+		resources := v1alpha3.GetAgentResources(&corev1.PodSpec{Containers: containers[i : i+1]})
+		if err := limits.ValidateAgentResources(resources); err != nil {
+			return fmt.Errorf("container %s: %v", containers[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// getBranchPodName returns the Pod name of a stage branch
+func getBranchPodName(pr *v1alpha3.PipelineRun, stageIndex, branchIndex int) string {
+	return fmt.Sprintf("%s-%d-%d", pr.Name, stageIndex, branchIndex)
+}
+
+// getWorkspaceName returns the name of the workspace PersistentVolumeClaim of a PipelineRun
+func getWorkspaceName(pr *v1alpha3.PipelineRun) string {
+	return pr.Name + "-workspace"
+}
+
+// getParameterSecretName returns the name of the Secret which keeps the secret parameters of a PipelineRun
+func getParameterSecretName(pr *v1alpha3.PipelineRun) string {
+	return pr.Name + "-parameters"
+}
+
+// getStepContainerName returns the container name of a step
+func getStepContainerName(stepIndex int) string {
+	return fmt.Sprintf("step-%d", stepIndex)
+}
+
+// newBranchPod returns a Pod which runs the steps of a stage branch one by one in the workspace of the PipelineRun.
+// All steps except the last one are init containers, so that Kubernetes runs them in order and stops at the first failure.
+func newBranchPod(pr *v1alpha3.PipelineRun, stageIndex, branchIndex int, branch kubernetesBranch,
+	resources corev1.ResourceRequirements) *corev1.Pod {
+	env := getParameterEnv(pr)
+	env = append(env, branch.env...)
+
+	containers := make([]corev1.Container, 0, len(branch.steps))
+	for i, step := range branch.steps {
+		workingDir := step.dir
+		if !path.IsAbs(workingDir) {
+			workingDir = path.Join(workspaceDir, workingDir)
+		}
+		containers = append(containers, corev1.Container{
+			Name:       getStepContainerName(i),
+			Image:      step.image,
+			Command:    []string{"sh", "-c", step.script},
+			WorkingDir: workingDir,
+			Env:        append(append([]corev1.EnvVar{}, env...), step.env...),
+			Resources:  *resources.DeepCopy(),
+			VolumeMounts: []corev1.VolumeMount{{
+				Name:      workspaceVolumeName,
+				MountPath: workspaceDir,
+				SubPath:   workspaceSubPath,
+			}},
+		})
+	}
+
+	last := len(containers) - 1
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: pr.Namespace,
+			Name:      getBranchPodName(pr, stageIndex, branchIndex),
+			Labels: map[string]string{
+				v1alpha3.PipelineNameLabelKey:    pr.Labels[v1alpha3.PipelineNameLabelKey],
+				v1alpha3.PipelineRunNameLabelKey: pr.Name,
+			},
+			OwnerReferences: []v1.OwnerReference{
+				*v1.NewControllerRef(pr, v1alpha3.GroupVersion.WithKind("PipelineRun")),
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:  corev1.RestartPolicyNever,
+			InitContainers: containers[:last],
+			Containers:     containers[last:],
+			Volumes: []corev1.Volume{{
+				Name: workspaceVolumeName,
+				VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: getWorkspaceName(pr),
+				}},
+			}},
+		},
+	}
+}
+
+// addCheckoutContainer checks out the source before the steps if the PipelineRun has a git repository
+func addCheckoutContainer(pod *corev1.Pod, pr *v1alpha3.PipelineRun, image string, resources corev1.ResourceRequirements) {
+	gitURL := pr.Annotations[v1alpha3.PipelineRunCheckoutURLAnnoKey]
+	if gitURL == "" {
+		return
+	}
+	var cloneArgs, revision, fetchRefs string
+	if scm := pr.Spec.SCM; scm != nil && scm.RefName != "" {
+		switch scm.RefType {
+		case v1alpha3.Branch, v1alpha3.Tag:
+			cloneArgs = "--branch " + scm.RefName
+		case v1alpha3.PullRequest, v1alpha3.MergeRequest:
+			revision, fetchRefs = getPullRequestRevision(pr)
+		}
+	}
+	checkout := corev1.Container{
+		Name:       checkoutContainerName,
+		Image:      image,
+		Command:    []string{"sh", "-c", checkoutScript},
+		WorkingDir: checkoutDir,
+		Resources:  *resources.DeepCopy(),
+		Env: []corev1.EnvVar{
+			{Name: "GIT_URL", Value: gitURL},
+			{Name: "GIT_CLONE_ARGS", Value: cloneArgs},
+			{Name: "GIT_REVISION", Value: revision},
+			{Name: "GIT_FETCH_REFS", Value: fetchRefs},
+		},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      workspaceVolumeName,
+			MountPath: checkoutDir,
+		}},
+	}
+	pod.Spec.InitContainers = append([]corev1.Container{checkout}, pod.Spec.InitContainers...)
+}
+
+// getPullRequestRevision returns the head commit of a pull request, and the refs which provide it on GitHub, Gitea,
+// GitLab and Bitbucket Server. The number comes from the parameter or the ref name, such as: PR-1
+func getPullRequestRevision(pr *v1alpha3.PipelineRun) (revision, fetchRefs string) {
+	_, values, _ := getParameters(pr)
+	revision = values[v1alpha3.PullRequestHeadSHAParameter]
+	number := values[v1alpha3.PullRequestNumberParameter]
+	if number == "" {
+		number = strings.TrimPrefix(strings.TrimPrefix(pr.Spec.SCM.RefName, "PR-"), "MR-")
+	}
+	if _, err := strconv.Atoi(number); err == nil {
+		fetchRefs = fmt.Sprintf("refs/pull/%[1]s/head refs/merge-requests/%[1]s/head refs/pull-requests/%[1]s/from", number)
+	}
+	return
+}
+
+// getParameterEnv returns the parameters of a PipelineRun as environment variables, including the default values.
+// The password and credentials parameters refer to the parameter Secret of the PipelineRun.
+func getParameterEnv(pr *v1alpha3.PipelineRun) (env []corev1.EnvVar) {
+	names, values, secrets := getParameters(pr)
+	for _, name := range names {
+		if !secrets[name] {
+			env = append(env, corev1.EnvVar{Name: name, Value: values[name]})
+			continue
+		}
+		env = append(env, corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: getParameterSecretName(pr)},
+				Key:                  name,
+			},
+		}})
+	}
+	return
+}
+
+// getSecretParameters returns the values of the password and credentials parameters of a PipelineRun
+func getSecretParameters(pr *v1alpha3.PipelineRun) map[string]string {
+	_, values, secrets := getParameters(pr)
+	secretValues := make(map[string]string, len(secrets))
+	for name := range secrets {
+		secretValues[name] = values[name]
+	}
+	return secretValues
+}
+
+// getParameters returns the names of the parameters in order, their values, and the names of the secret parameters
+// according to the parameter definitions of the Pipeline
+func getParameters(pr *v1alpha3.PipelineRun) (names []string, values map[string]string, secrets map[string]bool) {
+	values = make(map[string]string)
+	secrets = make(map[string]bool)
+	if pr.Spec.PipelineSpec != nil && pr.Spec.PipelineSpec.Pipeline != nil {
+		for _, param := range pr.Spec.PipelineSpec.Pipeline.Parameters {
+			names = append(names, param.Name)
+			values[param.Name] = param.DefaultValue
+			if param.IsSecret() {
+				secrets[param.Name] = true
+			}
+		}
+	}
+	for _, param := range pr.Spec.Parameters {
+		if _, ok := values[param.Name]; !ok {
+			names = append(names, param.Name)
+		}
+		values[param.Name] = param.Value
+	}
+	return
+}
+
+func isPodFinished(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
@@ -0,0 +1,550 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// twoStagesJenkinsfile has a stage with two steps, and a stage with two parallel branches
+const twoStagesJenkinsfile = `{"pipeline":{"stages":[
+{"name":"build","branches":[{"name":"default","steps":[
+  {"name":"echo","arguments":[{"key":"message","value":{"isLiteral":true,"value":"start"}}]},
+  {"name":"sh","arguments":[{"key":"script","value":{"isLiteral":true,"value":"make build"}}]}]}]},
+{"name":"test","parallel":[
+  {"name":"unit","branches":[{"name":"default","steps":[{"name":"sh","arguments":{"isLiteral":true,"value":"make test"}}]}]},
+  {"name":"lint","branches":[{"name":"default","steps":[{"name":"sh","arguments":{"isLiteral":true,"value":"make lint"}}]}]}]}]}}`
+
+func newKubernetesEngineScheme(t *testing.T) *runtime.Scheme {
+	schema := runtime.NewScheme()
+	assert.Nil(t, corev1.AddToScheme(schema))
+	assert.Nil(t, v1alpha3.AddToScheme(schema))
+	return schema
+}
+
+func newJSONPipeline(jenkinsfile string) *v1alpha3.Pipeline {
+	return &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "pipeline",
+			Annotations: map[string]string{
+				v1alpha3.PipelineEngineAnnoKey:              v1alpha3.PipelineEngineKubernetes,
+				v1alpha3.PipelineJenkinsfileEditModeAnnoKey: v1alpha3.PipelineJenkinsfileEditModeJSON,
+				v1alpha3.PipelineJenkinsfileValueAnnoKey:    jenkinsfile,
+			},
+		},
+		Spec: v1alpha3.PipelineSpec{
+			Type: v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{
+				Parameters: []v1alpha3.ParameterDefinition{{Name: "VERSION", DefaultValue: "v1"}},
+			},
+		},
+	}
+}
+
+func TestKubernetesEngine_trigger(t *testing.T) {
+	schema := newKubernetesEngineScheme(t)
+	existingRun := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "existing",
+			Labels:      map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "2"},
+		},
+	}
+
+	multiBranchPipeline := newJSONPipeline(twoStagesJenkinsfile)
+	multiBranchPipeline.Spec.Type = v1alpha3.MultiBranchPipelineType
+	rawPipeline := newJSONPipeline(twoStagesJenkinsfile)
+	rawPipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = v1alpha3.PipelineJenkinsfileEditModeRaw
+	countedPipeline := newJSONPipeline(twoStagesJenkinsfile)
+	countedPipeline.Annotations[v1alpha3.PipelineLastRunIDAnnoKey] = "5"
+	scmPipeline := newJSONPipeline(twoStagesJenkinsfile)
+	scmPipeline.Annotations[v1alpha3.PipelineSCMAnnoKey] = "https://github.com/kubesphere/ks-devops"
+
+	tests := []struct {
+		name            string
+		pipeline        *v1alpha3.Pipeline
+		wantRunID       string
+		wantCheckoutURL string
+		wantErr         bool
+	}{{
+		name:      "the run ID follows the existing PipelineRuns",
+		pipeline:  newJSONPipeline(twoStagesJenkinsfile),
+		wantRunID: "3",
+	}, {
+		name:      "the run ID follows the counter",
+		pipeline:  countedPipeline,
+		wantRunID: "6",
+	}, {
+		name:            "check out the git repository of the Pipeline",
+		pipeline:        scmPipeline,
+		wantRunID:       "3",
+		wantCheckoutURL: "https://github.com/kubesphere/ks-devops",
+	}, {
+		name:     "multi-branch Pipeline",
+		pipeline: multiBranchPipeline,
+		wantErr:  true,
+	}, {
+		name:     "the Pipeline is not edited in the JSON mode",
+		pipeline: rawPipeline,
+		wantErr:  true,
+	}, {
+		name:     "invalid Jenkinsfile",
+		pipeline: newJSONPipeline(`{"pipeline":{"stages":[]}}`),
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(existingRun.DeepCopy(), tt.pipeline.DeepCopy()).Build()
+			e := &kubernetesEngine{Client: c}
+			pipeline := &v1alpha3.Pipeline{}
+			assert.Nil(t, c.Get(context.TODO(), client.ObjectKeyFromObject(tt.pipeline), pipeline))
+			pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr"}}
+			runID, err := e.trigger(context.TODO(), pipeline, pr)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantRunID, runID)
+			if !tt.wantErr {
+				assert.Equal(t, twoStagesJenkinsfile, pr.Annotations[v1alpha3.PipelineRunJenkinsfileAnnoKey])
+				assert.Equal(t, tt.wantCheckoutURL, pr.Annotations[v1alpha3.PipelineRunCheckoutURLAnnoKey])
+				assert.Nil(t, c.Get(context.TODO(), client.ObjectKeyFromObject(tt.pipeline), pipeline))
+				assert.Equal(t, tt.wantRunID, pipeline.Annotations[v1alpha3.PipelineLastRunIDAnnoKey])
+			}
+		})
+	}
+}
+
+func TestKubernetesEngine_getNextRunID(t *testing.T) {
+	schema := newKubernetesEngineScheme(t)
+	ctx := context.TODO()
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(newJSONPipeline(twoStagesJenkinsfile)).Build()
+	e := &kubernetesEngine{Client: c}
+
+	pipeline := &v1alpha3.Pipeline{}
+	assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "pipeline"}, pipeline))
+	// the PipelineRuns which are triggered with the same stale Pipeline get different run IDs
+	var runIDs []string
+	for i := 0; i < 3; i++ {
+		runID, err := e.getNextRunID(ctx, pipeline)
+		assert.Nil(t, err)
+		runIDs = append(runIDs, runID)
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, runIDs)
+}
+
+func TestKubernetesEngine_run(t *testing.T) {
+	schema := newKubernetesEngineScheme(t)
+	ctx := context.TODO()
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+	pipelineSpec := newJSONPipeline("").Spec
+	pipelineSpec.Pipeline.Parameters = append(pipelineSpec.Pipeline.Parameters,
+		v1alpha3.ParameterDefinition{Name: "TOKEN", Type: "password"})
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "pr",
+			UID:       "uid",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+			Annotations: map[string]string{
+				v1alpha3.JenkinsPipelineRunIDAnnoKey:   "1",
+				v1alpha3.PipelineRunJenkinsfileAnnoKey: twoStagesJenkinsfile,
+				v1alpha3.PipelineRunCheckoutURLAnnoKey: "https://github.com/kubesphere/ks-devops",
+			},
+		},
+		Spec: v1alpha3.PipelineRunSpec{
+			PipelineSpec: &pipelineSpec,
+			Parameters:   []v1alpha3.Parameter{{Name: "BRANCH", Value: "main"}, {Name: "TOKEN", Value: "token"}},
+			SCM:          &v1alpha3.SCM{RefType: v1alpha3.Branch, RefName: "main"},
+		},
+		Status: v1alpha3.PipelineRunStatus{StartTime: &startTime},
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pr.DeepCopy()).Build()
+	e := &kubernetesEngine{Client: c, option: KubernetesEngineOption{Image: "alpine", WorkspaceStorageClass: "nfs"}}
+
+	// finishPod marks a Pod as finished, the containers exit with the given codes
+	finishPod := func(name string, exitCodes ...int32) {
+		pod := &corev1.Pod{}
+		assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: name}, pod))
+		pod.Status.Phase = corev1.PodSucceeded
+		pod.Status.StartTime = &startTime
+		containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for i, code := range exitCodes {
+			if code != 0 {
+				pod.Status.Phase = corev1.PodFailed
+			}
+			status := corev1.ContainerStatus{Name: containers[i].Name, State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{ExitCode: code, StartedAt: startTime, FinishedAt: metav1.Now()},
+			}}
+			if i < len(pod.Spec.InitContainers) {
+				pod.Status.InitContainerStatuses = append(pod.Status.InitContainerStatuses, status)
+			} else {
+				pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, status)
+			}
+		}
+		assert.Nil(t, c.Status().Update(ctx, pod))
+	}
+	getPods := func() map[string]*corev1.Pod {
+		pods, err := e.getPods(ctx, pr)
+		assert.Nil(t, err)
+		return pods
+	}
+
+	// the Pod of the first stage is created
+	result, err := e.getResult(ctx, nil, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Running.String(), result.State)
+	assert.Equal(t, startTime.Time, result.StartTime.Time)
+	// the workspace is shared by all Pods of the PipelineRun
+	workspace := &corev1.PersistentVolumeClaim{}
+	assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "pr-workspace"}, workspace))
+	assert.True(t, metav1.IsControlledBy(workspace, pr))
+	assert.Equal(t, "nfs", *workspace.Spec.StorageClassName)
+	assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, workspace.Spec.AccessModes)
+	assert.Equal(t, "1Gi", workspace.Spec.Resources.Requests.Storage().String())
+	assertWorkspace := func(pod *corev1.Pod) {
+		if assert.Len(t, pod.Spec.Volumes, 1) && assert.NotNil(t, pod.Spec.Volumes[0].PersistentVolumeClaim) {
+			assert.Equal(t, "pr-workspace", pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+		}
+		for _, container := range pod.Spec.Containers {
+			assert.Equal(t, []corev1.VolumeMount{{Name: workspaceVolumeName, MountPath: workspaceDir, SubPath: workspaceSubPath}},
+				container.VolumeMounts)
+		}
+	}
+
+	pods := getPods()
+	if assert.Len(t, pods, 1) && assert.NotNil(t, pods["pr-0-0"]) {
+		pod := pods["pr-0-0"]
+		assert.Equal(t, "pr", pod.Labels[v1alpha3.PipelineRunNameLabelKey])
+		assert.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+		assertWorkspace(pod)
+		if assert.Len(t, pod.Spec.InitContainers, 2) && assert.Len(t, pod.Spec.Containers, 1) {
+			// the first stage checks out the source before the steps
+			checkout := pod.Spec.InitContainers[0]
+			assert.Equal(t, checkoutContainerName, checkout.Name)
+			assert.Equal(t, "alpine", checkout.Image)
+			assert.Equal(t, []corev1.EnvVar{
+				{Name: "GIT_URL", Value: "https://github.com/kubesphere/ks-devops"},
+				{Name: "GIT_CLONE_ARGS", Value: "--branch main"},
+				{Name: "GIT_REVISION", Value: ""},
+				{Name: "GIT_FETCH_REFS", Value: ""},
+			}, checkout.Env)
+
+			// every container has the default resources
+			for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+				assert.Equal(t, "100m", container.Resources.Requests.Cpu().String())
+				assert.Equal(t, "1Gi", container.Resources.Limits.Memory().String())
+			}
+
+			assert.Equal(t, "alpine", pod.Spec.InitContainers[1].Image)
+			assert.Equal(t, []string{"sh", "-c", "make build"}, pod.Spec.Containers[0].Command)
+			assert.Equal(t, workspaceDir, pod.Spec.Containers[0].WorkingDir)
+			// the secret parameters are not in the Pod spec
+			assert.Equal(t, []corev1.EnvVar{{Name: "VERSION", Value: "v1"}, {Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "pr-parameters"}, Key: "TOKEN"},
+			}}, {Name: "BRANCH", Value: "main"}}, pod.Spec.Containers[0].Env)
+		}
+	}
+	parameterSecret := &corev1.Secret{}
+	assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "pr-parameters"}, parameterSecret))
+	assert.True(t, metav1.IsControlledBy(parameterSecret, pr))
+	assert.Equal(t, map[string]string{"TOKEN": "token"}, parameterSecret.StringData)
+
+	// nothing changes until the first stage finished
+	_, err = e.getResult(ctx, nil, pr)
+	assert.Nil(t, err)
+	assert.Len(t, getPods(), 1)
+
+	// hold before the next stage if it's paused
+	finishPod("pr-0-0", 0, 0, 0)
+	paused := pr.DeepCopy()
+	paused.Status.AddCondition(&v1alpha3.Condition{Type: v1alpha3.ConditionPaused, Status: v1alpha3.ConditionTrue})
+	result, err = e.getResult(ctx, nil, paused)
+	assert.Nil(t, err)
+	assert.Equal(t, Paused.String(), result.State)
+	assert.Len(t, getPods(), 1)
+
+	// the parallel branches of the next stage start together
+	result, err = e.getResult(ctx, nil, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Running.String(), result.State)
+	pods = getPods()
+	assert.Len(t, pods, 3)
+	for _, name := range []string{"pr-1-0", "pr-1-1"} {
+		if assert.NotNil(t, pods[name]) {
+			assertWorkspace(pods[name])
+			// the source has been checked out by the first stage
+			assert.Empty(t, pods[name].Spec.InitContainers)
+		}
+	}
+
+	// the PipelineRun fails once a branch failed
+	finishPod("pr-1-0", 0)
+	finishPod("pr-1-1", 2)
+	result, err = e.getResult(ctx, nil, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Finished.String(), result.State)
+	assert.Equal(t, Failure.String(), result.Result)
+	assert.False(t, result.EndTime.IsZero())
+
+	nodeDetails, err := e.getNodeDetails(ctx, nil, pr)
+	assert.Nil(t, err)
+	if assert.Len(t, nodeDetails, 4) {
+		build := nodeDetails[0]
+		assert.Equal(t, "build", build.DisplayName)
+		assert.Equal(t, stageNodeType, build.Type)
+		assert.Equal(t, Success.String(), build.Result)
+		assert.Equal(t, []job.Edge{{ID: "2", Type: stageNodeType}}, build.Edges)
+		if assert.Len(t, build.Steps, 2) {
+			assert.Equal(t, "Print Message", build.Steps[0].DisplayName)
+			assert.Equal(t, Finished.String(), build.Steps[0].State)
+			assert.Equal(t, "make build", build.Steps[1].DisplayDescription)
+			assert.Equal(t, Success.String(), build.Steps[1].Result)
+		}
+
+		test := nodeDetails[1]
+		assert.Equal(t, "test", test.DisplayName)
+		assert.Equal(t, Failure.String(), test.Result)
+		assert.Empty(t, test.Steps)
+		assert.Equal(t, []job.Edge{{ID: "3", Type: parallelNodeType}, {ID: "4", Type: parallelNodeType}}, test.Edges)
+
+		assert.Equal(t, "unit", nodeDetails[2].DisplayName)
+		assert.Equal(t, parallelNodeType, nodeDetails[2].Type)
+		assert.Equal(t, Success.String(), nodeDetails[2].Result)
+		assert.Equal(t, "lint", nodeDetails[3].DisplayName)
+		assert.Equal(t, "2", nodeDetails[3].FirstParent)
+		if assert.Len(t, nodeDetails[3].Steps, 1) {
+			assert.Equal(t, Failure.String(), nodeDetails[3].Steps[0].Result)
+		}
+	}
+}
+
+func TestAddCheckoutContainer(t *testing.T) {
+	newPipelineRun := func(scm *v1alpha3.SCM, params ...v1alpha3.Parameter) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				v1alpha3.PipelineRunCheckoutURLAnnoKey: "https://github.com/kubesphere/ks-devops",
+			}},
+			Spec: v1alpha3.PipelineRunSpec{SCM: scm, Parameters: params},
+		}
+	}
+	tests := []struct {
+		name string
+		pr   *v1alpha3.PipelineRun
+		want map[string]string
+	}{{
+		name: "no SCM",
+		pr:   newPipelineRun(nil),
+		want: map[string]string{"GIT_CLONE_ARGS": "", "GIT_REVISION": "", "GIT_FETCH_REFS": ""},
+	}, {
+		name: "tag",
+		pr:   newPipelineRun(&v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: "v1.0.0"}),
+		want: map[string]string{"GIT_CLONE_ARGS": "--branch v1.0.0", "GIT_REVISION": "", "GIT_FETCH_REFS": ""},
+	}, {
+		name: "pull request triggered by a webhook",
+		pr: newPipelineRun(&v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-3"},
+			v1alpha3.Parameter{Name: v1alpha3.PullRequestNumberParameter, Value: "3"},
+			v1alpha3.Parameter{Name: v1alpha3.PullRequestHeadSHAParameter, Value: "abc"}),
+		want: map[string]string{"GIT_CLONE_ARGS": "", "GIT_REVISION": "abc",
+			"GIT_FETCH_REFS": "refs/pull/3/head refs/merge-requests/3/head refs/pull-requests/3/from"},
+	}, {
+		name: "pull request without parameters",
+		pr:   newPipelineRun(&v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-5"}),
+		want: map[string]string{"GIT_CLONE_ARGS": "", "GIT_REVISION": "",
+			"GIT_FETCH_REFS": "refs/pull/5/head refs/merge-requests/5/head refs/pull-requests/5/from"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{}
+			addCheckoutContainer(pod, tt.pr, "alpine", corev1.ResourceRequirements{})
+			if assert.Len(t, pod.Spec.InitContainers, 1) {
+				env := map[string]string{}
+				for _, item := range pod.Spec.InitContainers[0].Env {
+					env[item.Name] = item.Value
+				}
+				delete(env, "GIT_URL")
+				assert.Equal(t, tt.want, env)
+			}
+		})
+	}
+}
+
+func TestKubernetesEngineOption_getStepResources(t *testing.T) {
+	resources := KubernetesEngineOption{}.getStepResources(nil)
+	assert.Equal(t, corev1.ResourceRequirements{Requests: defaultStepRequests, Limits: defaultStepLimits}, resources)
+
+	option := KubernetesEngineOption{
+		StepRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		StepLimits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+	}
+	resources = option.getStepResources(nil)
+	assert.Equal(t, "2", resources.Requests.Cpu().String())
+	assert.Equal(t, "128Mi", resources.Requests.Memory().String())
+	assert.Equal(t, "4", resources.Limits.Cpu().String())
+
+	// the limits are capped by the DevOpsProject, so are the requests
+	resources = option.getStepResources(&v1alpha3.DevOpsProjectLimits{MaxAgentResources: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("500m"),
+		corev1.ResourceMemory: resource.MustParse("2Gi"),
+	}})
+	assert.Equal(t, "500m", resources.Requests.Cpu().String())
+	assert.Equal(t, "500m", resources.Limits.Cpu().String())
+	assert.Equal(t, "1Gi", resources.Limits.Memory().String())
+}
+
+func TestKubernetesEngine_createStagePods(t *testing.T) {
+	schema := newKubernetesEngineScheme(t)
+	ctx := context.TODO()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "ns",
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"},
+	}}
+	newProject := func(limits *v1alpha3.DevOpsProjectLimits) *v1alpha3.DevOpsProject {
+		return &v1alpha3.DevOpsProject{
+			ObjectMeta: metav1.ObjectMeta{Name: "project"},
+			Spec:       v1alpha3.DevOpsProjectSpec{Limits: limits},
+		}
+	}
+	pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr"}}
+	stages, err := parseKubernetesStages(`{"pipeline":{"stages":[{"name":"build","branches":[{"name":"default","steps":[
+  {"name":"container","arguments":{"isLiteral":true,"value":"go"},"children":[
+    {"name":"sh","arguments":{"isLiteral":true,"value":"go build"}}]}]}]}]}}`, KubernetesEngineOption{})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		limits  *v1alpha3.DevOpsProjectLimits
+		option  KubernetesEngineOption
+		wantErr string
+	}{{
+		name:   "within the limits",
+		limits: &v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"go"}},
+	}, {
+		name:    "the container is not allowed",
+		limits:  &v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"maven"}},
+		wantErr: `the Pod ns/pr-1-0 for stage "build" violates the limits of DevOpsProject project: agent label "go" is not allowed`,
+	}, {
+		name: "the limits are capped by the DevOpsProject",
+		limits: &v1alpha3.DevOpsProjectLimits{MaxAgentResources: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("500m"),
+		}},
+		option: KubernetesEngineOption{StepLimits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(namespace, newProject(tt.limits)).Build()
+			e := &kubernetesEngine{Client: c, option: tt.option}
+			err := e.createStagePods(ctx, pr, 1, stages[0])
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Empty(t, getPodNames(t, c))
+				return
+			}
+			assert.Nil(t, err)
+			pod := &corev1.Pod{}
+			assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "pr-1-0"}, pod))
+			if tt.limits.MaxAgentResources != nil {
+				assert.Equal(t, "500m", pod.Spec.Containers[0].Resources.Limits.Cpu().String())
+			}
+		})
+	}
+}
+
+func TestValidateBranchLimits(t *testing.T) {
+	branch := kubernetesBranch{steps: []kubernetesStep{{container: "maven"}, {}}}
+	pod := newBranchPod(&v1alpha3.PipelineRun{}, 0, 0, branch, corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+	})
+
+	assert.Nil(t, validateBranchLimits(nil, branch, pod))
+	assert.Nil(t, validateBranchLimits(&v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"maven"}}, branch, pod))
+	assert.EqualError(t, validateBranchLimits(&v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"base"}},
+		branch, pod), `agent label "maven" is not allowed`)
+	// the containers run one by one, so the resources are not summed up
+	assert.Nil(t, validateBranchLimits(&v1alpha3.DevOpsProjectLimits{MaxAgentResources: corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("2"),
+	}}, branch, pod))
+	assert.EqualError(t, validateBranchLimits(&v1alpha3.DevOpsProjectLimits{MaxAgentResources: corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("1"),
+	}}, branch, pod), "container step-0: cpu 2 exceeds the limit 1")
+}
+
+func TestKubernetesEngine_stop(t *testing.T) {
+	schema := newKubernetesEngineScheme(t)
+	ctx := context.TODO()
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "pr",
+			UID:       "uid",
+			Annotations: map[string]string{
+				v1alpha3.JenkinsPipelineRunIDAnnoKey:   "1",
+				v1alpha3.PipelineRunJenkinsfileAnnoKey: twoStagesJenkinsfile,
+			},
+		},
+	}
+	stages, err := parseKubernetesStages(twoStagesJenkinsfile, KubernetesEngineOption{})
+	assert.Nil(t, err)
+	finished := newBranchPod(pr, 0, 0, stages[0].branches[0], corev1.ResourceRequirements{})
+	finished.Status.Phase = corev1.PodSucceeded
+	running := newBranchPod(pr, 1, 0, stages[1].branches[0], corev1.ResourceRequirements{})
+	running.Status.Phase = corev1.PodRunning
+	other := newBranchPod(pr, 1, 1, stages[1].branches[1], corev1.ResourceRequirements{})
+	other.OwnerReferences = nil
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pr.DeepCopy(), finished, running, other).Build()
+	e := &kubernetesEngine{Client: c}
+	assert.Nil(t, e.stop(ctx, pr))
+
+	assert.ElementsMatch(t, []string{"pr-0-0", "pr-1-1"}, getPodNames(t, c))
+
+	// the PipelineRun is aborted once it's stopped
+	pr.Status.AddCondition(&v1alpha3.Condition{Type: v1alpha3.ConditionStopped, Status: v1alpha3.ConditionTrue})
+	result, err := e.getResult(ctx, nil, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Finished.String(), result.State)
+	assert.Equal(t, Aborted.String(), result.Result)
+	assert.Len(t, getPodNames(t, c), 2, "no more Pods should be created after stopped")
+
+	nodeDetails, err := e.getNodeDetails(ctx, nil, pr)
+	assert.Nil(t, err)
+	if assert.Len(t, nodeDetails, 4) {
+		assert.Equal(t, Aborted.String(), nodeDetails[2].Result)
+		assert.Equal(t, NotBuiltState.String(), nodeDetails[2].Steps[0].State)
+	}
+}
+
+func getPodNames(t *testing.T, c client.Client) (names []string) {
+	pods := &corev1.PodList{}
+	assert.Nil(t, c.List(context.TODO(), pods))
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// jsonJenkinsfile is the Jenkinsfile which is edited in the JSON mode
+type jsonJenkinsfile struct {
+	Pipeline struct {
+		Stages      []jsonStage        `json:"stages"`
+		Environment []jsonStepArgument `json:"environment,omitempty"`
+	} `json:"pipeline"`
+}
+
+// jsonStage is a stage of the JSON Jenkinsfile, it has either branches or parallel stages
+type jsonStage struct {
+	Name        string             `json:"name"`
+	Branches    []jsonStageBranch  `json:"branches,omitempty"`
+	Parallel    []jsonStage        `json:"parallel,omitempty"`
+	Environment []jsonStepArgument `json:"environment,omitempty"`
+}
+
+// jsonStageBranch contains the steps of a stage
+type jsonStageBranch struct {
+	Name  string     `json:"name"`
+	Steps []jsonStep `json:"steps"`
+}
+
+// jsonStep is a step of the JSON Jenkinsfile.
+// The arguments could be a list of named arguments, or a single value of the default argument.
+type jsonStep struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Children  []jsonStep      `json:"children,omitempty"`
+}
+
+type jsonStepArgument struct {
+	Key   string            `json:"key"`
+	Value jsonArgumentValue `json:"value"`
+}
+
+type jsonArgumentValue struct {
+	IsLiteral bool        `json:"isLiteral"`
+	Value     interface{} `json:"value"`
+}
+
+// getArgument returns the literal value of an argument.
+// The single value is taken as the argument, because it's the default argument of the step.
+func (s *jsonStep) getArgument(key string) (value string, err error) {
+	var arg *jsonArgumentValue
+	var args []jsonStepArgument
+	if json.Unmarshal(s.Arguments, &args) == nil {
+		for i := range args {
+			if args[i].Key == key {
+				arg = &args[i].Value
+				break
+			}
+		}
+	} else {
+		single := &jsonArgumentValue{}
+		if json.Unmarshal(s.Arguments, single) == nil {
+			arg = single
+		}
+	}
+
+	switch {
+	case arg == nil || arg.Value == nil:
+		err = fmt.Errorf("the argument %q of step %q is required", key, s.Name)
+	case !arg.IsLiteral:
+		err = fmt.Errorf("the non-literal argument %q of step %q is not supported", key, s.Name)
+	default:
+		value = fmt.Sprint(arg.Value)
+	}
+	return
+}
+
+// kubernetesStage is a stage which runs as Pods, every branch runs in a Pod
+type kubernetesStage struct {
+	name     string
+	branches []kubernetesBranch
+}
+
+// kubernetesBranch is a branch of a stage, its steps run in order
+type kubernetesBranch struct {
+	name  string
+	env   []corev1.EnvVar
+	steps []kubernetesStep
+}
+
+// kubernetesStep is a step which runs as a container
+type kubernetesStep struct {
+	displayName string
+	description string
+	// container is the name of the container step which the step is in, it's empty if the step runs with the
+	// default image
+	container string
+	image     string
+	dir       string
+	script    string
+	env       []corev1.EnvVar
+}
+
+// parseKubernetesStages converts the JSON Jenkinsfile into the stages which could run as Pods
+func parseKubernetesStages(jenkinsfile string, option KubernetesEngineOption) (stages []kubernetesStage, err error) {
+	if jenkinsfile == "" {
+		err = errors.New("the JSON Jenkinsfile is empty")
+		return
+	}
+	j := &jsonJenkinsfile{}
+	if err = json.Unmarshal([]byte(jenkinsfile), j); err != nil {
+		err = fmt.Errorf("invalid JSON Jenkinsfile: %v", err)
+		return
+	}
+	if len(j.Pipeline.Stages) == 0 {
+		err = errors.New("there is no stage in the JSON Jenkinsfile")
+		return
+	}
+
+	var env []corev1.EnvVar
+	if env, err = convertEnvironment(j.Pipeline.Environment, nil); err != nil {
+		return
+	}
+	for _, s := range j.Pipeline.Stages {
+		var stage kubernetesStage
+		if stage, err = convertStage(s, env, option); err != nil {
+			return
+		}
+		stages = append(stages, stage)
+	}
+	return
+}
+
+func convertStage(s jsonStage, env []corev1.EnvVar, option KubernetesEngineOption) (stage kubernetesStage, err error) {
+	stage.name = s.Name
+	if env, err = convertEnvironment(s.Environment, env); err != nil {
+		return
+	}
+
+	if len(s.Parallel) > 0 {
+		// every parallel stage runs as a branch
+		for _, parallel := range s.Parallel {
+			if len(parallel.Parallel) > 0 {
+				err = fmt.Errorf("the nested parallel stages of stage %q are not supported", s.Name)
+				return
+			}
+			var branchEnv []corev1.EnvVar
+			if branchEnv, err = convertEnvironment(parallel.Environment, env); err != nil {
+				return
+			}
+			branch := kubernetesBranch{name: parallel.Name, env: branchEnv}
+			for _, b := range parallel.Branches {
+				var steps []kubernetesStep
+				if steps, err = convertSteps(b.Steps, "", "", option); err != nil {
+					return
+				}
+				branch.steps = append(branch.steps, steps...)
+			}
+			stage.branches = append(stage.branches, branch)
+		}
+	} else {
+		for _, b := range s.Branches {
+			branch := kubernetesBranch{name: b.Name, env: env}
+			if branch.steps, err = convertSteps(b.Steps, "", "", option); err != nil {
+				return
+			}
+			stage.branches = append(stage.branches, branch)
+		}
+	}
+
+	if len(stage.branches) == 0 {
+		err = fmt.Errorf("there is no step in stage %q", s.Name)
+	}
+	for _, branch := range stage.branches {
+		if len(branch.steps) == 0 {
+			err = fmt.Errorf("there is no step in branch %q of stage %q", branch.name, s.Name)
+			break
+		}
+	}
+	return
+}
+
+// convertSteps converts the steps into containers, the container and dir steps only change the image and working
+// directory of their children
+func convertSteps(steps []jsonStep, container, dir string, option KubernetesEngineOption) (result []kubernetesStep, err error) {
+	image := option.getImage(container)
+	for i := range steps {
+		step := &steps[i]
+		var (
+			value    string
+			children []kubernetesStep
+		)
+		switch step.Name {
+		case "sh":
+			if value, err = step.getArgument("script"); err == nil {
+				result = append(result, kubernetesStep{
+					displayName: "Shell Script",
+					description: value,
+					container:   container,
+					image:       image,
+					dir:         dir,
+					script:      value,
+				})
+			}
+		case "echo":
+			if value, err = step.getArgument("message"); err == nil {
+				result = append(result, kubernetesStep{
+					displayName: "Print Message",
+					description: value,
+					container:   container,
+					image:       image,
+					dir:         dir,
+					script:      `echo "$STEP_MESSAGE"`,
+					env:         []corev1.EnvVar{{Name: "STEP_MESSAGE", Value: value}},
+				})
+			}
+		case "container":
+			if value, err = step.getArgument("name"); err == nil {
+				children, err = convertSteps(step.Children, value, dir, option)
+				result = append(result, children...)
+			}
+		case "dir":
+			if value, err = step.getArgument("path"); err == nil {
+				if !path.IsAbs(value) {
+					value = path.Join(dir, value)
+				}
+				children, err = convertSteps(step.Children, container, value, option)
+				result = append(result, children...)
+			}
+		default:
+			err = fmt.Errorf("the step %q is not supported by the kubernetes engine", step.Name)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// convertEnvironment appends the literal environment variables to the inherited ones
+func convertEnvironment(environment []jsonStepArgument, inherited []corev1.EnvVar) (env []corev1.EnvVar, err error) {
+	env = append(env, inherited...)
+	for _, item := range environment {
+		if !item.Value.IsLiteral {
+			err = fmt.Errorf("the non-literal environment variable %q is not supported", item.Key)
+			return
+		}
+		env = append(env, corev1.EnvVar{Name: item.Key, Value: fmt.Sprint(item.Value.Value)})
+	}
+	return
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseKubernetesStages(t *testing.T) {
+	option := KubernetesEngineOption{
+		Image:           "alpine",
+		ContainerImages: map[string]string{"maven": "maven:3"},
+	}
+
+	tests := []struct {
+		name        string
+		jenkinsfile string
+		want        []kubernetesStage
+		wantErr     string
+	}{{
+		name:    "empty Jenkinsfile",
+		wantErr: "the JSON Jenkinsfile is empty",
+	}, {
+		name:        "invalid JSON",
+		jenkinsfile: "pipeline {}",
+		wantErr:     "invalid JSON Jenkinsfile",
+	}, {
+		name:        "no stages",
+		jenkinsfile: `{"pipeline":{"stages":[]}}`,
+		wantErr:     "there is no stage in the JSON Jenkinsfile",
+	}, {
+		name:        "stage without steps",
+		jenkinsfile: `{"pipeline":{"stages":[{"name":"build","branches":[{"name":"default","steps":[]}]}]}}`,
+		wantErr:     `there is no step in branch "default" of stage "build"`,
+	}, {
+		name: "unsupported step",
+		jenkinsfile: `{"pipeline":{"stages":[{"name":"build","branches":[{"name":"default","steps":[
+{"name":"input","arguments":[{"key":"message","value":{"isLiteral":true,"value":"ok?"}}]}]}]}]}}`,
+		wantErr: `the step "input" is not supported by the kubernetes engine`,
+	}, {
+		name: "non-literal argument",
+		jenkinsfile: `{"pipeline":{"stages":[{"name":"build","branches":[{"name":"default","steps":[
+{"name":"sh","arguments":[{"key":"script","value":{"isLiteral":false,"value":"${script}"}}]}]}]}]}}`,
+		wantErr: `the non-literal argument "script" of step "sh" is not supported`,
+	}, {
+		name: "missing argument",
+		jenkinsfile: `{"pipeline":{"stages":[{"name":"build","branches":[{"name":"default","steps":[
+{"name":"echo","arguments":[]}]}]}]}}`,
+		wantErr: `the argument "message" of step "echo" is required`,
+	}, {
+		name: "steps in containers and directories",
+		jenkinsfile: `{"pipeline":{"environment":[{"key":"GLOBAL","value":{"isLiteral":true,"value":"1"}}],
+"stages":[{"name":"build","environment":[{"key":"STAGE","value":{"isLiteral":true,"value":"2"}}],
+"branches":[{"name":"default","steps":[
+{"name":"echo","arguments":[{"key":"message","value":{"isLiteral":true,"value":"hello"}}]},
+{"name":"container","arguments":{"isLiteral":true,"value":"maven"},"children":[
+  {"name":"dir","arguments":[{"key":"path","value":{"isLiteral":true,"value":"app"}}],"children":[
+    {"name":"sh","arguments":[{"key":"script","value":{"isLiteral":true,"value":"mvn package"}}]}]}]},
+{"name":"container","arguments":{"isLiteral":true,"value":"unknown"},"children":[
+  {"name":"sh","arguments":{"isLiteral":true,"value":"ls"}}]}
+]}]}]}}`,
+		want: []kubernetesStage{{
+			name: "build",
+			branches: []kubernetesBranch{{
+				name: "default",
+				env:  []corev1.EnvVar{{Name: "GLOBAL", Value: "1"}, {Name: "STAGE", Value: "2"}},
+				steps: []kubernetesStep{{
+					displayName: "Print Message",
+					description: "hello",
+					image:       "alpine",
+					script:      `echo "$STEP_MESSAGE"`,
+					env:         []corev1.EnvVar{{Name: "STEP_MESSAGE", Value: "hello"}},
+				}, {
+					displayName: "Shell Script",
+					description: "mvn package",
+					container:   "maven",
+					image:       "maven:3",
+					dir:         "app",
+					script:      "mvn package",
+				}, {
+					displayName: "Shell Script",
+					description: "ls",
+					container:   "unknown",
+					image:       "alpine",
+					script:      "ls",
+				}},
+			}},
+		}},
+	}, {
+		name: "parallel stages",
+		jenkinsfile: `{"pipeline":{"stages":[{"name":"test","parallel":[
+{"name":"unit","branches":[{"name":"default","steps":[{"name":"sh","arguments":{"isLiteral":true,"value":"make test"}}]}]},
+{"name":"lint","environment":[{"key":"STRICT","value":{"isLiteral":true,"value":"true"}}],
+ "branches":[{"name":"default","steps":[{"name":"sh","arguments":{"isLiteral":true,"value":"make lint"}}]}]}]}]}}`,
+		want: []kubernetesStage{{
+			name: "test",
+			branches: []kubernetesBranch{{
+				name: "unit",
+				steps: []kubernetesStep{{
+					displayName: "Shell Script",
+					description: "make test",
+					image:       "alpine",
+					script:      "make test",
+				}},
+			}, {
+				name: "lint",
+				env:  []corev1.EnvVar{{Name: "STRICT", Value: "true"}},
+				steps: []kubernetesStep{{
+					displayName: "Shell Script",
+					description: "make lint",
+					image:       "alpine",
+					script:      "make lint",
+				}},
+			}},
+		}},
+	}, {
+		name: "nested parallel stages",
+		jenkinsfile: `{"pipeline":{"stages":[{"name":"test","parallel":[
+{"name":"unit","parallel":[{"name":"inner"}]}]}]}}`,
+		wantErr: `the nested parallel stages of stage "test" are not supported`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseKubernetesStages(tt.jenkinsfile, option)
+			if tt.wantErr != "" {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKubernetesEngineOption_getImage(t *testing.T) {
+	assert.Equal(t, defaultStepImage, KubernetesEngineOption{}.getImage("base"))
+	option := KubernetesEngineOption{Image: "alpine", ContainerImages: map[string]string{"go": "golang"}}
+	assert.Equal(t, "golang", option.getImage("go"))
+	assert.Equal(t, "alpine", option.getImage("base"))
+}
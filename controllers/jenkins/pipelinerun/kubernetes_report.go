@@ -0,0 +1,335 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	corev1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+)
+
+const (
+	// stageNodeType is the node type of a stage in Jenkins BlueOcean
+	stageNodeType = "STAGE"
+	// parallelNodeType is the node type of a parallel branch in Jenkins BlueOcean
+	parallelNodeType = "PARALLEL"
+)
+
+// runReport describes a PipelineRun which runs as Pods in the same format as Jenkins BlueOcean
+type runReport struct {
+	pr     *v1alpha3.PipelineRun
+	stages []kubernetesStage
+	pods   map[string]*corev1.Pod
+	now    time.Time
+
+	state  string
+	result string
+	// nextStage is the index of the stage whose Pods should be created, it's negative if there is no such stage
+	nextStage int
+}
+
+func newRunReport(pr *v1alpha3.PipelineRun, stages []kubernetesStage, pods map[string]*corev1.Pod, now time.Time) *runReport {
+	r := &runReport{
+		pr:        pr,
+		stages:    stages,
+		pods:      pods,
+		now:       now,
+		state:     Running.String(),
+		result:    Unknown.String(),
+		nextStage: -1,
+	}
+
+	for i := range stages {
+		state, result, missing := r.getStageStatus(i)
+		if state == Finished.String() && result == Success.String() {
+			continue
+		}
+
+		switch {
+		case state == Finished.String():
+			r.state, r.result = Finished.String(), result
+		case pr.Status.IsStopped():
+			r.state, r.result = Finished.String(), Aborted.String()
+		case state == "" && pr.Status.IsPaused():
+			// hold before the stage until it's resumed
+			r.state = Paused.String()
+		case missing:
+			r.nextStage = i
+		}
+		return r
+	}
+	r.state, r.result = Finished.String(), Success.String()
+	return r
+}
+
+// getStageStatus returns the status of a stage according to the Pods of its branches.
+// The state is empty if there is no Pod of the stage.
+func (r *runReport) getStageStatus(stageIndex int) (state, result string, missing bool) {
+	var started, failed bool
+	finished := 0
+	branches := r.stages[stageIndex].branches
+	for j := range branches {
+		pod := r.pods[getBranchPodName(r.pr, stageIndex, j)]
+		if pod == nil {
+			missing = true
+			continue
+		}
+		started = true
+		if podState, podResult := getPodStatus(pod); podState == Finished.String() {
+			finished++
+			failed = failed || podResult != Success.String()
+		}
+	}
+
+	switch {
+	case !started:
+	case finished == len(branches):
+		state, result = Finished.String(), Success.String()
+		if failed {
+			result = Failure.String()
+		}
+	default:
+		state, result = Running.String(), Unknown.String()
+	}
+	return
+}
+
+// getResult returns the result of the PipelineRun
+func (r *runReport) getResult() *job.PipelineRun {
+	run := &job.PipelineRun{}
+	run.ID, _ = r.pr.GetPipelineRunID()
+	run.Pipeline = r.pr.Labels[v1alpha3.PipelineNameLabelKey]
+	run.State = r.state
+	run.Result = r.result
+
+	startTime := r.pr.CreationTimestamp.Time
+	if r.pr.Status.StartTime != nil {
+		startTime = r.pr.Status.StartTime.Time
+	}
+	run.StartTime = job.Time{Time: startTime}
+	run.EnQueueTime = job.Time{Time: startTime}
+
+	endTime := r.now
+	if r.state == Finished.String() {
+		if r.result != Aborted.String() {
+			if lastFinishedAt := r.getLastFinishedAt(); !lastFinishedAt.IsZero() {
+				endTime = lastFinishedAt
+			}
+		}
+		run.EndTime = job.Time{Time: endTime}
+	}
+	duration := endTime.Sub(startTime).Milliseconds()
+	run.DurationInMillis = &duration
+	return run
+}
+
+// getLastFinishedAt returns the time when the last step finished
+func (r *runReport) getLastFinishedAt() (lastFinishedAt time.Time) {
+	for _, pod := range r.pods {
+		if finishedAt := getPodFinishedAt(pod); finishedAt.After(lastFinishedAt) {
+			lastFinishedAt = finishedAt
+		}
+	}
+	return
+}
+
+// getNodeDetails returns the stages and steps like Jenkins BlueOcean does.
+// A stage with parallel branches has a stage node without steps, and a parallel node for every branch.
+func (r *runReport) getNodeDetails() []pipelinerun.NodeDetail {
+	id := 0
+	nextID := func() string {
+		id++
+		return strconv.Itoa(id)
+	}
+
+	// allocate the node IDs first, then the nodes are able to point to the next ones
+	stageIDs := make([]string, len(r.stages))
+	branchIDs := make([][]string, len(r.stages))
+	for i, stage := range r.stages {
+		stageIDs[i] = nextID()
+		if len(stage.branches) > 1 {
+			for range stage.branches {
+				branchIDs[i] = append(branchIDs[i], nextID())
+			}
+		}
+	}
+
+	nodeDetails := make([]pipelinerun.NodeDetail, 0, len(r.stages))
+	for i, stage := range r.stages {
+		var edges []job.Edge
+		if i+1 < len(r.stages) {
+			edges = []job.Edge{{ID: stageIDs[i+1], Type: stageNodeType}}
+		}
+		var firstParent string
+		if i > 0 {
+			firstParent = stageIDs[i-1]
+		}
+
+		if len(stage.branches) == 1 {
+			nodeDetail := r.getBranchNodeDetail(i, 0, nextID)
+			nodeDetail.ID = stageIDs[i]
+			nodeDetail.DisplayName = stage.name
+			nodeDetail.Type = stageNodeType
+			nodeDetail.Edges = edges
+			nodeDetail.FirstParent = firstParent
+			nodeDetails = append(nodeDetails, nodeDetail)
+			continue
+		}
+
+		stageNode := pipelinerun.NodeDetail{Node: job.Node{
+			ID:          stageIDs[i],
+			DisplayName: stage.name,
+			Type:        stageNodeType,
+			FirstParent: firstParent,
+		}}
+		stageNode.State, stageNode.Result, _ = r.getStageStatus(i)
+		stageNode.State, stageNode.Result = r.abortIfNeeded(stageNode.State, stageNode.Result)
+		branchNodes := make([]pipelinerun.NodeDetail, 0, len(stage.branches))
+		for j := range stage.branches {
+			branchNode := r.getBranchNodeDetail(i, j, nextID)
+			branchNode.ID = branchIDs[i][j]
+			branchNode.Type = parallelNodeType
+			branchNode.Edges = edges
+			branchNode.FirstParent = stageIDs[i]
+			branchNodes = append(branchNodes, branchNode)
+
+			stageNode.Edges = append(stageNode.Edges, job.Edge{ID: branchNode.ID, Type: parallelNodeType})
+			if !branchNode.StartTime.IsZero() && (stageNode.StartTime.IsZero() || branchNode.StartTime.Before(stageNode.StartTime.Time)) {
+				stageNode.StartTime = branchNode.StartTime
+			}
+			if branchNode.DurationInMillis > stageNode.DurationInMillis {
+				stageNode.DurationInMillis = branchNode.DurationInMillis
+			}
+		}
+		nodeDetails = append(nodeDetails, stageNode)
+		nodeDetails = append(nodeDetails, branchNodes...)
+	}
+	return nodeDetails
+}
+
+// getBranchNodeDetail returns the node of a stage branch, and the steps which are the containers of its Pod
+func (r *runReport) getBranchNodeDetail(stageIndex, branchIndex int, nextID func() string) pipelinerun.NodeDetail {
+	branch := r.stages[stageIndex].branches[branchIndex]
+	pod := r.pods[getBranchPodName(r.pr, stageIndex, branchIndex)]
+
+	nodeDetail := pipelinerun.NodeDetail{
+		Node:  job.Node{DisplayName: branch.name},
+		Steps: make([]pipelinerun.Step, 0, len(branch.steps)),
+	}
+	if pod != nil {
+		nodeDetail.State, nodeDetail.Result = getPodStatus(pod)
+		if pod.Status.StartTime != nil {
+			nodeDetail.StartTime = job.Time{Time: pod.Status.StartTime.Time}
+			endTime := r.now
+			if nodeDetail.State == Finished.String() {
+				endTime = getPodFinishedAt(pod)
+			}
+			nodeDetail.DurationInMillis = endTime.Sub(pod.Status.StartTime.Time).Milliseconds()
+		}
+	}
+	nodeDetail.State, nodeDetail.Result = r.abortIfNeeded(nodeDetail.State, nodeDetail.Result)
+
+	for i, step := range branch.steps {
+		jobStep := job.Step{
+			ID:                 nextID(),
+			DisplayName:        step.displayName,
+			DisplayDescription: step.description,
+			Type:               "STEP",
+		}
+		if pod != nil {
+			var startTime time.Time
+			jobStep.State, jobStep.Result, startTime, jobStep.DurationInMillis = getContainerStatus(pod,
+				getStepContainerName(i), r.now)
+			if !startTime.IsZero() {
+				jobStep.StartTime = job.Time{Time: startTime}
+			}
+		}
+		if jobStep.State != Finished.String() && nodeDetail.State == Finished.String() {
+			// the steps after a failed one never run
+			jobStep.State, jobStep.Result = NotBuiltState.String(), NotBuiltResult.String()
+		}
+		nodeDetail.Steps = append(nodeDetail.Steps, pipelinerun.Step{Step: jobStep})
+	}
+	return nodeDetail
+}
+
+// abortIfNeeded marks the unfinished nodes as aborted if the PipelineRun was aborted
+func (r *runReport) abortIfNeeded(state, result string) (string, string) {
+	if r.result == Aborted.String() && state != Finished.String() {
+		return Finished.String(), Aborted.String()
+	}
+	return state, result
+}
+
+// getPodStatus returns the state and result of a Pod in the format of Jenkins BlueOcean
+func getPodStatus(pod *corev1.Pod) (state, result string) {
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return Finished.String(), Success.String()
+	case corev1.PodFailed:
+		return Finished.String(), Failure.String()
+	default:
+		return Running.String(), Unknown.String()
+	}
+}
+
+// getPodFinishedAt returns the time when the last container of a Pod finished
+func getPodFinishedAt(pod *corev1.Pod) (finishedAt time.Time) {
+	for _, status := range getAllContainerStatuses(pod) {
+		if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.After(finishedAt) {
+			finishedAt = terminated.FinishedAt.Time
+		}
+	}
+	return
+}
+
+// getContainerStatus returns the status of a step container in the format of Jenkins BlueOcean
+func getContainerStatus(pod *corev1.Pod, name string, now time.Time) (state, result string, startTime time.Time,
+	durationInMillis int64) {
+	state = Queued.String()
+	for _, status := range getAllContainerStatuses(pod) {
+		if status.Name != name {
+			continue
+		}
+		switch {
+		case status.State.Terminated != nil:
+			terminated := status.State.Terminated
+			state, result = Finished.String(), Success.String()
+			if terminated.ExitCode != 0 {
+				result = Failure.String()
+			}
+			startTime = terminated.StartedAt.Time
+			durationInMillis = terminated.FinishedAt.Sub(startTime).Milliseconds()
+		case status.State.Running != nil:
+			state, result = Running.String(), Unknown.String()
+			startTime = status.State.Running.StartedAt.Time
+			durationInMillis = now.Sub(startTime).Milliseconds()
+		}
+		break
+	}
+	return
+}
+
+func getAllContainerStatuses(pod *corev1.Pod) []corev1.ContainerStatus {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	return append(statuses, pod.Status.ContainerStatuses...)
+}
@@ -21,7 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/go-logr/logr"
-	cmstore "kubesphere.io/devops/pkg/store/configmap"
+	"kubesphere.io/devops/pkg/store/backend"
 	storeInter "kubesphere.io/devops/pkg/store/store"
 	"kubesphere.io/devops/pkg/utils/k8sutil"
 	"reflect"
@@ -32,12 +32,14 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	devopsClient "kubesphere.io/devops/pkg/client/devops"
+	"kubesphere.io/devops/pkg/event/cloudevents"
 	"kubesphere.io/devops/pkg/jwt/token"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -61,10 +63,26 @@ type Reconciler struct {
 	TokenIssuer          token.Issuer
 	recorder             record.EventRecorder
 	PipelineRunDataStore string
+	// DataStoreOption provides the dependencies of the PipelineRun data store backend
+	DataStoreOption *backend.Option
+	// DataRetention is how long the data of a completed PipelineRun will be kept, keep it forever if it's zero
+	DataRetention time.Duration
+	// KubernetesEngineOption provides the images of the PipelineRuns which run as Kubernetes Pods
+	KubernetesEngineOption *KubernetesEngineOption
+	// EventPublisher exports the lifecycle events of PipelineRuns to the sinks, the events are dropped if it's nil
+	EventPublisher *cloudevents.Publisher
+
+	dataStore storeInter.Backend
 }
 
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=create
+//+kubebuilder:rbac:groups="",resources=namespaces;podtemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=devopsprojects,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -80,15 +98,16 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	jHandler := &jenkinsHandler{&r.JenkinsCore}
-
 	// don't modify the cache in other places, like informer cache.
 	pipelineRunCopied := pipelineRun.DeepCopy()
 
 	// DeletionTimestamp.IsZero() means copyPipeline has not been deleted.
 	if !pipelineRunCopied.ObjectMeta.DeletionTimestamp.IsZero() {
-		if err = jHandler.deleteJenkinsJobHistory(pipelineRunCopied); err != nil {
-			klog.V(4).Infof("failed to delete Jenkins job history from PipelineRun: %s/%s, error: %v",
+		if err = r.getEngine(pipelineRunCopied).cleanup(ctx, pipelineRunCopied); err != nil {
+			klog.V(4).Infof("failed to delete the run history from PipelineRun: %s/%s, error: %v",
+				pipelineRunCopied.Namespace, pipelineRunCopied.Name, err)
+		} else if err = r.deletePipelineRunData(ctx, req.NamespacedName); err != nil {
+			klog.V(4).Infof("failed to delete the data of PipelineRun: %s/%s, error: %v",
 				pipelineRunCopied.Namespace, pipelineRunCopied.Name, err)
 		} else {
 			k8sutil.RemoveFinalizer(&pipelineRunCopied.ObjectMeta, v1alpha3.PipelineRunFinalizerName)
@@ -99,7 +118,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	// the PipelineRun cannot allow building
 	if !pipelineRunCopied.Buildable() {
-		return ctrl.Result{}, nil
+		if result, err := r.retryIfNeeded(ctx, pipelineRunCopied); err != nil || !result.IsZero() {
+			return result, err
+		}
+		return r.cleanupExpiredData(ctx, pipelineRunCopied)
 	}
 
 	// check PipelineRef
@@ -126,10 +148,24 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	log = log.WithValues("namespace", namespaceName, "Pipeline", pipelineName)
 
+	// the engine of a PipelineRun is decided by its Pipeline before it starts
+	if !pipelineRunCopied.HasStarted() && pipeline.GetEngine() != v1alpha3.PipelineEngineJenkins {
+		if pipelineRunCopied.Annotations == nil {
+			pipelineRunCopied.Annotations = make(map[string]string)
+		}
+		pipelineRunCopied.Annotations[v1alpha3.PipelineEngineAnnoKey] = pipeline.GetEngine()
+	}
+	runEngine := r.getEngine(pipelineRunCopied)
+
 	// check PipelineRun status
 	if pipelineRunCopied.HasStarted() {
-		log.V(5).Info("pipeline has already started, and we are retrieving run data from Jenkins.")
-		pipelineBuild, err := jHandler.getPipelineRunResult(namespaceName, pipelineName, pipelineRunCopied)
+		log.V(5).Info("pipeline has already started, and we are retrieving run data from the engine.")
+		if err := r.handleAction(ctx, runEngine, pipelineRunCopied); err != nil {
+			log.Error(err, "unable to apply the action of PipelineRun.")
+			return ctrl.Result{}, err
+		}
+
+		pipelineBuild, err := runEngine.getResult(ctx, pipeline, pipelineRunCopied)
 		if err != nil {
 			if err.Error() == BuildNotExistMsg { // retry if get pipelinerun failed by not exist
 				runID, _ := pipelineRunCopied.GetPipelineRunID()
@@ -152,7 +188,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 			return ctrl.Result{}, err
 		}
 
-		nodeDetails, err := jHandler.getPipelineNodeDetails(pipelineName, namespaceName, pipelineRunCopied)
+		nodeDetails, err := runEngine.getNodeDetails(ctx, pipeline, pipelineRunCopied)
 		if err != nil {
 			log.Error(err, "unable to get PipelineRun nodes detail")
 			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve nodes detail from Jenkins, and error was %v", err)
@@ -191,25 +227,17 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
 	}
 
-	// get or create JenkinsCore if the PipelineRun has creator annotation
-	jenkinsCore, err := r.getOrCreateJenkinsCore(pipelineRunCopied.GetAnnotations())
-	if err != nil {
-		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
-		return ctrl.Result{}, err
+	// wait for other PipelineRuns of the same concurrency group, and the limits of the DevOpsProject
+	if admitted, result, err := r.admitPipelineRun(ctx, pipelineRunCopied, pipeline); err != nil || !admitted {
+		if err != nil {
+			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
+		}
+		return result, err
 	}
-	// create trigger handler
-	triggerHandler := &jenkinsHandler{jenkinsCore}
+
 	// first run
-	jobRun, err := triggerHandler.triggerJenkinsJob(namespaceName, pipelineName, &pipelineRunCopied.Spec)
-	if err != nil {
-		log.Error(err, "unable to run pipeline", "namespace", namespaceName, "pipeline", pipeline.Name)
-		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
-		return ctrl.Result{}, err
-	}
-	// check if there is still a same PipelineRun
-	if exists, err := r.hasSamePipelineRun(jobRun, pipeline); err != nil {
-		return ctrl.Result{}, err
-	} else if exists {
+	runID, err := runEngine.trigger(ctx, pipeline, pipelineRunCopied)
+	if err == errSamePipelineRun {
 		// if there still exists the same pending PipelineRun, then give up reconciling
 		if err := r.Delete(ctx, pipelineRunCopied); err != nil {
 			// ignore the not found error here
@@ -217,15 +245,19 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		}
 		log.Info("Skipped this PipelineRun because there was still a pending Pipeline with the same parameter")
 		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "unable to run pipeline", "namespace", namespaceName, "pipeline", pipeline.Name)
+		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
+		return ctrl.Result{}, err
 	}
 
-	log.Info("Triggered a PipelineRun", "runID", jobRun.ID)
+	log.Info("Triggered a PipelineRun", "runID", runID)
 
-	// set Jenkins run ID
+	// set the run ID
 	if pipelineRunCopied.Annotations == nil {
 		pipelineRunCopied.Annotations = make(map[string]string)
 	}
-	pipelineRunCopied.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = jobRun.ID
+	pipelineRunCopied.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = runID
 
 	// the Update method only updates fields except subresource: status
 	if err := r.updateLabelsAndAnnotations(ctx, pipelineRunCopied); err != nil {
@@ -258,21 +290,77 @@ func (r *Reconciler) storePipelineRunData(nodeDetailsJSON string, pipelineRunCop
 		if err = r.updateLabelsAndAnnotations(r.ctx, pipelineRunCopied); err != nil {
 			r.log.Error(err, "unable to update PipelineRun labels and annotations.")
 		}
-	} else if r.PipelineRunDataStore == "configmap" {
-		var cmStore storeInter.ConfigMapStore
-		if cmStore, err = cmstore.NewConfigMapStore(r.ctx, r.req.NamespacedName, r.Client); err == nil {
-			cmStore.SetStages(nodeDetailsJSON)
-			cmStore.SetOwnerReference(v1.OwnerReference{
-				APIVersion: pipelineRunCopied.APIVersion,
-				Kind:       pipelineRunCopied.Kind,
-				Name:       pipelineRunCopied.Name,
-				UID:        pipelineRunCopied.UID,
-			})
-			err = cmStore.Save()
-		}
 	} else {
-		err = fmt.Errorf("unknown pipelineRun data store type: %s", r.PipelineRunDataStore)
+		var dataStore storeInter.Backend
+		if dataStore, err = r.getDataStore(); err != nil {
+			return
+		}
+
+		var runStore storeInter.PipelineRunDataStore
+		if runStore, err = dataStore.Open(r.ctx, r.req.NamespacedName); err == nil {
+			runStore.SetStages(nodeDetailsJSON)
+			if cmStore, ok := runStore.(storeInter.ConfigMapStore); ok {
+				cmStore.SetOwnerReference(v1.OwnerReference{
+					APIVersion: pipelineRunCopied.APIVersion,
+					Kind:       pipelineRunCopied.Kind,
+					Name:       pipelineRunCopied.Name,
+					UID:        pipelineRunCopied.UID,
+				})
+			}
+			err = runStore.Save()
+		}
+	}
+	return
+}
+
+// getDataStore returns the PipelineRun data store backend, it will be created at the first time
+func (r *Reconciler) getDataStore() (dataStore storeInter.Backend, err error) {
+	if r.dataStore != nil {
+		dataStore = r.dataStore
+		return
+	}
+
+	option := &backend.Option{}
+	if r.DataStoreOption != nil {
+		*option = *r.DataStoreOption
+	}
+	if option.Client == nil {
+		option.Client = r.Client
+	}
+	if dataStore, err = backend.New(r.PipelineRunDataStore, option); err == nil {
+		r.dataStore = dataStore
+	}
+	return
+}
+
+// deletePipelineRunData deletes the data of a PipelineRun from the data store
+func (r *Reconciler) deletePipelineRunData(ctx context.Context, key types.NamespacedName) (err error) {
+	if r.PipelineRunDataStore == "" {
+		// the data is kept in the annotations
+		return
+	}
+
+	var dataStore storeInter.Backend
+	if dataStore, err = r.getDataStore(); err == nil {
+		err = dataStore.Delete(ctx, key)
+	}
+	return
+}
+
+// cleanupExpiredData deletes the data of a completed PipelineRun once it exceeds the retention
+func (r *Reconciler) cleanupExpiredData(ctx context.Context, pipelineRun *v1alpha3.PipelineRun) (result ctrl.Result, err error) {
+	if r.DataRetention <= 0 || !pipelineRun.HasCompleted() {
+		return
+	}
+
+	if expireIn := time.Until(pipelineRun.Status.CompletionTime.Add(r.DataRetention)); expireIn > 0 {
+		result = ctrl.Result{RequeueAfter: expireIn}
+		return
 	}
+	err = r.deletePipelineRunData(ctx, types.NamespacedName{
+		Namespace: pipelineRun.Namespace,
+		Name:      pipelineRun.Name,
+	})
 	return
 }
 
@@ -328,7 +416,9 @@ func (r *Reconciler) updateLabelsAndAnnotations(ctx context.Context, pr *v1alpha
 }
 
 func (r *Reconciler) updateStatus(ctx context.Context, desiredStatus *v1alpha3.PipelineRunStatus, prKey client.ObjectKey) error {
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	var oldStatus *v1alpha3.PipelineRunStatus
+	var updatedPipelineRun *v1alpha3.PipelineRun
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		prToUpdate := v1alpha3.PipelineRun{}
 		err := r.Get(ctx, prKey, &prToUpdate)
 		if err != nil {
@@ -337,10 +427,29 @@ func (r *Reconciler) updateStatus(ctx context.Context, desiredStatus *v1alpha3.P
 		if reflect.DeepEqual(*desiredStatus, prToUpdate.Status) {
 			return nil
 		}
+		oldStatus = prToUpdate.Status.DeepCopy()
 		prToUpdate = *prToUpdate.DeepCopy()
 		prToUpdate.Status = *desiredStatus
-		return r.Status().Update(ctx, &prToUpdate)
+		if err = r.Status().Update(ctx, &prToUpdate); err == nil {
+			updatedPipelineRun = &prToUpdate
+		}
+		return err
 	})
+	if err == nil && updatedPipelineRun != nil {
+		r.publishLifecycleEvents(oldStatus, updatedPipelineRun)
+	}
+	return err
+}
+
+// publishLifecycleEvents exports the lifecycle transitions of a PipelineRun as CloudEvents
+func (r *Reconciler) publishLifecycleEvents(oldStatus *v1alpha3.PipelineRunStatus, pr *v1alpha3.PipelineRun) {
+	if r.EventPublisher == nil {
+		return
+	}
+	now := time.Now()
+	for _, eventType := range cloudevents.GetPipelineRunEventTypes(oldStatus, &pr.Status) {
+		r.EventPublisher.Publish(cloudevents.NewPipelineRunEvent(eventType, pr, now))
+	}
 }
 
 func (r *Reconciler) makePipelineRunOrphan(ctx context.Context, pr *v1alpha3.PipelineRun) (err error) {
@@ -34,13 +34,18 @@ import (
 	"kubesphere.io/devops/pkg/api/devops/v1alpha1"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/client/clientset/versioned/scheme"
+	"kubesphere.io/devops/pkg/event/cloudevents"
 	"kubesphere.io/devops/pkg/jwt/token"
+	"kubesphere.io/devops/pkg/store/backend"
+	"net/http/httptest"
+	"path/filepath"
 	"reflect"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"testing"
+	"time"
 	// nolint
 	// The fakeclient will undeprecated starting with v0.7.0
 	// Reference:
@@ -516,3 +521,88 @@ func TestStorePipelineRunData(t *testing.T) {
 	}
 	assert.Nil(t, r.storePipelineRunData("", pipelineRun.DeepCopy()))
 }
+
+func TestCleanupExpiredData(t *testing.T) {
+	root := t.TempDir()
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetName(key.Name)
+	pipelineRun.SetNamespace(key.Namespace)
+
+	r := &Reconciler{
+		PipelineRunDataStore: "filesystem",
+		DataStoreOption:      &backend.Option{Dir: root},
+		DataRetention:        time.Hour,
+		req:                  ctrl.Request{NamespacedName: key},
+	}
+	assert.Nil(t, r.storePipelineRunData("[]", pipelineRun.DeepCopy()))
+	dataDir := filepath.Join(root, key.Namespace, key.Name)
+	assert.DirExists(t, dataDir)
+
+	// not completed yet
+	result, err := r.cleanupExpiredData(context.Background(), pipelineRun.DeepCopy())
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	// completed but not expired
+	completionTime := metav1.NewTime(time.Now().Add(-time.Minute))
+	pipelineRun.Status.CompletionTime = &completionTime
+	result, err = r.cleanupExpiredData(context.Background(), pipelineRun.DeepCopy())
+	assert.Nil(t, err)
+	assert.True(t, result.RequeueAfter > 0 && result.RequeueAfter <= time.Hour)
+	assert.DirExists(t, dataDir)
+
+	// expired
+	completionTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	result, err = r.cleanupExpiredData(context.Background(), pipelineRun.DeepCopy())
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.NoDirExists(t, dataDir)
+}
+
+func TestUpdateStatusPublishesEvents(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	sink := &cloudevents.Sink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+	publisher := cloudevents.NewPublisher([]string{server.URL})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = publisher.Start(ctx)
+	}()
+
+	pipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "name"},
+		Spec:       v1alpha3.PipelineRunSpec{PipelineRef: &v1.ObjectReference{Name: "pipeline"}},
+	}
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+	r := &Reconciler{
+		Client:         fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun).Build(),
+		EventPublisher: publisher,
+	}
+
+	now := metav1.Now()
+	status := &v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running, StartTime: &now}
+	assert.Nil(t, r.updateStatus(ctx, status, key))
+	// nothing changed, no more events
+	assert.Nil(t, r.updateStatus(ctx, status, key))
+	status = status.DeepCopy()
+	status.Phase = v1alpha3.Succeeded
+	status.CompletionTime = &now
+	assert.Nil(t, r.updateStatus(ctx, status, key))
+
+	assert.Eventually(t, func() bool {
+		return len(sink.Events()) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+	var eventTypes []string
+	for _, event := range sink.Events() {
+		eventTypes = append(eventTypes, event.Type)
+		assert.Equal(t, "name", event.Subject)
+	}
+	assert.Equal(t, []string{cloudevents.PipelineRunStarted, cloudevents.PipelineRunPhaseChanged,
+		cloudevents.PipelineRunPhaseChanged, cloudevents.PipelineRunFinished}, eventTypes)
+}
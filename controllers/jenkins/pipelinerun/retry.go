@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// retryIfNeeded creates a follow-up PipelineRun for a failed PipelineRun if its retry policy allows.
+// The attempt chain is recorded in the Retry condition of every failed PipelineRun.
+func (r *Reconciler) retryIfNeeded(ctx context.Context, pr *v1alpha3.PipelineRun) (result ctrl.Result, err error) {
+	policy := pr.GetRetryPolicy()
+	if policy == nil || policy.MaxAttempts <= 1 || !pr.HasCompleted() || pr.Status.Phase != v1alpha3.Failed {
+		return
+	}
+	condition := pr.Status.GetCondition(v1alpha3.ConditionRetry)
+	if condition != nil && condition.Status != v1alpha3.ConditionUnknown {
+		// it was retried or ran out of attempts already
+		return
+	}
+
+	if pr.Status.IsStopped() {
+		// it was stopped on purpose
+		return
+	}
+
+	runResult := getJenkinsRunResult(pr)
+	if !policy.AllowRetry(runResult) {
+		return
+	}
+
+	attempt := pr.GetAttempt()
+	if attempt >= policy.MaxAttempts {
+		err = r.setRetryCondition(ctx, pr, v1alpha3.ConditionFalse, v1alpha3.RetryExhausted,
+			fmt.Sprintf("attempt %d of %d failed with %s, no more attempts", attempt, policy.MaxAttempts, runResult))
+		return
+	}
+
+	retryTime := pr.Status.CompletionTime.Add(policy.GetBackoff(attempt))
+	if wait := time.Until(retryTime); wait > 0 {
+		if condition == nil {
+			err = r.setRetryCondition(ctx, pr, v1alpha3.ConditionUnknown, v1alpha3.RetryScheduled,
+				fmt.Sprintf("attempt %d of %d failed with %s, will retry at %s", attempt, policy.MaxAttempts, runResult,
+					retryTime.Format(time.RFC3339)))
+		}
+		result = ctrl.Result{RequeueAfter: wait}
+		return
+	}
+
+	// the name of the follow-up PipelineRun is fixed, so it won't be created twice
+	nextRun := newRetryPipelineRun(pr, attempt+1)
+	if err = r.Create(ctx, nextRun); err != nil && !apierrors.IsAlreadyExists(err) {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.RetryFailed,
+			"Failed to create PipelineRun %s to retry, and error was %v", nextRun.Name, err)
+		return
+	}
+
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Retried, "Retried by PipelineRun %s", nextRun.Name)
+	err = r.setRetryCondition(ctx, pr, v1alpha3.ConditionTrue, v1alpha3.Retried,
+		fmt.Sprintf("attempt %d of %d failed with %s, retried by PipelineRun %s", attempt, policy.MaxAttempts, runResult,
+			nextRun.Name))
+	return
+}
+
+func (r *Reconciler) setRetryCondition(ctx context.Context, pr *v1alpha3.PipelineRun, status v1alpha3.ConditionStatus,
+	reason, message string) error {
+	now := v1.Now()
+	prStatus := pr.Status.DeepCopy()
+	prStatus.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionRetry,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	return r.updateStatus(ctx, prStatus, client.ObjectKey{Namespace: pr.Namespace, Name: pr.Name})
+}
+
+// newRetryPipelineRun creates a PipelineRun which has the same spec with the failed one
+func newRetryPipelineRun(pr *v1alpha3.PipelineRun, attempt int) *v1alpha3.PipelineRun {
+	firstRunName := pr.Name
+	if name, ok := pr.Annotations[v1alpha3.PipelineRunRetryOfAnnoKey]; ok && name != "" {
+		firstRunName = name
+	}
+
+	annotations := make(map[string]string, len(pr.Annotations))
+	for key, value := range pr.Annotations {
+		annotations[key] = value
+	}
+	// the running data belongs to the failed PipelineRun only
+	delete(annotations, v1alpha3.JenkinsPipelineRunIDAnnoKey)
+	delete(annotations, v1alpha3.JenkinsPipelineRunStatusAnnoKey)
+	delete(annotations, v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey)
+	annotations[v1alpha3.PipelineRunRetryOfAnnoKey] = firstRunName
+	annotations[v1alpha3.PipelineRunAttemptAnnoKey] = strconv.Itoa(attempt)
+
+	labels := make(map[string]string, len(pr.Labels))
+	for key, value := range pr.Labels {
+		labels[key] = value
+	}
+
+	spec := pr.Spec.DeepCopy()
+	spec.Action = nil
+	return &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Name:            fmt.Sprintf("%s-retry-%d", firstRunName, attempt),
+			Namespace:       pr.Namespace,
+			Labels:          labels,
+			Annotations:     annotations,
+			OwnerReferences: pr.OwnerReferences,
+		},
+		Spec: *spec,
+	}
+}
+
+// getJenkinsRunResult returns the result of the Jenkins build, such as: FAILURE, ABORTED
+func getJenkinsRunResult(pr *v1alpha3.PipelineRun) string {
+	runStatus := &job.PipelineRun{}
+	if err := json.Unmarshal([]byte(pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey]), runStatus); err != nil {
+		return ""
+	}
+	return runStatus.Result
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestRetryIfNeeded(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	stop := v1alpha3.Stop
+	newFailedRun := func(result string, completedBefore time.Duration, annotations map[string]string) *v1alpha3.PipelineRun {
+		completionTime := metav1.NewTime(time.Now().Add(-completedBefore))
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "pr",
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey:     "1",
+					v1alpha3.JenkinsPipelineRunStatusAnnoKey: `{"result":"` + result + `"}`,
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{Name: "pipeline"},
+				Action:      &stop,
+				Retry: &v1alpha3.RetryPolicy{
+					MaxAttempts: 3,
+					Backoff:     &metav1.Duration{Duration: time.Minute},
+				},
+			},
+			Status: v1alpha3.PipelineRunStatus{
+				Phase:          v1alpha3.Failed,
+				CompletionTime: &completionTime,
+			},
+		}
+		for key, value := range annotations {
+			pr.Annotations[key] = value
+		}
+		return pr
+	}
+
+	tests := []struct {
+		name          string
+		pipelineRun   *v1alpha3.PipelineRun
+		wantRequeue   bool
+		wantCondition *v1alpha3.Condition
+		wantNextRun   string
+		wantAttempt   string
+	}{{
+		name: "succeeded PipelineRun",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newFailedRun("SUCCESS", time.Hour, nil)
+			pr.Status.Phase = v1alpha3.Succeeded
+			return pr
+		}(),
+	}, {
+		name:        "the failure reason is not allowed",
+		pipelineRun: newFailedRun("ABORTED", time.Hour, nil),
+	}, {
+		name:          "wait for the backoff",
+		pipelineRun:   newFailedRun("FAILURE", 0, nil),
+		wantRequeue:   true,
+		wantCondition: &v1alpha3.Condition{Status: v1alpha3.ConditionUnknown, Reason: v1alpha3.RetryScheduled},
+	}, {
+		name:          "retry the first run",
+		pipelineRun:   newFailedRun("FAILURE", time.Hour, nil),
+		wantCondition: &v1alpha3.Condition{Status: v1alpha3.ConditionTrue, Reason: v1alpha3.Retried},
+		wantNextRun:   "pr-retry-2",
+		wantAttempt:   "2",
+	}, {
+		name: "retry a retried run",
+		pipelineRun: newFailedRun("FAILURE", time.Hour, map[string]string{
+			v1alpha3.PipelineRunRetryOfAnnoKey: "first",
+			v1alpha3.PipelineRunAttemptAnnoKey: "2",
+		}),
+		wantCondition: &v1alpha3.Condition{Status: v1alpha3.ConditionTrue, Reason: v1alpha3.Retried},
+		wantNextRun:   "first-retry-3",
+		wantAttempt:   "3",
+	}, {
+		name: "run out of attempts",
+		pipelineRun: newFailedRun("FAILURE", time.Hour, map[string]string{
+			v1alpha3.PipelineRunRetryOfAnnoKey: "first",
+			v1alpha3.PipelineRunAttemptAnnoKey: "3",
+		}),
+		wantCondition: &v1alpha3.Condition{Status: v1alpha3.ConditionFalse, Reason: v1alpha3.RetryExhausted},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.pipelineRun.DeepCopy()).Build(),
+				log:      logr.New(log.NullLogSink{}),
+				recorder: record.NewFakeRecorder(10),
+			}
+			result, err := r.retryIfNeeded(context.Background(), tt.pipelineRun.DeepCopy())
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRequeue, result.RequeueAfter > 0)
+
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(tt.pipelineRun), pr))
+			condition := pr.Status.GetCondition(v1alpha3.ConditionRetry)
+			if tt.wantCondition == nil {
+				assert.Nil(t, condition)
+			} else if assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantCondition.Status, condition.Status)
+				assert.Equal(t, tt.wantCondition.Reason, condition.Reason)
+			}
+
+			runs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, r.List(context.Background(), runs))
+			if tt.wantNextRun == "" {
+				assert.Len(t, runs.Items, 1)
+				return
+			}
+			nextRun := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: tt.wantNextRun}, nextRun))
+			assert.Equal(t, tt.wantAttempt, nextRun.Annotations[v1alpha3.PipelineRunAttemptAnnoKey])
+			assert.False(t, nextRun.HasStarted())
+			assert.Nil(t, nextRun.Spec.Action)
+			assert.Equal(t, "pipeline", nextRun.Labels[v1alpha3.PipelineNameLabelKey])
+			assert.Contains(t, condition.Message, tt.wantNextRun)
+
+			// it won't be retried again
+			_, err = r.retryIfNeeded(context.Background(), pr)
+			assert.Nil(t, err)
+			assert.Nil(t, r.List(context.Background(), runs))
+			assert.Len(t, runs.Items, 2)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/event/notification"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconciler notifies the receivers when an Application becomes Degraded or OutOfSync, or recovers.
+// The notified problems are stored in an annotation of the Application, so the notification is sent once
+// even if the controller restarts.
+type Reconciler struct {
+	client.Client
+	// Notifier sends the notifications, only the Kubernetes events are recorded if it's nil
+	Notifier *notification.Notifier
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+// Reconcile compares the health and sync status of the Application with the notified problems
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.Info(fmt.Sprintf("start to reconcile Application: %s", req.String()))
+
+	app := &v1alpha1.Application{}
+	if err = r.Get(ctx, req.NamespacedName, app); err != nil || !app.DeletionTimestamp.IsZero() {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	notified := app.Annotations[v1alpha1.AnnoKeyNotifiedProblems]
+	problems, newProblems := getProblems(app, notified)
+	current := strings.Join(problems, ",")
+	if current == notified {
+		return
+	}
+
+	var events []notification.Event
+	for _, problem := range newProblems {
+		events = append(events, notification.Event(problem))
+	}
+	if current == "" {
+		events = append(events, notification.EventRecovered)
+	}
+
+	appToUpdate := app.DeepCopy()
+	if current == "" {
+		delete(appToUpdate.Annotations, v1alpha1.AnnoKeyNotifiedProblems)
+	} else {
+		if appToUpdate.Annotations == nil {
+			appToUpdate.Annotations = map[string]string{}
+		}
+		appToUpdate.Annotations[v1alpha1.AnnoKeyNotifiedProblems] = current
+	}
+	if err = r.Patch(ctx, appToUpdate, client.MergeFrom(app)); err != nil {
+		return
+	}
+
+	for _, e := range events {
+		r.notify(app, e)
+	}
+	return
+}
+
+// getProblems returns the problems of the Application, and the new ones which have not been notified.
+// A notified problem is kept until the Application is Healthy or Synced, so the flapping between
+// Degraded and Progressing does not send duplicated notifications.
+func getProblems(app *v1alpha1.Application, notified string) (problems, newProblems []string) {
+	notifiedProblems := map[string]bool{}
+	for _, problem := range strings.Split(notified, ",") {
+		notifiedProblems[problem] = problem != ""
+	}
+
+	check := func(problem notification.Event, occurred, resolved bool) {
+		switch {
+		case occurred:
+			problems = append(problems, string(problem))
+			if !notifiedProblems[string(problem)] {
+				newProblems = append(newProblems, string(problem))
+			}
+		case !resolved && notifiedProblems[string(problem)]:
+			problems = append(problems, string(problem))
+		}
+	}
+	check(notification.EventDegraded, app.Status.Health == v1alpha1.HealthStatusDegraded,
+		app.Status.Health == v1alpha1.HealthStatusHealthy)
+	check(notification.EventOutOfSync, app.Status.SyncStatus == v1alpha1.SyncStatusOutOfSync,
+		app.Status.SyncStatus == v1alpha1.SyncStatusSynced)
+	return
+}
+
+func (r *Reconciler) notify(app *v1alpha1.Application, e notification.Event) {
+	n := &notification.Notification{
+		Event:       e,
+		Namespace:   app.Namespace,
+		Application: app.Name,
+		Health:      string(app.Status.Health),
+		SyncStatus:  string(app.Status.SyncStatus),
+		Time:        time.Now(),
+	}
+
+	eventType := corev1.EventTypeWarning
+	switch e {
+	case notification.EventDegraded:
+		n.Message = getConditionMessage(app, v1alpha1.ApplicationConditionHealthy)
+	case notification.EventOutOfSync:
+		n.Message = getConditionMessage(app, v1alpha1.ApplicationConditionSynced)
+	case notification.EventRecovered:
+		eventType = corev1.EventTypeNormal
+	}
+	r.recorder.Event(app, eventType, string(e), n.Text())
+	r.Notifier.Notify(n)
+}
+
+func getConditionMessage(app *v1alpha1.Application, conditionType string) string {
+	if condition := meta.FindStatusCondition(app.Status.Conditions, conditionType); condition != nil {
+		return condition.Message
+	}
+	return ""
+}
+
+// GetName returns the name of this controller
+func (r *Reconciler) GetName() string {
+	return "ApplicationNotificationController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *Reconciler) GetGroupName() string {
+	return "notification"
+}
+
+// statusChangedPredicate accepts the changes of the health and sync status of the Applications
+var statusChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldApp, okOld := e.ObjectOld.(*v1alpha1.Application)
+		newApp, okNew := e.ObjectNew.(*v1alpha1.Application)
+		return okOld && okNew && (oldApp.Status.Health != newApp.Status.Health ||
+			oldApp.Status.SyncStatus != newApp.Status.SyncStatus)
+	},
+	DeleteFunc: func(event.DeleteEvent) bool {
+		return false
+	},
+}
+
+// SetupWithManager setups the log and recorder
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Application{}, builder.WithPredicates(statusChangedPredicate)).
+		Complete(r)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/event/notification"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReconcile(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+
+	newApp := func(health v1alpha1.HealthStatus, syncStatus v1alpha1.SyncStatusCode, notified string) *v1alpha1.Application {
+		app := &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+		}
+		if notified != "" {
+			app.Annotations = map[string]string{v1alpha1.AnnoKeyNotifiedProblems: notified}
+		}
+		app.Status.SetHealth(health, "", 0)
+		app.Status.SetSyncStatus(syncStatus, "", 0)
+		return app
+	}
+
+	tests := []struct {
+		name         string
+		app          *v1alpha1.Application
+		wantNotified string
+		wantEvents   []string
+	}{{
+		name: "not found",
+	}, {
+		name: "healthy and synced",
+		app:  newApp(v1alpha1.HealthStatusHealthy, v1alpha1.SyncStatusSynced, ""),
+	}, {
+		name:         "became degraded and out of sync",
+		app:          newApp(v1alpha1.HealthStatusDegraded, v1alpha1.SyncStatusOutOfSync, ""),
+		wantNotified: "Degraded,OutOfSync",
+		wantEvents: []string{"Warning Degraded [Degraded] Application ns/app is Degraded: the Application is Degraded",
+			"Warning OutOfSync [OutOfSync] Application ns/app is OutOfSync: the Application is OutOfSync"},
+	}, {
+		name:         "became out of sync after degraded",
+		app:          newApp(v1alpha1.HealthStatusDegraded, v1alpha1.SyncStatusOutOfSync, "Degraded"),
+		wantNotified: "Degraded,OutOfSync",
+		wantEvents:   []string{"Warning OutOfSync [OutOfSync] Application ns/app is OutOfSync: the Application is OutOfSync"},
+	}, {
+		name:         "still degraded when it's progressing",
+		app:          newApp(v1alpha1.HealthStatusProgressing, v1alpha1.SyncStatusUnknown, "Degraded,OutOfSync"),
+		wantNotified: "Degraded,OutOfSync",
+	}, {
+		name:         "synced but still degraded",
+		app:          newApp(v1alpha1.HealthStatusProgressing, v1alpha1.SyncStatusSynced, "Degraded,OutOfSync"),
+		wantNotified: "Degraded",
+	}, {
+		name:       "recovered",
+		app:        newApp(v1alpha1.HealthStatusHealthy, v1alpha1.SyncStatusSynced, "Degraded"),
+		wantEvents: []string{"Normal Recovered [Recovered] Application ns/app recovered, it is Healthy and Synced"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(schema)
+			if tt.app != nil {
+				builder.WithObjects(tt.app)
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &Reconciler{
+				Client:   builder.Build(),
+				log:      logr.New(log.NullLogSink{}),
+				recorder: recorder,
+			}
+			key := types.NamespacedName{Namespace: "ns", Name: "app"}
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			assert.NoError(t, err)
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			assert.Equal(t, tt.wantEvents, events)
+
+			if tt.app == nil {
+				return
+			}
+			app := &v1alpha1.Application{}
+			assert.NoError(t, r.Get(context.Background(), key, app))
+			assert.Equal(t, tt.wantNotified, app.Annotations[v1alpha1.AnnoKeyNotifiedProblems])
+
+			// nothing is notified again in the next round
+			_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			assert.NoError(t, err)
+			assert.Equal(t, 0, len(recorder.Events))
+		})
+	}
+}
+
+func TestReconcileWithNotifier(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	var received []*notification.Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := &notification.Notification{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(n))
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, n)
+	}))
+	defer server.Close()
+
+	notifier := notification.NewNotifier([]notification.Receiver{{Type: notification.ReceiverTypeWebhook, URL: server.URL}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = notifier.Start(ctx)
+	}()
+
+	app := &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"}}
+	app.Status.SetHealth(v1alpha1.HealthStatusDegraded, "Deployment ns/app is Degraded", 0)
+	app.Status.SetSyncStatus(v1alpha1.SyncStatusSynced, "", 0)
+	r := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(app).Build(),
+		Notifier: notifier,
+		log:      logr.New(log.NullLogSink{}),
+		recorder: record.NewFakeRecorder(10),
+	}
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "app"}})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, notification.EventDegraded, received[0].Event)
+	assert.Equal(t, "ns", received[0].Namespace)
+	assert.Equal(t, "app", received[0].Application)
+	assert.Equal(t, "Degraded", received[0].Health)
+	assert.Equal(t, "Synced", received[0].SyncStatus)
+	assert.Equal(t, "Deployment ns/app is Degraded", received[0].Message)
+}
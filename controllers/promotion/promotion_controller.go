@@ -0,0 +1,228 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions/status,verbs=get;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconciler promotes the synced revision of the source Application to the target Application
+type Reconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+// Reconcile promotes the synced revision once it changed, or waits for the approval of it
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.Info(fmt.Sprintf("start to reconcile Promotion: %s", req.String()))
+
+	promotion := &v1alpha1.Promotion{}
+	if err = r.Get(ctx, req.NamespacedName, promotion); err != nil || !promotion.DeletionTimestamp.IsZero() {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	var source, target *v1alpha1.Application
+	if source, err = r.getApplication(ctx, promotion, promotion.Spec.Source); source == nil {
+		return
+	}
+	if target, err = r.getApplication(ctx, promotion, promotion.Spec.Target); target == nil {
+		return
+	}
+	if source.Spec.Kind != target.Spec.Kind {
+		r.recorder.Eventf(promotion, corev1.EventTypeWarning, "Invalid",
+			"the source Application %s and the target Application %s use different engines", source.Name, target.Name)
+		return
+	}
+
+	revision, images, synced := getSyncedRevision(source)
+	if !synced {
+		// it will be triggered again once the source Application is synced
+		return
+	}
+
+	err = r.progress(ctx, promotion, target, revision, images)
+	return
+}
+
+func (r *Reconciler) getApplication(ctx context.Context, promotion *v1alpha1.Promotion, name string) (
+	app *v1alpha1.Application, err error) {
+	app = &v1alpha1.Application{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: promotion.Namespace, Name: name}, app); err != nil {
+		app = nil
+		if err = client.IgnoreNotFound(err); err == nil {
+			r.recorder.Eventf(promotion, corev1.EventTypeWarning, "NotFound", "the Application %s was not found", name)
+		}
+	}
+	return
+}
+
+// progress decides what to do with the synced revision, then records it in the status
+func (r *Reconciler) progress(ctx context.Context, promotion *v1alpha1.Promotion, target *v1alpha1.Application,
+	revision string, images []string) (err error) {
+	now := metav1.Now()
+	pending := promotion.Status.Pending
+	record := v1alpha1.PromotionRecord{
+		Revision:  revision,
+		Images:    images,
+		StartTime: &now,
+	}
+
+	switch {
+	case pending.IsSameRevision(revision, images):
+		// the decision is recorded in the status by the approve and reject APIs, so it cannot be forged by the ones
+		// who could only update the Promotion
+		switch {
+		case pending.Approved == nil || pending.Reviewer == "":
+			return
+		case *pending.Approved:
+			err = r.promote(ctx, promotion, target, pending)
+		default:
+			r.complete(promotion, pending, v1alpha1.PromotionRejected,
+				fmt.Sprintf("revision %s was rejected by %s", revision, pending.Reviewer))
+		}
+	case len(promotion.Status.History) > 0 && promotion.Status.History[0].IsSameRevision(revision, images):
+		// it has been promoted, rejected or failed
+		return
+	default:
+		r.supersede(promotion)
+		if isUpToDate(target, revision, images) {
+			r.complete(promotion, &record, v1alpha1.PromotionSucceeded,
+				fmt.Sprintf("the target Application %s is already up to date with revision %s", target.Name, revision))
+		} else if promotion.Spec.RequireApproval {
+			record.Phase = v1alpha1.PromotionWaitingForApproval
+			record.Message = fmt.Sprintf("revision %s is waiting for the approval", revision)
+			promotion.Status.Pending = &record
+			r.recorder.Eventf(promotion, corev1.EventTypeNormal, string(v1alpha1.PromotionWaitingForApproval),
+				"revision %s of Application %s is waiting for the approval", revision, promotion.Spec.Source)
+		} else {
+			err = r.promote(ctx, promotion, target, &record)
+		}
+	}
+	if err == nil {
+		err = r.Status().Update(ctx, promotion)
+	}
+	return
+}
+
+// promote applies the revision to the target Application
+func (r *Reconciler) promote(ctx context.Context, promotion *v1alpha1.Promotion, target *v1alpha1.Application,
+	record *v1alpha1.PromotionRecord) (err error) {
+	if err = applyRevision(target, record.Revision, record.Images); err != nil {
+		r.complete(promotion, record, v1alpha1.PromotionFailed, err.Error())
+		return nil
+	}
+	if err = r.Update(ctx, target); err != nil {
+		r.recorder.Eventf(promotion, corev1.EventTypeWarning, string(v1alpha1.PromotionFailed),
+			"failed to update the target Application %s: %v", target.Name, err)
+		return
+	}
+	r.complete(promotion, record, v1alpha1.PromotionSucceeded, fmt.Sprintf("revision %s was promoted from %s to %s",
+		record.Revision, promotion.Spec.Source, promotion.Spec.Target))
+	return
+}
+
+// complete moves the record into the history
+func (r *Reconciler) complete(promotion *v1alpha1.Promotion, record *v1alpha1.PromotionRecord,
+	phase v1alpha1.PromotionPhase, message string) {
+	now := metav1.Now()
+	record.Phase = phase
+	record.Message = message
+	record.CompletionTime = &now
+	promotion.AddHistory(*record)
+	promotion.Status.Pending = nil
+
+	eventType := corev1.EventTypeNormal
+	if phase == v1alpha1.PromotionFailed {
+		eventType = corev1.EventTypeWarning
+	}
+	r.recorder.Event(promotion, eventType, string(phase), message)
+}
+
+// supersede moves the pending record into the history because a newer revision came
+func (r *Reconciler) supersede(promotion *v1alpha1.Promotion) {
+	if pending := promotion.Status.Pending; pending != nil {
+		r.complete(promotion, pending, v1alpha1.PromotionSuperseded,
+			fmt.Sprintf("revision %s was superseded before it was approved", pending.Revision))
+	}
+}
+
+// isUpToDate returns true if the target Application does not change after applying the revision
+func isUpToDate(target *v1alpha1.Application, revision string, images []string) bool {
+	app := target.DeepCopy()
+	if err := applyRevision(app, revision, images); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(app.Spec, target.Spec)
+}
+
+// findPromotions returns the Promotions which refer to the Application
+func (r *Reconciler) findPromotions(obj client.Object) (requests []reconcile.Request) {
+	promotions := &v1alpha1.PromotionList{}
+	if err := r.List(context.Background(), promotions, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.log.Error(err, "failed to list Promotions", "namespace", obj.GetNamespace())
+		return
+	}
+	for _, item := range promotions.Items {
+		if item.Spec.Source == obj.GetName() || item.Spec.Target == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name},
+			})
+		}
+	}
+	return
+}
+
+// GetName returns the name of this controller
+func (r *Reconciler) GetName() string {
+	return "PromotionController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *Reconciler) GetGroupName() string {
+	return "promotion"
+}
+
+// SetupWithManager setups the log and recorder
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Promotion{}).
+		Watches(&source.Kind{Type: &v1alpha1.Application{}}, handler.EnqueueRequestsFromMapFunc(r.findPromotions)).
+		Complete(r)
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/controllers/core"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newArgoApp(name, targetRevision, syncedRevision string) *v1alpha1.Application {
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.ArgoCD,
+			ArgoApp: &v1alpha1.ArgoApplication{
+				Spec: v1alpha1.ArgoApplicationSpec{
+					Source: v1alpha1.ApplicationSource{
+						RepoURL:        "https://github.com/org/repo",
+						TargetRevision: targetRevision,
+						Kustomize: &v1alpha1.ApplicationSourceKustomize{
+							Images: v1alpha1.KustomizeImages{v1alpha1.KustomizeImage("nginx=nginx:" + targetRevision)},
+						},
+					},
+				},
+			},
+		},
+	}
+	if syncedRevision != "" {
+		app.Status.ArgoApp = `{"sync":{"status":"Synced","revision":"` + syncedRevision + `"}}`
+	}
+	return app
+}
+
+func TestReconcile(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPromotion := func(requireApproval bool, annotations map[string]string, status v1alpha1.PromotionStatus) *v1alpha1.Promotion {
+		return &v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "staging-to-prod", Annotations: annotations},
+			Spec: v1alpha1.PromotionSpec{
+				Source:          "staging",
+				Target:          "prod",
+				RequireApproval: requireApproval,
+			},
+			Status: status,
+		}
+	}
+	pending := func(revision string) v1alpha1.PromotionStatus {
+		return v1alpha1.PromotionStatus{Pending: &v1alpha1.PromotionRecord{
+			Revision: revision,
+			Images:   []string{"nginx=nginx:" + revision},
+			Phase:    v1alpha1.PromotionWaitingForApproval,
+		}}
+	}
+	reviewed := func(revision string, approved bool) v1alpha1.PromotionStatus {
+		status := pending(revision)
+		status.Pending.Approved = &approved
+		status.Pending.Reviewer = "admin"
+		return status
+	}
+
+	tests := []struct {
+		name          string
+		promotion     *v1alpha1.Promotion
+		apps          []*v1alpha1.Application
+		wantRevision  string
+		wantPending   string
+		wantHistory   []v1alpha1.PromotionPhase
+		wantReviewer  string
+		wantNoPromote bool
+	}{{
+		name:          "the applications are not found",
+		promotion:     newPromotion(false, nil, v1alpha1.PromotionStatus{}),
+		wantNoPromote: true,
+	}, {
+		name:      "the source application is not synced",
+		promotion: newPromotion(false, nil, v1alpha1.PromotionStatus{}),
+		apps:      []*v1alpha1.Application{newArgoApp("staging", "v2", ""), newArgoApp("prod", "v1", "v1")},
+		// keep the target as it is
+		wantRevision: "v1",
+	}, {
+		name:         "promote directly",
+		promotion:    newPromotion(false, nil, v1alpha1.PromotionStatus{}),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), newArgoApp("prod", "v1", "v1")},
+		wantRevision: "v2",
+		wantHistory:  []v1alpha1.PromotionPhase{v1alpha1.PromotionSucceeded},
+	}, {
+		name:         "the target is up to date",
+		promotion:    newPromotion(true, nil, v1alpha1.PromotionStatus{}),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), newArgoApp("prod", "v2", "v2")},
+		wantRevision: "v2",
+		wantHistory:  []v1alpha1.PromotionPhase{v1alpha1.PromotionSucceeded},
+	}, {
+		name:         "waiting for the approval",
+		promotion:    newPromotion(true, nil, v1alpha1.PromotionStatus{}),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), newArgoApp("prod", "v1", "v1")},
+		wantRevision: "v1",
+		wantPending:  "v2",
+	}, {
+		name:         "approved",
+		promotion:    newPromotion(true, nil, reviewed("v2", true)),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), newArgoApp("prod", "v1", "v1")},
+		wantRevision: "v2",
+		wantHistory:  []v1alpha1.PromotionPhase{v1alpha1.PromotionSucceeded},
+		wantReviewer: "admin",
+	}, {
+		name:         "rejected",
+		promotion:    newPromotion(true, nil, reviewed("v2", false)),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), newArgoApp("prod", "v1", "v1")},
+		wantRevision: "v1",
+		wantHistory:  []v1alpha1.PromotionPhase{v1alpha1.PromotionRejected},
+		wantReviewer: "admin",
+	}, {
+		name:         "superseded by a newer revision, the stale approval is discarded",
+		promotion:    newPromotion(true, nil, reviewed("v2", true)),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v3", "v3"), newArgoApp("prod", "v1", "v1")},
+		wantRevision: "v1",
+		wantPending:  "v3",
+		wantHistory:  []v1alpha1.PromotionPhase{v1alpha1.PromotionSuperseded},
+	}, {
+		name:         "the approval annotation is ignored",
+		promotion:    newPromotion(true, map[string]string{"gitops.kubesphere.io/promotion-approve": "admin"}, pending("v2")),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), newArgoApp("prod", "v1", "v1")},
+		wantRevision: "v1",
+		wantPending:  "v2",
+	}, {
+		name: "the rejected revision is not promoted again",
+		promotion: newPromotion(false, nil, v1alpha1.PromotionStatus{History: []v1alpha1.PromotionRecord{{
+			Revision: "v2",
+			Images:   []string{"nginx=nginx:v2"},
+			Phase:    v1alpha1.PromotionRejected,
+		}}}),
+		apps:         []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), newArgoApp("prod", "v1", "v1")},
+		wantRevision: "v1",
+		wantHistory:  []v1alpha1.PromotionPhase{v1alpha1.PromotionRejected},
+	}, {
+		name:      "different engines",
+		promotion: newPromotion(false, nil, v1alpha1.PromotionStatus{}),
+		apps: []*v1alpha1.Application{newArgoApp("staging", "v2", "v2"), func() *v1alpha1.Application {
+			app := newArgoApp("prod", "v1", "v1")
+			app.Spec.Kind = v1alpha1.FluxCD
+			return app
+		}()},
+		wantRevision: "v1",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []client.Object{tt.promotion.DeepCopy()}
+			for _, app := range tt.apps {
+				objects = append(objects, app.DeepCopy())
+			}
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(objects...).Build()
+			r := &Reconciler{
+				Client:   c,
+				log:      logr.New(log.NullLogSink{}),
+				recorder: record.NewFakeRecorder(10),
+			}
+			key := types.NamespacedName{Namespace: "ns", Name: "staging-to-prod"}
+			_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+			assert.Nil(t, err)
+
+			promotion := &v1alpha1.Promotion{}
+			assert.Nil(t, c.Get(context.TODO(), key, promotion))
+			if tt.wantPending == "" {
+				assert.Nil(t, promotion.Status.Pending)
+			} else if assert.NotNil(t, promotion.Status.Pending) {
+				assert.Equal(t, tt.wantPending, promotion.Status.Pending.Revision)
+				assert.Equal(t, v1alpha1.PromotionWaitingForApproval, promotion.Status.Pending.Phase)
+			}
+			var phases []v1alpha1.PromotionPhase
+			for _, item := range promotion.Status.History {
+				phases = append(phases, item.Phase)
+			}
+			assert.Equal(t, tt.wantHistory, phases)
+			if tt.wantReviewer != "" {
+				assert.Equal(t, tt.wantReviewer, promotion.Status.History[0].Reviewer)
+			}
+
+			if tt.wantNoPromote {
+				return
+			}
+			target := &v1alpha1.Application{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "prod"}, target))
+			assert.Equal(t, tt.wantRevision, target.Spec.ArgoApp.Spec.Source.TargetRevision)
+			assert.Equal(t, v1alpha1.KustomizeImages{v1alpha1.KustomizeImage("nginx=nginx:" + tt.wantRevision)},
+				target.Spec.ArgoApp.Spec.Source.Kustomize.Images)
+		})
+	}
+}
+
+func TestFindPromotions(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(
+		&v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "dev-to-staging"},
+			Spec:       v1alpha1.PromotionSpec{Source: "dev", Target: "staging"},
+		},
+		&v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "staging-to-prod"},
+			Spec:       v1alpha1.PromotionSpec{Source: "staging", Target: "prod"},
+		},
+		&v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "staging-to-prod"},
+			Spec:       v1alpha1.PromotionSpec{Source: "staging", Target: "prod"},
+		}).Build()
+	r := &Reconciler{Client: c, log: logr.New(log.NullLogSink{})}
+
+	assert.Len(t, r.findPromotions(newArgoApp("staging", "v1", "")), 2)
+	assert.Len(t, r.findPromotions(newArgoApp("prod", "v1", "")), 1)
+	assert.Len(t, r.findPromotions(newArgoApp("test", "v1", "")), 0)
+}
+
+func TestReconciler_SetupWithManager(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	r := &Reconciler{}
+	err = r.SetupWithManager(&core.FakeManager{
+		Client: fake.NewClientBuilder().WithScheme(schema).Build(),
+		Scheme: schema,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "promotion", r.GetGroupName())
+}
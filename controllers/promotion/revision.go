@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	apimeta "kubesphere.io/devops/pkg/external/fluxcd/meta"
+)
+
+// argoSyncStatus is the part of the Argo CD Application status which has the synced revision
+type argoSyncStatus struct {
+	Sync struct {
+		Status   string `json:"status"`
+		Revision string `json:"revision"`
+	} `json:"sync"`
+}
+
+// getSyncedRevision returns the revision and image overrides of an Application which were synced to all destinations
+func getSyncedRevision(app *v1alpha1.Application) (revision string, images []string, synced bool) {
+	switch app.Spec.Kind {
+	case v1alpha1.ArgoCD:
+		if app.Spec.ArgoApp == nil || app.Status.ArgoApp == "" {
+			return
+		}
+		status := &argoSyncStatus{}
+		if err := json.Unmarshal([]byte(app.Status.ArgoApp), status); err != nil {
+			return
+		}
+		if kustomize := app.Spec.ArgoApp.Spec.Source.Kustomize; kustomize != nil {
+			for _, image := range kustomize.Images {
+				images = append(images, string(image))
+			}
+		}
+		revision = status.Sync.Revision
+		synced = status.Sync.Status == "Synced" && revision != ""
+	case v1alpha1.FluxCD:
+		if app.Spec.FluxApp == nil || app.Spec.FluxApp.Spec.Config == nil {
+			return
+		}
+		config := app.Spec.FluxApp.Spec.Config
+		fluxStatus := app.Status.FluxApp
+		var total int
+		var revisions []string
+		if config.HelmRelease != nil {
+			total = len(config.HelmRelease.Deploy)
+			for _, status := range fluxStatus.HelmReleaseStatus {
+				if status != nil && meta.IsStatusConditionTrue(status.Conditions, apimeta.ReadyCondition) {
+					revisions = append(revisions, status.LastAppliedRevision)
+				}
+			}
+		} else if len(config.Kustomization) > 0 {
+			total = len(config.Kustomization)
+			for _, status := range fluxStatus.KustomizationStatus {
+				if status != nil && meta.IsStatusConditionTrue(status.Conditions, apimeta.ReadyCondition) {
+					revisions = append(revisions, status.LastAppliedRevision)
+				}
+			}
+			for _, image := range config.Kustomization[0].Images {
+				images = append(images, formatImage(image))
+			}
+		}
+		if total == 0 || len(revisions) != total {
+			return
+		}
+		for _, item := range revisions {
+			if item != revisions[0] {
+				return
+			}
+		}
+		revision = revisions[0]
+		synced = revision != ""
+	}
+	return
+}
+
+// applyRevision sets the revision and image overrides to the Application.
+// The revision of FluxCD Kustomizations comes from the shared Git source, so only the image overrides are applied.
+func applyRevision(app *v1alpha1.Application, revision string, images []string) error {
+	switch app.Spec.Kind {
+	case v1alpha1.ArgoCD:
+		if app.Spec.ArgoApp == nil {
+			return errors.New("the target Application has no Argo CD Application")
+		}
+		source := &app.Spec.ArgoApp.Spec.Source
+		source.TargetRevision = revision
+		if len(images) > 0 {
+			if source.Kustomize == nil {
+				source.Kustomize = &v1alpha1.ApplicationSourceKustomize{}
+			}
+			source.Kustomize.Images = make(v1alpha1.KustomizeImages, len(images))
+			for i, image := range images {
+				source.Kustomize.Images[i] = v1alpha1.KustomizeImage(image)
+			}
+		}
+	case v1alpha1.FluxCD:
+		if app.Spec.FluxApp == nil || app.Spec.FluxApp.Spec.Config == nil {
+			return errors.New("the target Application has no FluxCD Application")
+		}
+		config := app.Spec.FluxApp.Spec.Config
+		if config.HelmRelease != nil {
+			if config.HelmRelease.Chart == nil {
+				return errors.New("the chart version of the target Application which refers to a HelmTemplate could not be changed")
+			}
+			config.HelmRelease.Chart.Version = revision
+		}
+		if len(images) > 0 {
+			kusImages := make([]kusv1.Image, len(images))
+			for i, image := range images {
+				kusImages[i] = parseImage(image)
+			}
+			for _, kus := range config.Kustomization {
+				kus.Images = kusImages
+			}
+		}
+	default:
+		return errors.New("the engine of the target Application is not supported")
+	}
+	return nil
+}
+
+// formatImage returns the image override in the format of name=newName:newTag@digest
+func formatImage(image kusv1.Image) string {
+	var suffix string
+	if image.NewTag != "" {
+		suffix += ":" + image.NewTag
+	}
+	if image.Digest != "" {
+		suffix += "@" + image.Digest
+	}
+	if image.NewName == "" {
+		return image.Name + suffix
+	}
+	return image.Name + "=" + image.NewName + suffix
+}
+
+// parseImage parses the image override which looks like name=newName:newTag@digest, name:newTag or name@digest
+func parseImage(text string) (image kusv1.Image) {
+	ref := text
+	if i := strings.Index(text, "="); i >= 0 {
+		image.Name, ref = text[:i], text[i+1:]
+	}
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref, image.Digest = ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref, image.NewTag = ref[:i], ref[i+1:]
+	}
+	if image.Name == "" {
+		image.Name = ref
+	} else {
+		image.NewName = ref
+	}
+	return
+}
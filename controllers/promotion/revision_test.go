@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"kubesphere.io/devops/pkg/external/fluxcd/meta"
+)
+
+func newFluxHelmApp(version string, revisions ...string) *v1alpha1.Application {
+	app := &v1alpha1.Application{
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{
+				Spec: v1alpha1.FluxApplicationSpec{
+					Config: &v1alpha1.FluxApplicationConfig{
+						HelmRelease: &v1alpha1.HelmReleaseSpec{
+							Chart:  &v1alpha1.HelmChartTemplateSpec{Chart: "demo", Version: version},
+							Deploy: []*v1alpha1.Deploy{{}, {}},
+						},
+					},
+				},
+			},
+		},
+		Status: v1alpha1.ApplicationStatus{
+			FluxApp: v1alpha1.FluxApplicationStatus{HelmReleaseStatus: map[string]*helmv2.HelmReleaseStatus{}},
+		},
+	}
+	for i, revision := range revisions {
+		app.Status.FluxApp.HelmReleaseStatus[string(rune('a'+i))] = &helmv2.HelmReleaseStatus{
+			LastAppliedRevision: revision,
+			Conditions:          []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionTrue}},
+		}
+	}
+	return app
+}
+
+func newFluxKustomizationApp(images []kusv1.Image, revision string) *v1alpha1.Application {
+	return &v1alpha1.Application{
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{
+				Spec: v1alpha1.FluxApplicationSpec{
+					Config: &v1alpha1.FluxApplicationConfig{
+						Kustomization: []*v1alpha1.KustomizationSpec{{Images: images}},
+					},
+				},
+			},
+		},
+		Status: v1alpha1.ApplicationStatus{
+			FluxApp: v1alpha1.FluxApplicationStatus{KustomizationStatus: map[string]*kusv1.KustomizationStatus{
+				"a": {
+					LastAppliedRevision: revision,
+					Conditions:          []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionTrue}},
+				},
+			}},
+		},
+	}
+}
+
+func TestGetSyncedRevision(t *testing.T) {
+	tests := []struct {
+		name         string
+		app          *v1alpha1.Application
+		wantRevision string
+		wantImages   []string
+		wantSynced   bool
+	}{{
+		name:         "synced Argo CD Application",
+		app:          newArgoApp("app", "main", "abc"),
+		wantRevision: "abc",
+		wantImages:   []string{"nginx=nginx:main"},
+		wantSynced:   true,
+	}, {
+		name: "out of sync Argo CD Application",
+		app: func() *v1alpha1.Application {
+			app := newArgoApp("app", "main", "")
+			app.Status.ArgoApp = `{"sync":{"status":"OutOfSync","revision":"abc"}}`
+			return app
+		}(),
+		wantRevision: "abc",
+		wantImages:   []string{"nginx=nginx:main"},
+	}, {
+		name: "invalid Argo CD status",
+		app: func() *v1alpha1.Application {
+			app := newArgoApp("app", "main", "")
+			app.Status.ArgoApp = `invalid`
+			return app
+		}(),
+	}, {
+		name:         "all HelmReleases are ready",
+		app:          newFluxHelmApp("*", "1.0.1", "1.0.1"),
+		wantRevision: "1.0.1",
+		wantSynced:   true,
+	}, {
+		name: "some HelmReleases are not ready",
+		app:  newFluxHelmApp("*", "1.0.1"),
+	}, {
+		name: "the HelmReleases have different revisions",
+		app:  newFluxHelmApp("*", "1.0.1", "1.0.0"),
+	}, {
+		name:         "Kustomization",
+		app:          newFluxKustomizationApp([]kusv1.Image{{Name: "nginx", NewTag: "v2"}}, "main/abc"),
+		wantRevision: "main/abc",
+		wantImages:   []string{"nginx:v2"},
+		wantSynced:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revision, images, synced := getSyncedRevision(tt.app)
+			assert.Equal(t, tt.wantRevision, revision)
+			assert.Equal(t, tt.wantImages, images)
+			assert.Equal(t, tt.wantSynced, synced)
+		})
+	}
+}
+
+func TestApplyRevision(t *testing.T) {
+	// Argo CD
+	app := newArgoApp("app", "v1", "")
+	app.Spec.ArgoApp.Spec.Source.Kustomize = nil
+	assert.Nil(t, applyRevision(app, "v2", []string{"nginx:v2"}))
+	assert.Equal(t, "v2", app.Spec.ArgoApp.Spec.Source.TargetRevision)
+	assert.Equal(t, v1alpha1.KustomizeImages{"nginx:v2"}, app.Spec.ArgoApp.Spec.Source.Kustomize.Images)
+
+	// FluxCD HelmRelease
+	app = newFluxHelmApp("1.0.0")
+	assert.Nil(t, applyRevision(app, "1.0.1", nil))
+	assert.Equal(t, "1.0.1", app.Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version)
+
+	app.Spec.FluxApp.Spec.Config.HelmRelease.Chart = nil
+	app.Spec.FluxApp.Spec.Config.HelmRelease.Template = "template"
+	assert.NotNil(t, applyRevision(app, "1.0.1", nil))
+
+	// FluxCD Kustomization
+	app = newFluxKustomizationApp(nil, "")
+	assert.Nil(t, applyRevision(app, "main/abc", []string{"nginx=registry/nginx:v2"}))
+	assert.Equal(t, []kusv1.Image{{Name: "nginx", NewName: "registry/nginx", NewTag: "v2"}},
+		app.Spec.FluxApp.Spec.Config.Kustomization[0].Images)
+
+	// invalid
+	assert.NotNil(t, applyRevision(&v1alpha1.Application{Spec: v1alpha1.ApplicationSpec{Kind: v1alpha1.ArgoCD}}, "v1", nil))
+	assert.NotNil(t, applyRevision(&v1alpha1.Application{Spec: v1alpha1.ApplicationSpec{Kind: v1alpha1.FluxCD}}, "v1", nil))
+	assert.NotNil(t, applyRevision(&v1alpha1.Application{}, "v1", nil))
+}
+
+func TestImage(t *testing.T) {
+	tests := []struct {
+		text  string
+		image kusv1.Image
+	}{{
+		text:  "nginx",
+		image: kusv1.Image{Name: "nginx"},
+	}, {
+		text:  "nginx:v1",
+		image: kusv1.Image{Name: "nginx", NewTag: "v1"},
+	}, {
+		text:  "nginx@sha256:abc",
+		image: kusv1.Image{Name: "nginx", Digest: "sha256:abc"},
+	}, {
+		text:  "nginx=registry:5000/library/nginx:v1",
+		image: kusv1.Image{Name: "nginx", NewName: "registry:5000/library/nginx", NewTag: "v1"},
+	}, {
+		text:  "nginx=registry:5000/library/nginx:v1@sha256:abc",
+		image: kusv1.Image{Name: "nginx", NewName: "registry:5000/library/nginx", NewTag: "v1", Digest: "sha256:abc"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.image, parseImage(tt.text))
+			assert.Equal(t, tt.text, formatImage(tt.image))
+		})
+	}
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// requeueInterval is the interval to check the health of the promoted destinations
+const requeueInterval = 10 * time.Second
+
+// Health is the health of the destinations which the new revision was promoted to
+type Health string
+
+const (
+	// Healthy indicates that all the promoted destinations are healthy
+	Healthy Health = "Healthy"
+	// Progressing indicates that some promoted destinations are not ready yet
+	Progressing Health = "Progressing"
+	// Degraded indicates that some promoted destinations failed
+	Degraded Health = "Degraded"
+)
+
+// Aggregate returns the worst health
+func Aggregate(healths ...Health) Health {
+	result := Healthy
+	for _, health := range healths {
+		switch health {
+		case Degraded:
+			return Degraded
+		case Progressing:
+			result = Progressing
+		}
+	}
+	return result
+}
+
+// HealthFunc returns the health of the destinations which the new revision was promoted to according to the plan
+type HealthFunc func(ctx context.Context, app *v1alpha1.Application, plan *Plan) (Health, error)
+
+// Plan tells an engine which revision should be applied to each destination
+type Plan struct {
+	// Stable is the spec of the stable revision, it's applied to the destinations which are not promoted
+	Stable *v1alpha1.ApplicationSpec
+	// Weight is the traffic weight of the new revision, it's nil if no traffic step was reached
+	Weight *int32
+	// WeightParameter is the Helm value which receives the traffic weight
+	WeightParameter string
+
+	allPromoted  bool
+	destinations map[string]bool
+}
+
+// IsPromoted returns true if the new revision should be applied to the destination
+func (p *Plan) IsPromoted(destination string) bool {
+	return p == nil || p.allPromoted || p.destinations[destination]
+}
+
+// GetPlan returns the plan of the current rollout.
+// It's nil if the desired spec should be applied to all destinations.
+func GetPlan(app *v1alpha1.Application) (plan *Plan, err error) {
+	strategy, status := app.Spec.Rollout, app.Status.Rollout
+	if strategy == nil || status == nil || status.StableSpec == "" {
+		return
+	}
+	switch status.Phase {
+	case v1alpha1.RolloutProgressing, v1alpha1.RolloutPaused, v1alpha1.RolloutRolledBack:
+	default:
+		return
+	}
+
+	stable := &v1alpha1.ApplicationSpec{}
+	if err = json.Unmarshal([]byte(status.StableSpec), stable); err != nil {
+		err = fmt.Errorf("invalid stable spec of the rollout: %v", err)
+		return
+	}
+	plan = &Plan{
+		Stable:          stable,
+		WeightParameter: strategy.GetTrafficWeightParameter(),
+		destinations:    map[string]bool{},
+	}
+	if status.Phase == v1alpha1.RolloutRolledBack {
+		return
+	}
+
+	// all destinations are promoted at the first step if no step promotes destinations
+	plan.allPromoted = true
+	for i, step := range strategy.Steps {
+		if len(step.Destinations) > 0 {
+			plan.allPromoted = false
+		}
+		if i > int(status.CurrentStep) {
+			continue
+		}
+		for _, destination := range step.Destinations {
+			plan.destinations[destination] = true
+		}
+		if step.Weight != nil {
+			plan.Weight = step.Weight
+		}
+	}
+	return
+}
+
+// Reconcile moves the rollout of the Application forward, then returns the plan which the engine should apply.
+// The plan is nil if the desired spec should be applied to all destinations.
+func Reconcile(ctx context.Context, c client.Client, recorder record.EventRecorder, app *v1alpha1.Application,
+	getHealth HealthFunc) (plan *Plan, result ctrl.Result, err error) {
+	if app.Spec.Rollout == nil {
+		return
+	}
+
+	health := Healthy
+	if plan, err = GetPlan(app); err != nil {
+		return
+	}
+	if plan != nil && app.Status.Rollout.Phase != v1alpha1.RolloutRolledBack {
+		if health, err = getHealth(ctx, app, plan); err != nil {
+			return
+		}
+	}
+
+	var status *v1alpha1.RolloutStatus
+	var approved bool
+	if status, approved, result.RequeueAfter, err = progress(app, health, metav1.Now()); err != nil {
+		return
+	}
+	key := types.NamespacedName{Namespace: app.Namespace, Name: app.Name}
+	if approved {
+		if err = removePromoteAnnotation(ctx, c, key); err != nil {
+			return
+		}
+	}
+	if !reflect.DeepEqual(status, app.Status.Rollout) {
+		if err = updateStatus(ctx, c, key, status); err != nil {
+			return
+		}
+		if app.Status.Rollout == nil || app.Status.Rollout.Phase != status.Phase ||
+			app.Status.Rollout.CurrentStep != status.CurrentStep {
+			eventType := corev1.EventTypeNormal
+			if status.Phase == v1alpha1.RolloutRolledBack {
+				eventType = corev1.EventTypeWarning
+			}
+			recorder.Event(app, eventType, "Rollout"+string(status.Phase), status.Message)
+		}
+		app.Status.Rollout = status
+	}
+	plan, err = GetPlan(app)
+	return
+}
+
+// progress returns the next status of the rollout according to the health of the promoted destinations
+func progress(app *v1alpha1.Application, health Health, now metav1.Time) (
+	status *v1alpha1.RolloutStatus, approved bool, requeueAfter time.Duration, err error) {
+	strategy := app.Spec.Rollout
+	revision := app.Spec.GetRevision()
+	status = &v1alpha1.RolloutStatus{}
+	if app.Status.Rollout != nil {
+		status = app.Status.Rollout.DeepCopy()
+	}
+
+	switch {
+	case status.Phase == v1alpha1.RolloutCompleted && status.Revision == revision:
+		return
+	case status.StableRevision == "" || status.StableRevision == revision:
+		// there is no stable revision to protect, or the spec was reverted to the stable revision
+		err = complete(status, app, revision, now)
+		return
+	case status.Revision != revision:
+		status.Phase = v1alpha1.RolloutProgressing
+		status.Revision = revision
+		status.CurrentStep = 0
+		status.StepStartTime = &now
+		status.Message = fmt.Sprintf("rolling out revision %s", revision)
+		requeueAfter = requeueInterval
+		return
+	case status.Phase == v1alpha1.RolloutRolledBack:
+		// waiting for a new revision
+		return
+	case int(status.CurrentStep) >= len(strategy.Steps):
+		err = complete(status, app, revision, now)
+		return
+	}
+
+	step := strategy.Steps[status.CurrentStep]
+	// only a paused rollout could be approved
+	hasApproval := status.Phase == v1alpha1.RolloutPaused && app.Annotations[v1alpha1.RolloutPromoteAnnoKey] != ""
+	switch {
+	case health == Degraded && strategy.AutoRollback:
+		status.Phase = v1alpha1.RolloutRolledBack
+		status.Message = fmt.Sprintf("rolled back to revision %s because the health check failed at step %d",
+			status.StableRevision, status.CurrentStep)
+		return
+	case hasApproval:
+	case health == Degraded:
+		status.Phase = v1alpha1.RolloutPaused
+		status.Message = fmt.Sprintf("the health check failed at step %d, waiting for the approval", status.CurrentStep)
+		requeueAfter = requeueInterval
+		return
+	case health != Healthy:
+		status.Phase = v1alpha1.RolloutProgressing
+		status.Message = fmt.Sprintf("waiting for the destinations of step %d to be healthy", status.CurrentStep)
+		requeueAfter = requeueInterval
+		return
+	case step.Pause != nil && step.Pause.Duration != nil:
+		startTime := now
+		if status.StepStartTime != nil {
+			startTime = *status.StepStartTime
+		}
+		if remaining := startTime.Add(step.Pause.Duration.Duration).Sub(now.Time); remaining > 0 {
+			status.Phase = v1alpha1.RolloutPaused
+			status.Message = fmt.Sprintf("pausing at step %d for %s", status.CurrentStep, step.Pause.Duration.Duration)
+			requeueAfter = remaining
+			return
+		}
+	case step.Pause != nil:
+		status.Phase = v1alpha1.RolloutPaused
+		status.Message = fmt.Sprintf("waiting for the approval of step %d", status.CurrentStep)
+		requeueAfter = requeueInterval
+		return
+	}
+
+	approved = hasApproval
+	status.CurrentStep++
+	status.StepStartTime = &now
+	if int(status.CurrentStep) >= len(strategy.Steps) {
+		err = complete(status, app, revision, now)
+		return
+	}
+	status.Phase = v1alpha1.RolloutProgressing
+	status.Message = fmt.Sprintf("promoted revision %s to step %d", revision, status.CurrentStep)
+	requeueAfter = requeueInterval
+	return
+}
+
+// complete marks the revision as the stable one
+func complete(status *v1alpha1.RolloutStatus, app *v1alpha1.Application, revision string, now metav1.Time) error {
+	stableSpec, err := json.Marshal(app.Spec.GetRevisionSpec())
+	if err != nil {
+		return err
+	}
+	status.Phase = v1alpha1.RolloutCompleted
+	status.Revision = revision
+	status.StableRevision = revision
+	status.StableSpec = string(stableSpec)
+	status.CurrentStep = int32(len(app.Spec.Rollout.Steps))
+	status.StepStartTime = &now
+	status.Message = fmt.Sprintf("revision %s was promoted to all destinations", revision)
+	return nil
+}
+
+func updateStatus(ctx context.Context, c client.Client, key types.NamespacedName, status *v1alpha1.RolloutStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		app := &v1alpha1.Application{}
+		if err = c.Get(ctx, key, app); err != nil {
+			return
+		}
+		app.Status.Rollout = status
+		return c.Status().Update(ctx, app)
+	})
+}
+
+func removePromoteAnnotation(ctx context.Context, c client.Client, key types.NamespacedName) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		app := &v1alpha1.Application{}
+		if err = c.Get(ctx, key, app); err != nil {
+			return
+		}
+		if _, ok := app.Annotations[v1alpha1.RolloutPromoteAnnoKey]; !ok {
+			return
+		}
+		delete(app.Annotations, v1alpha1.RolloutPromoteAnnoKey)
+		return c.Update(ctx, app)
+	})
+}
+
+// SetHelmValue sets a value of the Helm values by a dot-separated path, such as canary.weight
+func SetHelmValue(values *apiextensionsv1.JSON, path string, value interface{}) (result *apiextensionsv1.JSON, err error) {
+	data := map[string]interface{}{}
+	if values != nil && len(values.Raw) > 0 {
+		if err = json.Unmarshal(values.Raw, &data); err != nil {
+			return
+		}
+	}
+
+	keys := strings.Split(path, ".")
+	parent := data
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := parent[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			parent[key] = child
+		}
+		parent = child
+	}
+	parent[keys[len(keys)-1]] = value
+
+	var raw []byte
+	if raw, err = json.Marshal(data); err == nil {
+		result = &apiextensionsv1.JSON{Raw: raw}
+	}
+	return
+}
@@ -0,0 +1,368 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func newApp(targetRevision string, strategy *v1alpha1.RolloutStrategy) *v1alpha1.Application {
+	return &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app"},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.ArgoCD,
+			ArgoApp: &v1alpha1.ArgoApplication{
+				Spec: v1alpha1.ArgoApplicationSpec{
+					Source: v1alpha1.ApplicationSource{RepoURL: "https://github.com/org/repo", TargetRevision: targetRevision},
+				},
+			},
+			Rollout: strategy,
+		},
+	}
+}
+
+// newRollingOutApp returns an Application which is rolling out v2 at the given step, v1 is the stable revision
+func newRollingOutApp(strategy *v1alpha1.RolloutStrategy, phase v1alpha1.RolloutPhase, step int32,
+	stepStartTime time.Time) *v1alpha1.Application {
+	stable := newApp("v1", strategy)
+	stableSpec, _ := json.Marshal(stable.Spec.GetRevisionSpec())
+	app := newApp("v2", strategy)
+	startTime := metav1.NewTime(stepStartTime)
+	app.Status.Rollout = &v1alpha1.RolloutStatus{
+		Phase:          phase,
+		Revision:       app.Spec.GetRevision(),
+		StableRevision: stable.Spec.GetRevision(),
+		StableSpec:     string(stableSpec),
+		CurrentStep:    step,
+		StepStartTime:  &startTime,
+	}
+	return app
+}
+
+func TestAggregate(t *testing.T) {
+	assert.Equal(t, Healthy, Aggregate())
+	assert.Equal(t, Healthy, Aggregate(Healthy, Healthy))
+	assert.Equal(t, Progressing, Aggregate(Healthy, Progressing))
+	assert.Equal(t, Degraded, Aggregate(Progressing, Degraded, Healthy))
+}
+
+func TestGetPlan(t *testing.T) {
+	strategy := &v1alpha1.RolloutStrategy{
+		Steps: []v1alpha1.RolloutStep{
+			{Destinations: []string{"test"}, Weight: int32Ptr(10)},
+			{Destinations: []string{"staging"}},
+			{Weight: int32Ptr(50)},
+		},
+	}
+	trafficOnly := &v1alpha1.RolloutStrategy{
+		Steps:                  []v1alpha1.RolloutStep{{Weight: int32Ptr(20)}, {Weight: int32Ptr(60)}},
+		TrafficWeightParameter: "traffic",
+	}
+
+	// no rollout
+	plan, err := GetPlan(newApp("v1", nil))
+	assert.Nil(t, err)
+	assert.Nil(t, plan)
+	assert.True(t, plan.IsPromoted("any"))
+
+	// completed
+	plan, err = GetPlan(newRollingOutApp(strategy, v1alpha1.RolloutCompleted, 3, time.Now()))
+	assert.Nil(t, err)
+	assert.Nil(t, plan)
+
+	// promoted to the destinations of the reached steps
+	plan, err = GetPlan(newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 1, time.Now()))
+	assert.Nil(t, err)
+	if assert.NotNil(t, plan) {
+		assert.Equal(t, "v1", plan.Stable.ArgoApp.Spec.Source.TargetRevision)
+		assert.True(t, plan.IsPromoted("test"))
+		assert.True(t, plan.IsPromoted("staging"))
+		assert.False(t, plan.IsPromoted("production"))
+		assert.Equal(t, int32Ptr(10), plan.Weight)
+		assert.Equal(t, v1alpha1.DefaultTrafficWeightParameter, plan.WeightParameter)
+	}
+
+	// all destinations are promoted if no step promotes destinations
+	plan, err = GetPlan(newRollingOutApp(trafficOnly, v1alpha1.RolloutPaused, 1, time.Now()))
+	assert.Nil(t, err)
+	if assert.NotNil(t, plan) {
+		assert.True(t, plan.IsPromoted("production"))
+		assert.Equal(t, int32Ptr(60), plan.Weight)
+		assert.Equal(t, "traffic", plan.WeightParameter)
+	}
+
+	// nothing is promoted after rolling back
+	plan, err = GetPlan(newRollingOutApp(trafficOnly, v1alpha1.RolloutRolledBack, 1, time.Now()))
+	assert.Nil(t, err)
+	if assert.NotNil(t, plan) {
+		assert.False(t, plan.IsPromoted("production"))
+		assert.Nil(t, plan.Weight)
+	}
+
+	// invalid stable spec
+	app := newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 0, time.Now())
+	app.Status.Rollout.StableSpec = "invalid"
+	_, err = GetPlan(app)
+	assert.NotNil(t, err)
+}
+
+func TestProgress(t *testing.T) {
+	now := metav1.Now()
+	steps := []v1alpha1.RolloutStep{
+		{Weight: int32Ptr(10), Pause: &v1alpha1.RolloutPause{Duration: &metav1.Duration{Duration: time.Minute}}},
+		{Weight: int32Ptr(50), Pause: &v1alpha1.RolloutPause{}},
+		{Weight: int32Ptr(100)},
+	}
+	strategy := &v1alpha1.RolloutStrategy{Steps: steps}
+	autoRollback := &v1alpha1.RolloutStrategy{Steps: steps, AutoRollback: true}
+	approved := func(app *v1alpha1.Application) *v1alpha1.Application {
+		app.Annotations = map[string]string{v1alpha1.RolloutPromoteAnnoKey: "true"}
+		return app
+	}
+
+	tests := []struct {
+		name             string
+		app              *v1alpha1.Application
+		health           Health
+		wantPhase        v1alpha1.RolloutPhase
+		wantStep         int32
+		wantApproved     bool
+		wantRequeueAfter time.Duration
+		wantStable       string
+	}{{
+		name:       "the first revision is promoted directly",
+		app:        newApp("v1", strategy),
+		health:     Healthy,
+		wantPhase:  v1alpha1.RolloutCompleted,
+		wantStep:   3,
+		wantStable: "v1",
+	}, {
+		name: "start to roll out a new revision",
+		app: func() *v1alpha1.Application {
+			app := newRollingOutApp(strategy, v1alpha1.RolloutCompleted, 3, now.Time)
+			app.Spec.ArgoApp.Spec.Source.TargetRevision = "v3"
+			return app
+		}(),
+		health:           Healthy,
+		wantPhase:        v1alpha1.RolloutProgressing,
+		wantStep:         0,
+		wantRequeueAfter: requeueInterval,
+		wantStable:       "v1",
+	}, {
+		name: "the spec was reverted to the stable revision",
+		app: func() *v1alpha1.Application {
+			app := newRollingOutApp(strategy, v1alpha1.RolloutPaused, 1, now.Time)
+			app.Spec.ArgoApp.Spec.Source.TargetRevision = "v1"
+			return app
+		}(),
+		health:     Healthy,
+		wantPhase:  v1alpha1.RolloutCompleted,
+		wantStep:   3,
+		wantStable: "v1",
+	}, {
+		name:             "waiting for the destinations to be healthy",
+		app:              newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 0, now.Time),
+		health:           Progressing,
+		wantPhase:        v1alpha1.RolloutProgressing,
+		wantStep:         0,
+		wantRequeueAfter: requeueInterval,
+		wantStable:       "v1",
+	}, {
+		name:             "pausing for a duration",
+		app:              newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 0, now.Add(-20*time.Second)),
+		health:           Healthy,
+		wantPhase:        v1alpha1.RolloutPaused,
+		wantStep:         0,
+		wantRequeueAfter: 40 * time.Second,
+		wantStable:       "v1",
+	}, {
+		name:             "the pause is over",
+		app:              newRollingOutApp(strategy, v1alpha1.RolloutPaused, 0, now.Add(-time.Minute)),
+		health:           Healthy,
+		wantPhase:        v1alpha1.RolloutProgressing,
+		wantStep:         1,
+		wantRequeueAfter: requeueInterval,
+		wantStable:       "v1",
+	}, {
+		name:             "waiting for the approval",
+		app:              newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 1, now.Time),
+		health:           Healthy,
+		wantPhase:        v1alpha1.RolloutPaused,
+		wantStep:         1,
+		wantRequeueAfter: requeueInterval,
+		wantStable:       "v1",
+	}, {
+		name:             "a progressing rollout could not be approved",
+		app:              approved(newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 1, now.Time)),
+		health:           Healthy,
+		wantPhase:        v1alpha1.RolloutPaused,
+		wantStep:         1,
+		wantRequeueAfter: requeueInterval,
+		wantStable:       "v1",
+	}, {
+		name:             "approved",
+		app:              approved(newRollingOutApp(strategy, v1alpha1.RolloutPaused, 1, now.Time)),
+		health:           Healthy,
+		wantPhase:        v1alpha1.RolloutProgressing,
+		wantStep:         2,
+		wantApproved:     true,
+		wantRequeueAfter: requeueInterval,
+		wantStable:       "v1",
+	}, {
+		name:       "completed after the last step",
+		app:        newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 2, now.Time),
+		health:     Healthy,
+		wantPhase:  v1alpha1.RolloutCompleted,
+		wantStep:   3,
+		wantStable: "v2",
+	}, {
+		name:             "paused when the health check failed",
+		app:              newRollingOutApp(strategy, v1alpha1.RolloutProgressing, 0, now.Time),
+		health:           Degraded,
+		wantPhase:        v1alpha1.RolloutPaused,
+		wantStep:         0,
+		wantRequeueAfter: requeueInterval,
+		wantStable:       "v1",
+	}, {
+		name:       "rolled back when the health check failed",
+		app:        newRollingOutApp(autoRollback, v1alpha1.RolloutProgressing, 1, now.Time),
+		health:     Degraded,
+		wantPhase:  v1alpha1.RolloutRolledBack,
+		wantStep:   1,
+		wantStable: "v1",
+	}, {
+		name:       "waiting for a new revision after rolling back",
+		app:        newRollingOutApp(autoRollback, v1alpha1.RolloutRolledBack, 1, now.Time),
+		health:     Healthy,
+		wantPhase:  v1alpha1.RolloutRolledBack,
+		wantStep:   1,
+		wantStable: "v1",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, approved, requeueAfter, err := progress(tt.app, tt.health, now)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantPhase, status.Phase, status.Message)
+			assert.Equal(t, tt.wantStep, status.CurrentStep)
+			assert.Equal(t, tt.wantApproved, approved)
+			assert.Equal(t, tt.wantRequeueAfter, requeueAfter)
+
+			stable := &v1alpha1.ApplicationSpec{}
+			assert.Nil(t, json.Unmarshal([]byte(status.StableSpec), stable))
+			assert.Equal(t, tt.wantStable, stable.ArgoApp.Spec.Source.TargetRevision)
+			assert.Equal(t, stable.GetRevision(), status.StableRevision)
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	strategy := &v1alpha1.RolloutStrategy{
+		Steps: []v1alpha1.RolloutStep{{Weight: int32Ptr(10), Pause: &v1alpha1.RolloutPause{}}},
+	}
+	app := newRollingOutApp(strategy, v1alpha1.RolloutPaused, 0, time.Now())
+	app.Annotations = map[string]string{v1alpha1.RolloutPromoteAnnoKey: "true", "other": "value"}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(app.DeepCopy()).Build()
+	recorder := record.NewFakeRecorder(10)
+
+	var healthChecked bool
+	getHealth := func(_ context.Context, _ *v1alpha1.Application, plan *Plan) (Health, error) {
+		healthChecked = true
+		assert.Equal(t, int32Ptr(10), plan.Weight)
+		return Healthy, nil
+	}
+	plan, result, err := Reconcile(context.TODO(), c, recorder, app, getHealth)
+	assert.Nil(t, err)
+	assert.True(t, healthChecked)
+	assert.Nil(t, plan)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, v1alpha1.RolloutCompleted, app.Status.Rollout.Phase)
+	assert.Len(t, recorder.Events, 1)
+
+	latest := &v1alpha1.Application{}
+	assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "app"}, latest))
+	assert.Equal(t, map[string]string{"other": "value"}, latest.Annotations)
+	assert.Equal(t, app.Status.Rollout.Phase, latest.Status.Rollout.Phase)
+	assert.Equal(t, app.Status.Rollout.StableRevision, latest.Status.Rollout.StableRevision)
+
+	// nothing changed
+	plan, result, err = Reconcile(context.TODO(), c, recorder, latest, getHealth)
+	assert.Nil(t, err)
+	assert.Nil(t, plan)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Len(t, recorder.Events, 1)
+
+	// no rollout strategy
+	plan, result, err = Reconcile(context.TODO(), c, recorder, newApp("v1", nil), getHealth)
+	assert.Nil(t, err)
+	assert.Nil(t, plan)
+	assert.Zero(t, result.RequeueAfter)
+}
+
+func TestSetHelmValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		values *apiextensionsv1.JSON
+		path   string
+		want   string
+	}{{
+		name: "nil values",
+		path: "canary.weight",
+		want: `{"canary":{"weight":10}}`,
+	}, {
+		name:   "keep other values",
+		values: &apiextensionsv1.JSON{Raw: []byte(`{"image":"nginx","canary":{"enabled":true,"weight":0}}`)},
+		path:   "canary.weight",
+		want:   `{"image":"nginx","canary":{"enabled":true,"weight":10}}`,
+	}, {
+		name:   "replace a non-object value",
+		values: &apiextensionsv1.JSON{Raw: []byte(`{"canary":"none"}`)},
+		path:   "canary.weight",
+		want:   `{"canary":{"weight":10}}`,
+	}, {
+		name: "top level value",
+		path: "weight",
+		want: `{"weight":10}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SetHelmValue(tt.values, tt.path, 10)
+			assert.Nil(t, err)
+			assert.JSONEq(t, tt.want, string(result.Raw))
+		})
+	}
+
+	_, err := SetHelmValue(&apiextensionsv1.JSON{Raw: []byte("invalid")}, "weight", 10)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"encoding/json"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// CredentialExpiresAtAnnoKey is the time when a credential expires, in the RFC 3339 format
+	CredentialExpiresAtAnnoKey = DevOpsCredentialPrefix + "expires-at"
+	// CredentialRotationPeriodAnnoKey is how often a credential should be rotated, such as 720h
+	CredentialRotationPeriodAnnoKey = DevOpsCredentialPrefix + "rotation-period"
+	// CredentialLastRotatedAnnoKey is the time when a credential was rotated last time, in the RFC 3339 format.
+	// The creation time of the credential is used if it's missing.
+	CredentialLastRotatedAnnoKey = DevOpsCredentialPrefix + "last-rotated"
+	// CredentialConditionsAnnoKey holds the conditions of a credential in JSON, because a secret has no status
+	CredentialConditionsAnnoKey = DevOpsCredentialPrefix + "conditions"
+
+	// CredentialConditionExpiring is True when a credential expired, or it's going to expire soon
+	CredentialConditionExpiring = "Expiring"
+	// CredentialConditionRotationDue is True when a credential was not rotated in its rotation period
+	CredentialConditionRotationDue = "RotationDue"
+
+	// CredentialReasonExpired means the credential expired
+	CredentialReasonExpired = "Expired"
+	// CredentialReasonExpiringSoon means the credential is going to expire soon
+	CredentialReasonExpiringSoon = "ExpiringSoon"
+	// CredentialReasonNotExpiring means the credential is not going to expire soon
+	CredentialReasonNotExpiring = "NotExpiring"
+	// CredentialReasonRotationOverdue means the credential was not rotated in time
+	CredentialReasonRotationOverdue = "RotationOverdue"
+	// CredentialReasonRotated means the credential was rotated in its rotation period
+	CredentialReasonRotated = "Rotated"
+)
+
+// ValidateCredentialRotation checks the expiry and rotation annotations of a credential
+func ValidateCredentialRotation(secret *v1.Secret) error {
+	var allErrs field.ErrorList
+	annotationsPath := field.NewPath("metadata", "annotations")
+	for _, key := range []string{CredentialExpiresAtAnnoKey, CredentialLastRotatedAnnoKey} {
+		if value, ok := secret.Annotations[key]; ok {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				allErrs = append(allErrs, field.Invalid(annotationsPath.Key(key), value,
+					"it must be a time in the RFC 3339 format, such as 2006-01-02T15:04:05Z"))
+			}
+		}
+	}
+	if value, ok := secret.Annotations[CredentialRotationPeriodAnnoKey]; ok {
+		if period, err := time.ParseDuration(value); err != nil || period <= 0 {
+			allErrs = append(allErrs, field.Invalid(annotationsPath.Key(CredentialRotationPeriodAnnoKey), value,
+				"it must be a positive duration, such as 720h"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Kind: "Secret"}, secret.Name, allErrs)
+}
+
+// GetCredentialExpiresAt returns the time when the credential expires, the zero time means it never expires
+func GetCredentialExpiresAt(secret *v1.Secret) (expiresAt time.Time) {
+	if value, ok := secret.Annotations[CredentialExpiresAtAnnoKey]; ok {
+		expiresAt, _ = time.Parse(time.RFC3339, value)
+	}
+	return
+}
+
+// GetCredentialRotationDue returns the time when the credential should be rotated next time,
+// the zero time means it has no rotation period
+func GetCredentialRotationDue(secret *v1.Secret) (due time.Time) {
+	period, err := time.ParseDuration(secret.Annotations[CredentialRotationPeriodAnnoKey])
+	if err != nil || period <= 0 {
+		return
+	}
+	lastRotated := secret.CreationTimestamp.Time
+	if value, ok := secret.Annotations[CredentialLastRotatedAnnoKey]; ok {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			lastRotated = t
+		}
+	}
+	return lastRotated.Add(period)
+}
+
+// GetCredentialConditions returns the conditions of a credential, the invalid value is ignored
+func GetCredentialConditions(secret *v1.Secret) (conditions []metav1.Condition) {
+	if value, ok := secret.Annotations[CredentialConditionsAnnoKey]; ok {
+		_ = json.Unmarshal([]byte(value), &conditions)
+	}
+	return
+}
+
+// SetCredentialConditions stores the conditions of a credential, the annotation is removed if there's no condition
+func SetCredentialConditions(secret *v1.Secret, conditions []metav1.Condition) {
+	if len(conditions) == 0 {
+		delete(secret.Annotations, CredentialConditionsAnnoKey)
+		return
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	data, _ := json.Marshal(conditions)
+	secret.Annotations[CredentialConditionsAnnoKey] = string(data)
+}
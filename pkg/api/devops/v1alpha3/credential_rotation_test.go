@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newCredentialWithAnnotations(annotations map[string]string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "fake",
+			CreationTimestamp: metav1.NewTime(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)),
+			Annotations:       annotations,
+		},
+		Type: SecretTypeBasicAuth,
+	}
+}
+
+func TestValidateCredentialRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{{
+		name: "without annotations",
+	}, {
+		name: "valid annotations",
+		annotations: map[string]string{
+			CredentialExpiresAtAnnoKey:      "2022-12-31T00:00:00Z",
+			CredentialLastRotatedAnnoKey:    "2022-06-01T08:00:00+08:00",
+			CredentialRotationPeriodAnnoKey: "720h",
+		},
+	}, {
+		name:        "invalid expiry time",
+		annotations: map[string]string{CredentialExpiresAtAnnoKey: "2022-12-31"},
+		wantErr:     true,
+	}, {
+		name:        "invalid last rotated time",
+		annotations: map[string]string{CredentialLastRotatedAnnoKey: "yesterday"},
+		wantErr:     true,
+	}, {
+		name:        "invalid rotation period",
+		annotations: map[string]string{CredentialRotationPeriodAnnoKey: "30d"},
+		wantErr:     true,
+	}, {
+		name:        "negative rotation period",
+		annotations: map[string]string{CredentialRotationPeriodAnnoKey: "-1h"},
+		wantErr:     true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCredentialRotation(newCredentialWithAnnotations(tt.annotations))
+			if tt.wantErr {
+				assert.True(t, apierrors.IsInvalid(err), err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestGetCredentialExpiresAt(t *testing.T) {
+	assert.True(t, GetCredentialExpiresAt(newCredentialWithAnnotations(nil)).IsZero())
+	assert.True(t, GetCredentialExpiresAt(newCredentialWithAnnotations(map[string]string{
+		CredentialExpiresAtAnnoKey: "invalid",
+	})).IsZero())
+	assert.Equal(t, time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC), GetCredentialExpiresAt(
+		newCredentialWithAnnotations(map[string]string{
+			CredentialExpiresAtAnnoKey: "2022-12-31T00:00:00Z",
+		})).UTC())
+}
+
+func TestGetCredentialRotationDue(t *testing.T) {
+	assert.True(t, GetCredentialRotationDue(newCredentialWithAnnotations(nil)).IsZero())
+	// since the creation time
+	assert.Equal(t, time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC), GetCredentialRotationDue(
+		newCredentialWithAnnotations(map[string]string{
+			CredentialRotationPeriodAnnoKey: "24h",
+		})).UTC())
+	// since the last rotation
+	assert.Equal(t, time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC), GetCredentialRotationDue(
+		newCredentialWithAnnotations(map[string]string{
+			CredentialRotationPeriodAnnoKey: "24h",
+			CredentialLastRotatedAnnoKey:    "2022-06-01T00:00:00Z",
+		})).UTC())
+}
+
+func TestCredentialConditions(t *testing.T) {
+	secret := newCredentialWithAnnotations(nil)
+	assert.Empty(t, GetCredentialConditions(secret))
+
+	conditions := []metav1.Condition{{
+		Type:               CredentialConditionExpiring,
+		Status:             metav1.ConditionTrue,
+		Reason:             CredentialReasonExpired,
+		LastTransitionTime: metav1.Unix(1640995200, 0),
+	}}
+	SetCredentialConditions(secret, conditions)
+	assert.Equal(t, conditions, GetCredentialConditions(secret))
+
+	SetCredentialConditions(secret, nil)
+	assert.NotContains(t, secret.Annotations, CredentialConditionsAnnoKey)
+
+	secret.Annotations[CredentialConditionsAnnoKey] = "invalid"
+	assert.Empty(t, GetCredentialConditions(secret))
+}
@@ -16,7 +16,11 @@ limitations under the License.
 
 package v1alpha3
 
-import v1 "k8s.io/api/core/v1"
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
 
 /*
 *
@@ -67,6 +71,59 @@ const (
 	SecretTypeKubeConfig v1.SecretType = DevOpsCredentialPrefix + "kubeconfig"
 	// KubeConfigSecretKey is the key of the secret for SecretTypeKubeConfig secrets
 	KubeConfigSecretKey = "content"
+
+	// SecretTypeSOPS contains the private keys to decrypt the SOPS encrypted manifests of the FluxCD Applications.
+	// The key names follow the convention of FluxCD, then the secret could be used as the decryption secret directly.
+	//
+	// Required at least one of fields:
+	// - Secret.Data["identity.agekey"] - the age private keys
+	// - Secret.Data["identity.asc"] - the armored OpenPGP private keys
+	SecretTypeSOPS v1.SecretType = DevOpsCredentialPrefix + "sops"
+	// SOPSAgeKey is the key of the age private keys for SecretTypeSOPS secrets
+	SOPSAgeKey = "identity.agekey"
+	// SOPSPGPKey is the key of the armored OpenPGP private keys for SecretTypeSOPS secrets
+	SOPSPGPKey = "identity.asc"
+
+	// SecretTypeGitHubApp contains data needed for authenticating as an installation of a GitHub App.
+	//
+	// Required fields:
+	// - Secret.Data["app_id"] - the ID of the GitHub App
+	// - Secret.Data["private_key"] - the PEM encoded private key of the GitHub App
+	// Optional fields:
+	// - Secret.Data["owner"] - the organization or user which installed the GitHub App, it's used to find the installation
+	// - Secret.Data["api_uri"] - the API endpoint of GitHub Enterprise
+	SecretTypeGitHubApp v1.SecretType = DevOpsCredentialPrefix + "github-app"
+	// GitHubAppIDKey is the key of the app ID for SecretTypeGitHubApp secrets
+	GitHubAppIDKey = "app_id"
+	// GitHubAppInstallationIDKey is the key of the installation ID, it's not accepted by SecretTypeGitHubApp secrets
+	// because Jenkins cannot use it
+	GitHubAppInstallationIDKey = "installation_id"
+	// GitHubAppPrivateKey is the key of the private key for SecretTypeGitHubApp secrets
+	GitHubAppPrivateKey = "private_key"
+	// GitHubAppOwnerKey is the key of the owner for SecretTypeGitHubApp secrets
+	GitHubAppOwnerKey = "owner"
+	// GitHubAppAPIURIKey is the key of the GitHub Enterprise API endpoint for SecretTypeGitHubApp secrets
+	GitHubAppAPIURIKey = "api_uri"
+
+	// SecretTypeDockerConfigJSON contains the auths of the image registries, the same as kubernetes.io/dockerconfigjson.
+	//
+	// Required fields:
+	// - Secret.Data[".dockerconfigjson"] - the content of ~/.docker/config.json
+	SecretTypeDockerConfigJSON v1.SecretType = DevOpsCredentialPrefix + "dockerconfigjson"
+	// DockerConfigJSONKey is the key of the docker config for SecretTypeDockerConfigJSON secrets
+	DockerConfigJSONKey = v1.DockerConfigJsonKey
+
+	// SecretTypeAccessKey contains an access key pair of a cloud provider, such as AWS or Alibaba Cloud.
+	//
+	// Required fields:
+	// - Secret.Data["access_key_id"] - the ID of the access key
+	// - Secret.Data["secret_access_key"] - the secret of the access key
+	SecretTypeAccessKey v1.SecretType = DevOpsCredentialPrefix + "access-key"
+	// AccessKeyIDKey is the key of the access key ID for SecretTypeAccessKey secrets
+	AccessKeyIDKey = "access_key_id"
+	// AccessKeySecretKey is the key of the access key secret for SecretTypeAccessKey secrets
+	AccessKeySecretKey = "secret_access_key"
+
 	//	CredentialAutoSyncAnnoKey is used to indicate whether the secret is automatically synchronized to devops.
 	//	In the old version, the credential is stored in jenkins and cannot be obtained.
 	//	This field is set to ensure that the secret is not overwritten by a nil value.
@@ -74,6 +131,20 @@ const (
 	CredentialSyncStatusAnnoKey = DevOpsCredentialPrefix + "syncstatus"
 	CredentialSyncTimeAnnoKey   = DevOpsCredentialPrefix + "synctime"
 	CredentialSyncMsgAnnoKey    = DevOpsCredentialPrefix + "syncmsg"
+
+	// CredentialSourceAnnoKey is the external secret backend which stores the data of a credential, such as vault.
+	// The data is not stored in the secret, it's resolved from the backend when the credential is used.
+	CredentialSourceAnnoKey = DevOpsCredentialPrefix + "source"
+	// CredentialSourcePathAnnoKey is the path of the credential data in the external secret backend, it's relative to
+	// the namespace of the DevOpsProject, such as github for the data at <mount>/<namespace>/github in Vault.
+	// The keys of the data in the backend should be the same as the keys of the credential type, such as username.
+	CredentialSourcePathAnnoKey = DevOpsCredentialPrefix + "source-path"
+	// CredentialSourceVault means the data of a credential is stored in a Vault-compatible KV secrets engine
+	CredentialSourceVault = "vault"
+
+	// CredentialReferenceField is the field indexer name of the credentials referenced by an object,
+	// the values are in the format of namespace/name
+	CredentialReferenceField = "credential.references"
 )
 
 var supportedCredentialTypes = []v1.SecretType{
@@ -81,6 +152,10 @@ var supportedCredentialTypes = []v1.SecretType{
 	SecretTypeSSHAuth,
 	SecretTypeSecretText,
 	SecretTypeKubeConfig,
+	SecretTypeSOPS,
+	SecretTypeGitHubApp,
+	SecretTypeDockerConfigJSON,
+	SecretTypeAccessKey,
 }
 
 // GetSupportedCredentialTypes gets all supported credential types. The return value is unmodifiable.
@@ -89,3 +164,14 @@ func GetSupportedCredentialTypes() []v1.SecretType {
 	copy(copiedCredentialTypes, supportedCredentialTypes)
 	return copiedCredentialTypes
 }
+
+// IsExternalCredential returns true if the data of the credential is stored in an external secret backend
+func IsExternalCredential(secret *v1.Secret) bool {
+	return secret.Annotations[CredentialSourceAnnoKey] != ""
+}
+
+// IsJenkinsCredential returns true if the secret is a credential which should be synchronized to Jenkins.
+// The SOPS keys are only used by FluxCD, they are never sent to Jenkins.
+func IsJenkinsCredential(secret *v1.Secret) bool {
+	return strings.HasPrefix(string(secret.Type), DevOpsCredentialPrefix) && secret.Type != SecretTypeSOPS
+}
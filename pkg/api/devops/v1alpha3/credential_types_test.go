@@ -20,6 +20,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestGetSupportedCredentialTypes(t *testing.T) {
@@ -34,3 +36,19 @@ func TestGetSupportedCredentialTypes(t *testing.T) {
 	assert.NotEqual(t, types, GetSupportedCredentialTypes())
 	assert.Equal(t, supportedCredentialTypes, GetSupportedCredentialTypes())
 }
+
+func TestIsJenkinsCredential(t *testing.T) {
+	assert.True(t, IsJenkinsCredential(&v1.Secret{Type: SecretTypeBasicAuth}))
+	assert.True(t, IsJenkinsCredential(&v1.Secret{Type: SecretTypeKubeConfig}))
+	assert.True(t, IsJenkinsCredential(&v1.Secret{Type: SecretTypeGitHubApp}))
+	assert.True(t, IsJenkinsCredential(&v1.Secret{Type: SecretTypeDockerConfigJSON}))
+	assert.False(t, IsJenkinsCredential(&v1.Secret{Type: SecretTypeSOPS}))
+	assert.False(t, IsJenkinsCredential(&v1.Secret{Type: v1.SecretTypeOpaque}))
+}
+
+func TestIsExternalCredential(t *testing.T) {
+	assert.False(t, IsExternalCredential(&v1.Secret{Type: SecretTypeBasicAuth}))
+	assert.True(t, IsExternalCredential(&v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{CredentialSourceAnnoKey: CredentialSourceVault},
+	}}))
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateCredentialData checks the data of a credential according to its type.
+// The data of an external credential is stored in its backend, so it's not checked.
+func ValidateCredentialData(secret *v1.Secret) error {
+	if IsExternalCredential(secret) {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	dataPath := field.NewPath("data")
+	switch secret.Type {
+	case SecretTypeGitHubApp:
+		value := GetCredentialValue(secret, GitHubAppIDKey)
+		if id, err := strconv.ParseInt(value, 10, 64); err != nil || id <= 0 {
+			allErrs = append(allErrs, field.Invalid(dataPath.Key(GitHubAppIDKey), value, "it must be a positive integer"))
+		}
+		if GetCredentialValue(secret, GitHubAppInstallationIDKey) != "" {
+			allErrs = append(allErrs, field.Forbidden(dataPath.Key(GitHubAppInstallationIDKey),
+				"Jenkins finds the installation by the owner, please set the owner instead"))
+		}
+		if block, _ := pem.Decode([]byte(GetCredentialValue(secret, GitHubAppPrivateKey))); block == nil ||
+			(block.Type != "RSA PRIVATE KEY" && block.Type != "PRIVATE KEY") {
+			// don't show the private key in the error message
+			allErrs = append(allErrs, field.Invalid(dataPath.Key(GitHubAppPrivateKey), "",
+				"it must be a PEM encoded RSA private key"))
+		}
+		if value := GetCredentialValue(secret, GitHubAppAPIURIKey); value != "" {
+			if _, err := url.ParseRequestURI(value); err != nil {
+				allErrs = append(allErrs, field.Invalid(dataPath.Key(GitHubAppAPIURIKey), value, err.Error()))
+			}
+		}
+	case SecretTypeDockerConfigJSON:
+		dockerConfig := struct {
+			Auths map[string]json.RawMessage `json:"auths"`
+		}{}
+		if err := json.Unmarshal([]byte(GetCredentialValue(secret, DockerConfigJSONKey)), &dockerConfig); err != nil ||
+			len(dockerConfig.Auths) == 0 {
+			allErrs = append(allErrs, field.Invalid(dataPath.Key(DockerConfigJSONKey), "",
+				"it must be a docker config in JSON with at least one item in auths"))
+		}
+	case SecretTypeAccessKey:
+		for _, key := range []string{AccessKeyIDKey, AccessKeySecretKey} {
+			if GetCredentialValue(secret, key) == "" {
+				allErrs = append(allErrs, field.Required(dataPath.Key(key), ""))
+			}
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Kind: "Secret"}, secret.Name, allErrs)
+}
+
+// GetCredentialValue returns the value of the key in the stringData or data of a credential
+func GetCredentialValue(secret *v1.Secret, key string) string {
+	if value, ok := secret.StringData[key]; ok {
+		return value
+	}
+	return string(secret.Data[key])
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateCredentialData(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	tests := []struct {
+		name    string
+		secret  *v1.Secret
+		wantErr bool
+	}{{
+		name:   "basic auth is not checked",
+		secret: &v1.Secret{Type: SecretTypeBasicAuth},
+	}, {
+		name: "valid GitHub App",
+		secret: &v1.Secret{
+			Type: SecretTypeGitHubApp,
+			Data: map[string][]byte{
+				GitHubAppIDKey:      []byte("12345"),
+				GitHubAppPrivateKey: []byte(privateKey),
+				GitHubAppOwnerKey:   []byte("kubesphere"),
+			},
+			StringData: map[string]string{GitHubAppAPIURIKey: "https://github.example.com/api/v3"},
+		},
+	}, {
+		name: "GitHub App with an invalid app ID",
+		secret: &v1.Secret{
+			Type: SecretTypeGitHubApp,
+			StringData: map[string]string{
+				GitHubAppIDKey:      "app",
+				GitHubAppPrivateKey: privateKey,
+			},
+		},
+		wantErr: true,
+	}, {
+		name: "GitHub App with an invalid private key",
+		secret: &v1.Secret{
+			Type: SecretTypeGitHubApp,
+			StringData: map[string]string{
+				GitHubAppIDKey:      "12345",
+				GitHubAppPrivateKey: "private key",
+			},
+		},
+		wantErr: true,
+	}, {
+		name: "GitHub App with an installation ID",
+		secret: &v1.Secret{
+			Type: SecretTypeGitHubApp,
+			StringData: map[string]string{
+				GitHubAppIDKey:             "12345",
+				GitHubAppInstallationIDKey: "67890",
+				GitHubAppPrivateKey:        privateKey,
+			},
+		},
+		wantErr: true,
+	}, {
+		name: "valid docker config",
+		secret: &v1.Secret{
+			Type: SecretTypeDockerConfigJSON,
+			StringData: map[string]string{
+				DockerConfigJSONKey: `{"auths":{"docker.io":{"auth":"YWRtaW46cGFzc3dvcmQ="}}}`,
+			},
+		},
+	}, {
+		name: "docker config without auths",
+		secret: &v1.Secret{
+			Type: SecretTypeDockerConfigJSON,
+			Data: map[string][]byte{DockerConfigJSONKey: []byte(`{}`)},
+		},
+		wantErr: true,
+	}, {
+		name: "valid access key",
+		secret: &v1.Secret{
+			Type: SecretTypeAccessKey,
+			StringData: map[string]string{
+				AccessKeyIDKey:     "id",
+				AccessKeySecretKey: "secret",
+			},
+		},
+	}, {
+		name: "access key without the secret",
+		secret: &v1.Secret{
+			Type:       SecretTypeAccessKey,
+			StringData: map[string]string{AccessKeyIDKey: "id"},
+		},
+		wantErr: true,
+	}, {
+		name: "external credential is not checked",
+		secret: &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				CredentialSourceAnnoKey: CredentialSourceVault,
+			}},
+			Type: SecretTypeAccessKey,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCredentialData(tt.secret)
+			if tt.wantErr {
+				assert.True(t, apierrors.IsInvalid(err), err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestGetCredentialValue(t *testing.T) {
+	secret := &v1.Secret{
+		Data:       map[string][]byte{"a": []byte("data"), "b": []byte("data")},
+		StringData: map[string]string{"b": "string"},
+	}
+	assert.Equal(t, "data", GetCredentialValue(secret, "a"))
+	assert.Equal(t, "string", GetCredentialValue(secret, "b"))
+	assert.Empty(t, GetCredentialValue(secret, "c"))
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// SupportedAgentResources are the resources of the Jenkins agents which could be limited
+var SupportedAgentResources = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+
+// agentLabelPatterns match the agent labels in a Jenkinsfile, such as:
+// agent { label 'maven' }, agent { kubernetes { inheritFrom 'maven' } } and node('maven')
+var agentLabelPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\blabel\s*\(?\s*['"]([^'"]+)['"]`),
+	regexp.MustCompile(`\binheritFrom\s*\(?\s*['"]([^'"]+)['"]`),
+	regexp.MustCompile(`\bnode\s*\(\s*(?:label\s*:\s*)?['"]([^'"]+)['"]`),
+}
+
+// agentAnyPattern matches the agent which could be any node, such as: agent any
+var agentAnyPattern = regexp.MustCompile(`\bagent\s+any\b`)
+
+// agentPodPattern matches the triple-quoted inline pod of a Kubernetes agent, such as: agent { kubernetes { yaml ... } }
+var agentPodPattern = regexp.MustCompile(`\byaml\s*\(?\s*(?:'''|""")([\s\S]*?)(?:'''|""")`)
+
+// GetJenkinsfileAgentLabels returns the sorted unique agent labels of a Jenkinsfile,
+// the label expressions like 'maven && !windows' are split into the labels
+func GetJenkinsfileAgentLabels(jenkinsfile string) []string {
+	found := map[string]bool{}
+	labels := make([]string, 0)
+	for _, pattern := range agentLabelPatterns {
+		for _, match := range pattern.FindAllStringSubmatch(jenkinsfile, -1) {
+			for _, label := range strings.FieldsFunc(match[1], isLabelExpressionSeparator) {
+				if !found[label] {
+					found[label] = true
+					labels = append(labels, label)
+				}
+			}
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func isLabelExpressionSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("&|!()", r)
+}
+
+// IsJenkinsfileAgentAny returns true if a Jenkinsfile could run on any agent
+func IsJenkinsfileAgentAny(jenkinsfile string) bool {
+	return agentAnyPattern.MatchString(jenkinsfile)
+}
+
+// GetJenkinsfileAgentPods returns the inline pods of the Kubernetes agents in a Jenkinsfile,
+// the pods which cannot be parsed are skipped because Jenkins cannot run them either
+func GetJenkinsfileAgentPods(jenkinsfile string) (pods []v1.Pod) {
+	for _, match := range agentPodPattern.FindAllStringSubmatch(jenkinsfile, -1) {
+		pod := v1.Pod{}
+		if err := yaml.Unmarshal([]byte(match[1]), &pod); err == nil {
+			pods = append(pods, pod)
+		}
+	}
+	return
+}
+
+// GetAgentResources returns the CPU and memory of an agent pod,
+// the limit of a container is preferred to its request
+func GetAgentResources(spec *v1.PodSpec) v1.ResourceList {
+	resources := v1.ResourceList{}
+	for _, container := range spec.Containers {
+		for _, name := range SupportedAgentResources {
+			quantity, ok := container.Resources.Limits[name]
+			if !ok {
+				if quantity, ok = container.Resources.Requests[name]; !ok {
+					continue
+				}
+			}
+			total := resources[name]
+			total.Add(quantity)
+			resources[name] = total
+		}
+	}
+	return resources
+}
+
+// IsAgentLabelAllowed returns true if the Pipelines could run on the agents of the label
+func (l *DevOpsProjectLimits) IsAgentLabelAllowed(label string) bool {
+	if l == nil || len(l.AllowedAgentLabels) == 0 {
+		return true
+	}
+	for _, allowed := range l.AllowedAgentLabels {
+		if allowed == label {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAgentResources returns an error if the resources of an agent exceed the limits
+func (l *DevOpsProjectLimits) ValidateAgentResources(resources v1.ResourceList) error {
+	if l == nil {
+		return nil
+	}
+	var exceeded []string
+	for _, name := range SupportedAgentResources {
+		max, ok := l.MaxAgentResources[name]
+		if !ok || max.IsZero() {
+			continue
+		}
+		if quantity, ok := resources[name]; ok && quantity.Cmp(max) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s %s exceeds the limit %s", name, quantity.String(), max.String()))
+		}
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(exceeded, ", "))
+}
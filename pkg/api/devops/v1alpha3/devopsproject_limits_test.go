@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const agentsJenkinsfile = `pipeline {
+  agent {
+    node {
+      label 'maven && !windows'
+    }
+  }
+  stages {
+    stage('build') {
+      agent {
+        kubernetes {
+          inheritFrom "go"
+          yaml '''
+spec:
+  containers:
+  - name: go
+    image: golang:1.17
+    resources:
+      limits:
+        cpu: 1
+        memory: 1Gi
+  - name: jnlp
+    image: jenkins/inbound-agent
+    resources:
+      requests:
+        cpu: 500m
+'''
+        }
+      }
+      steps {
+        sh label: 'compile', script: 'go build'
+      }
+    }
+  }
+}
+node('(base || nodejs)') {
+  echo 'scripted'
+}`
+
+func TestGetJenkinsfileAgentLabels(t *testing.T) {
+	assert.Equal(t, []string{"base", "go", "maven", "nodejs", "windows"}, GetJenkinsfileAgentLabels(agentsJenkinsfile))
+	assert.Equal(t, []string{}, GetJenkinsfileAgentLabels("pipeline { agent any }"))
+	assert.Equal(t, []string{"maven"}, GetJenkinsfileAgentLabels("node(label: 'maven') {}"))
+}
+
+func TestIsJenkinsfileAgentAny(t *testing.T) {
+	assert.True(t, IsJenkinsfileAgentAny("pipeline {\n  agent any\n}"))
+	assert.False(t, IsJenkinsfileAgentAny(agentsJenkinsfile))
+}
+
+func TestGetJenkinsfileAgentPods(t *testing.T) {
+	pods := GetJenkinsfileAgentPods(agentsJenkinsfile)
+	if assert.Equal(t, 1, len(pods)) {
+		resources := GetAgentResources(&pods[0].Spec)
+		assert.Equal(t, "1500m", resources.Cpu().String())
+		assert.Equal(t, "1Gi", resources.Memory().String())
+	}
+	assert.Empty(t, GetJenkinsfileAgentPods("pipeline { agent { kubernetes { yaml '''spec: [invalid''' } } }"))
+}
+
+func TestDevOpsProjectLimits(t *testing.T) {
+	var unlimited *DevOpsProjectLimits
+	assert.True(t, unlimited.IsAgentLabelAllowed("maven"))
+	assert.Nil(t, unlimited.ValidateAgentResources(v1.ResourceList{v1.ResourceCPU: resource.MustParse("64")}))
+
+	limits := &DevOpsProjectLimits{
+		MaxAgentResources: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("2"),
+			v1.ResourceMemory: resource.MustParse("0"),
+		},
+		AllowedAgentLabels: []string{"base", "maven"},
+	}
+	assert.True(t, limits.IsAgentLabelAllowed("maven"))
+	assert.False(t, limits.IsAgentLabelAllowed("go"))
+	assert.Nil(t, limits.ValidateAgentResources(v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("2"),
+		v1.ResourceMemory: resource.MustParse("64Gi"),
+	}))
+	assert.EqualError(t, limits.ValidateAgentResources(v1.ResourceList{v1.ResourceCPU: resource.MustParse("2500m")}),
+		"cpu 2500m exceeds the limit 2")
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha3
 
 import (
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,6 +37,30 @@ const (
 // DevOpsProjectSpec defines the desired state of DevOpsProject
 type DevOpsProjectSpec struct {
 	Argo *Argo `json:"argo,omitempty"`
+	// Limits are the quotas and policies of the Pipelines in this DevOpsProject
+	Limits *DevOpsProjectLimits `json:"limits,omitempty"`
+}
+
+// DevOpsProjectLimits are the quotas and policies of the Pipelines in a DevOpsProject, a zero value means no limit
+type DevOpsProjectLimits struct {
+	// MaxConcurrentPipelineRuns is the max number of the running PipelineRuns, the others wait in the Queued phase
+	MaxConcurrentPipelineRuns int32 `json:"maxConcurrentPipelineRuns,omitempty"`
+	// MaxPipelines is the max number of the Pipelines
+	MaxPipelines int32 `json:"maxPipelines,omitempty"`
+	// MaxAgentResources are the max CPU and memory of a Jenkins agent which the Pipelines run on
+	MaxAgentResources v1.ResourceList `json:"maxAgentResources,omitempty"`
+	// AllowedAgentLabels are the labels of the Jenkins agents which the Pipelines could run on
+	AllowedAgentLabels []string `json:"allowedAgentLabels,omitempty"`
+}
+
+// DevOpsProjectUsage is the usage of the limits of a DevOpsProject
+type DevOpsProjectUsage struct {
+	// Pipelines is the number of the Pipelines
+	Pipelines int32 `json:"pipelines"`
+	// RunningPipelineRuns is the number of the PipelineRuns which are running in Jenkins
+	RunningPipelineRuns int32 `json:"runningPipelineRuns"`
+	// QueuedPipelineRuns is the number of the PipelineRuns which are waiting to be triggered
+	QueuedPipelineRuns int32 `json:"queuedPipelineRuns"`
 }
 
 // Argo represents the Argo CD specification
@@ -143,6 +168,8 @@ type SignatureKey struct {
 // DevOpsProjectStatus defines the observed state of DevOpsProject
 type DevOpsProjectStatus struct {
 	AdminNamespace string `json:"adminNamespace,omitempty"`
+	// Usage is the usage of the limits, it's reported even if there are no limits
+	Usage *DevOpsProjectUsage `json:"usage,omitempty"`
 }
 
 // +genclient
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the validating webhook of DevOpsProject.
+func (p *DevOpsProject) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(p).Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-devopsproject,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=devopsprojects,verbs=create;update,versions=v1alpha3,name=vdevopsproject.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &DevOpsProject{}
+
+// ValidateCreate validates the DevOpsProject when it's created.
+func (p *DevOpsProject) ValidateCreate() error {
+	return p.validate()
+}
+
+// ValidateUpdate validates the DevOpsProject when its spec changed.
+func (p *DevOpsProject) ValidateUpdate(old runtime.Object) error {
+	if oldProject, ok := old.(*DevOpsProject); ok && reflect.DeepEqual(oldProject.Spec, p.Spec) {
+		return nil
+	}
+	return p.validate()
+}
+
+// ValidateDelete allows to delete any DevOpsProject.
+func (p *DevOpsProject) ValidateDelete() error {
+	return nil
+}
+
+func (p *DevOpsProject) validate() error {
+	var allErrs field.ErrorList
+	if p.Spec.Argo != nil {
+		allErrs = validateSyncWindows(p.Spec.Argo.SyncWindows, field.NewPath("spec", "argo", "syncWindows"))
+	}
+	if p.Spec.Limits != nil {
+		allErrs = append(allErrs, validateLimits(p.Spec.Limits, field.NewPath("spec", "limits"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("DevOpsProject").GroupKind(), p.Name, allErrs)
+}
+
+func validateSyncWindows(windows SyncWindows, fldPath *field.Path) (allErrs field.ErrorList) {
+	for i, window := range windows {
+		if window == nil {
+			continue
+		}
+		windowPath := fldPath.Index(i)
+		switch window.Kind {
+		case SyncWindowKindAllow, SyncWindowKindDeny:
+		default:
+			allErrs = append(allErrs, field.NotSupported(windowPath.Child("kind"), window.Kind,
+				[]string{SyncWindowKindAllow, SyncWindowKindDeny}))
+		}
+		if _, err := cron.ParseStandard(window.Schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("schedule"), window.Schedule,
+				"it is not a valid cron schedule: "+err.Error()))
+		}
+		if duration, err := time.ParseDuration(window.Duration); err != nil || duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("duration"), window.Duration,
+				"it must be a positive duration, such as 1h or 30m"))
+		}
+		if _, err := time.LoadLocation(window.TimeZone); err != nil {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("timeZone"), window.TimeZone,
+				"it is not a valid time zone: "+err.Error()))
+		}
+	}
+	return
+}
+
+func validateLimits(limits *DevOpsProjectLimits, fldPath *field.Path) (allErrs field.ErrorList) {
+	if limits.MaxConcurrentPipelineRuns < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxConcurrentPipelineRuns"),
+			limits.MaxConcurrentPipelineRuns, "it must be greater than or equal to 0"))
+	}
+	if limits.MaxPipelines < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxPipelines"), limits.MaxPipelines,
+			"it must be greater than or equal to 0"))
+	}
+	supported := make([]string, len(SupportedAgentResources))
+	for i, name := range SupportedAgentResources {
+		supported[i] = string(name)
+	}
+	for name, quantity := range limits.MaxAgentResources {
+		resourcePath := fldPath.Child("maxAgentResources").Key(string(name))
+		if name != v1.ResourceCPU && name != v1.ResourceMemory {
+			allErrs = append(allErrs, field.NotSupported(resourcePath, name, supported))
+		} else if quantity.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(resourcePath, quantity.String(), "it must be greater than or equal to 0"))
+		}
+	}
+	for i, label := range limits.AllowedAgentLabels {
+		if strings.TrimSpace(label) == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("allowedAgentLabels").Index(i), "the label cannot be empty"))
+		}
+	}
+	return
+}
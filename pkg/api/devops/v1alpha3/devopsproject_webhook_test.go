@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestDevOpsProject_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		argo       *Argo
+		limits     *DevOpsProjectLimits
+		wantFields []string
+	}{{
+		name: "without Argo settings",
+	}, {
+		name: "valid sync windows",
+		argo: &Argo{SyncWindows: SyncWindows{
+			{Kind: SyncWindowKindAllow, Schedule: "0 22 * * mon-fri", Duration: "8h", TimeZone: "Asia/Shanghai"},
+			{Kind: SyncWindowKindDeny, Schedule: "@daily", Duration: "30m"},
+		}},
+	}, {
+		name: "invalid sync windows",
+		argo: &Argo{SyncWindows: SyncWindows{
+			{Kind: SyncWindowKindAllow, Schedule: "0 22 * *", Duration: "8h"},
+			{Kind: "block", Schedule: "0 25 * * *", Duration: "-1h", TimeZone: "Mars/Olympus"},
+		}},
+		wantFields: []string{
+			"spec.argo.syncWindows[0].schedule",
+			"spec.argo.syncWindows[1].kind",
+			"spec.argo.syncWindows[1].schedule",
+			"spec.argo.syncWindows[1].duration",
+			"spec.argo.syncWindows[1].timeZone",
+		},
+	}, {
+		name: "valid limits",
+		limits: &DevOpsProjectLimits{
+			MaxConcurrentPipelineRuns: 2,
+			MaxPipelines:              10,
+			MaxAgentResources: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("2"),
+				v1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+			AllowedAgentLabels: []string{"base", "maven"},
+		},
+	}, {
+		name: "invalid limits",
+		limits: &DevOpsProjectLimits{
+			MaxConcurrentPipelineRuns: -1,
+			MaxPipelines:              -1,
+			MaxAgentResources: v1.ResourceList{
+				v1.ResourceCPU:              resource.MustParse("-1"),
+				v1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+			},
+			AllowedAgentLabels: []string{"base", " "},
+		},
+		wantFields: []string{
+			"spec.limits.maxConcurrentPipelineRuns",
+			"spec.limits.maxPipelines",
+			"spec.limits.maxAgentResources[cpu]",
+			"spec.limits.maxAgentResources[ephemeral-storage]",
+			"spec.limits.allowedAgentLabels[1]",
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &DevOpsProject{Spec: DevOpsProjectSpec{Argo: tt.argo, Limits: tt.limits}}
+			assertInvalidFields(t, project.ValidateCreate(), tt.wantFields)
+			assertInvalidFields(t, project.ValidateUpdate(&DevOpsProject{}), tt.wantFields)
+			// the unchanged spec is not validated
+			assert.Nil(t, project.ValidateUpdate(project.DeepCopy()))
+			assert.Nil(t, project.ValidateDelete())
+		})
+	}
+}
@@ -36,6 +36,22 @@ const (
 	PipelineNameLabelKey = devops.GroupName + "/pipeline"
 	// PipelineRunCreatorAnnoKey is annotation key of PipelineRun's creator
 	PipelineRunCreatorAnnoKey = devops.GroupName + "/creator"
+	// PipelineRunGitURLAnnoKey is annotation key of the git repository URL which triggered the PipelineRun.
+	PipelineRunGitURLAnnoKey = devops.GroupName + "/git-url"
+	// PipelineRunRetryOfAnnoKey is annotation key of the first PipelineRun which a retried PipelineRun comes from.
+	PipelineRunRetryOfAnnoKey = devops.GroupName + "/retry-of"
+	// PipelineRunAttemptAnnoKey is annotation key of the attempt number of a retried PipelineRun.
+	PipelineRunAttemptAnnoKey = devops.GroupName + "/attempt"
+	// PipelineRunConcurrencyGroupLabelKey is label key of the concurrency group which a PipelineRun belongs to.
+	PipelineRunConcurrencyGroupLabelKey = devops.GroupName + "/concurrency-group"
+	// PipelineRunNameLabelKey is label key of the PipelineRun which a Pod of the Kubernetes engine belongs to.
+	PipelineRunNameLabelKey = devops.GroupName + "/pipelinerun"
+	// PipelineRunJenkinsfileAnnoKey is annotation key of the JSON Jenkinsfile which a PipelineRun of the Kubernetes
+	// engine runs. It's a snapshot, so the changes of the Pipeline won't affect the running PipelineRun.
+	PipelineRunJenkinsfileAnnoKey = devops.GroupName + "/jenkinsfile"
+	// PipelineRunCheckoutURLAnnoKey is annotation key of the git repository URL which a PipelineRun of the Kubernetes
+	// engine checks out into its workspace. It's a snapshot as well.
+	PipelineRunCheckoutURLAnnoKey = devops.GroupName + "/checkout-url"
 	// PipelineRunSCMRefNameField is the field name of SCM reference name in PipelineRun spec.
 	PipelineRunSCMRefNameField = "spec.scm.ref-name"
 	// PipelineRunIdentifierIndexerName is an indexer name of PipelineRun identifier.
@@ -47,12 +47,23 @@ const (
 	PipelineJenkinsfileEditModeAnnoKey = PipelinePrefix + "jenkinsfile.edit.mode"
 	// PipelineJenkinsfileValidateAnnoKey is the annotation key of the Jenkinsfile validate, success or failure
 	PipelineJenkinsfileValidateAnnoKey = PipelinePrefix + "jenkinsfile.validate"
+	// PipelineEngineAnnoKey is the annotation key of the engine which runs the Pipeline, it's Jenkins by default
+	PipelineEngineAnnoKey = PipelinePrefix + "engine"
+	// PipelineLastRunIDAnnoKey is the annotation key of the last run ID which the Kubernetes engine allocated
+	PipelineLastRunIDAnnoKey = PipelinePrefix + "last-run-id"
+	// PipelineSCMAnnoKey is the annotation key of the git repository URL of a Pipeline which is not multi-branch
+	PipelineSCMAnnoKey = "scm.devops.kubesphere.io"
 
 	// PipelineJenkinsfileEditModeJSON indicates the Jenkinsfile editing mode is JSON
 	PipelineJenkinsfileEditModeJSON = "json"
 	// PipelineJenkinsfileEditModeRaw indicates the Jenkinsfile editing mode is groovy
 	PipelineJenkinsfileEditModeRaw = "raw"
 
+	// PipelineEngineJenkins indicates the PipelineRuns are run by Jenkins
+	PipelineEngineJenkins = "jenkins"
+	// PipelineEngineKubernetes indicates the PipelineRuns are run as Kubernetes Pods, it only supports the JSON Jenkinsfile
+	PipelineEngineKubernetes = "kubernetes"
+
 	// PipelineJenkinsfileValidateSuccess indicates the Jenkinsfile validate is success
 	PipelineJenkinsfileValidateSuccess = "success"
 	// PipelineJenkinsfileValidateFailure indicates the Jenkinsfile validate is failure
@@ -64,6 +75,18 @@ type PipelineSpec struct {
 	Type                PipelineType         `json:"type" description:"type of devops pipeline, in scm or no scm"`
 	Pipeline            *NoScmPipeline       `json:"pipeline,omitempty" description:"no scm pipeline structs"`
 	MultiBranchPipeline *MultiBranchPipeline `json:"multi_branch_pipeline,omitempty" description:"in scm pipeline structs"`
+	Concurrency         *ConcurrencyPolicy   `json:"concurrency,omitempty" description:"limits the running PipelineRuns across Pipelines"`
+}
+
+// ConcurrencyPolicy limits the running PipelineRuns of all Pipelines which are in the same group of a DevOpsProject.
+type ConcurrencyPolicy struct {
+	// Group is the name of the concurrency group, only one PipelineRun of a group runs at a time.
+	// It must be a valid label value.
+	Group string `json:"group" description:"the name of the concurrency group, only one PipelineRun of a group runs at a time"`
+
+	// CancelInProgress indicates whether to stop the running and queued PipelineRuns of the group when a newer one arrives.
+	// +optional
+	CancelInProgress bool `json:"cancelInProgress,omitempty" description:"whether to stop the older PipelineRuns of the group when a newer one arrives"`
 }
 
 // PipelineStatus defines the observed state of Pipeline
@@ -105,6 +128,14 @@ func (p *Pipeline) IsMultiBranch() bool {
 	return p.Spec.Type == MultiBranchPipelineType
 }
 
+// GetEngine returns the engine which runs the PipelineRuns of this Pipeline.
+func (p *Pipeline) GetEngine() string {
+	if p != nil && p.Annotations[PipelineEngineAnnoKey] == PipelineEngineKubernetes {
+		return PipelineEngineKubernetes
+	}
+	return PipelineEngineJenkins
+}
+
 // PipelineType is an alias of string that represents the type of Pipelines
 type PipelineType string
 
@@ -132,6 +163,7 @@ type NoScmPipeline struct {
 	RemoteTrigger     *RemoteTrigger        `json:"remote_trigger,omitempty" mapstructure:"remote_trigger" description:"Remote api define to trigger pipeline run"`
 	GenericWebhook    *GenericWebhook       `json:"generic_webhook,omitempty" mapstructure:"generic_webhook" description:"Generic webhook config"`
 	Jenkinsfile       string                `json:"jenkinsfile,omitempty" description:"Jenkinsfile's content'"`
+	Retry             *RetryPolicy          `json:"retry,omitempty" description:"The policy to re-run the failed PipelineRuns automatically"`
 }
 
 type MultiBranchPipeline struct {
@@ -270,6 +302,11 @@ type ParameterDefinition struct {
 	Description  string `json:"description,omitempty" description:"description of pipeline"`
 }
 
+// IsSecret returns true if the value of the parameter is a password or credentials, it should not be exposed
+func (p ParameterDefinition) IsSecret() bool {
+	return p.Type == "password" || p.Type == "credentials"
+}
+
 type TimerTrigger struct {
 	// user in no scm job
 	Cron string `json:"cron,omitempty" description:"jenkins cron script"`
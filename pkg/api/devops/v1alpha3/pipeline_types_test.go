@@ -18,6 +18,7 @@ package v1alpha3
 
 import (
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"testing"
 )
 
@@ -124,3 +125,38 @@ func TestMultiBranchPipeline_GetGitURL(t *testing.T) {
 		})
 	}
 }
+
+func TestPipeline_GetEngine(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline *Pipeline
+		want     string
+	}{{
+		name:     "Should return jenkins if the Pipeline is nil",
+		pipeline: nil,
+		want:     PipelineEngineJenkins,
+	}, {
+		name:     "Should return jenkins if there is no engine annotation",
+		pipeline: &Pipeline{},
+		want:     PipelineEngineJenkins,
+	}, {
+		name: "Should return jenkins if the engine is unknown",
+		pipeline: &Pipeline{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{PipelineEngineAnnoKey: "fake"},
+		}},
+		want: PipelineEngineJenkins,
+	}, {
+		name: "Should return kubernetes if the engine is kubernetes",
+		pipeline: &Pipeline{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{PipelineEngineAnnoKey: PipelineEngineKubernetes},
+		}},
+		want: PipelineEngineKubernetes,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pipeline.GetEngine(); got != tt.want {
+				t.Errorf("Pipeline.GetEngine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
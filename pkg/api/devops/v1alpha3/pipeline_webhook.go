@@ -0,0 +1,278 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of Pipeline.
+func (p *Pipeline) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(p).Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-devops-kubesphere-io-v1alpha3-pipeline,mutating=true,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelines,verbs=create;update,versions=v1alpha3,name=mpipeline.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Pipeline{}
+
+// Default sets the type and the name of the Pipeline spec if they are missing.
+func (p *Pipeline) Default() {
+	if p.Spec.Type == "" {
+		if p.Spec.MultiBranchPipeline != nil && p.Spec.Pipeline == nil {
+			p.Spec.Type = MultiBranchPipelineType
+		} else if p.Spec.Pipeline != nil {
+			p.Spec.Type = NoScmPipelineType
+		}
+	}
+	if p.Spec.Pipeline != nil && p.Spec.Pipeline.Name == "" {
+		p.Spec.Pipeline.Name = p.Name
+	}
+	if p.Spec.MultiBranchPipeline != nil && p.Spec.MultiBranchPipeline.Name == "" {
+		p.Spec.MultiBranchPipeline.Name = p.Name
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-pipeline,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelines,verbs=create;update,versions=v1alpha3,name=vpipeline.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Pipeline{}
+
+// ValidateCreate validates the Pipeline when it's created.
+func (p *Pipeline) ValidateCreate() error {
+	return p.validate()
+}
+
+// ValidateUpdate validates the Pipeline when its spec changed.
+// The Pipelines which were created before the webhook should be allowed to update metadata, such as finalizers.
+func (p *Pipeline) ValidateUpdate(old runtime.Object) error {
+	if oldPipeline, ok := old.(*Pipeline); ok && reflect.DeepEqual(oldPipeline.Spec, p.Spec) {
+		return nil
+	}
+	return p.validate()
+}
+
+// ValidateDelete allows to delete any Pipeline.
+func (p *Pipeline) ValidateDelete() error {
+	return nil
+}
+
+func (p *Pipeline) validate() error {
+	allErrs := validatePipelineSpec(&p.Spec, field.NewPath("spec"))
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Pipeline").GroupKind(), p.Name, allErrs)
+}
+
+func validatePipelineSpec(spec *PipelineSpec, fldPath *field.Path) (allErrs field.ErrorList) {
+	switch spec.Type {
+	case NoScmPipelineType:
+		if spec.Pipeline == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("pipeline"),
+				fmt.Sprintf("it is required by the Pipeline type %q", spec.Type)))
+		} else {
+			allErrs = append(allErrs, validateNoScmPipeline(spec.Pipeline, fldPath.Child("pipeline"))...)
+		}
+	case MultiBranchPipelineType:
+		if spec.MultiBranchPipeline == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("multi_branch_pipeline"),
+				fmt.Sprintf("it is required by the Pipeline type %q", spec.Type)))
+		} else {
+			allErrs = append(allErrs, validateMultiBranchPipeline(spec.MultiBranchPipeline,
+				fldPath.Child("multi_branch_pipeline"))...)
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), spec.Type,
+			[]string{string(NoScmPipelineType), string(MultiBranchPipelineType)}))
+	}
+	allErrs = append(allErrs, validateConcurrencyPolicy(spec.Concurrency, fldPath.Child("concurrency"))...)
+	return
+}
+
+func validateNoScmPipeline(pipeline *NoScmPipeline, fldPath *field.Path) (allErrs field.ErrorList) {
+	if pipeline.TimerTrigger != nil && pipeline.TimerTrigger.Cron != "" {
+		if err := validateJenkinsCron(pipeline.TimerTrigger.Cron); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("timer_trigger", "cron"), pipeline.TimerTrigger.Cron,
+				err.Error()))
+		}
+	}
+
+	names := make(map[string]bool, len(pipeline.Parameters))
+	for i, param := range pipeline.Parameters {
+		namePath := fldPath.Child("parameters").Index(i).Child("name")
+		if param.Name == "" {
+			allErrs = append(allErrs, field.Required(namePath, "the name of a parameter is required"))
+		} else if names[param.Name] {
+			allErrs = append(allErrs, field.Duplicate(namePath, param.Name))
+		}
+		names[param.Name] = true
+	}
+
+	allErrs = append(allErrs, validateRetryPolicy(pipeline.Retry, fldPath.Child("retry"))...)
+	return
+}
+
+func validateMultiBranchPipeline(pipeline *MultiBranchPipeline, fldPath *field.Path) (allErrs field.ErrorList) {
+	sources := map[string]struct {
+		name string
+		set  bool
+	}{
+		SourceTypeGit:       {"git_source", pipeline.GitSource != nil},
+		SourceTypeGithub:    {"github_source", pipeline.GitHubSource != nil},
+		SourceTypeGitlab:    {"gitlab_source", pipeline.GitlabSource != nil},
+		SourceTypeSVN:       {"svn_source", pipeline.SvnSource != nil},
+		SourceTypeSingleSVN: {"single_svn_source", pipeline.SingleSvnSource != nil},
+		SourceTypeBitbucket: {"bitbucket_server_source", pipeline.BitbucketServerSource != nil},
+	}
+	if source, ok := sources[pipeline.SourceType]; !ok {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("source_type"), pipeline.SourceType,
+			[]string{SourceTypeGit, SourceTypeGithub, SourceTypeGitlab, SourceTypeSVN, SourceTypeSingleSVN,
+				SourceTypeBitbucket}))
+	} else if !source.set {
+		allErrs = append(allErrs, field.Required(fldPath.Child(source.name),
+			fmt.Sprintf("it is required by the source type %q", pipeline.SourceType)))
+	}
+
+	if pipeline.TimerTrigger != nil && pipeline.TimerTrigger.Interval != "" {
+		if millis, err := strconv.ParseInt(pipeline.TimerTrigger.Interval, 10, 64); err != nil || millis <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("timer_trigger", "interval"),
+				pipeline.TimerTrigger.Interval, "it must be a positive number of milliseconds"))
+		}
+	}
+	return
+}
+
+func validateRetryPolicy(policy *RetryPolicy, fldPath *field.Path) (allErrs field.ErrorList) {
+	if policy == nil {
+		return
+	}
+	if policy.MaxAttempts < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxAttempts"), policy.MaxAttempts,
+			"it must be greater than or equal to 0"))
+	}
+	if policy.Backoff != nil && policy.Backoff.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("backoff"), policy.Backoff.Duration.String(),
+			"it must be greater than or equal to 0"))
+	}
+	supportedReasons := []string{string(RetryOnFailure), string(RetryOnUnstable), string(RetryOnAborted),
+		string(RetryOnNotBuilt)}
+	for i, reason := range policy.RetryOn {
+		switch reason {
+		case RetryOnFailure, RetryOnUnstable, RetryOnAborted, RetryOnNotBuilt:
+		default:
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("retryOn").Index(i), reason, supportedReasons))
+		}
+	}
+	return
+}
+
+func validateConcurrencyPolicy(policy *ConcurrencyPolicy, fldPath *field.Path) (allErrs field.ErrorList) {
+	if policy == nil {
+		return
+	}
+	if policy.Group == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("group"), "the name of the concurrency group is required"))
+	} else {
+		for _, msg := range validation.IsValidLabelValue(policy.Group) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("group"), policy.Group, msg))
+		}
+	}
+	return
+}
+
+// cronFieldRanges are the ranges of minute, hour, day of month, month and day of week in a Jenkins cron line
+var cronFieldRanges = [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// validateJenkinsCron checks the syntax of the Jenkins cron which might have multiple lines.
+// See also: https://www.jenkins.io/doc/book/pipeline/syntax/#cron-syntax
+func validateJenkinsCron(cron string) error {
+	for i, line := range strings.Split(cron, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "TZ=") {
+			continue
+		}
+
+		switch line {
+		case "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly":
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != len(cronFieldRanges) {
+			return fmt.Errorf("line %d: expected 5 fields (MINUTE HOUR DOM MONTH DOW) but got %d", i+1, len(fields))
+		}
+		for j, cronField := range fields {
+			if err := validateCronField(cronField, cronFieldRanges[j][0], cronFieldRanges[j][1]); err != nil {
+				return fmt.Errorf("line %d, field %d: %v", i+1, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCronField checks a comma-separated list, each item looks like: *, H, H(1-5), 1, 1-5, with an optional /step
+func validateCronField(cronField string, min, max int) error {
+	for _, item := range strings.Split(cronField, ",") {
+		if step := strings.Index(item, "/"); step >= 0 {
+			if n, err := strconv.Atoi(item[step+1:]); err != nil || n <= 0 {
+				return fmt.Errorf("invalid step %q in %q", item[step+1:], item)
+			}
+			item = item[:step]
+		}
+
+		switch {
+		case item == "*" || item == "H":
+		case strings.HasPrefix(item, "H(") && strings.HasSuffix(item, ")"):
+			if err := validateCronRange(strings.TrimSuffix(strings.TrimPrefix(item, "H("), ")"), min, max); err != nil {
+				return err
+			}
+		default:
+			if err := validateCronRange(item, min, max); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateCronRange(cronRange string, min, max int) error {
+	bounds := strings.SplitN(cronRange, "-", 2)
+	values := make([]int, 0, len(bounds))
+	for _, bound := range bounds {
+		value, err := strconv.Atoi(bound)
+		if err != nil {
+			return fmt.Errorf("invalid value %q", cronRange)
+		}
+		if value < min || value > max {
+			return fmt.Errorf("value %d is out of range %d-%d", value, min, max)
+		}
+		values = append(values, value)
+	}
+	if len(values) == 2 && values[0] > values[1] {
+		return fmt.Errorf("invalid range %q", cronRange)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPipeline_Default(t *testing.T) {
+	pipeline := &Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "pipeline"},
+		Spec:       PipelineSpec{Pipeline: &NoScmPipeline{}},
+	}
+	pipeline.Default()
+	assert.Equal(t, NoScmPipelineType, pipeline.Spec.Type)
+	assert.Equal(t, "pipeline", pipeline.Spec.Pipeline.Name)
+
+	pipeline = &Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "pipeline"},
+		Spec:       PipelineSpec{MultiBranchPipeline: &MultiBranchPipeline{Name: "name"}},
+	}
+	pipeline.Default()
+	assert.Equal(t, MultiBranchPipelineType, pipeline.Spec.Type)
+	assert.Equal(t, "name", pipeline.Spec.MultiBranchPipeline.Name)
+}
+
+func TestPipeline_ValidateCreate(t *testing.T) {
+	newPipeline := func(pipeline *NoScmPipeline) *Pipeline {
+		return &Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipeline"},
+			Spec: PipelineSpec{
+				Type:     NoScmPipelineType,
+				Pipeline: pipeline,
+			},
+		}
+	}
+	newMultiBranchPipeline := func(pipeline *MultiBranchPipeline) *Pipeline {
+		return &Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipeline"},
+			Spec: PipelineSpec{
+				Type:                MultiBranchPipelineType,
+				MultiBranchPipeline: pipeline,
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		pipeline   *Pipeline
+		wantFields []string
+	}{{
+		name:     "a valid Pipeline",
+		pipeline: newPipeline(&NoScmPipeline{TimerTrigger: &TimerTrigger{Cron: "H/15 * * * *\n# comment\n@daily"}}),
+	}, {
+		name:       "unknown Pipeline type",
+		pipeline:   &Pipeline{Spec: PipelineSpec{Type: "fake"}},
+		wantFields: []string{"spec.type"},
+	}, {
+		name:       "a Pipeline without spec",
+		pipeline:   newPipeline(nil),
+		wantFields: []string{"spec.pipeline"},
+	}, {
+		name:       "invalid cron",
+		pipeline:   newPipeline(&NoScmPipeline{TimerTrigger: &TimerTrigger{Cron: "* * *"}}),
+		wantFields: []string{"spec.pipeline.timer_trigger.cron"},
+	}, {
+		name: "invalid parameters",
+		pipeline: newPipeline(&NoScmPipeline{Parameters: []ParameterDefinition{
+			{Name: "a"}, {Name: ""}, {Name: "a"},
+		}}),
+		wantFields: []string{"spec.pipeline.parameters[1].name", "spec.pipeline.parameters[2].name"},
+	}, {
+		name: "invalid retry policy",
+		pipeline: newPipeline(&NoScmPipeline{Retry: &RetryPolicy{
+			MaxAttempts: -1,
+			Backoff:     &metav1.Duration{Duration: -time.Second},
+			RetryOn:     []RetryReason{RetryOnFailure, "SUCCESS"},
+		}}),
+		wantFields: []string{"spec.pipeline.retry.maxAttempts", "spec.pipeline.retry.backoff",
+			"spec.pipeline.retry.retryOn[1]"},
+	}, {
+		name: "invalid concurrency group",
+		pipeline: func() *Pipeline {
+			pipeline := newPipeline(&NoScmPipeline{})
+			pipeline.Spec.Concurrency = &ConcurrencyPolicy{Group: "deploy to prod"}
+			return pipeline
+		}(),
+		wantFields: []string{"spec.concurrency.group"},
+	}, {
+		name: "a valid multi-branch Pipeline",
+		pipeline: newMultiBranchPipeline(&MultiBranchPipeline{
+			SourceType:   SourceTypeGithub,
+			GitHubSource: &GithubSource{},
+			TimerTrigger: &TimerTrigger{Interval: "60000"},
+		}),
+	}, {
+		name:       "a multi-branch Pipeline without spec",
+		pipeline:   newMultiBranchPipeline(nil),
+		wantFields: []string{"spec.multi_branch_pipeline"},
+	}, {
+		name:       "a multi-branch Pipeline without source",
+		pipeline:   newMultiBranchPipeline(&MultiBranchPipeline{SourceType: SourceTypeGit}),
+		wantFields: []string{"spec.multi_branch_pipeline.git_source"},
+	}, {
+		name:       "a multi-branch Pipeline with unknown source type",
+		pipeline:   newMultiBranchPipeline(&MultiBranchPipeline{SourceType: "fake"}),
+		wantFields: []string{"spec.multi_branch_pipeline.source_type"},
+	}, {
+		name: "a multi-branch Pipeline with invalid interval",
+		pipeline: newMultiBranchPipeline(&MultiBranchPipeline{
+			SourceType:   SourceTypeGit,
+			GitSource:    &GitSource{},
+			TimerTrigger: &TimerTrigger{Interval: "1m"},
+		}),
+		wantFields: []string{"spec.multi_branch_pipeline.timer_trigger.interval"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pipeline.ValidateCreate()
+			assertInvalidFields(t, err, tt.wantFields)
+		})
+	}
+}
+
+func TestPipeline_ValidateUpdate(t *testing.T) {
+	invalid := &Pipeline{Spec: PipelineSpec{Type: "fake"}}
+
+	// allow to update the metadata of an invalid Pipeline
+	updated := invalid.DeepCopy()
+	updated.Finalizers = []string{"finalizer"}
+	assert.Nil(t, updated.ValidateUpdate(invalid))
+
+	updated.Spec.Type = "another"
+	assert.NotNil(t, updated.ValidateUpdate(invalid))
+	assert.Nil(t, updated.ValidateDelete())
+}
+
+func TestValidateJenkinsCron(t *testing.T) {
+	tests := []struct {
+		cron    string
+		wantErr bool
+	}{
+		{cron: "H * * * *"},
+		{cron: "H(0-29)/10 * * * *"},
+		{cron: "45 9-16/2 * * 1-5"},
+		{cron: "0,15,30,45 0 1 1,6 7"},
+		{cron: "TZ=Asia/Shanghai\n@hourly"},
+		{cron: "60 * * * *", wantErr: true},
+		{cron: "* 24 * * *", wantErr: true},
+		{cron: "* * 0 * *", wantErr: true},
+		{cron: "* * * 13 *", wantErr: true},
+		{cron: "5-1 * * * *", wantErr: true},
+		{cron: "*/0 * * * *", wantErr: true},
+		{cron: "H(a-b) * * * *", wantErr: true},
+		{cron: "@every", wantErr: true},
+		{cron: "* * * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cron, func(t *testing.T) {
+			err := validateJenkinsCron(tt.cron)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+// assertInvalidFields asserts the error is an invalid error which only contains the given fields
+func assertInvalidFields(t *testing.T, err error, wantFields []string) {
+	if len(wantFields) == 0 {
+		assert.Nil(t, err)
+		return
+	}
+
+	statusErr, ok := err.(*apierrors.StatusError)
+	if !assert.True(t, ok, err) || !assert.True(t, apierrors.IsInvalid(err)) {
+		return
+	}
+	var fields []string
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		fields = append(fields, cause.Field)
+	}
+	assert.ElementsMatch(t, wantFields, fields)
+}
@@ -18,7 +18,9 @@ package v1alpha3
 
 import (
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -47,6 +49,11 @@ type PipelineRunSpec struct {
 	// Action indicates what we need to do with current PipelineRun.
 	// +optional
 	Action *Action `json:"action,omitempty"`
+
+	// Retry is the policy to re-run the PipelineRun automatically once it failed.
+	// The retry policy of the Pipeline will be used if it's empty.
+	// +optional
+	Retry *RetryPolicy `json:"retry,omitempty"`
 }
 
 // PipelineRunStatus defines the observed state of PipelineRun
@@ -99,6 +106,28 @@ type PipelineRunList struct {
 	Items           []PipelineRun `json:"items"`
 }
 
+// GetCondition returns the condition with the given type, nil if not found.
+func (status *PipelineRunStatus) GetCondition(conditionType ConditionType) *Condition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsPaused indicates if the PipelineRun has been paused.
+func (status *PipelineRunStatus) IsPaused() bool {
+	condition := status.GetCondition(ConditionPaused)
+	return condition != nil && condition.Status == ConditionTrue
+}
+
+// IsStopped indicates if the PipelineRun has been requested to stop.
+func (status *PipelineRunStatus) IsStopped() bool {
+	condition := status.GetCondition(ConditionStopped)
+	return condition != nil && condition.Status == ConditionTrue
+}
+
 // GetLatestCondition obtains latest condition from history of conditions.
 func (status *PipelineRunStatus) GetLatestCondition() *Condition {
 	if len(status.Conditions) == 0 {
@@ -172,6 +201,25 @@ func (pr *PipelineRun) GetRefName() string {
 	return refName
 }
 
+// GetRetryPolicy returns the retry policy of the PipelineRun, it falls back to the one of the Pipeline.
+func (pr *PipelineRun) GetRetryPolicy() *RetryPolicy {
+	if pr.Spec.Retry != nil {
+		return pr.Spec.Retry
+	}
+	if pr.Spec.PipelineSpec != nil && pr.Spec.PipelineSpec.Pipeline != nil {
+		return pr.Spec.PipelineSpec.Pipeline.Retry
+	}
+	return nil
+}
+
+// GetAttempt returns the attempt number of the PipelineRun, the first run is 1.
+func (pr *PipelineRun) GetAttempt() int {
+	if attempt, err := strconv.Atoi(pr.Annotations[PipelineRunAttemptAnnoKey]); err == nil && attempt > 0 {
+		return attempt
+	}
+	return 1
+}
+
 // GetPipelineRunID gets ID of PipelineRun.
 func (pr *PipelineRun) GetPipelineRunID() (pipelineRunID string, exist bool) {
 	pipelineRunID, exist = pr.Annotations[JenkinsPipelineRunIDAnnoKey]
@@ -216,6 +264,65 @@ type Parameter struct {
 	Value string `json:"value" description:"parameter value"`
 }
 
+// RetryPolicy describes how to re-run a failed PipelineRun automatically.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first run.
+	MaxAttempts int `json:"maxAttempts,omitempty" description:"the maximum number of attempts, including the first run"`
+
+	// Backoff is the duration to wait before the first retry, it doubles for every following retry.
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty" description:"the duration to wait before the first retry, it doubles for every following retry"`
+
+	// RetryOn is the failure reasons which are allowed to retry, only FAILURE is allowed if it's empty.
+	// +optional
+	RetryOn []RetryReason `json:"retryOn,omitempty" description:"the failure reasons which are allowed to retry"`
+}
+
+// RetryReason is the result of a Jenkins build which could be retried.
+type RetryReason string
+
+const (
+	// RetryOnFailure allows to retry the PipelineRun which failed.
+	RetryOnFailure RetryReason = "FAILURE"
+	// RetryOnUnstable allows to retry the PipelineRun which is unstable.
+	RetryOnUnstable RetryReason = "UNSTABLE"
+	// RetryOnAborted allows to retry the PipelineRun which was aborted.
+	RetryOnAborted RetryReason = "ABORTED"
+	// RetryOnNotBuilt allows to retry the PipelineRun which was not built.
+	RetryOnNotBuilt RetryReason = "NOT_BUILT"
+)
+
+// AllowRetry returns true if the result of a Jenkins build matches the failure reasons.
+func (policy *RetryPolicy) AllowRetry(result string) bool {
+	if len(policy.RetryOn) == 0 {
+		return result == string(RetryOnFailure)
+	}
+	for _, reason := range policy.RetryOn {
+		if string(reason) == result {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBackoff returns the duration to wait after the given attempt failed.
+func (policy *RetryPolicy) GetBackoff(attempt int) time.Duration {
+	if policy.Backoff == nil || attempt < 1 {
+		return 0
+	}
+	backoff := policy.Backoff.Duration
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// maxRetryBackoff is the upper limit of the retry backoff.
+const maxRetryBackoff = time.Hour
+
 // RefType indicates that SCM reference type, such as branch, tag, pr, mr.
 type RefType string
 
@@ -233,6 +340,20 @@ const (
 	MergeRequest RefType = "mr"
 )
 
+// Parameters of the PipelineRuns which are triggered by SCM webhooks.
+const (
+	// PullRequestNumberParameter is the parameter name of the pull request number.
+	PullRequestNumberParameter = "PR_NUMBER"
+	// PullRequestSourceBranchParameter is the parameter name of the pull request source branch.
+	PullRequestSourceBranchParameter = "PR_SOURCE_BRANCH"
+	// PullRequestTargetBranchParameter is the parameter name of the pull request target branch.
+	PullRequestTargetBranchParameter = "PR_TARGET_BRANCH"
+	// PullRequestHeadSHAParameter is the parameter name of the pull request head commit SHA.
+	PullRequestHeadSHAParameter = "PR_HEAD_SHA"
+	// TagNameParameter is the parameter name of the tag.
+	TagNameParameter = "TAG_NAME"
+)
+
 // SCM is a SCM configuration that target PipelineRun requires.
 type SCM struct {
 	// RefType indicates that SCM reference type, such as branch, tag, pr, mr.
@@ -248,6 +369,8 @@ type RunPhase string
 const (
 	// Pending indicates that the PipelineRun is pending.
 	Pending RunPhase = "Pending"
+	// Queued indicates that the PipelineRun is waiting for other PipelineRuns of the same concurrency group.
+	Queued RunPhase = "Queued"
 	// Running indicates that the PipelineRun is running.
 	Running RunPhase = "Running"
 	// Succeeded indicates that the PipelineRun has succeeded.
@@ -271,6 +394,15 @@ const (
 	// ConditionSucceeded indicates that the pipeline has finished.
 	// For pipeline which runs to completion
 	ConditionSucceeded ConditionType = "Succeeded"
+
+	// ConditionRetry indicates whether the failed pipeline has been retried.
+	ConditionRetry ConditionType = "Retry"
+
+	// ConditionPaused indicates whether the running pipeline has been paused.
+	ConditionPaused ConditionType = "Paused"
+
+	// ConditionStopped indicates whether the running pipeline has been requested to stop.
+	ConditionStopped ConditionType = "Stopped"
 )
 
 // ConditionStatus is the status of the current condition.
@@ -336,6 +468,22 @@ const (
 	TriggerFailed string = "TriggerFailed"
 	// RetrieveFailed indicates that it failed to retrieve the latest running data
 	RetrieveFailed string = "RetrieveFailed"
+	// RetryScheduled indicates that the failed PipelineRun will be retried after the backoff
+	RetryScheduled string = "RetryScheduled"
+	// Retried indicates that a follow-up PipelineRun has been created for the failed PipelineRun
+	Retried string = "Retried"
+	// RetryExhausted indicates that the failed PipelineRun has run out of attempts
+	RetryExhausted string = "RetryExhausted"
+	// RetryFailed indicates that it failed to create the follow-up PipelineRun
+	RetryFailed string = "RetryFailed"
+	// Paused indicates that the PipelineRun has been paused
+	Paused string = "Paused"
+	// Resumed indicates that the paused PipelineRun has been resumed
+	Resumed string = "Resumed"
+	// Stopped indicates that the running PipelineRun has been requested to stop
+	Stopped string = "Stopped"
+	// ActionFailed indicates that it failed to apply the action of the PipelineRun
+	ActionFailed string = "ActionFailed"
 )
 
 func init() {
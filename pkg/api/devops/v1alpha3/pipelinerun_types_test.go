@@ -18,6 +18,7 @@ package v1alpha3
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -315,3 +316,102 @@ func TestPipelineRun_GetRefName(t *testing.T) {
 		})
 	}
 }
+
+func TestPipelineRun_GetRetryPolicy(t *testing.T) {
+	pipelinePolicy := &RetryPolicy{MaxAttempts: 2}
+	runPolicy := &RetryPolicy{MaxAttempts: 3}
+	tests := []struct {
+		name string
+		spec PipelineRunSpec
+		want *RetryPolicy
+	}{{
+		name: "no retry policy",
+		spec: PipelineRunSpec{},
+		want: nil,
+	}, {
+		name: "inherit from the Pipeline",
+		spec: PipelineRunSpec{
+			PipelineSpec: &PipelineSpec{Pipeline: &NoScmPipeline{Retry: pipelinePolicy}},
+		},
+		want: pipelinePolicy,
+	}, {
+		name: "the PipelineRun one is preferred",
+		spec: PipelineRunSpec{
+			PipelineSpec: &PipelineSpec{Pipeline: &NoScmPipeline{Retry: pipelinePolicy}},
+			Retry:        runPolicy,
+		},
+		want: runPolicy,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{Spec: tt.spec}
+			assert.Equal(t, tt.want, pr.GetRetryPolicy())
+		})
+	}
+}
+
+func TestPipelineRun_GetAttempt(t *testing.T) {
+	pr := &PipelineRun{}
+	assert.Equal(t, 1, pr.GetAttempt())
+
+	pr.Annotations = map[string]string{PipelineRunAttemptAnnoKey: "invalid"}
+	assert.Equal(t, 1, pr.GetAttempt())
+
+	pr.Annotations[PipelineRunAttemptAnnoKey] = "3"
+	assert.Equal(t, 3, pr.GetAttempt())
+}
+
+func TestRetryPolicy_AllowRetry(t *testing.T) {
+	policy := &RetryPolicy{}
+	assert.True(t, policy.AllowRetry("FAILURE"))
+	assert.False(t, policy.AllowRetry("ABORTED"))
+	assert.False(t, policy.AllowRetry(""))
+
+	policy.RetryOn = []RetryReason{RetryOnAborted, RetryOnUnstable}
+	assert.False(t, policy.AllowRetry("FAILURE"))
+	assert.True(t, policy.AllowRetry("ABORTED"))
+	assert.True(t, policy.AllowRetry("UNSTABLE"))
+}
+
+func TestRetryPolicy_GetBackoff(t *testing.T) {
+	policy := &RetryPolicy{}
+	assert.Equal(t, time.Duration(0), policy.GetBackoff(1))
+
+	policy.Backoff = &v1.Duration{Duration: time.Minute}
+	assert.Equal(t, time.Duration(0), policy.GetBackoff(0))
+	assert.Equal(t, time.Minute, policy.GetBackoff(1))
+	assert.Equal(t, 2*time.Minute, policy.GetBackoff(2))
+	assert.Equal(t, 4*time.Minute, policy.GetBackoff(3))
+	assert.Equal(t, time.Hour, policy.GetBackoff(100))
+}
+
+func TestPipelineRunStatus_GetCondition(t *testing.T) {
+	status := &PipelineRunStatus{}
+	assert.Nil(t, status.GetCondition(ConditionRetry))
+
+	status.AddCondition(&Condition{Type: ConditionRetry, Reason: "reason"})
+	condition := status.GetCondition(ConditionRetry)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, "reason", condition.Reason)
+	}
+	assert.Nil(t, status.GetCondition(ConditionSucceeded))
+}
+
+func TestPipelineRunStatus_IsPaused(t *testing.T) {
+	status := &PipelineRunStatus{}
+	assert.False(t, status.IsPaused())
+
+	status.AddCondition(&Condition{Type: ConditionPaused, Status: ConditionTrue})
+	assert.True(t, status.IsPaused())
+
+	status.AddCondition(&Condition{Type: ConditionPaused, Status: ConditionFalse})
+	assert.False(t, status.IsPaused())
+}
+
+func TestPipelineRunStatus_IsStopped(t *testing.T) {
+	status := &PipelineRunStatus{}
+	assert.False(t, status.IsStopped())
+
+	status.AddCondition(&Condition{Type: ConditionStopped, Status: ConditionTrue})
+	assert.True(t, status.IsStopped())
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of PipelineRun.
+func (pr *PipelineRun) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(pr).Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-devops-kubesphere-io-v1alpha3-pipelinerun,mutating=true,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelineruns,verbs=create;update,versions=v1alpha3,name=mpipelinerun.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &PipelineRun{}
+
+// Default sets the SCM reference type of a multi-branch PipelineRun as branch if it's missing.
+func (pr *PipelineRun) Default() {
+	if pr.Spec.IsMultiBranchPipeline() && pr.Spec.SCM != nil && pr.Spec.SCM.RefType == "" {
+		pr.Spec.SCM.RefType = Branch
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-pipelinerun,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelineruns,verbs=create;update,versions=v1alpha3,name=vpipelinerun.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PipelineRun{}
+
+// ValidateCreate validates the PipelineRun when it's created.
+func (pr *PipelineRun) ValidateCreate() error {
+	return pr.validate()
+}
+
+// ValidateUpdate validates the PipelineRun when its spec changed.
+func (pr *PipelineRun) ValidateUpdate(old runtime.Object) error {
+	if oldPipelineRun, ok := old.(*PipelineRun); ok && reflect.DeepEqual(oldPipelineRun.Spec, pr.Spec) {
+		return nil
+	}
+	return pr.validate()
+}
+
+// ValidateDelete allows to delete any PipelineRun.
+func (pr *PipelineRun) ValidateDelete() error {
+	return nil
+}
+
+func (pr *PipelineRun) validate() error {
+	allErrs := validatePipelineRunSpec(&pr.Spec, field.NewPath("spec"))
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("PipelineRun").GroupKind(), pr.Name, allErrs)
+}
+
+func validatePipelineRunSpec(spec *PipelineRunSpec, fldPath *field.Path) (allErrs field.ErrorList) {
+	if spec.PipelineRef == nil || spec.PipelineRef.Name == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("pipelineRef", "name"),
+			"the Pipeline which the PipelineRun belongs to is required"))
+	}
+
+	if spec.IsMultiBranchPipeline() {
+		if spec.SCM == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("scm"),
+				"it is required by the PipelineRun of a multi-branch Pipeline"))
+		} else if spec.SCM.RefName == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("scm", "refName"),
+				"the branch, tag or pull request to run is required by the PipelineRun of a multi-branch Pipeline"))
+		}
+	}
+
+	if spec.Action != nil {
+		switch *spec.Action {
+		case Stop, Pause, Resume:
+		default:
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("action"), *spec.Action,
+				[]string{string(Stop), string(Pause), string(Resume)}))
+		}
+	}
+
+	for i, param := range spec.Parameters {
+		if param.Name == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("parameters").Index(i).Child("name"),
+				"the name of a parameter is required"))
+		}
+	}
+
+	allErrs = append(allErrs, validateRetryPolicy(spec.Retry, fldPath.Child("retry"))...)
+	return
+}
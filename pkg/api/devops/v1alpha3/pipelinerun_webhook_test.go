@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPipelineRun_Default(t *testing.T) {
+	pr := &PipelineRun{
+		Spec: PipelineRunSpec{
+			PipelineSpec: &PipelineSpec{Type: MultiBranchPipelineType},
+			SCM:          &SCM{RefName: "master"},
+		},
+	}
+	pr.Default()
+	assert.Equal(t, Branch, pr.Spec.SCM.RefType)
+
+	pr.Spec.SCM.RefType = Tag
+	pr.Default()
+	assert.Equal(t, Tag, pr.Spec.SCM.RefType)
+}
+
+func TestPipelineRun_ValidateCreate(t *testing.T) {
+	pause, unknown := Pause, Action("Restart")
+	newPipelineRun := func(pipelineType PipelineType, scm *SCM) *PipelineRun {
+		return &PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "pr"},
+			Spec: PipelineRunSpec{
+				PipelineRef:  &v1.ObjectReference{Name: "pipeline"},
+				PipelineSpec: &PipelineSpec{Type: pipelineType},
+				SCM:          scm,
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		pipelineRun *PipelineRun
+		wantFields  []string
+	}{{
+		name:        "a valid PipelineRun",
+		pipelineRun: newPipelineRun(NoScmPipelineType, nil),
+	}, {
+		name:        "a valid PipelineRun of a multi-branch Pipeline",
+		pipelineRun: newPipelineRun(MultiBranchPipelineType, &SCM{RefType: Branch, RefName: "master"}),
+	}, {
+		name:        "a PipelineRun without Pipeline reference",
+		pipelineRun: &PipelineRun{},
+		wantFields:  []string{"spec.pipelineRef.name"},
+	}, {
+		name:        "a PipelineRun of a multi-branch Pipeline without SCM",
+		pipelineRun: newPipelineRun(MultiBranchPipelineType, nil),
+		wantFields:  []string{"spec.scm"},
+	}, {
+		name:        "a PipelineRun of a multi-branch Pipeline without SCM reference name",
+		pipelineRun: newPipelineRun(MultiBranchPipelineType, &SCM{RefType: Branch}),
+		wantFields:  []string{"spec.scm.refName"},
+	}, {
+		name: "a PipelineRun with a valid action",
+		pipelineRun: func() *PipelineRun {
+			pr := newPipelineRun(NoScmPipelineType, nil)
+			pr.Spec.Action = &pause
+			return pr
+		}(),
+	}, {
+		name: "a PipelineRun with an unknown action",
+		pipelineRun: func() *PipelineRun {
+			pr := newPipelineRun(NoScmPipelineType, nil)
+			pr.Spec.Action = &unknown
+			return pr
+		}(),
+		wantFields: []string{"spec.action"},
+	}, {
+		name: "a PipelineRun with invalid parameters and retry policy",
+		pipelineRun: func() *PipelineRun {
+			pr := newPipelineRun(NoScmPipelineType, nil)
+			pr.Spec.Parameters = []Parameter{{Name: "", Value: "value"}}
+			pr.Spec.Retry = &RetryPolicy{MaxAttempts: -1}
+			return pr
+		}(),
+		wantFields: []string{"spec.parameters[0].name", "spec.retry.maxAttempts"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pipelineRun.ValidateCreate()
+			assertInvalidFields(t, err, tt.wantFields)
+		})
+	}
+}
+
+func TestPipelineRun_ValidateUpdate(t *testing.T) {
+	invalid := &PipelineRun{}
+
+	// allow to update the metadata of an invalid PipelineRun, such as removing the finalizers
+	updated := invalid.DeepCopy()
+	updated.Finalizers = []string{PipelineRunFinalizerName}
+	assert.Nil(t, updated.ValidateUpdate(invalid))
+
+	stop := Stop
+	updated.Spec.Action = &stop
+	assert.NotNil(t, updated.ValidateUpdate(invalid))
+	assert.Nil(t, updated.ValidateDelete())
+}
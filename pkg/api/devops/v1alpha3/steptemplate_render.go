@@ -75,7 +75,9 @@ func jsonFormat(content string) string {
 
 func wrapWithCredential(secretType, secretName, target string) string {
 	switch secretType {
-	case string(v1.SecretTypeBasicAuth), string(SecretTypeBasicAuth):
+	case string(v1.SecretTypeBasicAuth), string(SecretTypeBasicAuth), string(SecretTypeGitHubApp),
+		string(SecretTypeAccessKey):
+		// the GitHub App and the access key are converted to the username and password credentials in Jenkins
 		target = fmt.Sprintf(`{
       "arguments": {
         "isLiteral": false,
@@ -101,6 +103,15 @@ func wrapWithCredential(secretType, secretName, target string) string {
   },
   "children": [%s],
   "name": "withCredentials"
+}`, secretName, target)
+	case string(v1.SecretTypeDockerConfigJson), string(SecretTypeDockerConfigJSON):
+		target = fmt.Sprintf(`{
+  "arguments": {
+    "isLiteral": false,
+    "value": "${[file(credentialsId: '%s', variable: 'VARIABLE')]}"
+  },
+  "children": [%s],
+  "name": "withCredentials"
 }`, secretName, target)
 	case string(SecretTypeSSHAuth):
 		target = fmt.Sprintf(`{
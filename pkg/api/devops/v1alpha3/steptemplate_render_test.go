@@ -180,6 +180,30 @@ func Test_wrapWithCredential(t *testing.T) {
 			target:     "echo 1",
 		},
 		want: readFile("testdata/credential-ssh.json"),
+	}, {
+		name: "secret as GitHub App type",
+		args: args{
+			secretType: string(SecretTypeGitHubApp),
+			secretName: "config",
+			target:     "echo 1",
+		},
+		want: readFile("testdata/credential-username-password.json"),
+	}, {
+		name: "secret as access key type",
+		args: args{
+			secretType: string(SecretTypeAccessKey),
+			secretName: "config",
+			target:     "echo 1",
+		},
+		want: readFile("testdata/credential-username-password.json"),
+	}, {
+		name: "secret as docker config type",
+		args: args{
+			secretType: string(SecretTypeDockerConfigJSON),
+			secretName: "config",
+			target:     "echo 1",
+		},
+		want: readFile("testdata/credential-file.json"),
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -35,6 +35,10 @@ type SecretInStep struct {
 	Type    string            `json:"type,omitempty"`
 	Wrap    bool              `json:"wrap,omitempty"`
 	Mapping map[string]string `json:"mapping,omitempty"`
+	// Name is the default credential of the step, it's wrapped when no credential is chosen while rendering
+	Name string `json:"name,omitempty"`
+	// Namespace is the namespace of the default credential
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // ParameterInStep is the parameter which used in a step
@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// SyncWindowKindAllow means the applications could be synced only during the window
+	SyncWindowKindAllow = "allow"
+	// SyncWindowKindDeny means the applications could not be synced during the window
+	SyncWindowKindDeny = "deny"
+)
+
+// maxSyncWindowTransitions limits how many window openings and closings are checked to find the next sync time
+const maxSyncWindowTransitions = 1000
+
+// Matches returns the windows which are applied to the application with the given name and destination.
+// A window without any applications, namespaces and clusters is applied to nothing, same as Argo CD.
+func (w SyncWindows) Matches(app, namespace, server, cluster string) (windows SyncWindows) {
+	for _, window := range w {
+		if window == nil {
+			continue
+		}
+		if matchAnyPattern(window.Applications, app) || matchAnyPattern(window.Namespaces, namespace) ||
+			matchAnyPattern(window.Clusters, server) || matchAnyPattern(window.Clusters, cluster) {
+			windows = append(windows, window)
+		}
+	}
+	return
+}
+
+// Active returns the windows which are open at the given time. The invalid windows are ignored.
+func (w SyncWindows) Active(now time.Time) (windows SyncWindows) {
+	for _, window := range w {
+		if window != nil && window.IsActive(now) {
+			windows = append(windows, window)
+		}
+	}
+	return
+}
+
+// CanSync returns true if the applications assigned with the windows could be synced at the given time.
+// An active deny window blocks the sync, and the allow windows block the sync if none of them is active.
+// The ManualSync of the windows allows the manual syncs which would otherwise be blocked.
+func (w SyncWindows) CanSync(now time.Time, manual bool) bool {
+	var activeDeny, inactiveAllow SyncWindows
+	hasActiveAllow := false
+	for _, window := range w {
+		if window == nil {
+			continue
+		}
+		active := window.IsActive(now)
+		switch {
+		case window.Kind == SyncWindowKindDeny && active:
+			activeDeny = append(activeDeny, window)
+		case window.Kind == SyncWindowKindAllow && active:
+			hasActiveAllow = true
+		case window.Kind == SyncWindowKindAllow:
+			inactiveAllow = append(inactiveAllow, window)
+		}
+	}
+
+	if len(activeDeny) > 0 {
+		// all the active deny windows have to allow the manual syncs
+		for _, window := range activeDeny {
+			if !window.ManualSync {
+				return false
+			}
+		}
+		return manual
+	}
+	if hasActiveAllow || len(inactiveAllow) == 0 {
+		return true
+	}
+	if manual {
+		for _, window := range inactiveAllow {
+			if window.ManualSync {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NextSyncTime returns the first time from the given time that the applications could be synced.
+// It returns nil if no such time is found, for example, a deny window is always open.
+func (w SyncWindows) NextSyncTime(now time.Time, manual bool) *time.Time {
+	t := now
+	for i := 0; i < maxSyncWindowTransitions; i++ {
+		if w.CanSync(t, manual) {
+			return &t
+		}
+
+		var next time.Time
+		for _, window := range w {
+			if window == nil {
+				continue
+			}
+			if transition := window.nextTransition(t); !transition.IsZero() && (next.IsZero() || transition.Before(next)) {
+				next = transition
+			}
+		}
+		if next.IsZero() {
+			break
+		}
+		t = next
+	}
+	return nil
+}
+
+// IsActive returns true if the window is open at the given time, it's false if the window is invalid.
+// A window is open from a time matching its schedule, and lasts for its duration.
+func (w *SyncWindow) IsActive(now time.Time) bool {
+	schedule, duration, loc, err := w.parse()
+	if err != nil {
+		return false
+	}
+	start := schedule.Next(now.In(loc).Add(-duration - time.Minute))
+	for !start.IsZero() && !start.After(now) {
+		if now.Before(start.Add(duration)) {
+			return true
+		}
+		start = schedule.Next(start)
+	}
+	return false
+}
+
+// nextTransition returns the first time after the given time that the window opens or closes
+func (w *SyncWindow) nextTransition(now time.Time) (transition time.Time) {
+	schedule, duration, loc, err := w.parse()
+	if err != nil {
+		return
+	}
+	// the window closes at the end of the latest open
+	var end time.Time
+	start := schedule.Next(now.In(loc).Add(-duration - time.Minute))
+	for !start.IsZero() && !start.After(now) {
+		if start.Add(duration).After(now) {
+			end = start.Add(duration)
+		}
+		start = schedule.Next(start)
+	}
+	if !end.IsZero() {
+		return end
+	}
+	return start
+}
+
+// Validate checks the kind, schedule, duration and time zone of the windows.
+func (w SyncWindows) Validate() error {
+	return validateSyncWindows(w, field.NewPath("syncWindows")).ToAggregate()
+}
+
+// parse parses the schedule in the standard cron format as Argo CD does, such as: "0 22 * * 1-5" or "@daily"
+func (w *SyncWindow) parse() (schedule cron.Schedule, duration time.Duration, loc *time.Location, err error) {
+	if schedule, err = cron.ParseStandard(w.Schedule); err != nil {
+		err = fmt.Errorf("invalid schedule %q: %v", w.Schedule, err)
+		return
+	}
+	if duration, err = time.ParseDuration(w.Duration); err != nil || duration <= 0 {
+		err = fmt.Errorf("invalid duration %q: it must be a positive duration, such as 1h or 30m", w.Duration)
+		return
+	}
+	// the empty time zone is UTC
+	if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+		err = fmt.Errorf("invalid time zone %q: %v", w.TimeZone, err)
+	}
+	return
+}
+
+// matchAnyPattern returns true if the text matches any of the patterns, a pattern supports the wildcards * and ?
+func matchAnyPattern(patterns []string, text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+		if matched, _ := regexp.MatchString("^"+expr+"$", text); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncWindow_parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		from    time.Time
+		want    time.Time
+		wantErr bool
+	}{{
+		name: "every 15 minutes",
+		spec: "*/15 * * * *",
+		from: time.Date(2022, 10, 17, 10, 7, 30, 0, time.UTC),
+		want: time.Date(2022, 10, 17, 10, 15, 0, 0, time.UTC),
+	}, {
+		name: "the next minute is strictly after the given time",
+		spec: "15 10 * * *",
+		from: time.Date(2022, 10, 17, 10, 15, 0, 0, time.UTC),
+		want: time.Date(2022, 10, 18, 10, 15, 0, 0, time.UTC),
+	}, {
+		name: "weekdays",
+		spec: "0 22 * * 1-5",
+		from: time.Date(2022, 10, 21, 23, 0, 0, 0, time.UTC),
+		want: time.Date(2022, 10, 24, 22, 0, 0, 0, time.UTC),
+	}, {
+		name: "names of months and days",
+		spec: "30 8 * JAN-mar sat,sun",
+		from: time.Date(2022, 10, 17, 0, 0, 0, 0, time.UTC),
+		want: time.Date(2023, 1, 1, 8, 30, 0, 0, time.UTC),
+	}, {
+		name: "either day of month or day of week",
+		spec: "0 0 1 * 1",
+		from: time.Date(2022, 10, 25, 0, 0, 0, 0, time.UTC),
+		want: time.Date(2022, 10, 31, 0, 0, 0, 0, time.UTC),
+	}, {
+		name: "Sunday is 0",
+		spec: "0 0 * * 0",
+		from: time.Date(2022, 10, 17, 0, 0, 0, 0, time.UTC),
+		want: time.Date(2022, 10, 23, 0, 0, 0, 0, time.UTC),
+	}, {
+		name: "a step from a value",
+		spec: "50/5 * * * *",
+		from: time.Date(2022, 10, 17, 10, 56, 0, 0, time.UTC),
+		want: time.Date(2022, 10, 17, 11, 50, 0, 0, time.UTC),
+	}, {
+		name: "leap day",
+		spec: "0 0 29 2 *",
+		from: time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+		want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+	}, {
+		name: "never",
+		spec: "0 0 30 2 *",
+		from: time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+	}, {
+		name: "descriptor",
+		spec: "@hourly",
+		from: time.Date(2022, 10, 17, 10, 7, 0, 0, time.UTC),
+		want: time.Date(2022, 10, 17, 11, 0, 0, 0, time.UTC),
+	}, {
+		name:    "missing fields",
+		spec:    "0 22 * *",
+		wantErr: true,
+	}, {
+		name:    "out of range",
+		spec:    "0 24 * * *",
+		wantErr: true,
+	}, {
+		name:    "invalid step",
+		spec:    "*/0 * * * *",
+		wantErr: true,
+	}, {
+		name:    "invalid range",
+		spec:    "0 0 * * 5-1",
+		wantErr: true,
+	}, {
+		name:    "invalid value",
+		spec:    "0 0 * * monday",
+		wantErr: true,
+	}, {
+		name:    "day of week is out of range",
+		spec:    "0 0 * * 7",
+		wantErr: true,
+	}, {
+		name:    "seconds are not supported",
+		spec:    "0 0 22 * * *",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := &SyncWindow{Kind: SyncWindowKindAllow, Schedule: tt.spec, Duration: "1h"}
+			schedule, _, _, err := window.parse()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(tt.from))
+		})
+	}
+}
+
+func TestSyncWindow_IsActive(t *testing.T) {
+	window := &SyncWindow{Kind: SyncWindowKindAllow, Schedule: "0 22 * * *", Duration: "8h"}
+	assert.False(t, window.IsActive(time.Date(2022, 10, 17, 21, 59, 0, 0, time.UTC)))
+	assert.True(t, window.IsActive(time.Date(2022, 10, 17, 22, 0, 0, 0, time.UTC)))
+	assert.True(t, window.IsActive(time.Date(2022, 10, 18, 5, 59, 59, 0, time.UTC)))
+	assert.False(t, window.IsActive(time.Date(2022, 10, 18, 6, 0, 0, 0, time.UTC)))
+
+	// 09:00 in Shanghai is 01:00 in UTC
+	window = &SyncWindow{Kind: SyncWindowKindAllow, Schedule: "0 9 * * *", Duration: "1h", TimeZone: "Asia/Shanghai"}
+	assert.True(t, window.IsActive(time.Date(2022, 10, 17, 1, 30, 0, 0, time.UTC)))
+	assert.False(t, window.IsActive(time.Date(2022, 10, 17, 9, 30, 0, 0, time.UTC)))
+
+	// the invalid window is never active
+	window = &SyncWindow{Kind: SyncWindowKindDeny, Schedule: "* * * * *", Duration: "forever"}
+	assert.False(t, window.IsActive(time.Date(2022, 10, 17, 9, 30, 0, 0, time.UTC)))
+}
+
+func TestSyncWindows_Matches(t *testing.T) {
+	byApp := &SyncWindow{Applications: []string{"guestbook-*"}}
+	byNamespace := &SyncWindow{Namespaces: []string{"prod"}}
+	byCluster := &SyncWindow{Clusters: []string{"https://*.example.com", "in-cluster"}}
+	windows := SyncWindows{byApp, byNamespace, byCluster, &SyncWindow{}, nil}
+
+	assert.Equal(t, SyncWindows{byApp}, windows.Matches("guestbook-ui", "dev", "https://kubernetes.default.svc", ""))
+	assert.Equal(t, SyncWindows{byNamespace, byCluster}, windows.Matches("app", "prod", "https://a.example.com", ""))
+	assert.Equal(t, SyncWindows{byCluster}, windows.Matches("app", "dev", "", "in-cluster"))
+	assert.Empty(t, windows.Matches("guestbook", "dev", "https://kubernetes.default.svc", ""))
+}
+
+func TestSyncWindows_CanSync(t *testing.T) {
+	// the windows of the workdays daytime, it's 10:00 on Monday now
+	now := time.Date(2022, 10, 17, 10, 0, 0, 0, time.UTC)
+	activeAllow := &SyncWindow{Kind: SyncWindowKindAllow, Schedule: "0 9 * * 1-5", Duration: "8h"}
+	inactiveAllow := &SyncWindow{Kind: SyncWindowKindAllow, Schedule: "0 22 * * *", Duration: "2h"}
+	activeDeny := &SyncWindow{Kind: SyncWindowKindDeny, Schedule: "0 9 * * 1-5", Duration: "8h"}
+	inactiveDeny := &SyncWindow{Kind: SyncWindowKindDeny, Schedule: "0 22 * * *", Duration: "2h"}
+	withManualSync := func(window *SyncWindow) *SyncWindow {
+		window = window.DeepCopy()
+		window.ManualSync = true
+		return window
+	}
+
+	tests := []struct {
+		name       string
+		windows    SyncWindows
+		wantAuto   bool
+		wantManual bool
+	}{{
+		name:       "no windows",
+		wantAuto:   true,
+		wantManual: true,
+	}, {
+		name:       "active allow window",
+		windows:    SyncWindows{activeAllow, inactiveAllow},
+		wantAuto:   true,
+		wantManual: true,
+	}, {
+		name:    "inactive allow window",
+		windows: SyncWindows{inactiveAllow},
+	}, {
+		name:       "inactive allow window enables manual sync",
+		windows:    SyncWindows{withManualSync(inactiveAllow)},
+		wantManual: true,
+	}, {
+		name:       "inactive deny window",
+		windows:    SyncWindows{inactiveDeny},
+		wantAuto:   true,
+		wantManual: true,
+	}, {
+		name:    "active deny window wins",
+		windows: SyncWindows{activeAllow, activeDeny},
+	}, {
+		name:       "active deny window enables manual sync",
+		windows:    SyncWindows{activeAllow, withManualSync(activeDeny)},
+		wantManual: true,
+	}, {
+		name:    "all the active deny windows have to enable manual sync",
+		windows: SyncWindows{withManualSync(activeDeny), activeDeny},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantAuto, tt.windows.CanSync(now, false))
+			assert.Equal(t, tt.wantManual, tt.windows.CanSync(now, true))
+		})
+	}
+}
+
+func TestSyncWindows_NextSyncTime(t *testing.T) {
+	now := time.Date(2022, 10, 17, 10, 0, 0, 0, time.UTC)
+	at := func(day, hour int) *time.Time {
+		t := time.Date(2022, 10, day, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		windows SyncWindows
+		want    *time.Time
+	}{{
+		name: "could sync now",
+		want: &now,
+	}, {
+		name:    "the allow window opens",
+		windows: SyncWindows{{Kind: SyncWindowKindAllow, Schedule: "0 22 * * *", Duration: "2h"}},
+		want:    at(17, 22),
+	}, {
+		name:    "the deny window closes",
+		windows: SyncWindows{{Kind: SyncWindowKindDeny, Schedule: "0 9 * * *", Duration: "8h"}},
+		want:    at(17, 17),
+	}, {
+		name: "the allow window opens during the deny window",
+		windows: SyncWindows{
+			{Kind: SyncWindowKindDeny, Schedule: "0 9 * * *", Duration: "8h"},
+			{Kind: SyncWindowKindAllow, Schedule: "0 12 * * *", Duration: "8h"},
+		},
+		want: at(17, 17),
+	}, {
+		name: "the deny window opens during the allow window",
+		windows: SyncWindows{
+			{Kind: SyncWindowKindDeny, Schedule: "0 20 * * *", Duration: "4h"},
+			{Kind: SyncWindowKindAllow, Schedule: "0 22 * * *", Duration: "4h"},
+		},
+		want: at(18, 0),
+	}, {
+		name:    "the deny window never closes",
+		windows: SyncWindows{{Kind: SyncWindowKindDeny, Schedule: "* * * * *", Duration: "1h"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.windows.NextSyncTime(now, false))
+		})
+	}
+}
+
+func TestSyncWindows_Validate(t *testing.T) {
+	assert.NoError(t, SyncWindows{{Kind: SyncWindowKindDeny, Schedule: "@daily", Duration: "1h"}}.Validate())
+	assert.Error(t, SyncWindows{{Kind: SyncWindowKindDeny, Schedule: "@daily"}}.Validate())
+}
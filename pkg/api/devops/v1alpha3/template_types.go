@@ -67,7 +67,8 @@ type TemplateParameter struct {
 
 // ParameterValidation is definition of how can we validate our parameter.
 type ParameterValidation struct {
-	// Expression is the expression of the validation.
+	// Expression is the CEL expression of the validation, the value of the parameter is the variable self.
+	// See also: https://github.com/google/cel-spec
 	Expression string `json:"expression"`
 
 	// Message is given when validation failure.
@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"google.golang.org/protobuf/proto"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// defaultTemplateParameterType is the type of a template parameter if it's missing
+const defaultTemplateParameterType = "string"
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of Template.
+func (template *Template) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(template).Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-devops-kubesphere-io-v1alpha3-template,mutating=true,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=templates,verbs=create;update,versions=v1alpha3,name=mtemplate.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Template{}
+
+// Default sets the type of the parameters as string if it's missing.
+func (template *Template) Default() {
+	defaultTemplateSpec(&template.Spec)
+}
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-template,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=templates,verbs=create;update,versions=v1alpha3,name=vtemplate.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Template{}
+
+// ValidateCreate validates the Template when it's created.
+func (template *Template) ValidateCreate() error {
+	return validateTemplate("Template", template.Name, &template.Spec)
+}
+
+// ValidateUpdate validates the Template when its spec changed.
+func (template *Template) ValidateUpdate(old runtime.Object) error {
+	if oldTemplate, ok := old.(*Template); ok && reflect.DeepEqual(oldTemplate.Spec, template.Spec) {
+		return nil
+	}
+	return validateTemplate("Template", template.Name, &template.Spec)
+}
+
+// ValidateDelete allows to delete any Template.
+func (template *Template) ValidateDelete() error {
+	return nil
+}
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of ClusterTemplate.
+func (template *ClusterTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(template).Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-devops-kubesphere-io-v1alpha3-clustertemplate,mutating=true,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=clustertemplates,verbs=create;update,versions=v1alpha3,name=mclustertemplate.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &ClusterTemplate{}
+
+// Default sets the type of the parameters as string if it's missing.
+func (template *ClusterTemplate) Default() {
+	defaultTemplateSpec(&template.Spec)
+}
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-clustertemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=clustertemplates,verbs=create;update,versions=v1alpha3,name=vclustertemplate.devops.kubesphere.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ClusterTemplate{}
+
+// ValidateCreate validates the ClusterTemplate when it's created.
+func (template *ClusterTemplate) ValidateCreate() error {
+	return validateTemplate("ClusterTemplate", template.Name, &template.Spec)
+}
+
+// ValidateUpdate validates the ClusterTemplate when its spec changed.
+func (template *ClusterTemplate) ValidateUpdate(old runtime.Object) error {
+	if oldTemplate, ok := old.(*ClusterTemplate); ok && reflect.DeepEqual(oldTemplate.Spec, template.Spec) {
+		return nil
+	}
+	return validateTemplate("ClusterTemplate", template.Name, &template.Spec)
+}
+
+// ValidateDelete allows to delete any ClusterTemplate.
+func (template *ClusterTemplate) ValidateDelete() error {
+	return nil
+}
+
+func defaultTemplateSpec(spec *TemplateSpec) {
+	for i := range spec.Parameters {
+		if spec.Parameters[i].Type == "" {
+			spec.Parameters[i].Type = defaultTemplateParameterType
+		}
+	}
+}
+
+func validateTemplate(kind, name string, spec *TemplateSpec) error {
+	allErrs := validateTemplateSpec(spec, field.NewPath("spec"))
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind(kind).GroupKind(), name, allErrs)
+}
+
+func validateTemplateSpec(spec *TemplateSpec, fldPath *field.Path) (allErrs field.ErrorList) {
+	names := make(map[string]bool, len(spec.Parameters))
+	for i, param := range spec.Parameters {
+		paramPath := fldPath.Child("parameters").Index(i)
+		if param.Name == "" {
+			allErrs = append(allErrs, field.Required(paramPath.Child("name"), "the name of a parameter is required"))
+		} else if names[param.Name] {
+			allErrs = append(allErrs, field.Duplicate(paramPath.Child("name"), param.Name))
+		}
+		names[param.Name] = true
+
+		if param.Validation == nil {
+			continue
+		}
+		if _, err := compileValidationExpression(param.Validation.Expression); err != nil {
+			allErrs = append(allErrs, field.Invalid(paramPath.Child("validation", "expression"),
+				param.Validation.Expression, err.Error()))
+		}
+	}
+	return
+}
+
+// validationExpressionVariable is the variable of the parameter value in a validation expression
+const validationExpressionVariable = "self"
+
+// compileValidationExpression checks if the validation expression is a CEL expression which returns a boolean
+func compileValidationExpression(expression string) (program cel.Program, err error) {
+	if expression == "" {
+		err = fmt.Errorf("the expression is required")
+		return
+	}
+	var env *cel.Env
+	if env, err = cel.NewEnv(cel.Declarations(decls.NewVar(validationExpressionVariable, decls.Dyn))); err != nil {
+		return
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		err = fmt.Errorf("it is not a valid CEL expression: %v", issues.Err())
+		return
+	}
+	if outputType := ast.ResultType(); !proto.Equal(outputType, decls.Bool) && !proto.Equal(outputType, decls.Dyn) {
+		err = fmt.Errorf("the expression must return a boolean")
+		return
+	}
+	return env.Program(ast)
+}
+
+// EvaluateValidationExpression returns true if the value of a parameter passes the validation expression
+func EvaluateValidationExpression(expression string, value interface{}) (passed bool, err error) {
+	var program cel.Program
+	if program, err = compileValidationExpression(expression); err != nil {
+		return
+	}
+	out, _, err := program.Eval(map[string]interface{}{validationExpressionVariable: value})
+	if err != nil {
+		err = fmt.Errorf("failed to evaluate the expression: %v", err)
+		return
+	}
+	var ok bool
+	if passed, ok = out.Value().(bool); !ok {
+		err = fmt.Errorf("the expression must return a boolean")
+	}
+	return
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Default(t *testing.T) {
+	template := &Template{Spec: TemplateSpec{Parameters: []TemplateParameter{
+		{Name: "a"}, {Name: "b", Type: "boolean"},
+	}}}
+	template.Default()
+	assert.Equal(t, "string", template.Spec.Parameters[0].Type)
+	assert.Equal(t, "boolean", template.Spec.Parameters[1].Type)
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters []TemplateParameter
+		wantFields []string
+	}{{
+		name: "valid parameters",
+		parameters: []TemplateParameter{
+			{Name: "url", Validation: &ParameterValidation{Expression: "self.matches('^https?://')", Message: "invalid URL"}},
+			{Name: "replicas", Validation: &ParameterValidation{Expression: "self > 0 && self < 10", Message: "invalid replicas"}},
+			{Name: "branch"},
+		},
+	}, {
+		name: "the expression does not compile",
+		parameters: []TemplateParameter{
+			{Name: "url", Validation: &ParameterValidation{Expression: "matches()", Message: "invalid URL"}},
+		},
+		wantFields: []string{"spec.parameters[0].validation.expression"},
+	}, {
+		name: "the expression is not a boolean",
+		parameters: []TemplateParameter{
+			{Name: "url", Validation: &ParameterValidation{Expression: "self + 'suffix' == 'a' ? 1 : 2", Message: "invalid URL"}},
+		},
+		wantFields: []string{"spec.parameters[0].validation.expression"},
+	}, {
+		name: "the expression is missing",
+		parameters: []TemplateParameter{
+			{Name: "url", Validation: &ParameterValidation{Message: "invalid URL"}},
+		},
+		wantFields: []string{"spec.parameters[0].validation.expression"},
+	}, {
+		name:       "parameters without name",
+		parameters: []TemplateParameter{{Name: ""}},
+		wantFields: []string{"spec.parameters[0].name"},
+	}, {
+		name:       "duplicated parameters",
+		parameters: []TemplateParameter{{Name: "url"}, {Name: "url"}},
+		wantFields: []string{"spec.parameters[1].name"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &Template{Spec: TemplateSpec{Parameters: tt.parameters}}
+			assertInvalidFields(t, template.ValidateCreate(), tt.wantFields)
+			assertInvalidFields(t, template.ValidateUpdate(&Template{}), tt.wantFields)
+
+			clusterTemplate := &ClusterTemplate{Spec: TemplateSpec{Parameters: tt.parameters}}
+			assertInvalidFields(t, clusterTemplate.ValidateCreate(), tt.wantFields)
+			assertInvalidFields(t, clusterTemplate.ValidateUpdate(&ClusterTemplate{}), tt.wantFields)
+		})
+	}
+}
+
+func TestTemplate_ValidateUpdate(t *testing.T) {
+	invalid := TemplateSpec{Parameters: []TemplateParameter{{
+		Name:       "replicas",
+		Validation: &ParameterValidation{Expression: "self >"},
+	}}}
+
+	// allow to update the metadata of an invalid Template
+	template := &Template{Spec: invalid}
+	updated := template.DeepCopy()
+	updated.Finalizers = []string{"finalizer"}
+	assert.Nil(t, updated.ValidateUpdate(template))
+	updated.Spec.Parameters[0].Name = "another"
+	assert.NotNil(t, updated.ValidateUpdate(template))
+
+	clusterTemplate := &ClusterTemplate{Spec: invalid}
+	updatedClusterTemplate := clusterTemplate.DeepCopy()
+	updatedClusterTemplate.Labels = map[string]string{"a": "b"}
+	assert.Nil(t, updatedClusterTemplate.ValidateUpdate(clusterTemplate))
+	updatedClusterTemplate.Spec.Parameters[0].Name = "another"
+	assert.NotNil(t, updatedClusterTemplate.ValidateUpdate(clusterTemplate))
+}
+
+func TestEvaluateValidationExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		value      interface{}
+		wantPassed bool
+		wantErr    bool
+	}{{
+		name:       "string matches",
+		expression: "self.matches('^https?://')",
+		value:      "https://kubesphere.io",
+		wantPassed: true,
+	}, {
+		name:       "string does not match",
+		expression: "self.matches('^https?://')",
+		value:      "ftp://kubesphere.io",
+	}, {
+		name:       "number in range",
+		expression: "self > 0.0 && self <= 10.0",
+		value:      float64(3),
+		wantPassed: true,
+	}, {
+		name:       "not a CEL expression",
+		expression: "^https?://",
+		value:      "https://kubesphere.io",
+		wantErr:    true,
+	}, {
+		name:       "not a boolean at runtime",
+		expression: "self",
+		value:      "true",
+		wantErr:    true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passed, err := EvaluateValidationExpression(tt.expression, tt.value)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantPassed, passed)
+		})
+	}
+}
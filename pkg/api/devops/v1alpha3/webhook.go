@@ -55,6 +55,26 @@ type WebhookSpec struct {
 	SkipVerify bool                `json:"skipVerify"`
 }
 
+// GetWebhookSecretValue returns the HMAC secret of a webhook from the referenced secret.
+// It supports secret-text, basic-auth and opaque secrets.
+func GetWebhookSecretValue(secret *v1.Secret) (value string) {
+	if secret == nil {
+		return
+	}
+
+	switch secret.Type {
+	case SecretTypeBasicAuth, v1.SecretTypeBasicAuth:
+		value = string(secret.Data[BasicAuthPasswordKey])
+	case v1.SecretTypeOpaque:
+		if value = string(secret.Data[SecretTextSecretKey]); value == "" {
+			value = string(secret.Data[v1.ServiceAccountTokenKey])
+		}
+	default:
+		value = string(secret.Data[SecretTextSecretKey])
+	}
+	return
+}
+
 func init() {
 	SchemeBuilder.Register(&Webhook{}, &WebhookList{})
 }
@@ -454,13 +454,28 @@ func (in *Condition) DeepCopy() *Condition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConcurrencyPolicy) DeepCopyInto(out *ConcurrencyPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConcurrencyPolicy.
+func (in *ConcurrencyPolicy) DeepCopy() *ConcurrencyPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ConcurrencyPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DevOpsProject) DeepCopyInto(out *DevOpsProject) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevOpsProject.
@@ -481,6 +496,33 @@ func (in *DevOpsProject) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevOpsProjectLimits) DeepCopyInto(out *DevOpsProjectLimits) {
+	*out = *in
+	if in.MaxAgentResources != nil {
+		in, out := &in.MaxAgentResources, &out.MaxAgentResources
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.AllowedAgentLabels != nil {
+		in, out := &in.AllowedAgentLabels, &out.AllowedAgentLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevOpsProjectLimits.
+func (in *DevOpsProjectLimits) DeepCopy() *DevOpsProjectLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(DevOpsProjectLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DevOpsProjectList) DeepCopyInto(out *DevOpsProjectList) {
 	*out = *in
@@ -521,6 +563,11 @@ func (in *DevOpsProjectSpec) DeepCopyInto(out *DevOpsProjectSpec) {
 		*out = new(Argo)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(DevOpsProjectLimits)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevOpsProjectSpec.
@@ -536,6 +583,11 @@ func (in *DevOpsProjectSpec) DeepCopy() *DevOpsProjectSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DevOpsProjectStatus) DeepCopyInto(out *DevOpsProjectStatus) {
 	*out = *in
+	if in.Usage != nil {
+		in, out := &in.Usage, &out.Usage
+		*out = new(DevOpsProjectUsage)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevOpsProjectStatus.
@@ -548,6 +600,21 @@ func (in *DevOpsProjectStatus) DeepCopy() *DevOpsProjectStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevOpsProjectUsage) DeepCopyInto(out *DevOpsProjectUsage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevOpsProjectUsage.
+func (in *DevOpsProjectUsage) DeepCopy() *DevOpsProjectUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(DevOpsProjectUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DiscarderProperty) DeepCopyInto(out *DiscarderProperty) {
 	*out = *in
@@ -920,6 +987,11 @@ func (in *NoScmPipeline) DeepCopyInto(out *NoScmPipeline) {
 		*out = new(GenericWebhook)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NoScmPipeline.
@@ -1178,6 +1250,11 @@ func (in *PipelineRunSpec) DeepCopyInto(out *PipelineRunSpec) {
 		*out = new(Action)
 		**out = **in
 	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineRunSpec.
@@ -1237,6 +1314,11 @@ func (in *PipelineSpec) DeepCopyInto(out *PipelineSpec) {
 		*out = new(MultiBranchPipeline)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(ConcurrencyPolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineSpec.
@@ -1309,6 +1391,31 @@ func (in *RemoteTrigger) DeepCopy() *RemoteTrigger {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
+	*out = *in
+	if in.Backoff != nil {
+		in, out := &in.Backoff, &out.Backoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RetryOn != nil {
+		in, out := &in.RetryOn, &out.RetryOn
+		*out = make([]RetryReason, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicy.
+func (in *RetryPolicy) DeepCopy() *RetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SCM) DeepCopyInto(out *SCM) {
 	*out = *in
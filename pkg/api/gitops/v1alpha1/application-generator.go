@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ApplicationGeneratorLabelKey is the label key of the generated Applications, the value is the name of the generator
+	ApplicationGeneratorLabelKey = GroupName + "/application-generator"
+
+	// ApplicationGeneratorConditionReady indicates whether the Applications were generated
+	ApplicationGeneratorConditionReady = "Ready"
+	// ApplicationGeneratorReasonGenerated means all the Applications were generated
+	ApplicationGeneratorReasonGenerated = "Generated"
+	// ApplicationGeneratorReasonFailed means the Applications could not be generated, see the message for the details
+	ApplicationGeneratorReasonFailed = "GenerateFailed"
+	// ApplicationGeneratorReasonPruneSkipped means no Application was generated, and the existing ones were not pruned
+	ApplicationGeneratorReasonPruneSkipped = "PruneSkipped"
+)
+
+// DefaultApplicationGeneratorInterval is the default interval of generating the Applications again
+const DefaultApplicationGeneratorInterval = 3 * time.Minute
+
+// ApplicationGeneratorSpec describes how to generate a set of Applications from a template
+type ApplicationGeneratorSpec struct {
+	// Generators produce the sets of parameters, an Application is generated for each set.
+	// The parameters of all the generators are merged as a whole.
+	Generators []ApplicationGeneratorSource `json:"generators"`
+	// Template is the template of the generated Applications, the parameters are referenced as {{name}}
+	Template ApplicationTemplate `json:"template"`
+	// Interval is the interval of generating the Applications again, such as picking up new clusters or directories.
+	// The default value is 3m.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Prune indicates whether to delete the generated Applications which are not generated anymore, it's true by default
+	Prune *bool `json:"prune,omitempty"`
+	// AllowEmpty allows pruning all the generated Applications when the generators produce no parameters. It's false by
+	// default, because an empty cluster list or a mismatched directory pattern would delete all the workloads.
+	AllowEmpty bool `json:"allowEmpty,omitempty"`
+}
+
+// ApplicationGeneratorSource is a generator of the parameters, only one of the generators should be set
+type ApplicationGeneratorSource struct {
+	List         *ListGenerator         `json:"list,omitempty"`
+	Cluster      *ClusterGenerator      `json:"cluster,omitempty"`
+	GitDirectory *GitDirectoryGenerator `json:"gitDirectory,omitempty"`
+}
+
+// ListGenerator generates the parameters from a fixed list
+type ListGenerator struct {
+	Elements []map[string]string `json:"elements"`
+}
+
+// ClusterGenerator generates the parameters from the KubeSphere clusters.
+// The parameters are: name, server, metadata.labels.<key> and values.<key>.
+type ClusterGenerator struct {
+	// Selector selects the clusters by labels, all the clusters are selected if it's empty
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+	// Values are the additional parameters, the values could reference the other parameters as well
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// GitDirectoryGenerator generates the parameters from the directories of a Git repository.
+// The parameters are: path, path.basename and path.basenameNormalized.
+type GitDirectoryGenerator struct {
+	// Repo is the GitRepository in the same namespace
+	Repo v1.LocalObjectReference `json:"repo"`
+	// Revision is the branch, tag or commit, the default branch is used if it's empty
+	Revision string `json:"revision,omitempty"`
+	// Directories are the paths of the directories, the glob patterns are supported, such as apps/*
+	Directories []GitDirectory `json:"directories"`
+}
+
+// GitDirectory is a path or glob pattern of the directories
+type GitDirectory struct {
+	Path string `json:"path"`
+	// Exclude indicates the matched directories are excluded
+	Exclude bool `json:"exclude,omitempty"`
+}
+
+// ApplicationTemplate is the template of the generated Applications
+type ApplicationTemplate struct {
+	Metadata ApplicationTemplateMeta `json:"metadata"`
+	Spec     ApplicationSpec         `json:"spec"`
+}
+
+// ApplicationTemplateMeta is the metadata of the generated Applications
+type ApplicationTemplateMeta struct {
+	// Name is the name of the generated Application, it must be unique for every set of parameters
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ApplicationGeneratorStatus is the status of an ApplicationGenerator
+type ApplicationGeneratorStatus struct {
+	// Applications are the names of the generated Applications
+	Applications []string           `json:"applications,omitempty"`
+	Conditions   []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +k8s:openapi-gen=true
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.template.spec.kind`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// ApplicationGenerator generates a set of Applications from a template, such as one Application per cluster
+type ApplicationGenerator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationGeneratorSpec   `json:"spec"`
+	Status ApplicationGeneratorStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ApplicationGeneratorList represents a set of the ApplicationGenerators
+type ApplicationGeneratorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationGenerator `json:"items"`
+}
+
+// GetInterval returns the interval of generating the Applications again
+func (g *ApplicationGenerator) GetInterval() time.Duration {
+	if g.Spec.Interval != nil && g.Spec.Interval.Duration > 0 {
+		return g.Spec.Interval.Duration
+	}
+	return DefaultApplicationGeneratorInterval
+}
+
+// IsPruneEnabled returns true if the Applications which are not generated anymore should be deleted
+func (g *ApplicationGenerator) IsPruneEnabled() bool {
+	return g.Spec.Prune == nil || *g.Spec.Prune
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationGenerator{}, &ApplicationGeneratorList{})
+}
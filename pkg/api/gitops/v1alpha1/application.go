@@ -251,6 +251,9 @@ type ApplicationSpec struct {
 	Kind    Engine           `json:"kind,omitempty"`
 	ArgoApp *ArgoApplication `json:"argoApp,omitempty"`
 	FluxApp *FluxApplication `json:"fluxApp,omitempty"`
+	// Rollout promotes a new revision of the Application progressively, the new revision
+	// is applied to all destinations at once if it's nil
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
 }
 
 // ArgoApplication is a definition of Argo Application resource.
@@ -599,6 +602,16 @@ type ApplicationStatus struct {
 	Kind    Engine                `json:"kind,omitempty"`
 	ArgoApp string                `json:"argoApp,omitempty"`
 	FluxApp FluxApplicationStatus `json:"fluxApp,omitempty"`
+	Rollout *RolloutStatus        `json:"rollout,omitempty"`
+	// SyncHistory is the sync history of the Argo CD Application, the latest one comes first
+	SyncHistory []SyncHistory `json:"syncHistory,omitempty"`
+	// Health is the normalized health status of the Application
+	Health HealthStatus `json:"health,omitempty"`
+	// SyncStatus is the normalized sync status of the Application
+	SyncStatus SyncStatusCode `json:"syncStatus,omitempty"`
+	// Conditions are the latest observations of the Application,
+	// such as whether the live resources drifted from the desired state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
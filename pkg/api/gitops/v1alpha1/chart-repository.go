@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ChartRepositoryType is the type of ChartRepository
+type ChartRepositoryType string
+
+const (
+	// ChartRepositoryTypeHelm is a Helm repository which serves the index.yaml over HTTP or HTTPS
+	ChartRepositoryTypeHelm ChartRepositoryType = "helm"
+	// ChartRepositoryTypeOCI is an OCI registry which stores the charts as OCI artifacts
+	ChartRepositoryTypeOCI ChartRepositoryType = "oci"
+)
+
+const (
+	// ChartRepositoryConditionReady indicates whether the FluxCD HelmRepository is ready
+	ChartRepositoryConditionReady = "Ready"
+	// ChartRepositoryReasonInvalid means the spec of the ChartRepository is invalid, see the message for the details
+	ChartRepositoryReasonInvalid = "InvalidSpec"
+)
+
+// DefaultChartRepositoryInterval is the default interval of checking the ChartRepository for updates
+const DefaultChartRepositoryInterval = 10 * time.Minute
+
+// ChartRepositorySpec describes a Helm chart repository
+type ChartRepositorySpec struct {
+	// Type is the type of the repository, the default value is helm
+	// +kubebuilder:validation:Enum=helm;oci
+	// +kubebuilder:default:=helm
+	Type ChartRepositoryType `json:"type,omitempty"`
+	// URL is the address of the repository, such as https://charts.bitnami.com/bitnami for the helm type,
+	// or oci://ghcr.io/stefanprodan/charts for the oci type
+	URL string `json:"url"`
+	// Secret is a basic-auth credential in the same DevOpsProject to access the repository
+	Secret *v1.LocalObjectReference `json:"secret,omitempty"`
+	// Interval is the interval of checking the repository for updates, the default value is 10m
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Suspend tells FluxCD to suspend checking the repository
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// ChartRepositoryStatus is the status of a ChartRepository
+type ChartRepositoryStatus struct {
+	// Conditions are copied from the FluxCD HelmRepository
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +k8s:openapi-gen=true
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// ChartRepository is a Helm chart repository of a DevOpsProject, it's the source of the HelmRelease Applications
+type ChartRepository struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ChartRepositorySpec   `json:"spec"`
+	Status ChartRepositoryStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ChartRepositoryList represents a set of the ChartRepositories
+type ChartRepositoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ChartRepository `json:"items"`
+}
+
+// GetType returns the type of the ChartRepository, it's helm if missing
+func (r *ChartRepository) GetType() ChartRepositoryType {
+	if r.Spec.Type == "" {
+		return ChartRepositoryTypeHelm
+	}
+	return r.Spec.Type
+}
+
+// GetInterval returns the interval of checking the repository for updates
+func (r *ChartRepository) GetInterval() time.Duration {
+	if r.Spec.Interval != nil && r.Spec.Interval.Duration > 0 {
+		return r.Spec.Interval.Duration
+	}
+	return DefaultChartRepositoryInterval
+}
+
+// Validate checks whether the URL matches the type of the ChartRepository
+func (r *ChartRepository) Validate() error {
+	repoURL, err := url.Parse(r.Spec.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", r.Spec.URL, err)
+	}
+	switch r.GetType() {
+	case ChartRepositoryTypeHelm:
+		if repoURL.Scheme != "http" && repoURL.Scheme != "https" || repoURL.Host == "" {
+			return fmt.Errorf("the URL of a helm repository must be an HTTP or HTTPS URL, but got %q", r.Spec.URL)
+		}
+	case ChartRepositoryTypeOCI:
+		if repoURL.Scheme != "oci" || repoURL.Host == "" {
+			return fmt.Errorf("the URL of an oci repository must start with oci://, but got %q", r.Spec.URL)
+		}
+	default:
+		return fmt.Errorf("unsupported type %q, the supported ones are: %s, %s", r.Spec.Type,
+			ChartRepositoryTypeHelm, ChartRepositoryTypeOCI)
+	}
+	return nil
+}
+
+func init() {
+	SchemeBuilder.Register(&ChartRepository{}, &ChartRepositoryList{})
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestChartRepository_GetType(t *testing.T) {
+	assert.Equal(t, ChartRepositoryTypeHelm, (&ChartRepository{}).GetType())
+	assert.Equal(t, ChartRepositoryTypeOCI, (&ChartRepository{Spec: ChartRepositorySpec{
+		Type: ChartRepositoryTypeOCI}}).GetType())
+}
+
+func TestChartRepository_GetInterval(t *testing.T) {
+	assert.Equal(t, DefaultChartRepositoryInterval, (&ChartRepository{}).GetInterval())
+	assert.Equal(t, time.Hour, (&ChartRepository{Spec: ChartRepositorySpec{
+		Interval: &metav1.Duration{Duration: time.Hour}}}).GetInterval())
+}
+
+func TestChartRepository_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		repoType ChartRepositoryType
+		url      string
+		wantErr  bool
+	}{{
+		name: "helm by default",
+		url:  "https://charts.bitnami.com/bitnami",
+	}, {
+		name:     "helm over http",
+		repoType: ChartRepositoryTypeHelm,
+		url:      "http://chartmuseum:8080",
+	}, {
+		name:     "helm with an oci URL",
+		repoType: ChartRepositoryTypeHelm,
+		url:      "oci://ghcr.io/stefanprodan/charts",
+		wantErr:  true,
+	}, {
+		name:     "helm without a host",
+		repoType: ChartRepositoryTypeHelm,
+		url:      "https:///charts",
+		wantErr:  true,
+	}, {
+		name:     "oci",
+		repoType: ChartRepositoryTypeOCI,
+		url:      "oci://ghcr.io/stefanprodan/charts",
+	}, {
+		name:     "oci with an https URL",
+		repoType: ChartRepositoryTypeOCI,
+		url:      "https://ghcr.io/stefanprodan/charts",
+		wantErr:  true,
+	}, {
+		name:     "invalid URL",
+		repoType: ChartRepositoryTypeOCI,
+		url:      "oci://ghcr.io:port",
+		wantErr:  true,
+	}, {
+		name:     "unsupported type",
+		repoType: "git",
+		url:      "https://github.com/kubesphere/ks-devops",
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &ChartRepository{Spec: ChartRepositorySpec{Type: tt.repoType, URL: tt.url}}
+			assert.Equal(t, tt.wantErr, repo.Validate() != nil)
+		})
+	}
+}
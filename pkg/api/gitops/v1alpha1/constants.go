@@ -29,6 +29,9 @@ const (
 const (
 	// AnnoKeyImages is the key for the image list
 	AnnoKeyImages = GroupName + "/images"
+	// AnnoKeyNotifiedProblems is the key for the problems of the Application which have been notified,
+	// such as: Degraded,OutOfSync
+	AnnoKeyNotifiedProblems = GroupName + "/notified-problems"
 )
 
 // ApplicationFinalizerName is the name of PipelineRun finalizer
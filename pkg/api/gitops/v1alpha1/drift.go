@@ -0,0 +1,29 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+const (
+	// ApplicationConditionDrifted indicates whether the live resources of the Application drifted from the desired state
+	ApplicationConditionDrifted = "Drifted"
+
+	// DriftReasonDetected means some live resources are different from the desired state
+	DriftReasonDetected = "DriftDetected"
+	// DriftReasonNotDetected means all the live resources are the same as the desired state
+	DriftReasonNotDetected = "NoDrift"
+	// DriftReasonDetectionFailed means the drift could not be detected, see the message for the details
+	DriftReasonDetectionFailed = "DetectionFailed"
+)
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HealthStatus is the normalized health status of an Application, it's the same for both Argo CD and FluxCD
+type HealthStatus string
+
+const (
+	// HealthStatusHealthy means all the resources are healthy
+	HealthStatusHealthy HealthStatus = "Healthy"
+	// HealthStatusSuspended means the reconciliation of some resources is suspended
+	HealthStatusSuspended HealthStatus = "Suspended"
+	// HealthStatusProgressing means some resources are not healthy yet, but they are expected to be healthy soon
+	HealthStatusProgressing HealthStatus = "Progressing"
+	// HealthStatusMissing means some resources are missing
+	HealthStatusMissing HealthStatus = "Missing"
+	// HealthStatusDegraded means some resources failed to reach the healthy state
+	HealthStatusDegraded HealthStatus = "Degraded"
+	// HealthStatusUnknown means the health of the resources could not be assessed
+	HealthStatusUnknown HealthStatus = "Unknown"
+)
+
+// healthOrder is the order of the health status from the best to the worst
+var healthOrder = []HealthStatus{HealthStatusHealthy, HealthStatusSuspended, HealthStatusProgressing,
+	HealthStatusMissing, HealthStatusDegraded, HealthStatusUnknown}
+
+// IsWorseThan returns true if the health status is worse than the other one
+func (h HealthStatus) IsWorseThan(other HealthStatus) bool {
+	return h.order() > other.order()
+}
+
+func (h HealthStatus) order() int {
+	for i, status := range healthOrder {
+		if status == h {
+			return i
+		}
+	}
+	return len(healthOrder) - 1
+}
+
+// SyncStatusCode is the normalized sync status of an Application, it's the same for both Argo CD and FluxCD
+type SyncStatusCode string
+
+const (
+	// SyncStatusSynced means the live state is the same as the desired state in the source
+	SyncStatusSynced SyncStatusCode = "Synced"
+	// SyncStatusOutOfSync means the live state is different from the desired state in the source
+	SyncStatusOutOfSync SyncStatusCode = "OutOfSync"
+	// SyncStatusUnknown means the sync status could not be determined
+	SyncStatusUnknown SyncStatusCode = "Unknown"
+)
+
+const (
+	// ApplicationConditionHealthy indicates whether the resources of the Application are healthy,
+	// the reason is the HealthStatus
+	ApplicationConditionHealthy = "Healthy"
+	// ApplicationConditionSynced indicates whether the Application is synced with the source,
+	// the reason is the SyncStatusCode
+	ApplicationConditionSynced = "Synced"
+)
+
+// SetHealth sets the health status along with the Healthy condition
+func (s *ApplicationStatus) SetHealth(health HealthStatus, message string, observedGeneration int64) {
+	s.Health = health
+	status := metav1.ConditionUnknown
+	switch health {
+	case HealthStatusHealthy:
+		status = metav1.ConditionTrue
+	case HealthStatusDegraded, HealthStatusMissing:
+		status = metav1.ConditionFalse
+	}
+	if message == "" {
+		message = "the Application is " + string(health)
+	}
+	meta.SetStatusCondition(&s.Conditions, metav1.Condition{
+		Type:               ApplicationConditionHealthy,
+		Status:             status,
+		Reason:             string(health),
+		Message:            message,
+		ObservedGeneration: observedGeneration,
+	})
+}
+
+// SetSyncStatus sets the sync status along with the Synced condition
+func (s *ApplicationStatus) SetSyncStatus(syncStatus SyncStatusCode, message string, observedGeneration int64) {
+	s.SyncStatus = syncStatus
+	status := metav1.ConditionUnknown
+	switch syncStatus {
+	case SyncStatusSynced:
+		status = metav1.ConditionTrue
+	case SyncStatusOutOfSync:
+		status = metav1.ConditionFalse
+	}
+	if message == "" {
+		message = "the Application is " + string(syncStatus)
+	}
+	meta.SetStatusCondition(&s.Conditions, metav1.Condition{
+		Type:               ApplicationConditionSynced,
+		Status:             status,
+		Reason:             string(syncStatus),
+		Message:            message,
+		ObservedGeneration: observedGeneration,
+	})
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHealthStatus_IsWorseThan(t *testing.T) {
+	assert.True(t, HealthStatusDegraded.IsWorseThan(HealthStatusProgressing))
+	assert.True(t, HealthStatusProgressing.IsWorseThan(HealthStatusHealthy))
+	assert.True(t, HealthStatusUnknown.IsWorseThan(HealthStatusDegraded))
+	assert.True(t, HealthStatus("fake").IsWorseThan(HealthStatusDegraded))
+	assert.False(t, HealthStatusHealthy.IsWorseThan(HealthStatusSuspended))
+	assert.False(t, HealthStatusMissing.IsWorseThan(HealthStatusMissing))
+}
+
+func TestApplicationStatus_SetHealth(t *testing.T) {
+	tests := []struct {
+		health      HealthStatus
+		message     string
+		wantStatus  metav1.ConditionStatus
+		wantMessage string
+	}{{
+		health:      HealthStatusHealthy,
+		wantStatus:  metav1.ConditionTrue,
+		wantMessage: "the Application is Healthy",
+	}, {
+		health:      HealthStatusDegraded,
+		message:     "Deployment default/fake is Degraded",
+		wantStatus:  metav1.ConditionFalse,
+		wantMessage: "Deployment default/fake is Degraded",
+	}, {
+		health:      HealthStatusMissing,
+		wantStatus:  metav1.ConditionFalse,
+		wantMessage: "the Application is Missing",
+	}, {
+		health:      HealthStatusProgressing,
+		wantStatus:  metav1.ConditionUnknown,
+		wantMessage: "the Application is Progressing",
+	}}
+	for _, tt := range tests {
+		t.Run(string(tt.health), func(t *testing.T) {
+			status := &ApplicationStatus{}
+			status.SetHealth(tt.health, tt.message, 2)
+			assert.Equal(t, tt.health, status.Health)
+			condition := meta.FindStatusCondition(status.Conditions, ApplicationConditionHealthy)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantStatus, condition.Status)
+				assert.Equal(t, string(tt.health), condition.Reason)
+				assert.Equal(t, tt.wantMessage, condition.Message)
+				assert.Equal(t, int64(2), condition.ObservedGeneration)
+			}
+		})
+	}
+}
+
+func TestApplicationStatus_SetSyncStatus(t *testing.T) {
+	tests := []struct {
+		syncStatus SyncStatusCode
+		wantStatus metav1.ConditionStatus
+	}{{
+		syncStatus: SyncStatusSynced,
+		wantStatus: metav1.ConditionTrue,
+	}, {
+		syncStatus: SyncStatusOutOfSync,
+		wantStatus: metav1.ConditionFalse,
+	}, {
+		syncStatus: SyncStatusUnknown,
+		wantStatus: metav1.ConditionUnknown,
+	}}
+	for _, tt := range tests {
+		t.Run(string(tt.syncStatus), func(t *testing.T) {
+			status := &ApplicationStatus{}
+			status.SetSyncStatus(tt.syncStatus, "", 1)
+			assert.Equal(t, tt.syncStatus, status.SyncStatus)
+			condition := meta.FindStatusCondition(status.Conditions, ApplicationConditionSynced)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantStatus, condition.Status)
+				assert.Equal(t, string(tt.syncStatus), condition.Reason)
+				assert.Equal(t, "the Application is "+string(tt.syncStatus), condition.Message)
+			}
+		})
+	}
+}
@@ -28,6 +28,7 @@ type ImageUpdaterSpec struct {
 	Kind   string            `json:"kind,omitempty"`
 	Images []string          `json:"images,omitempty"`
 	Argo   *ArgoImageUpdater `json:"argo,omitempty"`
+	Flux   *FluxImageUpdater `json:"flux,omitempty"`
 }
 
 // ArgoImageUpdater is the specification of the Argo image updater
@@ -43,6 +44,69 @@ type ArgoImageUpdater struct {
 	Secrets        map[string]string `json:"secrets,omitempty"`
 }
 
+// FluxImageUpdater is the specification of the FluxCD image automation.
+// The keys of the maps are the aliases of the images, the semantics of the values are the same as ArgoImageUpdater.
+type FluxImageUpdater struct {
+	App v1.LocalObjectReference `json:"app"`
+	// Interval is the interval of scanning the image repositories and updating the Git repository, defaults to 1m
+	Interval       *metav1.Duration  `json:"interval,omitempty"`
+	UpdateStrategy map[string]string `json:"updateStrategy,omitempty"`
+	AllowTags      map[string]string `json:"allowTags,omitempty"`
+	IgnoreTags     map[string]string `json:"ignoreTags,omitempty"`
+	// Secrets are the names of the docker-registry secrets in the same namespace
+	Secrets map[string]string  `json:"secrets,omitempty"`
+	Git     FluxImageUpdateGit `json:"git,omitempty"`
+}
+
+// FluxImageUpdateGit describes how to write the updated images back to the Git repository
+type FluxImageUpdateGit struct {
+	// Branch is the branch to checkout, defaults to the reference of the GitRepository source
+	Branch string `json:"branch,omitempty"`
+	// PushBranch is the branch to push the commits, defaults to the checkout branch
+	PushBranch string `json:"pushBranch,omitempty"`
+	// Path is the path of the manifests which have the image policy markers, defaults to the root of the repository
+	Path            string `json:"path,omitempty"`
+	AuthorName      string `json:"authorName,omitempty"`
+	AuthorEmail     string `json:"authorEmail,omitempty"`
+	MessageTemplate string `json:"messageTemplate,omitempty"`
+}
+
+// ImageUpdateStrategy is the strategy of choosing the image tag to update to
+type ImageUpdateStrategy string
+
+const (
+	// UpdateStrategySemver chooses the highest version which satisfies the version constraint of the image
+	UpdateStrategySemver ImageUpdateStrategy = "semver"
+	// UpdateStrategyLatest chooses the tag which was built most recently
+	UpdateStrategyLatest ImageUpdateStrategy = "latest"
+	// UpdateStrategyName chooses the last tag in the alphabetical order
+	UpdateStrategyName ImageUpdateStrategy = "name"
+	// UpdateStrategyDigest follows the digest of a mutable tag
+	UpdateStrategyDigest ImageUpdateStrategy = "digest"
+)
+
+// ImageUpdaterStatus is the status of the image updater
+type ImageUpdaterStatus struct {
+	Images []ImageStatus `json:"images,omitempty"`
+	// LastPushCommit is the SHA of the last commit pushed by the image updater
+	LastPushCommit string `json:"lastPushCommit,omitempty"`
+	// LastPushTime is the time of the last commit pushed by the image updater
+	LastPushTime *metav1.Time `json:"lastPushTime,omitempty"`
+}
+
+// ImageStatus is the status of an image which is updated by the image updater
+type ImageStatus struct {
+	// Name is the alias of the image
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// Policy is the reference of the image policy in the format of namespace:name, it's used in the markers
+	Policy string `json:"policy,omitempty"`
+	// LatestTag is the latest tag chosen by the update strategy
+	LatestTag string `json:"latestTag,omitempty"`
+	// LastAppliedTag is the tag which was written back by the last push
+	LastAppliedTag string `json:"lastAppliedTag,omitempty"`
+}
+
 // WriteMethod is an alias of string that represents the write back method of Argo CD Image updater
 type WriteMethod string
 
@@ -76,7 +140,8 @@ type ImageUpdater struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec ImageUpdaterSpec `json:"spec"`
+	Spec   ImageUpdaterSpec   `json:"spec"`
+	Status ImageUpdaterStatus `json:"status,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
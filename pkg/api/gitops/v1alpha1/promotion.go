@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultPromotionHistoryLimit is the default max number of the records in the history of a Promotion
+const DefaultPromotionHistoryLimit = 10
+
+// PromotionSpec describes which Application the synced revision is promoted from and to
+type PromotionSpec struct {
+	// Source is the name of the Application which the synced revision and image overrides are promoted from
+	Source string `json:"source"`
+	// Target is the name of the Application which the revision and image overrides are promoted to.
+	// The source and target Applications must be in the same namespace as the Promotion and use the same engine.
+	Target string `json:"target"`
+	// RequireApproval indicates whether a new revision waits for the manual approval before it's promoted
+	RequireApproval bool `json:"requireApproval,omitempty"`
+	// HistoryLimit is the max number of the records in the history, the default value is 10
+	// +kubebuilder:validation:Minimum=1
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+}
+
+// PromotionPhase is the phase of a promotion record
+type PromotionPhase string
+
+const (
+	// PromotionWaitingForApproval means the revision is waiting for the manual approval
+	PromotionWaitingForApproval PromotionPhase = "WaitingForApproval"
+	// PromotionSucceeded means the revision was promoted to the target Application
+	PromotionSucceeded PromotionPhase = "Succeeded"
+	// PromotionFailed means the revision could not be promoted to the target Application
+	PromotionFailed PromotionPhase = "Failed"
+	// PromotionRejected means the revision was rejected by a reviewer
+	PromotionRejected PromotionPhase = "Rejected"
+	// PromotionSuperseded means the revision was superseded by a newer one before it was approved
+	PromotionSuperseded PromotionPhase = "Superseded"
+)
+
+// PromotionRecord is the audit record of a revision which was promoted, or is waiting to be promoted
+type PromotionRecord struct {
+	// Revision is the synced revision of the source Application.
+	// It's the Git commit or chart version for Argo CD, the chart version or the Git revision for FluxCD.
+	Revision string `json:"revision"`
+	// Images are the image overrides of the source Application, the format is name=newName:newTag@digest
+	Images []string `json:"images,omitempty"`
+	// Phase is the phase of the promotion
+	Phase PromotionPhase `json:"phase"`
+	// Approved is the decision of the reviewer on the pending promotion. It's only set by the approve and reject APIs
+	// via the status subresource, the reviewer is the authenticated user of the request.
+	Approved *bool `json:"approved,omitempty"`
+	// Reviewer is the one who approved or rejected the promotion
+	Reviewer       string       `json:"reviewer,omitempty"`
+	Message        string       `json:"message,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// PromotionStatus is the status of a Promotion
+type PromotionStatus struct {
+	// Pending is the promotion which is waiting for the approval
+	Pending *PromotionRecord `json:"pending,omitempty"`
+	// History is the audit history of the promotions, the latest one comes first
+	History []PromotionRecord `json:"history,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +k8s:openapi-gen=true
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.source`
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.target`
+// +kubebuilder:printcolumn:name="Pending",type=string,JSONPath=`.status.pending.revision`
+
+// Promotion promotes the synced revision of an Application to another Application,
+// such as from the staging environment to the production environment
+type Promotion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PromotionSpec   `json:"spec"`
+	Status PromotionStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PromotionList represents a set of the Promotions
+type PromotionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Promotion `json:"items"`
+}
+
+// GetHistoryLimit returns the max number of the records in the history
+func (p *Promotion) GetHistoryLimit() int {
+	if p.Spec.HistoryLimit != nil && *p.Spec.HistoryLimit > 0 {
+		return int(*p.Spec.HistoryLimit)
+	}
+	return DefaultPromotionHistoryLimit
+}
+
+// AddHistory puts a record at the head of the history, the oldest records are dropped if it exceeds the limit
+func (p *Promotion) AddHistory(record PromotionRecord) {
+	history := append([]PromotionRecord{record}, p.Status.History...)
+	if limit := p.GetHistoryLimit(); len(history) > limit {
+		history = history[:limit]
+	}
+	p.Status.History = history
+}
+
+// IsSameRevision returns true if the record promotes the given revision and images
+func (r *PromotionRecord) IsSameRevision(revision string, images []string) bool {
+	if r == nil || r.Revision != revision || len(r.Images) != len(images) {
+		return false
+	}
+	for i := range images {
+		if r.Images[i] != images[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	SchemeBuilder.Register(&Promotion{}, &PromotionList{})
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromotion_AddHistory(t *testing.T) {
+	promotion := &Promotion{}
+	assert.Equal(t, DefaultPromotionHistoryLimit, promotion.GetHistoryLimit())
+
+	limit := int32(3)
+	promotion.Spec.HistoryLimit = &limit
+	for i := 0; i < 5; i++ {
+		promotion.AddHistory(PromotionRecord{Revision: fmt.Sprintf("v%d", i)})
+	}
+	assert.Equal(t, []PromotionRecord{{Revision: "v4"}, {Revision: "v3"}, {Revision: "v2"}}, promotion.Status.History)
+}
+
+func TestPromotionRecord_IsSameRevision(t *testing.T) {
+	var record *PromotionRecord
+	assert.False(t, record.IsSameRevision("v1", nil))
+
+	record = &PromotionRecord{Revision: "v1", Images: []string{"nginx:v1"}}
+	assert.True(t, record.IsSameRevision("v1", []string{"nginx:v1"}))
+	assert.False(t, record.IsSameRevision("v2", []string{"nginx:v1"}))
+	assert.False(t, record.IsSameRevision("v1", []string{"nginx:v2"}))
+	assert.False(t, record.IsSameRevision("v1", nil))
+}
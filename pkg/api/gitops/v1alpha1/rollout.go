@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/utils"
+)
+
+// RolloutPromoteAnnoKey is the annotation key to approve the paused step of a rollout, the value could be anything.
+// It will be removed once the rollout moves to the next step.
+const RolloutPromoteAnnoKey = GroupName + "/rollout-promote"
+
+// DefaultTrafficWeightParameter is the default Helm value which receives the traffic weight of the new revision
+const DefaultTrafficWeightParameter = "canary.weight"
+
+// RolloutStrategy describes how to promote a new revision of the Application step by step.
+// A canary release shifts the traffic or the destinations gradually, a blue-green release
+// could be a traffic step with weight 0 which pauses for the approval, then a traffic step with weight 100.
+type RolloutStrategy struct {
+	// Steps are the ordered steps to promote a new revision
+	Steps []RolloutStep `json:"steps,omitempty"`
+	// AutoRollback indicates whether to roll back to the stable revision when a health check failed,
+	// otherwise the rollout pauses until it's approved
+	AutoRollback bool `json:"autoRollback,omitempty"`
+	// TrafficWeightParameter is the Helm value which receives the traffic weight of the new revision,
+	// the chart is responsible for routing the traffic. The default value is canary.weight
+	TrafficWeightParameter string `json:"trafficWeightParameter,omitempty"`
+}
+
+// RolloutStep is a step of a rollout, it promotes the new revision to some destinations,
+// or shifts some traffic to the new revision, then pauses if it's necessary
+type RolloutStep struct {
+	// Destinations are the names of the FluxCD Deploy destinations to promote in this step.
+	// The name is the target namespace, or <kubeconfig secret name>-<target namespace> for a member cluster.
+	// The destinations which are not in any step are promoted when the rollout completes.
+	Destinations []string `json:"destinations,omitempty"`
+	// Weight is the percentage of the traffic to the new revision
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Weight *int32 `json:"weight,omitempty"`
+	// Pause holds the rollout at this step, the rollout moves to the next step once the promoted
+	// destinations are healthy and the pause is over
+	Pause *RolloutPause `json:"pause,omitempty"`
+}
+
+// RolloutPause holds a rollout for a while, or until it's approved if the duration is empty
+type RolloutPause struct {
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// RolloutPhase is the phase of a rollout
+type RolloutPhase string
+
+const (
+	// RolloutProgressing indicates that the new revision is being promoted
+	RolloutProgressing RolloutPhase = "Progressing"
+	// RolloutPaused indicates that the rollout is waiting for the pause duration or the approval
+	RolloutPaused RolloutPhase = "Paused"
+	// RolloutCompleted indicates that the new revision was promoted to all destinations
+	RolloutCompleted RolloutPhase = "Completed"
+	// RolloutRolledBack indicates that the new revision was rolled back to the stable revision
+	RolloutRolledBack RolloutPhase = "RolledBack"
+)
+
+// RolloutStatus is the status of a rollout
+type RolloutStatus struct {
+	Phase RolloutPhase `json:"phase,omitempty"`
+	// Revision is the revision which is being rolled out
+	Revision string `json:"revision,omitempty"`
+	// StableRevision is the revision which was promoted to all destinations
+	StableRevision string `json:"stableRevision,omitempty"`
+	// StableSpec is the JSON format of the spec of the stable revision, it's used to roll back
+	StableSpec string `json:"stableSpec,omitempty"`
+	// CurrentStep is the index of the current step
+	CurrentStep int32 `json:"currentStep"`
+	// StepStartTime is the time when the current step started
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+	Message       string       `json:"message,omitempty"`
+}
+
+// GetTrafficWeightParameter returns the Helm value which receives the traffic weight
+func (r *RolloutStrategy) GetTrafficWeightParameter() string {
+	if r.TrafficWeightParameter == "" {
+		return DefaultTrafficWeightParameter
+	}
+	return r.TrafficWeightParameter
+}
+
+// GetRevisionSpec returns the part of the spec which decides the revision,
+// the rollout strategy and the Argo CD operation are not part of it
+func (spec *ApplicationSpec) GetRevisionSpec() *ApplicationSpec {
+	revisionSpec := spec.DeepCopy()
+	revisionSpec.Rollout = nil
+	if revisionSpec.ArgoApp != nil {
+		revisionSpec.ArgoApp.Operation = nil
+	}
+	return revisionSpec
+}
+
+// GetRevision returns a hash of the spec, a new revision will be rolled out once it changed
+func (spec *ApplicationSpec) GetRevision() string {
+	return utils.ComputeHash(spec.GetRevisionSpec())
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolloutStrategy_GetTrafficWeightParameter(t *testing.T) {
+	assert.Equal(t, DefaultTrafficWeightParameter, (&RolloutStrategy{}).GetTrafficWeightParameter())
+	assert.Equal(t, "traffic", (&RolloutStrategy{TrafficWeightParameter: "traffic"}).GetTrafficWeightParameter())
+}
+
+func TestApplicationSpec_GetRevision(t *testing.T) {
+	spec := &ApplicationSpec{
+		Kind: ArgoCD,
+		ArgoApp: &ArgoApplication{
+			Spec: ArgoApplicationSpec{
+				Source: ApplicationSource{RepoURL: "https://github.com/org/repo", TargetRevision: "v1"},
+			},
+		},
+	}
+	revision := spec.GetRevision()
+	assert.NotEmpty(t, revision)
+
+	// the rollout strategy and the operation are not part of the revision
+	withRollout := spec.DeepCopy()
+	withRollout.Rollout = &RolloutStrategy{AutoRollback: true}
+	withRollout.ArgoApp.Operation = &Operation{Sync: &SyncOperation{Prune: true}}
+	assert.Equal(t, revision, withRollout.GetRevision())
+	assert.NotNil(t, withRollout.Rollout)
+	assert.NotNil(t, withRollout.ArgoApp.Operation)
+
+	newRevision := spec.DeepCopy()
+	newRevision.ArgoApp.Spec.Source.TargetRevision = "v2"
+	assert.NotEqual(t, revision, newRevision.GetRevision())
+}
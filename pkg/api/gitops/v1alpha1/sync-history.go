@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxSyncHistory is the max number of the records in the sync history of an Argo CD Application
+const MaxSyncHistory = 10
+
+// SyncResult is the result of a sync operation
+type SyncResult string
+
+const (
+	// SyncSucceeded indicates the sync operation succeeded
+	SyncSucceeded SyncResult = "Succeeded"
+	// SyncFailed indicates the sync operation failed
+	SyncFailed SyncResult = "Failed"
+	// SyncError indicates the sync operation could not be performed
+	SyncError SyncResult = "Error"
+)
+
+// SyncHistory is a record of a finished sync operation of the Argo CD Application
+type SyncHistory struct {
+	// ID is the ID of the deployment history in Argo CD, it is only meaningful if the sync succeeded.
+	// An Application could be rolled back to the revision of a succeeded sync with its ID.
+	ID int64 `json:"id"`
+	// Revision is the revision which was synced
+	Revision string `json:"revision,omitempty"`
+	// DeployStartedAt is the time when the sync started
+	DeployStartedAt *metav1.Time `json:"deployStartedAt,omitempty"`
+	// DeployedAt is the time when the sync finished
+	DeployedAt metav1.Time `json:"deployedAt"`
+	// Initiator is the name of the user who triggered the sync, it is empty if the sync was automated
+	Initiator string `json:"initiator,omitempty"`
+	// Automated indicates the sync was triggered by the automated sync policy
+	Automated bool `json:"automated,omitempty"`
+	// Result is the result of the sync
+	Result SyncResult `json:"result"`
+	// Message is the message of the sync operation
+	Message string `json:"message,omitempty"`
+}
+
+// GetSyncHistory returns the succeeded sync history with the given ID, or nil if not found
+func (in *ApplicationStatus) GetSyncHistory(id int64) *SyncHistory {
+	for i := range in.SyncHistory {
+		if history := &in.SyncHistory[i]; history.ID == id && history.Result == SyncSucceeded {
+			return history
+		}
+	}
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 	rt "runtime"
 	"time"
 
+	"kubesphere.io/devops/pkg/credential"
 	"kubesphere.io/devops/pkg/jwt/token"
 	"kubesphere.io/devops/pkg/kapis/common"
 	"kubesphere.io/devops/pkg/kapis/doc"
@@ -62,6 +63,8 @@ import (
 	devopsv1alpha2 "kubesphere.io/devops/pkg/kapis/devops/v1alpha2"
 	devopsv1alpha3 "kubesphere.io/devops/pkg/kapis/devops/v1alpha3"
 	imagebuilder "kubesphere.io/devops/pkg/kapis/imagebuilder/v1alpha1"
+	"kubesphere.io/devops/pkg/store/backend"
+	"kubesphere.io/devops/pkg/store/store"
 	utilnet "kubesphere.io/devops/pkg/utils/net"
 )
 
@@ -120,7 +123,9 @@ func (s *APIServer) PrepareRun(stopCh <-chan struct{}) error {
 		logStackOnRecover(panicReason, httpWriter)
 	})
 
-	s.installKubeSphereAPIs()
+	if err := s.installKubeSphereAPIs(); err != nil {
+		return err
+	}
 
 	for _, ws := range s.container.RegisteredWebServices() {
 		klog.V(2).Infof("%s", ws.RootPath())
@@ -137,7 +142,7 @@ func (s *APIServer) PrepareRun(stopCh <-chan struct{}) error {
 // Installation happens before all informers start to cache objects, so
 //
 //	any attempt to list objects using listers will get empty results.
-func (s *APIServer) installKubeSphereAPIs() {
+func (s *APIServer) installKubeSphereAPIs() error {
 	jenkinsCore := core.JenkinsCore{
 		URL:      s.Config.JenkinsOptions.Host,
 		UserName: s.Config.JenkinsOptions.Username,
@@ -158,7 +163,18 @@ func (s *APIServer) installKubeSphereAPIs() {
 		jenkinsCore)
 	utilruntime.Must(err)
 	wss = append(wss, v1alpha2WSS...)
-	wss = append(wss, devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, tokenIssue, jenkinsCore)...)
+	// the controller writes the PipelineRun data to the configured data store, so it must not fallback to another one
+	dataStore, err := s.getPipelineRunDataStore()
+	if err != nil {
+		return err
+	}
+	// the external secret backends which are enabled but broken must not be ignored
+	credentialResolver, err := credential.NewResolverFromOptions(s.Config.VaultOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create the external secret backends of credentials: %v", err)
+	}
+	wss = append(wss, devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, tokenIssue, jenkinsCore,
+		dataStore, credentialResolver)...)
 	wss = append(wss, oauth.AddToContainer(s.container,
 		auth.NewTokenOperator(
 			s.CacheClient,
@@ -169,6 +185,24 @@ func (s *APIServer) installKubeSphereAPIs() {
 	}, s.Config.ArgoCDOption, s.Config.FluxCDOption)...)
 	wss = append(wss, imagebuilder.AddToContainer(s.container, s.Client, s.DevopsClient))
 	doc.AddSwaggerService(wss, s.container)
+	return nil
+}
+
+// getPipelineRunDataStore returns the data store backend of PipelineRuns, nil means the default one
+func (s *APIServer) getPipelineRunDataStore() (dataStore store.Backend, err error) {
+	option := s.Config.PipelineRunDataStore
+	if option == nil || option.Type == "" {
+		return
+	}
+
+	if dataStore, err = backend.New(option.Type, &backend.Option{
+		Client:   s.Client,
+		S3Client: s.S3Client,
+		Dir:      option.Dir,
+	}); err != nil {
+		err = fmt.Errorf("failed to create the PipelineRun data store %q: %v", option.Type, err)
+	}
+	return
 }
 
 func getTokenIssue(config *apiserverconfig.Config) token.Issuer {
@@ -182,6 +216,9 @@ func (s *APIServer) Run(stopCh context.Context) (err error) {
 	if err := indexers.CreatePipelineRunIdentityIndexer(s.RuntimeCache); err != nil {
 		return err
 	}
+	if err := indexers.CreateCredentialReferenceIndexer(s.RuntimeCache); err != nil {
+		return err
+	}
 
 	err = s.waitForResourceSync(stopCh)
 	if err != nil {
@@ -293,6 +330,10 @@ func (s *APIServer) waitForResourceSync(stopCh context.Context) error {
 }
 
 func logStackOnRecover(panicReason interface{}, w http.ResponseWriter) {
+	if panicReason == http.ErrAbortHandler {
+		// let the http server abort the response, such as a broken log stream
+		panic(panicReason)
+	}
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("recover from panic situation: - %v\r\n", panicReason))
 	for i := 2; ; i += 1 {
@@ -215,8 +215,8 @@ func TestNotImplement(t *testing.T) {
 	assertNils(t, o1, o2)
 	o1, o2 = client.GetBranchArtifacts("", "", "", "", nil)
 	assertNils(t, o1, o2)
-	o1, o2 = client.GetBranchRunLog("", "", "", "", nil)
-	assertNils(t, o1, o2)
+	o1, o2, o3 = client.GetBranchRunLog("", "", "", "", nil)
+	assertNils(t, o1, o2, o3)
 	o1, o2, o3 = client.GetBranchStepLog("", "", "", "", "", "", nil)
 	assertNils(t, o1, o2, o3)
 	o1, o2 = client.SubmitBranchInputStep("", "", "", "", "", "", nil)
@@ -136,7 +136,7 @@ func (j *JenkinsClient) GetBranchArtifacts(projectName, pipelineName, branchName
 }
 
 // GetBranchRunLog returns the pipeline run log
-func (j *JenkinsClient) GetBranchRunLog(projectName, pipelineName, branchName, runID string, httpParameters *devops.HttpParameters) ([]byte, error) {
+func (j *JenkinsClient) GetBranchRunLog(projectName, pipelineName, branchName, runID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	return j.jenkins.GetBranchRunLog(projectName, pipelineName, branchName, runID, httpParameters)
 }
 
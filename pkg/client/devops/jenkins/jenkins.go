@@ -417,14 +417,13 @@ func (j *Jenkins) GetBranchArtifacts(projectName, pipelineName, branchName, runI
 	return res, err
 }
 
-func (j *Jenkins) GetBranchRunLog(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) ([]byte, error) {
+func (j *Jenkins) GetBranchRunLog(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	PipelineOjb := &Pipeline{
 		HttpParameters: httpParameters,
 		Jenkins:        j,
 		Path:           fmt.Sprintf(GetBranchRunLogUrl+httpParameters.Url.RawQuery, projectName, pipelineName, branchName, runId),
 	}
-	res, err := PipelineOjb.GetBranchRunLog()
-	return res, err
+	return PipelineOjb.GetBranchRunLog()
 }
 
 func (j *Jenkins) GetBranchStepLog(projectName, pipelineName, branchName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
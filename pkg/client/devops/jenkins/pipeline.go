@@ -531,13 +531,13 @@ func (p *Pipeline) GetBranchArtifacts() ([]devops.Artifacts, error) {
 	return artifacts, err
 }
 
-func (p *Pipeline) GetBranchRunLog() ([]byte, error) {
-	res, err := p.Jenkins.SendPureRequest(p.Path, p.HttpParameters)
+func (p *Pipeline) GetBranchRunLog() ([]byte, http.Header, error) {
+	res, header, err := p.Jenkins.SendPureRequestWithHeaderResp(p.Path, p.HttpParameters)
 	if err != nil {
 		klog.Error(err)
 	}
 
-	return res, err
+	return res, header, err
 }
 
 func (p *Pipeline) GetBranchStepLog() ([]byte, http.Header, error) {
@@ -17,6 +17,9 @@ limitations under the License.
 package util
 
 import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"net/http"
 
@@ -46,8 +49,99 @@ func ConvertSecretToCredential(secret *v1.Secret) (interface{}, error) {
 	case devopsv1alpha3.SecretTypeKubeConfig:
 		secretContent := string(secret.Data[devopsv1alpha3.KubeConfigSecretKey])
 		return jcredential.NewKubeConfigCredential(name, secretContent), nil
+	case devopsv1alpha3.SecretTypeGitHubApp:
+		privateKey, err := toPKCS8PrivateKey(secret.Data[devopsv1alpha3.GitHubAppPrivateKey])
+		if err != nil {
+			return nil, restful.NewError(http.StatusBadRequest, err.Error())
+		}
+		return NewGitHubAppCredential(name, string(secret.Data[devopsv1alpha3.GitHubAppIDKey]), privateKey,
+			string(secret.Data[devopsv1alpha3.GitHubAppOwnerKey]),
+			string(secret.Data[devopsv1alpha3.GitHubAppAPIURIKey])), nil
+	case devopsv1alpha3.SecretTypeDockerConfigJSON:
+		return NewFileCredential(name, "config.json", secret.Data[devopsv1alpha3.DockerConfigJSONKey]), nil
+	case devopsv1alpha3.SecretTypeAccessKey:
+		// the access key pair is used as the username and password, then it works without any cloud provider plugins
+		accessKeyID := string(secret.Data[devopsv1alpha3.AccessKeyIDKey])
+		accessKeySecret := string(secret.Data[devopsv1alpha3.AccessKeySecretKey])
+		return jcredential.NewUsernamePasswordCredential(name, accessKeyID, accessKeySecret), nil
 	default:
 		err := fmt.Errorf("error unsupport credential type")
 		return nil, restful.NewError(http.StatusBadRequest, err.Error())
 	}
 }
+
+const (
+	// GitHubAppCredentialStaplerClass is the Jenkins class of the GitHub App credential
+	GitHubAppCredentialStaplerClass = "org.jenkinsci.plugins.github_branch_source.GitHubAppCredentials"
+	// FileCredentialStaplerClass is the Jenkins class of the secret file credential
+	FileCredentialStaplerClass = "org.jenkinsci.plugins.plaincredentials.impl.FileCredentialsImpl"
+)
+
+// GitHubAppCredential is the GitHub App credential provided by the GitHub Branch Source plugin of Jenkins
+type GitHubAppCredential struct {
+	jcredential.Credential `json:",inline"`
+	AppID                  string `json:"appID"`
+	PrivateKey             string `json:"privateKey"`
+	Owner                  string `json:"owner,omitempty"`
+	APIURI                 string `json:"apiUri,omitempty"`
+}
+
+// NewGitHubAppCredential creates a GitHub App credential of Jenkins, the private key must be in the PKCS#8 format
+func NewGitHubAppCredential(id, appID, privateKey, owner, apiURI string) *GitHubAppCredential {
+	return &GitHubAppCredential{
+		Credential: jcredential.Credential{
+			Scope:        jcredential.GLOBALScope,
+			ID:           id,
+			Class:        GitHubAppCredentialStaplerClass,
+			StaplerClass: GitHubAppCredentialStaplerClass,
+		},
+		AppID:      appID,
+		PrivateKey: privateKey,
+		Owner:      owner,
+		APIURI:     apiURI,
+	}
+}
+
+// FileCredential is the secret file credential of Jenkins
+type FileCredential struct {
+	jcredential.Credential `json:",inline"`
+	FileName               string `json:"fileName"`
+	// SecretBytes is the base64 encoded content of the file
+	SecretBytes string `json:"secretBytes"`
+}
+
+// NewFileCredential creates a secret file credential of Jenkins
+func NewFileCredential(id, fileName string, content []byte) *FileCredential {
+	return &FileCredential{
+		Credential: jcredential.Credential{
+			Scope:        jcredential.GLOBALScope,
+			ID:           id,
+			Class:        FileCredentialStaplerClass,
+			StaplerClass: FileCredentialStaplerClass,
+		},
+		FileName:    fileName,
+		SecretBytes: base64.StdEncoding.EncodeToString(content),
+	}
+}
+
+// toPKCS8PrivateKey converts the private key to the PKCS#8 format which is required by Jenkins.
+// GitHub generates the private keys of GitHub Apps in the PKCS#1 format.
+func toPKCS8PrivateKey(data []byte) (string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", fmt.Errorf("the private key is not PEM encoded")
+	}
+	if block.Type == "PRIVATE KEY" {
+		return string(data), nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the PKCS#1 private key: %v", err)
+	}
+	var der []byte
+	if der, err = x509.MarshalPKCS8PrivateKey(key); err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
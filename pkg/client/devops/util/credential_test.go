@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	jcredential "github.com/jenkins-zh/jenkins-client/pkg/credential"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func TestConvertSecretToCredential(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+	pkcs1Key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	pkcs8Der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	pkcs8Key := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Der}))
+
+	newSecret := func(secretType v1.SecretType, data map[string][]byte) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake"},
+			Type:       secretType,
+			Data:       data,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		secret  *v1.Secret
+		want    interface{}
+		wantErr bool
+	}{{
+		name: "basic auth",
+		secret: newSecret(devopsv1alpha3.SecretTypeBasicAuth, map[string][]byte{
+			devopsv1alpha3.BasicAuthUsernameKey: []byte("admin"),
+			devopsv1alpha3.BasicAuthPasswordKey: []byte("password"),
+		}),
+		want: jcredential.NewUsernamePasswordCredential("fake", "admin", "password"),
+	}, {
+		name: "GitHub App with a PKCS#1 private key",
+		secret: newSecret(devopsv1alpha3.SecretTypeGitHubApp, map[string][]byte{
+			devopsv1alpha3.GitHubAppIDKey:      []byte("12345"),
+			devopsv1alpha3.GitHubAppPrivateKey: pkcs1Key,
+			devopsv1alpha3.GitHubAppOwnerKey:   []byte("kubesphere"),
+		}),
+		want: NewGitHubAppCredential("fake", "12345", pkcs8Key, "kubesphere", ""),
+	}, {
+		name: "GitHub App with a PKCS#8 private key",
+		secret: newSecret(devopsv1alpha3.SecretTypeGitHubApp, map[string][]byte{
+			devopsv1alpha3.GitHubAppIDKey:      []byte("12345"),
+			devopsv1alpha3.GitHubAppPrivateKey: []byte(pkcs8Key),
+		}),
+		want: NewGitHubAppCredential("fake", "12345", pkcs8Key, "", ""),
+	}, {
+		name: "GitHub App with an invalid private key",
+		secret: newSecret(devopsv1alpha3.SecretTypeGitHubApp, map[string][]byte{
+			devopsv1alpha3.GitHubAppPrivateKey: []byte("invalid"),
+		}),
+		wantErr: true,
+	}, {
+		name: "docker config",
+		secret: newSecret(devopsv1alpha3.SecretTypeDockerConfigJSON, map[string][]byte{
+			devopsv1alpha3.DockerConfigJSONKey: []byte(`{"auths":{}}`),
+		}),
+		want: &FileCredential{
+			Credential: jcredential.Credential{
+				Scope:        jcredential.GLOBALScope,
+				ID:           "fake",
+				Class:        FileCredentialStaplerClass,
+				StaplerClass: FileCredentialStaplerClass,
+			},
+			FileName:    "config.json",
+			SecretBytes: "eyJhdXRocyI6e319",
+		},
+	}, {
+		name: "access key",
+		secret: newSecret(devopsv1alpha3.SecretTypeAccessKey, map[string][]byte{
+			devopsv1alpha3.AccessKeyIDKey:     []byte("id"),
+			devopsv1alpha3.AccessKeySecretKey: []byte("secret"),
+		}),
+		want: jcredential.NewUsernamePasswordCredential("fake", "id", "secret"),
+	}, {
+		name:    "SOPS keys are not sent to Jenkins",
+		secret:  newSecret(devopsv1alpha3.SecretTypeSOPS, nil),
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertSecretToCredential(tt.secret)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
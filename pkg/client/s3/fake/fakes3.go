@@ -17,6 +17,7 @@ limitations under the License.
 package fake
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -70,6 +71,8 @@ func (s *FakeS3) Read(key string) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
+		// keep the object readable for the next time
+		o.Body = bytes.NewReader(data)
 		return data, nil
 	}
 	return nil, awserr.New(s3.ErrCodeNoSuchKey, "no such object", nil)
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides an in-process KV server which is compatible with the Vault KV secrets engine
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Server is a fake Vault server which serves a KV secrets engine of the version 1 or 2
+type Server struct {
+	*httptest.Server
+
+	token     string
+	mount     string
+	kvVersion int
+
+	lock    sync.Mutex
+	secrets map[string]*secret
+}
+
+type secret struct {
+	data    map[string]interface{}
+	version int
+}
+
+// NewServer starts a fake Vault server, the requests must have the token
+func NewServer(token, mount string, kvVersion int) *Server {
+	s := &Server{
+		token:     token,
+		mount:     strings.Trim(mount, "/"),
+		kvVersion: kvVersion,
+		secrets:   map[string]*secret{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Put writes a new version of the secret at the path
+func (s *Server) Put(path string, data map[string]interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	path = strings.Trim(path, "/")
+	version := 1
+	if old, ok := s.secrets[path]; ok {
+		version = old.version + 1
+	}
+	s.secrets[path] = &secret{data: data, version: version}
+}
+
+// Delete deletes the secret at the path
+func (s *Server) Delete(path string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.secrets, strings.Trim(path, "/"))
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("X-Vault-Token") != s.token {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	prefix := "/v1/" + s.mount + "/"
+	if s.kvVersion == 2 {
+		prefix += "data/"
+	}
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, prefix) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+		return
+	}
+
+	s.lock.Lock()
+	item, ok := s.secrets[strings.TrimPrefix(r.URL.Path, prefix)]
+	s.lock.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+		return
+	}
+
+	var data interface{} = item.data
+	if s.kvVersion == 2 {
+		data = map[string]interface{}{
+			"data":     item.data,
+			"metadata": map[string]interface{}{"version": item.version},
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(obj)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vault
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/pflag"
+)
+
+// Options contains the configuration to access a Vault-compatible KV secrets engine
+type Options struct {
+	Address   string `json:"address,omitempty" yaml:"address" description:"The address of the Vault server"`
+	Token     string `json:"token,omitempty" yaml:"token" description:"The token to access the Vault server"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace" description:"The Vault Enterprise namespace"`
+	Mount     string `json:"mount,omitempty" yaml:"mount" description:"The mount path of the KV secrets engine"`
+	KVVersion int    `json:"kvVersion,omitempty" yaml:"kvVersion" description:"The version of the KV secrets engine, 1 or 2"`
+}
+
+// NewVaultOptions creates a default disabled Options(empty address)
+func NewVaultOptions() *Options {
+	return &Options{
+		Mount:     "secret",
+		KVVersion: 2,
+	}
+}
+
+// Enabled returns true if the address of the Vault server is set
+func (s *Options) Enabled() bool {
+	return s != nil && s.Address != ""
+}
+
+// Validate checks the options if the Vault server is enabled
+func (s *Options) Validate() []error {
+	var errors []error
+	if !s.Enabled() {
+		return errors
+	}
+
+	if u, err := url.Parse(s.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errors = append(errors, fmt.Errorf("the address of the Vault server must be an HTTP or HTTPS URL, but got %q",
+			s.Address))
+	}
+	if s.KVVersion != 1 && s.KVVersion != 2 {
+		errors = append(errors, fmt.Errorf("the version of the KV secrets engine must be 1 or 2, but got %d",
+			s.KVVersion))
+	}
+	if s.Mount == "" {
+		errors = append(errors, fmt.Errorf("the mount path of the KV secrets engine is required"))
+	}
+	return errors
+}
+
+// ApplyTo applies the current values to target one
+func (s *Options) ApplyTo(options *Options) {
+	if s.Address != "" {
+		*options = *s
+	}
+}
+
+// AddFlags adds flags to a flag set
+func (s *Options) AddFlags(fs *pflag.FlagSet, c *Options) {
+	fs.StringVar(&s.Address, "vault-address", c.Address, ""+
+		"The address of the Vault-compatible server which stores the data of the external credentials, "+
+		"such as https://vault.vault-system.svc:8200. If left empty, following vault options will be ignored.")
+
+	fs.StringVar(&s.Token, "vault-token", c.Token, "The token to access the Vault server.")
+
+	fs.StringVar(&s.Namespace, "vault-namespace", c.Namespace, "The Vault Enterprise namespace, it's optional.")
+
+	fs.StringVar(&s.Mount, "vault-mount", c.Mount, "The mount path of the KV secrets engine.")
+
+	fs.IntVar(&s.KVVersion, "vault-kv-version", c.KVVersion, "The version of the KV secrets engine, 1 or 2.")
+}
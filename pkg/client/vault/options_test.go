@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vault
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVaultOptions(t *testing.T) {
+	options := NewVaultOptions()
+	assert.False(t, options.Enabled())
+	assert.Nil(t, options.Validate())
+
+	options.KVVersion = 3
+	assert.Nil(t, options.Validate(), "the options are not validated if Vault is disabled")
+	options.Address = "https://vault:8200"
+	assert.Equal(t, 1, len(options.Validate()))
+
+	target := NewVaultOptions()
+	options.ApplyTo(target)
+	assert.Equal(t, options, target)
+
+	flagSet := &pflag.FlagSet{}
+	options.AddFlags(flagSet, options)
+	for _, name := range []string{"vault-address", "vault-token", "vault-namespace", "vault-mount", "vault-kv-version"} {
+		assert.NotNil(t, flagSet.Lookup(name), name)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vault reads the secrets from the KV secrets engine of Vault or a Vault-compatible server, such as OpenBao.
+// See also: https://developer.hashicorp.com/vault/api-docs/secret/kv
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxResponseSize is the max size of a secret response
+	maxResponseSize = 1 << 20
+	// defaultTimeout is the timeout of the requests to the Vault server
+	defaultTimeout = 10 * time.Second
+)
+
+// Client reads the secrets from the KV secrets engine
+type Client struct {
+	options    *Options
+	httpClient *http.Client
+}
+
+// NewClient creates a Vault client
+func NewClient(options *Options) (*Client, error) {
+	if errs := options.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if !options.Enabled() {
+		return nil, fmt.Errorf("the address of the Vault server is required")
+	}
+	return &Client{
+		options:    options,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}, nil
+}
+
+// kvResponse is the response of reading a secret, the data is nested in the data field in the KV version 2
+type kvResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []string        `json:"errors"`
+}
+
+type kvV2Data struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// Read returns the latest version of the secret at the path, which is relative to the mount path.
+// The values which are not strings are encoded as JSON.
+func (c *Client) Read(ctx context.Context, path string) (data map[string][]byte, err error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		err = fmt.Errorf("the path of the secret is required")
+		return
+	}
+	for _, segment := range strings.Split(path, "/") {
+		// the dot segments would go out of the expected path, the others might be a part of the query
+		if segment == "." || segment == ".." || strings.ContainsAny(segment, "?#%\\") {
+			err = fmt.Errorf("invalid path %q of the secret", path)
+			return
+		}
+	}
+	mount := strings.Trim(c.options.Mount, "/")
+	api := fmt.Sprintf("%s/v1/%s/%s", strings.TrimSuffix(c.options.Address, "/"), mount, path)
+	if c.options.KVVersion == 2 {
+		api = fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(c.options.Address, "/"), mount, path)
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, api, nil); err != nil {
+		return
+	}
+	req.Header.Set("X-Vault-Token", c.options.Token)
+	if c.options.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.options.Namespace)
+	}
+
+	var res *http.Response
+	if res, err = c.httpClient.Do(req); err != nil {
+		return
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(res.Body, maxResponseSize)); err != nil {
+		return
+	}
+	kvRes := &kvResponse{}
+	if res.StatusCode != http.StatusOK {
+		if json.Unmarshal(body, kvRes) == nil && len(kvRes.Errors) > 0 {
+			err = fmt.Errorf("failed to read secret %s/%s from Vault, status code %d: %s", mount, path,
+				res.StatusCode, strings.Join(kvRes.Errors, "; "))
+		} else {
+			err = fmt.Errorf("failed to read secret %s/%s from Vault, status code %d", mount, path, res.StatusCode)
+		}
+		return
+	}
+	if err = json.Unmarshal(body, kvRes); err != nil {
+		return
+	}
+
+	values := map[string]interface{}{}
+	if c.options.KVVersion == 2 {
+		v2Data := &kvV2Data{}
+		if err = json.Unmarshal(kvRes.Data, v2Data); err != nil {
+			return
+		}
+		values = v2Data.Data
+	} else if err = json.Unmarshal(kvRes.Data, &values); err != nil {
+		return
+	}
+	if values == nil {
+		// the latest version of the secret was deleted
+		err = fmt.Errorf("secret %s/%s is deleted in Vault", mount, path)
+		return
+	}
+
+	data = make(map[string][]byte, len(values))
+	for key, value := range values {
+		if text, ok := value.(string); ok {
+			data[key] = []byte(text)
+		} else if data[key], err = json.Marshal(value); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vault
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kubesphere.io/devops/pkg/client/vault/fake"
+)
+
+func TestClient_Read(t *testing.T) {
+	for _, kvVersion := range []int{1, 2} {
+		server := fake.NewServer("root", "kv", kvVersion)
+		server.Put("devops/github", map[string]interface{}{
+			"username": "admin",
+			"password": "s3cr3t",
+			"port":     22,
+		})
+
+		client, err := NewClient(&Options{Address: server.URL, Token: "root", Mount: "/kv/", KVVersion: kvVersion})
+		assert.Nil(t, err)
+
+		data, err := client.Read(context.TODO(), "/devops/github")
+		assert.Nil(t, err, kvVersion)
+		assert.Equal(t, map[string][]byte{
+			"username": []byte("admin"),
+			"password": []byte("s3cr3t"),
+			"port":     []byte("22"),
+		}, data, kvVersion)
+
+		_, err = client.Read(context.TODO(), "devops/not-found")
+		assert.Contains(t, err.Error(), "status code 404", kvVersion)
+		_, err = client.Read(context.TODO(), "")
+		assert.NotNil(t, err, kvVersion)
+		for _, path := range []string{"ns/../devops/github", "./devops/github", "devops/github?version=1"} {
+			_, err = client.Read(context.TODO(), path)
+			assert.Contains(t, err.Error(), "invalid path", path)
+		}
+
+		client, err = NewClient(&Options{Address: server.URL, Token: "invalid", Mount: "kv", KVVersion: kvVersion})
+		assert.Nil(t, err)
+		_, err = client.Read(context.TODO(), "devops/github")
+		assert.Contains(t, err.Error(), "permission denied", kvVersion)
+		server.Close()
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient(NewVaultOptions())
+	assert.NotNil(t, err)
+
+	_, err = NewClient(&Options{Address: "vault:8200", Mount: "secret", KVVersion: 2})
+	assert.NotNil(t, err)
+
+	_, err = NewClient(&Options{Address: "https://vault:8200", Mount: "secret", KVVersion: 3})
+	assert.NotNil(t, err)
+
+	client, err := NewClient(&Options{Address: "https://vault:8200", Mount: "secret", KVVersion: 1})
+	assert.Nil(t, err)
+	assert.NotNil(t, client)
+}
@@ -29,6 +29,7 @@ import (
 
 	"kubesphere.io/devops/pkg/client/devops/jenkins"
 	"kubesphere.io/devops/pkg/client/s3"
+	"kubesphere.io/devops/pkg/client/vault"
 )
 
 // Package config saves configuration for running KubeSphere components
@@ -86,6 +87,8 @@ type Config struct {
 	SonarQubeOptions      *sonarqube.Options                 `json:"sonarqube,omitempty" yaml:"sonarQube,omitempty" mapstructure:"sonarqube"`
 	ArgoCDOption          *ArgoCDOption                      `json:"argocd,omitempty" yaml:"argocd,omitempty" mapstructure:"argocd"`
 	FluxCDOption          *FluxCDOption                      `json:"fluxcd,omitempty" yaml:"fluxcd,omitempty" mapstructure:"fluxcd"`
+	PipelineRunDataStore  *PipelineRunDataStoreOption        `json:"pipelineRunDataStore,omitempty" yaml:"pipelineRunDataStore,omitempty" mapstructure:"pipelineRunDataStore"`
+	VaultOptions          *vault.Options                     `json:"vault,omitempty" yaml:"vault,omitempty" mapstructure:"vault"`
 	AuthenticationOptions *authoptions.AuthenticationOptions `json:"authentication,omitempty" yaml:"authentication,omitempty" mapstructure:"authentication"`
 	AuthMode              AuthMode                           `json:"authMode,omitempty" yaml:"authMode,omitempty" mapstructure:"authMode"`
 	JWTSecret             string                             `json:"jwtSecret,omitempty" yaml:"jwtSecret,omitempty" mapstructure:"jwtSecret"`
@@ -94,13 +97,15 @@ type Config struct {
 // New creates a default non-empty Config
 func New() *Config {
 	return &Config{
-		SonarQubeOptions:  sonarqube.NewSonarQubeOptions(),
-		JenkinsOptions:    jenkins.NewJenkinsOptions(),
-		KubernetesOptions: k8s.NewKubernetesOptions(),
-		S3Options:         s3.NewS3Options(),
-		AuthMode:          AuthModeToken,
-		ArgoCDOption:      &ArgoCDOption{},
-		FluxCDOption:      &FluxCDOption{},
+		SonarQubeOptions:     sonarqube.NewSonarQubeOptions(),
+		JenkinsOptions:       jenkins.NewJenkinsOptions(),
+		KubernetesOptions:    k8s.NewKubernetesOptions(),
+		S3Options:            s3.NewS3Options(),
+		AuthMode:             AuthModeToken,
+		ArgoCDOption:         &ArgoCDOption{},
+		FluxCDOption:         &FluxCDOption{},
+		PipelineRunDataStore: NewPipelineRunDataStoreOption(),
+		VaultOptions:         vault.NewVaultOptions(),
 	}
 }
 
@@ -167,4 +172,8 @@ func (conf *Config) stripEmptyOptions() {
 	if conf.S3Options != nil && conf.S3Options.Endpoint == "" {
 		conf.S3Options = nil
 	}
+
+	if conf.VaultOptions != nil && conf.VaultOptions.Address == "" {
+		conf.VaultOptions = nil
+	}
 }
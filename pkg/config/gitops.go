@@ -25,6 +25,10 @@ import (
 type ArgoCDOption struct {
 	Enabled   bool   `json:"enabled,omitempty" yaml:"enabled,omitempty" description:"enabled ArgoCD"`
 	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty" description:"Which namespace the ArgoCD located"`
+	// Server is the address of the Argo CD API server, it's required by the diff preview
+	Server   string `json:"server,omitempty" yaml:"server,omitempty" description:"The address of the Argo CD API server"`
+	Token    string `json:"token,omitempty" yaml:"token,omitempty" description:"The token to access the Argo CD API server"`
+	Insecure bool   `json:"insecure,omitempty" yaml:"insecure,omitempty" description:"Skip the TLS verification of the Argo CD API server"`
 }
 
 // AddFlags adds the flags which related to argocd
@@ -32,16 +36,25 @@ func (o *ArgoCDOption) AddFlags(fs *pflag.FlagSet, parentOptions *ArgoCDOption)
 	fs.BoolVar(&o.Enabled, "argocd-enabled", parentOptions.Enabled, "Enable ArgoCD APIs")
 	// see also https://argo-cd.readthedocs.io/en/stable/getting_started/
 	fs.StringVar(&o.Namespace, "argocd-namespace", parentOptions.Namespace, "Which namespace the ArgoCD located")
+	fs.StringVar(&o.Server, "argocd-server", parentOptions.Server, "The address of the Argo CD API server, "+
+		"such as: https://argocd-server.argocd.svc")
+	fs.StringVar(&o.Token, "argocd-token", parentOptions.Token, "The token to access the Argo CD API server")
+	fs.BoolVar(&o.Insecure, "argocd-insecure", parentOptions.Insecure, "Skip the TLS verification of the Argo CD API server")
 }
 
 // FluxCDOption as the FluxCD integration configuration
 type FluxCDOption struct {
 	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty" description:"enabled FluxCD"`
+	// ChartRepositoryAllowedHosts are the hosts in the internal networks which the chart repositories could be on
+	ChartRepositoryAllowedHosts []string `json:"chartRepositoryAllowedHosts,omitempty" yaml:"chartRepositoryAllowedHosts,omitempty" description:"The internal hosts which the chart repositories could be on"`
 }
 
 // AddFlags adds the flags which related to fluxcd
 func (o *FluxCDOption) AddFlags(fs *pflag.FlagSet, parentOptions *FluxCDOption) {
 	fs.BoolVar(&o.Enabled, "fluxcd-enabled", parentOptions.Enabled, "Enable FluxCD APIs")
+	fs.StringSliceVar(&o.ChartRepositoryAllowedHosts, "fluxcd-chart-repository-allowed-hosts",
+		parentOptions.ChartRepositoryAllowedHosts, "The hosts in the internal networks which the chart repositories "+
+			"could be on, such as: chartmuseum.kubesphere-devops-system.svc")
 }
 
 // GetGitOpsEngine return gitops engine type
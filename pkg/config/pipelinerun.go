@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"github.com/spf13/pflag"
+	"kubesphere.io/devops/pkg/store/store"
+)
+
+// PipelineRunDataStoreOption as the configuration of the PipelineRun data store
+type PipelineRunDataStoreOption struct {
+	Type string `json:"type,omitempty" yaml:"type,omitempty" description:"The data store type of the PipelineRun data"`
+	Dir  string `json:"dir,omitempty" yaml:"dir,omitempty" description:"The root directory of the filesystem data store"`
+}
+
+// AddFlags adds the flags which related to the PipelineRun data store
+func (o *PipelineRunDataStoreOption) AddFlags(fs *pflag.FlagSet, parentOptions *PipelineRunDataStoreOption) {
+	fs.StringVar(&o.Type, "pipelinerun-data-store", parentOptions.Type,
+		"The data store type of the PipelineRun data, could be configmap, s3 or filesystem")
+	fs.StringVar(&o.Dir, "pipelinerun-data-store-dir", parentOptions.Dir,
+		"The root directory of the filesystem data store, it's usually a mounted PersistentVolume")
+}
+
+// NewPipelineRunDataStoreOption creates a default PipelineRunDataStoreOption
+func NewPipelineRunDataStoreOption() *PipelineRunDataStoreOption {
+	return &PipelineRunDataStoreOption{Type: store.TypeConfigMap}
+}
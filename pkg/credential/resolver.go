@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package credential resolves the data of the DevOps credentials which are stored in the external secret backends,
+// then the secret material does not have to live in etcd.
+package credential
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/vault"
+)
+
+// Backend is an external secret backend which stores the data of the credentials
+type Backend interface {
+	// Read returns the latest data at the path
+	Read(ctx context.Context, path string) (map[string][]byte, error)
+}
+
+// Resolver resolves the data of the credentials from their external secret backends
+type Resolver struct {
+	backends map[string]Backend
+}
+
+// NewResolver creates a Resolver with the backends, the keys are the values of the annotation CredentialSourceAnnoKey
+func NewResolver(backends map[string]Backend) *Resolver {
+	return &Resolver{backends: backends}
+}
+
+// NewResolverFromOptions creates a Resolver with the enabled backends
+func NewResolverFromOptions(vaultOptions *vault.Options) (resolver *Resolver, err error) {
+	backends := map[string]Backend{}
+	if vaultOptions.Enabled() {
+		if backends[v1alpha3.CredentialSourceVault], err = vault.NewClient(vaultOptions); err != nil {
+			return
+		}
+	}
+	resolver = NewResolver(backends)
+	return
+}
+
+// Resolve returns a copy of the credential whose data is read from its external secret backend.
+// The credential itself is returned if it is not an external credential.
+func (r *Resolver) Resolve(ctx context.Context, secret *v1.Secret) (resolved *v1.Secret, err error) {
+	if secret == nil || !v1alpha3.IsExternalCredential(secret) {
+		resolved = secret
+		return
+	}
+
+	source := secret.Annotations[v1alpha3.CredentialSourceAnnoKey]
+	path := secret.Annotations[v1alpha3.CredentialSourcePathAnnoKey]
+	var backend Backend
+	if r != nil {
+		backend = r.backends[source]
+	}
+	if backend == nil {
+		err = fmt.Errorf("the external secret backend %q of credential %s/%s is not enabled", source,
+			secret.Namespace, secret.Name)
+		return
+	}
+	if path == "" {
+		err = fmt.Errorf("the annotation %s of credential %s/%s is required", v1alpha3.CredentialSourcePathAnnoKey,
+			secret.Namespace, secret.Name)
+		return
+	}
+
+	if path, err = scopePath(secret.Namespace, path); err != nil {
+		err = fmt.Errorf("invalid annotation %s of credential %s/%s: %v", v1alpha3.CredentialSourcePathAnnoKey,
+			secret.Namespace, secret.Name, err)
+		return
+	}
+
+	var data map[string][]byte
+	if data, err = backend.Read(ctx, path); err != nil {
+		err = fmt.Errorf("failed to resolve credential %s/%s from %s: %v", secret.Namespace, secret.Name, source, err)
+		return
+	}
+	resolved = secret.DeepCopy()
+	resolved.Data = data
+	return
+}
+
+// pathSegmentPattern matches a segment of the path in the external secret backend
+var pathSegmentPattern = regexp.MustCompile(`^[\w.@=+-]+$`)
+
+// scopePath returns the path under the prefix of the DevOpsProject which the credential belongs to. The backends are
+// accessed with the same token, the prefix makes sure a credential could not refer to the data of other projects.
+func scopePath(namespace, path string) (string, error) {
+	if namespace == "" {
+		return "", fmt.Errorf("the namespace of the credential is required")
+	}
+	if strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("the path %q must be relative to the DevOpsProject", path)
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." || !pathSegmentPattern.MatchString(segment) {
+			return "", fmt.Errorf("invalid segment %q in the path %q", segment, path)
+		}
+	}
+	return namespace + "/" + path, nil
+}
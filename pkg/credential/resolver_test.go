@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credential
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/vault"
+	"kubesphere.io/devops/pkg/client/vault/fake"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	server := fake.NewServer("root", "secret", 2)
+	defer server.Close()
+	server.Put("ns/devops/github", map[string]interface{}{"username": "admin", "password": "s3cr3t"})
+	server.Put("other-ns/devops/github", map[string]interface{}{"username": "other", "password": "other"})
+
+	resolver, err := NewResolverFromOptions(&vault.Options{
+		Address: server.URL, Token: "root", Mount: "secret", KVVersion: 2})
+	assert.Nil(t, err)
+
+	newSecret := func(source, path string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "github", Annotations: map[string]string{
+				v1alpha3.CredentialSourceAnnoKey:     source,
+				v1alpha3.CredentialSourcePathAnnoKey: path,
+			}},
+			Type: v1alpha3.SecretTypeBasicAuth,
+		}
+	}
+
+	t.Run("not an external credential", func(t *testing.T) {
+		secret := &v1.Secret{Data: map[string][]byte{"username": []byte("admin")}}
+		resolved, err := resolver.Resolve(context.TODO(), secret)
+		assert.Nil(t, err)
+		assert.Same(t, secret, resolved)
+
+		resolved, err = resolver.Resolve(context.TODO(), nil)
+		assert.Nil(t, err)
+		assert.Nil(t, resolved)
+	})
+
+	t.Run("resolve from vault", func(t *testing.T) {
+		secret := newSecret(v1alpha3.CredentialSourceVault, "devops/github")
+		resolved, err := resolver.Resolve(context.TODO(), secret)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string][]byte{"username": []byte("admin"), "password": []byte("s3cr3t")}, resolved.Data)
+		assert.Nil(t, secret.Data, "the original secret should not be changed")
+
+		// the rotated data is resolved
+		server.Put("ns/devops/github", map[string]interface{}{"username": "admin", "password": "n3w"})
+		resolved, err = resolver.Resolve(context.TODO(), secret)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("n3w"), resolved.Data["password"])
+	})
+
+	t.Run("path not found", func(t *testing.T) {
+		_, err := resolver.Resolve(context.TODO(), newSecret(v1alpha3.CredentialSourceVault, "devops/gitlab"))
+		assert.Contains(t, err.Error(), "failed to resolve credential ns/github from vault")
+	})
+
+	t.Run("path is missing", func(t *testing.T) {
+		_, err := resolver.Resolve(context.TODO(), newSecret(v1alpha3.CredentialSourceVault, ""))
+		assert.Contains(t, err.Error(), v1alpha3.CredentialSourcePathAnnoKey)
+	})
+
+	t.Run("path out of the DevOpsProject", func(t *testing.T) {
+		for _, path := range []string{"/other-ns/devops/github", "../other-ns/devops/github",
+			"devops/../../other-ns/devops/github", "devops/./github", "devops//github", "devops/github?version=1",
+			"devops/%2e%2e/github"} {
+			_, err := resolver.Resolve(context.TODO(), newSecret(v1alpha3.CredentialSourceVault, path))
+			if assert.NotNil(t, err, path) {
+				assert.Contains(t, err.Error(), "invalid annotation "+v1alpha3.CredentialSourcePathAnnoKey, path)
+			}
+		}
+
+		// the same path refers to the data of its own DevOpsProject
+		secret := newSecret(v1alpha3.CredentialSourceVault, "devops/github")
+		secret.Namespace = "other-ns"
+		resolved, err := resolver.Resolve(context.TODO(), secret)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("other"), resolved.Data["username"])
+	})
+
+	t.Run("backend is not enabled", func(t *testing.T) {
+		_, err := resolver.Resolve(context.TODO(), newSecret("aws", "devops/github"))
+		assert.Contains(t, err.Error(), `the external secret backend "aws"`)
+
+		var nilResolver *Resolver
+		_, err = nilResolver.Resolve(context.TODO(), newSecret(v1alpha3.CredentialSourceVault, "devops/github"))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestNewResolverFromOptions(t *testing.T) {
+	resolver, err := NewResolverFromOptions(vault.NewVaultOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, resolver.backends)
+
+	_, err = NewResolverFromOptions(&vault.Options{Address: "vault:8200"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+// The types of the PipelineRun lifecycle events
+const (
+	// PipelineRunStarted is the event type when a PipelineRun has started
+	PipelineRunStarted = "io.kubesphere.devops.pipelinerun.started"
+	// PipelineRunPhaseChanged is the event type when the phase of a PipelineRun has changed
+	PipelineRunPhaseChanged = "io.kubesphere.devops.pipelinerun.phase.changed"
+	// PipelineRunFinished is the event type when a PipelineRun has completed
+	PipelineRunFinished = "io.kubesphere.devops.pipelinerun.finished"
+)
+
+// maskedParameterValue replaces the values of the secret parameters in the events
+const maskedParameterValue = "******"
+
+// PipelineRunData is the data of the PipelineRun lifecycle events
+type PipelineRunData struct {
+	Namespace   string                  `json:"namespace"`
+	Name        string                  `json:"name"`
+	RunID       string                  `json:"runID,omitempty"`
+	PipelineRef *corev1.ObjectReference `json:"pipelineRef,omitempty"`
+	SCM         *v1alpha3.SCM           `json:"scm,omitempty"`
+	Parameters  []v1alpha3.Parameter    `json:"parameters,omitempty"`
+	Phase       v1alpha3.RunPhase       `json:"phase,omitempty"`
+	// Result is the final phase of a completed PipelineRun, such as Succeeded, Failed and Cancelled
+	Result         string       `json:"result,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// DurationInMillis is the duration from the start to the completion, or to now if it's not completed
+	DurationInMillis int64 `json:"durationInMillis,omitempty"`
+}
+
+// GetPipelineRunEventTypes returns the types of the lifecycle events when the status of a PipelineRun changed
+func GetPipelineRunEventTypes(oldStatus, newStatus *v1alpha3.PipelineRunStatus) (eventTypes []string) {
+	if oldStatus.StartTime == nil && newStatus.StartTime != nil {
+		eventTypes = append(eventTypes, PipelineRunStarted)
+	}
+	if oldStatus.Phase != newStatus.Phase && newStatus.Phase != "" {
+		eventTypes = append(eventTypes, PipelineRunPhaseChanged)
+	}
+	if oldStatus.CompletionTime == nil && newStatus.CompletionTime != nil {
+		eventTypes = append(eventTypes, PipelineRunFinished)
+	}
+	return
+}
+
+// NewPipelineRunEvent creates a lifecycle event of a PipelineRun
+func NewPipelineRunEvent(eventType string, pr *v1alpha3.PipelineRun, now time.Time) Event {
+	data := &PipelineRunData{
+		Namespace:      pr.Namespace,
+		Name:           pr.Name,
+		SCM:            pr.Spec.SCM,
+		Parameters:     maskSecretParameters(pr),
+		Phase:          pr.Status.Phase,
+		StartTime:      pr.Status.StartTime,
+		CompletionTime: pr.Status.CompletionTime,
+	}
+	data.RunID, _ = pr.GetPipelineRunID()
+	if pr.Spec.PipelineRef != nil {
+		data.PipelineRef = pr.Spec.PipelineRef.DeepCopy()
+		if data.PipelineRef.Namespace == "" {
+			data.PipelineRef.Namespace = pr.Namespace
+		}
+	}
+	if pr.HasCompleted() {
+		data.Result = string(pr.Status.Phase)
+	}
+	if pr.Status.StartTime != nil {
+		endTime := now
+		if pr.HasCompleted() {
+			endTime = pr.Status.CompletionTime.Time
+		}
+		data.DurationInMillis = endTime.Sub(pr.Status.StartTime.Time).Milliseconds()
+	}
+
+	return Event{
+		ID:      string(uuid.NewUUID()),
+		Source:  fmt.Sprintf("/apis/%s/namespaces/%s/pipelineruns", v1alpha3.GroupVersion.String(), pr.Namespace),
+		Type:    eventType,
+		Subject: pr.Name,
+		Time:    now,
+		Data:    data,
+	}
+}
+
+// maskSecretParameters returns the parameters of a PipelineRun, the values of the password and credentials parameters
+// are masked according to the parameter definitions of the Pipeline
+func maskSecretParameters(pr *v1alpha3.PipelineRun) []v1alpha3.Parameter {
+	if len(pr.Spec.Parameters) == 0 {
+		return nil
+	}
+	secrets := make(map[string]bool)
+	if pr.Spec.PipelineSpec != nil && pr.Spec.PipelineSpec.Pipeline != nil {
+		for _, definition := range pr.Spec.PipelineSpec.Pipeline.Parameters {
+			if definition.IsSecret() {
+				secrets[definition.Name] = true
+			}
+		}
+	}
+
+	parameters := make([]v1alpha3.Parameter, len(pr.Spec.Parameters))
+	for i, param := range pr.Spec.Parameters {
+		parameters[i] = param
+		if secrets[param.Name] {
+			parameters[i].Value = maskedParameterValue
+		}
+	}
+	return parameters
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func TestGetPipelineRunEventTypes(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name      string
+		oldStatus v1alpha3.PipelineRunStatus
+		newStatus v1alpha3.PipelineRunStatus
+		want      []string
+	}{{
+		name:      "nothing changed",
+		oldStatus: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running, StartTime: &now},
+		newStatus: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running, StartTime: &now},
+	}, {
+		name:      "queued",
+		oldStatus: v1alpha3.PipelineRunStatus{},
+		newStatus: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Queued},
+		want:      []string{PipelineRunPhaseChanged},
+	}, {
+		name:      "started",
+		oldStatus: v1alpha3.PipelineRunStatus{},
+		newStatus: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running, StartTime: &now},
+		want:      []string{PipelineRunStarted, PipelineRunPhaseChanged},
+	}, {
+		name:      "finished",
+		oldStatus: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running, StartTime: &now},
+		newStatus: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Succeeded, StartTime: &now, CompletionTime: &now},
+		want:      []string{PipelineRunPhaseChanged, PipelineRunFinished},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetPipelineRunEventTypes(&tt.oldStatus, &tt.newStatus))
+		})
+	}
+}
+
+func TestNewPipelineRunEvent(t *testing.T) {
+	startTime := metav1.NewTime(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	completionTime := metav1.NewTime(startTime.Add(90 * time.Second))
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "pr",
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "3"},
+		},
+		Spec: v1alpha3.PipelineRunSpec{
+			PipelineRef: &corev1.ObjectReference{Name: "pipeline"},
+			SCM:         &v1alpha3.SCM{RefName: "main", RefType: "branch"},
+			Parameters:  []v1alpha3.Parameter{{Name: "a", Value: "b"}},
+		},
+		Status: v1alpha3.PipelineRunStatus{
+			Phase:     v1alpha3.Running,
+			StartTime: &startTime,
+		},
+	}
+
+	// a running PipelineRun has no result yet
+	event := NewPipelineRunEvent(PipelineRunStarted, pr, startTime.Add(time.Second))
+	assert.NotEmpty(t, event.ID)
+	assert.Equal(t, "/apis/devops.kubesphere.io/v1alpha3/namespaces/ns/pipelineruns", event.Source)
+	assert.Equal(t, PipelineRunStarted, event.Type)
+	assert.Equal(t, "pr", event.Subject)
+	data := event.Data.(*PipelineRunData)
+	assert.Equal(t, "3", data.RunID)
+	assert.Equal(t, &corev1.ObjectReference{Namespace: "ns", Name: "pipeline"}, data.PipelineRef)
+	assert.Equal(t, "main", data.SCM.RefName)
+	assert.Equal(t, pr.Spec.Parameters, data.Parameters)
+	assert.Empty(t, data.Result)
+	assert.Equal(t, int64(1000), data.DurationInMillis)
+
+	pr.Status.Phase = v1alpha3.Succeeded
+	pr.Status.CompletionTime = &completionTime
+	event = NewPipelineRunEvent(PipelineRunFinished, pr, time.Now())
+	data = event.Data.(*PipelineRunData)
+	assert.Equal(t, string(v1alpha3.Succeeded), data.Result)
+	assert.Equal(t, int64(90000), data.DurationInMillis)
+	// the PipelineRun itself should not be changed
+	assert.Equal(t, "", pr.Spec.PipelineRef.Namespace)
+}
+
+func TestNewPipelineRunEvent_secretParameters(t *testing.T) {
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr"},
+		Spec: v1alpha3.PipelineRunSpec{
+			PipelineSpec: &v1alpha3.PipelineSpec{
+				Type: v1alpha3.NoScmPipelineType,
+				Pipeline: &v1alpha3.NoScmPipeline{Parameters: []v1alpha3.ParameterDefinition{
+					{Name: "version", Type: "string"},
+					{Name: "token", Type: "password"},
+					{Name: "registry", Type: "credentials"},
+				}},
+			},
+			Parameters: []v1alpha3.Parameter{
+				{Name: "version", Value: "v1.0.0"},
+				{Name: "token", Value: "secret"},
+				{Name: "registry", Value: "docker-hub"},
+			},
+		},
+	}
+
+	data := NewPipelineRunEvent(PipelineRunStarted, pr, time.Now()).Data.(*PipelineRunData)
+	assert.Equal(t, []v1alpha3.Parameter{
+		{Name: "version", Value: "v1.0.0"},
+		{Name: "token", Value: "******"},
+		{Name: "registry", Value: "******"},
+	}, data.Parameters)
+	// the PipelineRun itself should not be changed
+	assert.Equal(t, "secret", pr.Spec.Parameters[1].Value)
+}
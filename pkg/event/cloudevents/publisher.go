@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/event/sender"
+)
+
+// Publisher sends the CloudEvents to the sinks in the background.
+// It implements manager.Runnable, so it starts and stops along with the controller manager.
+type Publisher struct {
+	sinks  []string
+	sender *sender.Sender
+}
+
+// NewPublisher creates a Publisher which sends the events to the given sink URLs
+func NewPublisher(sinks []string) *Publisher {
+	return &Publisher{
+		sinks:  sinks,
+		sender: sender.New(),
+	}
+}
+
+// Publish puts the event into the queue without blocking, the event is dropped if the queue is full
+func (p *Publisher) Publish(event Event) {
+	if p == nil || len(p.sinks) == 0 {
+		return
+	}
+	for _, sink := range p.sinks {
+		sink := sink
+		queued := p.sender.Enqueue(sink, func(ctx context.Context) {
+			if err := p.Send(ctx, sink, event); err != nil {
+				klog.Errorf("failed to send CloudEvent %s of type %s to %s, error: %v", event.ID, event.Type, sink, err)
+			}
+		})
+		if !queued {
+			klog.Warningf("dropped CloudEvent %s of type %s to %s due to the full queue, %d dropped in total",
+				event.ID, event.Type, sink, p.sender.Dropped())
+		}
+	}
+}
+
+// Start sends the queued events until the context is done
+func (p *Publisher) Start(ctx context.Context) error {
+	return p.sender.Start(ctx)
+}
+
+// Send sends an event to a sink in the HTTP binary content mode, it retries with backoff if the sink is unavailable
+func (p *Publisher) Send(ctx context.Context, sink string, event Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set(HeaderSpecVersion, SpecVersion)
+	header.Set(HeaderID, event.ID)
+	header.Set(HeaderSource, event.Source)
+	header.Set(HeaderType, event.Type)
+	if event.Subject != "" {
+		header.Set(HeaderSubject, event.Subject)
+	}
+	header.Set(HeaderTime, event.Time.UTC().Format(time.RFC3339Nano))
+	header.Set("Content-Type", ContentTypeJSON)
+	return p.sender.Post(ctx, sink, header, data)
+}
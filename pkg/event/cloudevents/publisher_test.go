@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"kubesphere.io/devops/pkg/event/sender"
+)
+
+func newTestPublisher(sinks ...string) *Publisher {
+	p := NewPublisher(sinks)
+	p.sender.Backoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+	return p
+}
+
+func TestPublisher_Send(t *testing.T) {
+	now := time.Now()
+	event := Event{
+		ID:      "id",
+		Source:  "/source",
+		Type:    "io.kubesphere.devops.test",
+		Subject: "subject",
+		Time:    now,
+		Data:    map[string]string{"key": "value"},
+	}
+
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		wantErr      bool
+		wantRequests int32
+	}{{
+		name:         "accepted at the first time",
+		statusCodes:  []int{http.StatusAccepted},
+		wantRequests: 1,
+	}, {
+		name:         "retry if the sink is unavailable",
+		statusCodes:  []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+		wantRequests: 3,
+	}, {
+		name:         "give up after the backoff steps",
+		statusCodes:  []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+		wantErr:      true,
+		wantRequests: 3,
+	}, {
+		name:         "no retry if the sink refuses the event",
+		statusCodes:  []int{http.StatusBadRequest},
+		wantErr:      true,
+		wantRequests: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &Sink{}
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				index := atomic.AddInt32(&requests, 1) - 1
+				if code := tt.statusCodes[index]; code >= 300 {
+					w.WriteHeader(code)
+					return
+				}
+				sink.ServeHTTP(w, req)
+			}))
+			defer server.Close()
+
+			err := newTestPublisher(server.URL).Send(context.TODO(), server.URL, event)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantRequests, atomic.LoadInt32(&requests))
+			if tt.wantErr {
+				return
+			}
+
+			events := sink.Events()
+			if assert.Len(t, events, 1) {
+				received := events[0]
+				assert.Equal(t, "id", received.ID)
+				assert.Equal(t, "/source", received.Source)
+				assert.Equal(t, "io.kubesphere.devops.test", received.Type)
+				assert.Equal(t, "subject", received.Subject)
+				assert.True(t, now.Equal(received.Time))
+				assert.JSONEq(t, `{"key":"value"}`, string(received.Data))
+			}
+		})
+	}
+}
+
+func TestPublisher_Start(t *testing.T) {
+	sink := &Sink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	p := newTestPublisher(server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.Start(ctx)
+	}()
+
+	p.Publish(Event{ID: "1", Source: "/source", Type: "type", Data: []string{"a"}})
+	p.Publish(Event{ID: "2", Source: "/source", Type: "type"})
+	assert.Eventually(t, func() bool {
+		return len(sink.Events()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var data []string
+	assert.Nil(t, json.Unmarshal(sink.Events()[0].Data, &data))
+	assert.Equal(t, []string{"a"}, data)
+
+	cancel()
+	assert.Nil(t, <-done)
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	// it's safe to publish events without any sink
+	var nilPublisher *Publisher
+	nilPublisher.Publish(Event{ID: "1"})
+	p := NewPublisher(nil)
+	p.Publish(Event{ID: "1"})
+	assert.Equal(t, 0, p.sender.Len())
+
+	// drop the events once the queue is full
+	p = NewPublisher([]string{"http://localhost"})
+	for i := 0; i <= sender.DefaultQueueSize; i++ {
+		p.Publish(Event{ID: "1"})
+	}
+	assert.Equal(t, sender.DefaultQueueSize, p.sender.Len())
+	assert.Equal(t, int64(1), p.sender.Dropped())
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReceivedEvent is an event which was received by the Sink, its data is kept as raw JSON
+type ReceivedEvent struct {
+	Event
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Sink is a local sink which receives the CloudEvents in the HTTP binary content mode.
+// It keeps the events in memory, it's useful to test or debug the event delivery.
+type Sink struct {
+	// OnReceive is called when an event was received
+	OnReceive func(event ReceivedEvent)
+
+	mutex  sync.Mutex
+	events []ReceivedEvent
+}
+
+var _ http.Handler = &Sink{}
+
+// ServeHTTP receives an event
+func (s *Sink) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Header.Get(HeaderSpecVersion) != SpecVersion || req.Header.Get(HeaderID) == "" ||
+		req.Header.Get(HeaderSource) == "" || req.Header.Get(HeaderType) == "" {
+		http.Error(w, "it is not a valid CloudEvent in the binary content mode", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event := ReceivedEvent{
+		Event: Event{
+			ID:      req.Header.Get(HeaderID),
+			Source:  req.Header.Get(HeaderSource),
+			Type:    req.Header.Get(HeaderType),
+			Subject: req.Header.Get(HeaderSubject),
+		},
+		Data: data,
+	}
+	event.Time, _ = time.Parse(time.RFC3339Nano, req.Header.Get(HeaderTime))
+
+	s.mutex.Lock()
+	s.events = append(s.events, event)
+	s.mutex.Unlock()
+	if s.OnReceive != nil {
+		s.OnReceive(event)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Events returns the received events
+func (s *Sink) Events() []ReceivedEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]ReceivedEvent{}, s.events...)
+}
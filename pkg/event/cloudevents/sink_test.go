@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSink(t *testing.T) {
+	validHeaders := map[string]string{
+		HeaderSpecVersion: SpecVersion,
+		HeaderID:          "id",
+		HeaderSource:      "/source",
+		HeaderType:        "type",
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantCode   int
+		wantEvents int
+	}{{
+		name:     "unsupported method",
+		method:   http.MethodGet,
+		headers:  validHeaders,
+		wantCode: http.StatusMethodNotAllowed,
+	}, {
+		name:     "missing the event attributes",
+		method:   http.MethodPost,
+		headers:  map[string]string{HeaderSpecVersion: SpecVersion},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "unsupported spec version",
+		method:   http.MethodPost,
+		headers:  map[string]string{HeaderSpecVersion: "0.3", HeaderID: "id", HeaderSource: "/source", HeaderType: "type"},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:       "valid event",
+		method:     http.MethodPost,
+		headers:    validHeaders,
+		wantCode:   http.StatusAccepted,
+		wantEvents: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []ReceivedEvent
+			sink := &Sink{OnReceive: func(event ReceivedEvent) {
+				received = append(received, event)
+			}}
+
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(`{}`))
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			sink.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Len(t, sink.Events(), tt.wantEvents)
+			assert.Equal(t, sink.Events(), append([]ReceivedEvent{}, received...))
+		})
+	}
+}
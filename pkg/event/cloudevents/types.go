@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"time"
+)
+
+// SpecVersion is the version of the CloudEvents specification
+const SpecVersion = "1.0"
+
+// The HTTP headers of the event attributes in the binary content mode.
+// See also: https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/bindings/http-protocol-binding.md#31-binary-content-mode
+const (
+	HeaderSpecVersion = "ce-specversion"
+	HeaderID          = "ce-id"
+	HeaderSource      = "ce-source"
+	HeaderType        = "ce-type"
+	HeaderSubject     = "ce-subject"
+	HeaderTime        = "ce-time"
+	// ContentTypeJSON is the content type of the event data
+	ContentTypeJSON = "application/json"
+)
+
+// Event is a CloudEvent, its data will be encoded as JSON
+type Event struct {
+	ID      string      `json:"id"`
+	Source  string      `json:"source"`
+	Type    string      `json:"type"`
+	Subject string      `json:"subject,omitempty"`
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data,omitempty"`
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"net/http"
+
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/event/sender"
+)
+
+// Notifier sends the notifications to the receivers in the background.
+// It implements manager.Runnable, so it starts and stops along with the controller manager.
+type Notifier struct {
+	receivers []Receiver
+	sender    *sender.Sender
+}
+
+// NewNotifier creates a Notifier which sends the notifications to the given receivers
+func NewNotifier(receivers []Receiver) *Notifier {
+	return &Notifier{
+		receivers: receivers,
+		sender:    sender.New(),
+	}
+}
+
+// Notify puts the notification into the queue without blocking, the notification is dropped if the queue is full
+func (n *Notifier) Notify(notification *Notification) {
+	if n == nil || len(n.receivers) == 0 {
+		return
+	}
+	for _, receiver := range n.receivers {
+		receiver := receiver
+		queued := n.sender.Enqueue(receiver.URL, func(ctx context.Context) {
+			if err := n.Send(ctx, receiver, notification); err != nil {
+				klog.Errorf("failed to send the %s notification of Application %s/%s to the %s receiver, error: %v",
+					notification.Event, notification.Namespace, notification.Application, receiver.Type, err)
+			}
+		})
+		if !queued {
+			klog.Warningf("dropped the %s notification of Application %s/%s to the %s receiver due to the full queue, "+
+				"%d dropped in total", notification.Event, notification.Namespace, notification.Application,
+				receiver.Type, n.sender.Dropped())
+		}
+	}
+}
+
+// Start sends the queued notifications until the context is done
+func (n *Notifier) Start(ctx context.Context) error {
+	return n.sender.Start(ctx)
+}
+
+// Send sends a notification to a receiver, it retries with backoff if the receiver is unavailable
+func (n *Notifier) Send(ctx context.Context, receiver Receiver, notification *Notification) error {
+	data, err := receiver.payload(notification)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return n.sender.Post(ctx, receiver.URL, header, data)
+}
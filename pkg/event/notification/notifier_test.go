@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"kubesphere.io/devops/pkg/event/sender"
+)
+
+func newTestNotifier(receivers ...Receiver) *Notifier {
+	n := NewNotifier(receivers)
+	n.sender.Backoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+	return n
+}
+
+var fakeNotification = &Notification{
+	Event:       EventDegraded,
+	Namespace:   "fake-ns",
+	Application: "fake-app",
+	Health:      "Degraded",
+	SyncStatus:  "Synced",
+	Message:     "fake message",
+	Time:        time.Date(2022, 10, 17, 10, 0, 0, 0, time.UTC),
+}
+
+func TestNotifier_Send(t *testing.T) {
+	tests := []struct {
+		name         string
+		receiverType ReceiverType
+		statusCodes  []int
+		wantErr      bool
+		wantRequests int32
+		wantBody     string
+	}{{
+		name:         "webhook",
+		receiverType: ReceiverTypeWebhook,
+		statusCodes:  []int{http.StatusOK},
+		wantRequests: 1,
+		wantBody: `{"event":"Degraded","namespace":"fake-ns","application":"fake-app","health":"Degraded",
+"syncStatus":"Synced","message":"fake message","time":"2022-10-17T10:00:00Z"}`,
+	}, {
+		name:         "slack",
+		receiverType: ReceiverTypeSlack,
+		statusCodes:  []int{http.StatusOK},
+		wantRequests: 1,
+		wantBody:     `{"text":"[Degraded] Application fake-ns/fake-app is Degraded: fake message"}`,
+	}, {
+		name:         "dingtalk",
+		receiverType: ReceiverTypeDingTalk,
+		statusCodes:  []int{http.StatusOK},
+		wantRequests: 1,
+		wantBody: `{"msgtype":"text",
+"text":{"content":"[Degraded] Application fake-ns/fake-app is Degraded: fake message"}}`,
+	}, {
+		name:         "feishu",
+		receiverType: ReceiverTypeFeishu,
+		statusCodes:  []int{http.StatusOK},
+		wantRequests: 1,
+		wantBody: `{"msg_type":"text",
+"content":{"text":"[Degraded] Application fake-ns/fake-app is Degraded: fake message"}}`,
+	}, {
+		name:         "retry if the receiver is unavailable",
+		receiverType: ReceiverTypeWebhook,
+		statusCodes:  []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+		wantRequests: 3,
+	}, {
+		name:         "no retry if the receiver refuses the notification",
+		receiverType: ReceiverTypeWebhook,
+		statusCodes:  []int{http.StatusBadRequest},
+		wantErr:      true,
+		wantRequests: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				index := atomic.AddInt32(&requests, 1) - 1
+				body, _ = io.ReadAll(req.Body)
+				w.WriteHeader(tt.statusCodes[index])
+			}))
+			defer server.Close()
+
+			receiver := Receiver{Type: tt.receiverType, URL: server.URL}
+			err := newTestNotifier(receiver).Send(context.TODO(), receiver, fakeNotification)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantRequests, atomic.LoadInt32(&requests))
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, string(body))
+			}
+		})
+	}
+}
+
+func TestNotifier_Start(t *testing.T) {
+	var lock sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, req.URL.Path)
+	}))
+	defer server.Close()
+
+	n := newTestNotifier(Receiver{Type: ReceiverTypeWebhook, URL: server.URL + "/webhook"},
+		Receiver{Type: ReceiverTypeSlack, URL: server.URL + "/slack"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- n.Start(ctx)
+	}()
+
+	n.Notify(fakeNotification)
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"/webhook", "/slack"}, received)
+
+	cancel()
+	assert.Nil(t, <-done)
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	// it's safe to notify without any receiver
+	var nilNotifier *Notifier
+	nilNotifier.Notify(fakeNotification)
+	n := NewNotifier(nil)
+	n.Notify(fakeNotification)
+	assert.Equal(t, 0, n.sender.Len())
+
+	// drop the notifications once the queue is full
+	n = NewNotifier([]Receiver{{Type: ReceiverTypeWebhook, URL: "http://localhost"}})
+	for i := 0; i <= sender.DefaultQueueSize; i++ {
+		n.Notify(fakeNotification)
+	}
+	assert.Equal(t, sender.DefaultQueueSize, n.sender.Len())
+	assert.Equal(t, int64(1), n.sender.Dropped())
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ReceiverType is the type of receiver, it decides the format of the message
+type ReceiverType string
+
+const (
+	// ReceiverTypeWebhook receives the Notification as JSON
+	ReceiverTypeWebhook ReceiverType = "webhook"
+	// ReceiverTypeSlack is the incoming webhook of Slack
+	ReceiverTypeSlack ReceiverType = "slack"
+	// ReceiverTypeDingTalk is the custom robot of DingTalk
+	ReceiverTypeDingTalk ReceiverType = "dingtalk"
+	// ReceiverTypeWeCom is the group robot of WeCom
+	ReceiverTypeWeCom ReceiverType = "wecom"
+	// ReceiverTypeFeishu is the custom bot of Feishu
+	ReceiverTypeFeishu ReceiverType = "feishu"
+)
+
+var supportedReceiverTypes = []ReceiverType{ReceiverTypeWebhook, ReceiverTypeSlack, ReceiverTypeDingTalk,
+	ReceiverTypeWeCom, ReceiverTypeFeishu}
+
+// Receiver receives the notifications by HTTP POST requests
+type Receiver struct {
+	Type ReceiverType
+	URL  string
+}
+
+// ParseReceiver parses a receiver in the format of [type=]url, the type is webhook if it's omitted.
+// For example: slack=https://hooks.slack.com/services/xxx
+func ParseReceiver(receiver string) (result Receiver, err error) {
+	result = Receiver{Type: ReceiverTypeWebhook, URL: receiver}
+	if parts := strings.SplitN(receiver, "=", 2); len(parts) == 2 {
+		for _, supported := range supportedReceiverTypes {
+			if parts[0] == string(supported) {
+				result = Receiver{Type: supported, URL: parts[1]}
+				break
+			}
+		}
+	}
+
+	var parsed *url.URL
+	if parsed, err = url.Parse(result.URL); err == nil && (parsed.Scheme != "http" && parsed.Scheme != "https" ||
+		parsed.Host == "") {
+		err = fmt.Errorf("the URL must be an absolute HTTP or HTTPS URL")
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid notification receiver %q: %v", receiver, err)
+	}
+	return
+}
+
+// ParseReceivers parses the receivers in the format of [type=]url
+func ParseReceivers(receivers []string) (result []Receiver, err error) {
+	result = make([]Receiver, 0, len(receivers))
+	for _, receiver := range receivers {
+		var parsed Receiver
+		if parsed, err = ParseReceiver(receiver); err != nil {
+			return
+		}
+		result = append(result, parsed)
+	}
+	return
+}
+
+// payload returns the request body of the notification in the format of the receiver
+func (r Receiver) payload(n *Notification) ([]byte, error) {
+	var body interface{}
+	switch r.Type {
+	case ReceiverTypeSlack:
+		body = map[string]string{"text": n.Text()}
+	case ReceiverTypeDingTalk, ReceiverTypeWeCom:
+		body = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": n.Text()},
+		}
+	case ReceiverTypeFeishu:
+		body = map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": n.Text()},
+		}
+	default:
+		body = n
+	}
+	return json.Marshal(body)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReceiver(t *testing.T) {
+	tests := []struct {
+		receiver string
+		want     Receiver
+		wantErr  bool
+	}{{
+		receiver: "https://example.com/webhook?token=abc",
+		want:     Receiver{Type: ReceiverTypeWebhook, URL: "https://example.com/webhook?token=abc"},
+	}, {
+		receiver: "slack=https://hooks.slack.com/services/fake",
+		want:     Receiver{Type: ReceiverTypeSlack, URL: "https://hooks.slack.com/services/fake"},
+	}, {
+		receiver: "dingtalk=https://oapi.dingtalk.com/robot/send?access_token=fake",
+		want:     Receiver{Type: ReceiverTypeDingTalk, URL: "https://oapi.dingtalk.com/robot/send?access_token=fake"},
+	}, {
+		receiver: "wecom=https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=fake",
+		want:     Receiver{Type: ReceiverTypeWeCom, URL: "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=fake"},
+	}, {
+		receiver: "unknown=https://example.com",
+		wantErr:  true,
+	}, {
+		receiver: "slack=hooks.slack.com",
+		wantErr:  true,
+	}, {
+		receiver: "ftp://example.com",
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.receiver, func(t *testing.T) {
+			got, err := ParseReceiver(tt.receiver)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseReceivers(t *testing.T) {
+	receivers, err := ParseReceivers([]string{"https://example.com", "slack=https://hooks.slack.com/services/fake"})
+	assert.Nil(t, err)
+	assert.Equal(t, []Receiver{
+		{Type: ReceiverTypeWebhook, URL: "https://example.com"},
+		{Type: ReceiverTypeSlack, URL: "https://hooks.slack.com/services/fake"},
+	}, receivers)
+
+	_, err = ParseReceivers([]string{"https://example.com", "invalid"})
+	assert.Error(t, err)
+}
+
+func TestNotification_Text(t *testing.T) {
+	assert.Equal(t, "[OutOfSync] Application ns/app is OutOfSync", (&Notification{
+		Event: EventOutOfSync, Namespace: "ns", Application: "app",
+	}).Text())
+	assert.Equal(t, "[Recovered] Application ns/app recovered, it is Healthy and Synced", (&Notification{
+		Event: EventRecovered, Namespace: "ns", Application: "app", Health: "Healthy", SyncStatus: "Synced",
+	}).Text())
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event is the status change of a gitops Application which triggers a notification
+type Event string
+
+const (
+	// EventDegraded means the Application became Degraded
+	EventDegraded Event = "Degraded"
+	// EventOutOfSync means the Application became OutOfSync
+	EventOutOfSync Event = "OutOfSync"
+	// EventRecovered means the Application became Healthy and Synced again
+	EventRecovered Event = "Recovered"
+)
+
+// Notification is the payload of the webhook receivers
+type Notification struct {
+	Event       Event     `json:"event"`
+	Namespace   string    `json:"namespace"`
+	Application string    `json:"application"`
+	Health      string    `json:"health,omitempty"`
+	SyncStatus  string    `json:"syncStatus,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Text returns the human-readable text of the notification, it's sent to the chat receivers
+func (n *Notification) Text() string {
+	var text string
+	switch n.Event {
+	case EventRecovered:
+		text = fmt.Sprintf("[%s] Application %s/%s recovered, it is %s and %s", n.Event, n.Namespace, n.Application,
+			n.Health, n.SyncStatus)
+	default:
+		text = fmt.Sprintf("[%s] Application %s/%s is %s", n.Event, n.Namespace, n.Application, n.Event)
+	}
+	if n.Message != "" {
+		text += ": " + n.Message
+	}
+	return text
+}
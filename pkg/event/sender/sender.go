@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sender posts the events to the external HTTP endpoints in the background, the failed requests are retried
+// with backoff. Each endpoint has its own queue and worker, so a slow endpoint does not delay the others.
+// It's shared by the CloudEvents publisher and the Application notifier.
+package sender
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// DefaultQueueSize is the number of jobs which are waiting to be run of each endpoint
+const DefaultQueueSize = 100
+
+// DefaultBackoff is the backoff of retrying to send a request, it gives up after about half a minute
+var DefaultBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+// Job sends something with the Sender, it's run in the background
+type Job func(ctx context.Context)
+
+// Sender runs the queued jobs of each endpoint one by one in the background.
+// It implements manager.Runnable, so it starts and stops along with the controller manager.
+type Sender struct {
+	// Backoff is the backoff of retrying to send a request
+	Backoff wait.Backoff
+
+	client  *http.Client
+	dropped int64
+
+	mutex sync.Mutex
+	// ctx is the context of the workers, it's nil until the Sender starts
+	ctx    context.Context
+	queues map[string]chan Job
+}
+
+// New creates a Sender with the default backoff and queue size
+func New() *Sender {
+	return &Sender{
+		Backoff: DefaultBackoff,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queues:  map[string]chan Job{},
+	}
+}
+
+// Enqueue puts the job into the queue of the endpoint without blocking.
+// It returns false if the job is dropped due to the full queue, the dropped jobs are counted.
+func (s *Sender) Enqueue(endpoint string, job Job) bool {
+	s.mutex.Lock()
+	queue, ok := s.queues[endpoint]
+	if !ok {
+		queue = make(chan Job, DefaultQueueSize)
+		s.queues[endpoint] = queue
+		if s.ctx != nil {
+			go work(s.ctx, queue)
+		}
+	}
+	s.mutex.Unlock()
+
+	select {
+	case queue <- job:
+		return true
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+		return false
+	}
+}
+
+// Len returns the number of the jobs in all the queues
+func (s *Sender) Len() (count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, queue := range s.queues {
+		count += len(queue)
+	}
+	return
+}
+
+// Dropped returns the number of the jobs which were dropped due to the full queues
+func (s *Sender) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Start runs the queued jobs until the context is done
+func (s *Sender) Start(ctx context.Context) error {
+	s.mutex.Lock()
+	s.ctx = ctx
+	for _, queue := range s.queues {
+		go work(ctx, queue)
+	}
+	s.mutex.Unlock()
+
+	<-ctx.Done()
+	return nil
+}
+
+// work runs the jobs of a queue one by one until the context is done
+func work(ctx context.Context, queue chan Job) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-queue:
+			job(ctx)
+		}
+	}
+}
+
+// Post sends the body to the URL, it retries with backoff if the server is unavailable
+func (s *Sender) Post(ctx context.Context, url string, header http.Header, body []byte) error {
+	return retry.OnError(s.Backoff, isRetriable, func() error {
+		return s.post(ctx, url, header, body)
+	})
+}
+
+func (s *Sender) post(ctx context.Context, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	default:
+		// the server refuses the request, it makes no sense to retry
+		return &permanentError{fmt.Errorf("unexpected status code %d", resp.StatusCode)}
+	}
+}
+
+// permanentError indicates the request could not be delivered by retrying
+type permanentError struct {
+	error
+}
+
+func isRetriable(err error) bool {
+	var permanent *permanentError
+	return !errors.As(err, &permanent)
+}
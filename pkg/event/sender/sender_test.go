@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sender
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func newTestSender() *Sender {
+	s := New()
+	s.Backoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+	return s
+}
+
+func TestSender_Post(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		wantErr      bool
+		wantRequests int32
+	}{{
+		name:         "accepted at the first time",
+		statusCodes:  []int{http.StatusAccepted},
+		wantRequests: 1,
+	}, {
+		name:         "retry if the server is unavailable",
+		statusCodes:  []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+		wantRequests: 3,
+	}, {
+		name:         "give up after the backoff steps",
+		statusCodes:  []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+		wantErr:      true,
+		wantRequests: 3,
+	}, {
+		name:         "no retry if the server refuses the request",
+		statusCodes:  []int{http.StatusBadRequest},
+		wantErr:      true,
+		wantRequests: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				index := atomic.AddInt32(&requests, 1) - 1
+				body, _ := io.ReadAll(req.Body)
+				assert.Equal(t, http.MethodPost, req.Method)
+				assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+				assert.Equal(t, `{"key":"value"}`, string(body))
+				w.WriteHeader(tt.statusCodes[index])
+			}))
+			defer server.Close()
+
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			err := newTestSender().Post(context.TODO(), server.URL, header, []byte(`{"key":"value"}`))
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantRequests, atomic.LoadInt32(&requests))
+		})
+	}
+
+	// an invalid URL is not retried
+	err := newTestSender().Post(context.TODO(), "://invalid", nil, nil)
+	assert.False(t, isRetriable(err))
+}
+
+func TestSender_Start(t *testing.T) {
+	s := newTestSender()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Start(ctx)
+	}()
+
+	var jobs int32
+	for i := 0; i < 2; i++ {
+		assert.True(t, s.Enqueue("http://a", func(context.Context) {
+			atomic.AddInt32(&jobs, 1)
+		}))
+	}
+	// the queue which is created after starting has a worker as well
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&jobs) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, s.Enqueue("http://b", func(context.Context) {
+		atomic.AddInt32(&jobs, 1)
+	}))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&jobs) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done)
+}
+
+func TestSender_hangingEndpoint(t *testing.T) {
+	hanging := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-hanging
+	}))
+	defer slow.Close()
+	// release the hanging requests before closing the server
+	defer close(hanging)
+	var requests int32
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer fast.Close()
+
+	s := newTestSender()
+	// queue the jobs before starting, the workers pick them up once started
+	for _, url := range []string{slow.URL, fast.URL, slow.URL, fast.URL} {
+		url := url
+		assert.True(t, s.Enqueue(url, func(ctx context.Context) {
+			_ = s.Post(ctx, url, nil, nil)
+		}))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = s.Start(ctx)
+	}()
+
+	// the hanging endpoint does not delay the others
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&requests) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestSender_Enqueue(t *testing.T) {
+	// drop the jobs once the queue is full
+	s := New()
+	for i := 0; i < DefaultQueueSize; i++ {
+		assert.True(t, s.Enqueue("http://a", func(context.Context) {}))
+	}
+	assert.False(t, s.Enqueue("http://a", func(context.Context) {}))
+	assert.Equal(t, int64(1), s.Dropped())
+
+	// the queues of the other endpoints are not affected
+	assert.True(t, s.Enqueue("http://b", func(context.Context) {}))
+	assert.Equal(t, DefaultQueueSize+1, s.Len())
+}
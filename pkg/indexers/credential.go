@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package indexers
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CredentialReferenceObjects are the objects which might reference the credentials
+var CredentialReferenceObjects = []client.Object{
+	&v1alpha3.Pipeline{},
+	&v1alpha3.GitRepository{},
+	&v1alpha1.ImageUpdater{},
+	&v1alpha1.ChartRepository{},
+	&v1alpha3.ClusterStepTemplate{},
+}
+
+// jenkinsfileCredentialPattern matches the credentials in a Jenkinsfile, such as: credentialsId: 'github'
+var jenkinsfileCredentialPattern = regexp.MustCompile(`credentialsId\s*:\s*['"]([^'"$]+)['"]`)
+
+// CreateCredentialReferenceIndexer creates field indexers which could speed up listing the objects by the credentials
+// they reference. The objects whose CRDs are not installed are skipped.
+func CreateCredentialReferenceIndexer(runtimeCache cache.Cache) error {
+	for _, obj := range CredentialReferenceObjects {
+		if err := runtimeCache.IndexField(context.Background(), obj, v1alpha3.CredentialReferenceField,
+			ExtractCredentialReferences); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractCredentialReferences returns the credentials referenced by an object, in the format of namespace/name.
+// The credential of a step template is usually chosen when a step is rendered into a Jenkinsfile, so it's extracted
+// from the Jenkinsfile of the Pipeline. Only the default credential is referenced by the step template itself.
+func ExtractCredentialReferences(o client.Object) []string {
+	namespace := o.GetNamespace()
+	var names []string
+	switch obj := o.(type) {
+	case *v1alpha3.Pipeline:
+		if pipeline := obj.Spec.Pipeline; pipeline != nil {
+			for _, match := range jenkinsfileCredentialPattern.FindAllStringSubmatch(pipeline.Jenkinsfile, -1) {
+				names = append(names, match[1])
+			}
+		}
+		if pipeline := obj.Spec.MultiBranchPipeline; pipeline != nil {
+			if pipeline.GitSource != nil {
+				names = append(names, pipeline.GitSource.CredentialId)
+			}
+			if pipeline.GitHubSource != nil {
+				names = append(names, pipeline.GitHubSource.CredentialId)
+			}
+			if pipeline.GitlabSource != nil {
+				names = append(names, pipeline.GitlabSource.CredentialId)
+			}
+			if pipeline.BitbucketServerSource != nil {
+				names = append(names, pipeline.BitbucketServerSource.CredentialId)
+			}
+			if pipeline.SvnSource != nil {
+				names = append(names, pipeline.SvnSource.CredentialId)
+			}
+			if pipeline.SingleSvnSource != nil {
+				names = append(names, pipeline.SingleSvnSource.CredentialId)
+			}
+		}
+	case *v1alpha3.GitRepository:
+		if secret := obj.Spec.Secret; secret != nil && secret.Name != "" {
+			if secret.Namespace != "" {
+				return []string{secret.Namespace + "/" + secret.Name}
+			}
+			names = append(names, secret.Name)
+		}
+	case *v1alpha1.ImageUpdater:
+		if obj.Spec.Argo != nil {
+			for _, secret := range obj.Spec.Argo.Secrets {
+				names = append(names, secret)
+			}
+		}
+		if obj.Spec.Flux != nil {
+			for _, secret := range obj.Spec.Flux.Secrets {
+				names = append(names, secret)
+			}
+		}
+	case *v1alpha1.ChartRepository:
+		if obj.Spec.Secret != nil {
+			names = append(names, obj.Spec.Secret.Name)
+		}
+	case *v1alpha3.ClusterStepTemplate:
+		// the step template is cluster scoped, so the credential must have its namespace
+		if secret := obj.Spec.Secret; secret.Name != "" && secret.Namespace != "" {
+			names = append(names, secret.Namespace+"/"+secret.Name)
+		}
+	}
+	return toCredentialKeys(namespace, names)
+}
+
+// toCredentialKeys converts the names into the sorted unique keys, the name could have its own namespace
+func toCredentialKeys(namespace string, names []string) []string {
+	keys := make([]string, 0, len(names))
+	found := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		key := name
+		if !strings.Contains(name, "/") {
+			key = namespace + "/" + name
+		}
+		if !found[key] {
+			found[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
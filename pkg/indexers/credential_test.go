@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package indexers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCreateCredentialReferenceIndexer(t *testing.T) {
+	assert.Nil(t, CreateCredentialReferenceIndexer(&informertest.FakeInformers{}))
+}
+
+func TestExtractCredentialReferences(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{Namespace: "ns", Name: "fake"}
+	tests := []struct {
+		name string
+		obj  client.Object
+		want []string
+	}{{
+		name: "pipeline without credentials",
+		obj: &v1alpha3.Pipeline{
+			ObjectMeta: objectMeta,
+			Spec:       v1alpha3.PipelineSpec{Type: v1alpha3.NoScmPipelineType},
+		},
+		want: []string{},
+	}, {
+		name: "credentials in the Jenkinsfile",
+		obj: &v1alpha3.Pipeline{
+			ObjectMeta: objectMeta,
+			Spec: v1alpha3.PipelineSpec{
+				Type: v1alpha3.NoScmPipelineType,
+				Pipeline: &v1alpha3.NoScmPipeline{Jenkinsfile: `pipeline {
+  stages {
+    stage('clone') {
+      steps {
+        git(url: 'https://github.com/fake/fake', credentialsId: 'github', branch: 'master')
+        withCredentials([usernamePassword(credentialsId : "harbor", passwordVariable: 'PASS', usernameVariable: 'USER')]) {
+          sh 'docker login'
+        }
+        withCredentials([string(credentialsId: "$TOKEN", variable: 'TOKEN')]) {
+          sh 'echo github'
+        }
+        git(url: 'https://github.com/fake/another', credentialsId: 'github')
+      }
+    }
+  }
+}`},
+			},
+		},
+		want: []string{"ns/github", "ns/harbor"},
+	}, {
+		name: "multi-branch pipeline",
+		obj: &v1alpha3.Pipeline{
+			ObjectMeta: objectMeta,
+			Spec: v1alpha3.PipelineSpec{
+				Type: v1alpha3.MultiBranchPipelineType,
+				MultiBranchPipeline: &v1alpha3.MultiBranchPipeline{
+					GitHubSource: &v1alpha3.GithubSource{CredentialId: "github"},
+				},
+			},
+		},
+		want: []string{"ns/github"},
+	}, {
+		name: "git repository",
+		obj: &v1alpha3.GitRepository{
+			ObjectMeta: objectMeta,
+			Spec:       v1alpha3.GitRepositorySpec{Secret: &v1.SecretReference{Name: "git"}},
+		},
+		want: []string{"ns/git"},
+	}, {
+		name: "git repository with a secret in another namespace",
+		obj: &v1alpha3.GitRepository{
+			ObjectMeta: objectMeta,
+			Spec:       v1alpha3.GitRepositorySpec{Secret: &v1.SecretReference{Namespace: "other", Name: "git"}},
+		},
+		want: []string{"other/git"},
+	}, {
+		name: "image updater",
+		obj: &v1alpha1.ImageUpdater{
+			ObjectMeta: objectMeta,
+			Spec: v1alpha1.ImageUpdaterSpec{
+				Argo: &v1alpha1.ArgoImageUpdater{Secrets: map[string]string{"nginx": "other/docker"}},
+				Flux: &v1alpha1.FluxImageUpdater{Secrets: map[string]string{"nginx": "docker", "redis": "docker"}},
+			},
+		},
+		want: []string{"ns/docker", "other/docker"},
+	}, {
+		name: "chart repository",
+		obj: &v1alpha1.ChartRepository{
+			ObjectMeta: objectMeta,
+			Spec:       v1alpha1.ChartRepositorySpec{Secret: &v1.LocalObjectReference{Name: "helm"}},
+		},
+		want: []string{"ns/helm"},
+	}, {
+		name: "step template without the default credential",
+		obj: &v1alpha3.ClusterStepTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake"},
+			Spec:       v1alpha3.StepTemplateSpec{Secret: v1alpha3.SecretInStep{Type: "basic-auth", Wrap: true}},
+		},
+		want: []string{},
+	}, {
+		name: "step template with the default credential",
+		obj: &v1alpha3.ClusterStepTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake"},
+			Spec: v1alpha3.StepTemplateSpec{Secret: v1alpha3.SecretInStep{
+				Type: "basic-auth", Namespace: "ns", Name: "harbor"}},
+		},
+		want: []string{"ns/harbor"},
+	}, {
+		name: "unknown object",
+		obj:  &v1.Secret{ObjectMeta: objectMeta},
+		want: []string{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractCredentialReferences(tt.obj))
+		})
+	}
+}
@@ -584,12 +584,17 @@ func (h *ProjectPipelineHandler) GetBranchRunLog(req *restful.Request, resp *res
 	branchName := req.PathParameter("branch")
 	runId := req.PathParameter("run")
 
-	res, err := h.devopsOperator.GetBranchRunLog(projectName, pipelineName, branchName, runId, req.Request)
+	res, header, err := h.devopsOperator.GetBranchRunLog(projectName, pipelineName, branchName, runId, req.Request)
 	if err != nil {
 		parseErr(err, resp)
 		return
 	}
 
+	for k, v := range header {
+		if strings.HasPrefix(k, jenkinsHeaderPre) {
+			resp.AddHeader(k, v[0])
+		}
+	}
 	resp.Write(res)
 }
 
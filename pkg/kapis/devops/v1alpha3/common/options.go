@@ -17,12 +17,15 @@ package common
 
 import (
 	"github.com/emicklei/go-restful"
+	"kubesphere.io/devops/pkg/credential"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Options contain options needed by creating handlers.
 type Options struct {
 	GenericClient client.Client
+	// CredentialResolver resolves the data of the credentials stored in the external secret backends
+	CredentialResolver *credential.Resolver
 }
 
 var (
@@ -17,6 +17,10 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/emicklei/go-restful"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -30,17 +34,20 @@ import (
 	"kubesphere.io/devops/pkg/models/devops"
 	servererr "kubesphere.io/devops/pkg/server/errors"
 	"kubesphere.io/devops/pkg/server/params"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type devopsHandler struct {
-	k8sClient    k8s.Client
-	devopsClient devopsClient.Interface
+	k8sClient     k8s.Client
+	devopsClient  devopsClient.Interface
+	genericClient client.Client
 }
 
-func newDevOpsHandler(devopsClient devopsClient.Interface, k8sClient k8s.Client) *devopsHandler {
+func newDevOpsHandler(devopsClient devopsClient.Interface, k8sClient k8s.Client, genericClient client.Client) *devopsHandler {
 	return &devopsHandler{
-		k8sClient:    k8sClient,
-		devopsClient: devopsClient,
+		k8sClient:     k8sClient,
+		devopsClient:  devopsClient,
+		genericClient: genericClient,
 	}
 }
 
@@ -338,6 +345,28 @@ func (h *devopsHandler) UpdateCredential(request *restful.Request, response *res
 	}
 }
 
+// RotateCredential merges the new data into a credential, and synchronizes it to Jenkins again
+func (h *devopsHandler) RotateCredential(request *restful.Request, response *restful.Response) {
+	devops := request.PathParameter("devops")
+	credential := request.PathParameter("credential")
+	// the body is omitted for the external credentials, they are only synchronized again
+	obj := &v1.Secret{}
+	if request.Request.ContentLength != 0 {
+		if err := request.ReadEntity(obj); err != nil {
+			klog.Error(err)
+			kapis.HandleBadRequest(response, request, err)
+			return
+		}
+	}
+
+	if client, err := h.getDevOps(request); err == nil {
+		rotated, err := client.RotateCredentialObj(devops, credential, obj)
+		errorHandle(request, response, rotated, err)
+	} else {
+		kapis.HandleBadRequest(response, request, err)
+	}
+}
+
 func errorHandle(request *restful.Request, response *restful.Response, obj interface{}, err error) {
 	if obj == nil {
 		obj = servererr.None
@@ -349,17 +378,50 @@ func errorHandle(request *restful.Request, response *restful.Response, obj inter
 			kapis.HandleNotFound(response, request, err)
 			return
 		}
+		if errors.IsConflict(err) {
+			kapis.HandleConflict(response, request, err)
+			return
+		}
 		kapis.HandleBadRequest(response, request, err)
 		return
 	}
 	_ = response.WriteEntity(obj)
 }
 
+// GetCredentialUsage returns the objects which reference the credential
+func (h *devopsHandler) GetCredentialUsage(request *restful.Request, response *restful.Response) {
+	devopsProject := request.PathParameter("devops")
+	credential := request.PathParameter("credential")
+
+	client, err := h.getDevOps(request)
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	// make sure the current user has the permission to access the credential
+	obj, err := client.GetCredentialObj(devopsProject, credential)
+	if err != nil {
+		errorHandle(request, response, nil, err)
+		return
+	}
+
+	usages, err := devops.GetCredentialUsages(request.Request.Context(), h.genericClient, obj.Namespace, obj.Name)
+	errorHandle(request, response, usages, err)
+}
+
+// DeleteCredential deletes the credential, it's not allowed to delete a credential in use unless it's forced
 func (h *devopsHandler) DeleteCredential(request *restful.Request, response *restful.Response) {
 	devopsProject := request.PathParameter("devops")
 	credential := request.PathParameter("credential")
+	force := request.QueryParameter("force") == "true"
 
 	if client, err := h.getDevOps(request); err == nil {
+		if !force {
+			if err = h.checkCredentialNotInUse(client, devopsProject, credential); err != nil {
+				errorHandle(request, response, nil, err)
+				return
+			}
+		}
 		err := client.DeleteCredentialObj(devopsProject, credential)
 		errorHandle(request, response, servererr.None, err)
 	} else {
@@ -367,6 +429,28 @@ func (h *devopsHandler) DeleteCredential(request *restful.Request, response *res
 	}
 }
 
+// checkCredentialNotInUse returns a conflict error if the credential is referenced by any objects
+func (h *devopsHandler) checkCredentialNotInUse(client devops.DevopsOperator, devopsProject, credential string) error {
+	obj, err := client.GetCredentialObj(devopsProject, credential)
+	if err != nil {
+		return err
+	}
+	usages, err := devops.GetCredentialUsages(context.Background(), h.genericClient, obj.Namespace, obj.Name)
+	if err != nil {
+		return err
+	}
+	if len(usages) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(usages))
+	for _, usage := range usages {
+		names = append(names, fmt.Sprintf("%s %s/%s", usage.Kind, usage.Namespace, usage.Name))
+	}
+	return errors.NewConflict(v1.Resource("secrets"), credential,
+		fmt.Errorf("it is still used by %s, delete it with force=true if you are sure",
+			strings.Join(names, ", ")))
+}
+
 func (h *devopsHandler) getJenkinsLabels(request *restful.Request, response *restful.Response) {
 	client, err := h.getDevOps(request)
 	if err != nil {
@@ -24,6 +24,7 @@ import (
 	"io"
 	"k8s.io/apimachinery/pkg/types"
 	cmstore "kubesphere.io/devops/pkg/store/configmap"
+	"kubesphere.io/devops/pkg/store/store"
 	"net/url"
 	"strconv"
 
@@ -45,6 +46,8 @@ import (
 type apiHandlerOption struct {
 	devopsClient devopsClient.Interface
 	client       client.Client
+	// dataStore is the PipelineRun data store, use the ConfigMap one if it's nil
+	dataStore store.Backend
 }
 
 // apiHandler contains functions to handle coming request and give a response.
@@ -57,6 +60,13 @@ func newAPIHandler(o apiHandlerOption) *apiHandler {
 	return &apiHandler{o}
 }
 
+func (h *apiHandler) getDataStore() store.Backend {
+	if h.dataStore == nil {
+		return cmstore.NewBackend(h.client)
+	}
+	return h.dataStore
+}
+
 func (h *apiHandler) listPipelineRuns(request *restful.Request, response *restful.Response) {
 	nsName := request.PathParameter("namespace")
 	pipName := request.PathParameter("pipeline")
@@ -180,10 +190,10 @@ func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.
 	// get stage status
 	stagesJSON, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey]
 	if !ok {
-		if pipelineRunStore, err := cmstore.NewConfigMapStore(ctx, types.NamespacedName{
+		if pipelineRunStore, err := h.getDataStore().Open(ctx, types.NamespacedName{
 			Namespace: namespaceName,
 			Name:      pipelineRunName,
-		}, h.client); err != nil {
+		}); err != nil {
 			// If the stages status does not exist, set it as an empty array
 			stagesJSON = "[]"
 		} else {
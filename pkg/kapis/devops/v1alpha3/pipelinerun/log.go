@@ -0,0 +1,328 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/devops"
+	"kubesphere.io/devops/pkg/kapis"
+)
+
+const (
+	// headerMoreData indicates whether Jenkins is still writing the log
+	headerMoreData = "X-More-Data"
+	// headerTextSize is the offset of the log that Jenkins has sent
+	headerTextSize = "X-Text-Size"
+
+	mimeEventStream = "text/event-stream"
+	mimeTextPlain   = "text/plain"
+)
+
+// logPollInterval is the interval to fetch the log from Jenkins again
+var logPollInterval = 2 * time.Second
+
+// logFetcher fetches the log from the given offset
+type logFetcher func(start int64) (data []byte, header http.Header, err error)
+
+// logWriter writes a piece of log into the response
+type logWriter interface {
+	// writeLog writes a piece of the log
+	writeLog(data []byte) error
+	// writeEnd tells the client there's no more log
+	writeEnd() error
+	// writeError tells the client it fails to fetch the log, it never mixes the error into the log
+	writeError(err error) error
+}
+
+// streamRunLog streams the whole log of a PipelineRun
+func (h *apiHandler) streamRunLog(request *restful.Request, response *restful.Response) {
+	h.streamLog(request, response, func(pr *v1alpha3.PipelineRun, buildID string) logFetcher {
+		projectName, pipelineName := pr.Namespace, pr.Labels[v1alpha3.PipelineNameLabelKey]
+		if pr.Spec.IsMultiBranchPipeline() {
+			branchName := pr.GetRefName()
+			return func(start int64) ([]byte, http.Header, error) {
+				return h.devopsClient.GetBranchRunLog(projectName, pipelineName, branchName, buildID, newLogParameters(start))
+			}
+		}
+		return func(start int64) ([]byte, http.Header, error) {
+			return h.devopsClient.GetRunLog(projectName, pipelineName, buildID, newLogParameters(start))
+		}
+	})
+}
+
+// streamStepLog streams the log of a step of a PipelineRun
+func (h *apiHandler) streamStepLog(request *restful.Request, response *restful.Response) {
+	nodeID := request.PathParameter("node")
+	stepID := request.PathParameter("step")
+
+	h.streamLog(request, response, func(pr *v1alpha3.PipelineRun, buildID string) logFetcher {
+		projectName, pipelineName := pr.Namespace, pr.Labels[v1alpha3.PipelineNameLabelKey]
+		if pr.Spec.IsMultiBranchPipeline() {
+			branchName := pr.GetRefName()
+			return func(start int64) ([]byte, http.Header, error) {
+				return h.devopsClient.GetBranchStepLog(projectName, pipelineName, branchName, buildID, nodeID, stepID,
+					newLogParameters(start))
+			}
+		}
+		return func(start int64) ([]byte, http.Header, error) {
+			return h.devopsClient.GetStepLog(projectName, pipelineName, buildID, nodeID, stepID, newLogParameters(start))
+		}
+	})
+}
+
+// streamLog keeps sending the log to the client until Jenkins has no more data or the client goes away
+func (h *apiHandler) streamLog(request *restful.Request, response *restful.Response,
+	newFetcher func(pr *v1alpha3.PipelineRun, buildID string) logFetcher) {
+	namespaceName := request.PathParameter("namespace")
+	pipelineRunName := request.PathParameter("pipelinerun")
+	ctx := request.Request.Context()
+
+	start, err := parseInt64(request.QueryParameter("start"), 0)
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	follow, err := parseBool(request.QueryParameter("follow"), true)
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	// the run ID is only available after the PipelineRun was triggered in Jenkins
+	pr, buildID, err := h.waitForBuildID(ctx, client.ObjectKey{Namespace: namespaceName, Name: pipelineRunName}, follow)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	fetch := newFetcher(pr, buildID)
+
+	writer := newLogWriter(request, response)
+	for {
+		data, header, err := fetch(start)
+		if err != nil {
+			klog.V(4).Infof("failed to fetch the log of PipelineRun %s/%s, error: %v", namespaceName, pipelineRunName, err)
+			_ = writer.writeError(err)
+			return
+		}
+
+		if len(data) > 0 {
+			if err = writer.writeLog(data); err != nil {
+				return
+			}
+		}
+		start = nextLogOffset(start, data, header)
+
+		if !follow || header.Get(headerMoreData) != "true" {
+			_ = writer.writeEnd()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// waitForBuildID returns the Jenkins run ID of the PipelineRun, it waits for the ID if the follow is true
+func (h *apiHandler) waitForBuildID(ctx context.Context, key client.ObjectKey, follow bool) (
+	pr *v1alpha3.PipelineRun, buildID string, err error) {
+	for {
+		pr = &v1alpha3.PipelineRun{}
+		if err = h.client.Get(ctx, key, pr); err != nil {
+			return
+		}
+		// the PipelineRuns on the Kubernetes engine have no log in Jenkins, their logs are the ones of the step containers
+		if pr.Annotations[v1alpha3.PipelineEngineAnnoKey] == v1alpha3.PipelineEngineKubernetes {
+			err = apierrors.NewBadRequest(fmt.Sprintf("PipelineRun %s/%s runs on the Kubernetes engine, "+
+				"please read the logs of its Pods instead", key.Namespace, key.Name))
+			return
+		}
+
+		var exists bool
+		if buildID, exists = pr.GetPipelineRunID(); exists {
+			return
+		}
+		if !follow || pr.HasCompleted() {
+			err = fmt.Errorf("unable to get the log of PipelineRun %s/%s due to not found run ID", key.Namespace, key.Name)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// nextLogOffset returns the offset of the next request, X-Text-Size is preferred
+func nextLogOffset(start int64, data []byte, header http.Header) int64 {
+	if textSize, err := strconv.ParseInt(header.Get(headerTextSize), 10, 64); err == nil {
+		return textSize
+	}
+	return start + int64(len(data))
+}
+
+func newLogParameters(start int64) *devops.HttpParameters {
+	return &devops.HttpParameters{
+		Method: http.MethodGet,
+		Header: http.Header{},
+		Url: &url.URL{
+			RawQuery: url.Values{"start": []string{strconv.FormatInt(start, 10)}}.Encode(),
+		},
+	}
+}
+
+func newLogWriter(request *restful.Request, response *restful.Response) logWriter {
+	if strings.Contains(request.HeaderParameter("Accept"), mimeEventStream) {
+		response.AddHeader("Content-Type", mimeEventStream)
+		response.AddHeader("Cache-Control", "no-cache")
+		response.AddHeader("Connection", "keep-alive")
+		return &eventStreamWriter{response: response}
+	}
+	response.AddHeader("Content-Type", mimeTextPlain+"; charset=utf-8")
+	return &chunkedWriter{response: response}
+}
+
+// chunkedWriter sends the log as a chunked plain text
+type chunkedWriter struct {
+	response *restful.Response
+	written  bool
+}
+
+func (w *chunkedWriter) writeLog(data []byte) (err error) {
+	if _, err = w.response.Write(data); err == nil {
+		w.written = true
+		w.response.Flush()
+	}
+	return
+}
+
+func (w *chunkedWriter) writeEnd() error {
+	if !w.written {
+		// make sure the client receives the headers even if there's no log
+		w.response.WriteHeader(http.StatusOK)
+	}
+	return nil
+}
+
+// writeError responds the error if there's no log sent yet, otherwise it aborts the response. So the client finds out
+// the log is incomplete from the broken chunked encoding, instead of taking the error message as a part of the log.
+func (w *chunkedWriter) writeError(err error) error {
+	if w.written {
+		panic(http.ErrAbortHandler)
+	}
+	w.response.WriteHeader(http.StatusInternalServerError)
+	_, err = w.response.Write([]byte(err.Error()))
+	return err
+}
+
+// eventStreamWriter sends the log as Server-Sent Events, each line of the log is a data field
+type eventStreamWriter struct {
+	response *restful.Response
+	// unfinished is the trailing line without the line break, it's sent along with the next piece of the log
+	unfinished []byte
+}
+
+// writeLog sends the complete lines only, so a line split across the pieces of the log is sent as a whole
+func (w *eventStreamWriter) writeLog(data []byte) error {
+	data = append(w.unfinished, data...)
+	index := bytes.LastIndexByte(data, '\n')
+	w.unfinished = append([]byte(nil), data[index+1:]...)
+	if index < 0 {
+		return nil
+	}
+	return w.writeEvent("log", data[:index+1])
+}
+
+func (w *eventStreamWriter) writeEnd() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.writeEvent("end", nil)
+}
+
+// writeError sends the error as an event of the type error, which is distinguished from the log events
+func (w *eventStreamWriter) writeError(err error) error {
+	if flushErr := w.flush(); flushErr != nil {
+		return flushErr
+	}
+	return w.writeEvent("error", []byte(err.Error()))
+}
+
+// flush sends the unfinished line if there is
+func (w *eventStreamWriter) flush() error {
+	if len(w.unfinished) == 0 {
+		return nil
+	}
+	data := w.unfinished
+	w.unfinished = nil
+	return w.writeEvent("log", data)
+}
+
+func (w *eventStreamWriter) writeEvent(event string, data []byte) (err error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("event: " + event + "\n")
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), len(data)+1)
+	for scanner.Scan() {
+		buf.WriteString("data: ")
+		buf.Write(scanner.Bytes())
+		buf.WriteString("\n")
+	}
+	if len(data) == 0 {
+		buf.WriteString("data: \n")
+	}
+	buf.WriteString("\n")
+
+	if _, err = w.response.Write(buf.Bytes()); err == nil {
+		w.response.Flush()
+	}
+	return
+}
+
+func parseInt64(value string, defaultValue int64) (int64, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func parseBool(value string, defaultValue bool) (bool, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/devops"
+	fakedevops "kubesphere.io/devops/pkg/client/devops/fake"
+)
+
+type logResponse struct {
+	data     string
+	moreData bool
+	err      error
+}
+
+// fakeLogDevops returns the log pieces one by one
+type fakeLogDevops struct {
+	*fakedevops.Devops
+	responses []logResponse
+	paths     []string
+	starts    []string
+}
+
+func (d *fakeLogDevops) next(path string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	start := httpParameters.Url.Query().Get("start")
+	d.paths = append(d.paths, path)
+	d.starts = append(d.starts, start)
+
+	res := d.responses[0]
+	d.responses = d.responses[1:]
+	if res.err != nil {
+		return nil, nil, res.err
+	}
+	textSize, _ := strconv.Atoi(start)
+	return []byte(res.data), http.Header{
+		headerMoreData: []string{strconv.FormatBool(res.moreData)},
+		headerTextSize: []string{strconv.Itoa(textSize + len(res.data))},
+	}, nil
+}
+
+func (d *fakeLogDevops) GetRunLog(projectName, pipelineName, runID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return d.next(projectName+"/"+pipelineName+"/"+runID, httpParameters)
+}
+
+func (d *fakeLogDevops) GetStepLog(projectName, pipelineName, runID, nodeID, stepID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return d.next(projectName+"/"+pipelineName+"/"+runID+"/"+nodeID+"/"+stepID, httpParameters)
+}
+
+func (d *fakeLogDevops) GetBranchRunLog(projectName, pipelineName, branchName, runID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return d.next(projectName+"/"+pipelineName+"/"+branchName+"/"+runID, httpParameters)
+}
+
+func (d *fakeLogDevops) GetBranchStepLog(projectName, pipelineName, branchName, runID, nodeID, stepID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return d.next(projectName+"/"+pipelineName+"/"+branchName+"/"+runID+"/"+nodeID+"/"+stepID, httpParameters)
+}
+
+func TestStreamLog(t *testing.T) {
+	logPollInterval = time.Millisecond
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(runID string, multiBranch bool) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "pr",
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+			},
+		}
+		if runID != "" {
+			pr.Annotations = map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: runID}
+		}
+		if multiBranch {
+			pr.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+			pr.Spec.SCM = &v1alpha3.SCM{RefName: "main"}
+		}
+		return pr
+	}
+
+	tests := []struct {
+		name        string
+		pipelineRun *v1alpha3.PipelineRun
+		uri         string
+		accept      string
+		responses   []logResponse
+		wantCode    int
+		wantAbort   bool
+		wantBody    string
+		wantPaths   []string
+		wantStarts  []string
+	}{{
+		name:        "follow the run log until there's no more data",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log",
+		responses:   []logResponse{{data: "a\n", moreData: true}, {moreData: true}, {data: "b\n"}},
+		wantCode:    http.StatusOK,
+		wantBody:    "a\nb\n",
+		wantPaths:   []string{"ns/pipeline/1", "ns/pipeline/1", "ns/pipeline/1"},
+		wantStarts:  []string{"0", "2", "2"},
+	}, {
+		name:        "do not follow the run log",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log?follow=false&start=3",
+		responses:   []logResponse{{data: "a\n", moreData: true}},
+		wantCode:    http.StatusOK,
+		wantBody:    "a\n",
+		wantPaths:   []string{"ns/pipeline/1"},
+		wantStarts:  []string{"3"},
+	}, {
+		name:        "run log of a multi-branch PipelineRun",
+		pipelineRun: newPipelineRun("1", true),
+		uri:         "/namespaces/ns/pipelineruns/pr/log",
+		responses:   []logResponse{{data: "a\n"}},
+		wantCode:    http.StatusOK,
+		wantBody:    "a\n",
+		wantPaths:   []string{"ns/pipeline/main/1"},
+		wantStarts:  []string{"0"},
+	}, {
+		name:        "step log as Server-Sent Events",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/nodes/2/steps/3/log",
+		accept:      mimeEventStream,
+		responses:   []logResponse{{data: "a\nb\n", moreData: true}, {data: "c"}},
+		wantCode:    http.StatusOK,
+		wantBody: "event: log\ndata: a\ndata: b\n\n" +
+			"event: log\ndata: c\n\n" +
+			"event: end\ndata: \n\n",
+		wantPaths:  []string{"ns/pipeline/1/2/3", "ns/pipeline/1/2/3"},
+		wantStarts: []string{"0", "4"},
+	}, {
+		name:        "a line split across the pieces of the log as Server-Sent Events",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log",
+		accept:      mimeEventStream,
+		responses:   []logResponse{{data: "a\nb", moreData: true}, {data: "c", moreData: true}, {data: "d\ne"}},
+		wantCode:    http.StatusOK,
+		wantBody: "event: log\ndata: a\n\n" +
+			"event: log\ndata: bcd\n\n" +
+			"event: log\ndata: e\n\n" +
+			"event: end\ndata: \n\n",
+		wantPaths:  []string{"ns/pipeline/1", "ns/pipeline/1", "ns/pipeline/1"},
+		wantStarts: []string{"0", "3", "4"},
+	}, {
+		name:        "send the unfinished line before the error event",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log",
+		accept:      mimeEventStream,
+		responses:   []logResponse{{data: "a", moreData: true}, {err: errors.New("jenkins is down")}},
+		wantCode:    http.StatusOK,
+		wantBody: "event: log\ndata: a\n\n" +
+			"event: error\ndata: jenkins is down\n\n",
+		wantPaths:  []string{"ns/pipeline/1", "ns/pipeline/1"},
+		wantStarts: []string{"0", "1"},
+	}, {
+		name:        "step log of a multi-branch PipelineRun",
+		pipelineRun: newPipelineRun("1", true),
+		uri:         "/namespaces/ns/pipelineruns/pr/nodes/2/steps/3/log",
+		responses:   []logResponse{{data: "a"}},
+		wantCode:    http.StatusOK,
+		wantBody:    "a",
+		wantPaths:   []string{"ns/pipeline/main/1/2/3"},
+		wantStarts:  []string{"0"},
+	}, {
+		name:        "failed to fetch the log at the beginning",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log",
+		responses:   []logResponse{{err: errors.New("jenkins is down")}},
+		wantCode:    http.StatusInternalServerError,
+	}, {
+		name:        "abort the log stream if it failed to fetch the log",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log",
+		responses:   []logResponse{{data: "a\n", moreData: true}, {err: errors.New("jenkins is down")}},
+		wantAbort:   true,
+	}, {
+		name:        "send an error event if it failed to fetch the log",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log",
+		accept:      mimeEventStream,
+		responses:   []logResponse{{data: "a\n", moreData: true}, {err: errors.New("jenkins is down")}},
+		wantCode:    http.StatusOK,
+		wantBody: "event: log\ndata: a\n\n" +
+			"event: error\ndata: jenkins is down\n\n",
+		wantPaths:  []string{"ns/pipeline/1", "ns/pipeline/1"},
+		wantStarts: []string{"0", "2"},
+	}, {
+		name:        "no run ID without following",
+		pipelineRun: newPipelineRun("", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log?follow=false",
+		wantCode:    http.StatusInternalServerError,
+	}, {
+		name:        "invalid start",
+		pipelineRun: newPipelineRun("1", false),
+		uri:         "/namespaces/ns/pipelineruns/pr/log?start=a",
+		wantCode:    http.StatusBadRequest,
+	}, {
+		name: "PipelineRun on the Kubernetes engine",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun("1", false)
+			pr.Annotations[v1alpha3.PipelineEngineAnnoKey] = v1alpha3.PipelineEngineKubernetes
+			return pr
+		}(),
+		uri:      "/namespaces/ns/pipelineruns/pr/log",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "PipelineRun not found",
+		uri:      "/namespaces/ns/pipelineruns/pr/log",
+		wantCode: http.StatusNotFound,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientBuilder := fake.NewClientBuilder().WithScheme(schema)
+			if tt.pipelineRun != nil {
+				clientBuilder.WithObjects(tt.pipelineRun)
+			}
+			devopsClient := &fakeLogDevops{Devops: fakedevops.NewFakeDevops(nil), responses: tt.responses}
+
+			container := restful.NewContainer()
+			ws := new(restful.WebService)
+			RegisterRoutes(ws, devopsClient, clientBuilder.Build(), nil)
+			container.Add(ws)
+
+			httpRequest := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+			if tt.accept != "" {
+				httpRequest.Header.Set("Accept", tt.accept)
+			}
+			recorder := httptest.NewRecorder()
+			if tt.wantAbort {
+				assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+					container.Dispatch(recorder, httpRequest)
+				})
+				return
+			}
+			container.Dispatch(recorder, httpRequest)
+
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantBody, recorder.Body.String())
+				assert.Equal(t, tt.wantPaths, devopsClient.paths)
+				assert.Equal(t, tt.wantStarts, devopsClient.starts)
+			}
+		})
+	}
+}
@@ -29,14 +29,16 @@ import (
 	"kubesphere.io/devops/pkg/api"
 	"kubesphere.io/devops/pkg/client/devops"
 	devopsClient "kubesphere.io/devops/pkg/client/devops"
+	"kubesphere.io/devops/pkg/store/store"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // RegisterRoutes register routes into web service.
-func RegisterRoutes(ws *restful.WebService, devopsClient devopsClient.Interface, c client.Client) {
+func RegisterRoutes(ws *restful.WebService, devopsClient devopsClient.Interface, c client.Client, dataStore store.Backend) {
 	handler := newAPIHandler(apiHandlerOption{
 		devopsClient: devopsClient,
 		client:       c,
+		dataStore:    dataStore,
 	})
 
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/pipelineruns").
@@ -75,6 +77,32 @@ func RegisterRoutes(ws *restful.WebService, devopsClient devopsClient.Interface,
 		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
 		Returns(http.StatusOK, api.StatusOK, []pipelinerun.NodeDetail{}))
 
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/log").
+		To(handler.streamRunLog).
+		Doc("Stream the whole log of a PipelineRun as chunked text, or Server-Sent Events if the Accept header is text/event-stream").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.QueryParameter("start", "The offset of the log to start from").DataType("integer").DefaultValue("0")).
+		Param(ws.QueryParameter("follow", "Keep streaming the log until the PipelineRun is completed").
+			DataType("bool").DefaultValue("true")).
+		Produces(mimeTextPlain, mimeEventStream).
+		Returns(http.StatusOK, api.StatusOK, nil).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/nodes/{node}/steps/{step}/log").
+		To(handler.streamStepLog).
+		Doc("Stream the log of a step as chunked text, or Server-Sent Events if the Accept header is text/event-stream").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.PathParameter("node", "ID of the node in Jenkins")).
+		Param(ws.PathParameter("step", "ID of the step in Jenkins")).
+		Param(ws.QueryParameter("start", "The offset of the log to start from").DataType("integer").DefaultValue("0")).
+		Param(ws.QueryParameter("follow", "Keep streaming the log until the step is completed").
+			DataType("bool").DefaultValue("true")).
+		Produces(mimeTextPlain, mimeEventStream).
+		Returns(http.StatusOK, api.StatusOK, nil).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
 	// download PipelineRun artifact
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/artifacts/download").
 		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
@@ -30,12 +30,15 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/client/k8s"
+	"kubesphere.io/devops/pkg/credential"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/common"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipeline"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipelinerun"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/scm"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/template"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/webhook"
+	"kubesphere.io/devops/pkg/models/devops"
+	"kubesphere.io/devops/pkg/store/store"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"kubesphere.io/devops/pkg/api"
@@ -56,7 +59,8 @@ var GroupVersion = schema.GroupVersion{Group: api.GroupName, Version: "v1alpha3"
 
 // AddToContainer adds web service into container.
 func AddToContainer(container *restful.Container, devopsClient devopsClient.Interface, k8sClient k8s.Client,
-	client client.Client, tokenIssue token.Issuer, jenkins core.JenkinsCore) (wss []*restful.WebService) {
+	client client.Client, tokenIssue token.Issuer, jenkins core.JenkinsCore, dataStore store.Backend,
+	credentialResolver *credential.Resolver) (wss []*restful.WebService) {
 
 	services := []*restful.WebService{
 		runtime.NewWebService(v1alpha3.GroupVersion),
@@ -65,13 +69,14 @@ func AddToContainer(container *restful.Container, devopsClient devopsClient.Inte
 
 	for _, service := range services {
 		registerRoutes(devopsClient, k8sClient, client, service)
-		pipelinerun.RegisterRoutes(service, devopsClient, client)
+		pipelinerun.RegisterRoutes(service, devopsClient, client, dataStore)
 		pipeline.RegisterRoutes(service, client)
 		template.RegisterRoutes(service, &common.Options{
 			GenericClient: client,
 		})
 		steptemplate.RegisterRoutes(service, &common.Options{
-			GenericClient: client,
+			GenericClient:      client,
+			CredentialResolver: credentialResolver,
 		})
 		webhook.RegisterWebhooks(client, service, tokenIssue, jenkins)
 		container.Add(service)
@@ -80,7 +85,7 @@ func AddToContainer(container *restful.Container, devopsClient devopsClient.Inte
 }
 
 func registerRoutes(devopsClient devopsClient.Interface, k8sClient k8s.Client, client client.Client, ws *restful.WebService) {
-	handler := newDevOpsHandler(devopsClient, k8sClient)
+	handler := newDevOpsHandler(devopsClient, k8sClient, client)
 	registerRoutersForCredentials(handler, ws)
 	registerRoutersForPipelines(handler, ws)
 	registerRoutersForWorkspace(handler, ws)
@@ -126,11 +131,32 @@ func registerRoutersForCredentials(handler *devopsHandler, ws *restful.WebServic
 		Returns(http.StatusOK, api.StatusOK, v1.Secret{}).
 		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsProjectTag}))
 
+	ws.Route(ws.POST("/devops/{devops}/credentials/{credential}/rotate").
+		To(handler.RotateCredential).
+		Param(ws.PathParameter("devops", "project name")).
+		Param(ws.PathParameter("credential", "credential name")).
+		Reads(v1.Secret{}).
+		Doc("rotate the credential of the specified devops by merging the data and stringData of the body, "+
+			"then synchronize it to Jenkins again. The body is omitted for the external credentials").
+		Returns(http.StatusOK, api.StatusOK, v1.Secret{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsProjectTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/credentials/{credential}/usage").
+		To(handler.GetCredentialUsage).
+		Param(ws.PathParameter("devops", "project name")).
+		Param(ws.PathParameter("credential", "credential name")).
+		Doc("get the Pipelines, GitRepositories, ImageUpdaters and ChartRepositories which reference the credential").
+		Returns(http.StatusOK, api.StatusOK, []devops.CredentialUsage{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsProjectTag}))
+
 	ws.Route(ws.DELETE("/devops/{devops}/credentials/{credential}").
 		To(handler.DeleteCredential).
 		Param(ws.PathParameter("devops", "project name")).
 		Param(ws.PathParameter("credential", "credential name")).
-		Doc("delete the credential of the specified devops for the current user").
+		Param(ws.QueryParameter("force", "delete the credential even if it is still in use").
+			Required(false).DataType("bool").DefaultValue("false")).
+		Doc("delete the credential of the specified devops for the current user, "+
+			"it's not allowed to delete a credential in use unless it's forced").
 		Returns(http.StatusOK, api.StatusOK, v1.Secret{}).
 		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
 }
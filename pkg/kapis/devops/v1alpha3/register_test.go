@@ -32,6 +32,7 @@ import (
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha1"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	gitopsv1alpha1 "kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	fakeclientset "kubesphere.io/devops/pkg/client/clientset/versioned/fake"
 	fakedevops "kubesphere.io/devops/pkg/client/devops/fake"
 	"kubesphere.io/devops/pkg/client/k8s"
@@ -45,12 +46,22 @@ func TestAPIsExist(t *testing.T) {
 
 	err = v1.SchemeBuilder.AddToScheme(schema)
 	assert.Nil(t, err)
+	assert.Nil(t, v1alpha3.AddToScheme(schema))
+	assert.Nil(t, gitopsv1alpha1.AddToScheme(schema))
 
 	container := restful.NewContainer()
 	AddToContainer(container, fakedevops.NewFakeDevops(nil), k8s.NewFakeClientSets(k8sfake.NewSimpleClientset(&v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "fake", Namespace: "fake",
 		},
+	}, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "in-use", Namespace: "fake",
+		},
+	}, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "step-template-default", Namespace: "fake",
+		},
 	}), nil, nil, "", nil,
 		fakeclientset.NewSimpleClientset(&v1alpha3.DevOpsProject{
 			ObjectMeta: metav1.ObjectMeta{Name: "fake"},
@@ -61,7 +72,14 @@ func TestAPIsExist(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "fake", Namespace: "fake",
 		},
-	}), &token.FakeIssuer{}, core.JenkinsCore{})
+	}, &v1alpha3.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake", Namespace: "fake"},
+		Spec:       v1alpha3.GitRepositorySpec{Secret: &v1.SecretReference{Name: "in-use"}},
+	}, &v1alpha3.ClusterStepTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake"},
+		Spec: v1alpha3.StepTemplateSpec{Secret: v1alpha3.SecretInStep{
+			Namespace: "fake", Name: "step-template-default"}},
+	}), &token.FakeIssuer{}, core.JenkinsCore{}, nil, nil)
 
 	type args struct {
 		method string
@@ -114,12 +132,82 @@ func TestAPIsExist(t *testing.T) {
 		},
 		body:       `{}`,
 		expectCode: 200,
+	}, {
+		name: "rotate a credential without new data",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/devops/fake/credentials/fake/rotate",
+		},
+		expectCode: 400,
+	}, {
+		name: "rotate a credential with body",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/devops/fake/credentials/fake/rotate",
+		},
+		body: `{"stringData":{"password":"new"}}`,
+	}, {
+		name: "rotate a credential with an invalid body",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/devops/fake/credentials/fake/rotate",
+		},
+		body:       `invalid`,
+		expectCode: 400,
+	}, {
+		name: "rotate a non-existing credential",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/devops/fake/credentials/non-existing/rotate",
+		},
+		expectCode: 404,
+	}, {
+		name: "create a credential with an invalid expiry time",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/devops/fake/credentials",
+		},
+		body:       `{"metadata":{"annotations":{"credential.devops.kubesphere.io/expires-at":"tomorrow"}}}`,
+		expectCode: 400,
+	}, {
+		name: "get the usage of a credential",
+		args: args{
+			method: http.MethodGet,
+			uri:    "/devops/fake/credentials/in-use/usage",
+		},
+	}, {
+		name: "get the usage of a non-existing credential",
+		args: args{
+			method: http.MethodGet,
+			uri:    "/devops/fake/credentials/non-existing/usage",
+		},
+		expectCode: 404,
 	}, {
 		name: "delete a credential",
 		args: args{
 			method: http.MethodDelete,
 			uri:    "/devops/fake/credentials/fake",
 		},
+	}, {
+		name: "delete a credential in use",
+		args: args{
+			method: http.MethodDelete,
+			uri:    "/devops/fake/credentials/in-use",
+		},
+		expectCode: 409,
+	}, {
+		name: "delete a credential used by a step template only",
+		args: args{
+			method: http.MethodDelete,
+			uri:    "/devops/fake/credentials/step-template-default",
+		},
+		expectCode: 409,
+	}, {
+		name: "delete a credential in use forcibly",
+		args: args{
+			method: http.MethodDelete,
+			uri:    "/devops/fake/credentials/in-use?force=true",
+		},
 	}, {
 		name: "get pipeline list",
 		args: args{
@@ -271,7 +359,7 @@ func TestGetDevOpsProject(t *testing.T) {
 					constants.WorkspaceLabelKey: "ws",
 				},
 			},
-		})), fake.NewFakeClientWithScheme(schema), &token.FakeIssuer{}, core.JenkinsCore{})
+		})), fake.NewFakeClientWithScheme(schema), &token.FakeIssuer{}, core.JenkinsCore{}, nil, nil)
 
 	type args struct {
 		method string
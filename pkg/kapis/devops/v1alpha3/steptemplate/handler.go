@@ -23,6 +23,7 @@ import (
 
 	"github.com/emicklei/go-restful"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
@@ -75,6 +76,10 @@ func (h *handler) renderClusterStepTemplate(req *restful.Request, resp *restful.
 		// TODO considering have logger output instead of the std output
 		fmt.Printf("something goes wrong when getting secret, error: %v\n", err)
 	}
+	if defaultSecret := clusterStepTemplate.Spec.Secret; secret == nil && defaultSecret.Name != "" {
+		// only the name of the default credential is wrapped, its data is not exposed to the template
+		secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: defaultSecret.Namespace, Name: defaultSecret.Name}}
+	}
 
 	param := map[string]interface{}{}
 	// get the parameters from request
@@ -95,10 +100,18 @@ func (h *handler) getSecret(req *restful.Request) (secret *v1.Secret, err error)
 	secretNamespace := req.QueryParameter(SecretNamespaceQueryParameter.Data().Name)
 	if secretName != "" || secretNamespace != "" {
 		secret = &v1.Secret{}
-		err = h.Get(context.Background(), types.NamespacedName{
+		if err = h.Get(context.Background(), types.NamespacedName{
 			Namespace: secretNamespace,
 			Name:      secretName,
-		}, secret)
+		}, secret); err != nil {
+			return
+		}
+
+		// the data of an external credential is not stored in the secret
+		var resolved *v1.Secret
+		if resolved, err = h.credentialResolver.Resolve(context.Background(), secret); err == nil {
+			secret = resolved
+		}
 	}
 	return
 }
@@ -18,12 +18,14 @@ package steptemplate
 
 import (
 	"github.com/emicklei/go-restful"
+	"kubesphere.io/devops/pkg/credential"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/common"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type handler struct {
 	client.Client
+	credentialResolver *credential.Resolver
 }
 
 var (
@@ -40,7 +42,7 @@ var (
 
 // RegisterRoutes registry the handlers of the stepTemplates
 func RegisterRoutes(service *restful.WebService, options *common.Options) {
-	h := &handler{options.GenericClient}
+	h := &handler{Client: options.GenericClient, credentialResolver: options.CredentialResolver}
 	service.Route(service.GET("/clustersteptemplates").
 		To(h.clusterStepTemplates).
 		Doc("Return the cluster level stepTemplate list"))
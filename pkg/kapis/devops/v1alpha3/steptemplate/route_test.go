@@ -28,6 +28,9 @@ import (
 	"kubesphere.io/devops/pkg/api"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	ksruntime "kubesphere.io/devops/pkg/apiserver/runtime"
+	"kubesphere.io/devops/pkg/client/vault"
+	fakevault "kubesphere.io/devops/pkg/client/vault/fake"
+	"kubesphere.io/devops/pkg/credential"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/common"
 	"net/http"
 	"net/http/httptest"
@@ -162,6 +165,45 @@ func TestAPIs(t *testing.T) {
 }`, string(bytes))
 		},
 		wantCode: http.StatusOK,
+	}, {
+		name: "render a clusterStepTemplate with the default credential",
+		args: args{
+			api:    "/clustersteptemplates/fake/render",
+			method: http.MethodPost,
+			getBody: func() io.Reader {
+				return bytes.NewBufferString(`{}`)
+			},
+		},
+		getInstances: func() []runtime.Object {
+			return []runtime.Object{&v1alpha3.ClusterStepTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "fake",
+				},
+				Spec: v1alpha3.StepTemplateSpec{
+					Secret: v1alpha3.SecretInStep{
+						Type:      string(v1alpha3.SecretTypeBasicAuth),
+						Wrap:      true,
+						Namespace: "ns",
+						Name:      "default",
+					},
+					Template: `echo {{.secret.Data.password}}`,
+				},
+			}, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ns",
+					Name:      "default",
+				},
+				Type: v1alpha3.SecretTypeBasicAuth,
+				Data: map[string][]byte{
+					v1.BasicAuthPasswordKey: []byte("password"),
+				},
+			}}
+		},
+		verify: func(bytes []byte, t *testing.T) {
+			assert.Contains(t, string(bytes), "credentialsId: 'default'")
+			assert.NotContains(t, string(bytes), "echo password")
+		},
+		wantCode: http.StatusOK,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -196,3 +238,44 @@ func TestAPIs(t *testing.T) {
 		})
 	}
 }
+
+func TestGetExternalSecret(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	server := fakevault.NewServer("token", "secret", 2)
+	defer server.Close()
+	server.Put("ns/devops/secret", map[string]interface{}{
+		v1.BasicAuthUsernameKey: "username",
+		v1.BasicAuthPasswordKey: "password",
+	})
+	resolver, err := credential.NewResolverFromOptions(&vault.Options{
+		Address: server.URL, Token: "token", Mount: "secret", KVVersion: 2})
+	assert.Nil(t, err)
+
+	h := &handler{
+		Client: fake.NewFakeClientWithScheme(schema, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "secret",
+				Annotations: map[string]string{
+					v1alpha3.CredentialSourceAnnoKey:     v1alpha3.CredentialSourceVault,
+					v1alpha3.CredentialSourcePathAnnoKey: "devops/secret",
+				},
+			},
+			Type: v1.SecretTypeBasicAuth,
+		}),
+		credentialResolver: resolver,
+	}
+	httpRequest, _ := http.NewRequest(http.MethodPost,
+		"http://fake.com/clustersteptemplates/fake/render?secret=secret&secretNamespace=ns", nil)
+
+	secret, err := h.getSecret(restful.NewRequest(httpRequest))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{
+		v1.BasicAuthUsernameKey: []byte("username"),
+		v1.BasicAuthPasswordKey: []byte("password"),
+	}, secret.Data)
+}
@@ -17,6 +17,8 @@ package template
 
 import (
 	"bytes"
+	"fmt"
+
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
@@ -55,6 +57,10 @@ func render(templateObject v1alpha3.TemplateObject, parameters []Parameter) (v1a
 		parameterMap[parameter.Name] = parameter.Value
 	}
 
+	if err := validateParameters(templateObject.TemplateSpec().Parameters, parameterMap); err != nil {
+		return nil, err
+	}
+
 	parametersData := map[string]map[string]interface{}{}
 	parametersData[parametersKey] = parameterMap
 
@@ -71,3 +77,27 @@ func render(templateObject v1alpha3.TemplateObject, parameters []Parameter) (v1a
 	templateObject.GetAnnotations()[devops.GroupName+devops.RenderResultAnnoKey] = renderResult
 	return templateObject, nil
 }
+
+// validateParameters evaluates the validation expressions against the values of the parameters. The expressions which
+// could not be compiled are skipped, they're written before the admission webhook checks them.
+func validateParameters(templateParameters []v1alpha3.TemplateParameter, values map[string]interface{}) error {
+	for _, param := range templateParameters {
+		value, ok := values[param.Name]
+		if !ok || param.Validation == nil {
+			continue
+		}
+		passed, err := v1alpha3.EvaluateValidationExpression(param.Validation.Expression, value)
+		if err != nil {
+			klog.Warningf("skipped the validation of parameter %s, error: %v", param.Name, err)
+			continue
+		}
+		if !passed {
+			message := param.Validation.Message
+			if message == "" {
+				message = fmt.Sprintf("it does not match the expression %q", param.Validation.Expression)
+			}
+			return errors.NewBadRequest(fmt.Sprintf("invalid parameter %s: %s", param.Name, message))
+		}
+	}
+	return nil
+}
@@ -35,6 +35,20 @@ func Test_render(t *testing.T) {
 			},
 		}
 	}
+	createValidatedTemplate := func(expression, message, template string) v1alpha3.TemplateObject {
+		return &v1alpha3.Template{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "fake-name",
+			},
+			Spec: v1alpha3.TemplateSpec{
+				Parameters: []v1alpha3.TemplateParameter{{
+					Name:       "url",
+					Validation: &v1alpha3.ParameterValidation{Expression: expression, Message: message},
+				}},
+				Template: template,
+			},
+		}
+	}
 	type args struct {
 		template   v1alpha3.TemplateObject
 		parameters []Parameter
@@ -123,6 +137,40 @@ func Test_render(t *testing.T) {
 			assert.Equal(t, "Valid", got)
 		},
 		wantErr: assert.NoError,
+	}, {
+		name: "Should render if the parameters pass the validation",
+		args: args{
+			template:   createValidatedTemplate("self.matches('^https?://')", "", "$(.params.url)"),
+			parameters: []Parameter{{Name: "url", Value: "https://kubesphere.io"}},
+		},
+		verify: func(t *testing.T, template v1alpha3.TemplateObject) {
+			got := template.GetAnnotations()[devops.GroupName+devops.RenderResultAnnoKey]
+			assert.Equal(t, "https://kubesphere.io", got)
+		},
+		wantErr: assert.NoError,
+	}, {
+		name: "Should return error if the parameters fail the validation",
+		args: args{
+			template:   createValidatedTemplate("self.matches('^https?://')", "url must be an HTTP address", "$(.params.url)"),
+			parameters: []Parameter{{Name: "url", Value: "ftp://kubesphere.io"}},
+		},
+		verify: func(t *testing.T, template v1alpha3.TemplateObject) {
+			assert.Nil(t, template)
+		},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			return assert.EqualError(t, err, "invalid parameter url: url must be an HTTP address", i...)
+		},
+	}, {
+		name: "Should skip the validation expression which could not be compiled",
+		args: args{
+			template:   createValidatedTemplate("^https?://", "", "$(.params.url)"),
+			parameters: []Parameter{{Name: "url", Value: "ftp://kubesphere.io"}},
+		},
+		verify: func(t *testing.T, template v1alpha3.TemplateObject) {
+			got := template.GetAnnotations()[devops.GroupName+devops.RenderResultAnnoKey]
+			assert.Equal(t, "ftp://kubesphere.io", got)
+		},
+		wantErr: assert.NoError,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -18,10 +18,14 @@ package webhook
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"io"
+	corev1 "k8s.io/api/core/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/util/retry"
@@ -190,6 +194,78 @@ func TestSCMWebhook(t *testing.T) {
 		scmAnnotationKey:    "https://gitlab.com/linuxsuren/test",
 	})
 
+	gitRepo := &v1alpha3.GitRepository{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: v1alpha3.GitRepositorySpec{
+			Provider: "gitlab",
+			URL:      "https://gitlab.com/linuxsuren/test",
+			Webhooks: []corev1.LocalObjectReference{{Name: "hook"}},
+		},
+	}
+	webhook := &v1alpha3.Webhook{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "hook",
+			Namespace: "default",
+		},
+		Spec: v1alpha3.WebhookSpec{
+			Secret: &corev1.SecretReference{Name: "hook-secret"},
+		},
+	}
+	webhookSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "hook-secret",
+			Namespace: "default",
+		},
+		Type: v1alpha3.SecretTypeSecretText,
+		Data: map[string][]byte{
+			v1alpha3.SecretTextSecretKey: []byte("token"),
+		},
+	}
+	githubPipeline := defaultPipeline.DeepCopy()
+	githubPipeline.Annotations[scmAnnotationKey] = "https://github.com/linuxsuren/test"
+	githubRepo := gitRepo.DeepCopy()
+	githubRepo.Spec.Provider = "github"
+	githubRepo.Spec.URL = "https://github.com/linuxsuren/test"
+	bitbucketPipeline := defaultPipeline.DeepCopy()
+	bitbucketPipeline.Annotations[scmAnnotationKey] = "https://bitbucket.org/linuxsuren/test"
+	bitbucketRepo := gitRepo.DeepCopy()
+	bitbucketRepo.Spec.Provider = "bitbucket_cloud"
+	bitbucketRepo.Spec.URL = "https://bitbucket.org/linuxsuren/test"
+	// the same repository in another namespace has a different secret
+	otherRepo, otherWebhook, otherSecret := gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()
+	otherRepo.Namespace, otherWebhook.Namespace, otherSecret.Namespace = "other", "other", "other"
+	otherSecret.Data[v1alpha3.SecretTextSecretKey] = []byte("another")
+	otherPipeline := defaultPipeline.DeepCopy()
+	otherPipeline.Namespace = "other"
+	unsignedPipeline := defaultPipeline.DeepCopy()
+	unsignedPipeline.Annotations[allowUnsignedAnnotationKey] = "true"
+	tagPipeline := githubPipeline.DeepCopy()
+	tagPipeline.Annotations[scmEventsAnnotationKey] = `["push", "tag"]`
+	githubTagWebhookBody := strings.Replace(githubWebhookBody, `"ref": "refs/heads/master"`, `"ref": "refs/tags/master"`, 1)
+
+	assertOK := func(t *testing.T, c client.Client, body string) {
+		assert.Equal(t, "ok", body)
+
+		pipelineRuns := &v1alpha3.PipelineRunList{}
+		assert.Nil(t, c.List(context.Background(), pipelineRuns))
+		assert.Equal(t, 1, len(pipelineRuns.Items))
+	}
+	assertRejected := func(t *testing.T, c client.Client, body string) {
+		events := &corev1.EventList{}
+		assert.Nil(t, c.List(context.Background(), events))
+		if assert.Equal(t, 1, len(events.Items)) {
+			assert.Equal(t, eventReasonWebhookRejected, events.Items[0].Reason)
+			assert.Equal(t, "test", events.Items[0].InvolvedObject.Name)
+		}
+
+		pipelineRuns := &v1alpha3.PipelineRunList{}
+		assert.Nil(t, c.List(context.Background(), pipelineRuns))
+		assert.Equal(t, 0, len(pipelineRuns.Items))
+	}
+
 	type args struct {
 		method     string
 		uri        string
@@ -200,6 +276,7 @@ func TestSCMWebhook(t *testing.T) {
 	tests := []struct {
 		name      string
 		args      args
+		wantCode  int
 		assertion func(t *testing.T, c client.Client, body string)
 	}{{
 		name: "unknown SCM webhook",
@@ -226,7 +303,7 @@ func TestSCMWebhook(t *testing.T) {
 			assert.Equal(t, "no pipeline matched", body)
 		},
 	}, {
-		name: "gitlab webhook",
+		name: "unsigned gitlab webhook without any secrets",
 		args: args{
 			method:     http.MethodPost,
 			uri:        "/webhooks/scm",
@@ -236,9 +313,213 @@ func TestSCMWebhook(t *testing.T) {
 				"X-Gitlab-Event": "Push Hook",
 			},
 		},
+		wantCode: http.StatusUnauthorized,
 		assertion: func(t *testing.T, c client.Client, body string) {
-			assert.Equal(t, "ok", body)
+			assert.Contains(t, body, "refused to trigger pipeline default/fake by an unsigned request")
+
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), pipelineRuns))
+			assert.Equal(t, 0, len(pipelineRuns.Items))
+		},
+	}, {
+		name: "unsigned gitlab webhook is allowed by the pipeline",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{unsignedPipeline},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		assertion: assertOK,
+	}, {
+		name: "gitlab webhook without the token while the secret is required",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		wantCode:  http.StatusUnauthorized,
+		assertion: assertRejected,
+	}, {
+		name: "gitlab webhook with an invalid token",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "invalid",
+			},
+		},
+		wantCode:  http.StatusUnauthorized,
+		assertion: assertRejected,
+	}, {
+		name: "gitlab webhook with a missing secret",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "token",
+			},
+		},
+		wantCode:  http.StatusUnauthorized,
+		assertion: assertRejected,
+	}, {
+		name: "gitlab webhook with a valid token",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "token",
+			},
+		},
+		assertion: assertOK,
+	}, {
+		name: "gitlab webhook with a valid token while another namespace has a different secret",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy(),
+				otherRepo, otherWebhook, otherSecret},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "token",
+			},
+		},
+		assertion: assertOK,
+	}, {
+		name: "the rejection in a namespace is not hidden by the other namespaces",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy(),
+				otherPipeline, otherRepo, otherWebhook, otherSecret},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "token",
+			},
 		},
+		wantCode: http.StatusUnauthorized,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			events := &corev1.EventList{}
+			assert.Nil(t, c.List(context.Background(), events, client.InNamespace("other")))
+			assert.Equal(t, 1, len(events.Items))
+
+			// the pipeline in the verified namespace is still triggered
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), pipelineRuns))
+			if assert.Equal(t, 1, len(pipelineRuns.Items)) {
+				assert.Equal(t, "default", pipelineRuns.Items[0].Namespace)
+			}
+		},
+	}, {
+		name: "github webhook with an invalid signature",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{githubPipeline.DeepCopy(), githubRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   githubWebhookBody,
+			header: map[string]string{
+				"X-GitHub-Event":    "push",
+				"X-GitHub-Delivery": "fake",
+				"X-Hub-Signature":   githubSignature(githubWebhookBody, "invalid"),
+			},
+		},
+		wantCode:  http.StatusUnauthorized,
+		assertion: assertRejected,
+	}, {
+		name: "github webhook with a valid signature",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{githubPipeline.DeepCopy(), githubRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   githubWebhookBody,
+			header: map[string]string{
+				"X-GitHub-Event":    "push",
+				"X-GitHub-Delivery": "fake",
+				"X-Hub-Signature":   githubSignature(githubWebhookBody, "token"),
+			},
+		},
+		assertion: assertOK,
+	}, {
+		name: "github tag push does not trigger the pipeline without the tag events",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{githubPipeline.DeepCopy(), githubRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   githubTagWebhookBody,
+			header: map[string]string{
+				"X-GitHub-Event":    "push",
+				"X-GitHub-Delivery": "fake",
+				"X-Hub-Signature":   githubSignature(githubTagWebhookBody, "token"),
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "no pipeline matched", body)
+		},
+	}, {
+		name: "github tag push triggers the pipeline with the tag events",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{tagPipeline, githubRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   githubTagWebhookBody,
+			header: map[string]string{
+				"X-GitHub-Event":    "push",
+				"X-GitHub-Delivery": "fake",
+				"X-Hub-Signature":   githubSignature(githubTagWebhookBody, "token"),
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assertOK(t, c, body)
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), pipelineRuns))
+			if assert.Equal(t, 1, len(pipelineRuns.Items)) {
+				assert.Equal(t, &v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: "master"}, pipelineRuns.Items[0].Spec.SCM)
+			}
+		},
+	}, {
+		name: "bitbucket webhook with an invalid secret",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm?secret=invalid",
+			initObject: []runtime.Object{bitbucketPipeline.DeepCopy(), bitbucketRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   bitbucketWebhookBody,
+			header: map[string]string{
+				"User-Agent":  "Bitbucket-Webhooks/2.0",
+				"X-Event-Key": "repo:push",
+			},
+		},
+		wantCode:  http.StatusUnauthorized,
+		assertion: assertRejected,
+	}, {
+		name: "bitbucket webhook with a valid secret",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm?secret=token",
+			initObject: []runtime.Object{bitbucketPipeline.DeepCopy(), bitbucketRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   bitbucketWebhookBody,
+			header: map[string]string{
+				"User-Agent":  "Bitbucket-Webhooks/2.0",
+				"X-Event-Key": "repo:push",
+			},
+		},
+		assertion: assertOK,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -263,7 +544,10 @@ func TestSCMWebhook(t *testing.T) {
 			}
 			httpWriter := httptest.NewRecorder()
 			container.Dispatch(httpWriter, httpRequest)
-			assert.Equal(t, 200, httpWriter.Code)
+			if tt.wantCode == 0 {
+				tt.wantCode = http.StatusOK
+			}
+			assert.Equal(t, tt.wantCode, httpWriter.Code)
 			if tt.assertion != nil {
 				body := httpWriter.Body
 				var bodyResponse string
@@ -384,6 +668,67 @@ const gitlabWebhookBody = `{
   }
 }`
 
+func githubSignature(body, key string) string {
+	mac := hmac.New(sha1.New, []byte(key))
+	_, _ = mac.Write([]byte(body))
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+const githubWebhookBody = `{
+  "ref": "refs/heads/master",
+  "before": "8f4b347e7d6b7647b51647dcd07ddafd4bded19f",
+  "after": "bd4f171cec5c6f9b8b184107ce318bf9a54dce26",
+  "repository": {
+    "id": 1296269,
+    "name": "test",
+    "full_name": "linuxsuren/test",
+    "owner": {
+      "login": "linuxsuren"
+    },
+    "html_url": "https://github.com/linuxsuren/test",
+    "clone_url": "https://github.com/linuxsuren/test.git",
+    "ssh_url": "git@github.com:linuxsuren/test.git",
+    "default_branch": "master"
+  },
+  "head_commit": {
+    "id": "bd4f171cec5c6f9b8b184107ce318bf9a54dce26",
+    "message": "Add new file"
+  },
+  "sender": {
+    "login": "linuxsuren"
+  }
+}`
+
+const bitbucketWebhookBody = `{
+  "push": {
+    "changes": [
+      {
+        "new": {
+          "type": "branch",
+          "name": "master",
+          "target": {
+            "type": "commit",
+            "hash": "bd4f171cec5c6f9b8b184107ce318bf9a54dce26",
+            "message": "Add new file"
+          }
+        }
+      }
+    ]
+  },
+  "repository": {
+    "full_name": "linuxsuren/test",
+    "name": "test",
+    "links": {
+      "html": {
+        "href": "https://bitbucket.org/linuxsuren/test"
+      }
+    }
+  },
+  "actor": {
+    "username": "linuxsuren"
+  }
+}`
+
 func Test(t *testing.T) {
 	err := retry.OnError(retry.DefaultRetry, func(err error) bool {
 		return true
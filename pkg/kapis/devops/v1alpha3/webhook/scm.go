@@ -17,8 +17,10 @@ limitations under the License.
 package webhook
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/jenkins-x/go-scm/scm"
@@ -27,24 +29,50 @@ import (
 	"github.com/jenkins-x/go-scm/scm/driver/gitlab"
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
-	"kubesphere.io/devops/pkg/client/devops"
 	"kubesphere.io/devops/pkg/jwt/token"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipelinerun"
 	"net/http"
 	"regexp"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // tokenExpireIn indicates that the temporary token issued by controller will be expired in some time.
 const tokenExpireIn time.Duration = 5 * time.Minute
-const scmAnnotationKey = "scm.devops.kubesphere.io"
+const scmAnnotationKey = v1alpha3.PipelineSCMAnnoKey
 const scmRefAnnotationKey = "scm.devops.kubesphere.io/ref"
+
+// scmEventsAnnotationKey is the annotation of the SCM events which trigger a Pipeline, such as: ["push","tag"].
+// Only the push events trigger the Pipelines without it.
+const scmEventsAnnotationKey = "scm.devops.kubesphere.io/events"
 const triggerAnnotationKey = "devops.kubesphere.io/trigger"
 
+// allowUnsignedAnnotationKey allows the unsigned webhook requests to trigger a Pipeline when there is no Webhook secret
+// of its repository. The unsigned requests are rejected by default.
+const allowUnsignedAnnotationKey = "scm.devops.kubesphere.io/allow-unsigned"
+const tagRefPrefix = "refs/tags/"
+const branchRefPrefix = "refs/heads/"
+
+// the kinds of the SCM events which are able to trigger PipelineRuns
+const (
+	scmEventPush        = "push"
+	scmEventTag         = "tag"
+	scmEventPullRequest = "pull-request"
+)
+
+// eventReasonWebhookRejected is the reason of the event which records a rejected webhook request
+const eventReasonWebhookRejected = "WebhookRejected"
+
 // SCMHandler handles requests from webhooks.
 type SCMHandler struct {
 	client.Client
@@ -83,68 +111,231 @@ func (h *SCMHandler) scmWebhook(request *restful.Request, response *restful.Resp
 		return
 	}
 
-	webhook, err := scmClient.Webhooks.Parse(request.Request, func(webhook scm.Webhook) (string, error) {
-		return "", nil
-	})
+	ctx := context.TODO()
+	body, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		_ = response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	// parse parses the payload and verifies its signature with the given key, the signature is not verified
+	// if the key is empty
+	parse := func(key string) (scm.Webhook, error) {
+		req := request.Request.Clone(ctx)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return scmClient.Webhooks.Parse(req, func(scm.Webhook) (string, error) {
+			return key, nil
+		})
+	}
+
+	// the secret belongs to the DevOpsProject of a Pipeline, so the signature is verified per Pipeline below
+	webhook, err := parse("")
 	if err != nil {
 		_, _ = response.Write([]byte(err.Error()))
 		return
 	}
 
-	ctx := context.TODO()
 	found := false
-	if webhook.Kind() == scm.WebhookKindPush {
+	// the errors of the matched Pipelines, the response has the worst status code of them
+	var errs []error
+	code := http.StatusOK
+	// the mismatched URL is only reported if nothing is triggered
+	var mismatchErr error
+	triggered := false
+	fail := func(err error, statusCode int) {
+		errs = append(errs, err)
+		if statusCode > code {
+			code = statusCode
+		}
+	}
+	if event := getSCMEvent(webhook, scmClient.Driver); event != nil {
 		repo := webhook.Repository()
-		pushHook := webhook.(*scm.PushHook)
 
 		pipelineList := &v1alpha3.PipelineList{}
-		if err = h.List(ctx, pipelineList); err == nil {
-			for i := range pipelineList.Items {
-				pipeline := pipelineList.Items[i]
-				if !branchMatch(pipeline, pushHook.Ref) {
-					continue
-				}
-				found = true
-
-				gitURL := pipeline.GetAnnotations()[scmAnnotationKey]
-				if pipeline.IsMultiBranch() {
-					gitURL = pipeline.Spec.MultiBranchPipeline.GetGitURL()
-					if gitURL != "" && gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = scanJenkinsMultiBranchPipeline(pipeline, h.jenkins, h.issue)
-					}
-				} else if gitURL != "" {
-					if gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = h.createPipelineRun(pipeline, pushHook)
-					} else {
-						err = fmt.Errorf("expect URL: %s, got: %v", gitURL, []string{repo.Link, repo.Clone, repo.CloneSSH})
-					}
+		if err = h.List(ctx, pipelineList); err != nil {
+			_ = response.WriteError(http.StatusInternalServerError, err)
+			return
+		}
+		// the verification results of the namespaces
+		verified := map[string]*verification{}
+		for i := range pipelineList.Items {
+			pipeline := pipelineList.Items[i]
+			if !eventEnabled(pipeline, event.kind) || !branchMatch(pipeline, event.ref) {
+				continue
+			}
+			found = true
+
+			gitURL := pipeline.GetAnnotations()[scmAnnotationKey]
+			if pipeline.IsMultiBranch() {
+				gitURL = pipeline.Spec.MultiBranchPipeline.GetGitURL()
+			}
+			if gitURL == "" {
+				continue
+			} else if !gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
+				if !pipeline.IsMultiBranch() {
+					mismatchErr = fmt.Errorf("expect URL: %s, got: %v", gitURL, []string{repo.Link, repo.Clone, repo.CloneSSH})
 				}
+				continue
+			}
+
+			result, ok := verified[pipeline.Namespace]
+			if !ok {
+				result = h.verifyWebhook(ctx, pipeline.Namespace, repo, parse)
+				verified[pipeline.Namespace] = result
+			}
+			if result.err != nil {
+				fail(result.err, result.code)
+				continue
+			}
+			if !result.signed && pipeline.GetAnnotations()[allowUnsignedAnnotationKey] != "true" {
+				fail(fmt.Errorf("refused to trigger pipeline %s/%s by an unsigned request, there is no webhook "+
+					"secret of the repository in the namespace", pipeline.Namespace, pipeline.Name), http.StatusUnauthorized)
+				continue
+			}
+
+			if pipeline.IsMultiBranch() {
+				err = scanJenkinsMultiBranchPipeline(pipeline, h.jenkins, h.issue)
+			} else {
+				err = h.createPipelineRun(pipeline, event, gitURL)
+			}
+			if err != nil {
+				fail(err, http.StatusBadRequest)
+			} else {
+				triggered = true
 			}
 		}
 	}
 
 	if !found {
 		_ = response.WriteErrorString(http.StatusOK, "no pipeline matched")
-		return
-	} else if err != nil {
-		_ = response.WriteError(http.StatusBadRequest, err)
+	} else if len(errs) > 0 {
+		_ = response.WriteErrorString(code, utilerrors.NewAggregate(errs).Error())
+	} else if mismatchErr != nil && !triggered {
+		_ = response.WriteErrorString(http.StatusBadRequest, mismatchErr.Error())
 	} else {
 		_, _ = response.Write([]byte("ok"))
 	}
 }
 
-func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, hook *scm.PushHook) (err error) {
-	branch := strings.TrimPrefix(hook.Ref, "refs/heads/")
+// verification is the result of verifying a webhook request for a namespace
+type verification struct {
+	err  error
+	code int
+	// signed is true if the signature is verified with the webhook secret
+	signed bool
+}
+
+// verifyWebhook verifies the signature of a webhook request with the secret of the GitRepository in the namespace.
+// The signature could not be verified if there is no GitRepository of the repository with a secret in the namespace,
+// it's up to the Pipelines whether to accept the unsigned request.
+func (h *SCMHandler) verifyWebhook(ctx context.Context, namespace string, repo scm.Repository,
+	parse func(key string) (scm.Webhook, error)) *verification {
+	gitRepo, secret, err := h.getWebhookSecret(ctx, namespace, repo)
+	if err == nil && gitRepo != nil {
+		// the payload must be signed once there is a secret configured for the repository
+		_, err = parse(secret)
+	}
+	switch {
+	case err == nil:
+		return &verification{signed: gitRepo != nil}
+	case gitRepo != nil:
+		h.recordRejection(ctx, gitRepo, err)
+		return &verification{err: err, code: http.StatusUnauthorized}
+	default:
+		return &verification{err: err, code: http.StatusInternalServerError}
+	}
+}
+
+// scmEvent represents a webhook event which is able to trigger PipelineRuns
+type scmEvent struct {
+	// kind is one of push, tag and pull-request
+	kind string
+	// ref is used to match against the branch rules of Pipelines, it's a full ref, such as: refs/heads/master,
+	// refs/tags/v1.0.0. A pull request is matched by its target branch.
+	ref string
+	// scm is the SCM reference of the PipelineRun, it's nil for a branch push
+	scm        *v1alpha3.SCM
+	parameters []v1alpha3.Parameter
+}
 
-	var scmObj *v1alpha3.SCM
-	if scmObj, err = pipelinerun.CreateScm(&pipeline.Spec, branch); err == nil {
-		run := pipelinerun.CreatePipelineRun(&pipeline, &devops.RunPayload{}, scmObj)
-		run.Annotations[triggerAnnotationKey] = "webhook"
-		err = h.Create(context.Background(), run)
+// getSCMEvent converts a webhook into an event, returns nil if the webhook should not trigger anything
+func getSCMEvent(webhook scm.Webhook, driver scm.Driver) (event *scmEvent) {
+	switch hook := webhook.(type) {
+	case *scm.PushHook:
+		if strings.HasPrefix(hook.Ref, tagRefPrefix) {
+			event = newTagEvent(strings.TrimPrefix(hook.Ref, tagRefPrefix))
+		} else {
+			event = &scmEvent{kind: scmEventPush, ref: hook.Ref}
+		}
+	case *scm.TagHook:
+		// GitHub sends a push event for the same tag, it's handled above
+		if hook.Action == scm.ActionCreate && driver != scm.DriverGithub {
+			event = newTagEvent(hook.Ref.Name)
+		}
+	case *scm.PullRequestHook:
+		switch hook.Action {
+		case scm.ActionOpen, scm.ActionReopen, scm.ActionSync:
+			event = newPullRequestEvent(hook.PullRequest)
+		}
 	}
 	return
 }
 
+func newTagEvent(tag string) *scmEvent {
+	return &scmEvent{
+		kind: scmEventTag,
+		ref:  tagRefPrefix + tag,
+		scm: &v1alpha3.SCM{
+			RefType: v1alpha3.Tag,
+			RefName: tag,
+		},
+		parameters: []v1alpha3.Parameter{{
+			Name:  v1alpha3.TagNameParameter,
+			Value: tag,
+		}},
+	}
+}
+
+func newPullRequestEvent(pr scm.PullRequest) *scmEvent {
+	return &scmEvent{
+		kind: scmEventPullRequest,
+		// match the branch rules against the target branch
+		ref: branchRefPrefix + strings.TrimPrefix(pr.Target, branchRefPrefix),
+		scm: &v1alpha3.SCM{
+			RefType: v1alpha3.PullRequest,
+			RefName: fmt.Sprintf("PR-%d", pr.Number),
+		},
+		parameters: []v1alpha3.Parameter{{
+			Name:  v1alpha3.PullRequestNumberParameter,
+			Value: strconv.Itoa(pr.Number),
+		}, {
+			Name:  v1alpha3.PullRequestSourceBranchParameter,
+			Value: pr.Source,
+		}, {
+			Name:  v1alpha3.PullRequestTargetBranchParameter,
+			Value: pr.Target,
+		}, {
+			Name:  v1alpha3.PullRequestHeadSHAParameter,
+			Value: pr.Sha,
+		}},
+	}
+}
+
+func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, event *scmEvent, gitURL string) (err error) {
+	scmObj := event.scm
+	if scmObj == nil {
+		branch := strings.TrimPrefix(event.ref, branchRefPrefix)
+		if scmObj, err = pipelinerun.CreateScm(&pipeline.Spec, branch); err != nil {
+			return
+		}
+	}
+
+	run := pipelinerun.CreateBarePipelineRun(&pipeline, event.parameters, scmObj)
+	run.Annotations[triggerAnnotationKey] = "webhook"
+	run.Annotations[v1alpha3.PipelineRunGitURLAnnoKey] = gitURL
+	err = h.Create(context.Background(), run)
+	return
+}
+
 func scanJenkinsMultiBranchPipeline(pipeline v1alpha3.Pipeline, jenkins core.JenkinsCore, issue token.Issuer) (err error) {
 	var accessToken string
 	accessToken, err = issue.IssueTo(&user.DefaultInfo{Name: "admin"}, token.AccessToken, tokenExpireIn)
@@ -163,6 +354,128 @@ func scanJenkinsMultiBranchPipeline(pipeline v1alpha3.Pipeline, jenkins core.Jen
 	return
 }
 
+// getWebhookSecret finds the GitRepositories of the given repository in the namespace, then returns the HMAC secret
+// of their Webhooks. The GitRepository is nil if there is no Webhook with a secret, in which case the signature will
+// not be verified. It's an error if the Webhooks have different secrets, because it's not clear which one to use.
+func (h *SCMHandler) getWebhookSecret(ctx context.Context, namespace string, repo scm.Repository) (
+	gitRepo *v1alpha3.GitRepository, secret string, err error) {
+	gitRepoList := &v1alpha3.GitRepositoryList{}
+	if err = h.List(ctx, gitRepoList, client.InNamespace(namespace)); err != nil {
+		err = fmt.Errorf("failed to list git repositories in namespace %s, error: %v", namespace, err)
+		return
+	}
+
+	for i := range gitRepoList.Items {
+		item := &gitRepoList.Items[i]
+		if item.Spec.URL == "" || !gitRepoMatch(item.Spec.URL, repo.Link, repo.Clone, repo.CloneSSH) {
+			continue
+		}
+
+		for _, webhookRef := range item.Spec.Webhooks {
+			webhook := &v1alpha3.Webhook{}
+			if err = h.Get(ctx, types.NamespacedName{
+				Namespace: item.Namespace,
+				Name:      webhookRef.Name,
+			}, webhook); apierrors.IsNotFound(err) {
+				err = nil
+				continue
+			} else if err != nil {
+				err = fmt.Errorf("cannot get webhook %s/%s, error: %v", item.Namespace, webhookRef.Name, err)
+				return
+			}
+			if webhook.Spec.Secret == nil {
+				continue
+			}
+
+			var value string
+			if value, err = h.getSecretValue(ctx, webhook.Spec.Secret, item.Namespace); err != nil {
+				gitRepo = item
+				return
+			}
+			if gitRepo == nil {
+				gitRepo, secret = item, value
+			} else if value != secret {
+				err = fmt.Errorf("the webhooks of git repositories %s and %s in namespace %s have different secrets",
+					gitRepo.Name, item.Name, namespace)
+				return
+			}
+		}
+	}
+	return
+}
+
+// getSecretValue returns the HMAC secret from the referenced secret
+func (h *SCMHandler) getSecretValue(ctx context.Context, ref *v1.SecretReference, defaultNamespace string) (
+	value string, err error) {
+	ns := ref.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+
+	secret := &v1.Secret{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: ns, Name: ref.Name}, secret); err != nil {
+		err = fmt.Errorf("cannot get webhook secret %s/%s, error: %v", ns, ref.Name, err)
+		return
+	}
+
+	value = v1alpha3.GetWebhookSecretValue(secret)
+	if value == "" {
+		err = fmt.Errorf("webhook secret %s/%s is empty", ns, ref.Name)
+	}
+	return
+}
+
+// recordRejection records a warning event on the GitRepository for a rejected webhook request
+func (h *SCMHandler) recordRejection(ctx context.Context, gitRepo *v1alpha3.GitRepository, reason error) {
+	message := reason.Error()
+	if errors.Is(reason, scm.ErrSignatureInvalid) {
+		message = "rejected a webhook request with a missing or invalid signature"
+	}
+
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: gitRepo.Name + ".",
+			Namespace:    gitRepo.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion:      v1alpha3.GroupVersion.String(),
+			Kind:            "GitRepository",
+			Namespace:       gitRepo.Namespace,
+			Name:            gitRepo.Name,
+			UID:             gitRepo.UID,
+			ResourceVersion: gitRepo.ResourceVersion,
+		},
+		Reason:         eventReasonWebhookRejected,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: "devops-apiserver"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	// failing to record the event should not affect the response
+	_ = h.Create(ctx, event)
+}
+
+// eventEnabled returns true if the kind of SCM events is enabled by the annotation, only push is enabled by default
+func eventEnabled(pipeline v1alpha3.Pipeline, kind string) bool {
+	events, ok := pipeline.Annotations[scmEventsAnnotationKey]
+	if !ok {
+		return kind == scmEventPush
+	}
+	var eventSlice []string
+	if err := json.Unmarshal([]byte(events), &eventSlice); err != nil {
+		return kind == scmEventPush
+	}
+	for i := range eventSlice {
+		if eventSlice[i] == kind {
+			return true
+		}
+	}
+	return false
+}
+
 // branchMatch matches the branch rules from annotation.
 // It supports regexp pattern, or returns true if no annotation found
 func branchMatch(pipeline v1alpha3.Pipeline, branch string) (ok bool) {
@@ -134,3 +134,124 @@ func Test_branchMatch(t *testing.T) {
 		})
 	}
 }
+
+func Test_eventEnabled(t *testing.T) {
+	withEvents := func(events string) v1alpha3.Pipeline {
+		return v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{scmEventsAnnotationKey: events},
+		}}
+	}
+	tests := []struct {
+		name     string
+		pipeline v1alpha3.Pipeline
+		kind     string
+		want     bool
+	}{{
+		name: "push is enabled by default",
+		kind: scmEventPush,
+		want: true,
+	}, {
+		name: "pull request is disabled by default",
+		kind: scmEventPullRequest,
+	}, {
+		name: "tag is disabled by default",
+		kind: scmEventTag,
+	}, {
+		name:     "pull request is enabled",
+		pipeline: withEvents(`["push", "pull-request"]`),
+		kind:     scmEventPullRequest,
+		want:     true,
+	}, {
+		name:     "push is disabled",
+		pipeline: withEvents(`["tag"]`),
+		kind:     scmEventPush,
+	}, {
+		name:     "invalid annotation",
+		pipeline: withEvents(`tag`),
+		kind:     scmEventTag,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, eventEnabled(tt.pipeline, tt.kind))
+		})
+	}
+}
+
+func Test_getSCMEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook scm.Webhook
+		driver  scm.Driver
+		want    *scmEvent
+	}{{
+		name:    "branch push",
+		webhook: &scm.PushHook{Ref: "refs/heads/master"},
+		driver:  scm.DriverGitlab,
+		want:    &scmEvent{kind: scmEventPush, ref: "refs/heads/master"},
+	}, {
+		name:    "tag push",
+		webhook: &scm.PushHook{Ref: "refs/tags/v1.0.0"},
+		driver:  scm.DriverGithub,
+		want: &scmEvent{
+			kind:       scmEventTag,
+			ref:        "refs/tags/v1.0.0",
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: "v1.0.0"},
+			parameters: []v1alpha3.Parameter{{Name: v1alpha3.TagNameParameter, Value: "v1.0.0"}},
+		},
+	}, {
+		name:    "tag created",
+		webhook: &scm.TagHook{Action: scm.ActionCreate, Ref: scm.Reference{Name: "v1.0.0"}},
+		driver:  scm.DriverGitlab,
+		want: &scmEvent{
+			kind:       scmEventTag,
+			ref:        "refs/tags/v1.0.0",
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: "v1.0.0"},
+			parameters: []v1alpha3.Parameter{{Name: v1alpha3.TagNameParameter, Value: "v1.0.0"}},
+		},
+	}, {
+		name:    "tag created on GitHub is handled by the push event",
+		webhook: &scm.TagHook{Action: scm.ActionCreate, Ref: scm.Reference{Name: "v1.0.0"}},
+		driver:  scm.DriverGithub,
+		want:    nil,
+	}, {
+		name:    "tag deleted",
+		webhook: &scm.TagHook{Action: scm.ActionDelete, Ref: scm.Reference{Name: "v1.0.0"}},
+		driver:  scm.DriverGitlab,
+		want:    nil,
+	}, {
+		name: "pull request opened",
+		webhook: &scm.PullRequestHook{Action: scm.ActionOpen, PullRequest: scm.PullRequest{
+			Number: 12,
+			Source: "feat-login",
+			Target: "master",
+			Sha:    "bd4f171cec5c6f9b8b184107ce318bf9a54dce26",
+		}},
+		driver: scm.DriverGithub,
+		want: &scmEvent{
+			kind: scmEventPullRequest,
+			ref:  "refs/heads/master",
+			scm:  &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-12"},
+			parameters: []v1alpha3.Parameter{
+				{Name: v1alpha3.PullRequestNumberParameter, Value: "12"},
+				{Name: v1alpha3.PullRequestSourceBranchParameter, Value: "feat-login"},
+				{Name: v1alpha3.PullRequestTargetBranchParameter, Value: "master"},
+				{Name: v1alpha3.PullRequestHeadSHAParameter, Value: "bd4f171cec5c6f9b8b184107ce318bf9a54dce26"},
+			},
+		},
+	}, {
+		name:    "pull request closed",
+		webhook: &scm.PullRequestHook{Action: scm.ActionClose},
+		driver:  scm.DriverGithub,
+		want:    nil,
+	}, {
+		name:    "unsupported webhook",
+		webhook: &scm.IssueHook{},
+		driver:  scm.DriverGithub,
+		want:    nil,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getSCMEvent(tt.webhook, tt.driver))
+		})
+	}
+}
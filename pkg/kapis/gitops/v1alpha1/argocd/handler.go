@@ -17,18 +17,24 @@ package argocd
 
 import (
 	"context"
+	"fmt"
 	"github.com/emicklei/go-restful"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/authentication/user"
 	utilretry "k8s.io/client-go/util/retry"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	apiserverrequest "kubesphere.io/devops/pkg/apiserver/request"
 	"kubesphere.io/devops/pkg/config"
 	"kubesphere.io/devops/pkg/kapis/common"
 	"kubesphere.io/devops/pkg/kapis/gitops/v1alpha1/gitops"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
 	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
 )
 
 var argoAppNotConfiguredError = restful.NewError(http.StatusBadRequest,
@@ -39,6 +45,10 @@ var invalidRequestBodyError = restful.NewError(http.StatusBadRequest,
 	"invalid application sync request")
 var unauthenticatedError = restful.NewError(http.StatusUnauthorized,
 	"unauthenticated request")
+var rollbackWithAutoSyncError = restful.NewError(http.StatusBadRequest,
+	"rollback cannot be initiated when automated sync is enabled")
+var argoServerNotConfiguredError = restful.NewError(http.StatusServiceUnavailable,
+	"the Argo CD API server is not configured")
 
 func (h *handler) createApplication(req *restful.Request, res *restful.Response) {
 	var err error
@@ -121,6 +131,10 @@ func (h *handler) syncApplication(namespace, name string, syncRequest *Applicati
 		return nil, argoAppNotConfiguredError
 	}
 
+	if err := h.checkSyncWindows(ctx, app); err != nil {
+		return nil, err
+	}
+
 	argoApp := app.Spec.ArgoApp
 
 	// concrete operation
@@ -157,6 +171,184 @@ func (h *handler) syncApplication(namespace, name string, syncRequest *Applicati
 	return h.updateOperation(namespace, name, operation)
 }
 
+func (h *handler) getSyncHistory(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	app := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+	history := app.Status.SyncHistory
+	if history == nil {
+		history = []v1alpha1.SyncHistory{}
+	}
+	common.Response(req, res, history, nil)
+}
+
+func (h *handler) handleRollbackApplication(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	rollbackRequest := &ApplicationRollbackRequest{}
+	if err := req.ReadEntity(rollbackRequest); err != nil {
+		common.Response(req, res, nil, invalidRequestBodyError)
+		return
+	}
+
+	currentUser, ok := apiserverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	app, err := h.rollbackApplication(namespace, name, rollbackRequest, currentUser)
+	common.Response(req, res, app, err)
+}
+
+// rollbackApplication syncs the application to the revision of a succeeded sync in its history.
+// Same as Argo CD, it's not allowed when the automated sync is enabled, or the rollback will be reverted soon.
+func (h *handler) rollbackApplication(namespace, name string, rollbackRequest *ApplicationRollbackRequest, currentUser user.Info) (*v1alpha1.Application, error) {
+	app := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		return nil, err
+	}
+	if app.Spec.ArgoApp == nil {
+		return nil, argoAppNotConfiguredError
+	}
+	syncPolicy := app.Spec.ArgoApp.Spec.SyncPolicy
+	if syncPolicy != nil && syncPolicy.Automated != nil {
+		return nil, rollbackWithAutoSyncError
+	}
+	if err := h.checkSyncWindows(context.Background(), app); err != nil {
+		return nil, err
+	}
+	history := app.Status.GetSyncHistory(rollbackRequest.ID)
+	if history == nil {
+		return nil, restful.NewError(http.StatusBadRequest,
+			fmt.Sprintf("cannot find the succeeded sync history with ID %d", rollbackRequest.ID))
+	}
+
+	operation := &v1alpha1.Operation{
+		Sync: &v1alpha1.SyncOperation{
+			Revision: history.Revision,
+			Prune:    rollbackRequest.Prune,
+			DryRun:   rollbackRequest.DryRun,
+		},
+		InitiatedBy: v1alpha1.OperationInitiator{Username: currentUser.GetName()},
+		Info: []*v1alpha1.Info{{
+			Name:  "Reason",
+			Value: fmt.Sprintf("Rollback to the sync history with ID %d", history.ID),
+		}},
+	}
+	if syncPolicy != nil {
+		operation.Sync.SyncOptions = syncPolicy.SyncOptions
+		if syncPolicy.Retry != nil {
+			operation.Retry = *syncPolicy.Retry
+		}
+	}
+	return h.updateOperation(namespace, name, operation)
+}
+
+func (h *handler) diffApplication(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	app := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+	if app.Spec.ArgoApp == nil {
+		common.Response(req, res, nil, argoAppNotConfiguredError)
+		return
+	}
+	if h.argoClient == nil {
+		common.Response(req, res, nil, argoServerNotConfiguredError)
+		return
+	}
+
+	diffs, err := diff.ArgoCD(req.Request.Context(), h.argoClient, app)
+	common.Response(req, res, diffs, err)
+}
+
+func (h *handler) getSyncWindows(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	ctx := context.Background()
+	app := &v1alpha1.Application{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+	if app.Spec.ArgoApp == nil {
+		common.Response(req, res, nil, argoAppNotConfiguredError)
+		return
+	}
+
+	windows, err := h.getApplicationSyncWindows(ctx, app)
+	if err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+	now := time.Now()
+	state := &ApplicationSyncWindows{
+		CanSync:           windows.CanSync(now, false),
+		ManualSyncEnabled: windows.CanSync(now, true),
+		AssignedWindows:   windows,
+		ActiveWindows:     windows.Active(now),
+	}
+	if !state.CanSync {
+		if next := windows.NextSyncTime(now, false); next != nil {
+			state.NextSyncTime = &metav1.Time{Time: *next}
+		}
+	}
+	if state.AssignedWindows == nil {
+		state.AssignedWindows = devopsv1alpha3.SyncWindows{}
+	}
+	if state.ActiveWindows == nil {
+		state.ActiveWindows = devopsv1alpha3.SyncWindows{}
+	}
+	common.Response(req, res, state, nil)
+}
+
+// getApplicationSyncWindows returns the sync windows of the DevOpsProject which are applied to the application.
+// The Argo AppProject of an application is always its namespace, see also the ApplicationReconciler.
+func (h *handler) getApplicationSyncWindows(ctx context.Context, app *v1alpha1.Application) (devopsv1alpha3.SyncWindows, error) {
+	project := &devopsv1alpha3.DevOpsProject{}
+	if err := h.Get(ctx, types.NamespacedName{Name: app.Namespace}, project); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if project.Spec.Argo == nil {
+		return nil, nil
+	}
+	destination := app.Spec.ArgoApp.Spec.Destination
+	return project.Spec.Argo.SyncWindows.Matches(app.Name, destination.Namespace, destination.Server,
+		destination.Name), nil
+}
+
+// checkSyncWindows returns an error if the application cannot be synced manually now because of the sync windows.
+func (h *handler) checkSyncWindows(ctx context.Context, app *v1alpha1.Application) error {
+	windows, err := h.getApplicationSyncWindows(ctx, app)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if windows.CanSync(now, true) {
+		return nil
+	}
+	message := fmt.Sprintf("cannot sync application %s: it is blocked by the sync windows", app.Name)
+	if next := windows.NextSyncTime(now, true); next != nil {
+		message += fmt.Sprintf(", the next sync window opens at %s", next.Format(time.RFC3339))
+	}
+	return restful.NewError(http.StatusForbidden, message)
+}
+
 func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Operation) (*v1alpha1.Application, error) {
 	var app *v1alpha1.Application
 	return app, utilretry.RetryOnConflict(utilretry.DefaultRetry, func() error {
@@ -215,11 +407,13 @@ func (h *handler) getClusters(req *restful.Request, res *restful.Response) {
 type handler struct {
 	*gitops.Handler
 	ArgoCDNamespace string
+	argoClient      diff.ArgoCDClient
 }
 
 func newHandler(options *common.Options, argoOption *config.ArgoCDOption) *handler {
 	return &handler{
 		Handler:         gitops.NewHandler(options),
 		ArgoCDNamespace: argoOption.Namespace,
+		argoClient:      diff.NewArgoCDClient(argoOption),
 	}
 }
@@ -17,18 +17,22 @@ package argocd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/emicklei/go-restful"
 	"github.com/stretchr/testify/assert"
 	"io"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes/scheme"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	"kubesphere.io/devops/pkg/apiserver/request"
 	"kubesphere.io/devops/pkg/kapis/common"
 	"kubesphere.io/devops/pkg/kapis/gitops/v1alpha1/gitops"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
 	"net/http"
 	"net/http/httptest"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -69,8 +73,25 @@ func Test_handler_handleSyncApplication(t *testing.T) {
 		req.PathParameters()[pathParameterApplication.Data().Name] = name
 		return req
 	}
+	createProject := func(manualSync bool) *devopsv1alpha3.DevOpsProject {
+		return &devopsv1alpha3.DevOpsProject{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake-namespace"},
+			Spec: devopsv1alpha3.DevOpsProjectSpec{
+				Argo: &devopsv1alpha3.Argo{
+					SyncWindows: devopsv1alpha3.SyncWindows{{
+						Kind:         devopsv1alpha3.SyncWindowKindDeny,
+						Schedule:     "* * * * *",
+						Duration:     "1h",
+						Applications: []string{"fake-*"},
+						ManualSync:   manualSync,
+					}},
+				},
+			},
+		}
+	}
 	type fields struct {
-		apps []v1alpha1.Application
+		apps    []v1alpha1.Application
+		project *devopsv1alpha3.DevOpsProject
 	}
 	type args struct {
 		req *restful.Request
@@ -197,11 +218,44 @@ func Test_handler_handleSyncApplication(t *testing.T) {
 			assert.True(t, gotOp.Sync.SyncStrategy.Apply.Force)
 			assert.Equal(t, v1alpha1.SyncOptions{"fake-option=true"}, gotOp.Sync.SyncOptions)
 		},
+	}, {
+		name: "Should return 403 if the app is blocked by a deny sync window",
+		fields: fields{
+			apps:    []v1alpha1.Application{*createApp("fake-app", nil)},
+			project: createProject(false),
+		},
+		args: args{
+			req: createRequest("fake-app", &ApplicationSyncRequest{}, true),
+		},
+		wantResponseCode: http.StatusForbidden,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, "cannot sync application fake-app: it is blocked by the sync windows")
+		},
+	}, {
+		name: "Should update operation field if the deny sync window enables the manual sync",
+		fields: fields{
+			apps:    []v1alpha1.Application{*createApp("fake-app", nil)},
+			project: createProject(true),
+		},
+		args: args{
+			req: createRequest("fake-app", &ApplicationSyncRequest{}, true),
+		},
+		wantResponseCode: http.StatusOK,
+		verifyResponse: func(t *testing.T, response string) {
+			gotApp := &v1alpha1.Application{}
+			assert.NoError(t, json.Unmarshal([]byte(response), gotApp))
+			assert.NotNil(t, gotApp.Spec.ArgoApp.Operation)
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
-			fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, gitops.ToObjects(tt.fields.apps)...)
+			utilruntime.Must(devopsv1alpha3.AddToScheme(scheme.Scheme))
+			objects := gitops.ToObjects(tt.fields.apps)
+			if tt.fields.project != nil {
+				objects = append(objects, tt.fields.project)
+			}
+			fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, objects...)
 			h := &handler{
 				Handler: &gitops.Handler{Client: fakeClient},
 			}
@@ -303,3 +357,342 @@ func Test_handler_updateOperation(t *testing.T) {
 		})
 	}
 }
+
+func Test_handler_handleRollbackApplication(t *testing.T) {
+	syncHistory := []v1alpha1.SyncHistory{{
+		ID:       1,
+		Revision: "fake-revision-1",
+		Result:   v1alpha1.SyncSucceeded,
+	}, {
+		Revision: "fake-revision-failed",
+		Result:   v1alpha1.SyncFailed,
+	}, {
+		ID:       0,
+		Revision: "fake-revision-0",
+		Result:   v1alpha1.SyncSucceeded,
+	}}
+	createApp := func(syncPolicy *v1alpha1.SyncPolicy) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "fake-app",
+				Namespace: "fake-namespace",
+			},
+			Spec: v1alpha1.ApplicationSpec{
+				ArgoApp: &v1alpha1.ArgoApplication{
+					Spec: v1alpha1.ArgoApplicationSpec{SyncPolicy: syncPolicy},
+				},
+			},
+			Status: v1alpha1.ApplicationStatus{SyncHistory: syncHistory},
+		}
+	}
+	createRequest := func(rollbackRequest *ApplicationRollbackRequest, withUser bool) *restful.Request {
+		var body io.Reader
+		if rollbackRequest != nil {
+			bodyJSON, err := json.Marshal(rollbackRequest)
+			assert.NoError(t, err)
+			body = bytes.NewBuffer(bodyJSON)
+		}
+		testReq := httptest.NewRequest(http.MethodPost, "/applications/fake-app/rollback", body)
+		testReq.Header.Set(restful.HEADER_ContentType, restful.MIME_JSON)
+		if withUser {
+			testReq = testReq.WithContext(request.WithUser(testReq.Context(), &user.DefaultInfo{Name: "fake-user"}))
+		}
+		req := restful.NewRequest(testReq)
+		req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+		req.PathParameters()[pathParameterApplication.Data().Name] = "fake-app"
+		return req
+	}
+	tests := []struct {
+		name             string
+		app              *v1alpha1.Application
+		req              *restful.Request
+		wantResponseCode int
+		verifyResponse   func(t *testing.T, response string)
+	}{{
+		name:             "Should return bad request error if rollback request is nil",
+		app:              createApp(nil),
+		req:              createRequest(nil, true),
+		wantResponseCode: http.StatusBadRequest,
+	}, {
+		name:             "Should return 401 if unauthenticated user requests this endpoint",
+		app:              createApp(nil),
+		req:              createRequest(&ApplicationRollbackRequest{ID: 1}, false),
+		wantResponseCode: http.StatusUnauthorized,
+	}, {
+		name: "Should return 400 if automated sync is enabled",
+		app: createApp(&v1alpha1.SyncPolicy{
+			Automated: &v1alpha1.SyncPolicyAutomated{},
+		}),
+		req:              createRequest(&ApplicationRollbackRequest{ID: 1}, true),
+		wantResponseCode: http.StatusBadRequest,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, rollbackWithAutoSyncError.Error())
+		},
+	}, {
+		name:             "Should return 400 if the history is not found",
+		app:              createApp(nil),
+		req:              createRequest(&ApplicationRollbackRequest{ID: 2}, true),
+		wantResponseCode: http.StatusBadRequest,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, "cannot find the succeeded sync history with ID 2")
+		},
+	}, {
+		name: "Should sync to the revision of the history",
+		app: createApp(&v1alpha1.SyncPolicy{
+			SyncOptions: v1alpha1.SyncOptions{"fake-option=true"},
+			Retry:       &v1alpha1.RetryStrategy{Limit: 3},
+		}),
+		req:              createRequest(&ApplicationRollbackRequest{ID: 0, Prune: true}, true),
+		wantResponseCode: http.StatusOK,
+		verifyResponse: func(t *testing.T, response string) {
+			gotApp := &v1alpha1.Application{}
+			assert.NoError(t, json.Unmarshal([]byte(response), gotApp))
+			gotOp := gotApp.Spec.ArgoApp.Operation
+			assert.NotNil(t, gotOp)
+			assert.Equal(t, "fake-revision-0", gotOp.Sync.Revision)
+			assert.True(t, gotOp.Sync.Prune)
+			assert.False(t, gotOp.Sync.DryRun)
+			assert.Equal(t, v1alpha1.SyncOptions{"fake-option=true"}, gotOp.Sync.SyncOptions)
+			assert.Equal(t, int64(3), gotOp.Retry.Limit)
+			assert.Equal(t, "fake-user", gotOp.InitiatedBy.Username)
+			assert.Equal(t, []*v1alpha1.Info{{Name: "Reason", Value: "Rollback to the sync history with ID 0"}}, gotOp.Info)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+			utilruntime.Must(devopsv1alpha3.AddToScheme(scheme.Scheme))
+			h := &handler{
+				Handler: &gitops.Handler{Client: fake.NewFakeClientWithScheme(scheme.Scheme, tt.app)},
+			}
+
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.handleRollbackApplication(tt.req, resp)
+			assert.Equal(t, tt.wantResponseCode, recorder.Code)
+			if tt.verifyResponse != nil {
+				tt.verifyResponse(t, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_handler_getSyncHistory(t *testing.T) {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-app",
+			Namespace: "fake-namespace",
+		},
+		Status: v1alpha1.ApplicationStatus{SyncHistory: []v1alpha1.SyncHistory{{
+			ID:        1,
+			Revision:  "fake-revision",
+			Initiator: "fake-user",
+			Result:    v1alpha1.SyncSucceeded,
+		}}},
+	}
+	emptyApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "empty-app",
+			Namespace: "fake-namespace",
+		},
+	}
+	h := &handler{
+		Handler: &gitops.Handler{Client: fake.NewFakeClientWithScheme(scheme.Scheme, app, emptyApp)},
+	}
+
+	for name, want := range map[string][]v1alpha1.SyncHistory{
+		"fake-app":  app.Status.SyncHistory,
+		"empty-app": {},
+	} {
+		req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/applications/"+name+"/history", nil))
+		req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+		req.PathParameters()[pathParameterApplication.Data().Name] = name
+		recorder := httptest.NewRecorder()
+		resp := restful.NewResponse(recorder)
+		resp.SetRequestAccepts(restful.MIME_JSON)
+		h.getSyncHistory(req, resp)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var got []v1alpha1.SyncHistory
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+		assert.Equal(t, want, got, name)
+	}
+}
+
+type fakeArgoCDClient struct {
+	resources []diff.ManagedResource
+}
+
+func (c *fakeArgoCDClient) GetManagedResources(context.Context, string, string) ([]diff.ManagedResource, error) {
+	return c.resources, nil
+}
+
+func Test_handler_diffApplication(t *testing.T) {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	argoApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "argo-app", Namespace: "fake-namespace"},
+		Spec:       v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}},
+	}
+	emptyApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "empty-app", Namespace: "fake-namespace"},
+	}
+	argoClient := &fakeArgoCDClient{resources: []diff.ManagedResource{{
+		Kind:        "ConfigMap",
+		Namespace:   "default",
+		Name:        "fake",
+		TargetState: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "fake", "namespace": "default"}}`,
+	}}}
+
+	tests := []struct {
+		name             string
+		app              string
+		argoClient       diff.ArgoCDClient
+		wantResponseCode int
+		wantResponse     string
+	}{{
+		name:             "not found",
+		app:              "not-found",
+		argoClient:       argoClient,
+		wantResponseCode: http.StatusNotFound,
+	}, {
+		name:             "not an Argo CD Application",
+		app:              "empty-app",
+		argoClient:       argoClient,
+		wantResponseCode: http.StatusBadRequest,
+		wantResponse:     argoAppNotConfiguredError.Error(),
+	}, {
+		name:             "Argo CD API server is not configured",
+		app:              "argo-app",
+		wantResponseCode: http.StatusServiceUnavailable,
+		wantResponse:     argoServerNotConfiguredError.Error(),
+	}, {
+		name:             "normal",
+		app:              "argo-app",
+		argoClient:       argoClient,
+		wantResponseCode: http.StatusOK,
+		wantResponse:     `"diff": "--- live/ConfigMap default/fake\n+++ desired/ConfigMap default/fake\n`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{
+				Handler:    &gitops.Handler{Client: fake.NewFakeClientWithScheme(scheme.Scheme, argoApp, emptyApp)},
+				argoClient: tt.argoClient,
+			}
+			req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/applications/"+tt.app+"/diff", nil))
+			req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+			req.PathParameters()[pathParameterApplication.Data().Name] = tt.app
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.diffApplication(req, resp)
+
+			assert.Equal(t, tt.wantResponseCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantResponse)
+		})
+	}
+}
+
+func Test_handler_getSyncWindows(t *testing.T) {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	utilruntime.Must(devopsv1alpha3.AddToScheme(scheme.Scheme))
+	createApp := func(name string) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "fake-namespace"},
+			Spec: v1alpha1.ApplicationSpec{
+				ArgoApp: &v1alpha1.ArgoApplication{
+					Spec: v1alpha1.ArgoApplicationSpec{
+						Destination: v1alpha1.ApplicationDestination{
+							Server:    "https://kubernetes.default.svc",
+							Namespace: "fake-target",
+						},
+					},
+				},
+			},
+		}
+	}
+	project := &devopsv1alpha3.DevOpsProject{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-namespace"},
+		Spec: devopsv1alpha3.DevOpsProjectSpec{
+			Argo: &devopsv1alpha3.Argo{
+				SyncWindows: devopsv1alpha3.SyncWindows{{
+					Kind:       devopsv1alpha3.SyncWindowKindDeny,
+					Schedule:   "* * * * *",
+					Duration:   "1h",
+					Namespaces: []string{"fake-target"},
+					ManualSync: true,
+				}, {
+					Kind:         devopsv1alpha3.SyncWindowKindAllow,
+					Schedule:     "* * * * *",
+					Duration:     "1h",
+					Applications: []string{"another-app"},
+				}},
+			},
+		},
+	}
+	emptyApp := createApp("empty-app")
+	emptyApp.Spec.ArgoApp = nil
+
+	tests := []struct {
+		name             string
+		objects          []runtime.Object
+		app              string
+		wantResponseCode int
+		verifyResponse   func(t *testing.T, state *ApplicationSyncWindows)
+	}{{
+		name:             "app not found",
+		app:              "fake-app",
+		wantResponseCode: http.StatusNotFound,
+	}, {
+		name:             "app without Argo CD settings",
+		objects:          []runtime.Object{emptyApp},
+		app:              "empty-app",
+		wantResponseCode: http.StatusBadRequest,
+	}, {
+		name:             "no DevOpsProject",
+		objects:          []runtime.Object{createApp("fake-app")},
+		app:              "fake-app",
+		wantResponseCode: http.StatusOK,
+		verifyResponse: func(t *testing.T, state *ApplicationSyncWindows) {
+			assert.True(t, state.CanSync)
+			assert.True(t, state.ManualSyncEnabled)
+			assert.Nil(t, state.NextSyncTime)
+			assert.Empty(t, state.AssignedWindows)
+		},
+	}, {
+		name:             "blocked by a deny window",
+		objects:          []runtime.Object{createApp("fake-app"), project.DeepCopy()},
+		app:              "fake-app",
+		wantResponseCode: http.StatusOK,
+		verifyResponse: func(t *testing.T, state *ApplicationSyncWindows) {
+			assert.False(t, state.CanSync)
+			assert.True(t, state.ManualSyncEnabled)
+			// the deny window never closes
+			assert.Nil(t, state.NextSyncTime)
+			assert.Equal(t, project.Spec.Argo.SyncWindows[:1], state.AssignedWindows)
+			assert.Equal(t, project.Spec.Argo.SyncWindows[:1], state.ActiveWindows)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{
+				Handler: &gitops.Handler{Client: fake.NewFakeClientWithScheme(scheme.Scheme, tt.objects...)},
+			}
+			req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/", nil))
+			req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+			req.PathParameters()[pathParameterApplication.Data().Name] = tt.app
+
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.getSyncWindows(req, resp)
+			assert.Equal(t, tt.wantResponseCode, recorder.Code)
+			if tt.verifyResponse != nil {
+				state := &ApplicationSyncWindows{}
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), state))
+				tt.verifyResponse(t, state)
+			}
+		})
+	}
+}
@@ -17,10 +17,13 @@ package argocd
 
 import (
 	"github.com/emicklei/go-restful"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"kubesphere.io/devops/pkg/api"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	"kubesphere.io/devops/pkg/config"
 	"kubesphere.io/devops/pkg/kapis/common"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
 	"net/http"
 )
 
@@ -60,6 +63,29 @@ type ApplicationSyncRequest struct {
 	SyncOptions   *v1alpha1.SyncOptions            `json:"syncOptions,omitempty"`
 }
 
+// ApplicationRollbackRequest is a request to roll back an application to the revision of a previous sync.
+type ApplicationRollbackRequest struct {
+	// ID is the ID of the sync history to roll back to
+	ID     int64 `json:"id"`
+	DryRun bool  `json:"dryRun"`
+	Prune  bool  `json:"prune"`
+}
+
+// ApplicationSyncWindows is the model of the sync windows state of an application.
+type ApplicationSyncWindows struct {
+	// CanSync indicates if the application could be synced automatically now
+	CanSync bool `json:"canSync"`
+	// ManualSyncEnabled indicates if the application could be synced manually now
+	ManualSyncEnabled bool `json:"manualSyncEnabled"`
+	// NextSyncTime is the time when the next sync window opens, it's empty if the application could be synced now
+	// or no window will open in the near future
+	NextSyncTime *metav1.Time `json:"nextSyncTime,omitempty"`
+	// AssignedWindows are the sync windows of the DevOpsProject which are applied to the application
+	AssignedWindows devopsv1alpha3.SyncWindows `json:"assignedWindows"`
+	// ActiveWindows are the assigned windows which are open now
+	ActiveWindows devopsv1alpha3.SyncWindows `json:"activeWindows"`
+}
+
 // RegisterRoutes is for registering Argo CD Application routes into WebService.
 func RegisterRoutes(service *restful.WebService, options *common.Options, argoOption *config.ArgoCDOption) {
 	handler := newHandler(options, argoOption)
@@ -104,6 +130,35 @@ func RegisterRoutes(service *restful.WebService, options *common.Options, argoOp
 		Doc("Sync a particular application manually").
 		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
 
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/history").
+		To(handler.getSyncHistory).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Doc("Get the sync history of a particular application, the latest one comes first").
+		Returns(http.StatusOK, api.StatusOK, []v1alpha1.SyncHistory{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/applications/{application}/rollback").
+		To(handler.handleRollbackApplication).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Reads(ApplicationRollbackRequest{}).
+		Doc("Roll back a particular application to the revision of a previous sync").
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/diff").
+		To(handler.diffApplication).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Doc("Preview the changes of a particular application, the ignored differences are not included").
+		Returns(http.StatusOK, api.StatusOK, []diff.ResourceDiff{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/syncwindows").
+		To(handler.getSyncWindows).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Doc("Check if a particular application could be synced now according to the sync windows, and when the next window opens").
+		Returns(http.StatusOK, api.StatusOK, ApplicationSyncWindows{}))
+
 	service.Route(service.DELETE("/namespaces/{namespace}/applications/{application}").
 		To(handler.DelApplication).
 		Param(common.NamespacePathParameter).
@@ -17,15 +17,30 @@ package fluxcd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/emicklei/go-restful"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	"kubesphere.io/devops/pkg/config"
 	"kubesphere.io/devops/pkg/kapis/common"
 	"kubesphere.io/devops/pkg/kapis/gitops/v1alpha1/gitops"
+	"kubesphere.io/devops/pkg/models/gitops/chart"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
+	"kubesphere.io/devops/pkg/models/gitops/sops"
+	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+	"time"
 )
 
+var diffNotSupportedError = restful.NewError(http.StatusBadRequest,
+	"the diff preview is only supported by the FluxCD Kustomization applications")
+var invalidSecretManifestError = restful.NewError(http.StatusBadRequest,
+	"the manifest is not a valid Secret")
+
 func (h *handler) createApplication(req *restful.Request, res *restful.Response) {
 	var err error
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
@@ -58,12 +73,131 @@ func (h *handler) getClusters(req *restful.Request, res *restful.Response) {
 	common.Response(req, res, fluxClusters, err)
 }
 
+func (h *handler) diffApplication(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	app := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+	if app.Spec.FluxApp == nil || app.Spec.FluxApp.Spec.Config == nil ||
+		app.Spec.FluxApp.Spec.Config.Kustomization == nil {
+		common.Response(req, res, nil, diffNotSupportedError)
+		return
+	}
+
+	diffs, err := h.differ.Diff(req.Request.Context(), app)
+	common.Response(req, res, diffs, err)
+}
+
+func (h *handler) encryptSecret(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	credentialName := common.GetPathParameter(req, pathParameterCredential)
+
+	encryptRequest := &SecretEncryptRequest{}
+	secret := &v1.Secret{}
+	if err := req.ReadEntity(encryptRequest); err != nil ||
+		yaml.Unmarshal([]byte(encryptRequest.Manifest), secret) != nil || secret.Kind != "Secret" {
+		common.Response(req, res, nil, invalidSecretManifestError)
+		return
+	}
+
+	credential := &v1.Secret{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: credentialName}, credential); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+	if credential.Type != devopsv1alpha3.SecretTypeSOPS {
+		common.Response(req, res, nil, restful.NewError(http.StatusBadRequest,
+			fmt.Sprintf("credential %s is not a SOPS credential", credentialName)))
+		return
+	}
+
+	manifest, err := sops.Encrypt(secret, credential, time.Now())
+	if err != nil {
+		common.Response(req, res, nil, restful.NewError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	common.Response(req, res, &SecretEncryptResponse{Manifest: string(manifest)}, nil)
+}
+
+func (h *handler) listCharts(req *restful.Request, res *restful.Response) {
+	lister, err := h.getChartLister(req)
+	if err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+
+	charts, err := lister.ListCharts(req.Request.Context())
+	common.Response(req, res, charts, toChartRepositoryError(err))
+}
+
+func (h *handler) listChartVersions(req *restful.Request, res *restful.Response) {
+	lister, err := h.getChartLister(req)
+	if err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+
+	chartName := common.GetPathParameter(req, pathParameterChart)
+	versions, err := lister.ListVersions(req.Request.Context(), chartName)
+	common.Response(req, res, versions, toChartRepositoryError(err))
+}
+
+// toChartRepositoryError converts the error of a chart lister to the one with the proper status code
+func toChartRepositoryError(err error) error {
+	var notFoundErr *chart.NotFoundError
+	var forbiddenHostErr *chart.ForbiddenHostError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &notFoundErr):
+		return restful.NewError(http.StatusNotFound, err.Error())
+	case errors.As(err, &forbiddenHostErr):
+		return restful.NewError(http.StatusForbidden, forbiddenHostErr.Error())
+	default:
+		return restful.NewError(http.StatusBadGateway, err.Error())
+	}
+}
+
+// getChartLister creates the chart lister of the ChartRepository with its credential
+func (h *handler) getChartLister(req *restful.Request) (lister chart.Lister, err error) {
+	ctx := req.Request.Context()
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	repoName := common.GetPathParameter(req, pathParameterChartRepository)
+
+	repo := &v1alpha1.ChartRepository{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: repoName}, repo); err != nil {
+		return
+	}
+	var secret *v1.Secret
+	if repo.Spec.Secret != nil && repo.Spec.Secret.Name != "" {
+		secret = &v1.Secret{}
+		if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: repo.Spec.Secret.Name}, secret); err != nil {
+			return
+		}
+	}
+	if lister, err = chart.NewLister(repo, secret, h.httpClient); err != nil {
+		err = restful.NewError(http.StatusBadRequest, err.Error())
+	}
+	return
+}
+
 type handler struct {
 	*gitops.Handler
+	differ     diff.Differ
+	httpClient *http.Client
 }
 
+// chartRepositoryTimeout is the timeout of the requests to the chart repositories
+const chartRepositoryTimeout = 30 * time.Second
+
 func newHandler(options *common.Options, fluxOption *config.FluxCDOption) *handler {
 	return &handler{
-		gitops.NewHandler(options),
+		Handler:    gitops.NewHandler(options),
+		differ:     diff.NewKustomizationDiffer(options.GenericClient),
+		httpClient: chart.NewHTTPClient(chartRepositoryTimeout, fluxOption.ChartRepositoryAllowedHosts),
 	}
 }
@@ -16,20 +16,28 @@
 package fluxcd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"filippo.io/age"
 	"github.com/emicklei/go-restful"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	devopsv1alpha3 "kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/kapis/common"
 	"kubesphere.io/devops/pkg/kapis/gitops/v1alpha1/gitops"
+	"kubesphere.io/devops/pkg/models/gitops/chart"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
 	"net/http"
 	"net/http/httptest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
+	"time"
 )
 
 func Test_handler_applicationGet(t *testing.T) {
@@ -114,3 +122,237 @@ func Test_handler_applicationGet(t *testing.T) {
 		})
 	}
 }
+
+type fakeDiffer struct {
+	diffs []diff.ResourceDiff
+}
+
+func (d *fakeDiffer) Diff(context.Context, *v1alpha1.Application) ([]diff.ResourceDiff, error) {
+	return d.diffs, nil
+}
+
+func Test_handler_diffApplication(t *testing.T) {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	helmApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-app", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+			Config: &v1alpha1.FluxApplicationConfig{HelmRelease: &v1alpha1.HelmReleaseSpec{}},
+		}}},
+	}
+	kusApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "kus-app", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+			Config: &v1alpha1.FluxApplicationConfig{Kustomization: []*v1alpha1.KustomizationSpec{{}}},
+		}}},
+	}
+	differ := &fakeDiffer{diffs: []diff.ResourceDiff{{
+		Kind: "ConfigMap", Namespace: "default", Name: "fake", Diff: "fake-diff",
+	}}}
+
+	tests := []struct {
+		name             string
+		app              string
+		wantResponseCode int
+		wantResponse     string
+	}{{
+		name:             "not found",
+		app:              "not-found",
+		wantResponseCode: http.StatusNotFound,
+	}, {
+		name:             "not a Kustomization",
+		app:              "helm-app",
+		wantResponseCode: http.StatusBadRequest,
+		wantResponse:     diffNotSupportedError.Error(),
+	}, {
+		name:             "normal",
+		app:              "kus-app",
+		wantResponseCode: http.StatusOK,
+		wantResponse:     `"diff": "fake-diff"`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{
+				Handler: &gitops.Handler{Client: fake.NewFakeClientWithScheme(scheme.Scheme, helmApp, kusApp)},
+				differ:  differ,
+			}
+			req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/applications/"+tt.app+"/diff", nil))
+			req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+			req.PathParameters()[pathParameterApplication.Data().Name] = tt.app
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.diffApplication(req, resp)
+
+			assert.Equal(t, tt.wantResponseCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantResponse)
+		})
+	}
+}
+
+func Test_handler_encryptSecret(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	assert.Nil(t, err)
+	sopsCredential := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "sops", Namespace: "fake-namespace"},
+		Type:       devopsv1alpha3.SecretTypeSOPS,
+		Data:       map[string][]byte{devopsv1alpha3.SOPSAgeKey: []byte(identity.String())},
+	}
+	basicAuthCredential := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-auth", Namespace: "fake-namespace"},
+		Type:       devopsv1alpha3.SecretTypeBasicAuth,
+	}
+	secretManifest := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: demo\nstringData:\n  password: s3cr3t\n"
+
+	tests := []struct {
+		name             string
+		credential       string
+		manifest         string
+		wantResponseCode int
+		wantResponse     string
+	}{{
+		name:             "not a Secret",
+		credential:       "sops",
+		manifest:         "apiVersion: v1\nkind: ConfigMap\n",
+		wantResponseCode: http.StatusBadRequest,
+		wantResponse:     invalidSecretManifestError.Error(),
+	}, {
+		name:             "credential not found",
+		credential:       "not-found",
+		manifest:         secretManifest,
+		wantResponseCode: http.StatusNotFound,
+	}, {
+		name:             "not a SOPS credential",
+		credential:       "basic-auth",
+		manifest:         secretManifest,
+		wantResponseCode: http.StatusBadRequest,
+		wantResponse:     "credential basic-auth is not a SOPS credential",
+	}, {
+		name:             "normal",
+		credential:       "sops",
+		manifest:         secretManifest,
+		wantResponseCode: http.StatusOK,
+		wantResponse:     "ENC[AES256_GCM,data:",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{
+				Handler: &gitops.Handler{Client: fake.NewFakeClientWithScheme(scheme.Scheme, sopsCredential, basicAuthCredential)},
+			}
+			body, err := json.Marshal(&SecretEncryptRequest{Manifest: tt.manifest})
+			assert.Nil(t, err)
+			httpReq := httptest.NewRequest(http.MethodPost, "/credentials/"+tt.credential+"/encrypt", bytes.NewReader(body))
+			httpReq.Header.Set(restful.HEADER_ContentType, restful.MIME_JSON)
+			req := restful.NewRequest(httpReq)
+			req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+			req.PathParameters()[pathParameterCredential.Data().Name] = tt.credential
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.encryptSecret(req, resp)
+
+			assert.Equal(t, tt.wantResponseCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantResponse)
+		})
+	}
+}
+
+func Test_handler_listCharts(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	utilruntime.Must(v1.AddToScheme(schema))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, _, _ := r.BasicAuth(); username != "admin" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("entries:\n  nginx:\n  - name: nginx\n    version: 1.0.0\n  - name: nginx\n    version: 1.1.0\n"))
+	}))
+	defer server.Close()
+
+	repo := &v1alpha1.ChartRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ChartRepositorySpec{
+			URL:    server.URL,
+			Secret: &v1.LocalObjectReference{Name: "auth"},
+		},
+	}
+	noAuthRepo := &v1alpha1.ChartRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-auth", Namespace: "fake-namespace"},
+		Spec:       v1alpha1.ChartRepositorySpec{URL: server.URL},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "fake-namespace"},
+		Type:       devopsv1alpha3.SecretTypeBasicAuth,
+		Data:       map[string][]byte{devopsv1alpha3.BasicAuthUsernameKey: []byte("admin")},
+	}
+
+	tests := []struct {
+		name             string
+		repo             string
+		chart            string
+		forbidden        bool
+		wantResponseCode int
+		wantResponse     string
+	}{{
+		name:             "repository not found",
+		repo:             "not-found",
+		wantResponseCode: http.StatusNotFound,
+	}, {
+		name:             "list charts",
+		repo:             "repo",
+		wantResponseCode: http.StatusOK,
+		wantResponse:     `"latestVersion": "1.1.0"`,
+	}, {
+		name:             "list versions",
+		repo:             "repo",
+		chart:            "nginx",
+		wantResponseCode: http.StatusOK,
+		wantResponse:     `"version": "1.1.0"`,
+	}, {
+		name:             "chart not found",
+		repo:             "repo",
+		chart:            "redis",
+		wantResponseCode: http.StatusNotFound,
+		wantResponse:     "chart redis is not found",
+	}, {
+		name:             "unauthorized",
+		repo:             "no-auth",
+		wantResponseCode: http.StatusBadGateway,
+		wantResponse:     "unexpected status code 401",
+	}, {
+		name:             "the repository is in the internal network",
+		repo:             "repo",
+		forbidden:        true,
+		wantResponseCode: http.StatusForbidden,
+		wantResponse:     "host 127.0.0.1 is not allowed to access",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := server.Client()
+			if tt.forbidden {
+				httpClient = chart.NewHTTPClient(time.Second, nil)
+			}
+			h := &handler{
+				Handler: &gitops.Handler{Client: fake.NewFakeClientWithScheme(schema, repo.DeepCopy(),
+					noAuthRepo.DeepCopy(), secret.DeepCopy())},
+				httpClient: httpClient,
+			}
+			req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/charts", nil))
+			req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+			req.PathParameters()[pathParameterChartRepository.Data().Name] = tt.repo
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			if tt.chart == "" {
+				h.listCharts(req, resp)
+			} else {
+				req.PathParameters()[pathParameterChart.Data().Name] = tt.chart
+				h.listChartVersions(req, resp)
+			}
+
+			assert.Equal(t, tt.wantResponseCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantResponse)
+		})
+	}
+}
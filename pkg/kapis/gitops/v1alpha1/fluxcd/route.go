@@ -21,6 +21,8 @@ import (
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
 	"kubesphere.io/devops/pkg/config"
 	"kubesphere.io/devops/pkg/kapis/common"
+	"kubesphere.io/devops/pkg/models/gitops/chart"
+	"kubesphere.io/devops/pkg/models/gitops/diff"
 	"net/http"
 )
 
@@ -32,6 +34,9 @@ var (
 	cascadeQueryParam        = restful.QueryParameter("cascade",
 		"Delete both the app and its resources, rather than only the application if cascade is true").
 		DefaultValue("false").DataType("bool")
+	pathParameterCredential      = restful.PathParameter("credential", "The name of the SOPS credential")
+	pathParameterChartRepository = restful.PathParameter("chartrepository", "The name of the ChartRepository")
+	pathParameterChart           = restful.PathParameter("chart", "The name of the chart")
 )
 
 // ApplicationPageResult is the model of page result of Applications.
@@ -40,6 +45,18 @@ type ApplicationPageResult struct {
 	TotalItems int                    `json:"totalItems"`
 }
 
+// SecretEncryptRequest is the request to encrypt a plaintext Secret manifest.
+type SecretEncryptRequest struct {
+	// Manifest is the plaintext Secret manifest in YAML or JSON
+	Manifest string `json:"manifest"`
+}
+
+// SecretEncryptResponse is the response of the Secret encryption.
+type SecretEncryptResponse struct {
+	// Manifest is the SOPS encrypted Secret manifest in YAML
+	Manifest string `json:"manifest"`
+}
+
 // RegisterRoutes is for registering Argo CD Application routes into WebService.
 func RegisterRoutes(service *restful.WebService, options *common.Options, fluxOption *config.FluxCDOption) {
 	handler := newHandler(options, fluxOption)
@@ -89,6 +106,36 @@ func RegisterRoutes(service *restful.WebService, options *common.Options, fluxOp
 		Doc("Create an application").
 		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
 
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/diff").
+		To(handler.diffApplication).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Doc("Preview the changes of a particular application by the server-side dry-run of its Kustomizations").
+		Returns(http.StatusOK, api.StatusOK, []diff.ResourceDiff{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/credentials/{credential}/encrypt").
+		To(handler.encryptSecret).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterCredential).
+		Reads(SecretEncryptRequest{}).
+		Doc("Encrypt the data of a plaintext Secret manifest with a SOPS credential, then it could be committed to Git").
+		Returns(http.StatusOK, api.StatusOK, SecretEncryptResponse{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/chartrepositories/{chartrepository}/charts").
+		To(handler.listCharts).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterChartRepository).
+		Doc("List the charts of a particular ChartRepository").
+		Returns(http.StatusOK, api.StatusOK, []chart.Chart{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/chartrepositories/{chartrepository}/charts/{chart}/versions").
+		To(handler.listChartVersions).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterChartRepository).
+		Param(pathParameterChart).
+		Doc("List the versions of a chart in a particular ChartRepository, the latest version goes first").
+		Returns(http.StatusOK, api.StatusOK, []chart.Version{}))
+
 	service.Route(service.GET("/clusters").
 		To(handler.getClusters).
 		Doc("Get the clusters list").
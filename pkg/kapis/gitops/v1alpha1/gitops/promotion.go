@@ -0,0 +1,216 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilretry "k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/apiserver/query"
+	apiserverrequest "kubesphere.io/devops/pkg/apiserver/request"
+	"kubesphere.io/devops/pkg/kapis/common"
+	"kubesphere.io/devops/pkg/models/resources/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pathParameterPromotion is a path parameter definition for promotion.
+var pathParameterPromotion = restful.PathParameter("promotion", "The promotion name")
+
+var noPendingPromotionError = restful.NewError(http.StatusBadRequest, "there is no promotion waiting for the approval")
+var unauthenticatedError = restful.NewError(http.StatusUnauthorized, "unauthenticated request")
+
+// PromotionPageResult is the model of page result of Promotions.
+type PromotionPageResult struct {
+	Items      []v1alpha1.Promotion `json:"items"`
+	TotalItems int                  `json:"totalItems"`
+}
+
+// RegisterPromotionRoutes registers the Promotion routes into WebService, they work with both Argo CD and FluxCD.
+func RegisterPromotionRoutes(service *restful.WebService, options *common.Options) {
+	handler := NewHandler(options)
+
+	service.Route(service.GET("/namespaces/{namespace}/promotions").
+		To(handler.PromotionList).
+		Param(common.NamespacePathParameter).
+		Param(common.PageQueryParameter).
+		Param(common.LimitQueryParameter).
+		Param(common.NameQueryParameter).
+		Param(common.SortByQueryParameter).
+		Param(common.AscendingQueryParameter).
+		Doc("Search promotions").
+		Returns(http.StatusOK, api.StatusOK, PromotionPageResult{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/promotions").
+		To(handler.CreatePromotion).
+		Param(common.NamespacePathParameter).
+		Reads(v1alpha1.Promotion{}).
+		Doc("Create a promotion from an application to another one").
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/promotions/{promotion}").
+		To(handler.GetPromotion).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Doc("Get a particular promotion, the status contains the pending promotion and the history").
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.DELETE("/namespaces/{namespace}/promotions/{promotion}").
+		To(handler.DelPromotion).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Doc("Delete a particular promotion").
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/promotions/{promotion}/approve").
+		To(handler.ApprovePromotion).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Doc("Approve the pending promotion").
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/promotions/{promotion}/reject").
+		To(handler.RejectPromotion).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Doc("Reject the pending promotion").
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+}
+
+// PromotionList lists the Promotions in a namespace
+func (h *Handler) PromotionList(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+
+	promotionList := &v1alpha1.PromotionList{}
+	if err := h.List(context.Background(), promotionList, client.InNamespace(namespace)); err != nil {
+		common.Response(req, res, promotionList, err)
+		return
+	}
+
+	objs := make([]runtime.Object, len(promotionList.Items))
+	for i := range promotionList.Items {
+		objs[i] = &promotionList.Items[i]
+	}
+	queryParam := query.ParseQueryParameter(req)
+	list := v1alpha3.DefaultList(objs, queryParam, v1alpha3.DefaultCompare(), v1alpha3.DefaultFilter(), nil)
+	common.Response(req, res, list, nil)
+}
+
+// CreatePromotion creates a Promotion after checking the source and target Applications
+func (h *Handler) CreatePromotion(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+
+	promotion := &v1alpha1.Promotion{}
+	err := req.ReadEntity(promotion)
+	if err == nil {
+		promotion.Namespace = namespace
+		// the status, including the decision of the reviewer, is only written by the controller and the review APIs
+		promotion.Status = v1alpha1.PromotionStatus{}
+		if err = h.validatePromotion(promotion); err == nil {
+			err = h.Create(context.Background(), promotion)
+		}
+	}
+	common.Response(req, res, promotion, err)
+}
+
+// GetPromotion returns a Promotion
+func (h *Handler) GetPromotion(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterPromotion)
+
+	promotion := &v1alpha1.Promotion{}
+	err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, promotion)
+	common.Response(req, res, promotion, err)
+}
+
+// DelPromotion deletes a Promotion, the Applications are not affected
+func (h *Handler) DelPromotion(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterPromotion)
+
+	ctx := context.Background()
+	promotion := &v1alpha1.Promotion{}
+	err := h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, promotion)
+	if err == nil {
+		err = h.Delete(ctx, promotion)
+	}
+	common.Response(req, res, promotion, err)
+}
+
+// ApprovePromotion approves the pending promotion as the current user
+func (h *Handler) ApprovePromotion(req *restful.Request, res *restful.Response) {
+	h.reviewPromotion(req, res, true)
+}
+
+// RejectPromotion rejects the pending promotion as the current user
+func (h *Handler) RejectPromotion(req *restful.Request, res *restful.Response) {
+	h.reviewPromotion(req, res, false)
+}
+
+// reviewPromotion records the decision and the authenticated user in the status of the pending promotion,
+// then the controller promotes or rejects it
+func (h *Handler) reviewPromotion(req *restful.Request, res *restful.Response, approved bool) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterPromotion)
+
+	currentUser, ok := apiserverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil || currentUser.GetName() == "" {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	ctx := context.Background()
+	promotion := &v1alpha1.Promotion{}
+	err := utilretry.RetryOnConflict(utilretry.DefaultRetry, func() (err error) {
+		if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, promotion); err != nil {
+			return
+		}
+		if promotion.Status.Pending == nil {
+			return noPendingPromotionError
+		}
+		promotion.Status.Pending.Approved = &approved
+		promotion.Status.Pending.Reviewer = currentUser.GetName()
+		return h.Status().Update(ctx, promotion)
+	})
+	common.Response(req, res, promotion, err)
+}
+
+// validatePromotion makes sure the source and target Applications exist and use the same engine
+func (h *Handler) validatePromotion(promotion *v1alpha1.Promotion) error {
+	if promotion.Spec.Source == "" || promotion.Spec.Target == "" || promotion.Spec.Source == promotion.Spec.Target {
+		return restful.NewError(http.StatusBadRequest, "the source and target applications are required and should be different")
+	}
+
+	var kinds []v1alpha1.Engine
+	for _, name := range []string{promotion.Spec.Source, promotion.Spec.Target} {
+		app := &v1alpha1.Application{}
+		if err := h.Get(context.Background(), types.NamespacedName{Namespace: promotion.Namespace, Name: name}, app); err != nil {
+			return err
+		}
+		kinds = append(kinds, app.Spec.Kind)
+	}
+	if kinds[0] != kinds[1] {
+		return restful.NewError(http.StatusBadRequest, fmt.Sprintf("the source application uses %q, but the target application uses %q",
+			kinds[0], kinds[1]))
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"kubesphere.io/devops/pkg/api"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/apiserver/request"
+	"kubesphere.io/devops/pkg/apiserver/runtime"
+	"kubesphere.io/devops/pkg/kapis/common"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPromotionRoutes(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newApp := func(name string, kind v1alpha1.Engine) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       v1alpha1.ApplicationSpec{Kind: kind},
+		}
+	}
+	newPromotion := func(name string, pending *v1alpha1.PromotionRecord) *v1alpha1.Promotion {
+		return &v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       v1alpha1.PromotionSpec{Source: "staging", Target: "prod"},
+			Status:     v1alpha1.PromotionStatus{Pending: pending},
+		}
+	}
+	pending := &v1alpha1.PromotionRecord{Revision: "v2", Phase: v1alpha1.PromotionWaitingForApproval}
+
+	tests := []struct {
+		name       string
+		method     string
+		uri        string
+		body       string
+		user       string
+		objects    []client.Object
+		expectCode int
+		verify     func(t *testing.T, c client.Client, body []byte)
+	}{{
+		name:   "list promotions",
+		method: http.MethodGet,
+		uri:    "/namespaces/ns/promotions",
+		objects: []client.Object{newPromotion("a", nil), newPromotion("b", nil), func() client.Object {
+			promotion := newPromotion("c", nil)
+			promotion.Namespace = "other"
+			return promotion
+		}()},
+		expectCode: http.StatusOK,
+		verify: func(t *testing.T, _ client.Client, body []byte) {
+			result := &api.ListResult{}
+			assert.Nil(t, json.Unmarshal(body, result))
+			assert.Equal(t, 2, result.TotalItems)
+		},
+	}, {
+		name:       "create a promotion",
+		method:     http.MethodPost,
+		uri:        "/namespaces/ns/promotions",
+		body:       `{"metadata":{"name":"staging-to-prod"},"spec":{"source":"staging","target":"prod","requireApproval":true}}`,
+		objects:    []client.Object{newApp("staging", v1alpha1.ArgoCD), newApp("prod", v1alpha1.ArgoCD)},
+		expectCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, _ []byte) {
+			promotion := &v1alpha1.Promotion{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "staging-to-prod"}, promotion))
+			assert.True(t, promotion.Spec.RequireApproval)
+		},
+	}, {
+		name:       "the target application does not exist",
+		method:     http.MethodPost,
+		uri:        "/namespaces/ns/promotions",
+		body:       `{"metadata":{"name":"staging-to-prod"},"spec":{"source":"staging","target":"prod"}}`,
+		objects:    []client.Object{newApp("staging", v1alpha1.ArgoCD)},
+		expectCode: http.StatusNotFound,
+	}, {
+		name:       "the applications use different engines",
+		method:     http.MethodPost,
+		uri:        "/namespaces/ns/promotions",
+		body:       `{"metadata":{"name":"staging-to-prod"},"spec":{"source":"staging","target":"prod"}}`,
+		objects:    []client.Object{newApp("staging", v1alpha1.ArgoCD), newApp("prod", v1alpha1.FluxCD)},
+		expectCode: http.StatusBadRequest,
+	}, {
+		name:       "promote to itself",
+		method:     http.MethodPost,
+		uri:        "/namespaces/ns/promotions",
+		body:       `{"metadata":{"name":"staging-to-staging"},"spec":{"source":"staging","target":"staging"}}`,
+		objects:    []client.Object{newApp("staging", v1alpha1.ArgoCD)},
+		expectCode: http.StatusBadRequest,
+	}, {
+		name:       "get a promotion",
+		method:     http.MethodGet,
+		uri:        "/namespaces/ns/promotions/a",
+		objects:    []client.Object{newPromotion("a", pending)},
+		expectCode: http.StatusOK,
+		verify: func(t *testing.T, _ client.Client, body []byte) {
+			promotion := &v1alpha1.Promotion{}
+			assert.Nil(t, json.Unmarshal(body, promotion))
+			assert.Equal(t, "v2", promotion.Status.Pending.Revision)
+		},
+	}, {
+		name:       "delete a promotion",
+		method:     http.MethodDelete,
+		uri:        "/namespaces/ns/promotions/a",
+		objects:    []client.Object{newPromotion("a", nil)},
+		expectCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, _ []byte) {
+			err := c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "a"}, &v1alpha1.Promotion{})
+			assert.NotNil(t, err)
+		},
+	}, {
+		name:       "approve the pending promotion",
+		method:     http.MethodPost,
+		uri:        "/namespaces/ns/promotions/a/approve",
+		user:       "admin",
+		objects:    []client.Object{newPromotion("a", pending)},
+		expectCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, _ []byte) {
+			promotion := &v1alpha1.Promotion{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "a"}, promotion))
+			if assert.NotNil(t, promotion.Status.Pending.Approved) {
+				assert.True(t, *promotion.Status.Pending.Approved)
+			}
+			assert.Equal(t, "admin", promotion.Status.Pending.Reviewer)
+		},
+	}, {
+		name:   "reject the pending promotion",
+		method: http.MethodPost,
+		uri:    "/namespaces/ns/promotions/a/reject",
+		user:   "admin",
+		objects: []client.Object{func() client.Object {
+			promotion := newPromotion("a", pending)
+			approved := true
+			promotion.Status.Pending.Approved = &approved
+			promotion.Status.Pending.Reviewer = "someone"
+			return promotion
+		}()},
+		expectCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, _ []byte) {
+			promotion := &v1alpha1.Promotion{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "a"}, promotion))
+			if assert.NotNil(t, promotion.Status.Pending.Approved) {
+				assert.False(t, *promotion.Status.Pending.Approved)
+			}
+			assert.Equal(t, "admin", promotion.Status.Pending.Reviewer)
+		},
+	}, {
+		name:       "no pending promotion",
+		method:     http.MethodPost,
+		uri:        "/namespaces/ns/promotions/a/approve",
+		user:       "admin",
+		objects:    []client.Object{newPromotion("a", nil)},
+		expectCode: http.StatusBadRequest,
+	}, {
+		name:       "unauthenticated",
+		method:     http.MethodPost,
+		uri:        "/namespaces/ns/promotions/a/approve",
+		objects:    []client.Object{newPromotion("a", pending)},
+		expectCode: http.StatusUnauthorized,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.objects...).Build()
+			service := runtime.NewWebService(v1alpha1.GroupVersion)
+			RegisterPromotionRoutes(service, &common.Options{GenericClient: c})
+			container := restful.NewContainer()
+			container.Add(service)
+
+			httpRequest := httptest.NewRequest(tt.method, "/kapis/gitops.kubesphere.io/v1alpha1"+tt.uri,
+				strings.NewReader(tt.body))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			if tt.user != "" {
+				httpRequest = httpRequest.WithContext(request.WithUser(httpRequest.Context(), &user.DefaultInfo{Name: tt.user}))
+			}
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.expectCode, httpWriter.Code, httpWriter.Body.String())
+			if tt.verify != nil {
+				tt.verify(t, c, httpWriter.Body.Bytes())
+			}
+		})
+	}
+}
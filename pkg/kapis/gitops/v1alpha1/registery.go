@@ -24,10 +24,14 @@ import (
 	"kubesphere.io/devops/pkg/kapis/common"
 	"kubesphere.io/devops/pkg/kapis/gitops/v1alpha1/argocd"
 	"kubesphere.io/devops/pkg/kapis/gitops/v1alpha1/fluxcd"
+	"kubesphere.io/devops/pkg/kapis/gitops/v1alpha1/gitops"
 )
 
 // TODO perhaps we can find a better way to declaim the permission needs of the apiserver
 //+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;update;delete;create;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions,verbs=get;list;update;delete;create;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions/status,verbs=get;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=chartrepositories,verbs=get;list;update;delete;create;watch
 
 // AddToContainer adds web services into web service container.
 func AddToContainer(container *restful.Container, options *common.Options, argoOption *config.ArgoCDOption, fluxOption *config.FluxCDOption) []*restful.WebService {
@@ -45,6 +49,7 @@ func AddToContainer(container *restful.Container, options *common.Options, argoO
 		default:
 			return nil
 		}
+		gitops.RegisterPromotionRoutes(service, options)
 		container.Add(service)
 	}
 	return services
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/indexers"
+	"kubesphere.io/devops/pkg/utils/sliceutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// CredentialUsage is an object which references a credential
+type CredentialUsage struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+// credentialReferenceLists are the lists of the objects which might reference the credentials,
+// see also indexers.CredentialReferenceObjects
+var credentialReferenceLists = []func() client.ObjectList{
+	func() client.ObjectList { return &v1alpha3.PipelineList{} },
+	func() client.ObjectList { return &v1alpha3.GitRepositoryList{} },
+	func() client.ObjectList { return &v1alpha1.ImageUpdaterList{} },
+	func() client.ObjectList { return &v1alpha1.ChartRepositoryList{} },
+	func() client.ObjectList { return &v1alpha3.ClusterStepTemplateList{} },
+}
+
+// GetCredentialUsages returns the objects which reference the specified credential.
+// The objects are found by the field indexer created by indexers.CreateCredentialReferenceIndexer,
+// the objects whose CRDs are not installed are skipped.
+func GetCredentialUsages(ctx context.Context, c client.Client, namespace, name string) (usages []CredentialUsage, err error) {
+	key := namespace + "/" + name
+	usages = []CredentialUsage{}
+	for _, newList := range credentialReferenceLists {
+		list := newList()
+		// objects might reference the credentials in other namespaces
+		if err = c.List(ctx, list, client.MatchingFields{v1alpha3.CredentialReferenceField: key}); err != nil {
+			if meta.IsNoMatchError(err) {
+				err = nil
+				continue
+			}
+			return
+		}
+
+		var items []runtime.Object
+		if items, err = meta.ExtractList(list); err != nil {
+			return
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok || !sliceutil.HasString(indexers.ExtractCredentialReferences(obj), key) {
+				// the field selector might be ignored by the client which doesn't support it
+				continue
+			}
+			gvk, gvkErr := apiutil.GVKForObject(obj, c.Scheme())
+			if gvkErr != nil {
+				err = gvkErr
+				return
+			}
+			usages = append(usages, CredentialUsage{
+				APIVersion: gvk.GroupVersion().String(),
+				Kind:       gvk.Kind,
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+			})
+		}
+	}
+	return
+}
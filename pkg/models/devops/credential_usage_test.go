@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetCredentialUsages(t *testing.T) {
+	schema := runtime.NewScheme()
+	assert.Nil(t, v1alpha3.AddToScheme(schema))
+	assert.Nil(t, v1alpha1.AddToScheme(schema))
+
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec: v1alpha3.PipelineSpec{
+			Type: v1alpha3.MultiBranchPipelineType,
+			MultiBranchPipeline: &v1alpha3.MultiBranchPipeline{
+				GitSource: &v1alpha3.GitSource{CredentialId: "git"},
+			},
+		},
+	}
+	repo := &v1alpha3.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "repo"},
+		Spec:       v1alpha3.GitRepositorySpec{Secret: &v1.SecretReference{Name: "git"}},
+	}
+	otherRepo := &v1alpha3.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "other"},
+		Spec:       v1alpha3.GitRepositorySpec{Secret: &v1.SecretReference{Name: "other"}},
+	}
+	updater := &v1alpha1.ImageUpdater{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "updater"},
+		Spec: v1alpha1.ImageUpdaterSpec{
+			Argo: &v1alpha1.ArgoImageUpdater{Secrets: map[string]string{"nginx": "ns/git"}},
+		},
+	}
+
+	stepTemplate := &v1alpha3.ClusterStepTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "step-template"},
+		Spec: v1alpha3.StepTemplateSpec{Secret: v1alpha3.SecretInStep{
+			Type: "basic-auth", Namespace: "ns", Name: "git"}},
+	}
+
+	c := fake.NewFakeClientWithScheme(schema, pipeline, repo, otherRepo, updater, stepTemplate)
+
+	usages, err := GetCredentialUsages(context.TODO(), c, "ns", "git")
+	assert.Nil(t, err)
+	assert.Equal(t, []CredentialUsage{{
+		APIVersion: v1alpha3.GroupVersion.String(),
+		Kind:       "Pipeline",
+		Namespace:  "ns",
+		Name:       "pipeline",
+	}, {
+		APIVersion: v1alpha3.GroupVersion.String(),
+		Kind:       "GitRepository",
+		Namespace:  "ns",
+		Name:       "repo",
+	}, {
+		APIVersion: v1alpha1.GroupVersion.String(),
+		Kind:       "ImageUpdater",
+		Namespace:  "apps",
+		Name:       "updater",
+	}, {
+		APIVersion: v1alpha3.GroupVersion.String(),
+		Kind:       "ClusterStepTemplate",
+		Name:       "step-template",
+	}}, usages)
+
+	usages, err = GetCredentialUsages(context.TODO(), c, "ns", "unused")
+	assert.Nil(t, err)
+	assert.Empty(t, usages)
+}
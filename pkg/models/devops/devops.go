@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"kubesphere.io/devops/pkg/constants"
 
@@ -72,6 +73,7 @@ type DevopsOperator interface {
 	GetCredentialObj(projectName string, secretName string) (*v1.Secret, error)
 	DeleteCredentialObj(projectName string, secretName string) error
 	UpdateCredentialObj(projectName string, secret *v1.Secret) (*v1.Secret, error)
+	RotateCredentialObj(projectName string, secretName string, data *v1.Secret) (*v1.Secret, error)
 	ListCredentialObj(projectName string, query *query.Query) (api.ListResult, error)
 
 	CheckPipelineName(projectName, pipelineName string, req *http.Request) (map[string]interface{}, error)
@@ -96,7 +98,7 @@ type DevopsOperator interface {
 	ReplayBranchPipeline(projectName, pipelineName, branchName, runId string, req *http.Request) (*devops.ReplayPipeline, error)
 	RunBranchPipeline(projectName, pipelineName, branchName string, req *http.Request) (*devops.RunPipeline, error)
 	GetBranchArtifacts(projectName, pipelineName, branchName, runId string, req *http.Request) ([]devops.Artifacts, error)
-	GetBranchRunLog(projectName, pipelineName, branchName, runId string, req *http.Request) ([]byte, error)
+	GetBranchRunLog(projectName, pipelineName, branchName, runId string, req *http.Request) ([]byte, http.Header, error)
 	GetBranchStepLog(projectName, pipelineName, branchName, runId, nodeId, stepId string, req *http.Request) ([]byte, http.Header, error)
 	GetBranchNodeSteps(projectName, pipelineName, branchName, runId, nodeId string, req *http.Request) ([]devops.NodeSteps, error)
 	GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId string, req *http.Request) ([]devops.BranchPipelineRunNodes, error)
@@ -181,6 +183,8 @@ func (d devopsOperator) CreateDevOpsProject(workspace string, project *v1alpha3.
 	}
 	project.Name = ""
 	project.Labels[constants.WorkspaceLabelKey] = workspace
+	// the limits are managed by the cluster admins
+	project.Spec.Limits = nil
 
 	// set annotations
 	if project.Annotations == nil {
@@ -249,7 +253,13 @@ func (d devopsOperator) DeleteDevOpsProject(workspace string, projectName string
 	return d.ksclient.DevopsV1alpha3().DevOpsProjects().Delete(d.context, projectName, *metav1.NewDeleteOptions(0))
 }
 
+// UpdateDevOpsProject updates the DevOps project, its limits are kept since they are managed by the cluster admins
 func (d devopsOperator) UpdateDevOpsProject(workspace string, project *v1alpha3.DevOpsProject) (*v1alpha3.DevOpsProject, error) {
+	stored, err := d.ksclient.DevopsV1alpha3().DevOpsProjects().Get(d.context, project.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	project.Spec.Limits = stored.Spec.Limits
 	if project.Annotations == nil {
 		project.Annotations = make(map[string]string)
 	}
@@ -392,6 +402,12 @@ func (d devopsOperator) CreateCredentialObj(projectName string, secret *v1.Secre
 	if err != nil {
 		return nil, err
 	}
+	if err = devopsv1alpha3.ValidateCredentialRotation(secret); err != nil {
+		return nil, err
+	}
+	if err = devopsv1alpha3.ValidateCredentialData(secret); err != nil {
+		return nil, err
+	}
 	if secret.Annotations == nil {
 		secret.Annotations = map[string]string{}
 	}
@@ -430,6 +446,12 @@ func (d devopsOperator) UpdateCredentialObj(projectName string, secret *v1.Secre
 	if err != nil {
 		return nil, err
 	}
+	if err = devopsv1alpha3.ValidateCredentialRotation(secret); err != nil {
+		return nil, err
+	}
+	if err = devopsv1alpha3.ValidateCredentialData(secret); err != nil {
+		return nil, err
+	}
 	if secret.Annotations == nil {
 		secret.Annotations = map[string]string{}
 	}
@@ -443,6 +465,77 @@ func (d devopsOperator) UpdateCredentialObj(projectName string, secret *v1.Secre
 	}
 }
 
+// RotateCredentialObj merges the data and stringData of the given secret into the data of a credential,
+// then the credential is synchronized to Jenkins again by the devopscredential controller.
+// The data of an external credential is rotated in its backend, so it is only synchronized again.
+func (d devopsOperator) RotateCredentialObj(projectName string, secretName string, data *v1.Secret) (*v1.Secret, error) {
+	projectObj, err := d.ksclient.DevopsV1alpha3().DevOpsProjects().Get(d.context, projectName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	secret, err := d.k8sclient.CoreV1().Secrets(projectObj.Status.AdminNamespace).Get(d.context, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	hasData := data != nil && (len(data.Data) > 0 || len(data.StringData) > 0)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	if devopsv1alpha3.IsExternalCredential(secret) {
+		if hasData {
+			return nil, errors.NewBadRequest(fmt.Sprintf("the data of credential %s is stored in the external "+
+				"secret backend %s, please rotate it there", secretName,
+				secret.Annotations[devopsv1alpha3.CredentialSourceAnnoKey]))
+		}
+	} else {
+		if !hasData {
+			return nil, errors.NewBadRequest(fmt.Sprintf("the new data of credential %s is required", secretName))
+		}
+		rotatedData, changed := mergeCredentialData(secret.Data, data)
+		if !changed {
+			return nil, errors.NewBadRequest(fmt.Sprintf("the new data of credential %s is the same as the current one",
+				secretName))
+		}
+		secret.Data = rotatedData
+		if err = devopsv1alpha3.ValidateCredentialData(secret); err != nil {
+			return nil, err
+		}
+		secret.Annotations[devopsv1alpha3.CredentialLastRotatedAnnoKey] = time.Now().UTC().Format(time.RFC3339)
+	}
+	// make sure the credential is synchronized to Jenkins even if its data is not changed
+	delete(secret.Annotations, devopsv1alpha3.DevOpsCredentialDataHash)
+	secret.Annotations[devopsv1alpha3.CredentialAutoSyncAnnoKey] = "true"
+	secret.Annotations[devopsv1alpha3.CredentialSyncStatusAnnoKey] = StatusPending
+	secret.Annotations[devopsv1alpha3.CredentialSyncTimeAnnoKey] = GetSyncNowTime()
+	if secret, err = d.k8sclient.CoreV1().Secrets(projectObj.Status.AdminNamespace).Update(d.context, secret, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+	return secretutil.MaskCredential(secret), nil
+}
+
+// mergeCredentialData returns the current data with the data and stringData of the given secret, the stringData wins
+// as the API server does. The keys which are not given are kept.
+func mergeCredentialData(current map[string][]byte, data *v1.Secret) (merged map[string][]byte, changed bool) {
+	merged = make(map[string][]byte, len(current)+len(data.Data)+len(data.StringData))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range data.Data {
+		merged[key] = value
+	}
+	for key, value := range data.StringData {
+		merged[key] = []byte(value)
+	}
+	for key, value := range merged {
+		if old, ok := current[key]; !ok || !bytes.Equal(old, value) {
+			changed = true
+			break
+		}
+	}
+	return
+}
+
 func (d devopsOperator) ListCredentialObj(projectName string, query *query.Query) (api.ListResult, error) {
 	projectObj, err := d.ksclient.DevopsV1alpha3().DevOpsProjects().Get(d.context, projectName, metav1.GetOptions{})
 	if err != nil {
@@ -722,15 +815,15 @@ func (d devopsOperator) GetBranchArtifacts(projectName, pipelineName, branchName
 	return res, err
 }
 
-func (d devopsOperator) GetBranchRunLog(projectName, pipelineName, branchName, runId string, req *http.Request) ([]byte, error) {
+func (d devopsOperator) GetBranchRunLog(projectName, pipelineName, branchName, runId string, req *http.Request) ([]byte, http.Header, error) {
 
-	res, err := d.devopsClient.GetBranchRunLog(projectName, pipelineName, branchName, runId, convertToHttpParameters(req))
+	res, header, err := d.devopsClient.GetBranchRunLog(projectName, pipelineName, branchName, runId, convertToHttpParameters(req))
 	if err != nil {
 		klog.Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	return res, err
+	return res, header, err
 }
 
 func (d devopsOperator) GetBranchStepLog(projectName, pipelineName, branchName, runId, nodeId, stepId string, req *http.Request) ([]byte, http.Header, error) {
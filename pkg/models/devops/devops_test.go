@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
@@ -220,6 +221,9 @@ func Test_devopsOperator_CreateDevOpsProject(t *testing.T) {
 				ObjectMeta: v1.ObjectMeta{
 					GenerateName: "devops",
 				},
+				Spec: v1alpha3.DevOpsProjectSpec{
+					Limits: &v1alpha3.DevOpsProjectLimits{MaxPipelines: 1000},
+				},
 			},
 		},
 		wantErr: false,
@@ -238,6 +242,7 @@ func Test_devopsOperator_CreateDevOpsProject(t *testing.T) {
 
 					assert.NotNil(t, item.Labels)
 					assert.Equal(t, args.workspace, item.Labels[constants.WorkspaceLabelKey])
+					assert.Nil(t, item.Spec.Limits, "the limits are managed by the cluster admins")
 					return true
 				}
 			}
@@ -330,6 +335,47 @@ func Test_devopsOperator_GetDevOpsProject(t *testing.T) {
 	}
 }
 
+func Test_devopsOperator_UpdateDevOpsProject(t *testing.T) {
+	limits := &v1alpha3.DevOpsProjectLimits{MaxConcurrentPipelineRuns: 2, MaxPipelines: 20}
+	stored := &v1alpha3.DevOpsProject{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake", Labels: map[string]string{constants.WorkspaceLabelKey: "ws"}},
+		Spec:       v1alpha3.DevOpsProjectSpec{Limits: limits.DeepCopy()},
+	}
+
+	tests := []struct {
+		name   string
+		limits *v1alpha3.DevOpsProjectLimits
+	}{{
+		name: "remove the limits",
+	}, {
+		name:   "raise the limits",
+		limits: &v1alpha3.DevOpsProjectLimits{MaxConcurrentPipelineRuns: 100, MaxPipelines: 1000},
+	}, {
+		name:   "keep the limits",
+		limits: limits.DeepCopy(),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := devopsOperator{
+				ksclient: fakeclientset.NewSimpleClientset(stored.DeepCopy()),
+				context:  context.TODO(),
+			}
+			project := stored.DeepCopy()
+			project.Annotations = map[string]string{"description": "updated"}
+			project.Spec.Limits = tt.limits
+			updated, err := d.UpdateDevOpsProject("ws", project)
+			assert.Nil(t, err)
+			assert.Equal(t, "updated", updated.Annotations["description"])
+			assert.Equal(t, StatusPending, updated.Annotations[v1alpha3.DevOpeProjectSyncStatusAnnoKey])
+			assert.Equal(t, limits, updated.Spec.Limits)
+		})
+	}
+
+	d := devopsOperator{ksclient: fakeclientset.NewSimpleClientset(), context: context.TODO()}
+	_, err := d.UpdateDevOpsProject("ws", stored.DeepCopy())
+	assert.True(t, errors.IsNotFound(err))
+}
+
 func Test_devopsOperator_UpdateJenkinsfile(t *testing.T) {
 	pipeline := &v1alpha3.Pipeline{}
 	pipeline.SetNamespace("ns")
@@ -673,3 +719,122 @@ func Test_devopsOperator_CheckDevopsName(t *testing.T) {
 		})
 	}
 }
+
+func Test_devopsOperator_RotateCredentialObj(t *testing.T) {
+	project := &v1alpha3.DevOpsProject{}
+	project.SetName("ns")
+	project.Status.AdminNamespace = "ns"
+
+	secret := &v12.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "fake",
+			Annotations: map[string]string{
+				v1alpha3.DevOpsCredentialDataHash:    "hash",
+				v1alpha3.CredentialSyncStatusAnnoKey: StatusSuccessful,
+			},
+		},
+		Type: v1alpha3.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			v1alpha3.BasicAuthUsernameKey: []byte("admin"),
+			v1alpha3.BasicAuthPasswordKey: []byte("old"),
+		},
+	}
+	externalSecret := secret.DeepCopy()
+	externalSecret.Annotations[v1alpha3.CredentialSourceAnnoKey] = v1alpha3.CredentialSourceVault
+	accessKeySecret := secret.DeepCopy()
+	accessKeySecret.Type = v1alpha3.SecretTypeAccessKey
+
+	tests := []struct {
+		name            string
+		secret          *v12.Secret
+		data            *v12.Secret
+		wantData        map[string][]byte
+		wantLastRotated bool
+		wantErr         bool
+	}{{
+		name:   "rotate with new data",
+		secret: secret.DeepCopy(),
+		data: &v12.Secret{
+			Data: map[string][]byte{v1alpha3.BasicAuthPasswordKey: []byte("new")},
+		},
+		wantData: map[string][]byte{
+			v1alpha3.BasicAuthUsernameKey: []byte("admin"),
+			v1alpha3.BasicAuthPasswordKey: []byte("new"),
+		},
+		wantLastRotated: true,
+	}, {
+		name:   "the stringData wins",
+		secret: secret.DeepCopy(),
+		data: &v12.Secret{
+			Data:       map[string][]byte{v1alpha3.BasicAuthPasswordKey: []byte("ignored")},
+			StringData: map[string]string{v1alpha3.BasicAuthPasswordKey: "new"},
+		},
+		wantData: map[string][]byte{
+			v1alpha3.BasicAuthUsernameKey: []byte("admin"),
+			v1alpha3.BasicAuthPasswordKey: []byte("new"),
+		},
+		wantLastRotated: true,
+	}, {
+		name:    "rotate without data",
+		secret:  secret.DeepCopy(),
+		data:    &v12.Secret{},
+		wantErr: true,
+	}, {
+		name:   "rotate with the same data",
+		secret: secret.DeepCopy(),
+		data: &v12.Secret{
+			Data:       map[string][]byte{v1alpha3.BasicAuthPasswordKey: []byte("new")},
+			StringData: map[string]string{v1alpha3.BasicAuthPasswordKey: "old"},
+		},
+		wantErr: true,
+	}, {
+		name:   "external credential with data",
+		secret: externalSecret.DeepCopy(),
+		data: &v12.Secret{
+			Data: map[string][]byte{v1alpha3.BasicAuthPasswordKey: []byte("new")},
+		},
+		wantErr: true,
+	}, {
+		name:   "rotate with invalid data",
+		secret: accessKeySecret,
+		data: &v12.Secret{
+			Data: map[string][]byte{v1alpha3.AccessKeyIDKey: []byte("id")},
+		},
+		wantErr: true,
+	}, {
+		name:   "external credential without data",
+		secret: externalSecret.DeepCopy(),
+		wantData: map[string][]byte{
+			v1alpha3.BasicAuthUsernameKey: []byte("admin"),
+			v1alpha3.BasicAuthPasswordKey: []byte("old"),
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sclient := k8sfake.NewSimpleClientset(tt.secret)
+			d := devopsOperator{
+				k8sclient: k8sclient,
+				ksclient:  fakeclientset.NewSimpleClientset(project.DeepCopy()),
+				context:   context.TODO(),
+			}
+			_, err := d.RotateCredentialObj("ns", "fake", tt.data)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				stored, err := k8sclient.CoreV1().Secrets("ns").Get(context.TODO(), "fake", metav1.GetOptions{})
+				assert.Nil(t, err)
+				assert.Equal(t, tt.secret, stored, "the credential should not be changed")
+				return
+			}
+			assert.Nil(t, err)
+
+			rotated, err := k8sclient.CoreV1().Secrets("ns").Get(context.TODO(), "fake", metav1.GetOptions{})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantData, rotated.Data)
+			assert.Equal(t, tt.wantLastRotated, rotated.Annotations[v1alpha3.CredentialLastRotatedAnnoKey] != "")
+			assert.Nil(t, v1alpha3.ValidateCredentialRotation(rotated))
+			assert.NotContains(t, rotated.Annotations, v1alpha3.DevOpsCredentialDataHash)
+			assert.Equal(t, StatusPending, rotated.Annotations[v1alpha3.CredentialSyncStatusAnnoKey])
+		})
+	}
+}
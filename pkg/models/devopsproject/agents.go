@@ -0,0 +1,243 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsproject
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/yaml"
+)
+
+// pipelineAgents are the agents which a Pipeline runs on
+type pipelineAgents struct {
+	// any indicates the Pipeline could run on any agent
+	any    bool
+	labels []string
+	// pods are the inline pods of the Kubernetes agents
+	pods []v1.Pod
+}
+
+// getPipelineAgents returns the agents of a Pipeline.
+// The JSON Jenkinsfile is checked as well if it's being edited in the JSON mode, because it's not converted into the
+// Jenkinsfile yet. The Pipelines on the Kubernetes engine only run the JSON Jenkinsfile, the names of the container
+// steps are their agent labels, and the resources of the steps are always capped by the limits.
+func getPipelineAgents(pipeline *v1alpha3.Pipeline) (agents *pipelineAgents, err error) {
+	agents = &pipelineAgents{}
+	jsonJenkinsfile := pipeline.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey]
+	if pipeline.GetEngine() == v1alpha3.PipelineEngineKubernetes {
+		if jsonJenkinsfile != "" {
+			err = agents.addJSONContainers(jsonJenkinsfile)
+		}
+		return
+	}
+
+	if jenkinsfile := pipeline.Spec.Pipeline.Jenkinsfile; jenkinsfile != "" {
+		agents.any = v1alpha3.IsJenkinsfileAgentAny(jenkinsfile)
+		agents.labels = v1alpha3.GetJenkinsfileAgentLabels(jenkinsfile)
+		agents.pods = v1alpha3.GetJenkinsfileAgentPods(jenkinsfile)
+	}
+	if pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] == v1alpha3.PipelineJenkinsfileEditModeJSON &&
+		jsonJenkinsfile != "" {
+		err = agents.addJSONAgents(jsonJenkinsfile)
+	}
+	return
+}
+
+// jsonAgentJenkinsfile is the part of the JSON Jenkinsfile which is related to the agents
+type jsonAgentJenkinsfile struct {
+	Pipeline struct {
+		Agent  *jsonAgent   `json:"agent,omitempty"`
+		Stages []jsonStages `json:"stages"`
+	} `json:"pipeline"`
+}
+
+type jsonStages struct {
+	Agent    *jsonAgent   `json:"agent,omitempty"`
+	Parallel []jsonStages `json:"parallel,omitempty"`
+	Stages   []jsonStages `json:"stages,omitempty"`
+	Branches []struct {
+		Steps []jsonStep `json:"steps"`
+	} `json:"branches,omitempty"`
+}
+
+// jsonAgent is an agent of the JSON Jenkinsfile, the type could be any, none, label, node, kubernetes and so on
+type jsonAgent struct {
+	Type      string          `json:"type"`
+	Argument  json.RawMessage `json:"argument,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// jsonStep is a step of the JSON Jenkinsfile, the arguments could be named or a single value
+type jsonStep struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Children  []jsonStep      `json:"children,omitempty"`
+}
+
+type jsonArgument struct {
+	Key   string            `json:"key"`
+	Value jsonArgumentValue `json:"value"`
+}
+
+type jsonArgumentValue struct {
+	IsLiteral bool        `json:"isLiteral"`
+	Value     interface{} `json:"value"`
+}
+
+// getJSONArgument returns the literal value of an argument, the single value is taken as the default argument.
+// The found is false if there's no such argument.
+func getJSONArgument(arguments json.RawMessage, key string) (value string, found bool, err error) {
+	var arg *jsonArgumentValue
+	var args []jsonArgument
+	if json.Unmarshal(arguments, &args) == nil {
+		for i := range args {
+			if args[i].Key == key {
+				arg = &args[i].Value
+				break
+			}
+		}
+	} else {
+		single := &jsonArgumentValue{}
+		if json.Unmarshal(arguments, single) == nil {
+			arg = single
+		}
+	}
+
+	switch {
+	case arg == nil || arg.Value == nil:
+	case !arg.IsLiteral:
+		err = fmt.Errorf("the non-literal %s %v cannot be checked against the limits", key, arg.Value)
+	default:
+		value, found = fmt.Sprint(arg.Value), true
+	}
+	return
+}
+
+// addJSONAgents adds the agents of a JSON Jenkinsfile
+func (a *pipelineAgents) addJSONAgents(jenkinsfile string) (err error) {
+	j := &jsonAgentJenkinsfile{}
+	if err = json.Unmarshal([]byte(jenkinsfile), j); err != nil {
+		return fmt.Errorf("invalid JSON Jenkinsfile: %v", err)
+	}
+	if err = a.addJSONAgent(j.Pipeline.Agent); err != nil {
+		return
+	}
+	return walkJSONStages(j.Pipeline.Stages, func(stage *jsonStages) error {
+		return a.addJSONAgent(stage.Agent)
+	})
+}
+
+func (a *pipelineAgents) addJSONAgent(agent *jsonAgent) (err error) {
+	if agent == nil {
+		return
+	}
+	switch agent.Type {
+	case "any":
+		a.any = true
+	case "label":
+		err = a.addJSONLabel(agent.Argument, "label")
+	case "node":
+		err = a.addJSONLabel(agent.Arguments, "label")
+	case "kubernetes":
+		if err = a.addJSONLabel(agent.Arguments, "label"); err != nil {
+			return
+		}
+		if err = a.addJSONLabel(agent.Arguments, "inheritFrom"); err != nil {
+			return
+		}
+		var podYAML string
+		var found bool
+		if podYAML, found, err = getJSONArgument(agent.Arguments, "yaml"); err != nil || !found {
+			return
+		}
+		// the pods which cannot be parsed are skipped because Jenkins cannot run them either
+		pod := v1.Pod{}
+		if yaml.Unmarshal([]byte(podYAML), &pod) == nil {
+			a.pods = append(a.pods, pod)
+		}
+	}
+	return
+}
+
+func (a *pipelineAgents) addJSONLabel(arguments json.RawMessage, key string) error {
+	label, found, err := getJSONArgument(arguments, key)
+	if err == nil && found {
+		a.addLabel(label)
+	}
+	return err
+}
+
+// addJSONContainers adds the names of the container steps of a JSON Jenkinsfile as the agent labels
+func (a *pipelineAgents) addJSONContainers(jenkinsfile string) (err error) {
+	j := &jsonAgentJenkinsfile{}
+	if err = json.Unmarshal([]byte(jenkinsfile), j); err != nil {
+		return fmt.Errorf("invalid JSON Jenkinsfile: %v", err)
+	}
+	return walkJSONStages(j.Pipeline.Stages, func(stage *jsonStages) error {
+		for i := range stage.Branches {
+			if err := a.addJSONContainerSteps(stage.Branches[i].Steps); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *pipelineAgents) addJSONContainerSteps(steps []jsonStep) error {
+	for i := range steps {
+		if steps[i].Name == "container" {
+			if err := a.addJSONLabel(steps[i].Arguments, "name"); err != nil {
+				return err
+			}
+		}
+		if err := a.addJSONContainerSteps(steps[i].Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLabel adds a label, the labels are kept sorted and unique
+func (a *pipelineAgents) addLabel(label string) {
+	index := sort.SearchStrings(a.labels, label)
+	if index < len(a.labels) && a.labels[index] == label {
+		return
+	}
+	a.labels = append(a.labels, "")
+	copy(a.labels[index+1:], a.labels[index:])
+	a.labels[index] = label
+}
+
+// walkJSONStages calls the function for every stage, including the parallel and nested ones
+func walkJSONStages(stages []jsonStages, fn func(stage *jsonStages) error) error {
+	for i := range stages {
+		if err := fn(&stages[i]); err != nil {
+			return err
+		}
+		if err := walkJSONStages(stages[i].Parallel, fn); err != nil {
+			return err
+		}
+		if err := walkJSONStages(stages[i].Stages, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
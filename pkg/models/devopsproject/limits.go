@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsproject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AgentPodTemplateLabelKey is the label key of the PodTemplates which are the Jenkins agents
+	AgentPodTemplateLabelKey = "jenkins.agent.pod"
+	// AgentLabelsAnnoKey is the annotation key of the extra Jenkins labels of an agent PodTemplate,
+	// the labels are separated by spaces
+	AgentLabelsAnnoKey = "jenkins.agent.labels"
+)
+
+// GetByNamespace returns the DevOpsProject which the namespace belongs to,
+// the project is nil if the namespace doesn't belong to any DevOpsProject
+func GetByNamespace(ctx context.Context, c client.Reader, namespace string) (project *v1alpha3.DevOpsProject, err error) {
+	ns := &v1.Namespace{}
+	if err = c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	name := ns.Labels[constants.DevOpsProjectLabelKey]
+	if name == "" {
+		return
+	}
+	project = &v1alpha3.DevOpsProject{}
+	if err = c.Get(ctx, client.ObjectKey{Name: name}, project); err != nil {
+		project = nil
+		err = client.IgnoreNotFound(err)
+	}
+	return
+}
+
+// ValidatePipelineAgents returns an error if the agents of a Pipeline violate the limits of its DevOpsProject.
+// Only the Jenkinsfile of a Pipeline is checked, the Jenkinsfiles of the multi-branch Pipelines live in the SCMs.
+// The resources of an agent come from its inline pod, or the PodTemplate which provides its label.
+// The agents which cannot be checked, such as the non-literal labels of a JSON Jenkinsfile, are violations as well.
+func ValidatePipelineAgents(ctx context.Context, c client.Reader, limits *v1alpha3.DevOpsProjectLimits,
+	pipeline *v1alpha3.Pipeline) (err error) {
+	if limits == nil || (pipeline.Spec.Pipeline == nil && pipeline.GetEngine() != v1alpha3.PipelineEngineKubernetes) {
+		return
+	}
+	var agents *pipelineAgents
+	if agents, err = getPipelineAgents(pipeline); err != nil {
+		return
+	}
+
+	var violations []string
+	if len(limits.AllowedAgentLabels) > 0 {
+		if agents.any {
+			violations = append(violations, "agent any is not allowed")
+		}
+		for _, label := range agents.labels {
+			if !limits.IsAgentLabelAllowed(label) {
+				violations = append(violations, fmt.Sprintf("agent label %q is not allowed", label))
+			}
+		}
+	}
+
+	if len(limits.MaxAgentResources) > 0 {
+		for _, pod := range agents.pods {
+			if err = limits.ValidateAgentResources(v1alpha3.GetAgentResources(&pod.Spec)); err != nil {
+				violations = append(violations, fmt.Sprintf("inline agent pod: %v", err))
+			}
+		}
+
+		// the Pipelines on the Kubernetes engine never run on the agent PodTemplates
+		if pipeline.GetEngine() != v1alpha3.PipelineEngineKubernetes {
+			podTemplates := &v1.PodTemplateList{}
+			if err = c.List(ctx, podTemplates, client.HasLabels{AgentPodTemplateLabelKey}); err != nil {
+				return
+			}
+			for i := range podTemplates.Items {
+				podTemplate := &podTemplates.Items[i]
+				if !hasAnyAgentLabel(podTemplate, agents.labels) {
+					continue
+				}
+				if err = limits.ValidateAgentResources(v1alpha3.GetAgentResources(&podTemplate.Template.Spec)); err != nil {
+					violations = append(violations, fmt.Sprintf("agent %s: %v", podTemplate.Name, err))
+				}
+			}
+		}
+		err = nil
+	}
+
+	if len(violations) > 0 {
+		err = errors.New(strings.Join(violations, "; "))
+	}
+	return
+}
+
+// hasAnyAgentLabel returns true if the agent PodTemplate provides any of the labels
+func hasAnyAgentLabel(podTemplate *v1.PodTemplate, labels []string) bool {
+	agentLabels := append(strings.Fields(podTemplate.Annotations[AgentLabelsAnnoKey]), podTemplate.Name)
+	for _, agentLabel := range agentLabels {
+		for _, label := range labels {
+			if agentLabel == label {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CountPipelines returns the number of the Pipelines in a namespace, the ones being deleted are not counted
+func CountPipelines(ctx context.Context, c client.Reader, namespace string) (count int32, err error) {
+	pipelines := &v1alpha3.PipelineList{}
+	if err = c.List(ctx, pipelines, client.InNamespace(namespace)); err != nil {
+		return
+	}
+	for i := range pipelines.Items {
+		if pipelines.Items[i].DeletionTimestamp.IsZero() {
+			count++
+		}
+	}
+	return
+}
+
+// GetUsage returns the usage of the limits of the DevOpsProject whose admin namespace is the given one
+func GetUsage(ctx context.Context, c client.Reader, namespace string) (usage *v1alpha3.DevOpsProjectUsage, err error) {
+	usage = &v1alpha3.DevOpsProjectUsage{}
+	if usage.Pipelines, err = CountPipelines(ctx, c, namespace); err != nil {
+		return
+	}
+
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = c.List(ctx, pipelineRuns, client.InNamespace(namespace)); err != nil {
+		return
+	}
+	for i := range pipelineRuns.Items {
+		item := &pipelineRuns.Items[i]
+		if !item.Buildable() || !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if item.HasStarted() {
+			usage.RunningPipelineRuns++
+		} else {
+			usage.QueuedPipelineRuns++
+		}
+	}
+	return
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsproject
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	schema := runtime.NewScheme()
+	assert.Nil(t, v1.AddToScheme(schema))
+	assert.Nil(t, v1alpha3.AddToScheme(schema))
+	return schema
+}
+
+func newProjectNamespace(namespace, project string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   namespace,
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: project},
+	}}
+}
+
+func newAgentPodTemplate(name, labels, memory string) *v1.PodTemplate {
+	return &v1.PodTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "kubesphere-devops-system",
+			Name:        name,
+			Labels:      map[string]string{AgentPodTemplateLabelKey: ""},
+			Annotations: map[string]string{AgentLabelsAnnoKey: labels},
+		},
+		Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name: name,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse(memory)},
+			},
+		}}}},
+	}
+}
+
+func newJenkinsfilePipeline(jenkinsfile string) *v1alpha3.Pipeline {
+	return &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "pipeline"},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Jenkinsfile: jenkinsfile},
+		},
+	}
+}
+
+func newJSONPipeline(engine, jsonJenkinsfile string) *v1alpha3.Pipeline {
+	pipeline := newJenkinsfilePipeline("")
+	pipeline.Annotations = map[string]string{
+		v1alpha3.PipelineEngineAnnoKey:              engine,
+		v1alpha3.PipelineJenkinsfileEditModeAnnoKey: v1alpha3.PipelineJenkinsfileEditModeJSON,
+		v1alpha3.PipelineJenkinsfileValueAnnoKey:    jsonJenkinsfile,
+	}
+	return pipeline
+}
+
+func TestGetByNamespace(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(
+		newProjectNamespace("project-ns", "project"),
+		newProjectNamespace("orphan-ns", "missing"),
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1alpha3.DevOpsProject{ObjectMeta: metav1.ObjectMeta{Name: "project"}}).Build()
+
+	project, err := GetByNamespace(context.TODO(), c, "project-ns")
+	assert.Nil(t, err)
+	if assert.NotNil(t, project) {
+		assert.Equal(t, "project", project.Name)
+	}
+	for _, namespace := range []string{"orphan-ns", "default", "missing"} {
+		project, err = GetByNamespace(context.TODO(), c, namespace)
+		assert.Nil(t, err)
+		assert.Nil(t, project, namespace)
+	}
+}
+
+func TestValidatePipelineAgents(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(
+		newAgentPodTemplate("maven", "java", "4Gi"),
+		newAgentPodTemplate("base", "", "512Mi")).Build()
+
+	tests := []struct {
+		name        string
+		limits      *v1alpha3.DevOpsProjectLimits
+		pipeline    *v1alpha3.Pipeline
+		wantMessage string
+	}{{
+		name:     "without limits",
+		pipeline: newJenkinsfilePipeline("pipeline { agent { label 'go' } }"),
+	}, {
+		name:     "multi-branch Pipeline",
+		limits:   &v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"base"}},
+		pipeline: &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}},
+	}, {
+		name:     "allowed agent labels",
+		limits:   &v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"base", "java"}},
+		pipeline: newJenkinsfilePipeline("pipeline { agent { label 'java' }; stages { stage('a') { agent { label 'base' } } } }"),
+	}, {
+		name:        "not allowed agent labels",
+		limits:      &v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"base"}},
+		pipeline:    newJenkinsfilePipeline("pipeline { agent any; stages { stage('a') { agent { label 'go' } } } }"),
+		wantMessage: `agent any is not allowed; agent label "go" is not allowed`,
+	}, {
+		name: "the agents are under the max resources",
+		limits: &v1alpha3.DevOpsProjectLimits{
+			MaxAgentResources: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+		pipeline: newJenkinsfilePipeline("pipeline { agent { label 'base' } }"),
+	}, {
+		name: "the agents exceed the max resources",
+		limits: &v1alpha3.DevOpsProjectLimits{
+			MaxAgentResources: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+		pipeline: newJenkinsfilePipeline(`pipeline {
+  agent { label 'java' }
+  stages {
+    stage('a') {
+      agent {
+        kubernetes {
+          yaml """
+spec:
+  containers:
+  - name: big
+    resources:
+      limits:
+        memory: 2Gi
+"""
+        }
+      }
+    }
+  }
+}`),
+		wantMessage: "inline agent pod: memory 2Gi exceeds the limit 1Gi; agent maven: memory 4Gi exceeds the limit 1Gi",
+	}, {
+		name: "the JSON Jenkinsfile which is being edited",
+		limits: &v1alpha3.DevOpsProjectLimits{
+			AllowedAgentLabels: []string{"base", "java"},
+			MaxAgentResources:  v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+		pipeline: newJSONPipeline(v1alpha3.PipelineEngineJenkins, `{"pipeline":{
+  "agent":{"type":"node","arguments":[{"key":"label","value":{"isLiteral":true,"value":"base"}}]},
+  "stages":[{"name":"a","parallel":[
+    {"name":"b","agent":{"type":"label","argument":{"isLiteral":true,"value":"java"}}},
+    {"name":"c","agent":{"type":"kubernetes","arguments":[
+      {"key":"inheritFrom","value":{"isLiteral":true,"value":"go"}},
+      {"key":"yaml","value":{"isLiteral":true,"value":"spec:\n  containers:\n  - name: big\n    resources:\n      limits:\n        memory: 2Gi\n"}}]}},
+    {"name":"d","agent":{"type":"any"}}]}]}}`),
+		wantMessage: `agent any is not allowed; agent label "go" is not allowed; ` +
+			"inline agent pod: memory 2Gi exceeds the limit 1Gi; agent maven: memory 4Gi exceeds the limit 1Gi",
+	}, {
+		name:        "the non-literal agent label of the JSON Jenkinsfile",
+		limits:      &v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"base"}},
+		pipeline:    newJSONPipeline(v1alpha3.PipelineEngineJenkins, `{"pipeline":{"agent":{"type":"label","argument":{"isLiteral":false,"value":"${AGENT}"}},"stages":[]}}`),
+		wantMessage: "the non-literal label ${AGENT} cannot be checked against the limits",
+	}, {
+		name:        "the invalid JSON Jenkinsfile",
+		limits:      &v1alpha3.DevOpsProjectLimits{AllowedAgentLabels: []string{"base"}},
+		pipeline:    newJSONPipeline(v1alpha3.PipelineEngineJenkins, `{"pipeline":`),
+		wantMessage: "invalid JSON Jenkinsfile: unexpected end of JSON input",
+	}, {
+		name: "the container steps on the Kubernetes engine",
+		limits: &v1alpha3.DevOpsProjectLimits{
+			AllowedAgentLabels: []string{"base"},
+			MaxAgentResources:  v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+		pipeline: newJSONPipeline(v1alpha3.PipelineEngineKubernetes, `{"pipeline":{"agent":{"type":"label","argument":{"isLiteral":true,"value":"maven"}},
+  "stages":[{"name":"a","branches":[{"name":"","steps":[
+    {"name":"container","arguments":{"isLiteral":true,"value":"base"},"children":[
+      {"name":"container","arguments":[{"key":"name","value":{"isLiteral":true,"value":"go"}}],"children":[]}]}]}]}]}}`),
+		wantMessage: `agent label "go" is not allowed`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePipelineAgents(context.TODO(), c, tt.limits, tt.pipeline)
+			if tt.wantMessage == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects([]client.Object{
+		&v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "a"}},
+		&v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "queued"}},
+		&v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "orphan",
+			Labels: map[string]string{v1alpha3.PipelineRunOrphanLabelKey: "true"}}},
+		&v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "project-ns", Name: "running",
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"}}},
+	}...).Build()
+
+	usage, err := GetUsage(context.TODO(), c, "project-ns")
+	assert.Nil(t, err)
+	assert.Equal(t, &v1alpha3.DevOpsProjectUsage{Pipelines: 1, RunningPipelineRuns: 1, QueuedPipelineRuns: 1}, usage)
+
+	usage, err = GetUsage(context.TODO(), c, "empty")
+	assert.Nil(t, err)
+	assert.Equal(t, &v1alpha3.DevOpsProjectUsage{}, usage)
+}
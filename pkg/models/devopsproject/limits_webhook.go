@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsproject
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PipelineLimitsWebhookPath is the path of the validating webhook which checks the Pipelines against the limits
+const PipelineLimitsWebhookPath = "/validate-devops-kubesphere-io-v1alpha3-pipeline-limits"
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-pipeline-limits,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelines,verbs=create;update,versions=v1alpha3,name=vpipelinelimits.devops.kubesphere.io,admissionReviewVersions=v1
+
+// PipelineLimitsValidator denies the Pipelines which exceed the limits of their DevOpsProjects.
+// It's not a part of the Pipeline webhook because the DevOpsProject and PodTemplates are needed.
+type PipelineLimitsValidator struct {
+	Client client.Reader
+
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &PipelineLimitsValidator{}
+
+// SetupWebhookWithManager registers the validating webhook into the webhook server of the manager
+func (v *PipelineLimitsValidator) SetupWebhookWithManager(mgr manager.Manager) (err error) {
+	if v.Client == nil {
+		v.Client = mgr.GetClient()
+	}
+	if v.decoder, err = admission.NewDecoder(mgr.GetScheme()); err == nil {
+		mgr.GetWebhookServer().Register(PipelineLimitsWebhookPath, &webhook.Admission{Handler: v})
+	}
+	return
+}
+
+// Handle checks the number of the Pipelines when a Pipeline is created, and checks the agents when its spec changed
+func (v *PipelineLimitsValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pipeline := &v1alpha3.Pipeline{}
+	if err := v.decoder.Decode(req, pipeline); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1.Update {
+		oldPipeline := &v1alpha3.Pipeline{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldPipeline); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if reflect.DeepEqual(oldPipeline.Spec, pipeline.Spec) && !hasAgentAnnotationsChanged(oldPipeline, pipeline) {
+			return admission.Allowed("")
+		}
+	}
+
+	project, err := GetByNamespace(ctx, v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if project == nil || project.Spec.Limits == nil {
+		return admission.Allowed("")
+	}
+	limits := project.Spec.Limits
+
+	if req.Operation == admissionv1.Create && limits.MaxPipelines > 0 {
+		var count int32
+		if count, err = CountPipelines(ctx, v.Client, req.Namespace); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if count >= limits.MaxPipelines {
+			return admission.Denied(fmt.Sprintf("DevOpsProject %s has reached the limit of %d Pipelines",
+				project.Name, limits.MaxPipelines))
+		}
+	}
+	if err = ValidatePipelineAgents(ctx, v.Client, limits, pipeline); err != nil {
+		return admission.Denied(fmt.Sprintf("the agents of Pipeline %s violate the limits of DevOpsProject %s: %v",
+			pipeline.Name, project.Name, err))
+	}
+	return admission.Allowed("")
+}
+
+// hasAgentAnnotationsChanged returns true if the annotations which decide the agents of a Pipeline changed.
+// The JSON Jenkinsfile decides the agents only if it's being edited, or the Pipeline runs on the Kubernetes engine,
+// otherwise it's converted from the Jenkinsfile.
+func hasAgentAnnotationsChanged(oldPipeline, pipeline *v1alpha3.Pipeline) bool {
+	if oldPipeline.GetEngine() != pipeline.GetEngine() {
+		return true
+	}
+	if pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] != v1alpha3.PipelineJenkinsfileEditModeJSON &&
+		pipeline.GetEngine() != v1alpha3.PipelineEngineKubernetes {
+		return false
+	}
+	return oldPipeline.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] != pipeline.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] ||
+		oldPipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] != pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey]
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsproject
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestPipelineLimitsValidator_Handle(t *testing.T) {
+	schema := newScheme(t)
+	decoder, err := admission.NewDecoder(schema)
+	assert.Nil(t, err)
+
+	limits := &v1alpha3.DevOpsProjectLimits{MaxPipelines: 1, AllowedAgentLabels: []string{"base"}}
+	newPipeline := func(name, label string) *v1alpha3.Pipeline {
+		pipeline := newJenkinsfilePipeline("pipeline { agent { label '" + label + "' } }")
+		pipeline.TypeMeta = metav1.TypeMeta{APIVersion: v1alpha3.GroupVersion.String(), Kind: "Pipeline"}
+		pipeline.Name = name
+		return pipeline
+	}
+	toRaw := func(pipeline *v1alpha3.Pipeline) runtime.RawExtension {
+		if pipeline == nil {
+			return runtime.RawExtension{}
+		}
+		data, err := json.Marshal(pipeline)
+		assert.Nil(t, err)
+		return runtime.RawExtension{Raw: data}
+	}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		limits      *v1alpha3.DevOpsProjectLimits
+		operation   admissionv1.Operation
+		pipeline    *v1alpha3.Pipeline
+		oldPipeline *v1alpha3.Pipeline
+		wantAllowed bool
+	}{{
+		name:        "not in a DevOpsProject",
+		namespace:   "default",
+		limits:      limits,
+		operation:   admissionv1.Create,
+		pipeline:    newPipeline("new", "go"),
+		wantAllowed: true,
+	}, {
+		name:        "without limits",
+		namespace:   "project-ns",
+		operation:   admissionv1.Create,
+		pipeline:    newPipeline("new", "go"),
+		wantAllowed: true,
+	}, {
+		name:      "reached the max Pipelines",
+		namespace: "project-ns",
+		limits:    limits,
+		operation: admissionv1.Create,
+		pipeline:  newPipeline("new", "base"),
+	}, {
+		name:        "under the max Pipelines",
+		namespace:   "project-ns",
+		limits:      &v1alpha3.DevOpsProjectLimits{MaxPipelines: 2},
+		operation:   admissionv1.Create,
+		pipeline:    newPipeline("new", "go"),
+		wantAllowed: true,
+	}, {
+		name:        "update the Pipeline with an allowed agent",
+		namespace:   "project-ns",
+		limits:      limits,
+		operation:   admissionv1.Update,
+		pipeline:    newPipeline("existing", "base"),
+		oldPipeline: newPipeline("existing", "go"),
+		wantAllowed: true,
+	}, {
+		name:        "update the Pipeline with a not allowed agent",
+		namespace:   "project-ns",
+		limits:      limits,
+		operation:   admissionv1.Update,
+		pipeline:    newPipeline("existing", "go"),
+		oldPipeline: newPipeline("existing", "base"),
+	}, {
+		name:        "the spec is not changed",
+		namespace:   "project-ns",
+		limits:      limits,
+		operation:   admissionv1.Update,
+		pipeline:    newPipeline("existing", "go"),
+		oldPipeline: newPipeline("existing", "go"),
+		wantAllowed: true,
+	}, {
+		name:      "update the JSON Jenkinsfile with a not allowed agent",
+		namespace: "project-ns",
+		limits:    limits,
+		operation: admissionv1.Update,
+		pipeline: func() *v1alpha3.Pipeline {
+			pipeline := newPipeline("existing", "base")
+			pipeline.Annotations = map[string]string{
+				v1alpha3.PipelineJenkinsfileEditModeAnnoKey: v1alpha3.PipelineJenkinsfileEditModeJSON,
+				v1alpha3.PipelineJenkinsfileValueAnnoKey: `{"pipeline":{"agent":{"type":"label",` +
+					`"argument":{"isLiteral":true,"value":"go"}},"stages":[]}}`,
+			}
+			return pipeline
+		}(),
+		oldPipeline: newPipeline("existing", "base"),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &v1alpha3.DevOpsProject{
+				ObjectMeta: metav1.ObjectMeta{Name: "project"},
+				Spec:       v1alpha3.DevOpsProjectSpec{Limits: tt.limits},
+			}
+			existing := newPipeline("existing", "base")
+			v := &PipelineLimitsValidator{
+				Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(
+					newProjectNamespace("project-ns", "project"), project, existing).Build(),
+				decoder: decoder,
+			}
+			resp := v.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				Namespace: tt.namespace,
+				Object:    toRaw(tt.pipeline),
+				OldObject: toRaw(tt.oldPipeline),
+			}})
+			assert.Equal(t, tt.wantAllowed, resp.Allowed, resp.Result)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// maxRedirects is the max number of the redirects when accessing a chart repository
+const maxRedirects = 10
+
+// ForbiddenHostError means the host of a chart repository resolves to an address which is not allowed to access
+type ForbiddenHostError struct {
+	Host string
+}
+
+func (e *ForbiddenHostError) Error() string {
+	return fmt.Sprintf("host %s is not allowed to access", e.Host)
+}
+
+// NewHTTPClient creates the HTTP client to access the chart repositories. The URLs of the repositories come from the
+// users, so the client refuses to connect to the loopback, private, link-local and unspecified addresses, except the
+// allowed hosts. The addresses are checked when connecting, so the redirects and the DNS records are covered.
+// The proxy of the environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY is used, it's trusted by the client.
+func NewHTTPClient(timeout time.Duration, allowedHosts []string) *http.Client {
+	return newHTTPClient(timeout, allowedHosts, httpproxy.FromEnvironment())
+}
+
+func newHTTPClient(timeout time.Duration, allowedHosts []string, proxyConfig *httpproxy.Config) *http.Client {
+	allowed := make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		allowed[host] = true
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	// resolve returns the addresses of a host, all of them must be allowed
+	resolve := func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addresses {
+			if !isPublicIP(address.IP) {
+				return nil, &ForbiddenHostError{Host: host}
+			}
+		}
+		if len(addresses) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return addresses, nil
+	}
+
+	// the proxies are usually in the internal networks, so they are allowed to connect
+	proxyHosts := make(map[string]bool, 2)
+	for _, proxy := range []string{proxyConfig.HTTPProxy, proxyConfig.HTTPSProxy} {
+		if proxyURL, err := url.Parse(proxy); err == nil && proxyURL.Hostname() != "" {
+			proxyHosts[proxyURL.Hostname()] = true
+		}
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	proxy := func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxyFunc(req.URL)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		// the proxy connects to the host on behalf of the client, so the host is checked before sending the request
+		if host := req.URL.Hostname(); !allowed[host] {
+			if _, err = resolve(req.Context(), host); err != nil {
+				return nil, err
+			}
+		}
+		return proxyURL, nil
+	}
+
+	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if allowed[host] || proxyHosts[host] {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		addresses, err := resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		// dial the checked address instead of resolving the host again
+		return dialer.DialContext(ctx, network, net.JoinHostPort(addresses[0].IP.String(), port))
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               proxy,
+			DialContext:         dialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("stopped after too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("unsupported redirect to %s", req.URL.Redacted())
+			}
+			return nil
+		},
+	}
+}
+
+// isPublicIP returns true if the IP is a global unicast address which is not in the private networks
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !isSharedAddress(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT space of RFC 6598, which is usually in the internal networks
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isSharedAddress(ip net.IP) bool {
+	return sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chart
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http/httpproxy"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/index.yaml":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("the secret in the internal service"))
+		default:
+			_, _ = w.Write([]byte("ok " + r.URL.Host))
+		}
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+
+	// the loopback address is refused
+	_, err = NewHTTPClient(time.Second, nil).Get(server.URL)
+	forbiddenHostErr := &ForbiddenHostError{}
+	if assert.True(t, errors.As(err, &forbiddenHostErr), err) {
+		assert.Equal(t, "127.0.0.1", forbiddenHostErr.Host)
+	}
+	_, err = NewHTTPClient(time.Second, nil).Get("http://localhost:" + serverURL.Port())
+	assert.True(t, errors.As(err, &forbiddenHostErr), err)
+
+	// the allowed host
+	client := NewHTTPClient(time.Second, []string{serverURL.Hostname()})
+	res, err := client.Get(server.URL)
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		_ = res.Body.Close()
+	}
+
+	// redirect to an unsupported scheme
+	_, err = client.Get(server.URL + "/redirect")
+	assert.Contains(t, err.Error(), "unsupported redirect to file:///etc/passwd")
+
+	// the response body of a failed request is not a part of the error
+	lister, err := NewLister(&v1alpha1.ChartRepository{Spec: v1alpha1.ChartRepositorySpec{URL: server.URL}}, nil, client)
+	assert.Nil(t, err)
+	_, err = lister.ListCharts(context.TODO())
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unexpected status code 500")
+		assert.NotContains(t, err.Error(), "secret")
+	}
+
+	// the proxy is allowed to connect, but the hosts which it connects to are checked
+	proxyClient := newHTTPClient(time.Second, nil, &httpproxy.Config{HTTPProxy: server.URL})
+	res, err = proxyClient.Get("http://8.8.8.8/index")
+	if assert.Nil(t, err) {
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assert.Equal(t, "ok 8.8.8.8", string(body))
+	}
+	_, err = proxyClient.Get("http://169.254.169.254/latest/meta-data")
+	if assert.True(t, errors.As(err, &forbiddenHostErr), err) {
+		assert.Equal(t, "169.254.169.254", forbiddenHostErr.Host)
+	}
+	res, err = newHTTPClient(time.Second, []string{"10.0.0.1"}, &httpproxy.Config{HTTPProxy: server.URL}).
+		Get("http://10.0.0.1/index")
+	if assert.Nil(t, err) {
+		_ = res.Body.Close()
+	}
+}
+
+func Test_isPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "8.8.8.8", want: true},
+		{ip: "2001:4860:4860::8888", want: true},
+		{ip: "127.0.0.1"},
+		{ip: "::1"},
+		{ip: "0.0.0.0"},
+		{ip: "10.0.0.1"},
+		{ip: "172.16.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "100.64.0.1"},
+		{ip: "169.254.169.254"},
+		{ip: "fe80::1"},
+		{ip: "fd00::1"},
+		{ip: "224.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPublicIP(net.ParseIP(tt.ip)))
+		})
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chart
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// helmIndex is the index.yaml of a Helm repository
+// See also: https://helm.sh/docs/topics/chart_repository/#the-index-file
+type helmIndex struct {
+	Entries map[string][]helmIndexEntry `json:"entries"`
+}
+
+type helmIndexEntry struct {
+	Name        string     `json:"name"`
+	Version     string     `json:"version"`
+	AppVersion  string     `json:"appVersion"`
+	Description string     `json:"description"`
+	Icon        string     `json:"icon"`
+	Created     *time.Time `json:"created"`
+	Digest      string     `json:"digest"`
+	Deprecated  bool       `json:"deprecated"`
+}
+
+// helmLister lists the charts from the index.yaml of a Helm repository
+type helmLister struct {
+	url        string
+	auth       *basicAuth
+	httpClient *http.Client
+}
+
+func newHelmLister(url string, auth *basicAuth, httpClient *http.Client) *helmLister {
+	return &helmLister{
+		url:        strings.TrimSuffix(url, "/"),
+		auth:       auth,
+		httpClient: httpClient,
+	}
+}
+
+// ListCharts returns all the charts of the index.yaml
+func (l *helmLister) ListCharts(ctx context.Context) (charts []Chart, err error) {
+	var index *helmIndex
+	if index, err = l.getIndex(ctx); err != nil {
+		return
+	}
+	charts = make([]Chart, 0, len(index.Entries))
+	for name, entries := range index.Entries {
+		versions := toVersions(entries)
+		if len(versions) == 0 {
+			continue
+		}
+		// the description and icon come from the latest version
+		latest := entries[0]
+		for _, entry := range entries {
+			if entry.Version == versions[0].Version {
+				latest = entry
+				break
+			}
+		}
+		charts = append(charts, Chart{
+			Name:          name,
+			Description:   latest.Description,
+			Icon:          latest.Icon,
+			LatestVersion: latest.Version,
+			Deprecated:    latest.Deprecated,
+		})
+	}
+	sort.Slice(charts, func(i, j int) bool {
+		return charts[i].Name < charts[j].Name
+	})
+	return
+}
+
+// ListVersions returns the versions of a chart in the index.yaml
+func (l *helmLister) ListVersions(ctx context.Context, chart string) (versions []Version, err error) {
+	var index *helmIndex
+	if index, err = l.getIndex(ctx); err != nil {
+		return
+	}
+	entries, ok := index.Entries[chart]
+	if !ok {
+		err = &NotFoundError{Chart: chart}
+		return
+	}
+	versions = toVersions(entries)
+	return
+}
+
+func (l *helmLister) getIndex(ctx context.Context) (index *helmIndex, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, l.url+"/index.yaml", nil); err != nil {
+		return
+	}
+	l.auth.set(req)
+
+	var res *http.Response
+	if res, err = l.httpClient.Do(req); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = readBody(res); err != nil {
+		return
+	}
+	index = &helmIndex{}
+	if err = yaml.Unmarshal(data, index); err != nil {
+		err = fmt.Errorf("invalid index.yaml of Helm repository %s: %v", l.url, err)
+	}
+	return
+}
+
+func toVersions(entries []helmIndexEntry) []Version {
+	versions := make([]Version, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, Version{
+			Version:     entry.Version,
+			AppVersion:  entry.AppVersion,
+			Description: entry.Description,
+			Created:     entry.Created,
+			Digest:      entry.Digest,
+			Deprecated:  entry.Deprecated,
+		})
+	}
+	sortVersions(versions)
+	return versions
+}
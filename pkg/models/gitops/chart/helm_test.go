@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chart
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+const fakeIndex = `apiVersion: v1
+entries:
+  nginx:
+  - name: nginx
+    version: 13.2.9
+    appVersion: 1.23.1
+    description: NGINX Open Source is a web server.
+    icon: https://example.com/nginx.png
+    created: "2022-10-01T10:00:00Z"
+    digest: abc
+  - name: nginx
+    version: 13.2.10
+    appVersion: 1.23.1
+    description: The latest NGINX.
+  - name: nginx
+    version: 9.0.0-rc.1
+  redis:
+  - name: redis
+    version: 17.3.2
+    deprecated: true
+`
+
+func newFakeHelmRepository(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/charts/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := w.Write([]byte(fakeIndex))
+		assert.Nil(t, err)
+	}))
+}
+
+func TestHelmLister(t *testing.T) {
+	server := newFakeHelmRepository(t)
+	defer server.Close()
+
+	repo := &v1alpha1.ChartRepository{Spec: v1alpha1.ChartRepositorySpec{URL: server.URL + "/charts/"}}
+	secret := &corev1.Secret{Data: map[string][]byte{"username": []byte("admin"), "password": []byte("secret")}}
+	lister, err := NewLister(repo, secret, nil)
+	assert.Nil(t, err)
+
+	charts, err := lister.ListCharts(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []Chart{{
+		Name:          "nginx",
+		Description:   "The latest NGINX.",
+		LatestVersion: "13.2.10",
+	}, {
+		Name:          "redis",
+		LatestVersion: "17.3.2",
+		Deprecated:    true,
+	}}, charts)
+
+	versions, err := lister.ListVersions(context.TODO(), "nginx")
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(versions)) {
+		assert.Equal(t, "13.2.10", versions[0].Version)
+		assert.Equal(t, "13.2.9", versions[1].Version)
+		assert.Equal(t, "1.23.1", versions[1].AppVersion)
+		assert.Equal(t, "abc", versions[1].Digest)
+		assert.NotNil(t, versions[1].Created)
+		assert.Equal(t, "9.0.0-rc.1", versions[2].Version)
+	}
+
+	_, err = lister.ListVersions(context.TODO(), "mysql")
+	assert.IsType(t, &NotFoundError{}, err)
+
+	// without the credential
+	lister, err = NewLister(repo, nil, nil)
+	assert.Nil(t, err)
+	_, err = lister.ListCharts(context.TODO())
+	assert.Contains(t, err.Error(), "unexpected status code 401")
+}
+
+func TestNewLister(t *testing.T) {
+	_, err := NewLister(&v1alpha1.ChartRepository{Spec: v1alpha1.ChartRepositorySpec{
+		URL: "oci://ghcr.io/stefanprodan/charts"}}, nil, nil)
+	assert.NotNil(t, err)
+
+	lister, err := NewLister(&v1alpha1.ChartRepository{Spec: v1alpha1.ChartRepositorySpec{
+		Type: v1alpha1.ChartRepositoryTypeOCI, URL: "oci://ghcr.io/stefanprodan/charts"}}, nil, nil)
+	assert.Nil(t, err)
+	if assert.IsType(t, &ociLister{}, lister) {
+		assert.Equal(t, "https://ghcr.io", lister.(*ociLister).registry)
+		assert.Equal(t, "stefanprodan/charts", lister.(*ociLister).path)
+	}
+}
+
+func Test_sortVersions(t *testing.T) {
+	versions := []Version{{Version: "latest"}, {Version: "1.0.0"}, {Version: "v1.10.0"}, {Version: "1.2.0+build.1"},
+		{Version: "main"}, {Version: "1.10.0-rc.1"}}
+	sortVersions(versions)
+	assert.Equal(t, []Version{{Version: "v1.10.0"}, {Version: "1.10.0-rc.1"}, {Version: "1.2.0+build.1"},
+		{Version: "1.0.0"}, {Version: "main"}, {Version: "latest"}}, versions)
+}
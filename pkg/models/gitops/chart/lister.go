@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chart lists the charts and their versions of the Helm and OCI chart repositories,
+// they are the candidates of the HelmRelease Applications.
+package chart
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/blang/semver/v4"
+	corev1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+// maxResponseSize is the max size of the response from a chart repository, the index.yaml might be large
+const maxResponseSize = 50 << 20
+
+// Chart is a chart of a repository
+type Chart struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Icon          string `json:"icon,omitempty"`
+	LatestVersion string `json:"latestVersion,omitempty"`
+	Deprecated    bool   `json:"deprecated,omitempty"`
+}
+
+// Version is a version of a chart
+type Version struct {
+	Version     string     `json:"version"`
+	AppVersion  string     `json:"appVersion,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Created     *time.Time `json:"created,omitempty"`
+	Digest      string     `json:"digest,omitempty"`
+	Deprecated  bool       `json:"deprecated,omitempty"`
+}
+
+// Lister lists the charts and versions of a chart repository
+type Lister interface {
+	// ListCharts returns the charts sorted by name
+	ListCharts(ctx context.Context) ([]Chart, error)
+	// ListVersions returns the versions of a chart, the latest version goes first
+	ListVersions(ctx context.Context, chart string) ([]Version, error)
+}
+
+// NewLister creates a Lister according to the type of the ChartRepository.
+// The secret is the basic-auth credential of the ChartRepository, it could be nil.
+func NewLister(repo *v1alpha1.ChartRepository, secret *corev1.Secret, httpClient *http.Client) (Lister, error) {
+	if err := repo.Validate(); err != nil {
+		return nil, err
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	auth := &basicAuth{}
+	if secret != nil {
+		auth.username = string(secret.Data[v1alpha3.BasicAuthUsernameKey])
+		auth.password = string(secret.Data[v1alpha3.BasicAuthPasswordKey])
+	}
+
+	if repo.GetType() == v1alpha1.ChartRepositoryTypeOCI {
+		return newOCILister(repo.Spec.URL, auth, httpClient)
+	}
+	return newHelmLister(repo.Spec.URL, auth, httpClient), nil
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (a *basicAuth) set(req *http.Request) {
+	if a.username != "" || a.password != "" {
+		req.SetBasicAuth(a.username, a.password)
+	}
+}
+
+// NotFoundError means the chart does not exist in the repository
+type NotFoundError struct {
+	Chart string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("chart %s is not found", e.Chart)
+}
+
+// readBody reads the response body, an error is returned if the status code is not 200
+func readBody(res *http.Response) (data []byte, err error) {
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		// the body is not a part of the error, it's up to the remote server and should not be echoed to the users
+		err = fmt.Errorf("unexpected status code %d from %s", res.StatusCode, res.Request.URL.Redacted())
+		return
+	}
+	data, err = io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	return
+}
+
+// sortVersions sorts the versions in the descending order of the semantic versions,
+// the invalid semantic versions go last
+func sortVersions(versions []Version) {
+	parsed := make(map[string]*semver.Version, len(versions))
+	for _, item := range versions {
+		if v, err := semver.ParseTolerant(item.Version); err == nil {
+			parsed[item.Version] = &v
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := parsed[versions[i].Version], parsed[versions[j].Version]
+		switch {
+		case a != nil && b != nil:
+			return a.GT(*b)
+		case a != nil || b != nil:
+			return a != nil
+		default:
+			return versions[i].Version > versions[j].Version
+		}
+	})
+}
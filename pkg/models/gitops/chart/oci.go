@@ -0,0 +1,239 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chart
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// maxTagPages is the max number of the pages when listing the tags of a chart
+const maxTagPages = 100
+
+// ociLister lists the charts from an OCI registry by the distribution API.
+// See also: https://github.com/opencontainers/distribution-spec/blob/main/spec.md
+type ociLister struct {
+	// registry is the base URL of the registry, such as https://ghcr.io
+	registry string
+	// path is the path of the repository in the registry, such as stefanprodan/charts
+	path       string
+	auth       *basicAuth
+	httpClient *http.Client
+}
+
+func newOCILister(repoURL string, auth *basicAuth, httpClient *http.Client) (lister *ociLister, err error) {
+	var u *url.URL
+	if u, err = url.Parse(repoURL); err != nil {
+		return
+	}
+	lister = &ociLister{
+		registry:   "https://" + u.Host,
+		path:       strings.Trim(u.Path, "/"),
+		auth:       auth,
+		httpClient: httpClient,
+	}
+	return
+}
+
+type ociCatalog struct {
+	Repositories []string `json:"repositories"`
+}
+
+type ociTagList struct {
+	Tags []string `json:"tags"`
+}
+
+// ListCharts returns the repositories right under the path by the catalog API.
+// Some registries do not support the catalog API, such as Docker Hub and GitHub Container Registry.
+func (l *ociLister) ListCharts(ctx context.Context) (charts []Chart, err error) {
+	catalog := &ociCatalog{}
+	if err = l.getJSON(ctx, "/v2/_catalog", "registry:catalog:*", catalog); err != nil {
+		err = fmt.Errorf("failed to list the charts by the catalog API of %s: %w", l.registry, err)
+		return
+	}
+	prefix := ""
+	if l.path != "" {
+		prefix = l.path + "/"
+	}
+	charts = []Chart{}
+	for _, repo := range catalog.Repositories {
+		if name := strings.TrimPrefix(repo, prefix); name != repo || prefix == "" {
+			if !strings.Contains(name, "/") {
+				charts = append(charts, Chart{Name: name})
+			}
+		}
+	}
+	sort.Slice(charts, func(i, j int) bool {
+		return charts[i].Name < charts[j].Name
+	})
+	return
+}
+
+// ListVersions returns the tags of a chart which are semantic versions.
+// The plus sign is not allowed in the OCI tags, so Helm replaces it with an underscore.
+func (l *ociLister) ListVersions(ctx context.Context, chart string) (versions []Version, err error) {
+	repo := strings.TrimPrefix(l.path+"/"+chart, "/")
+	next := fmt.Sprintf("/v2/%s/tags/list", repo)
+	versions = []Version{}
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		tagList := &ociTagList{}
+		var res *http.Response
+		if res, err = l.get(ctx, next, fmt.Sprintf("repository:%s:pull", repo)); err != nil {
+			return
+		}
+		if res.StatusCode == http.StatusNotFound {
+			_ = res.Body.Close()
+			err = &NotFoundError{Chart: chart}
+			return
+		}
+		if err = decodeJSON(res, tagList); err != nil {
+			return
+		}
+		for _, tag := range tagList.Tags {
+			version := strings.ReplaceAll(tag, "_", "+")
+			if _, parseErr := semver.ParseTolerant(version); parseErr == nil {
+				versions = append(versions, Version{Version: version})
+			}
+		}
+		next = getNextLink(res.Header.Get("Link"))
+	}
+	sortVersions(versions)
+	return
+}
+
+func (l *ociLister) getJSON(ctx context.Context, path, scope string, obj interface{}) error {
+	res, err := l.get(ctx, path, scope)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(res, obj)
+}
+
+// get sends a request to the registry, the credential is sent once the registry asks for it
+func (l *ociLister) get(ctx context.Context, path, scope string) (res *http.Response, err error) {
+	if res, err = l.do(ctx, path, ""); err != nil || res.StatusCode != http.StatusUnauthorized {
+		return
+	}
+	challenge := res.Header.Get("WWW-Authenticate")
+	_ = res.Body.Close()
+
+	var authorization string
+	if authorization, err = l.authorize(ctx, challenge, scope); err != nil {
+		return
+	}
+	return l.do(ctx, path, authorization)
+}
+
+func (l *ociLister) do(ctx context.Context, path, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.registry+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return l.httpClient.Do(req)
+}
+
+var challengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authorize returns the value of the Authorization header according to the challenge of the registry.
+// See also: https://docs.docker.com/registry/spec/auth/token/
+func (l *ociLister) authorize(ctx context.Context, challenge, scope string) (authorization string, err error) {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	if scheme == "basic" {
+		req := &http.Request{Header: http.Header{}}
+		l.auth.set(req)
+		authorization = req.Header.Get("Authorization")
+		return
+	}
+	if scheme != "bearer" {
+		err = fmt.Errorf("unsupported authentication challenge %q of %s", challenge, l.registry)
+		return
+	}
+
+	params := map[string]string{}
+	for _, match := range challengeParamRegex.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	var realm *url.URL
+	if realm, err = url.Parse(params["realm"]); err != nil || (realm.Scheme != "https" && realm.Scheme != "http") ||
+		realm.Host == "" {
+		err = fmt.Errorf("invalid realm in the authentication challenge %q of %s", challenge, l.registry)
+		return
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if params["scope"] != "" {
+		scope = params["scope"]
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil); err != nil {
+		return
+	}
+	l.auth.set(req)
+	var res *http.Response
+	if res, err = l.httpClient.Do(req); err != nil {
+		return
+	}
+	token := &struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err = decodeJSON(res, token); err != nil {
+		return
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	authorization = "Bearer " + token.Token
+	return
+}
+
+func decodeJSON(res *http.Response, obj interface{}) error {
+	data, err := readBody(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, obj)
+}
+
+var nextLinkRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="?next"?`)
+
+// getNextLink returns the path of the next page from the Link header, such as: </v2/a/tags/list?n=2&last=b>; rel="next"
+func getNextLink(link string) string {
+	if match := nextLinkRegex.FindStringSubmatch(link); match != nil {
+		if u, err := url.Parse(match[1]); err == nil {
+			return u.RequestURI()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chart
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+// newFakeRegistry creates a registry which requires the bearer token issued by its token endpoint
+func newFakeRegistry(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write := func(body string) {
+			_, err := w.Write([]byte(body))
+			assert.Nil(t, err)
+		}
+		if r.URL.Path == "/token" {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "admin" || password != "secret" || r.URL.Query().Get("service") != "fake" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			write(fmt.Sprintf(`{"token": "token-of-%s"}`, r.URL.Query().Get("scope")))
+			return
+		}
+
+		scope := "registry:catalog:*"
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			scope = fmt.Sprintf("repository:%s:pull", strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v2/"),
+				"/tags/list"))
+		}
+		if r.Header.Get("Authorization") != "Bearer token-of-"+scope {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="%s"`,
+				server.URL, scope))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/_catalog":
+			write(`{"repositories": ["library/nginx", "team/charts/podinfo", "team/charts/redis", "team/charts/nested/mysql"]}`)
+		case "/v2/team/charts/podinfo/tags/list":
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/team/charts/podinfo/tags/list?n=2&last=6.2.0>; rel="next"`)
+				write(`{"name": "team/charts/podinfo", "tags": ["6.1.0", "6.2.0"]}`)
+			} else {
+				write(`{"name": "team/charts/podinfo", "tags": ["6.2.1_build.1", "sha256-abc.sig"]}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			write(`{"errors": [{"code": "NAME_UNKNOWN"}]}`)
+		}
+	}))
+	return server
+}
+
+func TestOCILister(t *testing.T) {
+	server := newFakeRegistry(t)
+	defer server.Close()
+
+	repo := &v1alpha1.ChartRepository{Spec: v1alpha1.ChartRepositorySpec{
+		Type: v1alpha1.ChartRepositoryTypeOCI,
+		URL:  strings.Replace(server.URL, "https://", "oci://", 1) + "/team/charts",
+	}}
+	secret := &corev1.Secret{Data: map[string][]byte{"username": []byte("admin"), "password": []byte("secret")}}
+	lister, err := NewLister(repo, secret, server.Client())
+	assert.Nil(t, err)
+
+	charts, err := lister.ListCharts(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []Chart{{Name: "podinfo"}, {Name: "redis"}}, charts)
+
+	versions, err := lister.ListVersions(context.TODO(), "podinfo")
+	assert.Nil(t, err)
+	assert.Equal(t, []Version{{Version: "6.2.1+build.1"}, {Version: "6.2.0"}, {Version: "6.1.0"}}, versions)
+
+	_, err = lister.ListVersions(context.TODO(), "mysql")
+	assert.IsType(t, &NotFoundError{}, err)
+
+	// without the credential
+	lister, err = NewLister(repo, nil, server.Client())
+	assert.Nil(t, err)
+	_, err = lister.ListVersions(context.TODO(), "podinfo")
+	assert.Contains(t, err.Error(), "unexpected status code 401")
+}
+
+func Test_getNextLink(t *testing.T) {
+	assert.Equal(t, "/v2/a/tags/list?n=2&last=b", getNextLink(`</v2/a/tags/list?n=2&last=b>; rel="next"`))
+	assert.Equal(t, "/v2/a/tags/list?last=b", getNextLink(`<https://ghcr.io/v2/a/tags/list?last=b>; rel=next`))
+	assert.Equal(t, "", getNextLink(""))
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/config"
+)
+
+// ManagedResource is a resource managed by an Argo CD Application, the states are in JSON format
+type ManagedResource struct {
+	Group               string `json:"group,omitempty"`
+	Kind                string `json:"kind,omitempty"`
+	Namespace           string `json:"namespace,omitempty"`
+	Name                string `json:"name,omitempty"`
+	TargetState         string `json:"targetState,omitempty"`
+	LiveState           string `json:"liveState,omitempty"`
+	NormalizedLiveState string `json:"normalizedLiveState,omitempty"`
+	PredictedLiveState  string `json:"predictedLiveState,omitempty"`
+}
+
+// ArgoCDClient gets the managed resources of the Argo CD Applications
+type ArgoCDClient interface {
+	GetManagedResources(ctx context.Context, namespace, name string) ([]ManagedResource, error)
+}
+
+type argoCDClient struct {
+	server     string
+	token      string
+	httpClient *http.Client
+}
+
+// NewArgoCDClient creates a client of the Argo CD API server, it returns nil if the server is not configured
+func NewArgoCDClient(option *config.ArgoCDOption) ArgoCDClient {
+	if option == nil || option.Server == "" {
+		return nil
+	}
+	httpClient := &http.Client{}
+	if option.Insecure {
+		httpClient.Transport = &http.Transport{
+			// it is only enabled by the users explicitly, such as the Argo CD server with a self-signed certificate
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return &argoCDClient{
+		server:     strings.TrimSuffix(option.Server, "/"),
+		token:      option.Token,
+		httpClient: httpClient,
+	}
+}
+
+// GetManagedResources returns the managed resources of the Argo CD Application in the given namespace
+func (c *argoCDClient) GetManagedResources(ctx context.Context, namespace, name string) (
+	resources []ManagedResource, err error) {
+	api := fmt.Sprintf("%s/api/v1/applications/%s/managed-resources?appNamespace=%s", c.server,
+		url.PathEscape(name), url.QueryEscape(namespace))
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, api, nil); err != nil {
+		return
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	var resp *http.Response
+	if resp, err = c.httpClient.Do(req); err != nil {
+		return
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var data []byte
+	if data, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("failed to get the managed resources of Argo CD Application %s/%s, status code: %d, body: %s",
+			namespace, name, resp.StatusCode, string(data))
+		return
+	}
+
+	result := &struct {
+		Items []ManagedResource `json:"items"`
+	}{}
+	if err = json.Unmarshal(data, result); err == nil {
+		resources = result.Items
+	}
+	return
+}
+
+// ArgoCD returns the difference between the live and desired manifests of the resources managed by the
+// Argo CD Application. The differences ignored by the Application are not included.
+func ArgoCD(ctx context.Context, argoClient ArgoCDClient, app *v1alpha1.Application) (diffs []ResourceDiff, err error) {
+	if app.Spec.ArgoApp == nil {
+		return nil, fmt.Errorf("application %s/%s is not an Argo CD Application", app.Namespace, app.Name)
+	}
+	argoNamespace := app.Labels[v1alpha1.ArgoCDLocationLabelKey]
+	argoName := app.Labels[v1alpha1.ArgoCDAppNameLabelKey]
+	if argoName == "" {
+		argoName = app.Name
+	}
+
+	var resources []ManagedResource
+	if resources, err = argoClient.GetManagedResources(ctx, argoNamespace, argoName); err != nil {
+		return
+	}
+
+	diffs = []ResourceDiff{}
+	for _, resource := range resources {
+		var live, desired *unstructured.Unstructured
+		// the normalized and predicted states are the ones which Argo CD uses to compare
+		if live, err = parseState(resource.NormalizedLiveState, resource.LiveState); err != nil {
+			return
+		}
+		if desired, err = parseState(resource.PredictedLiveState, resource.TargetState); err != nil {
+			return
+		}
+
+		var result *ResourceDiff
+		if result, err = Compare(live, desired, app.Spec.ArgoApp.Spec.IgnoreDifferences); err != nil {
+			return
+		}
+		if result != nil {
+			diffs = append(diffs, *result)
+		}
+	}
+	return
+}
+
+// parseState parses the first non-empty state, the result is nil if the resource does not exist
+func parseState(states ...string) (obj *unstructured.Unstructured, err error) {
+	for _, state := range states {
+		if state == "" || state == "null" {
+			continue
+		}
+		obj = &unstructured.Unstructured{}
+		if err = json.Unmarshal([]byte(state), &obj.Object); err != nil {
+			err = fmt.Errorf("failed to parse the state of resource: %v", err)
+			obj = nil
+		}
+		return
+	}
+	return
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/config"
+)
+
+func TestArgoCDClient(t *testing.T) {
+	assert.Nil(t, NewArgoCDClient(nil))
+	assert.Nil(t, NewArgoCDClient(&config.ArgoCDOption{}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fake-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "/api/v1/applications/fake-app/managed-resources", r.URL.Path)
+		assert.Equal(t, "argocd", r.URL.Query().Get("appNamespace"))
+		_, _ = w.Write([]byte(`{"items": [{"group": "apps", "kind": "Deployment", "namespace": "default",
+  "name": "nginx", "targetState": "{}", "liveState": "null"}]}`))
+	}))
+	defer server.Close()
+
+	resources, err := NewArgoCDClient(&config.ArgoCDOption{Server: server.URL + "/", Token: "fake-token"}).
+		GetManagedResources(context.Background(), "argocd", "fake-app")
+	assert.NoError(t, err)
+	assert.Equal(t, []ManagedResource{{
+		Group:       "apps",
+		Kind:        "Deployment",
+		Namespace:   "default",
+		Name:        "nginx",
+		TargetState: "{}",
+		LiveState:   "null",
+	}}, resources)
+
+	_, err = NewArgoCDClient(&config.ArgoCDOption{Server: server.URL}).
+		GetManagedResources(context.Background(), "argocd", "fake-app")
+	assert.ErrorContains(t, err, "status code: 401")
+}
+
+type fakeArgoCDClient struct {
+	namespace, name string
+	resources       []ManagedResource
+	err             error
+}
+
+func (c *fakeArgoCDClient) GetManagedResources(_ context.Context, namespace, name string) ([]ManagedResource, error) {
+	c.namespace, c.name = namespace, name
+	return c.resources, c.err
+}
+
+func TestArgoCD(t *testing.T) {
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-app",
+			Namespace: "fake-namespace",
+			Labels: map[string]string{
+				v1alpha1.ArgoCDLocationLabelKey: "argocd",
+				v1alpha1.ArgoCDAppNameLabelKey:  "fake-app-abcde",
+			},
+		},
+		Spec: v1alpha1.ApplicationSpec{
+			ArgoApp: &v1alpha1.ArgoApplication{
+				Spec: v1alpha1.ArgoApplicationSpec{
+					IgnoreDifferences: []v1alpha1.ResourceIgnoreDifferences{{
+						Kind:         "ConfigMap",
+						JSONPointers: []string{"/data/ignored"},
+					}},
+				},
+			},
+		},
+	}
+
+	t.Run("not an Argo CD Application", func(t *testing.T) {
+		_, err := ArgoCD(context.Background(), &fakeArgoCDClient{}, &v1alpha1.Application{})
+		assert.Error(t, err)
+	})
+
+	t.Run("failed to get the managed resources", func(t *testing.T) {
+		_, err := ArgoCD(context.Background(), &fakeArgoCDClient{err: errors.New("fake")}, app)
+		assert.EqualError(t, err, "fake")
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		_, err := ArgoCD(context.Background(), &fakeArgoCDClient{resources: []ManagedResource{{
+			Kind: "ConfigMap", Name: "invalid", LiveState: "{",
+		}}}, app)
+		assert.Error(t, err)
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		argoClient := &fakeArgoCDClient{resources: []ManagedResource{{
+			Kind:               "ConfigMap",
+			Namespace:          "default",
+			Name:               "ignored",
+			LiveState:          `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "ignored", "namespace": "default"}, "data": {"ignored": "a"}}`,
+			PredictedLiveState: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "ignored", "namespace": "default"}, "data": {"ignored": "b"}}`,
+		}, {
+			Kind:                "ConfigMap",
+			Namespace:           "default",
+			Name:                "changed",
+			LiveState:           `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "changed", "namespace": "default", "uid": "fake"}, "data": {"key": "a"}}`,
+			NormalizedLiveState: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "changed", "namespace": "default"}, "data": {"key": "a"}}`,
+			TargetState:         `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "changed", "namespace": "default"}, "data": {"key": "b"}}`,
+		}, {
+			Kind:        "ConfigMap",
+			Namespace:   "default",
+			Name:        "created",
+			LiveState:   "null",
+			TargetState: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "created", "namespace": "default"}}`,
+		}}}
+		diffs, err := ArgoCD(context.Background(), argoClient, app)
+		assert.NoError(t, err)
+		assert.Equal(t, "argocd", argoClient.namespace)
+		assert.Equal(t, "fake-app-abcde", argoClient.name)
+		assert.Equal(t, []ResourceDiff{{
+			Kind:      "ConfigMap",
+			Namespace: "default",
+			Name:      "changed",
+			Diff: `--- live/ConfigMap default/changed
++++ desired/ConfigMap default/changed
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-  key: a
++  key: b
+ kind: ConfigMap
+ metadata:
+   name: changed
+`,
+		}, {
+			Kind:      "ConfigMap",
+			Namespace: "default",
+			Name:      "created",
+			Diff: `--- live/ConfigMap default/created
++++ desired/ConfigMap default/created
+@@ -0,0 +1,5 @@
++apiVersion: v1
++kind: ConfigMap
++metadata:
++  name: created
++  namespace: default
+`,
+		}}, diffs)
+	})
+}
@@ -0,0 +1,380 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package diff previews the changes which a sync makes to the resources of the gitops Applications.
+package diff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/yaml"
+)
+
+// ResourceDiff is the difference between the live and desired manifests of a resource
+type ResourceDiff struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Diff is the unified diff from the live manifest to the desired one.
+	// The live manifest is empty if the resource will be created, the desired one is empty if it will be pruned.
+	Diff string `json:"diff"`
+}
+
+// String returns a readable identity of the resource, such as: apps/Deployment default/nginx
+func (d ResourceDiff) String() string {
+	kind := d.Kind
+	if d.Group != "" {
+		kind = d.Group + "/" + d.Kind
+	}
+	if d.Namespace == "" {
+		return kind + " " + d.Name
+	}
+	return kind + " " + d.Namespace + "/" + d.Name
+}
+
+// Compare returns the difference between the live and desired objects, the result is nil if they are the same.
+// Either live or desired could be nil. The fields populated by the server and the fields ignored by the
+// ignoreDifferences rules are not compared.
+func Compare(live, desired *unstructured.Unstructured, ignores []v1alpha1.ResourceIgnoreDifferences) (
+	result *ResourceDiff, err error) {
+	obj := desired
+	if obj == nil {
+		obj = live
+	}
+	if obj == nil {
+		return
+	}
+	gvk := obj.GroupVersionKind()
+	result = &ResourceDiff{
+		Group:     gvk.Group,
+		Kind:      gvk.Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+
+	liveObj, desiredObj := copyObject(live), copyObject(desired)
+	if gvk.Group == "" && gvk.Kind == "Secret" {
+		hideSecretData(liveObj, desiredObj)
+	}
+	if err = normalize(liveObj, desiredObj, live, gvk.GroupKind(), result, ignores); err != nil {
+		return
+	}
+
+	if result.Diff, err = unifiedDiff(result.String(), liveObj, desiredObj); err == nil && result.Diff == "" {
+		result = nil
+	}
+	return
+}
+
+func copyObject(obj *unstructured.Unstructured) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	return obj.DeepCopy().Object
+}
+
+// hideSecretData replaces the values of the Secrets with the plus signs as Argo CD does. The same values have the
+// same replacement, so that the changed keys are still visible in the diff. The stringData is merged into the data.
+func hideSecretData(objs ...map[string]interface{}) {
+	keys := map[string]bool{}
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+		data, _, _ := unstructured.NestedMap(obj, "data")
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		stringData, _, _ := unstructured.NestedMap(obj, "stringData")
+		for key, value := range stringData {
+			data[key] = base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(value)))
+		}
+		unstructured.RemoveNestedField(obj, "stringData")
+		if len(data) > 0 {
+			obj["data"] = data
+		}
+		for key := range data {
+			keys[key] = true
+		}
+	}
+
+	for key := range keys {
+		replacements := map[string]string{}
+		nextReplacement := "++++++++"
+		for _, obj := range objs {
+			data, ok := obj["data"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			value, ok := data[key]
+			if !ok {
+				continue
+			}
+			replacement, ok := replacements[fmt.Sprint(value)]
+			if !ok {
+				replacement = nextReplacement
+				replacements[fmt.Sprint(value)] = replacement
+				nextReplacement += "+"
+			}
+			data[key] = replacement
+		}
+	}
+}
+
+// unifiedDiff returns the unified diff between the YAML formats of the live and desired objects
+func unifiedDiff(resource string, live, desired map[string]interface{}) (diff string, err error) {
+	var liveData, desiredData []byte
+	if live != nil {
+		if liveData, err = yaml.Marshal(live); err != nil {
+			return
+		}
+	}
+	if desired != nil {
+		if desiredData, err = yaml.Marshal(desired); err != nil {
+			return
+		}
+	}
+	if bytes.Equal(liveData, desiredData) {
+		return
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(liveData),
+		B:        splitLines(desiredData),
+		FromFile: "live/" + resource,
+		ToFile:   "desired/" + resource,
+		Context:  3,
+	})
+}
+
+// splitLines splits the data into lines which keep the line endings, there is no line if the data is empty
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// serverSideFields are populated by the Kubernetes API server, they are not the part of the desired state
+var serverSideFields = [][]string{
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+	{"status"},
+}
+
+// normalize removes the server-side fields and the fields ignored by the matched rules from the objects
+func normalize(liveObj, desiredObj map[string]interface{}, live *unstructured.Unstructured, gk schema.GroupKind,
+	resource *ResourceDiff, ignores []v1alpha1.ResourceIgnoreDifferences) error {
+	var pointers []string
+	var managers []string
+	for _, ignore := range ignores {
+		if !matchIgnoreDifferences(ignore, gk, resource.Namespace, resource.Name) {
+			continue
+		}
+		pointers = append(pointers, ignore.JSONPointers...)
+		for _, expression := range ignore.JQPathExpressions {
+			// only the plain path expressions are supported
+			if pointer, ok := jqPathToJSONPointer(expression); ok {
+				pointers = append(pointers, pointer)
+			}
+		}
+		managers = append(managers, ignore.ManagedFieldsManagers...)
+	}
+
+	var managedPaths []fieldpath.Path
+	if live != nil && len(managers) > 0 {
+		for _, entry := range live.GetManagedFields() {
+			if entry.FieldsV1 == nil || !contains(managers, entry.Manager) {
+				continue
+			}
+			fieldSet := &fieldpath.Set{}
+			if err := fieldSet.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+				return fmt.Errorf("failed to parse the managed fields of %s: %v", resource, err)
+			}
+			fieldSet.Leaves().Iterate(func(path fieldpath.Path) {
+				managedPaths = append(managedPaths, path.Copy())
+			})
+		}
+	}
+
+	for _, obj := range []map[string]interface{}{liveObj, desiredObj} {
+		if obj == nil {
+			continue
+		}
+		for _, fields := range serverSideFields {
+			unstructured.RemoveNestedField(obj, fields...)
+		}
+		if annotations, _, _ := unstructured.NestedMap(obj, "metadata", "annotations"); annotations != nil &&
+			len(annotations) == 0 {
+			unstructured.RemoveNestedField(obj, "metadata", "annotations")
+		}
+		for _, pointer := range pointers {
+			removeJSONPointer(obj, pointer)
+		}
+		for _, path := range managedPaths {
+			removeFieldPath(obj, path)
+		}
+	}
+	return nil
+}
+
+func matchIgnoreDifferences(ignore v1alpha1.ResourceIgnoreDifferences, gk schema.GroupKind, namespace, name string) bool {
+	return (ignore.Group == gk.Group || ignore.Group == "*") &&
+		(ignore.Kind == gk.Kind || ignore.Kind == "*") &&
+		(ignore.Namespace == "" || ignore.Namespace == namespace) &&
+		(ignore.Name == "" || ignore.Name == name)
+}
+
+// jqPathSegmentPattern matches the segments of a plain jq path, such as: .spec.replicas or .metadata.labels["app"]
+var jqPathSegmentPattern = regexp.MustCompile(`\.([A-Za-z0-9_-]+)|\["([^"]+)"]|\[([0-9]+)]`)
+
+// jqPathToJSONPointer converts a plain jq path expression to a JSON pointer
+func jqPathToJSONPointer(expression string) (pointer string, ok bool) {
+	expression = strings.TrimSpace(expression)
+	matches := jqPathSegmentPattern.FindAllStringSubmatchIndex(expression, -1)
+	if len(matches) == 0 {
+		return
+	}
+	end := 0
+	var segments []string
+	for _, match := range matches {
+		if match[0] != end {
+			// it's not a plain path expression, such as: select(...)
+			return
+		}
+		end = match[1]
+		for group := 1; group <= 3; group++ {
+			if match[group*2] >= 0 {
+				segment := expression[match[group*2]:match[group*2+1]]
+				segments = append(segments, strings.NewReplacer("~", "~0", "/", "~1").Replace(segment))
+			}
+		}
+	}
+	if end != len(expression) {
+		return
+	}
+	return "/" + strings.Join(segments, "/"), true
+}
+
+// removeJSONPointer removes the field which is referenced by the JSON pointer (RFC 6901) from the object
+func removeJSONPointer(obj map[string]interface{}, pointer string) {
+	if pointer == "" || pointer == "/" {
+		return
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[i])
+	}
+	removeTokens(obj, tokens)
+}
+
+// removeTokens removes the field which is referenced by the tokens of a JSON pointer from the node
+func removeTokens(node interface{}, tokens []string) interface{} {
+	token := tokens[0]
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		if len(tokens) == 1 {
+			delete(typed, token)
+		} else if child, ok := typed[token]; ok {
+			typed[token] = removeTokens(child, tokens[1:])
+		}
+	case []interface{}:
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index >= len(typed) {
+			return node
+		}
+		if len(tokens) == 1 {
+			return append(typed[:index:index], typed[index+1:]...)
+		}
+		typed[index] = removeTokens(typed[index], tokens[1:])
+	}
+	return node
+}
+
+// removeFieldPath removes the field which is referenced by the managed fields path from the node
+func removeFieldPath(node interface{}, path fieldpath.Path) interface{} {
+	element := path[0]
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		if element.FieldName == nil {
+			return node
+		}
+		if len(path) == 1 {
+			delete(typed, *element.FieldName)
+		} else if child, ok := typed[*element.FieldName]; ok {
+			typed[*element.FieldName] = removeFieldPath(child, path[1:])
+		}
+	case []interface{}:
+		for i, item := range typed {
+			if !matchPathElement(element, i, item) {
+				continue
+			}
+			if len(path) == 1 {
+				return append(typed[:i:i], typed[i+1:]...)
+			}
+			typed[i] = removeFieldPath(item, path[1:])
+			break
+		}
+	}
+	return node
+}
+
+// matchPathElement checks if the list item is referenced by the path element
+func matchPathElement(element fieldpath.PathElement, index int, item interface{}) bool {
+	switch {
+	case element.Index != nil:
+		return *element.Index == index
+	case element.Value != nil:
+		return fmt.Sprint((*element.Value).Unstructured()) == fmt.Sprint(item)
+	case element.Key != nil:
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, key := range *element.Key {
+			if fmt.Sprint(key.Value.Unstructured()) != fmt.Sprint(itemMap[key.Name]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
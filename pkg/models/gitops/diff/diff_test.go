@@ -0,0 +1,289 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+)
+
+func newDeployment(replicas int64, image string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "nginx",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{
+						"name":  "nginx",
+						"image": image,
+					}},
+				},
+			},
+		},
+	}}
+}
+
+func TestCompare(t *testing.T) {
+	liveWithServerFields := newDeployment(1, "nginx:1")
+	liveWithServerFields.SetResourceVersion("100")
+	liveWithServerFields.SetUID("uid")
+	liveWithServerFields.SetAnnotations(map[string]string{
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+	})
+	liveWithServerFields.Object["status"] = map[string]interface{}{"replicas": int64(1)}
+
+	liveScaledByHPA := newDeployment(3, "nginx:1")
+	liveScaledByHPA.SetManagedFields([]metav1.ManagedFieldsEntry{{
+		Manager:  "kube-controller-manager",
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+	}, {
+		Manager: "kubectl",
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(
+			`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"nginx\"}":{"f:image":{}}}}}}}`)},
+	}})
+
+	tests := []struct {
+		name     string
+		live     *unstructured.Unstructured
+		desired  *unstructured.Unstructured
+		ignores  []v1alpha1.ResourceIgnoreDifferences
+		wantNil  bool
+		wantDiff string
+	}{{
+		name: "both are nil",
+	}, {
+		name:    "the same except the server-side fields",
+		live:    liveWithServerFields,
+		desired: newDeployment(1, "nginx:1"),
+		wantNil: true,
+	}, {
+		name:    "different image",
+		live:    newDeployment(1, "nginx:1"),
+		desired: newDeployment(1, "nginx:2"),
+		wantDiff: `--- live/apps/Deployment default/nginx
++++ desired/apps/Deployment default/nginx
+@@ -8,5 +8,5 @@
+   template:
+     spec:
+       containers:
+-      - image: nginx:1
++      - image: nginx:2
+         name: nginx
+`,
+	}, {
+		name:    "to be created",
+		desired: newDeployment(1, "nginx:1"),
+		wantDiff: `--- live/apps/Deployment default/nginx
++++ desired/apps/Deployment default/nginx
+@@ -0,0 +1,12 @@
++apiVersion: apps/v1
+`,
+	}, {
+		name: "to be pruned",
+		live: newDeployment(1, "nginx:1"),
+		wantDiff: `--- live/apps/Deployment default/nginx
++++ desired/apps/Deployment default/nginx
+@@ -1,12 +0,0 @@
+-apiVersion: apps/v1
+`,
+	}, {
+		name:    "ignored by JSON pointers",
+		live:    newDeployment(1, "nginx:1"),
+		desired: newDeployment(2, "nginx:2"),
+		ignores: []v1alpha1.ResourceIgnoreDifferences{{
+			Group:        "apps",
+			Kind:         "Deployment",
+			JSONPointers: []string{"/spec/replicas", "/spec/template/spec/containers/0/image"},
+		}},
+		wantNil: true,
+	}, {
+		name:    "ignored by jq path expressions",
+		live:    newDeployment(1, "nginx:1"),
+		desired: newDeployment(2, "nginx:1"),
+		ignores: []v1alpha1.ResourceIgnoreDifferences{{
+			Group:             "*",
+			Kind:              "Deployment",
+			Namespace:         "default",
+			JQPathExpressions: []string{`.spec["replicas"]`},
+		}},
+		wantNil: true,
+	}, {
+		name:    "ignored by managed fields managers",
+		live:    liveScaledByHPA,
+		desired: newDeployment(1, "nginx:2"),
+		ignores: []v1alpha1.ResourceIgnoreDifferences{{
+			Group:                 "apps",
+			Kind:                  "Deployment",
+			ManagedFieldsManagers: []string{"kube-controller-manager", "kubectl"},
+		}},
+		wantNil: true,
+	}, {
+		name:    "the rule does not match the resource",
+		live:    newDeployment(1, "nginx:1"),
+		desired: newDeployment(2, "nginx:1"),
+		ignores: []v1alpha1.ResourceIgnoreDifferences{{
+			Group:        "apps",
+			Kind:         "Deployment",
+			Name:         "another",
+			JSONPointers: []string{"/spec/replicas"},
+		}},
+		wantDiff: `--- live/apps/Deployment default/nginx
++++ desired/apps/Deployment default/nginx
+@@ -4,7 +4,7 @@
+   name: nginx
+   namespace: default
+ spec:
+-  replicas: 1
++  replicas: 2
+   template:
+     spec:
+       containers:
+`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Compare(tt.live, tt.desired, tt.ignores)
+			assert.NoError(t, err)
+			if tt.wantDiff == "" {
+				assert.Nil(t, result)
+				return
+			}
+			assert.NotNil(t, result)
+			assert.Equal(t, "apps", result.Group)
+			assert.Equal(t, "Deployment", result.Kind)
+			assert.Equal(t, "default", result.Namespace)
+			assert.Equal(t, "nginx", result.Name)
+			assert.Contains(t, result.Diff, tt.wantDiff)
+		})
+	}
+}
+
+func TestCompare_secret(t *testing.T) {
+	newSecret := func(data, stringData map[string]interface{}) *unstructured.Unstructured {
+		secret := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "token", "namespace": "default"},
+		}}
+		if data != nil {
+			secret.Object["data"] = data
+		}
+		if stringData != nil {
+			secret.Object["stringData"] = stringData
+		}
+		return secret
+	}
+
+	live := newSecret(map[string]interface{}{"password": "b2xk", "username": "YWRtaW4="}, nil)
+	desired := newSecret(map[string]interface{}{"username": "YWRtaW4="},
+		map[string]interface{}{"password": "new", "token": "abc"})
+	result, err := Compare(live, desired, nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, `--- live/Secret default/token
++++ desired/Secret default/token
+@@ -1,6 +1,7 @@
+ apiVersion: v1
+ data:
+-  password: ++++++++
++  password: +++++++++
++  token: ++++++++
+   username: ++++++++
+ kind: Secret
+ metadata:
+`, result.Diff)
+		for _, value := range []string{"b2xk", "bmV3", "new", "abc", "YWRtaW4="} {
+			assert.NotContains(t, result.Diff, value)
+		}
+	}
+	// the objects are not changed
+	assert.Equal(t, "new", desired.Object["stringData"].(map[string]interface{})["password"])
+
+	// the same values
+	result, err = Compare(live, newSecret(nil, map[string]interface{}{"password": "old", "username": "admin"}), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestJQPathToJSONPointer(t *testing.T) {
+	tests := []struct {
+		expression  string
+		wantPointer string
+		wantOK      bool
+	}{{
+		expression:  ".spec.replicas",
+		wantPointer: "/spec/replicas",
+		wantOK:      true,
+	}, {
+		expression:  `.metadata.annotations["example.com/a~b"]`,
+		wantPointer: "/metadata/annotations/example.com~1a~0b",
+		wantOK:      true,
+	}, {
+		expression:  ".spec.template.spec.containers[0].image",
+		wantPointer: "/spec/template/spec/containers/0/image",
+		wantOK:      true,
+	}, {
+		expression: `.spec.template.spec.initContainers[] | select(.name == "injected")`,
+	}, {
+		expression: "spec.replicas",
+	}, {
+		expression: "",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			pointer, ok := jqPathToJSONPointer(tt.expression)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantPointer, pointer)
+		})
+	}
+}
+
+func TestRemoveJSONPointer(t *testing.T) {
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"example.com/key": "value", "keep": "value"},
+		},
+		"items": []interface{}{"a", "b", "c"},
+	}
+	removeJSONPointer(obj, "/metadata/annotations/example.com~1key")
+	removeJSONPointer(obj, "/items/1")
+	removeJSONPointer(obj, "/items/10")
+	removeJSONPointer(obj, "/not/found")
+	removeJSONPointer(obj, "")
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"keep": "value"},
+		},
+		"items": []interface{}{"a", "c"},
+	}, obj)
+}
+
+func TestResourceDiffString(t *testing.T) {
+	assert.Equal(t, "apps/Deployment default/nginx", ResourceDiff{Group: "apps", Kind: "Deployment",
+		Namespace: "default", Name: "nginx"}.String())
+	assert.Equal(t, "Namespace default", ResourceDiff{Kind: "Namespace", Name: "default"}.String())
+}
@@ -0,0 +1,390 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	// kustomizeControllerManager is the field manager which FluxCD applies the resources with
+	kustomizeControllerManager = "kustomize-controller"
+	// defaultSourceAPIVersion is the API version of the FluxCD sources if it's missing in the source reference
+	defaultSourceAPIVersion = "source.toolkit.fluxcd.io/v1beta2"
+	// maxArtifactSize is the max size of the uncompressed files in an artifact
+	maxArtifactSize = 100 << 20
+	// sopsField is the field which holds the metadata of the resources encrypted by SOPS
+	sopsField = "sops"
+	// dryRunClusterRole grants the server-side dry-run permission once it's bound in a namespace
+	dryRunClusterRole = "ks-devops-drift-dry-run-role"
+)
+
+// kustomizationFileNames are the names of the kustomize configuration files, they are not the resources to apply
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Differ previews the changes which a sync will make to the resources of an Application
+type Differ interface {
+	Diff(ctx context.Context, app *v1alpha1.Application) ([]ResourceDiff, error)
+}
+
+// KustomizationDiffer previews the changes which the FluxCD Kustomizations of an Application will make.
+// The path of the source artifact is rendered with kustomize, the patches and images in the spec of the
+// Kustomization are included. The rendered resources are applied with the server-side dry-run, then the results
+// are compared with the live resources. The post build variable substitution is not supported, and the Secrets are
+// skipped. The resources of the host cluster must be in the namespace of the Application or the target namespace of
+// the Kustomization, because they are read with the service account of ks-devops instead of the tenant.
+type KustomizationDiffer struct {
+	client.Client
+	// fetch downloads the artifact of a FluxCD source
+	fetch func(ctx context.Context, url string) (io.ReadCloser, error)
+	// dryRun applies the object with the server-side dry-run, the object is updated with the result
+	dryRun func(ctx context.Context, c client.Client, obj *unstructured.Unstructured) error
+	// newClient creates a client of a member cluster from its kubeconfig
+	newClient func(kubeconfig []byte) (client.Client, error)
+}
+
+var _ Differ = &KustomizationDiffer{}
+
+// NewKustomizationDiffer creates a KustomizationDiffer
+func NewKustomizationDiffer(c client.Client) *KustomizationDiffer {
+	return &KustomizationDiffer{
+		Client:    c,
+		fetch:     fetchArtifact,
+		dryRun:    serverSideDryRun,
+		newClient: newClientFromKubeConfig,
+	}
+}
+
+// Diff returns the difference between the live resources and the manifests in the sources of
+// the FluxCD Kustomizations which belong to the Application
+func (d *KustomizationDiffer) Diff(ctx context.Context, app *v1alpha1.Application) (diffs []ResourceDiff, err error) {
+	kusList := &kusv1.KustomizationList{}
+	if err = d.List(ctx, kusList, client.InNamespace(app.Namespace), client.MatchingLabels{
+		"app.kubernetes.io/managed-by": app.Name,
+	}); err != nil {
+		return
+	}
+	sort.Slice(kusList.Items, func(i, j int) bool {
+		return kusList.Items[i].Name < kusList.Items[j].Name
+	})
+
+	diffs = []ResourceDiff{}
+	for i := range kusList.Items {
+		var kusDiffs []ResourceDiff
+		if kusDiffs, err = d.diffKustomization(ctx, &kusList.Items[i]); err != nil {
+			err = fmt.Errorf("failed to preview the changes of Kustomization %s/%s: %v", kusList.Items[i].Namespace,
+				kusList.Items[i].Name, err)
+			return
+		}
+		diffs = append(diffs, kusDiffs...)
+	}
+	return
+}
+
+func (d *KustomizationDiffer) diffKustomization(ctx context.Context, kus *kusv1.Kustomization) (diffs []ResourceDiff, err error) {
+	var artifactURL string
+	if artifactURL, err = d.getArtifactURL(ctx, kus); err != nil {
+		return
+	}
+	var objects []*unstructured.Unstructured
+	if objects, err = d.loadManifests(ctx, artifactURL, kus); err != nil {
+		return
+	}
+	var targetClient client.Client
+	if targetClient, err = d.getTargetClient(ctx, kus); err != nil {
+		return
+	}
+
+	desiredIDs := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		if err = setNamespace(targetClient, obj, kus.Spec.TargetNamespace); err != nil {
+			return
+		}
+		desiredIDs[inventoryID(obj)] = true
+		if isSecret(obj.GroupVersionKind()) {
+			continue
+		}
+		if err = checkNamespace(kus, obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName()); err != nil {
+			return
+		}
+
+		var live *unstructured.Unstructured
+		if live, err = getLiveObject(ctx, targetClient, obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName()); err != nil {
+			err = fmt.Errorf("failed to get %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+			return
+		}
+		desired := obj.DeepCopy()
+		if err = d.dryRun(ctx, targetClient, desired); err != nil {
+			if apierrors.IsForbidden(err) {
+				err = fmt.Errorf("%v, bind the ClusterRole %s in namespace %s to allow it", err, dryRunClusterRole,
+					bindingNamespace(obj))
+			}
+			err = fmt.Errorf("server-side dry-run failed for %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(),
+				obj.GetName(), err)
+			return
+		}
+
+		var result *ResourceDiff
+		if result, err = Compare(live, desired, nil); err != nil {
+			return
+		}
+		if result != nil {
+			diffs = append(diffs, *result)
+		}
+	}
+
+	if !kus.Spec.Prune || kus.Status.Inventory == nil {
+		return
+	}
+	// the resources which were applied but removed from the source will be pruned
+	for _, entry := range kus.Status.Inventory.Entries {
+		if desiredIDs[entry.ID] {
+			continue
+		}
+		parts := strings.SplitN(entry.ID, "_", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		gvk := schema.GroupVersionKind{Group: parts[2], Version: entry.Version, Kind: parts[3]}
+		if isSecret(gvk) {
+			continue
+		}
+		if err = checkNamespace(kus, gvk, parts[0], parts[1]); err != nil {
+			return
+		}
+		var live *unstructured.Unstructured
+		if live, err = getLiveObject(ctx, targetClient, gvk, parts[0], parts[1]); err != nil {
+			return
+		}
+
+		var result *ResourceDiff
+		if result, err = Compare(live, nil, nil); err != nil {
+			return
+		}
+		if result != nil {
+			diffs = append(diffs, *result)
+		}
+	}
+	return
+}
+
+// getArtifactURL returns the URL of the latest artifact of the source which the Kustomization refers to
+func (d *KustomizationDiffer) getArtifactURL(ctx context.Context, kus *kusv1.Kustomization) (artifactURL string, err error) {
+	sourceRef := kus.Spec.SourceRef
+	source := &unstructured.Unstructured{}
+	apiVersion := sourceRef.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultSourceAPIVersion
+	}
+	source.SetAPIVersion(apiVersion)
+	source.SetKind(sourceRef.Kind)
+	namespace := sourceRef.Namespace
+	if namespace == "" {
+		namespace = kus.Namespace
+	}
+	if err = d.Get(ctx, types.NamespacedName{Namespace: namespace, Name: sourceRef.Name}, source); err != nil {
+		return
+	}
+	if artifactURL, _, _ = unstructured.NestedString(source.Object, "status", "artifact", "url"); artifactURL == "" {
+		err = fmt.Errorf("the artifact of %s %s/%s is not ready", sourceRef.Kind, namespace, sourceRef.Name)
+	}
+	return
+}
+
+// loadManifests renders the resources in the artifact with kustomize as FluxCD does
+func (d *KustomizationDiffer) loadManifests(ctx context.Context, artifactURL string, kus *kusv1.Kustomization) (
+	objects []*unstructured.Unstructured, err error) {
+	var artifact io.ReadCloser
+	if artifact, err = d.fetch(ctx, artifactURL); err != nil {
+		return
+	}
+	defer func() {
+		_ = artifact.Close()
+	}()
+
+	var fs filesys.FileSystem
+	if fs, err = extractArtifact(artifact); err != nil {
+		return
+	}
+	var rendered []*unstructured.Unstructured
+	if rendered, err = buildKustomization(fs, kus); err != nil {
+		return
+	}
+	for _, obj := range rendered {
+		if _, encrypted := obj.Object[sopsField]; encrypted && kus.Spec.Decryption != nil {
+			// it cannot be compared without the private key, FluxCD decrypts it before applying
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return
+}
+
+func isManifestFile(name string) bool {
+	base := path.Base(name)
+	for _, kustomizationFileName := range kustomizationFileNames {
+		if base == kustomizationFileName {
+			return false
+		}
+	}
+	ext := path.Ext(base)
+	return ext == ".yaml" || ext == ".yml" || ext == ".json"
+}
+
+// getTargetClient returns the client of the cluster which the Kustomization applies to
+func (d *KustomizationDiffer) getTargetClient(ctx context.Context, kus *kusv1.Kustomization) (client.Client, error) {
+	if kus.Spec.KubeConfig == nil {
+		return d.Client, nil
+	}
+	secret := &corev1.Secret{}
+	if err := d.Get(ctx, types.NamespacedName{Namespace: kus.Namespace, Name: kus.Spec.KubeConfig.SecretRef.Name},
+		secret); err != nil {
+		return nil, err
+	}
+	// the same keys as FluxCD
+	for _, key := range []string{"value", "value.yaml"} {
+		if kubeconfig, ok := secret.Data[key]; ok {
+			return d.newClient(kubeconfig)
+		}
+	}
+	return nil, fmt.Errorf("cannot find the kubeconfig in secret %s/%s", secret.Namespace, secret.Name)
+}
+
+// setNamespace sets the namespace of a namespaced object as FluxCD does
+func setNamespace(c client.Client, obj *unstructured.Unstructured, targetNamespace string) error {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			// the type might be defined by a CRD in the same source, let the dry-run report the error
+			return nil
+		}
+		return err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return nil
+	}
+	if targetNamespace != "" {
+		obj.SetNamespace(targetNamespace)
+	} else if obj.GetNamespace() == "" {
+		obj.SetNamespace(corev1.NamespaceDefault)
+	}
+	return nil
+}
+
+// isSecret returns true if it's a Secret. The Secrets are never previewed, so their values are never exposed
+func isSecret(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// checkNamespace makes sure the resource of the host cluster is in the namespace of the Application or the target
+// namespace of the Kustomization, so the resources of the other tenants cannot be read through the diff. The only
+// allowed cluster scoped resources are these namespaces. The member clusters are accessed with their own kubeconfig.
+func checkNamespace(kus *kusv1.Kustomization, gvk schema.GroupVersionKind, namespace, name string) error {
+	if kus.Spec.KubeConfig != nil {
+		return nil
+	}
+	isAllowed := func(ns string) bool {
+		return ns != "" && (ns == kus.Namespace || ns == kus.Spec.TargetNamespace)
+	}
+	if namespace == "" {
+		if gvk.Group == "" && gvk.Kind == "Namespace" && isAllowed(name) {
+			return nil
+		}
+		return fmt.Errorf("cannot preview the cluster scoped resource %s %s", gvk.Kind, name)
+	}
+	if !isAllowed(namespace) {
+		return fmt.Errorf("cannot preview %s %s/%s, it's out of the namespaces of the Application", gvk.Kind,
+			namespace, name)
+	}
+	return nil
+}
+
+// inventoryID returns the ID of the object in the inventory of a Kustomization
+func inventoryID(obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	return strings.Join([]string{obj.GetNamespace(), obj.GetName(), gvk.Group, gvk.Kind}, "_")
+}
+
+// getLiveObject returns the object in the cluster, it's nil if not found
+func getLiveObject(ctx context.Context, c client.Client, gvk schema.GroupVersionKind, namespace, name string) (
+	*unstructured.Unstructured, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(gvk)
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, live); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return live, nil
+}
+
+func fetchArtifact(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("failed to download artifact from %s, status code: %d", url, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// bindingNamespace returns the namespace where the dry-run permission of the object is bound
+func bindingNamespace(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" && obj.GetKind() == "Namespace" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace()
+}
+
+func serverSideDryRun(ctx context.Context, c client.Client, obj *unstructured.Unstructured) error {
+	return c.Patch(ctx, obj, client.Apply, client.DryRunAll, client.ForceOwnership,
+		client.FieldOwner(kustomizeControllerManager))
+}
+
+func newClientFromKubeConfig(kubeconfig []byte) (client.Client, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
+	}
+	return client.New(config, client.Options{})
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func createArtifact(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		assert.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+func TestKustomizationDiffer(t *testing.T) {
+	schema := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(schema))
+	assert.NoError(t, kusv1.AddToScheme(schema))
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-app", Namespace: "fake-namespace"},
+	}
+	kus := &kusv1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-app-abcde",
+			Namespace: "fake-namespace",
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "fake-app"},
+		},
+		Spec: kusv1.KustomizationSpec{
+			SourceRef:       kusv1.CrossNamespaceSourceReference{Kind: "GitRepository", Name: "fake-repo"},
+			Path:            "./deploy",
+			Prune:           true,
+			TargetNamespace: "target",
+		},
+		Status: kusv1.KustomizationStatus{
+			Inventory: &kusv1.ResourceInventory{Entries: []kusv1.ResourceRef{
+				{ID: "target_changed__ConfigMap", Version: "v1"},
+				{ID: "target_pruned__ConfigMap", Version: "v1"},
+				{ID: "target_gone__ConfigMap", Version: "v1"},
+			}},
+		},
+	}
+	newRepo := func(artifactURL string) *unstructured.Unstructured {
+		repo := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"artifact": map[string]interface{}{"url": artifactURL}},
+		}}
+		repo.SetAPIVersion(defaultSourceAPIVersion)
+		repo.SetKind("GitRepository")
+		repo.SetNamespace("fake-namespace")
+		repo.SetName("fake-repo")
+		return repo
+	}
+	repo := newRepo("http://source-controller/fake.tar.gz")
+	changed := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "changed", Namespace: "target", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "a"},
+	}
+	unchanged := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "unchanged", Namespace: "target"},
+		Data:       map[string]string{"key": "a"},
+	}
+	pruned := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pruned", Namespace: "target"},
+	}
+	artifact := createArtifact(t, map[string]string{
+		"deploy/configmaps.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: changed
+data:
+  key: b
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unchanged
+  namespace: another
+data:
+  key: a
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: skipped
+stringData:
+  password: b
+`,
+		"./deploy/namespace.json":   `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "target"}}`,
+		"deploy/kustomization.yaml": `resources: [configmaps.yaml, namespace.json]`,
+		"deploy/README.md":          `# not a manifest`,
+		"others/configmap.yaml":     `apiVersion: v1`,
+	})
+
+	newDiffer := func(objects ...client.Object) *KustomizationDiffer {
+		c := fake.NewClientBuilder().WithScheme(schema).WithRESTMapper(mapper).WithObjects(objects...).Build()
+		differ := NewKustomizationDiffer(c)
+		differ.fetch = func(_ context.Context, url string) (io.ReadCloser, error) {
+			assert.Equal(t, "http://source-controller/fake.tar.gz", url)
+			return io.NopCloser(bytes.NewReader(artifact)), nil
+		}
+		differ.dryRun = func(_ context.Context, _ client.Client, obj *unstructured.Unstructured) error {
+			// the server populates some fields
+			obj.SetResourceVersion("2")
+			obj.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: kustomizeControllerManager}})
+			return nil
+		}
+		return differ
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		diffs, err := newDiffer(kus, repo, changed, unchanged, pruned).Diff(context.Background(), app)
+		assert.NoError(t, err)
+		var names []string
+		for _, resourceDiff := range diffs {
+			names = append(names, resourceDiff.String())
+		}
+		assert.Equal(t, []string{"ConfigMap target/changed", "Namespace target", "ConfigMap target/pruned"}, names)
+		assert.Contains(t, diffs[0].Diff, "-  key: a\n+  key: b\n")
+		assert.True(t, strings.HasSuffix(diffs[2].Diff, "@@ -1,5 +0,0 @@\n-apiVersion: v1\n-kind: ConfigMap\n-metadata:\n-  name: pruned\n-  namespace: target\n"))
+	})
+
+	t.Run("no Kustomizations", func(t *testing.T) {
+		diffs, err := newDiffer().Diff(context.Background(), app)
+		assert.NoError(t, err)
+		assert.Empty(t, diffs)
+	})
+
+	t.Run("artifact is not ready", func(t *testing.T) {
+		_, err := newDiffer(kus, newRepo("")).Diff(context.Background(), app)
+		assert.ErrorContains(t, err, "the artifact of GitRepository fake-namespace/fake-repo is not ready")
+	})
+
+	t.Run("dry-run failed", func(t *testing.T) {
+		differ := newDiffer(kus, repo)
+		differ.dryRun = func(context.Context, client.Client, *unstructured.Unstructured) error {
+			return errors.New("fake error")
+		}
+		_, err := differ.Diff(context.Background(), app)
+		assert.ErrorContains(t, err, "server-side dry-run failed for ConfigMap target/changed: fake error")
+	})
+
+	t.Run("dry-run is forbidden", func(t *testing.T) {
+		differ := newDiffer(kus, repo)
+		differ.dryRun = func(_ context.Context, _ client.Client, obj *unstructured.Unstructured) error {
+			return apierrors.NewForbidden(corev1.Resource("configmaps"), obj.GetName(),
+				errors.New("fake error"))
+		}
+		_, err := differ.Diff(context.Background(), app)
+		assert.ErrorContains(t, err, "bind the ClusterRole ks-devops-drift-dry-run-role in namespace target")
+	})
+
+	t.Run("out of the namespaces of the Application", func(t *testing.T) {
+		noTargetKus := kus.DeepCopy()
+		noTargetKus.Spec.TargetNamespace = ""
+		_, err := newDiffer(noTargetKus, repo).Diff(context.Background(), app)
+		assert.ErrorContains(t, err, "cannot preview ConfigMap default/changed, it's out of the namespaces of the Application")
+	})
+
+	t.Run("pruned resources out of the namespaces of the Application", func(t *testing.T) {
+		otherKus := kus.DeepCopy()
+		otherKus.Status.Inventory.Entries = append(otherKus.Status.Inventory.Entries,
+			kusv1.ResourceRef{ID: "other_db__ConfigMap", Version: "v1"})
+		_, err := newDiffer(otherKus, repo).Diff(context.Background(), app)
+		assert.ErrorContains(t, err, "cannot preview ConfigMap other/db, it's out of the namespaces of the Application")
+	})
+
+	t.Run("member cluster", func(t *testing.T) {
+		remoteKus := kus.DeepCopy()
+		remoteKus.Spec.KubeConfig = &kusv1.KubeConfig{}
+		remoteKus.Spec.KubeConfig.SecretRef.Name = "member"
+		remoteKus.Status.Inventory = nil
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "member", Namespace: "fake-namespace"},
+			Data:       map[string][]byte{"value": []byte("fake-kubeconfig")},
+		}
+		differ := newDiffer(remoteKus, repo, secret)
+		differ.newClient = func(kubeconfig []byte) (client.Client, error) {
+			assert.Equal(t, "fake-kubeconfig", string(kubeconfig))
+			return fake.NewClientBuilder().WithScheme(schema).WithRESTMapper(mapper).WithObjects(unchanged).Build(), nil
+		}
+		diffs, err := differ.Diff(context.Background(), app)
+		assert.NoError(t, err)
+		assert.Len(t, diffs, 2)
+	})
+}
+
+func TestSetNamespace(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	c := fake.NewClientBuilder().WithRESTMapper(mapper).Build()
+
+	newObject := func(kind, namespace string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: kind})
+		obj.SetNamespace(namespace)
+		return obj
+	}
+
+	obj := newObject("ConfigMap", "")
+	assert.NoError(t, setNamespace(c, obj, ""))
+	assert.Equal(t, "default", obj.GetNamespace())
+
+	obj = newObject("ConfigMap", "ns")
+	assert.NoError(t, setNamespace(c, obj, "target"))
+	assert.Equal(t, "target", obj.GetNamespace())
+
+	obj = newObject("Unknown", "ns")
+	assert.NoError(t, setNamespace(c, obj, "target"))
+	assert.Equal(t, "ns", obj.GetNamespace())
+}
+
+func TestCheckNamespace(t *testing.T) {
+	kus := &kusv1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns"},
+		Spec:       kusv1.KustomizationSpec{TargetNamespace: "target"},
+	}
+	configMap := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	namespace := corev1.SchemeGroupVersion.WithKind("Namespace")
+	clusterRole := schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+
+	assert.NoError(t, checkNamespace(kus, configMap, "ns", "fake"))
+	assert.NoError(t, checkNamespace(kus, configMap, "target", "fake"))
+	assert.NoError(t, checkNamespace(kus, namespace, "", "target"))
+	assert.EqualError(t, checkNamespace(kus, configMap, "other", "fake"),
+		"cannot preview ConfigMap other/fake, it's out of the namespaces of the Application")
+	assert.EqualError(t, checkNamespace(kus, namespace, "", "other"), "cannot preview the cluster scoped resource Namespace other")
+	assert.EqualError(t, checkNamespace(kus, clusterRole, "", "admin"), "cannot preview the cluster scoped resource ClusterRole admin")
+
+	// the member clusters are accessed with their own kubeconfig
+	remoteKus := kus.DeepCopy()
+	remoteKus.Spec.KubeConfig = &kusv1.KubeConfig{}
+	assert.NoError(t, checkNamespace(remoteKus, configMap, "other", "fake"))
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"sigs.k8s.io/kustomize/api/krusty"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// artifactRoot is the directory which the files of an artifact are extracted to
+const artifactRoot = "/artifact"
+
+// extractArtifact extracts the regular files of a gzipped tarball into an in-memory file system
+func extractArtifact(artifact io.Reader) (fs filesys.FileSystem, err error) {
+	var gzipReader *gzip.Reader
+	if gzipReader, err = gzip.NewReader(artifact); err != nil {
+		return
+	}
+	fs = filesys.MakeFsInMemory()
+	tarReader := tar.NewReader(io.LimitReader(gzipReader, maxArtifactSize))
+	for {
+		var header *tar.Header
+		if header, err = tarReader.Next(); err == io.EOF {
+			err = nil
+			return
+		} else if err != nil {
+			return
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// the cleaned absolute path never goes out of the root
+		name := path.Join(artifactRoot, path.Clean("/"+header.Name))
+		var data []byte
+		if data, err = io.ReadAll(tarReader); err != nil {
+			return
+		}
+		if err = fs.WriteFile(name, data); err != nil {
+			return
+		}
+	}
+}
+
+// buildKustomization renders the manifests in the path of the artifact as FluxCD does. A kustomization file is
+// generated if there isn't one, then the overlays in the spec of the Kustomization are added to it.
+func buildKustomization(fs filesys.FileSystem, kus *kusv1.Kustomization) (objects []*unstructured.Unstructured, err error) {
+	if kus.Spec.PostBuild != nil {
+		// the variables might come from the ConfigMaps and Secrets, the substitution is not supported
+		err = fmt.Errorf("the post build variable substitution is not supported")
+		return
+	}
+
+	dir := path.Join(artifactRoot, path.Clean("/"+kus.Spec.Path))
+	if !fs.IsDir(dir) {
+		err = fmt.Errorf("the path %s is not found in the artifact", kus.Spec.Path)
+		return
+	}
+	kustomizationFile := findKustomizationFile(fs, dir)
+	kustomization := &kustypes.Kustomization{}
+	if kustomizationFile == "" {
+		kustomizationFile = path.Join(dir, kustomizationFileNames[0])
+		if kustomization.Resources, err = generateResources(fs, dir); err != nil {
+			return
+		}
+	} else {
+		var data []byte
+		if data, err = fs.ReadFile(kustomizationFile); err != nil {
+			return
+		}
+		if err = yaml.Unmarshal(data, kustomization); err != nil {
+			err = fmt.Errorf("failed to parse %s: %v", strings.TrimPrefix(kustomizationFile, artifactRoot+"/"), err)
+			return
+		}
+	}
+	if err = addOverlays(kustomization, kus); err != nil {
+		return
+	}
+	kustomization.FixKustomizationPreMarshalling()
+	var data []byte
+	if data, err = yaml.Marshal(kustomization); err != nil {
+		return
+	}
+	if err = fs.WriteFile(kustomizationFile, data); err != nil {
+		return
+	}
+
+	options := krusty.MakeDefaultOptions()
+	// the overlays could refer to the bases in the same artifact
+	options.LoadRestrictions = kustypes.LoadRestrictionsNone
+	resMap, err := krusty.MakeKustomizer(options).Run(fs, dir)
+	if err != nil {
+		return
+	}
+	for _, res := range resMap.Resources() {
+		var obj map[string]interface{}
+		if obj, err = res.Map(); err != nil {
+			return
+		}
+		objects = append(objects, &unstructured.Unstructured{Object: obj})
+	}
+	return
+}
+
+func findKustomizationFile(fs filesys.FileSystem, dir string) string {
+	for _, name := range kustomizationFileNames {
+		if file := path.Join(dir, name); fs.Exists(file) && !fs.IsDir(file) {
+			return file
+		}
+	}
+	return ""
+}
+
+// generateResources returns the resources of the generated kustomization file. They are the manifest files in the
+// directory, and the sub-directories which have their own kustomization files.
+func generateResources(fs filesys.FileSystem, dir string) (resources []string, err error) {
+	err = fs.Walk(dir, func(file string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.IsDir() {
+			if file != dir && findKustomizationFile(fs, file) != "" {
+				resources = append(resources, relativePath(dir, file))
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isManifestFile(file) {
+			resources = append(resources, relativePath(dir, file))
+		}
+		return nil
+	})
+	return
+}
+
+func relativePath(dir, file string) string {
+	return strings.TrimPrefix(file, dir+"/")
+}
+
+// addOverlays adds the patches and images in the spec of the FluxCD Kustomization to the kustomization file
+func addOverlays(kustomization *kustypes.Kustomization, kus *kusv1.Kustomization) (err error) {
+	for _, patch := range kus.Spec.Patches {
+		var target *kustypes.Selector
+		if target, err = toKustomizeSelector(patch.Target); err != nil {
+			return
+		}
+		kustomization.Patches = append(kustomization.Patches, kustypes.Patch{Patch: patch.Patch, Target: target})
+	}
+	for _, patch := range kus.Spec.PatchesStrategicMerge {
+		kustomization.PatchesStrategicMerge = append(kustomization.PatchesStrategicMerge,
+			kustypes.PatchStrategicMerge(patch.Raw))
+	}
+	for _, patch := range kus.Spec.PatchesJSON6902 {
+		var target *kustypes.Selector
+		if target, err = toKustomizeSelector(patch.Target); err != nil {
+			return
+		}
+		var operations []byte
+		if operations, err = json.Marshal(patch.Patch); err != nil {
+			return
+		}
+		kustomization.Patches = append(kustomization.Patches, kustypes.Patch{Patch: string(operations), Target: target})
+	}
+	for _, image := range kus.Spec.Images {
+		kustomization.Images = append(kustomization.Images, kustypes.Image{
+			Name:    image.Name,
+			NewName: image.NewName,
+			NewTag:  image.NewTag,
+			Digest:  image.Digest,
+		})
+	}
+	return
+}
+
+// toKustomizeSelector converts the selector of FluxCD to the one of kustomize, they have the same JSON format
+func toKustomizeSelector(selector kusv1.Selector) (target *kustypes.Selector, err error) {
+	if selector == (kusv1.Selector{}) {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(selector); err != nil {
+		return
+	}
+	target = &kustypes.Selector{}
+	err = json.Unmarshal(data, target)
+	return
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
+)
+
+const deploymentManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+`
+
+func TestBuildKustomization(t *testing.T) {
+	artifact := createArtifact(t, map[string]string{
+		"base/deployment.yaml": deploymentManifest,
+		"base/kustomization.yaml": `resources: [deployment.yaml]
+configMapGenerator:
+- name: config
+  literals: [key=value]
+`,
+		"overlays/prod/kustomization.yaml": `resources: [../../base]
+namePrefix: prod-
+`,
+		"plain/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+`,
+		"plain/values.txt":                "not a manifest",
+		"plain/nested/kustomization.yaml": `resources: [../../base]`,
+		"plain/nested/ignored.yaml":       `not referred by the kustomization file`,
+	})
+
+	build := func(kus *kusv1.Kustomization) ([]string, error) {
+		fs, err := extractArtifact(bytes.NewReader(artifact))
+		assert.NoError(t, err)
+		objects, err := buildKustomization(fs, kus)
+		var names []string
+		for _, obj := range objects {
+			names = append(names, obj.GetKind()+" "+obj.GetName())
+		}
+		return names, err
+	}
+
+	t.Run("overlay", func(t *testing.T) {
+		names, err := build(&kusv1.Kustomization{Spec: kusv1.KustomizationSpec{Path: "./overlays/prod"}})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Deployment prod-nginx", "ConfigMap prod-config-t757gk2bmf"}, names)
+	})
+
+	t.Run("generated kustomization", func(t *testing.T) {
+		names, err := build(&kusv1.Kustomization{Spec: kusv1.KustomizationSpec{Path: "plain"}})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Service nginx", "Deployment nginx", "ConfigMap config-t757gk2bmf"}, names)
+	})
+
+	t.Run("patches and images of the Kustomization", func(t *testing.T) {
+		fs, err := extractArtifact(bytes.NewReader(artifact))
+		assert.NoError(t, err)
+		objects, err := buildKustomization(fs, &kusv1.Kustomization{Spec: kusv1.KustomizationSpec{
+			Path: "base",
+			Patches: []kusv1.Patch{{
+				Patch:  `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+				Target: kusv1.Selector{Kind: "Deployment", Name: "nginx"},
+			}},
+			PatchesStrategicMerge: []apiextensionsv1.JSON{{
+				Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}, "data": {"added": "true"}}`),
+			}},
+			Images: []kusv1.Image{{Name: "nginx", NewTag: "1.23"}},
+		}})
+		assert.NoError(t, err)
+		if assert.Len(t, objects, 2) {
+			deployment, configMap := objects[0], objects[1]
+			if deployment.GetKind() != "Deployment" {
+				deployment, configMap = configMap, deployment
+			}
+			replicas, _, _ := unstructured.NestedFieldNoCopy(deployment.Object, "spec", "replicas")
+			assert.EqualValues(t, 3, replicas)
+			containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+			if assert.Len(t, containers, 1) {
+				assert.Equal(t, "nginx:1.23", containers[0].(map[string]interface{})["image"])
+			}
+			assert.Equal(t, map[string]interface{}{"added": "true", "key": "value"}, configMap.Object["data"])
+		}
+	})
+
+	t.Run("path not found", func(t *testing.T) {
+		_, err := build(&kusv1.Kustomization{Spec: kusv1.KustomizationSpec{Path: "missing"}})
+		assert.EqualError(t, err, "the path missing is not found in the artifact")
+	})
+
+	t.Run("post build substitution", func(t *testing.T) {
+		_, err := build(&kusv1.Kustomization{Spec: kusv1.KustomizationSpec{
+			Path:      "base",
+			PostBuild: &kusv1.PostBuild{Substitute: map[string]string{"var": "value"}},
+		}})
+		assert.EqualError(t, err, "the post build variable substitution is not supported")
+	})
+}
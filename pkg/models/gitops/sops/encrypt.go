@@ -0,0 +1,327 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sops encrypts the Kubernetes Secrets in the SOPS format, then they could be committed to Git
+// and decrypted by the FluxCD kustomize-controller.
+package sops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"golang.org/x/crypto/openpgp"
+	pgparmor "golang.org/x/crypto/openpgp/armor"
+	// the default hash of the OpenPGP keys without hash preferences
+	_ "golang.org/x/crypto/ripemd160"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+const (
+	// EncryptedRegex matches the keys whose values are encrypted, only the data of a Secret is encrypted
+	EncryptedRegex = "^(data|stringData)$"
+	// Version is the SOPS version which the encrypted files are compatible with
+	Version = "3.7.3"
+
+	// nonceSize is the size of the AES-GCM nonce used by SOPS
+	nonceSize = 32
+	// dataKeySize is the size of the AES-256 data key
+	dataKeySize = 32
+)
+
+var encryptedRegex = regexp.MustCompile(EncryptedRegex)
+
+// metadata is the sops section of an encrypted file
+type metadata struct {
+	KMS            []interface{} `yaml:"kms"`
+	GCPKMS         []interface{} `yaml:"gcp_kms"`
+	AzureKV        []interface{} `yaml:"azure_kv"`
+	HCVault        []interface{} `yaml:"hc_vault"`
+	Age            []ageKey      `yaml:"age"`
+	LastModified   string        `yaml:"lastmodified"`
+	MAC            string        `yaml:"mac"`
+	PGP            []pgpKey      `yaml:"pgp"`
+	EncryptedRegex string        `yaml:"encrypted_regex"`
+	Version        string        `yaml:"version"`
+}
+
+type ageKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+type pgpKey struct {
+	CreatedAt string `yaml:"created_at"`
+	Enc       string `yaml:"enc"`
+	FP        string `yaml:"fp"`
+}
+
+// Encrypt encrypts the data of the Secret with the public keys of a SOPS credential, and returns the YAML manifest.
+// Only the name, namespace, labels and annotations of the Secret metadata are kept.
+func Encrypt(secret *corev1.Secret, credential *corev1.Secret, now time.Time) (manifest []byte, err error) {
+	if credential.Type != v1alpha3.SecretTypeSOPS {
+		err = fmt.Errorf("the type of credential %s is %s instead of %s", credential.Name, credential.Type,
+			v1alpha3.SecretTypeSOPS)
+		return
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return
+	}
+
+	meta := &metadata{
+		LastModified:   now.UTC().Format(time.RFC3339),
+		EncryptedRegex: EncryptedRegex,
+		Version:        Version,
+	}
+	if meta.Age, err = encryptDataKeyWithAge(dataKey, credential.Data[v1alpha3.SOPSAgeKey]); err != nil {
+		return
+	}
+	if meta.PGP, err = encryptDataKeyWithPGP(dataKey, credential.Data[v1alpha3.SOPSPGPKey], meta.LastModified); err != nil {
+		return
+	}
+	if len(meta.Age) == 0 && len(meta.PGP) == 0 {
+		err = fmt.Errorf("no age or PGP key found in credential %s", credential.Name)
+		return
+	}
+
+	doc := secretToNode(secret)
+	mac := sha512.New()
+	if err = encryptNode(doc, nil, dataKey, mac); err != nil {
+		return
+	}
+	if meta.MAC, err = encryptValue(fmt.Sprintf("%X", mac.Sum(nil)), dataKey, meta.LastModified); err != nil {
+		return
+	}
+
+	metaNode := &yaml.Node{}
+	if err = metaNode.Encode(meta); err != nil {
+		return
+	}
+	doc.Content = append(doc.Content, stringNode("sops"), metaNode)
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	if err = encoder.Encode(doc); err == nil {
+		err = encoder.Close()
+	}
+	manifest = buf.Bytes()
+	return
+}
+
+// encryptNode encrypts the scalar values whose path matches the EncryptedRegex in place,
+// and writes all the plain values into the MAC in order, same as SOPS does.
+func encryptNode(node *yaml.Node, path []string, dataKey []byte, mac hash.Hash) (err error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err = encryptNode(node.Content[i+1], append(path, node.Content[i].Value), dataKey, mac); err != nil {
+				return
+			}
+		}
+	case yaml.SequenceNode:
+		// the index is not a part of the path
+		for _, item := range node.Content {
+			if err = encryptNode(item, path, dataKey, mac); err != nil {
+				return
+			}
+		}
+	case yaml.ScalarNode:
+		mac.Write([]byte(node.Value))
+		if !matchEncryptedRegex(path) || node.Value == "" {
+			return
+		}
+		if node.Value, err = encryptValue(node.Value, dataKey, strings.Join(path, ":")+":"); err == nil {
+			node.Style = 0
+		}
+	}
+	return
+}
+
+func matchEncryptedRegex(path []string) bool {
+	for _, key := range path {
+		if encryptedRegex.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptValue encrypts a string in the format: ENC[AES256_GCM,data:<base64>,iv:<base64>,tag:<base64>,type:str]
+func encryptValue(value string, dataKey []byte, additionalData string) (encrypted string, err error) {
+	var block cipher.Block
+	if block, err = aes.NewCipher(dataKey); err != nil {
+		return
+	}
+	var gcm cipher.AEAD
+	if gcm, err = cipher.NewGCMWithNonceSize(block, nonceSize); err != nil {
+		return
+	}
+	iv := make([]byte, nonceSize)
+	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+		return
+	}
+	out := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	tagIndex := len(out) - gcm.Overhead()
+	encrypted = fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]",
+		base64.StdEncoding.EncodeToString(out[:tagIndex]),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(out[tagIndex:]))
+	return
+}
+
+// encryptDataKeyWithAge encrypts the data key for the recipients of the age identities
+func encryptDataKeyWithAge(dataKey, identities []byte) (keys []ageKey, err error) {
+	if len(bytes.TrimSpace(identities)) == 0 {
+		return
+	}
+	var parsed []age.Identity
+	if parsed, err = age.ParseIdentities(bytes.NewReader(identities)); err != nil {
+		err = fmt.Errorf("invalid age key: %v", err)
+		return
+	}
+	for _, identity := range parsed {
+		x25519, ok := identity.(*age.X25519Identity)
+		if !ok {
+			continue
+		}
+		recipient := x25519.Recipient()
+
+		buf := &bytes.Buffer{}
+		armorWriter := armor.NewWriter(buf)
+		var writer io.WriteCloser
+		if writer, err = age.Encrypt(armorWriter, recipient); err != nil {
+			return
+		}
+		if _, err = writer.Write(dataKey); err != nil {
+			return
+		}
+		if err = writer.Close(); err != nil {
+			return
+		}
+		if err = armorWriter.Close(); err != nil {
+			return
+		}
+		keys = append(keys, ageKey{Recipient: recipient.String(), Enc: buf.String()})
+	}
+	return
+}
+
+// encryptDataKeyWithPGP encrypts the data key for the public keys of the armored OpenPGP private keys
+func encryptDataKeyWithPGP(dataKey, privateKeys []byte, createdAt string) (keys []pgpKey, err error) {
+	if len(bytes.TrimSpace(privateKeys)) == 0 {
+		return
+	}
+	var entities openpgp.EntityList
+	if entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(privateKeys)); err != nil {
+		err = fmt.Errorf("invalid PGP key: %v", err)
+		return
+	}
+	for _, entity := range entities {
+		buf := &bytes.Buffer{}
+		var armorWriter, writer io.WriteCloser
+		if armorWriter, err = pgparmor.Encode(buf, "PGP MESSAGE", nil); err != nil {
+			return
+		}
+		if writer, err = openpgp.Encrypt(armorWriter, []*openpgp.Entity{entity}, nil,
+			&openpgp.FileHints{IsBinary: true}, nil); err != nil {
+			return
+		}
+		if _, err = writer.Write(dataKey); err != nil {
+			return
+		}
+		if err = writer.Close(); err != nil {
+			return
+		}
+		if err = armorWriter.Close(); err != nil {
+			return
+		}
+		keys = append(keys, pgpKey{
+			CreatedAt: createdAt,
+			Enc:       buf.String(),
+			FP:        fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+		})
+	}
+	return
+}
+
+// secretToNode converts the Secret to a YAML document which has the same fields order as the kubectl output
+func secretToNode(secret *corev1.Secret) *yaml.Node {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	doc.Content = append(doc.Content, stringNode("apiVersion"), stringNode("v1"),
+		stringNode("kind"), stringNode("Secret"))
+
+	meta := &yaml.Node{Kind: yaml.MappingNode}
+	meta.Content = append(meta.Content, stringNode("name"), stringNode(secret.Name))
+	if secret.Namespace != "" {
+		meta.Content = append(meta.Content, stringNode("namespace"), stringNode(secret.Namespace))
+	}
+	if len(secret.Labels) > 0 {
+		meta.Content = append(meta.Content, stringNode("labels"), mapNode(secret.Labels))
+	}
+	if len(secret.Annotations) > 0 {
+		meta.Content = append(meta.Content, stringNode("annotations"), mapNode(secret.Annotations))
+	}
+	doc.Content = append(doc.Content, stringNode("metadata"), meta)
+
+	if secret.Type != "" {
+		doc.Content = append(doc.Content, stringNode("type"), stringNode(string(secret.Type)))
+	}
+	if len(secret.Data) > 0 {
+		data := make(map[string]string, len(secret.Data))
+		for key, value := range secret.Data {
+			data[key] = base64.StdEncoding.EncodeToString(value)
+		}
+		doc.Content = append(doc.Content, stringNode("data"), mapNode(data))
+	}
+	if len(secret.StringData) > 0 {
+		doc.Content = append(doc.Content, stringNode("stringData"), mapNode(secret.StringData))
+	}
+	return doc
+}
+
+func mapNode(values map[string]string) *yaml.Node {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keys {
+		node.Content = append(node.Content, stringNode(key), stringNode(values[key]))
+	}
+	return node
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
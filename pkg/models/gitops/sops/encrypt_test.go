@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	pgparmor "golang.org/x/crypto/openpgp/armor"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func TestEncrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	assert.NoError(t, err)
+	pgpKey := &bytes.Buffer{}
+	armorWriter, err := pgparmor.Encode(pgpKey, openpgp.PrivateKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, entity.SerializePrivate(armorWriter, nil))
+	assert.NoError(t, armorWriter.Close())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", Labels: map[string]string{"app": "demo"}},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte("s3cr3t"), "empty": {}},
+		StringData: map[string]string{"token": "abc"},
+	}
+	now := time.Date(2022, 10, 17, 10, 0, 0, 0, time.UTC)
+
+	t.Run("age and PGP keys", func(t *testing.T) {
+		credential := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sops"},
+			Type:       v1alpha3.SecretTypeSOPS,
+			Data: map[string][]byte{
+				v1alpha3.SOPSAgeKey: []byte("# created: 2022-10-17T10:00:00Z\n" + identity.String() + "\n"),
+				v1alpha3.SOPSPGPKey: pgpKey.Bytes(),
+			},
+		}
+		manifest, err := Encrypt(secret, credential, now)
+		assert.NoError(t, err)
+
+		doc := map[string]interface{}{}
+		assert.NoError(t, yaml.Unmarshal(manifest, &doc))
+		assert.Equal(t, map[string]interface{}{
+			"name": "demo", "namespace": "default", "labels": map[string]interface{}{"app": "demo"},
+		}, doc["metadata"])
+		assert.Equal(t, "Opaque", doc["type"])
+
+		sops := doc["sops"].(map[string]interface{})
+		assert.Equal(t, "2022-10-17T10:00:00Z", sops["lastmodified"])
+		assert.Equal(t, EncryptedRegex, sops["encrypted_regex"])
+		ageKeys := sops["age"].([]interface{})
+		if assert.Len(t, ageKeys, 1) {
+			assert.Equal(t, identity.Recipient().String(), ageKeys[0].(map[string]interface{})["recipient"])
+		}
+		pgpKeys := sops["pgp"].([]interface{})
+		if assert.Len(t, pgpKeys, 1) {
+			assert.Equal(t, fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint), pgpKeys[0].(map[string]interface{})["fp"])
+		}
+
+		// decrypt the data key by the age identity
+		ageReader, err := age.Decrypt(armor.NewReader(strings.NewReader(ageKeys[0].(map[string]interface{})["enc"].(string))), identity)
+		assert.NoError(t, err)
+		dataKey, err := io.ReadAll(ageReader)
+		assert.NoError(t, err)
+		assert.Len(t, dataKey, dataKeySize)
+
+		data := doc["data"].(map[string]interface{})
+		assert.Equal(t, "", data["empty"])
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("s3cr3t")), decryptValue(t, data["password"].(string), dataKey, "data:password:"))
+		assert.Equal(t, "abc", decryptValue(t, doc["stringData"].(map[string]interface{})["token"].(string), dataKey, "stringData:token:"))
+
+		// the MAC is computed from all the plain values in order
+		mac := sha512.New()
+		for _, value := range []string{"v1", "Secret", "demo", "default", "demo", "Opaque", "",
+			base64.StdEncoding.EncodeToString([]byte("s3cr3t")), "abc"} {
+			mac.Write([]byte(value))
+		}
+		assert.Equal(t, fmt.Sprintf("%X", mac.Sum(nil)), decryptValue(t, sops["mac"].(string), dataKey, "2022-10-17T10:00:00Z"))
+	})
+
+	t.Run("not a SOPS credential", func(t *testing.T) {
+		_, err := Encrypt(secret, &corev1.Secret{Type: v1alpha3.SecretTypeBasicAuth}, now)
+		assert.Error(t, err)
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		_, err := Encrypt(secret, &corev1.Secret{Type: v1alpha3.SecretTypeSOPS}, now)
+		assert.EqualError(t, err, "no age or PGP key found in credential ")
+	})
+
+	t.Run("invalid age key", func(t *testing.T) {
+		_, err := Encrypt(secret, &corev1.Secret{
+			Type: v1alpha3.SecretTypeSOPS,
+			Data: map[string][]byte{v1alpha3.SOPSAgeKey: []byte("AGE-SECRET-KEY-INVALID")},
+		}, now)
+		assert.Error(t, err)
+	})
+}
+
+var encryptedValueRegex = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:str]$`)
+
+func decryptValue(t *testing.T, value string, dataKey []byte, additionalData string) string {
+	matches := encryptedValueRegex.FindStringSubmatch(value)
+	if !assert.Len(t, matches, 4, value) {
+		return ""
+	}
+	var parts [][]byte
+	for _, match := range matches[1:] {
+		part, err := base64.StdEncoding.DecodeString(match)
+		assert.NoError(t, err)
+		parts = append(parts, part)
+	}
+	block, err := aes.NewCipher(dataKey)
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+	assert.NoError(t, err)
+	plain, err := gcm.Open(nil, parts[1], append(parts[0], parts[2]...), []byte(additionalData))
+	assert.NoError(t, err)
+	return string(plain)
+}
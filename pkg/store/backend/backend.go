@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	s3client "kubesphere.io/devops/pkg/client/s3"
+	"kubesphere.io/devops/pkg/store/configmap"
+	"kubesphere.io/devops/pkg/store/filesystem"
+	"kubesphere.io/devops/pkg/store/s3"
+	"kubesphere.io/devops/pkg/store/store"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Option holds the dependencies which are needed by the backends
+type Option struct {
+	// Client is required by the configmap backend
+	Client client.Client
+	// S3Client is required by the s3 backend
+	S3Client s3client.Interface
+	// Dir is the root directory of the filesystem backend
+	Dir string
+}
+
+// Builder creates a backend with the option
+type Builder func(option *Option) (store.Backend, error)
+
+var (
+	lock     sync.RWMutex
+	builders = map[string]Builder{
+		store.TypeConfigMap: func(option *Option) (store.Backend, error) {
+			if option.Client == nil {
+				return nil, errors.New("kubernetes client is required by the configmap data store")
+			}
+			return configmap.NewBackend(option.Client), nil
+		},
+		store.TypeS3: func(option *Option) (store.Backend, error) {
+			if option.S3Client == nil {
+				return nil, errors.New("s3 client is required by the s3 data store, please check the s3 options")
+			}
+			return s3.NewBackend(option.S3Client), nil
+		},
+		store.TypeFilesystem: func(option *Option) (store.Backend, error) {
+			if option.Dir == "" {
+				return nil, errors.New("directory is required by the filesystem data store")
+			}
+			return filesystem.NewBackend(option.Dir), nil
+		},
+	}
+)
+
+// Register registers a backend builder, the existing one with the same type will be replaced
+func Register(storeType string, builder Builder) {
+	lock.Lock()
+	defer lock.Unlock()
+	builders[storeType] = builder
+}
+
+// GetTypes returns the sorted types of all the registered backends
+func GetTypes() (types []string) {
+	lock.RLock()
+	defer lock.RUnlock()
+	for storeType := range builders {
+		types = append(types, storeType)
+	}
+	sort.Strings(types)
+	return
+}
+
+// New creates a backend by its type
+func New(storeType string, option *Option) (backend store.Backend, err error) {
+	lock.RLock()
+	builder, ok := builders[storeType]
+	lock.RUnlock()
+
+	if !ok {
+		err = fmt.Errorf("unknown pipelineRun data store type: %s", storeType)
+		return
+	}
+	if option == nil {
+		option = &Option{}
+	}
+	return builder(option)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"kubesphere.io/devops/pkg/client/s3/fake"
+	"kubesphere.io/devops/pkg/store/store"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		storeType string
+		option    *Option
+		wantErr   bool
+	}{{
+		name:      "configmap",
+		storeType: store.TypeConfigMap,
+		option:    &Option{Client: fakeclient.NewClientBuilder().Build()},
+	}, {
+		name:      "configmap without client",
+		storeType: store.TypeConfigMap,
+		option:    &Option{},
+		wantErr:   true,
+	}, {
+		name:      "s3",
+		storeType: store.TypeS3,
+		option:    &Option{S3Client: fake.NewFakeS3()},
+	}, {
+		name:      "s3 without client",
+		storeType: store.TypeS3,
+		option:    &Option{},
+		wantErr:   true,
+	}, {
+		name:      "filesystem",
+		storeType: store.TypeFilesystem,
+		option:    &Option{Dir: t.TempDir()},
+	}, {
+		name:      "filesystem without dir",
+		storeType: store.TypeFilesystem,
+		option:    &Option{},
+		wantErr:   true,
+	}, {
+		name:      "unknown",
+		storeType: "unknown",
+		option:    &Option{},
+		wantErr:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataStore, err := New(tt.storeType, tt.option)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.NotNil(t, dataStore)
+		})
+	}
+}
+
+type fakeBackend struct{}
+
+func (f *fakeBackend) Open(ctx context.Context, key types.NamespacedName) (store.PipelineRunDataStore, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) Delete(ctx context.Context, key types.NamespacedName) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("fake", func(option *Option) (store.Backend, error) {
+		return &fakeBackend{}, nil
+	})
+	assert.Contains(t, GetTypes(), "fake")
+
+	dataStore, err := New("fake", &Option{})
+	assert.Nil(t, err)
+	assert.IsType(t, &fakeBackend{}, dataStore)
+}
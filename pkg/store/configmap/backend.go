@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configmap
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"kubesphere.io/devops/pkg/store/store"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Backend keeps the PipelineRun data in ConfigMaps which have the same name as the PipelineRuns
+type Backend struct {
+	k8sClient client.Client
+}
+
+// NewBackend creates a ConfigMap based backend
+func NewBackend(k8sClient client.Client) *Backend {
+	return &Backend{k8sClient: k8sClient}
+}
+
+// Open returns the ConfigMap store of a PipelineRun
+func (b *Backend) Open(ctx context.Context, key types.NamespacedName) (store.PipelineRunDataStore, error) {
+	return NewConfigMapStore(ctx, key, b.k8sClient)
+}
+
+// Delete deletes the ConfigMap of a PipelineRun
+func (b *Backend) Delete(ctx context.Context, key types.NamespacedName) error {
+	cm := &v1.ConfigMap{}
+	cm.SetNamespace(key.Namespace)
+	cm.SetName(key.Name)
+	return client.IgnoreNotFound(b.k8sClient.Delete(ctx, cm))
+}
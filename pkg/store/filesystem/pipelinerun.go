@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/store/store"
+)
+
+// Backend keeps the PipelineRun data in a local directory, one file per key.
+// The directory could be a mounted PVC in order to share it between the controller and apiserver.
+type Backend struct {
+	root string
+}
+
+// NewBackend creates a filesystem based backend
+func NewBackend(root string) *Backend {
+	return &Backend{root: root}
+}
+
+// Open returns the data store of a PipelineRun, the files will be read once they're needed
+func (b *Backend) Open(ctx context.Context, key types.NamespacedName) (store.PipelineRunDataStore, error) {
+	return &FileStore{
+		dir:   b.dir(key),
+		cache: map[string]string{},
+		dirty: map[string]bool{},
+	}, nil
+}
+
+// Delete removes the directory of a PipelineRun
+func (b *Backend) Delete(ctx context.Context, key types.NamespacedName) error {
+	return os.RemoveAll(b.dir(key))
+}
+
+func (b *Backend) dir(key types.NamespacedName) string {
+	return filepath.Join(b.root, key.Namespace, key.Name)
+}
+
+// FileStore represents a PipelineRun data store base on the local filesystem
+type FileStore struct {
+	dir   string
+	cache map[string]string
+	dirty map[string]bool
+}
+
+// GetStages returns the stage data
+func (s *FileStore) GetStages() string {
+	return s.Get(store.DataKeyStage)
+}
+
+// SetStages stores the stage data
+func (s *FileStore) SetStages(stages string) {
+	s.Set(store.DataKeyStage, stages)
+}
+
+// GetStatus returns the status
+func (s *FileStore) GetStatus() string {
+	return s.Get(store.DataKeyStatus)
+}
+
+// SetStatus stores the status
+func (s *FileStore) SetStatus(status string) {
+	s.Set(store.DataKeyStatus, status)
+}
+
+// GetStepLog returns the step log
+func (s *FileStore) GetStepLog(stage, step int) string {
+	return s.Get(store.StepLogKey(stage, step))
+}
+
+// SetStepLog stores the step log
+func (s *FileStore) SetStepLog(stage, step int, log string) {
+	s.Set(store.StepLogKey(stage, step), log)
+}
+
+// GetAllLog returns the whole log
+func (s *FileStore) GetAllLog() string {
+	return s.Get(store.DataKeyAllLog)
+}
+
+// SetAllLog store the whole log
+func (s *FileStore) SetAllLog(log string) {
+	s.Set(store.DataKeyAllLog, log)
+}
+
+// Get returns the value by a key, it reads the file if it's not in the cache
+func (s *FileStore) Get(key string) string {
+	if value, ok := s.cache[key]; ok {
+		return value
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.V(4).Infof("failed to read file %s, error: %v", filepath.Join(s.dir, key), err)
+		}
+		return ""
+	}
+	s.cache[key] = string(data)
+	return s.cache[key]
+}
+
+// Set puts a key and value
+func (s *FileStore) Set(key, value string) {
+	s.cache[key] = value
+	s.dirty[key] = true
+}
+
+// Save writes the changed values into files
+func (s *FileStore) Save() (err error) {
+	if len(s.dirty) == 0 {
+		return
+	}
+
+	if err = os.MkdirAll(s.dir, 0750); err != nil {
+		err = fmt.Errorf("failed to create directory %s, error: %v", s.dir, err)
+		return
+	}
+
+	for key := range s.dirty {
+		if err = writeFile(filepath.Join(s.dir, key), s.cache[key]); err != nil {
+			return
+		}
+		delete(s.dirty, key)
+	}
+	return
+}
+
+// writeFile writes the data into a temporary file first, then renames it.
+// So the readers never see a partial file.
+func writeFile(name, data string) (err error) {
+	tmp := name + ".tmp"
+	if err = os.WriteFile(tmp, []byte(data), 0640); err != nil {
+		err = fmt.Errorf("failed to write file %s, error: %v", tmp, err)
+		return
+	}
+	if err = os.Rename(tmp, name); err != nil {
+		err = fmt.Errorf("failed to rename file %s, error: %v", tmp, err)
+	}
+	return
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestFileStore(t *testing.T) {
+	root := t.TempDir()
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+	backend := NewBackend(root)
+
+	runStore, err := backend.Open(context.Background(), key)
+	assert.Nil(t, err)
+	assert.Nil(t, runStore.Save())
+
+	assert.Empty(t, runStore.GetStages())
+	runStore.SetStages("stages")
+	assert.Equal(t, "stages", runStore.GetStages())
+	runStore.SetStatus("status")
+	runStore.SetStepLog(1, 2, "step")
+	runStore.SetAllLog("log")
+	assert.Nil(t, runStore.Save())
+
+	runStore, err = backend.Open(context.Background(), key)
+	assert.Nil(t, err)
+	assert.Equal(t, "stages", runStore.GetStages())
+	assert.Equal(t, "status", runStore.GetStatus())
+	assert.Equal(t, "step", runStore.GetStepLog(1, 2))
+	assert.Equal(t, "log", runStore.GetAllLog())
+	assert.Empty(t, runStore.GetStepLog(2, 1))
+
+	assert.Nil(t, backend.Delete(context.Background(), key))
+	_, err = os.Stat(filepath.Join(root, "ns", "name"))
+	assert.True(t, os.IsNotExist(err))
+	// delete a non-existing PipelineRun
+	assert.Nil(t, backend.Delete(context.Background(), key))
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	s3client "kubesphere.io/devops/pkg/client/s3"
+	"kubesphere.io/devops/pkg/store/store"
+)
+
+// indexKey is the object which holds all the keys of a PipelineRun
+const indexKey = "index"
+
+// Backend keeps every piece of the PipelineRun data as an object in a S3-compatible object storage,
+// so there is no size limit like the ConfigMap.
+type Backend struct {
+	client s3client.Interface
+}
+
+// NewBackend creates a S3 based backend
+func NewBackend(client s3client.Interface) *Backend {
+	return &Backend{client: client}
+}
+
+// Open loads the index of a PipelineRun, the values will be read once they're needed
+func (b *Backend) Open(ctx context.Context, key types.NamespacedName) (result store.PipelineRunDataStore, err error) {
+	objStore := &ObjectStore{
+		client: b.client,
+		prefix: objectPrefix(key),
+		cache:  map[string]string{},
+		dirty:  map[string]bool{},
+		keys:   map[string]bool{},
+	}
+	if err = objStore.loadIndex(); err == nil {
+		result = objStore
+	}
+	return
+}
+
+// Delete deletes all the objects of a PipelineRun
+func (b *Backend) Delete(ctx context.Context, key types.NamespacedName) (err error) {
+	objStore := &ObjectStore{
+		client: b.client,
+		prefix: objectPrefix(key),
+		keys:   map[string]bool{},
+	}
+	if err = objStore.loadIndex(); err != nil {
+		return
+	}
+
+	for k := range objStore.keys {
+		if err = b.client.Delete(objStore.objectKey(k)); err != nil && !isNotFound(err) {
+			return
+		}
+	}
+	if err = b.client.Delete(objStore.objectKey(indexKey)); isNotFound(err) {
+		err = nil
+	}
+	return
+}
+
+func objectPrefix(key types.NamespacedName) string {
+	return fmt.Sprintf("pipelineruns/%s/%s/", key.Namespace, key.Name)
+}
+
+// ObjectStore represents a PipelineRun data store base on S3
+type ObjectStore struct {
+	client s3client.Interface
+	prefix string
+
+	cache map[string]string
+	dirty map[string]bool
+	// keys are all the keys which have been saved
+	keys map[string]bool
+}
+
+// GetStages returns the stage data
+func (s *ObjectStore) GetStages() string {
+	return s.Get(store.DataKeyStage)
+}
+
+// SetStages stores the stage data
+func (s *ObjectStore) SetStages(stages string) {
+	s.Set(store.DataKeyStage, stages)
+}
+
+// GetStatus returns the status
+func (s *ObjectStore) GetStatus() string {
+	return s.Get(store.DataKeyStatus)
+}
+
+// SetStatus stores the status
+func (s *ObjectStore) SetStatus(status string) {
+	s.Set(store.DataKeyStatus, status)
+}
+
+// GetStepLog returns the step log
+func (s *ObjectStore) GetStepLog(stage, step int) string {
+	return s.Get(store.StepLogKey(stage, step))
+}
+
+// SetStepLog stores the step log
+func (s *ObjectStore) SetStepLog(stage, step int, log string) {
+	s.Set(store.StepLogKey(stage, step), log)
+}
+
+// GetAllLog returns the whole log
+func (s *ObjectStore) GetAllLog() string {
+	return s.Get(store.DataKeyAllLog)
+}
+
+// SetAllLog store the whole log
+func (s *ObjectStore) SetAllLog(log string) {
+	s.Set(store.DataKeyAllLog, log)
+}
+
+// Get returns the value by a key, it reads the object if it's not in the cache
+func (s *ObjectStore) Get(key string) string {
+	if value, ok := s.cache[key]; ok || !s.keys[key] {
+		return value
+	}
+
+	data, err := s.client.Read(s.objectKey(key))
+	if err != nil {
+		klog.V(4).Infof("failed to read object %s, error: %v", s.objectKey(key), err)
+		return ""
+	}
+	s.cache[key] = string(data)
+	return s.cache[key]
+}
+
+// Set puts a key and value
+func (s *ObjectStore) Set(key, value string) {
+	s.cache[key] = value
+	s.dirty[key] = true
+}
+
+// Save uploads the changed values and the index
+func (s *ObjectStore) Save() (err error) {
+	if len(s.dirty) == 0 {
+		return
+	}
+
+	for key := range s.dirty {
+		if err = s.client.Upload(s.objectKey(key), key, bytes.NewBufferString(s.cache[key])); err != nil {
+			err = fmt.Errorf("failed to upload object %s, error: %v", s.objectKey(key), err)
+			return
+		}
+		s.keys[key] = true
+		delete(s.dirty, key)
+	}
+	return s.saveIndex()
+}
+
+func (s *ObjectStore) objectKey(key string) string {
+	return s.prefix + key
+}
+
+func (s *ObjectStore) loadIndex() (err error) {
+	var data []byte
+	if data, err = s.client.Read(s.objectKey(indexKey)); err != nil {
+		if isNotFound(err) {
+			err = nil
+		}
+		return
+	}
+
+	var keys []string
+	if err = json.Unmarshal(data, &keys); err == nil {
+		for _, key := range keys {
+			s.keys[key] = true
+		}
+	}
+	return
+}
+
+func (s *ObjectStore) saveIndex() (err error) {
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var data []byte
+	if data, err = json.Marshal(keys); err == nil {
+		err = s.client.Upload(s.objectKey(indexKey), indexKey, bytes.NewBuffer(data))
+	}
+	return
+}
+
+func isNotFound(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == awss3.ErrCodeNoSuchKey
+}
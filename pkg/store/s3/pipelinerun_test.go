@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"kubesphere.io/devops/pkg/client/s3/fake"
+)
+
+func TestObjectStore(t *testing.T) {
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+	fakeS3 := fake.NewFakeS3()
+	backend := NewBackend(fakeS3)
+
+	runStore, err := backend.Open(context.Background(), key)
+	assert.Nil(t, err)
+	assert.Nil(t, runStore.Save())
+	assert.Empty(t, fakeS3.Storage)
+
+	assert.Empty(t, runStore.GetStages())
+	runStore.SetStages("stages")
+	assert.Equal(t, "stages", runStore.GetStages())
+	runStore.SetStatus("status")
+	runStore.SetStepLog(1, 2, "step")
+	runStore.SetAllLog("log")
+	assert.Nil(t, runStore.Save())
+	assert.Len(t, fakeS3.Storage, 5)
+	assert.Contains(t, fakeS3.Storage, "pipelineruns/ns/name/index")
+
+	// values are read from the objects lazily
+	runStore, err = backend.Open(context.Background(), key)
+	assert.Nil(t, err)
+	assert.Equal(t, "stages", runStore.GetStages())
+	assert.Equal(t, "status", runStore.GetStatus())
+	assert.Equal(t, "step", runStore.GetStepLog(1, 2))
+	assert.Equal(t, "log", runStore.GetAllLog())
+	assert.Empty(t, runStore.GetStepLog(2, 1))
+
+	assert.Nil(t, backend.Delete(context.Background(), key))
+	assert.Empty(t, fakeS3.Storage)
+	// delete a non-existing PipelineRun
+	assert.Nil(t, backend.Delete(context.Background(), key))
+}
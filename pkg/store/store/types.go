@@ -17,8 +17,11 @@ limitations under the License.
 package store
 
 import (
+	"context"
 	"fmt"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 const (
@@ -30,6 +33,15 @@ const (
 	DataKeyStatus = "status"
 )
 
+const (
+	// TypeConfigMap stores the PipelineRun data in a ConfigMap
+	TypeConfigMap = "configmap"
+	// TypeS3 stores the PipelineRun data in a S3-compatible object storage
+	TypeS3 = "s3"
+	// TypeFilesystem stores the PipelineRun data in a local directory, it could be a mounted PVC
+	TypeFilesystem = "filesystem"
+)
+
 // StepLogKey generates a unique key by stage and step number
 func StepLogKey(stage, step int) string {
 	return fmt.Sprintf("log-step-%d-%d", stage, step)
@@ -61,3 +73,11 @@ type ConfigMapStore interface {
 	PipelineRunDataStore
 	SetOwnerReference(owner metav1.OwnerReference)
 }
+
+// Backend represents a place where the data of PipelineRuns are kept
+type Backend interface {
+	// Open returns the data store of a PipelineRun, the existing data will be loaded
+	Open(ctx context.Context, key types.NamespacedName) (PipelineRunDataStore, error)
+	// Delete deletes all the data of a PipelineRun
+	Delete(ctx context.Context, key types.NamespacedName) error
+}
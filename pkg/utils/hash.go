@@ -17,6 +17,9 @@ limitations under the License.
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"hash"
 	"hash/fnv"
@@ -32,6 +35,14 @@ func ComputeHash(obj interface{}) string {
 	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32()))
 }
 
+// ComputeHMAC computes the HMAC-SHA256 value of a interface with the key.
+// Unlike ComputeHash, it's safe to be used on the secret values, which cannot be guessed without the key.
+func ComputeHMAC(key []byte, obj interface{}) string {
+	hasher := hmac.New(sha256.New, key)
+	deepHashObject(hasher, obj)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 // deepHashObject writes specified object to hash using the spew library
 // which follows pointers and prints actual values of the nested objects
 // ensuring the hash does not change when a pointer changes.
@@ -0,0 +1,32 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeHMAC(t *testing.T) {
+	data := map[string][]byte{"password": []byte("secret")}
+	hash := ComputeHMAC([]byte("key"), data)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, ComputeHMAC([]byte("key"), map[string][]byte{"password": []byte("secret")}))
+	assert.NotEqual(t, hash, ComputeHMAC([]byte("another key"), data))
+	assert.NotEqual(t, hash, ComputeHMAC([]byte("key"), map[string][]byte{"password": []byte("rotated")}))
+}
@@ -47,6 +47,27 @@ func kubeconfigCredentialMask(secret *v1.Secret) *v1.Secret {
 	return secret
 }
 
+func sopsCredentialMask(secret *v1.Secret) *v1.Secret {
+	secret.Data[v1alpha3.SOPSAgeKey] = defaultMasque
+	secret.Data[v1alpha3.SOPSPGPKey] = defaultMasque
+	return secret
+}
+
+func gitHubAppCredentialMask(secret *v1.Secret) *v1.Secret {
+	secret.Data[v1alpha3.GitHubAppPrivateKey] = defaultMasque
+	return secret
+}
+
+func dockerConfigJSONCredentialMask(secret *v1.Secret) *v1.Secret {
+	secret.Data[v1alpha3.DockerConfigJSONKey] = defaultMasque
+	return secret
+}
+
+func accessKeyCredentialMask(secret *v1.Secret) *v1.Secret {
+	secret.Data[v1alpha3.AccessKeySecretKey] = defaultMasque
+	return secret
+}
+
 // MaskCredential masks sensetive data inside credential.
 func MaskCredential(secret *v1.Secret) *v1.Secret {
 	if secret == nil || secret.Data == nil {
@@ -65,4 +86,8 @@ func init() {
 	credentialMaskHolder[v1alpha3.SecretTypeSSHAuth] = sshAuthCredentialMask
 	credentialMaskHolder[v1alpha3.SecretTypeSecretText] = secretTextCredentialMask
 	credentialMaskHolder[v1alpha3.SecretTypeKubeConfig] = kubeconfigCredentialMask
+	credentialMaskHolder[v1alpha3.SecretTypeSOPS] = sopsCredentialMask
+	credentialMaskHolder[v1alpha3.SecretTypeGitHubApp] = gitHubAppCredentialMask
+	credentialMaskHolder[v1alpha3.SecretTypeDockerConfigJSON] = dockerConfigJSONCredentialMask
+	credentialMaskHolder[v1alpha3.SecretTypeAccessKey] = accessKeyCredentialMask
 }
@@ -102,6 +102,78 @@ func Test_maskCredential(t *testing.T) {
 				v1alpha3.KubeConfigSecretKey: []byte(""),
 			},
 		},
+	}, {
+		name: "Mask SOPS secret",
+		args: args{
+			secret: &v1.Secret{
+				Type: v1alpha3.SecretTypeSOPS,
+				Data: map[string][]byte{
+					v1alpha3.SOPSAgeKey: []byte("fake age key"),
+					v1alpha3.SOPSPGPKey: []byte("fake pgp key"),
+				},
+			},
+		},
+		want: &v1.Secret{
+			Type: v1alpha3.SecretTypeSOPS,
+			Data: map[string][]byte{
+				v1alpha3.SOPSAgeKey: []byte(""),
+				v1alpha3.SOPSPGPKey: []byte(""),
+			},
+		},
+	}, {
+		name: "Mask GitHub App secret",
+		args: args{
+			secret: &v1.Secret{
+				Type: v1alpha3.SecretTypeGitHubApp,
+				Data: map[string][]byte{
+					v1alpha3.GitHubAppIDKey:      []byte("1"),
+					v1alpha3.GitHubAppPrivateKey: []byte("fake private key"),
+					v1alpha3.GitHubAppOwnerKey:   []byte("kubesphere"),
+				},
+			},
+		},
+		want: &v1.Secret{
+			Type: v1alpha3.SecretTypeGitHubApp,
+			Data: map[string][]byte{
+				v1alpha3.GitHubAppIDKey:      []byte("1"),
+				v1alpha3.GitHubAppPrivateKey: []byte(""),
+				v1alpha3.GitHubAppOwnerKey:   []byte("kubesphere"),
+			},
+		},
+	}, {
+		name: "Mask docker config secret",
+		args: args{
+			secret: &v1.Secret{
+				Type: v1alpha3.SecretTypeDockerConfigJSON,
+				Data: map[string][]byte{
+					v1alpha3.DockerConfigJSONKey: []byte(`{"auths":{}}`),
+				},
+			},
+		},
+		want: &v1.Secret{
+			Type: v1alpha3.SecretTypeDockerConfigJSON,
+			Data: map[string][]byte{
+				v1alpha3.DockerConfigJSONKey: []byte(""),
+			},
+		},
+	}, {
+		name: "Mask access key secret",
+		args: args{
+			secret: &v1.Secret{
+				Type: v1alpha3.SecretTypeAccessKey,
+				Data: map[string][]byte{
+					v1alpha3.AccessKeyIDKey:     []byte("fake id"),
+					v1alpha3.AccessKeySecretKey: []byte("fake secret"),
+				},
+			},
+		},
+		want: &v1.Secret{
+			Type: v1alpha3.SecretTypeAccessKey,
+			Data: map[string][]byte{
+				v1alpha3.AccessKeyIDKey:     []byte("fake id"),
+				v1alpha3.AccessKeySecretKey: []byte(""),
+			},
+		},
 	}, {
 		name: "Nil secret",
 		args: args{